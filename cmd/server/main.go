@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/arencloud/hermes/internal/api"
@@ -11,17 +15,51 @@ import (
 	"github.com/arencloud/hermes/internal/db"
 	"github.com/arencloud/hermes/internal/logging"
 	"github.com/arencloud/hermes/internal/middleware"
+	"github.com/arencloud/hermes/internal/s3"
+	"github.com/arencloud/hermes/internal/secrets"
+	"github.com/arencloud/hermes/internal/telemetry"
 )
 
 func main() {
 	cfg := config.Load()
 	logger := logging.New(cfg.Env)
+	if errs := config.Validate(cfg); len(errs) > 0 {
+		for _, err := range errs {
+			logger.Error("invalid configuration", "error", err)
+		}
+		os.Exit(2)
+	}
 
 	if err := db.Init(cfg, logger); err != nil {
 		logger.Fatal("failed to init db", "error", err)
 	}
 
+	if err := secrets.Init(cfg); err != nil {
+		logger.Fatal("failed to init secrets backend", "backend", cfg.SecretsBackend, "error", err)
+	}
+	s3.SetTransportSettings(s3.TransportSettings{
+		IdleConnTimeout:     time.Duration(cfg.S3IdleConnTimeoutSec) * time.Second,
+		MaxIdleConns:        int(cfg.S3MaxIdleConns),
+		DialTimeout:         time.Duration(cfg.S3DialTimeoutSec) * time.Second,
+		TLSHandshakeTimeout: time.Duration(cfg.S3TLSHandshakeTimeoutSec) * time.Second,
+	})
+
+	if sec := sessionSecret(cfg, logger); sec != nil {
+		api.SetSessionSecret(sec)
+	}
+
+	if ep := firstNonEmpty(cfg.OTLPMetricsEndpoint, cfg.OTLPEndpoint); ep != "" {
+		shutdown, err := telemetry.InitMetrics(context.Background(), ep)
+		if err != nil {
+			logger.Error("failed to init otlp metrics", "endpoint", ep, "error", err)
+		} else {
+			defer shutdown(context.Background())
+			logger.Info("otlp metrics export enabled", "endpoint", ep, "interval", telemetry.PushInterval.String())
+		}
+	}
+
 	r := api.Router(cfg, logger)
+	api.StartJobs(logger)
 
 	srv := &http.Server{
 		Addr:              ":" + cfg.HttpPort,
@@ -31,9 +69,70 @@ func main() {
 		WriteTimeout:      0,
 		MaxHeaderBytes:    1 << 20, // 1MB headers
 	}
-	logger.Info("server starting", "addr", srv.Addr)
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Println("server error:", err)
-		os.Exit(1)
+	logger.Info("server starting", "addr", srv.Addr, "tls", cfg.TLSCertFile != "")
+	serveErr := make(chan error, 1)
+	go func() {
+		if cfg.TLSCertFile != "" {
+			serveErr <- srv.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			serveErr <- srv.ListenAndServe()
+		}
+	}()
+	stop, cancelStop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancelStop()
+	select {
+	case err := <-serveErr:
+		if err != nil && err != http.ErrServerClosed {
+			log.Println("server error:", err)
+			os.Exit(1)
+		}
+		return
+	case <-stop.Done():
+	}
+
+	// Stop accepting connections, then give running requests, uploads and jobs the same drain
+	// deadline; uploads still running after it are aborted on their providers.
+	drain := time.Duration(cfg.ShutdownDrainSec) * time.Second
+	logger.Info("server shutting down", "drain", drain.String())
+	ctx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Error("server shutdown incomplete", "error", err)
+	}
+	if err := api.DrainUploads(ctx); err != nil {
+		logger.Error("uploads aborted at shutdown", "error", err)
+	}
+	if err := api.StopJobs(ctx); err != nil {
+		logger.Error("background jobs still running at shutdown", "error", err)
+	}
+}
+
+// sessionSecret resolves the cookie signing key. dev keeps the built-in key (nil),
+// prod refuses to start without SESSION_SECRET, and any other env falls back to a
+// random per-process key.
+func sessionSecret(cfg *config.Config, logger logging.Logger) []byte {
+	if cfg.SessionSecret != "" {
+		return []byte(cfg.SessionSecret)
+	}
+	switch cfg.Env {
+	case "dev":
+		return nil
+	case "prod":
+		logger.Fatal("SESSION_SECRET must be set in production")
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		logger.Fatal("failed to generate session secret", "error", err)
+	}
+	logger.Error("SESSION_SECRET not set; using an ephemeral secret, sessions will not survive restarts", "env", cfg.Env)
+	return b
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
 	}
+	return ""
 }
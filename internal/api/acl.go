@@ -0,0 +1,238 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	aclRead  = "read"
+	aclWrite = "write"
+)
+
+func registerBucketACL(r chi.Router) {
+	r.With(requireEditorOrAdmin).Get("/providers/{id}/buckets/{name}/acl", listBucketACL)
+	r.Group(func(gr chi.Router) {
+		gr.Use(requireAdmin)
+		gr.Post("/providers/{id}/buckets/{name}/acl", createBucketACL)
+		gr.Put("/providers/{id}/buckets/{name}/acl/{aclId}", updateBucketACL)
+		gr.Delete("/providers/{id}/buckets/{name}/acl/{aclId}", deleteBucketACL)
+	})
+}
+
+// aclAllows reports whether u may perform perm given the entries of one bucket.
+// No entries means the bucket is unrestricted; admins always pass so they can't lock
+// themselves out of a bucket they manage.
+func aclAllows(entries []models.BucketACL, u *models.User, perm string) bool {
+	if len(entries) == 0 {
+		return true
+	}
+	if u == nil {
+		return false
+	}
+	if u.Role == "admin" || u.Role == roleSuperAdmin {
+		return true
+	}
+	for _, e := range entries {
+		if e.Subject != u.Email && e.Subject != "role:"+u.Role {
+			continue
+		}
+		if e.Permission == aclWrite || e.Permission == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// aclCheck loads the ACL of a bucket and checks perm for u; see aclAllows.
+func aclCheck(gdb *gorm.DB, providerID uint, bucket string, u *models.User, perm string) (bool, error) {
+	var entries []models.BucketACL
+	if err := gdb.Where("provider_id = ? AND bucket = ?", providerID, bucket).Find(&entries).Error; err != nil {
+		return false, err
+	}
+	return aclAllows(entries, u, perm), nil
+}
+
+// enforceACL writes 403 (or 500) and returns false when the current user lacks perm on the bucket.
+func enforceACL(w http.ResponseWriter, r *http.Request, pid int, bucket, perm string) bool {
+	ok, err := aclCheck(db.DB, uint(pid), bucket, currentUser(r), perm)
+	if err != nil {
+		respondError(w, r, 500, err.Error())
+		return false
+	}
+	if !ok {
+		respondError(w, r, 403, "bucket access denied")
+		return false
+	}
+	return true
+}
+
+// validACLEntry normalises and checks an entry's subject and permission.
+func validACLEntry(e *models.BucketACL) string {
+	e.Subject = strings.TrimSpace(e.Subject)
+	e.Permission = strings.ToLower(strings.TrimSpace(e.Permission))
+	if e.Permission != aclRead && e.Permission != aclWrite {
+		return "permission must be read or write"
+	}
+	if role, ok := strings.CutPrefix(e.Subject, "role:"); ok {
+		if role != "viewer" && role != "editor" && role != "admin" {
+			return "unknown role in subject"
+		}
+		return ""
+	}
+	if !emailRe.MatchString(e.Subject) {
+		return "subject must be an email or role:<role>"
+	}
+	return ""
+}
+
+func aclParams(w http.ResponseWriter, r *http.Request) (uint, string, bool) {
+	pid, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || pid <= 0 {
+		respondError(w, r, 400, "invalid provider id")
+		return 0, "", false
+	}
+	bucket := chi.URLParam(r, "name")
+	if bucket == "" {
+		respondError(w, r, 400, "bucket is required")
+		return 0, "", false
+	}
+	return uint(pid), bucket, true
+}
+
+func listBucketACL(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	pid, bucket, ok := aclParams(w, r)
+	if !ok {
+		return
+	}
+	var entries []models.BucketACL
+	if err := readDB(r).Where("provider_id = ? AND bucket = ?", pid, bucket).Order("id").Find(&entries).Error; err != nil {
+		respondError(w, r, 500, err.Error())
+		return
+	}
+	RespondList(w, r, 200, entries, len(entries), len(entries), 0)
+}
+
+func createBucketACL(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	pid, bucket, ok := aclParams(w, r)
+	if !ok {
+		return
+	}
+	var in models.BucketACL
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondError(w, r, 400, err.Error())
+		return
+	}
+	if msg := validACLEntry(&in); msg != "" {
+		respondError(w, r, 400, msg)
+		return
+	}
+	e := models.BucketACL{ProviderID: pid, Bucket: bucket, Subject: in.Subject, Permission: in.Permission}
+	if err := db.DB.Create(&e).Error; err != nil {
+		respondError(w, r, 500, err.Error())
+		return
+	}
+	addEvent(r, "bucket.acl.create", map[string]any{"bucket": bucket, "subject": e.Subject, "permission": e.Permission})
+	RecordAudit(r.Context(), "bucket.acl.create", "bucket_acl", strconv.Itoa(int(e.ID)), map[string]any{"bucket": bucketResource(pid, bucket), "subject": e.Subject, "permission": e.Permission})
+	Respond(w, r, 201, e)
+}
+
+// findACL loads the entry named by {aclId}, making sure it belongs to the bucket in the path.
+func findACL(w http.ResponseWriter, r *http.Request) (*models.BucketACL, bool) {
+	pid, bucket, ok := aclParams(w, r)
+	if !ok {
+		return nil, false
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "aclId"))
+	if err != nil || id <= 0 {
+		respondError(w, r, 400, "invalid acl id")
+		return nil, false
+	}
+	var e models.BucketACL
+	if err := db.DB.Where("provider_id = ? AND bucket = ?", pid, bucket).First(&e, id).Error; err != nil {
+		respondError(w, r, 404, "acl entry not found")
+		return nil, false
+	}
+	return &e, true
+}
+
+func updateBucketACL(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	e, ok := findACL(w, r)
+	if !ok {
+		return
+	}
+	var in models.BucketACL
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondError(w, r, 400, err.Error())
+		return
+	}
+	if msg := validACLEntry(&in); msg != "" {
+		respondError(w, r, 400, msg)
+		return
+	}
+	before := *e
+	e.Subject, e.Permission = in.Subject, in.Permission
+	if err := db.DB.Save(e).Error; err != nil {
+		respondError(w, r, 500, err.Error())
+		return
+	}
+	addEvent(r, "bucket.acl.update", map[string]any{"bucket": e.Bucket, "subject": e.Subject, "permission": e.Permission})
+	RecordAudit(r.Context(), "bucket.acl.update", "bucket_acl", strconv.Itoa(int(e.ID)), map[string]any{"bucket": bucketResource(e.ProviderID, e.Bucket), "subject": e.Subject, "permission": e.Permission, "before": map[string]any{"subject": before.Subject, "permission": before.Permission}})
+	Respond(w, r, 200, e)
+}
+
+func deleteBucketACL(w http.ResponseWriter, r *http.Request) {
+	e, ok := findACL(w, r)
+	if !ok {
+		return
+	}
+	if err := db.DB.Delete(e).Error; err != nil {
+		respondError(w, r, 500, err.Error())
+		return
+	}
+	addEvent(r, "bucket.acl.delete", map[string]any{"bucket": e.Bucket, "subject": e.Subject})
+	RecordAudit(r.Context(), "bucket.acl.delete", "bucket_acl", strconv.Itoa(int(e.ID)), map[string]any{"bucket": bucketResource(e.ProviderID, e.Bucket), "subject": e.Subject, "permission": e.Permission})
+	w.WriteHeader(204)
+}
+
+// accessibleBuckets lists the known (synced) buckets the current user can read.
+func accessibleBuckets(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var buckets []models.Bucket
+	if err := readDB(r).Scopes(tenantScope(r)).Order("provider_id, name").Find(&buckets).Error; err != nil {
+		respondError(w, r, 500, err.Error())
+		return
+	}
+	var entries []models.BucketACL
+	if err := readDB(r).Find(&entries).Error; err != nil {
+		respondError(w, r, 500, err.Error())
+		return
+	}
+	type bucketKey struct {
+		pid  uint
+		name string
+	}
+	byBucket := map[bucketKey][]models.BucketACL{}
+	for _, e := range entries {
+		k := bucketKey{e.ProviderID, e.Bucket}
+		byBucket[k] = append(byBucket[k], e)
+	}
+	u := currentUser(r)
+	out := make([]models.Bucket, 0, len(buckets))
+	for _, b := range buckets {
+		if aclAllows(byBucket[bucketKey{b.ProviderID, b.Name}], u, aclRead) {
+			out = append(out, b)
+		}
+	}
+	RespondList(w, r, 200, out, len(out), len(out), 0)
+}
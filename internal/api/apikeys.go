@@ -0,0 +1,163 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// apiKeysEnabled gates Bearer authentication and the /auth/keys routes (API_KEYS_ENABLED).
+var apiKeysEnabled bool
+
+// An API key reads "hk_<id>_<secret>": the ID finds the row, whose KeyHash must match
+// the secret.
+const apiKeyPrefix = "hk_"
+
+const maxAPIKeyLabel = 100
+
+// registerAPIKeys adds key management under /auth. Keys are managed from a browser
+// session only, so a leaked key cannot mint more.
+func registerAPIKeys(r chi.Router) {
+	r.Group(func(kr chi.Router) {
+		kr.Use(requireSession)
+		kr.Use(requireNoPasswordChange)
+		kr.Get("/keys", listAPIKeys)
+		kr.Post("/keys", createAPIKey)
+		kr.Delete("/keys/{id}", revokeAPIKey)
+	})
+}
+
+func requireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if sessionUser(r) == nil {
+			respondError(w, r, http.StatusUnauthorized, "unauthorized", ErrCodeUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiKeyUser returns the owner of key when it is valid and unexpired, and stamps its use.
+func apiKeyUser(key string) *models.User {
+	rest, ok := strings.CutPrefix(key, apiKeyPrefix)
+	if !ok {
+		return nil
+	}
+	idPart, secretPart, ok := strings.Cut(rest, "_")
+	id, err := strconv.ParseUint(idPart, 10, 64)
+	if !ok || err != nil {
+		return nil
+	}
+	var k models.APIKey
+	if err := db.DB.First(&k, id).Error; err != nil {
+		return nil
+	}
+	now := time.Now().UTC()
+	if k.ExpiresAt != nil && !now.Before(*k.ExpiresAt) {
+		return nil
+	}
+	if bcrypt.CompareHashAndPassword([]byte(k.KeyHash), []byte(secretPart)) != nil {
+		return nil
+	}
+	var u models.User
+	if err := db.DB.First(&u, k.UserID).Error; err != nil {
+		return nil
+	}
+	if err := db.DB.Model(&k).UpdateColumn("last_used_at", now).Error; err != nil {
+		apiLogger.Error("db_warn", "msg", "api key last use not recorded", "key", k.ID, "error", err)
+	}
+	return &u
+}
+
+// listAPIKeys returns the caller's keys, without their secrets.
+func listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	keys := []models.APIKey{}
+	if err := db.DB.Where("user_id = ?", sessionUser(r).ID).Order("id").Find(&keys).Error; err != nil {
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
+		return
+	}
+	Respond(w, r, 200, keys)
+}
+
+// createAPIKey issues a key for the caller; the response is the only time it is shown.
+func createAPIKey(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var in struct {
+		Label     string     `json:"label"`
+		ExpiresAt *time.Time `json:"expiresAt"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondError(w, r, 400, err.Error(), ErrCodeInvalidRequest)
+		return
+	}
+	in.Label = strings.TrimSpace(in.Label)
+	if in.Label == "" {
+		respondError(w, r, 400, "label is required", ErrCodeMissingField)
+		return
+	}
+	if len(in.Label) > maxAPIKeyLabel {
+		respondError(w, r, 400, fmt.Sprintf("label must be at most %d characters", maxAPIKeyLabel), ErrCodeValidation)
+		return
+	}
+	if in.ExpiresAt != nil && !in.ExpiresAt.After(time.Now()) {
+		respondError(w, r, 400, "expiresAt must be in the future", ErrCodeValidation)
+		return
+	}
+	b := make([]byte, 32)
+	rand.Read(b)
+	secretPart := base64.RawURLEncoding.EncodeToString(b)
+	hash, err := bcrypt.GenerateFromPassword([]byte(secretPart), bcrypt.DefaultCost)
+	if err != nil {
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
+		return
+	}
+	k := models.APIKey{UserID: sessionUser(r).ID, KeyHash: string(hash), Label: in.Label}
+	if in.ExpiresAt != nil {
+		exp := in.ExpiresAt.UTC()
+		k.ExpiresAt = &exp
+	}
+	if err := db.DB.Create(&k).Error; err != nil {
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
+		return
+	}
+	addEvent(r, "api_key.create", map[string]any{"keyId": k.ID, "label": k.Label})
+	Respond(w, r, 201, map[string]any{
+		"id":        k.ID,
+		"label":     k.Label,
+		"key":       fmt.Sprintf("%s%d_%s", apiKeyPrefix, k.ID, secretPart),
+		"expiresAt": k.ExpiresAt,
+		"createdAt": k.CreatedAt,
+	})
+}
+
+// revokeAPIKey deletes one of the caller's keys; it stops working immediately.
+func revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id <= 0 {
+		respondError(w, r, 400, "invalid key id", ErrCodeInvalidID)
+		return
+	}
+	res := db.DB.Where("user_id = ?", sessionUser(r).ID).Delete(&models.APIKey{}, id)
+	if res.Error != nil {
+		respondError(w, r, 500, res.Error.Error(), ErrCodeInternal)
+		return
+	}
+	if res.RowsAffected == 0 {
+		respondError(w, r, 404, "key not found", ErrCodeNotFound)
+		return
+	}
+	addEvent(r, "api_key.revoke", map[string]any{"keyId": id})
+	w.WriteHeader(204)
+}
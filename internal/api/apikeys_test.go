@@ -0,0 +1,140 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arencloud/hermes/internal/config"
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+)
+
+func TestAPIKeys(t *testing.T) {
+	ts, _ := setupTestServer(t, func(c *config.Config) { c.APIKeysEnabled = true })
+	defer ts.Close()
+	cookie := loginAs(t, ts, "ci@example.com", "editor")
+	do := func(method, path, bearer string, withCookie bool, body any) (int, []byte) {
+		t.Helper()
+		var rd io.Reader
+		if body != nil {
+			b, _ := json.Marshal(body)
+			rd = bytes.NewReader(b)
+		}
+		req, _ := http.NewRequest(method, ts.URL+path, rd)
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		if withCookie {
+			req.AddCookie(cookie)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		out, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, out
+	}
+	create := func(body any) (uint, string) {
+		t.Helper()
+		code, out := do("POST", "/api/v1/auth/keys", "", true, body)
+		var created struct {
+			Data struct {
+				ID  uint   `json:"id"`
+				Key string `json:"key"`
+			} `json:"data"`
+		}
+		if json.Unmarshal(out, &created); code != 201 || !strings.HasPrefix(created.Data.Key, apiKeyPrefix) {
+			t.Fatalf("create key: %d %s", code, out)
+		}
+		return created.Data.ID, created.Data.Key
+	}
+
+	if code, out := do("POST", "/api/v1/auth/keys", "", true, map[string]any{}); code != 400 {
+		t.Fatalf("key without label: %d %s", code, out)
+	}
+	if code, out := do("POST", "/api/v1/auth/keys", "", true, map[string]any{"label": "old", "expiresAt": time.Now().Add(-time.Hour)}); code != 400 {
+		t.Fatalf("key expiring in the past: %d %s", code, out)
+	}
+	id, key := create(map[string]any{"label": "ci"})
+
+	// the key authenticates API calls on its own
+	if code, out := do("GET", "/api/v1/providers", key, false, nil); code != 200 {
+		t.Fatalf("providers with key: %d %s", code, out)
+	}
+	if code, _ := do("GET", "/api/v1/providers", key+"x", false, nil); code != 401 {
+		t.Fatalf("wrong secret: %d", code)
+	}
+	if code, _ := do("GET", "/api/v1/providers", "hk_999_abc", false, nil); code != 401 {
+		t.Fatalf("unknown key: %d", code)
+	}
+	// but cannot manage keys
+	if code, _ := do("GET", "/api/v1/auth/keys", key, false, nil); code != 401 {
+		t.Fatalf("listing keys with a key: %d", code)
+	}
+
+	code, out := do("GET", "/api/v1/auth/keys", "", true, nil)
+	var list struct {
+		Data []map[string]any `json:"data"`
+	}
+	json.Unmarshal(out, &list)
+	if code != 200 || len(list.Data) != 1 || list.Data[0]["label"] != "ci" || list.Data[0]["lastUsedAt"] == nil {
+		t.Fatalf("list keys: %d %s", code, out)
+	}
+	if strings.Contains(string(out), "keyHash") || strings.Contains(string(out), key) {
+		t.Fatalf("list leaks the key: %s", out)
+	}
+
+	// expiry is enforced
+	_, expiring := create(map[string]any{"label": "short", "expiresAt": time.Now().Add(time.Hour)})
+	if code, _ := do("GET", "/api/v1/providers", expiring, false, nil); code != 200 {
+		t.Fatalf("unexpired key: %d", code)
+	}
+	db.DB.Model(&models.APIKey{}).Where("label = ?", "short").Update("expires_at", time.Now().Add(-time.Minute))
+	if code, _ := do("GET", "/api/v1/providers", expiring, false, nil); code != 401 {
+		t.Fatalf("expired key: %d", code)
+	}
+
+	// revocation
+	if code, out := do("DELETE", fmt.Sprintf("/api/v1/auth/keys/%d", id), "", true, nil); code != 204 {
+		t.Fatalf("revoke: %d %s", code, out)
+	}
+	if code, _ := do("GET", "/api/v1/providers", key, false, nil); code != 401 {
+		t.Fatalf("revoked key: %d", code)
+	}
+	if code, _ := do("DELETE", fmt.Sprintf("/api/v1/auth/keys/%d", id), "", true, nil); code != 404 {
+		t.Fatalf("revoke twice: %d", code)
+	}
+}
+
+func TestAPIKeysDisabled(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	cookie := loginAs(t, ts, "nokeys@example.com", "editor")
+	req, _ := http.NewRequest("POST", ts.URL+"/api/v1/auth/keys", strings.NewReader(`{"label":"ci"}`))
+	req.AddCookie(cookie)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 404 && resp.StatusCode != 405 {
+		t.Fatalf("create key while disabled: %d", resp.StatusCode)
+	}
+	req, _ = http.NewRequest("GET", ts.URL+"/api/v1/providers", nil)
+	req.Header.Set("Authorization", "Bearer hk_1_secret")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 401 {
+		t.Fatalf("bearer while disabled: %d", resp.StatusCode)
+	}
+}
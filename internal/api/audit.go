@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// auditRetention is how long audit entries are kept (set in Router, AUDIT_LOG_RETENTION_DAYS).
+var auditRetention = 90 * 24 * time.Hour
+
+func registerAudit(r chi.Router) {
+	r.With(requireAdmin).Get("/audit", listAudit)
+}
+
+// RecordAudit stores a security-sensitive action with the actor taken from the request
+// trace in ctx. A failed insert is logged; it never fails the action itself.
+func RecordAudit(ctx context.Context, action, resourceType, resourceID string, detail map[string]any) {
+	if db.DB == nil {
+		return
+	}
+	e := models.AuditLog{Time: time.Now().UTC(), Action: action, ResourceType: resourceType, ResourceID: resourceID}
+	if t := traceFrom(ctx); t != nil {
+		e.ActorEmail, e.ActorRole, e.RemoteIP, e.TenantID = t.UserEmail, t.UserRole, t.RemoteIP, t.TenantID
+	}
+	// API key and provider token users are resolved after the trace starts
+	if u, ok := ctx.Value(tokenUserKey{}).(*models.User); ok && e.ActorEmail == "" {
+		e.ActorEmail, e.ActorRole, e.TenantID = u.Email, u.Role, u.TenantID
+	}
+	if len(detail) > 0 {
+		b, _ := json.Marshal(detail)
+		e.Detail = string(b)
+	}
+	if err := db.DB.Create(&e).Error; err != nil {
+		apiLogger.Error("audit record failed", "component", "audit", "action", action, "error", err)
+	}
+}
+
+// bucketResource is the audit resource ID of a bucket: "{providerId}/{bucket}".
+func bucketResource(pid uint, bucket string) string {
+	return strconv.Itoa(int(pid)) + "/" + bucket
+}
+
+// listAudit returns audit entries newest first (admin). ?before= (RFC3339) returns only
+// older entries, e.g. the time of the last entry of the previous page; ?limit= defaults
+// to 100, at most 1000.
+func listAudit(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 1000 {
+			respondError(w, r, 400, "limit must be between 1 and 1000", ErrCodeInvalidParameter)
+			return
+		}
+		limit = n
+	}
+	q := readDB(r).Scopes(tenantScope(r)).Order("time desc, id desc").Limit(limit)
+	if v := r.URL.Query().Get("before"); v != "" {
+		before, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			respondError(w, r, 400, "invalid before", ErrCodeInvalidParameter)
+			return
+		}
+		q = q.Where("time < ?", before.UTC())
+	}
+	var rows []models.AuditLog
+	if err := q.Find(&rows).Error; err != nil {
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
+		return
+	}
+	RespondList(w, r, 200, rows, len(rows), limit, 0)
+}
+
+// pruneAudit deletes audit entries older than auditRetention.
+func pruneAudit() error {
+	return db.DB.Where("time < ?", time.Now().UTC().Add(-auditRetention)).Delete(&models.AuditLog{}).Error
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+)
+
+func TestAuditLog(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	admin := loginAs(t, ts, "audit-admin@example.com", "admin")
+	viewer := loginAs(t, ts, "audit-viewer@example.com", "viewer")
+	send := func(method, path string, body any, c *http.Cookie) (int, []byte) {
+		t.Helper()
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req, _ := http.NewRequest(method, ts.URL+path, &buf)
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(c)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out bytes.Buffer
+		out.ReadFrom(resp.Body)
+		return resp.StatusCode, out.Bytes()
+	}
+
+	prov := map[string]any{"name": "audited", "type": "minio", "endpoint": "minio.local:9000", "accessKey": "ak", "secretKey": "sk-not-logged"}
+	code, body := send("POST", "/api/v1/providers?skipValidation=true", prov, admin)
+	if code != 201 {
+		t.Fatalf("create provider: %d %s", code, body)
+	}
+	var p models.Provider
+	json.Unmarshal(body, &p)
+
+	var e models.AuditLog
+	if err := db.DB.Where("action = ?", "provider.create").Last(&e).Error; err != nil {
+		t.Fatalf("no audit row for provider.create: %v", err)
+	}
+	if e.ActorEmail != "audit-admin@example.com" || e.ActorRole != "admin" || e.ResourceType != "provider" || e.ResourceID != strconv.Itoa(int(p.ID)) {
+		t.Fatalf("audit row: %+v", e)
+	}
+	if bytes.Contains([]byte(e.Detail), []byte("sk-not-logged")) {
+		t.Fatalf("secret key in audit detail: %s", e.Detail)
+	}
+
+	target := models.User{Email: "audit-target@example.com", Role: "viewer"}
+	db.DB.Create(&target)
+	if code, _ := send("PUT", "/api/v1/users/"+strconv.Itoa(int(target.ID)), map[string]any{"password": "another-secret"}, admin); code != 200 {
+		t.Fatalf("update user: %d", code)
+	}
+	var upd models.AuditLog
+	if err := db.DB.Where("action = ?", "user.update").Last(&upd).Error; err != nil || bytes.Contains([]byte(upd.Detail), []byte("another-secret")) {
+		t.Fatalf("user.update row: %+v %v", upd, err)
+	}
+
+	if code, _ := send("GET", "/api/v1/audit", nil, viewer); code != 403 {
+		t.Fatalf("viewer audit listing: %d, want 403", code)
+	}
+	list := func(query string) (int, []models.AuditLog) {
+		t.Helper()
+		code, body := send("GET", "/api/v1/audit"+query, nil, admin)
+		var env struct {
+			Data []models.AuditLog `json:"data"`
+		}
+		json.Unmarshal(body, &env)
+		return code, env.Data
+	}
+	code, rows := list("")
+	if code != 200 || len(rows) < 2 || rows[0].Action != "user.update" || rows[1].Action != "provider.create" {
+		t.Fatalf("audit listing: %d %+v", code, rows)
+	}
+	code, rows = list("?limit=1")
+	if code != 200 || len(rows) != 1 {
+		t.Fatalf("limit=1: %d %d rows", code, len(rows))
+	}
+	code, rows = list("?before=" + rows[0].Time.Format(time.RFC3339Nano))
+	if code != 200 || len(rows) == 0 || rows[0].Action != "provider.create" {
+		t.Fatalf("before: %d %+v", code, rows)
+	}
+	for _, q := range []string{"?limit=0", "?limit=5000", "?before=yesterday"} {
+		if code, _ := list(q); code != 400 {
+			t.Fatalf("%s: %d, want 400", q, code)
+		}
+	}
+
+	if code, _ := send("PUT", "/api/v1/providers/"+strconv.Itoa(int(p.ID)), map[string]any{"tags": []string{"prod"}}, admin); code != 200 {
+		t.Fatalf("update provider tags: %d", code)
+	}
+	var tags models.AuditLog
+	if err := db.DB.Where("action = ?", "provider.tags").Last(&tags).Error; err != nil || tags.ResourceID != strconv.Itoa(int(p.ID)) || !bytes.Contains([]byte(tags.Detail), []byte("prod")) {
+		t.Fatalf("provider.tags row: %+v %v", tags, err)
+	}
+}
+
+func TestPruneAudit(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	old := models.AuditLog{Time: time.Now().Add(-auditRetention - time.Hour), Action: "provider.delete"}
+	recent := models.AuditLog{Time: time.Now(), Action: "provider.create"}
+	db.DB.Create(&old)
+	db.DB.Create(&recent)
+	if err := pruneAudit(); err != nil {
+		t.Fatal(err)
+	}
+	var n int64
+	db.DB.Model(&models.AuditLog{}).Where("id = ?", old.ID).Count(&n)
+	if n != 0 {
+		t.Fatal("entry past the retention was kept")
+	}
+	db.DB.Model(&models.AuditLog{}).Where("id = ?", recent.ID).Count(&n)
+	if n != 1 {
+		t.Fatal("recent entry was pruned")
+	}
+}
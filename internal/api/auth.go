@@ -3,12 +3,16 @@ package api
 import (
 	"context"
 	"crypto/hmac"
+	crand "crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
 	"math/rand"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -21,9 +25,14 @@ import (
 	"golang.org/x/oauth2"
 )
 
-// very small in-memory session store
-var sessions = make(map[string]uint) // sessionID -> userID
-var secret = []byte("hermes-dev-secret")
+var secret = []byte("hermes-dev-secret") // dev default; replaced via SetSessionSecret outside dev
+
+// sessionTTL is how long a login session (a models.Session row) and its cookie last.
+var sessionTTL = 24 * time.Hour
+
+// SetSessionSecret sets the key used to sign session cookies. It must be called
+// before the server starts; cookies signed with a previous key stop validating.
+func SetSessionSecret(s []byte) { secret = s }
 
 func sign(value string) string {
 	h := hmac.New(sha256.New, secret)
@@ -31,8 +40,8 @@ func sign(value string) string {
 	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
 }
 
-func setSessionCookie(w http.ResponseWriter, sessionID string) {
-	cookie := &http.Cookie{Name: "dsess", Value: sessionID + "." + sign(sessionID), Path: "/", HttpOnly: true, SameSite: http.SameSiteLaxMode, Expires: time.Now().Add(24 * time.Hour)}
+func setSessionCookie(w http.ResponseWriter, s models.Session) {
+	cookie := &http.Cookie{Name: "dsess", Value: s.ID + "." + sign(s.ID), Path: "/", HttpOnly: true, SameSite: http.SameSiteLaxMode, Expires: s.ExpiresAt}
 	http.SetCookie(w, cookie)
 }
 
@@ -41,40 +50,63 @@ func clearSessionCookie(w http.ResponseWriter) {
 }
 
 func currentUser(r *http.Request) *models.User {
+	if u, ok := r.Context().Value(tokenUserKey{}).(*models.User); ok {
+		return u // set by providerTokenAuth or, for an API key, requireAuth
+	}
+	return sessionUser(r)
+}
+
+// sessionUser is the user signed in with the dsess cookie, ignoring tokens and API keys.
+func sessionUser(r *http.Request) *models.User {
 	c, err := r.Cookie("dsess")
 	if err != nil {
 		return nil
 	}
-	parts := c.Value
-	var sid, sig string
-	for i := 0; i < len(parts); i++ {
-		if parts[i] == '.' {
-			sid = parts[:i]
-			sig = parts[i+1:]
-			break
-		}
-	}
-	if sid == "" || sig == "" || sign(sid) != sig {
-		return nil
-	}
-	uid, ok := sessions[sid]
+	sid, ok := verifySessionCookie(c.Value)
 	if !ok {
 		return nil
 	}
 	var u models.User
-	if err := db.DB.First(&u, uid).Error; err != nil {
+	live := db.DB.Model(&models.Session{}).Select("user_id").Where("id = ? AND expires_at > ?", sid, time.Now().UTC())
+	if err := db.DB.Where("id = (?)", live).First(&u).Error; err != nil {
 		return nil
 	}
 	return &u
 }
 
+// verifySessionCookie splits a "<sessionID>.<signature>" cookie value and checks the
+// signature. The signature is base64url (no dots), so the split is on the last dot.
+func verifySessionCookie(v string) (string, bool) {
+	i := strings.LastIndexByte(v, '.')
+	if i <= 0 || i == len(v)-1 {
+		return "", false
+	}
+	sid, sig := v[:i], v[i+1:]
+	if !hmac.Equal([]byte(sign(sid)), []byte(sig)) {
+		return "", false
+	}
+	return sid, true
+}
+
+// requireAuth lets through requests with a session or provider token and, when API keys
+// are enabled, requests with a valid "Authorization: Bearer <key>"; the key's user is then
+// stored in the context so later currentUser calls do not verify it again.
 func requireAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if u := currentUser(r); u != nil {
 			next.ServeHTTP(w, r)
 			return
 		}
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		if key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && apiKeysEnabled {
+			u := apiKeyUser(key)
+			if u == nil {
+				respondError(w, r, http.StatusUnauthorized, "invalid or expired API key", ErrCodeUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tokenUserKey{}, u)))
+			return
+		}
+		respondError(w, r, http.StatusUnauthorized, "unauthorized", ErrCodeUnauthorized)
 	})
 }
 
@@ -82,11 +114,37 @@ func requireAdmin(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		u := currentUser(r)
 		if u == nil {
-			http.Error(w, "unauthorized", 401)
+			respondError(w, r, 401, "unauthorized", ErrCodeUnauthorized)
 			return
 		}
-		if u.Role != "admin" {
-			http.Error(w, "forbidden", 403)
+		if u.Role != "admin" && u.Role != roleSuperAdmin {
+			respondError(w, r, 403, "forbidden", ErrCodeForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireSuperAdmin guards settings that affect the whole server rather than one tenant:
+// with MULTI_TENANT only super-admins pass, otherwise it is requireAdmin.
+func requireSuperAdmin(next http.Handler) http.Handler {
+	return requireAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if multiTenant && !isSuperAdmin(r) {
+			respondError(w, r, 403, "forbidden", ErrCodeForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// requireNoPasswordChange blocks users flagged with MustChangePassword until they change it.
+// /auth/me and /auth/change-password stay reachable since they are outside the protected group.
+func requireNoPasswordChange(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u := currentUser(r); u != nil && u.MustChangePassword {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(403)
+			json.NewEncoder(w).Encode(map[string]string{"error": "password_change_required", "message": "You must change your password before continuing"})
 			return
 		}
 		next.ServeHTTP(w, r)
@@ -98,11 +156,11 @@ func requireEditorOrAdmin(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		u := currentUser(r)
 		if u == nil {
-			http.Error(w, "unauthorized", 401)
+			respondError(w, r, 401, "unauthorized", ErrCodeUnauthorized)
 			return
 		}
-		if u.Role != "admin" && u.Role != "editor" {
-			http.Error(w, "forbidden", 403)
+		if u.Role != "admin" && u.Role != "editor" && u.Role != roleSuperAdmin {
+			respondError(w, r, 403, "forbidden", ErrCodeForbidden)
 			return
 		}
 		next.ServeHTTP(w, r)
@@ -122,65 +180,129 @@ func registerAuth(r chi.Router, logger interface{}) {
 		// Federation config (admin-only) and OIDC endpoints under /auth
 		r.Group(func(ar chi.Router) {
 			ar.Use(requireAuth)
-			ar.Use(requireAdmin)
+			ar.Use(requireNoPasswordChange)
+			ar.Use(requireSuperAdmin)
 			ar.Get("/fed/config", getAuthConfig)
 			ar.Put("/fed/config", updateAuthConfig)
 		})
 		r.Get("/oidc/start", oidcStart)
 		r.Get("/oidc/callback", oidcCallback)
+		if apiKeysEnabled {
+			registerAPIKeys(r)
+		}
 	})
 }
 
+// createSession stores a new session for uid, valid for sessionTTL. The ID is random, as
+// it is all a cookie needs besides the signature.
+func createSession(uid uint) (models.Session, error) {
+	b := make([]byte, 32)
+	crand.Read(b)
+	now := time.Now().UTC()
+	s := models.Session{ID: base64.RawURLEncoding.EncodeToString(b), UserID: uid, CreatedAt: now, ExpiresAt: now.Add(sessionTTL)}
+	return s, db.DB.Create(&s).Error
+}
+
+// sessionCounts returns the number of active sessions per user ID.
+func sessionCounts() map[uint]int {
+	var rows []struct {
+		UserID uint
+		N      int
+	}
+	db.DB.Model(&models.Session{}).Select("user_id, count(*) AS n").Where("expires_at > ?", time.Now().UTC()).Group("user_id").Scan(&rows)
+	out := map[uint]int{}
+	for _, r := range rows {
+		out[r.UserID] = r.N
+	}
+	return out
+}
+
+// pruneSessions deletes expired sessions; they are already ignored by currentUser.
+func pruneSessions() error {
+	return db.DB.Where("expires_at <= ?", time.Now().UTC()).Delete(&models.Session{}).Error
+}
+
 func login(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	var in struct{ Email, Password string }
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-		http.Error(w, err.Error(), 400)
+		respondError(w, r, 400, err.Error(), ErrCodeInvalidRequest)
 		return
 	}
 	var u models.User
+	ip := requestIP(r)
 	if err := db.DB.Where("email = ?", in.Email).First(&u).Error; err != nil {
-		http.Error(w, "invalid credentials", 401)
+		recordLoginAttempt(0, in.Email, ip, false)
+		respondError(w, r, 401, "invalid credentials", ErrCodeInvalidCredentials)
 		return
 	}
 	if bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(in.Password)) != nil {
-		http.Error(w, "invalid credentials", 401)
+		recordLoginAttempt(u.ID, u.Email, ip, false)
+		respondError(w, r, 401, "invalid credentials", ErrCodeInvalidCredentials)
+		return
+	}
+	noteLogin(&u, ip)
+	s, err := createSession(u.ID)
+	if err != nil {
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
 		return
 	}
-	// create session
-	sid := base64.RawURLEncoding.EncodeToString([]byte(time.Now().Format(time.RFC3339Nano) + in.Email))
-	sessions[sid] = u.ID
-	setSessionCookie(w, sid)
+	setSessionCookie(w, s)
 	json.NewEncoder(w).Encode(map[string]any{"id": u.ID, "email": u.Email, "role": u.Role, "mustChangePassword": u.MustChangePassword})
 }
 
+// recordLoginAttempt stores the outcome of a password login; failures to write are only logged.
+func recordLoginAttempt(uid uint, email, ip string, success bool) {
+	a := models.LoginAttempt{UserID: uid, Email: strings.ToLower(email), Success: success, IP: ip}
+	if err := db.DB.Create(&a).Error; err != nil {
+		apiLogger.Error("db_warn", "msg", "login attempt not recorded", "email", email, "error", err)
+	}
+}
+
+// noteLogin records a successful login and stamps the user's last login time and address.
+func noteLogin(u *models.User, ip string) {
+	recordLoginAttempt(u.ID, u.Email, ip, true)
+	if err := db.DB.Model(u).UpdateColumns(map[string]any{"last_login_at": time.Now().UTC(), "last_login_ip": ip}).Error; err != nil {
+		apiLogger.Error("db_warn", "msg", "last login update failed", "user", u.ID, "error", err)
+	}
+}
+
+// requestIP is the client address as recorded on traces.
+func requestIP(r *http.Request) string {
+	if t := traceFrom(r.Context()); t != nil {
+		return t.RemoteIP
+	}
+	return clientIP(r)
+}
+
 func changePassword(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	u := currentUser(r)
 	if u == nil {
-		http.Error(w, "unauthorized", 401)
+		respondError(w, r, 401, "unauthorized", ErrCodeUnauthorized)
 		return
 	}
 	var in struct{ OldPassword, NewPassword string }
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-		http.Error(w, err.Error(), 400)
+		respondError(w, r, 400, err.Error(), ErrCodeInvalidRequest)
 		return
 	}
 	if len(in.NewPassword) < 8 {
-		http.Error(w, "password too short", 400)
+		respondError(w, r, 400, "password too short", ErrCodeWeakPassword)
 		return
 	}
 	if bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(in.OldPassword)) != nil {
-		http.Error(w, "invalid old password", 400)
+		respondError(w, r, 400, "invalid old password", ErrCodeInvalidCredentials)
 		return
 	}
 	hash, _ := bcrypt.GenerateFromPassword([]byte(in.NewPassword), bcrypt.DefaultCost)
 	u.Password = string(hash)
 	u.MustChangePassword = false
 	if err := db.DB.Save(u).Error; err != nil {
-		http.Error(w, err.Error(), 500)
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
 		return
 	}
+	RecordAudit(r.Context(), "user.password_change", "user", strconv.Itoa(int(u.ID)), nil)
 	json.NewEncoder(w).Encode(map[string]any{"ok": true})
 }
 
@@ -188,25 +310,16 @@ func me(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	u := currentUser(r)
 	if u == nil {
-		http.Error(w, "unauthorized", 401)
+		respondError(w, r, 401, "unauthorized", ErrCodeUnauthorized)
 		return
 	}
-	json.NewEncoder(w).Encode(map[string]any{"id": u.ID, "email": u.Email, "role": u.Role, "mustChangePassword": u.MustChangePassword})
+	json.NewEncoder(w).Encode(map[string]any{"id": u.ID, "email": u.Email, "role": u.Role, "mustChangePassword": u.MustChangePassword, "preferencesUrl": "/api/v1/me/preferences"})
 }
 
 func logout(w http.ResponseWriter, r *http.Request) {
-	c, err := r.Cookie("dsess")
-	if err == nil {
-		val := c.Value
-		var sid string
-		for i := 0; i < len(val); i++ {
-			if val[i] == '.' {
-				sid = val[:i]
-				break
-			}
-		}
-		if sid != "" {
-			delete(sessions, sid)
+	if c, err := r.Cookie("dsess"); err == nil {
+		if sid, ok := verifySessionCookie(c.Value); ok {
+			db.DB.Delete(&models.Session{}, "id = ?", sid)
 		}
 	}
 	clearSessionCookie(w)
@@ -228,7 +341,7 @@ func getAuthConfig(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	var ac models.AuthConfig
 	if err := db.DB.First(&ac).Error; err != nil {
-		http.Error(w, err.Error(), 500)
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
 		return
 	}
 	json.NewEncoder(w).Encode(ac)
@@ -258,12 +371,12 @@ func updateAuthConfig(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	var ac models.AuthConfig
 	if err := db.DB.First(&ac).Error; err != nil {
-		http.Error(w, err.Error(), 500)
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
 		return
 	}
 	var in map[string]any
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-		http.Error(w, err.Error(), 400)
+		respondError(w, r, 400, err.Error(), ErrCodeInvalidRequest)
 		return
 	}
 	if v, ok := in["mode"].(string); ok {
@@ -331,9 +444,16 @@ func updateAuthConfig(w http.ResponseWriter, r *http.Request) {
 		ac.DefaultRole = "viewer"
 	}
 	if err := db.DB.Save(&ac).Error; err != nil {
-		http.Error(w, err.Error(), 500)
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
 		return
 	}
+	// only the names of the submitted fields: values may include the client secret
+	fields := make([]string, 0, len(in))
+	for k := range in {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	RecordAudit(r.Context(), "auth_config.update", "auth_config", strconv.Itoa(int(ac.ID)), map[string]any{"mode": ac.Mode, "enabled": ac.Enabled, "fields": fields})
 	json.NewEncoder(w).Encode(ac)
 }
 
@@ -341,22 +461,22 @@ func updateAuthConfig(w http.ResponseWriter, r *http.Request) {
 func oidcStart(w http.ResponseWriter, r *http.Request) {
 	var ac models.AuthConfig
 	if err := db.DB.First(&ac).Error; err != nil {
-		http.Error(w, "not configured", 400)
+		respondError(w, r, 400, "not configured", ErrCodeAuthNotConfigured)
 		return
 	}
 	if !ac.Enabled || ac.Mode != "oidc" {
-		http.Error(w, "oidc not enabled", 400)
+		respondError(w, r, 400, "oidc not enabled", ErrCodeAuthNotConfigured)
 		return
 	}
 	if ac.OIDCIssuer == "" || ac.OIDCClientID == "" || ac.OIDCRedirectURL == "" {
-		http.Error(w, "missing oidc parameters", 400)
+		respondError(w, r, 400, "missing oidc parameters", ErrCodeAuthNotConfigured)
 		return
 	}
 	conf := oauth2.Config{ClientID: ac.OIDCClientID, ClientSecret: ac.OIDCClientSecret, RedirectURL: ac.OIDCRedirectURL, Scopes: strings.Fields(ac.OIDCScope)}
 	ctx := context.Background()
 	provider, err := oidc.NewProvider(ctx, ac.OIDCIssuer)
 	if err != nil {
-		http.Error(w, "failed to discover issuer: "+err.Error(), 500)
+		respondError(w, r, 500, "failed to discover issuer: "+err.Error(), ErrCodeOIDCFailed, ErrCodeOIDCFailed)
 		return
 	}
 	var ep oauth2.Endpoint
@@ -377,28 +497,28 @@ func oidcStart(w http.ResponseWriter, r *http.Request) {
 func oidcCallback(w http.ResponseWriter, r *http.Request) {
 	var ac models.AuthConfig
 	if err := db.DB.First(&ac).Error; err != nil {
-		http.Error(w, "not configured", 400)
+		respondError(w, r, 400, "not configured", ErrCodeAuthNotConfigured)
 		return
 	}
 	if !ac.Enabled || ac.Mode != "oidc" {
-		http.Error(w, "oidc not enabled", 400)
+		respondError(w, r, 400, "oidc not enabled", ErrCodeAuthNotConfigured)
 		return
 	}
 	state := r.URL.Query().Get("state")
 	code := r.URL.Query().Get("code")
 	if state == "" || code == "" {
-		http.Error(w, "invalid callback", 400)
+		respondError(w, r, 400, "invalid callback", ErrCodeOIDCFailed)
 		return
 	}
 	if !checkTempCookie(r, "ds_oidc_state", state) {
-		http.Error(w, "state mismatch", 400)
+		respondError(w, r, 400, "state mismatch", ErrCodeOIDCFailed)
 		return
 	}
 	nonce := getTempCookie(r, "ds_oidc_nonce")
 	ctx := context.Background()
 	provider, err := oidc.NewProvider(ctx, ac.OIDCIssuer)
 	if err != nil {
-		http.Error(w, "issuer discovery failed", 500)
+		respondError(w, r, 500, "issuer discovery failed", ErrCodeOIDCFailed)
 		return
 	}
 	conf := oauth2.Config{ClientID: ac.OIDCClientID, ClientSecret: ac.OIDCClientSecret, RedirectURL: ac.OIDCRedirectURL, Scopes: strings.Fields(ac.OIDCScope)}
@@ -408,18 +528,18 @@ func oidcCallback(w http.ResponseWriter, r *http.Request) {
 	}
 	tok, err := conf.Exchange(ctx, code)
 	if err != nil {
-		http.Error(w, "token exchange failed", 400)
+		respondError(w, r, 400, "token exchange failed", ErrCodeOIDCFailed)
 		return
 	}
 	rawIDToken, ok := tok.Extra("id_token").(string)
 	if !ok || rawIDToken == "" {
-		http.Error(w, "missing id_token", 400)
+		respondError(w, r, 400, "missing id_token", ErrCodeOIDCFailed)
 		return
 	}
 	verifier := provider.Verifier(&oidc.Config{ClientID: ac.OIDCClientID})
 	idTok, err := verifier.Verify(ctx, rawIDToken)
 	if err != nil {
-		http.Error(w, "invalid id_token", 400)
+		respondError(w, r, 400, "invalid id_token", ErrCodeOIDCFailed)
 		return
 	}
 	var claims struct {
@@ -429,7 +549,7 @@ func oidcCallback(w http.ResponseWriter, r *http.Request) {
 	}
 	_ = idTok.Claims(&claims)
 	if nonce != "" && claims.Nonce != "" && claims.Nonce != nonce {
-		http.Error(w, "nonce mismatch", 400)
+		respondError(w, r, 400, "nonce mismatch", ErrCodeOIDCFailed)
 		return
 	}
 	// Extract full claim set for role/group mapping
@@ -437,7 +557,7 @@ func oidcCallback(w http.ResponseWriter, r *http.Request) {
 	_ = idTok.Claims(&raw)
 	email := strings.ToLower(strings.TrimSpace(firstNonEmpty(claims.Email, claims.PreferredUsername)))
 	if email == "" {
-		http.Error(w, "email claim required", 400)
+		respondError(w, r, 400, "email claim required", ErrCodeOIDCFailed)
 		return
 	}
 	// Derive role from configured claim mapping
@@ -449,7 +569,7 @@ func oidcCallback(w http.ResponseWriter, r *http.Request) {
 	if err := db.DB.Where("email = ?", email).First(&u).Error; err != nil {
 		u = models.User{Email: email, Role: mappedRole}
 		if err := db.DB.Create(&u).Error; err != nil {
-			http.Error(w, "failed to create user", 500)
+			respondError(w, r, 500, "failed to create user", ErrCodeInternal)
 			return
 		}
 	} else if ac.OIDCUpdateRoleOnLogin {
@@ -457,9 +577,13 @@ func oidcCallback(w http.ResponseWriter, r *http.Request) {
 		u.Role = mappedRole
 		_ = db.DB.Save(&u).Error
 	}
-	sid := base64.RawURLEncoding.EncodeToString([]byte(time.Now().Format(time.RFC3339Nano) + email))
-	sessions[sid] = u.ID
-	setSessionCookie(w, sid)
+	noteLogin(&u, requestIP(r))
+	s, err := createSession(u.ID)
+	if err != nil {
+		respondError(w, r, 500, "failed to create session", ErrCodeInternal)
+		return
+	}
+	setSessionCookie(w, s)
 	http.Redirect(w, r, "/#/dashboard", http.StatusFound)
 }
 
@@ -482,8 +606,12 @@ func getTempCookie(r *http.Request, name string) string {
 	v, _ := url.QueryUnescape(c.Value)
 	return v
 }
+
+// checkTempCookie reports whether the cookie holds expected; a missing cookie or an empty
+// expected value never matches.
 func checkTempCookie(r *http.Request, name, expected string) bool {
-	return getTempCookie(r, name) == expected
+	v := getTempCookie(r, name)
+	return expected != "" && subtle.ConstantTimeCompare([]byte(v), []byte(expected)) == 1
 }
 
 func randToken(n int) string {
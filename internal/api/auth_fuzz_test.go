@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/arencloud/hermes/internal/models"
+)
+
+func FuzzSign(f *testing.F) {
+	f.Add("abc123", uint8(0))
+	f.Add("multi.dot.session", uint8(7))
+	f.Add("", uint8(3))
+	f.Add("\x00\xff.é", uint8(42))
+	f.Fuzz(func(t *testing.T, sid string, pos uint8) {
+		sig := sign(sid)
+		if sign(sid) != sig {
+			t.Fatalf("sign(%q) is not reproducible", sid)
+		}
+		got, ok := verifySessionCookie(sid + "." + sig)
+		if sid != "" && (!ok || got != sid) {
+			t.Fatalf("valid cookie for %q rejected (got %q, %v)", sid, got, ok)
+		}
+		// flip one signature character to another base64url character
+		b := []byte(sig)
+		i := int(pos) % len(b)
+		if b[i] == 'A' {
+			b[i] = 'B'
+		} else {
+			b[i] = 'A'
+		}
+		if _, ok := verifySessionCookie(sid + "." + string(b)); ok {
+			t.Fatalf("tampered signature accepted for %q", sid)
+		}
+		if _, ok := verifySessionCookie(sid); ok && sid != "" {
+			t.Fatalf("unsigned value %q accepted", sid)
+		}
+	})
+}
+
+func FuzzOIDCState(f *testing.F) {
+	f.Add("state123", "state123")
+	f.Add("state123", "state124")
+	f.Add("", "")
+	f.Add("a b;c=d", "a b;c=d")
+	f.Fuzz(func(t *testing.T, state, cookie string) {
+		rec := httptest.NewRecorder()
+		setTempCookie(rec, "ds_oidc_state", cookie)
+		r := httptest.NewRequest("GET", "/api/v1/auth/oidc/callback", nil)
+		for _, c := range rec.Result().Cookies() {
+			r.AddCookie(&http.Cookie{Name: c.Name, Value: c.Value})
+		}
+		ok := checkTempCookie(r, "ds_oidc_state", state)
+		if ok && (state == "" || state != cookie) {
+			t.Fatalf("state %q accepted against cookie %q", state, cookie)
+		}
+		if !ok && state != "" && state == cookie {
+			t.Fatalf("matching state %q rejected", state)
+		}
+	})
+}
+
+func FuzzMapClaimsToRole(f *testing.F) {
+	f.Add([]byte(`{"roles":["Admin"],"groups":"devs"}`), "roles", "groups", "admin", "devs", "")
+	f.Add([]byte(`{"roles":[1,null,{"x":"y"}]}`), "roles", "", "1", "", "viewer")
+	f.Add([]byte(`null`), "", "", "", "", "")
+	f.Fuzz(func(t *testing.T, claimsJSON []byte, roleClaim, groupClaim, admins, editors, viewers string) {
+		var claims map[string]any
+		if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+			return
+		}
+		ac := models.AuthConfig{OIDCRoleClaim: roleClaim, OIDCGroupClaim: groupClaim, OIDCAdminValues: admins, OIDCEditorValues: editors, OIDCViewerValues: viewers}
+		switch role := mapClaimsToRole(claims, ac); role {
+		case "", "admin", "editor", "viewer":
+		default:
+			t.Fatalf("unexpected role %q", role)
+		}
+	})
+}
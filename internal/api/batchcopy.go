@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+	"github.com/arencloud/hermes/internal/s3"
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	batchCopyMaxOps      = 100
+	batchCopyMaxParallel = 8
+)
+
+func registerBatchCopy(r chi.Router) {
+	r.With(requireEditorOrAdmin, noAPITimeout).Post("/providers/{id}/buckets/{name}/batch-copy", batchCopy)
+}
+
+type batchCopyOp struct {
+	SrcKey        string `json:"srcKey"`
+	DstBucket     string `json:"dstBucket"`
+	DstKey        string `json:"dstKey"`
+	DstProviderID int    `json:"dstProviderId"`
+}
+
+type batchCopyResult struct {
+	SrcKey string `json:"srcKey"`
+	OK     bool   `json:"ok"`
+	Error  string `json:"error"`
+}
+
+// batchCopy copies up to batchCopyMaxOps objects out of the bucket, maxParallel at a time.
+// Same-provider copies are server-side; cross-provider copies stream through Hermes.
+// A failed operation is reported in its result and does not stop the others.
+func batchCopy(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	pid, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || pid <= 0 {
+		respondError(w, r, 400, "invalid provider id")
+		return
+	}
+	srcBucket := chi.URLParam(r, "name")
+	var in struct {
+		Operations  []batchCopyOp `json:"operations"`
+		MaxParallel int           `json:"maxParallel"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondError(w, r, 400, err.Error())
+		return
+	}
+	if len(in.Operations) == 0 || len(in.Operations) > batchCopyMaxOps {
+		respondError(w, r, 400, fmt.Sprintf("operations must contain 1 to %d entries", batchCopyMaxOps))
+		return
+	}
+	for i, op := range in.Operations {
+		if op.SrcKey == "" || op.DstBucket == "" {
+			respondError(w, r, 400, fmt.Sprintf("operations[%d]: srcKey and dstBucket are required", i))
+			return
+		}
+	}
+	parallel := in.MaxParallel
+	if parallel <= 0 {
+		parallel = 4
+	}
+	parallel = min(parallel, batchCopyMaxParallel)
+	if !enforceACL(w, r, pid, srcBucket, aclRead) {
+		return
+	}
+	src, _, err := getClient(pid)
+	if err != nil {
+		respondError(w, r, 404, "provider not found")
+		return
+	}
+
+	// Resolve destinations up front so each provider is looked up (and checked) once.
+	u := currentUser(r)
+	clients := map[int]s3.ClientInterface{pid: src}
+	results := make([]batchCopyResult, len(in.Operations))
+	limits := make([]int64, len(in.Operations))
+	for i, op := range in.Operations {
+		results[i].SrcKey = op.SrcKey
+		dpid := op.DstProviderID
+		if dpid == 0 {
+			dpid = pid
+		}
+		if _, ok := clients[dpid]; !ok {
+			var p models.Provider
+			if err := db.DB.Scopes(tenantScope(r)).First(&p, dpid).Error; err == nil {
+				clients[dpid], _, _ = getClient(dpid)
+			} else {
+				clients[dpid] = nil
+			}
+		}
+		if clients[dpid] == nil {
+			results[i].Error = "destination provider not found"
+			continue
+		}
+		if ok, err := aclCheck(readDB(r), uint(dpid), op.DstBucket, u, aclWrite); err != nil || !ok {
+			results[i].Error = "write access to the destination bucket denied"
+			continue
+		}
+		limits[i] = objectSizeLimit(uint(dpid), op.DstBucket)
+	}
+
+	var success, failed atomic.Int32
+	var g errgroup.Group
+	g.SetLimit(parallel)
+	for i, op := range in.Operations {
+		if results[i].Error != "" {
+			failed.Add(1)
+			continue
+		}
+		dpid := op.DstProviderID
+		if dpid == 0 {
+			dpid = pid
+		}
+		dst := clients[dpid]
+		g.Go(func() error {
+			if err := copyBetween(r.Context(), src, dst, dpid == pid, srcBucket, op, limits[i]); err != nil {
+				results[i].Error = err.Error()
+				failed.Add(1)
+				return nil
+			}
+			results[i].OK = true
+			success.Add(1)
+			return nil
+		})
+	}
+	g.Wait()
+	addEvent(r, "objects.batch_copy", map[string]any{"bucket": srcBucket, "count": len(results), "success": success.Load(), "failed": failed.Load()})
+	Respond(w, r, 200, map[string]any{"results": results, "success": success.Load(), "failed": failed.Load()})
+}
+
+// copyBetween copies one object: server-side within a provider, download+upload across
+// providers. Cross-provider copies larger than limit (when positive) are refused.
+func copyBetween(ctx context.Context, src, dst s3.ClientInterface, sameProvider bool, srcBucket string, op batchCopyOp, limit int64) error {
+	dstKey := op.DstKey
+	if dstKey == "" {
+		dstKey = op.SrcKey
+	}
+	if sameProvider {
+		return src.CopyObject(ctx, srcBucket, op.SrcKey, op.DstBucket, dstKey)
+	}
+	rc, size, err := src.DownloadWithInfo(ctx, srcBucket, op.SrcKey)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if limit > 0 && size > limit {
+		return fmt.Errorf("object is %d bytes, the destination bucket accepts at most %d", size, limit)
+	}
+	if size <= 0 {
+		size = -1
+	}
+	_, err = dst.Upload(ctx, op.DstBucket, dstKey, rc, size, "application/octet-stream")
+	return err
+}
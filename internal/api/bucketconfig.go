@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+func registerBucketConfig(r chi.Router) {
+	r.Get("/providers/{id}/buckets/{name}/config", getBucketConfig)
+	r.With(requireAdmin).Put("/providers/{id}/buckets/{name}/config", putBucketConfig)
+}
+
+type bucketConfig struct {
+	MaxObjectSizeBytes int64 `json:"maxObjectSizeBytes"`
+}
+
+// objectSizeLimit returns the largest object accepted into bucket: the bucket's own
+// limit or the global MAX_UPLOAD_SIZE_BYTES, whichever is smaller. 0 means unlimited.
+func objectSizeLimit(pid uint, bucket string) int64 {
+	limit := maxUploadSizeBytes
+	var b models.Bucket
+	if err := db.DB.Where("provider_id = ? AND name = ?", pid, bucket).First(&b).Error; err == nil && b.MaxObjectSizeBytes > 0 {
+		if limit <= 0 || b.MaxObjectSizeBytes < limit {
+			limit = b.MaxObjectSizeBytes
+		}
+	}
+	return limit
+}
+
+// tooLarge reports whether err comes from a body cut off by http.MaxBytesReader.
+func tooLarge(err error) bool {
+	var mbe *http.MaxBytesError
+	return errors.As(err, &mbe) || strings.Contains(strings.ToLower(err.Error()), "request body too large")
+}
+
+func getBucketConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	pid, bucket, ok := aclParams(w, r)
+	if !ok || !enforceACL(w, r, int(pid), bucket, aclRead) {
+		return
+	}
+	var b models.Bucket
+	_ = readDB(r).Where("provider_id = ? AND name = ?", pid, bucket).First(&b).Error
+	Respond(w, r, 200, bucketConfig{MaxObjectSizeBytes: b.MaxObjectSizeBytes})
+}
+
+// putBucketConfig stores the bucket settings, creating the bucket row if Hermes has not
+// recorded the bucket yet.
+func putBucketConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	pid, bucket, ok := aclParams(w, r)
+	if !ok {
+		return
+	}
+	var in bucketConfig
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondError(w, r, 400, err.Error())
+		return
+	}
+	if in.MaxObjectSizeBytes < 0 {
+		respondError(w, r, 400, "maxObjectSizeBytes must be 0 (no bucket limit) or positive")
+		return
+	}
+	var b models.Bucket
+	if err := db.DB.Where("provider_id = ? AND name = ?", pid, bucket).First(&b).Error; err != nil {
+		b = models.Bucket{ProviderID: pid, Name: bucket, TenantID: tenantFromCtx(r)}
+	}
+	b.MaxObjectSizeBytes = in.MaxObjectSizeBytes
+	if err := db.DB.Save(&b).Error; err != nil {
+		respondError(w, r, 500, err.Error())
+		return
+	}
+	addEvent(r, "bucket.config", map[string]any{"bucket": bucket, "maxObjectSizeBytes": in.MaxObjectSizeBytes})
+	Respond(w, r, 200, in)
+}
@@ -1,31 +1,31 @@
 package api
 
 import (
+	"cmp"
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/arencloud/hermes/internal/concurrency"
 	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/middleware"
 	"github.com/arencloud/hermes/internal/models"
 	"github.com/arencloud/hermes/internal/s3"
 
 	"github.com/go-chi/chi/v5"
+	minio "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+	"gorm.io/gorm"
 )
 
-// countingWriter is a tiny io.Writer that invokes a callback with the number of bytes written.
-// It allows us to use io.TeeReader to count bytes as they stream without extra buffers/goroutines.
-type countingWriter struct{ on func(int) }
-
-func (w countingWriter) Write(p []byte) (int, error) {
-	if w.on != nil {
-		w.on(len(p))
-	}
-	return len(p), nil
-}
-
 func registerBuckets(r chi.Router) {
 	r.Get("/providers/{id}/buckets/db", listBucketsFromDB)
 	r.Get("/providers/{id}/buckets", listBuckets)
@@ -36,17 +36,91 @@ func registerBuckets(r chi.Router) {
 		gr.Delete("/providers/{id}/buckets/{name}", deleteBucket)
 		// objects (mutating)
 		gr.Delete("/providers/{id}/buckets/{name}/objects", deleteObject)
-		gr.Post("/providers/{id}/buckets/{name}/upload", uploadObject)
+		gr.With(noAPITimeout).Delete("/providers/{id}/buckets/{name}/objects/bulk", deleteObjects)
+		gr.With(noAPITimeout).Post("/providers/{id}/buckets/{name}/upload", uploadObject)
+		gr.With(noAPITimeout).Post("/providers/{id}/buckets/{name}/upload-url", uploadFromURL)
 		// copy/move between buckets (same provider)
-		gr.Post("/providers/{id}/buckets/{name}/move", moveObject)
-		gr.Post("/providers/{id}/buckets/{name}/copy", copyObject)
+		gr.With(noAPITimeout).Post("/providers/{id}/buckets/{name}/move", moveObject)
+		gr.With(noAPITimeout).Post("/providers/{id}/buckets/{name}/copy", copyObject)
+		gr.With(noAPITimeout).Post("/providers/{id}/buckets/{name}/objects/select", selectObject)
+		gr.Post("/providers/{id}/buckets/{name}/presign-upload", presignUpload)
+		gr.Put("/providers/{id}/buckets/{name}/objects/tags", putObjectTags)
 	})
 	// Read-only routes available to all authenticated users
 	r.Get("/providers/{id}/buckets/{name}/objects", listObjects)
-	r.Get("/providers/{id}/buckets/{name}/download", downloadObject)
+	r.With(noAPITimeout).Get("/providers/{id}/buckets/{name}/download", downloadObject)
+	r.Get("/providers/{id}/buckets/{name}/presign", presignDownload)
+	r.Get("/providers/{id}/buckets/{name}/objects/encryption", objectEncryption)
+	r.Get("/providers/{id}/buckets/{name}/objects/tags", getObjectTags)
+	r.Get("/providers/{id}/buckets/{name}/objects/meta", getObjectMeta)
+}
+
+// clientFactory builds the S3 client for a provider; tests swap it for an s3.MockClient.
+var clientFactory = s3.NewFromProvider
+
+// multipartOpts decides when uploadObject switches to a parallel multipart upload (set in Router)
+var multipartOpts = s3.DefaultMultipartOptions
+
+// multi-file upload limits (set in Router); maxBatchSizeBytes 0 = unlimited
+var (
+	maxBatchFiles     = 50
+	maxBatchSizeBytes int64
+)
+
+// maxBulkDelete caps the keys of one bulk delete request (set in Router)
+var maxBulkDelete = 1000
+
+// upload concurrency defaults (UPLOAD_CONCURRENCY_INIT, UPLOAD_CONCURRENCY_MAX, UPLOAD_LATENCY_TARGET_MS)
+const (
+	defaultUploadConcurrency    = 4
+	defaultUploadConcurrencyMax = 16
+	defaultUploadLatencyTarget  = 500 * time.Millisecond
+)
+
+// uploadLimiter gates the files stored by uploadObject and uploadFromURL; its limit follows
+// the observed upload latency (replaced in Router).
+var uploadLimiter = newUploadLimiter(0, 0, 0)
+
+// uploadQueueTimeout is how long an upload waits for the limiter before it is refused with 503.
+var uploadQueueTimeout = 30 * time.Second
+
+// uploadLatencyUnit is the size uploads are normalised to before the limiter observes them.
+const uploadLatencyUnit = 1 << 20
+
+// acquireUploadSlot waits up to uploadQueueTimeout for room in uploadLimiter; each
+// success must be paired with uploadLimiter.Release.
+func acquireUploadSlot(r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), uploadQueueTimeout)
+	defer cancel()
+	return uploadLimiter.Acquire(ctx)
+}
+
+// uploadLatency scales the duration of an n-byte upload to one of uploadLatencyUnit, so
+// that large files, which take long however idle the storage is, do not read as
+// congestion. Smaller uploads are observed as they are.
+func uploadLatency(d time.Duration, n int64) time.Duration {
+	if n <= uploadLatencyUnit {
+		return d
+	}
+	return time.Duration(float64(d) * uploadLatencyUnit / float64(n))
 }
 
-func getClient(id int) (*s3.Client, *models.Provider, error) {
+// newUploadLimiter builds the upload limiter; zero settings take their default.
+func newUploadLimiter(initial, maxLimit, targetMs int64) *concurrency.AdaptiveLimiter {
+	if initial <= 0 {
+		initial = defaultUploadConcurrency
+	}
+	if maxLimit <= 0 {
+		maxLimit = defaultUploadConcurrencyMax
+	}
+	target := time.Duration(targetMs) * time.Millisecond
+	if target <= 0 {
+		target = defaultUploadLatencyTarget
+	}
+	return concurrency.NewAdaptiveLimiter(int(initial), int(maxLimit), target)
+}
+
+func getClient(id int) (s3.ClientInterface, *models.Provider, error) {
 	if id <= 0 {
 		return nil, nil, http.ErrNoLocation
 	}
@@ -54,7 +128,7 @@ func getClient(id int) (*s3.Client, *models.Provider, error) {
 	if err := db.DB.First(&p, id).Error; err != nil {
 		return nil, nil, err
 	}
-	c, err := s3.NewFromProvider(p)
+	c, err := s3Clients.get(p)
 	return c, &p, err
 }
 
@@ -64,17 +138,18 @@ func listBuckets(w http.ResponseWriter, r *http.Request) {
 	addEvent(r, "buckets.list", nil)
 	pid, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil || pid <= 0 {
-		respondError(w, r, 400, "invalid provider id")
+		respondError(w, r, 400, "invalid provider id", ErrCodeInvalidID)
 		return
 	}
 	c, _, err := getClient(pid)
 	if err != nil {
-		respondError(w, r, 404, "provider not found")
+		respondError(w, r, 404, "provider not found", ErrCodeProviderNotFound)
 		return
 	}
 	items, err := c.ListBuckets(r.Context())
 	if err != nil {
-		respondError(w, r, 500, err.Error())
+		code, msg := s3Failure(r, err)
+		respondError(w, r, code, msg, storageErrCode(code, msg))
 		return
 	}
 	// Sync into DB (upsert live items, prune stale)
@@ -89,7 +164,7 @@ func listBuckets(w http.ResponseWriter, r *http.Request) {
 			}
 			db.DB.Save(&rec)
 		} else {
-			_ = db.DB.Create(&models.Bucket{ProviderID: uint(pid), Name: b.Name}).Error
+			_ = db.DB.Create(&models.Bucket{ProviderID: uint(pid), Name: b.Name, TenantID: tenantFromCtx(r)}).Error
 		}
 	}
 	// prune buckets in DB that are not present live
@@ -101,7 +176,7 @@ func listBuckets(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	}
-	json.NewEncoder(w).Encode(items)
+	RespondList(w, r, 200, items, len(items), len(items), 0)
 }
 
 // listBucketsFromDB returns persisted buckets for provider id. It adapts fields for UI compatibility.
@@ -110,12 +185,12 @@ func listBucketsFromDB(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Cache-Control", "no-store")
 	pid, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil || pid <= 0 {
-		http.Error(w, "invalid provider id", 400)
+		respondError(w, r, 400, "invalid provider id", ErrCodeInvalidID)
 		return
 	}
 	var rows []models.Bucket
-	if err := db.DB.Where("provider_id = ?", pid).Order("name asc").Find(&rows).Error; err != nil {
-		http.Error(w, err.Error(), 500)
+	if err := readDB(r).Scopes(tenantScope(r)).Where("provider_id = ?", pid).Order("name asc").Find(&rows).Error; err != nil {
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
 		return
 	}
 	// Create a light DTO compatible with UI buckets rendering: Name and CreationDate
@@ -136,27 +211,38 @@ func createBucket(w http.ResponseWriter, r *http.Request) {
 	addEvent(r, "bucket.create", nil)
 	pid, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil || pid <= 0 {
-		respondError(w, r, 400, "invalid provider id")
+		respondError(w, r, 400, "invalid provider id", ErrCodeInvalidID)
 		return
 	}
 	c, p, err := getClient(pid)
 	if err != nil {
-		respondError(w, r, 404, "provider not found")
+		respondError(w, r, 404, "provider not found", ErrCodeProviderNotFound)
 		return
 	}
 	var in struct {
-		Name   string `json:"name"`
-		Region string `json:"region"`
+		Name       string `json:"name"`
+		Region     string `json:"region"`
+		TemplateID int    `json:"templateId"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-		respondError(w, r, 400, err.Error())
+		respondError(w, r, 400, err.Error(), ErrCodeInvalidRequest)
 		return
 	}
 	if in.Region == "" {
 		in.Region = p.Region
 	}
+	// resolve the template before creating anything so a bad id leaves no bucket behind
+	var tmpl *models.BucketTemplate
+	if in.TemplateID > 0 {
+		tmpl = &models.BucketTemplate{}
+		if err := db.DB.First(tmpl, in.TemplateID).Error; err != nil {
+			respondError(w, r, 400, "unknown templateId", ErrCodeValidation)
+			return
+		}
+	}
 	if err := c.CreateBucket(r.Context(), in.Name, in.Region); err != nil {
-		respondError(w, r, 500, err.Error())
+		code, msg := s3Failure(r, err)
+		respondError(w, r, code, msg, storageErrCode(code, msg))
 		return
 	}
 	// upsert into DB immediately
@@ -167,186 +253,743 @@ func createBucket(w http.ResponseWriter, r *http.Request) {
 			db.DB.Save(&rec)
 		}
 	} else {
-		_ = db.DB.Create(&models.Bucket{ProviderID: uint(pid), Name: in.Name, Region: in.Region}).Error
+		_ = db.DB.Create(&models.Bucket{ProviderID: uint(pid), Name: in.Name, Region: in.Region, TenantID: tenantFromCtx(r)}).Error
+	}
+	if tmpl == nil {
+		w.WriteHeader(201)
+		return
 	}
+	warnings := applyBucketTemplate(r, c, pid, in.Name, tmpl)
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(map[string]any{"bucket": in.Name, "templateApplied": tmpl.Name, "warnings": warnings})
 }
 
 func deleteBucket(w http.ResponseWriter, r *http.Request) {
 	pid, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil || pid <= 0 {
-		respondError(w, r, 400, "invalid provider id")
+		respondError(w, r, 400, "invalid provider id", ErrCodeInvalidID)
 		return
 	}
 	name := chi.URLParam(r, "name")
+	if !enforceACL(w, r, pid, name, aclWrite) {
+		return
+	}
 	c, _, err := getClient(pid)
 	if err != nil {
-		respondError(w, r, 404, "provider not found")
+		respondError(w, r, 404, "provider not found", ErrCodeProviderNotFound)
+		return
+	}
+	if r.URL.Query().Get("force") == "true" {
+		// streams progress for as long as emptying the bucket takes
+		middleware.LiftTimeout(r)
+		forceDeleteBucket(w, r, c, pid, name)
 		return
 	}
 	if err := c.DeleteBucket(r.Context(), name); err != nil {
-		respondError(w, r, 500, err.Error())
+		code, msg := s3Failure(r, err)
+		respondError(w, r, code, msg, storageErrCode(code, msg))
 		return
 	}
+	pruneBucketRecords(uint(pid), name)
 	w.WriteHeader(204)
 }
 
+// bucketForceDeleteMax caps the objects a forced bucket delete removes (set in Router)
+var bucketForceDeleteMax = 100000
+
+// forceDeleteBatch is the most keys one S3 DeleteObjects request accepts.
+const forceDeleteBatch = 1000
+
+// forceDeleteBucket empties the bucket and removes it, streaming NDJSON progress like
+// copy and move. Objects are removed permanently, bypassing the trash bucket; on a
+// versioned bucket every version and delete marker goes too, as the provider refuses to
+// delete a bucket that still holds any. A bucket holding more than bucketForceDeleteMax
+// objects (versions, when versioned) is refused before anything is deleted.
+func forceDeleteBucket(w http.ResponseWriter, r *http.Request, c s3.ClientInterface, pid int, name string) {
+	versioned, err := c.BucketVersioningEnabled(r.Context(), name)
+	if err != nil {
+		code, msg := s3Failure(r, err)
+		respondError(w, r, code, msg, storageErrCode(code, msg))
+		return
+	}
+	// the listing stops one past the limit, so an oversized bucket is never listed in full
+	var items []minio.ObjectInfo
+	var truncated bool
+	if versioned {
+		items, truncated, err = c.ListBucketVersions(r.Context(), name, bucketForceDeleteMax)
+	} else {
+		items, truncated, err = c.ListObjectsPage(r.Context(), name, "", true, "", bucketForceDeleteMax)
+	}
+	if err != nil {
+		code, msg := s3Failure(r, err)
+		respondError(w, r, code, msg, storageErrCode(code, msg))
+		return
+	}
+	if truncated {
+		respondError(w, r, 409, fmt.Sprintf("bucket holds more than the %d objects a forced delete removes", bucketForceDeleteMax), ErrCodeConflict)
+		return
+	}
+	addEvent(r, "bucket.force_delete", map[string]any{"bucket": name, "objects": len(items), "versioned": versioned})
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no") // disable nginx proxy buffering
+	fl, _ := w.(http.Flusher)
+	write := func(obj map[string]any) {
+		b, _ := json.Marshal(obj)
+		w.Write(b)
+		w.Write([]byte("\n"))
+		if fl != nil {
+			fl.Flush()
+		}
+	}
+	total := len(items)
+	write(map[string]any{"status": "starting", "total": total})
+	deleted := 0
+	for start := 0; start < total; start += forceDeleteBatch {
+		batch := items[start:min(start+forceDeleteBatch, total)]
+		var failed []s3.ObjectError
+		var err error
+		if versioned {
+			failed, err = c.DeleteObjectVersions(r.Context(), name, batch)
+		} else {
+			keys := make([]string, 0, len(batch))
+			for _, it := range batch {
+				keys = append(keys, it.Key)
+			}
+			failed, err = c.DeleteObjects(r.Context(), name, keys)
+		}
+		if err == nil && len(failed) > 0 {
+			err = fmt.Errorf("%s: %w", failed[0].Key, failed[0].Err)
+		}
+		if err != nil {
+			_, msg := s3Failure(r, err)
+			write(map[string]any{"error": msg, "deleted": deleted + len(batch) - len(failed), "total": total})
+			return
+		}
+		deleted += len(batch)
+		write(map[string]any{"deleted": deleted, "total": total})
+	}
+	if err := c.DeleteBucket(r.Context(), name); err != nil {
+		_, msg := s3Failure(r, err)
+		write(map[string]any{"error": msg, "deleted": deleted, "total": total})
+		return
+	}
+	pruneBucketRecords(uint(pid), name)
+	write(map[string]any{"deleted": deleted, "total": total, "done": true})
+}
+
+// pruneBucketRecords drops what the database keeps about a deleted bucket, so a bucket
+// later created under the same name starts without the old ACL, size limit or statistics.
+func pruneBucketRecords(pid uint, name string) {
+	if err := db.DB.Where("provider_id = ? AND name = ?", pid, name).Delete(&models.Bucket{}).Error; err != nil {
+		apiLogger.Error("bucket record delete failed", "component", "bucket.delete", "provider", pid, "bucket", name, "error", err)
+	}
+	for _, m := range []any{&models.BucketSummary{}, &models.BucketACL{}, &models.ObjectStat{}, &models.ObjectIndex{}} {
+		if err := db.DB.Where("provider_id = ? AND bucket = ?", pid, name).Delete(m).Error; err != nil {
+			apiLogger.Error("bucket record delete failed", "component", "bucket.delete", "provider", pid, "bucket", name, "error", err)
+		}
+	}
+}
+
 func listObjects(w http.ResponseWriter, r *http.Request) {
 	addEvent(r, "objects.list", map[string]any{"bucket": chi.URLParam(r, "name")})
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Cache-Control", "no-store")
 	pid, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil || pid <= 0 {
-		respondError(w, r, 400, "invalid provider id")
+		respondError(w, r, 400, "invalid provider id", ErrCodeInvalidID)
 		return
 	}
 	bucket := chi.URLParam(r, "name")
 	if bucket == "" {
-		respondError(w, r, 400, "bucket is required")
+		respondError(w, r, 400, "bucket is required", ErrCodeMissingField)
+		return
+	}
+	if !enforceACL(w, r, pid, bucket, aclRead) {
 		return
 	}
-	prefix := r.URL.Query().Get("prefix")
-	recursive := r.URL.Query().Get("recursive") == "true"
+	q := r.URL.Query()
+	prefix := q.Get("prefix")
+	recursive := q.Get("recursive") == "true"
+	// envelope=false keeps the original response: every object in one array
+	paged := q.Get("envelope") != "false"
+	maxKeys := defaultListMaxKeys
+	var startAfter string
+	if paged {
+		if v := q.Get("maxKeys"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				respondError(w, r, 400, "maxKeys must be a positive number", ErrCodeInvalidParameter)
+				return
+			}
+			maxKeys = min(n, maxListMaxKeys)
+		}
+		if v := q.Get("continuationToken"); v != "" {
+			k, err := base64.RawURLEncoding.DecodeString(v)
+			if err != nil || len(k) == 0 {
+				respondError(w, r, 400, "invalid continuationToken", ErrCodeInvalidParameter)
+				return
+			}
+			startAfter = string(k)
+		}
+	}
 	c, _, err := getClient(pid)
 	if err != nil {
-		respondError(w, r, 404, "provider not found")
+		respondError(w, r, 404, "provider not found", ErrCodeProviderNotFound)
 		return
 	}
-	items, err := c.ListObjects(r.Context(), bucket, prefix, recursive)
+	var items []minio.ObjectInfo
+	var truncated bool
+	if paged {
+		items, truncated, err = c.ListObjectsPage(r.Context(), bucket, prefix, recursive, startAfter, maxKeys)
+	} else {
+		items, err = c.ListObjects(r.Context(), bucket, prefix, recursive)
+	}
 	if err != nil {
 		// Map common not-found errors to 404 for better UX
-		msg := err.Error()
-		if msg != "" {
-			if containsNoSuchBucket(msg) {
-				respondError(w, r, 404, "bucket not found")
-				return
-			}
+		if containsNoSuchBucket(err.Error()) {
+			respondError(w, r, 404, "bucket not found", ErrCodeBucketNotFound)
+			return
 		}
-		respondError(w, r, 500, msg)
+		code, msg := s3Failure(r, err)
+		respondError(w, r, code, msg, storageErrCode(code, msg))
 		return
 	}
-	json.NewEncoder(w).Encode(items)
+	if !paged {
+		RespondList(w, r, 200, items, len(items), len(items), 0)
+		return
+	}
+	out := objectPage{Objects: items, IsTruncated: truncated}
+	if out.Objects == nil {
+		out.Objects = []minio.ObjectInfo{}
+	}
+	if truncated {
+		out.NextToken = base64.RawURLEncoding.EncodeToString([]byte(items[len(items)-1].Key))
+	}
+	Respond(w, r, 200, out)
+}
+
+// object listing page sizes (maxKeys)
+const (
+	defaultListMaxKeys = 500
+	maxListMaxKeys     = 10000
+)
+
+// objectPage is one page of listObjects. nextToken, the last key encoded, is passed back
+// as continuationToken for the following page.
+type objectPage struct {
+	Objects     []minio.ObjectInfo `json:"objects"`
+	NextToken   string             `json:"nextToken,omitempty"`
+	IsTruncated bool               `json:"isTruncated"`
 }
 
 func deleteObject(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Cache-Control", "no-store")
 	pid, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil || pid <= 0 {
-		respondError(w, r, 400, "invalid provider id")
+		respondError(w, r, 400, "invalid provider id", ErrCodeInvalidID)
 		return
 	}
 	bucket := chi.URLParam(r, "name")
 	if bucket == "" {
-		respondError(w, r, 400, "bucket is required")
+		respondError(w, r, 400, "bucket is required", ErrCodeMissingField)
+		return
+	}
+	if !enforceACL(w, r, pid, bucket, aclWrite) {
 		return
 	}
 	key := r.URL.Query().Get("key")
 	c, _, err := getClient(pid)
 	if err != nil {
-		respondError(w, r, 404, "provider not found")
+		respondError(w, r, 404, "provider not found", ErrCodeProviderNotFound)
 		return
 	}
-	if err := c.DeleteObject(r.Context(), bucket, key); err != nil {
-		respondError(w, r, 500, err.Error())
+	// the size is only needed to keep the bucket summary current
+	size := int64(-1)
+	if incrementalStats {
+		if info, err := c.Stat(r.Context(), bucket, key); err == nil {
+			size = info.Size
+		}
+	}
+	// Soft-delete into the trash bucket when configured, unless the caller opts out
+	if trashBucket != "" && bucket != trashBucket && r.URL.Query().Get("permanent") != "true" {
+		if err := moveToTrash(r, c, pid, bucket, key); err != nil {
+			code, msg := s3Failure(r, err)
+			respondError(w, r, code, msg, storageErrCode(code, msg))
+			return
+		}
+	} else if err := c.DeleteObject(r.Context(), bucket, key); err != nil {
+		code, msg := s3Failure(r, err)
+		respondError(w, r, code, msg, storageErrCode(code, msg))
 		return
 	}
+	if size >= 0 {
+		adjustBucketSummary(uint(pid), bucket, -1, -size)
+	}
 	w.WriteHeader(204)
 }
 
+// bulkDeleteFailure is a key deleteObjects could not remove.
+type bulkDeleteFailure struct {
+	Key   string `json:"key"`
+	Error string `json:"error"`
+}
+
+// deleteObjects removes up to maxBulkDelete keys in one request. Keys that fail are
+// reported alongside the count deleted; an empty key list answers 204.
+func deleteObjects(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	pid, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || pid <= 0 {
+		respondError(w, r, 400, "invalid provider id", ErrCodeInvalidID)
+		return
+	}
+	bucket := chi.URLParam(r, "name")
+	if !enforceACL(w, r, pid, bucket, aclWrite) {
+		return
+	}
+	var in struct {
+		Keys []string `json:"keys"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondError(w, r, 400, err.Error(), ErrCodeInvalidRequest)
+		return
+	}
+	if len(in.Keys) > maxBulkDelete {
+		respondError(w, r, 413, fmt.Sprintf("at most %d keys per request", maxBulkDelete), ErrCodePayloadTooLarge)
+		return
+	}
+	if len(in.Keys) == 0 {
+		w.WriteHeader(204)
+		return
+	}
+	for _, k := range in.Keys {
+		if k == "" {
+			respondError(w, r, 400, "keys must not be empty", ErrCodeValidation)
+			return
+		}
+	}
+	c, _, err := getClient(pid)
+	if err != nil {
+		respondError(w, r, 404, "provider not found", ErrCodeProviderNotFound)
+		return
+	}
+	// sizes are only needed to keep the bucket summary current
+	sizes := map[string]int64{}
+	if incrementalStats {
+		for _, k := range in.Keys {
+			if info, err := c.Stat(r.Context(), bucket, k); err == nil {
+				sizes[k] = info.Size
+			}
+		}
+	}
+	var failed []s3.ObjectError
+	if trashBucket != "" && bucket != trashBucket && r.URL.Query().Get("permanent") != "true" {
+		for _, k := range in.Keys {
+			if err := moveToTrash(r, c, pid, bucket, k); err != nil {
+				failed = append(failed, s3.ObjectError{Key: k, Err: err})
+			}
+		}
+	} else if failed, err = c.DeleteObjects(r.Context(), bucket, in.Keys); err != nil {
+		code, msg := s3Failure(r, err)
+		respondError(w, r, code, msg, storageErrCode(code, msg))
+		return
+	}
+	out := struct {
+		Deleted int                 `json:"deleted"`
+		Failed  []bulkDeleteFailure `json:"failed"`
+	}{Failed: []bulkDeleteFailure{}}
+	for _, f := range failed {
+		msg := "delete failed"
+		if f.Err != nil {
+			msg = f.Err.Error()
+		}
+		out.Failed = append(out.Failed, bulkDeleteFailure{Key: f.Key, Error: msg})
+		delete(sizes, f.Key)
+	}
+	out.Deleted = len(in.Keys) - len(failed)
+	if len(sizes) > 0 {
+		var total int64
+		for _, s := range sizes {
+			total += s
+		}
+		adjustBucketSummary(uint(pid), bucket, -int64(len(sizes)), -total)
+	}
+	addEvent(r, "objects.bulk_delete", map[string]any{"bucket": bucket, "requested": len(in.Keys), "deleted": out.Deleted, "failed": len(failed)})
+	Respond(w, r, 200, out)
+}
+
 func uploadObject(w http.ResponseWriter, r *http.Request) {
 	addEvent(r, "object.upload", map[string]any{"bucket": chi.URLParam(r, "name")})
 	pid, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil || pid <= 0 {
-		respondError(w, r, 400, "invalid provider id")
+		respondError(w, r, 400, "invalid provider id", ErrCodeInvalidID)
 		return
 	}
 	bucket := chi.URLParam(r, "name")
 	if bucket == "" {
-		respondError(w, r, 400, "bucket is required")
+		respondError(w, r, 400, "bucket is required", ErrCodeMissingField)
 		return
 	}
-	c, _, err := getClient(pid)
+	if !enforceACL(w, r, pid, bucket, aclWrite) {
+		return
+	}
+	c, prov, err := getClient(pid)
 	if err != nil {
-		respondError(w, r, 404, "provider not found")
+		respondError(w, r, 404, "provider not found", ErrCodeProviderNotFound)
+		return
+	}
+	if !checkQuota(w, r, prov, true, r.ContentLength) {
 		return
 	}
-	// Enforce configurable maximum upload size to avoid memory pressure/DoS
-	if maxUploadSizeBytes > 0 {
-		r.Body = http.MaxBytesReader(w, r.Body, maxUploadSizeBytes)
+	// Each file is held to the bucket's (or the global) object limit and the whole request
+	// to MAX_BATCH_SIZE_BYTES, to avoid memory pressure/DoS
+	limit := objectSizeLimit(uint(pid), bucket)
+	if maxBatchSizeBytes > 0 {
+		if r.ContentLength > maxBatchSizeBytes {
+			respondError(w, r, 413, "payload too large", ErrCodePayloadTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBatchSizeBytes)
 	}
 	mr, err := r.MultipartReader()
 	if err != nil {
 		// Handle too large error specifically
-		msg := err.Error()
-		if strings.Contains(strings.ToLower(msg), "request body too large") || strings.Contains(strings.ToLower(msg), "http: request body too large") {
-			respondError(w, r, 413, "payload too large")
+		if tooLarge(err) {
+			respondError(w, r, 413, "payload too large", ErrCodePayloadTooLarge)
 			return
 		}
-		respondError(w, r, 400, "expecting multipart form-data")
+		respondError(w, r, 400, "expecting multipart form-data", ErrCodeInvalidRequest)
 		return
 	}
-	var key string
-	var info any
+	// key/keyN name the object of the following file/fileN part; prefix applies to files
+	// without one. Optional SSE fields must precede the file parts they apply to.
+	keys := map[string]string{}
+	// ?ifNoneMatch=* (or the If-None-Match: * header) only writes keys that do not exist yet
+	ifNoneMatch := r.URL.Query().Get("ifNoneMatch") == "*" || r.Header.Get("If-None-Match") == "*"
+	var prefix string
+	var sseType, sseKMSKeyID, sseCKey string
+	var results []batchUploadResult
+	var single *minio.UploadInfo // result of a request with just one "file" part
+	legacy := true
 	for {
 		part, err := mr.NextPart()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			respondError(w, r, 400, err.Error())
+			if len(results) > 0 && tooLarge(err) {
+				// the body hit MAX_BATCH_SIZE_BYTES; report it unless that file already failed on it
+				if last := results[len(results)-1]; last.status != 413 {
+					results = append(results, batchUploadResult{Error: "batch too large", status: 413, code: ErrCodePayloadTooLarge})
+				}
+				break
+			}
+			respondError(w, r, 400, err.Error(), ErrCodeInvalidRequest)
 			return
 		}
 		name := part.FormName()
-		if name == "key" {
+		switch {
+		case name == "prefix":
 			b, _ := io.ReadAll(part)
-			key = string(b)
+			prefix = strings.Trim(string(b), "/")
+			continue
+		case name == "sseType" || name == "sseKmsKeyId" || name == "sseCKey":
+			b, _ := io.ReadAll(part)
+			switch name {
+			case "sseType":
+				sseType = string(b)
+			case "sseKmsKeyId":
+				sseKMSKeyID = string(b)
+			default:
+				sseCKey = string(b)
+			}
+			continue
+		case strings.HasPrefix(name, "key"):
+			b, _ := io.ReadAll(part)
+			keys[strings.TrimPrefix(name, "key")] = string(b)
+			continue
+		case !strings.HasPrefix(name, "file"):
 			continue
 		}
-		if name == "file" {
-			if key == "" {
-				key = part.FileName()
+		suffix := strings.TrimPrefix(name, "file")
+		if suffix != "" || len(results) > 0 {
+			legacy = false
+		}
+		if len(results) >= maxBatchFiles {
+			results = append(results, batchUploadResult{Key: part.FileName(), Error: fmt.Sprintf("more than %d files in one request", maxBatchFiles), status: 413, code: ErrCodePayloadTooLarge})
+			break
+		}
+		key := keys[suffix]
+		delete(keys, suffix) // a repeated "file" part falls back to its file name
+		if key == "" {
+			key = part.FileName()
+			if prefix != "" {
+				key = prefix + "/" + key
 			}
-			ct := part.Header.Get("Content-Type")
-			// Size may be unknown in streaming; minio supports -1 for unknown length
-			uploadInfo, err := c.Upload(r.Context(), bucket, key, part, -1, ct)
-			if err != nil {
-				respondError(w, r, 500, err.Error())
-				return
+		}
+		res := batchUploadResult{Key: key}
+		sse, err := s3.ParseSSE(sseType, sseKMSKeyID, sseCKey)
+		if err != nil {
+			res.Error, res.status, res.code = err.Error(), 400, ErrCodeInvalidRequest
+			results = append(results, res)
+			continue
+		}
+		if ifNoneMatch {
+			if exists, err := objectExists(r, c, bucket, key); err != nil {
+				code, msg := s3Failure(r, err)
+				res.Error, res.status, res.code = msg, code, storageErrCode(code, msg)
+				results = append(results, res)
+				continue
+			} else if exists {
+				res.Error, res.status, res.code = "object already exists", 412, ErrCodePreconditionFailed
+				results = append(results, res)
+				continue
 			}
-			info = uploadInfo
-			addEvent(r, "object.upload.done", map[string]any{"bucket": bucket, "key": key})
-			// drain remaining parts but ignore
 		}
+		// the limiter admits files, not requests, so a batch does not hold a slot between them
+		if err := acquireUploadSlot(r); err != nil {
+			res.Error, res.status, res.code = "too many concurrent uploads", 503, ErrCodeStorageBusy
+			results = append(results, res)
+			continue
+		}
+		start := time.Now()
+		info, status, msg, code := uploadFormFile(r, c, uint(pid), prov, bucket, key, part, limit, sse)
+		if status == 0 || status >= 500 {
+			// rejected files say nothing about how loaded the storage path is
+			uploadLimiter.Observe(uploadLatency(time.Since(start), info.Size))
+		}
+		uploadLimiter.Release()
+		if status != 0 {
+			res.Error, res.status, res.code = msg, status, code
+			results = append(results, res)
+			continue
+		}
+		res.Size, res.ETag = info.Size, info.ETag
+		results = append(results, res)
+		single = &info
+	}
+	if len(results) == 0 {
+		respondError(w, r, 400, "no file provided", ErrCodeMissingField)
+		return
 	}
-	if info == nil {
-		respondError(w, r, 400, "no file provided")
+	if legacy && len(results) == 1 {
+		// a single "file" part keeps the original response: the upload info or an error
+		if res := results[0]; res.status == 412 {
+			respondPreconditionFailed(w)
+			return
+		} else if res.Error != "" {
+			respondError(w, r, res.status, res.Error, res.code)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(single)
 		return
 	}
+	failed := 0
+	var total int64
+	for _, res := range results {
+		if res.Error != "" {
+			failed++
+		}
+		total += res.Size
+	}
+	addEvent(r, "objects.batch_upload", map[string]any{"bucket": bucket, "files": len(results), "failed": failed, "bytes": total})
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(info)
+	// 207 tells clients to look at each file's result
+	if failed > 0 {
+		w.WriteHeader(http.StatusMultiStatus)
+	}
+	json.NewEncoder(w).Encode(results)
 }
 
-func downloadObject(w http.ResponseWriter, r *http.Request) {
+// objectExists stats key for a conditional write. A not-found answer means the key is
+// free; any other Stat failure is returned, as the key's state is unknown. A rejected
+// write is recorded as a precondition.failed trace event.
+func objectExists(r *http.Request, c s3.ClientInterface, bucket, key string) (bool, error) {
+	_, err := c.Stat(r.Context(), bucket, key)
+	if s3.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	addEvent(r, "precondition.failed", map[string]any{"bucket": bucket, "key": key, "ifNoneMatch": "*"})
+	return true, nil
+}
+
+// respondPreconditionFailed answers a conditional write whose key already exists.
+func respondPreconditionFailed(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(412)
+	json.NewEncoder(w).Encode(map[string]any{"error": "precondition_failed", "code": ErrCodePreconditionFailed, "message": "object already exists"})
+}
+
+// batchUploadResult is one file of a multi-file upload; Error is empty on success.
+type batchUploadResult struct {
+	Key    string `json:"key"`
+	Size   int64  `json:"size"`
+	ETag   string `json:"etag"`
+	Error  string `json:"error"`
+	status int
+	code   string
+}
+
+// uploadFormFile streams one file part to the bucket, holding it to limit bytes (0 = no
+// per-file limit). On failure it returns the HTTP status, message and error code for the client.
+func uploadFormFile(r *http.Request, c s3.ClientInterface, pid uint, prov *models.Provider, bucket, key string, part *multipart.Part, limit int64, sse encrypt.ServerSide) (minio.UploadInfo, int, string, string) {
+	ct := part.Header.Get("Content-Type")
+	// The part's own length when sent, else the request length as an upper bound;
+	// -1 lets UploadAuto spool up to the threshold to decide
+	size := r.ContentLength
+	if n, err := strconv.ParseInt(part.Header.Get("Content-Length"), 10, 64); err == nil {
+		if limit > 0 && n > limit {
+			return minio.UploadInfo{}, 413, "payload too large", ErrCodePayloadTooLarge
+		}
+		size = n
+	}
+	return storeUpload(r, c, pid, prov, bucket, key, part, size, ct, limit, sse)
+}
+
+// storeUpload streams body to the bucket and books the new object against the bucket
+// summary and the provider's upload quota. Its results are those of uploadFormFile.
+func storeUpload(r *http.Request, c s3.ClientInterface, pid uint, prov *models.Provider, bucket, key string, body io.Reader, size int64, ct string, limit int64, sse encrypt.ServerSide) (minio.UploadInfo, int, string, string) {
+	if limit > 0 {
+		body = &limitedPart{r: body, left: limit, limit: limit}
+	}
+	ctx, done, ok := trackUpload(r.Context(), c, bucket, key)
+	if !ok {
+		return minio.UploadInfo{}, 503, "server is shutting down", ErrCodeShuttingDown
+	}
+	info, err := s3.UploadAuto(ctx, c, bucket, key, body, size, ct, sse, multipartOpts)
+	done()
+	if err != nil {
+		if tooLarge(err) {
+			return minio.UploadInfo{}, 413, "payload too large", ErrCodePayloadTooLarge
+		}
+		code, msg := s3Failure(r, err)
+		return minio.UploadInfo{}, code, msg, storageErrCode(code, msg)
+	}
+	adjustBucketSummary(pid, bucket, 1, info.Size)
+	if err := addQuotaUsage(prov, info.Size, 0); err != nil {
+		apiLogger.Error("quota update failed", "component", "object.upload", "provider", pid, "error", err)
+	}
+	addEvent(r, "object.upload.done", map[string]any{"bucket": bucket, "key": key})
+	apiLogger.Debug("object uploaded", "component", "object.upload", "bucket", bucket, "key", key, "size", info.Size)
+	return info, 0, "", ""
+}
+
+// limitedPart fails with *http.MaxBytesError once a file part exceeds limit bytes, so
+// each file of a batch is held to the object size limit on its own.
+type limitedPart struct {
+	r     io.Reader
+	left  int64
+	limit int64
+}
+
+func (l *limitedPart) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.left+1 {
+		p = p[:l.left+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.left {
+		n, l.left = int(l.left), 0
+		return n, &http.MaxBytesError{Limit: l.limit}
+	}
+	l.left -= int64(n)
+	return n, err
+}
+
+// objectEncryption reports the server-side encryption applied to an object, as returned in Stat headers.
+func objectEncryption(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 	pid, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil || pid <= 0 {
-		respondError(w, r, 400, "invalid provider id")
+		respondError(w, r, 400, "invalid provider id", ErrCodeInvalidID)
 		return
 	}
 	bucket := chi.URLParam(r, "name")
+	if !enforceACL(w, r, pid, bucket, aclRead) {
+		return
+	}
 	key := r.URL.Query().Get("key")
+	if key == "" {
+		respondError(w, r, 400, "key is required", ErrCodeMissingField)
+		return
+	}
 	c, _, err := getClient(pid)
 	if err != nil {
-		respondError(w, r, 404, "provider not found")
+		respondError(w, r, 404, "provider not found", ErrCodeProviderNotFound)
+		return
+	}
+	info, err := c.Stat(r.Context(), bucket, key)
+	if err != nil {
+		code, msg := s3Failure(r, err)
+		respondError(w, r, code, msg, storageErrCode(code, msg))
+		return
+	}
+	h := info.Metadata
+	json.NewEncoder(w).Encode(map[string]any{
+		"bucket":               bucket,
+		"key":                  key,
+		"serverSideEncryption": h.Get("X-Amz-Server-Side-Encryption"),
+		"kmsKeyId":             h.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"),
+		"customerAlgorithm":    h.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm"),
+	})
+}
+
+func downloadObject(w http.ResponseWriter, r *http.Request) {
+	pid, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || pid <= 0 {
+		respondError(w, r, 400, "invalid provider id", ErrCodeInvalidID)
+		return
+	}
+	bucket := chi.URLParam(r, "name")
+	if !enforceACL(w, r, pid, bucket, aclRead) {
+		return
+	}
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		respondError(w, r, 400, "key is required", ErrCodeMissingField)
+		return
+	}
+	if strings.HasSuffix(key, "/") {
+		respondError(w, r, 400, "key is a folder", ErrCodeInvalidRequest)
+		return
+	}
+	c, prov, err := getClient(pid)
+	if err != nil {
+		respondError(w, r, 404, "provider not found", ErrCodeProviderNotFound)
+		return
+	}
+	if !checkQuota(w, r, prov, false, 0) {
 		return
 	}
 	rc, err := c.Download(r.Context(), bucket, key)
 	if err != nil {
-		respondError(w, r, 500, err.Error())
+		code, msg := s3Failure(r, err)
+		respondError(w, r, code, msg, storageErrCode(code, msg))
 		return
 	}
 	defer rc.Close()
-	w.Header().Set("Content-Disposition", "attachment; filename=\""+key+"\"")
-	io.Copy(w, rc)
+	// the stored content type, when the provider reports one
+	contentType := "application/octet-stream"
+	if info, err := c.Stat(r.Context(), bucket, key); err == nil && info.ContentType != "" {
+		contentType = info.ContentType
+	}
+	w.Header().Set("Content-Type", contentType)
+	setUntrustedContentHeaders(w.Header())
+	w.Header().Set("Content-Disposition", contentDisposition(downloadFilename(key), contentType, r.URL.Query().Get("inline") == "true"))
+	n, _ := io.Copy(w, rc)
+	apiLogger.Debug("object downloaded", "component", "object.download", "bucket", bucket, "key", key, "bytes", n)
+	if err := recordDownload(pid, bucket, key, n); err != nil {
+		apiLogger.Error("record download failed", "component", "object.download", "bucket", bucket, "key", key, "error", err)
+	}
+	if err := addQuotaUsage(prov, 0, n); err != nil {
+		apiLogger.Error("quota update failed", "component", "object.download", "provider", pid, "error", err)
+	}
 }
 
 // copyObject copies an object from the current bucket (name) to a destination bucket/key.
@@ -354,7 +997,7 @@ func downloadObject(w http.ResponseWriter, r *http.Request) {
 func copyObject(w http.ResponseWriter, r *http.Request) {
 	pid, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil || pid <= 0 {
-		http.Error(w, "invalid provider id", 400)
+		respondError(w, r, 400, "invalid provider id", ErrCodeInvalidID)
 		return
 	}
 	srcBucket := chi.URLParam(r, "name")
@@ -363,18 +1006,30 @@ func copyObject(w http.ResponseWriter, r *http.Request) {
 		DstBucket     string `json:"dstBucket"`
 		DstKey        string `json:"dstKey"`
 		DstProviderID int    `json:"dstProviderId"`
+		SSEType       string `json:"sseType"`
+		SSEKMSKeyID   string `json:"sseKmsKeyId"`
+		SSECKey       string `json:"sseCKey"`
+		IfNoneMatch   string `json:"ifNoneMatch"` // "*": fail with 412 when dstKey exists
 	}
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-		http.Error(w, err.Error(), 400)
+		respondError(w, r, 400, err.Error(), ErrCodeInvalidRequest)
 		return
 	}
 	if in.SrcKey == "" || in.DstBucket == "" {
-		http.Error(w, "srcKey and dstBucket are required", 400)
+		respondError(w, r, 400, "srcKey and dstBucket are required", ErrCodeMissingField)
 		return
 	}
 	if in.DstKey == "" {
 		in.DstKey = in.SrcKey
 	}
+	if !enforceACL(w, r, pid, srcBucket, aclRead) || !enforceACL(w, r, cmp.Or(in.DstProviderID, pid), in.DstBucket, aclWrite) {
+		return
+	}
+	sse, err := s3.ParseSSE(in.SSEType, in.SSEKMSKeyID, in.SSECKey)
+	if err != nil {
+		respondError(w, r, 400, err.Error(), ErrCodeInvalidRequest)
+		return
+	}
 
 	// We will stream progress updates to the client as NDJSON
 	w.Header().Set("Content-Type", "application/x-ndjson")
@@ -393,7 +1048,7 @@ func copyObject(w http.ResponseWriter, r *http.Request) {
 	// Resolve clients (support cross-provider)
 	srcClient, _, err := getClient(pid)
 	if err != nil {
-		http.Error(w, "source provider not found", 404)
+		respondError(w, r, 404, "source provider not found", ErrCodeProviderNotFound)
 		return
 	}
 	dstPid := in.DstProviderID
@@ -402,17 +1057,55 @@ func copyObject(w http.ResponseWriter, r *http.Request) {
 	}
 	dstClient, _, err := getClient(dstPid)
 	if err != nil {
-		http.Error(w, "destination provider not found", 404)
+		respondError(w, r, 404, "destination provider not found", ErrCodeProviderNotFound)
+		return
+	}
+	if in.IfNoneMatch == "*" {
+		exists, err := objectExists(r, dstClient, in.DstBucket, in.DstKey)
+		if err != nil {
+			code, msg := s3Failure(r, err)
+			respondError(w, r, code, msg, storageErrCode(code, msg))
+			return
+		}
+		if exists {
+			respondPreconditionFailed(w)
+			return
+		}
+	}
+
+	// Encrypted copies within one provider are done server-side so the provider applies SSE
+	if sse != nil && dstPid == pid {
+		if err := srcClient.CopyObjectWithSSE(r.Context(), srcBucket, in.SrcKey, in.DstBucket, in.DstKey, sse); err != nil {
+			_, msg := s3Failure(r, err)
+			write(map[string]any{"error": msg})
+			return
+		}
+		write(map[string]any{"progress": 100, "done": true})
+		addEvent(r, "object.copy.end", map[string]any{"ok": true, "sseType": in.SSEType})
 		return
 	}
 
-	// Determine size and get reader (best-effort total via DownloadWithInfo)
-	rc, total, err := srcClient.DownloadWithInfo(r.Context(), srcBucket, in.SrcKey)
+	// Determine size and get reader (best-effort total via DownloadWithInfo). The download
+	// has its own context so a failed upload can abort it.
+	dlCtx, abortDownload := context.WithCancel(r.Context())
+	defer abortDownload()
+	rc, total, err := srcClient.DownloadWithInfo(dlCtx, srcBucket, in.SrcKey)
 	if err != nil {
-		write(map[string]any{"error": err.Error()})
+		_, msg := s3Failure(r, err)
+		write(map[string]any{"error": msg})
 		return
 	}
 	defer rc.Close()
+	if limit := objectSizeLimit(uint(dstPid), in.DstBucket); limit > 0 && total > limit {
+		respondError(w, r, 413, "object exceeds the destination bucket's size limit", ErrCodePayloadTooLarge)
+		return
+	}
+	ctx, done, ok := trackUpload(r.Context(), dstClient, in.DstBucket, in.DstKey)
+	if !ok {
+		respondError(w, r, 503, "server is shutting down", ErrCodeShuttingDown)
+		return
+	}
+	defer done()
 	write(map[string]any{"status": "starting", "total": total})
 
 	// Counter
@@ -428,26 +1121,88 @@ func copyObject(w http.ResponseWriter, r *http.Request) {
 			case <-doneCh:
 				return
 			case <-ticker.C:
+				n := atomic.LoadInt64(&transferred)
 				pct := 0
 				if total > 0 {
-					pct = int((float64(transferred) / float64(total)) * 100)
+					pct = int((float64(n) / float64(total)) * 100)
 				}
-				write(map[string]any{"progress": pct, "bytes": transferred, "total": total})
+				write(map[string]any{"progress": pct, "bytes": n, "total": total})
 			}
 		}
 	}()
 
-	// Wrap reader to count bytes without additional buffering/goroutines
-	tee := io.TeeReader(rc, countingWriter{on: func(n int){ transferred += int64(n) }})
+	// Stream the source into the upload through a pipe, counting bytes as they pass
 	ct := "application/octet-stream"
-	if _, err := dstClient.Upload(r.Context(), in.DstBucket, in.DstKey, tee, -1, ct); err != nil {
-		write(map[string]any{"error": err.Error()})
+	count := func(n int) { atomic.AddInt64(&transferred, int64(n)) }
+	if _, err := pipeCopy(rc, abortDownload, count, func(body io.Reader) error {
+		_, err := dstClient.UploadWithSSE(ctx, in.DstBucket, in.DstKey, body, -1, ct, sse)
+		return err
+	}); err != nil {
+		_, msg := s3Failure(r, err)
+		write(map[string]any{"error": msg})
 		close(doneCh)
 		return
 	}
 	close(doneCh)
+	adjustBucketSummary(uint(dstPid), in.DstBucket, 1, transferred)
 	write(map[string]any{"progress": 100, "bytes": transferred, "total": total, "done": true})
-	addEvent(r, "object.copy.end", map[string]any{"ok": true})
+	fields := map[string]any{"ok": true}
+	if r.URL.Query().Get("verifyMetadata") == "true" {
+		diff, match, err := diffObjectMetadata(r.Context(), srcClient, srcBucket, in.SrcKey, dstClient, in.DstBucket, in.DstKey)
+		if err != nil {
+			_, msg := s3Failure(r, err)
+			write(map[string]any{"error": "metadata verification failed: " + msg})
+			fields["metadataVerifyError"] = msg
+		} else {
+			out := map[string]any{"metadataDiff": diff}
+			if !match {
+				out["warning"] = "metadata mismatch detected"
+			}
+			write(out)
+			fields["metadataDiff"] = diff
+			fields["metadataMatch"] = match
+		}
+	}
+	addEvent(r, "object.copy.end", fields)
+}
+
+// diffObjectMetadata stats the source and destination of a finished copy and compares
+// their ETag, size, content type and user metadata. match is false when any of them
+// differs, except an ETag difference where either side is a multipart ETag ("…-N"),
+// which never equals a single-part one for the same bytes.
+func diffObjectMetadata(ctx context.Context, srcC s3.ClientInterface, srcBucket, srcKey string, dstC s3.ClientInterface, dstBucket, dstKey string) (map[string]any, bool, error) {
+	src, err := srcC.Stat(ctx, srcBucket, srcKey)
+	if err != nil {
+		return nil, false, err
+	}
+	dst, err := dstC.Stat(ctx, dstBucket, dstKey)
+	if err != nil {
+		return nil, false, err
+	}
+	diff := map[string]any{}
+	match := true
+	field := func(name string, a, b any, counts bool) {
+		eq := a == b
+		diff[name] = map[string]any{"src": a, "dst": b, "match": eq}
+		if !eq && counts {
+			match = false
+		}
+	}
+	multipart := strings.Contains(src.ETag, "-") || strings.Contains(dst.ETag, "-")
+	field("etag", src.ETag, dst.ETag, !multipart)
+	field("size", src.Size, dst.Size, true)
+	field("contentType", src.ContentType, dst.ContentType, true)
+	keys := map[string]struct{}{}
+	for k := range src.UserMetadata {
+		keys[k] = struct{}{}
+	}
+	for k := range dst.UserMetadata {
+		keys[k] = struct{}{}
+	}
+	for k := range keys {
+		field("userMetadata."+k, src.UserMetadata[k], dst.UserMetadata[k], true)
+	}
+	return diff, match, nil
 }
 
 // moveObject moves an object from the current bucket (name) to a destination bucket/key.
@@ -455,7 +1210,7 @@ func copyObject(w http.ResponseWriter, r *http.Request) {
 func moveObject(w http.ResponseWriter, r *http.Request) {
 	pid, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil || pid <= 0 {
-		http.Error(w, "invalid provider id", 400)
+		respondError(w, r, 400, "invalid provider id", ErrCodeInvalidID)
 		return
 	}
 	srcBucket := chi.URLParam(r, "name")
@@ -464,18 +1219,30 @@ func moveObject(w http.ResponseWriter, r *http.Request) {
 		DstBucket     string `json:"dstBucket"`
 		DstKey        string `json:"dstKey"`
 		DstProviderID int    `json:"dstProviderId"`
+		SSEType       string `json:"sseType"`
+		SSEKMSKeyID   string `json:"sseKmsKeyId"`
+		SSECKey       string `json:"sseCKey"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-		http.Error(w, err.Error(), 400)
+		respondError(w, r, 400, err.Error(), ErrCodeInvalidRequest)
 		return
 	}
 	if in.SrcKey == "" || in.DstBucket == "" {
-		http.Error(w, "srcKey and dstBucket are required", 400)
+		respondError(w, r, 400, "srcKey and dstBucket are required", ErrCodeMissingField)
 		return
 	}
 	if in.DstKey == "" {
 		in.DstKey = in.SrcKey
 	}
+	// a move deletes the source, so it needs write access on both sides
+	if !enforceACL(w, r, pid, srcBucket, aclWrite) || !enforceACL(w, r, cmp.Or(in.DstProviderID, pid), in.DstBucket, aclWrite) {
+		return
+	}
+	sse, err := s3.ParseSSE(in.SSEType, in.SSEKMSKeyID, in.SSECKey)
+	if err != nil {
+		respondError(w, r, 400, err.Error(), ErrCodeInvalidRequest)
+		return
+	}
 
 	// We will stream progress updates to the client as NDJSON
 	w.Header().Set("Content-Type", "application/x-ndjson")
@@ -494,7 +1261,7 @@ func moveObject(w http.ResponseWriter, r *http.Request) {
 	// Resolve clients (support cross-provider)
 	srcClient, _, err := getClient(pid)
 	if err != nil {
-		http.Error(w, "source provider not found", 404)
+		respondError(w, r, 404, "source provider not found", ErrCodeProviderNotFound)
 		return
 	}
 	dstPid := in.DstProviderID
@@ -503,17 +1270,48 @@ func moveObject(w http.ResponseWriter, r *http.Request) {
 	}
 	dstClient, _, err := getClient(dstPid)
 	if err != nil {
-		http.Error(w, "destination provider not found", 404)
+		respondError(w, r, 404, "destination provider not found", ErrCodeProviderNotFound)
+		return
+	}
+
+	// Journal the move so that a crash between the copy and the source delete is finished
+	// at startup (db.ResumeMoves). Until the copy lands the row has nothing to recover.
+	mv := models.InProgressMove{ID: newTraceID(), ProviderID: uint(pid), SrcBucket: srcBucket, SrcKey: in.SrcKey, DstBucket: in.DstBucket, DstKey: in.DstKey}
+	if u := currentUser(r); u != nil {
+		mv.UserEmail = u.Email
+	}
+	if err := db.DB.Transaction(func(tx *gorm.DB) error { return tx.Create(&mv).Error }); err != nil {
+		respondError(w, r, 500, "could not record move", ErrCodeInternal)
 		return
 	}
+	copied := false
+	defer func() {
+		if !copied {
+			db.DB.Delete(&mv)
+		}
+	}()
 
-	// Determine size and get reader (best-effort total via DownloadWithInfo)
-	rc, total, err := srcClient.DownloadWithInfo(r.Context(), srcBucket, in.SrcKey)
+	// Determine size and get reader (best-effort total via DownloadWithInfo). The download
+	// has its own context so a failed upload can abort it.
+	dlCtx, abortDownload := context.WithCancel(r.Context())
+	defer abortDownload()
+	rc, total, err := srcClient.DownloadWithInfo(dlCtx, srcBucket, in.SrcKey)
 	if err != nil {
-		write(map[string]any{"error": err.Error()})
+		_, msg := s3Failure(r, err)
+		write(map[string]any{"error": msg})
 		return
 	}
 	defer rc.Close()
+	if limit := objectSizeLimit(uint(dstPid), in.DstBucket); limit > 0 && total > limit {
+		respondError(w, r, 413, "object exceeds the destination bucket's size limit", ErrCodePayloadTooLarge)
+		return
+	}
+	ctx, done, ok := trackUpload(r.Context(), dstClient, in.DstBucket, in.DstKey)
+	if !ok {
+		respondError(w, r, 503, "server is shutting down", ErrCodeShuttingDown)
+		return
+	}
+	defer done()
 	write(map[string]any{"status": "starting", "total": total})
 
 	// Counter
@@ -529,34 +1327,54 @@ func moveObject(w http.ResponseWriter, r *http.Request) {
 			case <-doneCh:
 				return
 			case <-ticker.C:
+				n := atomic.LoadInt64(&transferred)
 				pct := 0
 				if total > 0 {
-					pct = int((float64(transferred) / float64(total)) * 100)
+					pct = int((float64(n) / float64(total)) * 100)
 				}
-				write(map[string]any{"progress": pct, "bytes": transferred, "total": total})
+				write(map[string]any{"progress": pct, "bytes": n, "total": total})
 			}
 		}
 	}()
 
-	// Wrap reader to count bytes without additional buffering/goroutines
-	tee := io.TeeReader(rc, countingWriter{on: func(n int){ transferred += int64(n) }})
+	// Stream the source into the upload through a pipe, counting bytes as they pass
 	ct := "application/octet-stream"
-	if _, err := dstClient.Upload(r.Context(), in.DstBucket, in.DstKey, tee, -1, ct); err != nil {
-		write(map[string]any{"error": err.Error()})
+	count := func(n int) { atomic.AddInt64(&transferred, int64(n)) }
+	if _, err := pipeCopy(rc, abortDownload, count, func(body io.Reader) error {
+		_, err := dstClient.UploadWithSSE(ctx, in.DstBucket, in.DstKey, body, -1, ct, sse)
+		return err
+	}); err != nil {
+		_, msg := s3Failure(r, err)
+		write(map[string]any{"error": msg})
 		close(doneCh)
 		return
 	}
+	copied = true
+	copiedAt := time.Now()
+	db.DB.Model(&mv).Update("copied_at", &copiedAt)
 	// For move, delete the source after upload
 	if err := srcClient.DeleteObject(r.Context(), srcBucket, in.SrcKey); err != nil {
-		write(map[string]any{"error": err.Error()})
+		_, msg := s3Failure(r, err)
+		write(map[string]any{"error": msg})
 		close(doneCh)
 		return
 	}
+	deletedAt := time.Now()
+	db.DB.Model(&mv).Update("deleted_at", &deletedAt)
 	close(doneCh)
+	adjustBucketSummary(uint(pid), srcBucket, -1, -transferred)
+	adjustBucketSummary(uint(dstPid), in.DstBucket, 1, transferred)
 	write(map[string]any{"progress": 100, "bytes": transferred, "total": total, "done": true})
 	addEvent(r, "object.move.end", map[string]any{"ok": true})
 }
 
+// s3Failure records the raw storage error on the request trace and returns the status
+// and message that are safe to send to the client.
+func s3Failure(r *http.Request, err error) (int, string) {
+	addEvent(r, "s3.error", map[string]any{"error": err.Error()})
+	return s3.ClassifyError(err)
+}
+
 // containsNoSuchBucket reports whether the error message indicates the bucket is missing.
 func containsNoSuchBucket(msg string) bool {
 	m := strings.ToLower(msg)
@@ -0,0 +1,1922 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/arencloud/hermes/internal/config"
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+	"github.com/arencloud/hermes/internal/s3"
+	minio "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+// useMockS3 routes every getClient call to a fresh MockClient for the rest of the test.
+func useMockS3(t *testing.T) *s3.MockClient {
+	t.Helper()
+	m := s3.NewMock()
+	prev := clientFactory
+	clientFactory = func(models.Provider) (s3.ClientInterface, error) { return m, nil }
+	s3Clients.purge()
+	t.Cleanup(func() { clientFactory = prev; s3Clients.purge() })
+	return m
+}
+
+func mockProvider(t *testing.T) uint {
+	t.Helper()
+	p := models.Provider{Name: "mock", Type: "minio", Endpoint: "mock:9000"}
+	if err := db.DB.Create(&p).Error; err != nil {
+		t.Fatal(err)
+	}
+	return p.ID
+}
+
+func uploadForm(t *testing.T, key, content string) (*bytes.Buffer, string) {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("key", key)
+	fw, err := mw.CreateFormFile("file", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte(content))
+	mw.Close()
+	return &body, mw.FormDataContentType()
+}
+
+func TestUploadObjectS3ServerError(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	m := useMockS3(t)
+	m.OnUpload = func(bucket, key string, reader io.Reader, size int64, contentType string, sse encrypt.ServerSide) (minio.UploadInfo, error) {
+		return minio.UploadInfo{}, minio.ErrorResponse{StatusCode: 503, Code: "SlowDown", Message: "Please reduce your request rate."}
+	}
+	pid := mockProvider(t)
+	cookie := loginAs(t, ts, "mock-upload@example.com", "editor")
+	body, ct := uploadForm(t, "a.txt", "hello")
+	req, _ := http.NewRequest("POST", fmt.Sprintf("%s/api/v1/providers/%d/buckets/b/upload", ts.URL, pid), body)
+	req.Header.Set("Content-Type", ct)
+	req.AddCookie(cookie)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	out, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 503 || strings.Contains(string(out), "Please reduce your request rate.") {
+		t.Fatalf("expected 503 without the raw S3 error, got %d %q", resp.StatusCode, out)
+	}
+	// the failure is recorded on the request trace, with the raw error kept server-side
+	var tr models.TraceEventRow
+	if err := db.DB.Where("trace_id = ? AND name = ?", resp.Header.Get("X-Trace-Id"), "error").First(&tr).Error; err != nil {
+		t.Fatalf("error event not recorded: %v", err)
+	}
+	var raw models.TraceEventRow
+	if err := db.DB.Where("trace_id = ? AND name = ?", resp.Header.Get("X-Trace-Id"), "s3.error").First(&raw).Error; err != nil || !strings.Contains(raw.Fields, "Please reduce your request rate.") {
+		t.Fatalf("raw S3 error not recorded: %v %q", err, raw.Fields)
+	}
+}
+
+func TestS3ErrorsHideProviderDetails(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	m := useMockS3(t)
+	leak := errors.New(`Get "https://minio.internal:9000/b": internal error`)
+	m.OnListObjectVersions = func(bucket, key string) ([]minio.ObjectInfo, error) { return nil, leak }
+	m.OnGetBucketPolicy = func(bucket string) (string, error) { return "", leak }
+	m.OnListObjects = func(bucket, prefix string) ([]minio.ObjectInfo, error) { return nil, leak }
+	pid := mockProvider(t)
+	cookie := loginAs(t, ts, "s3-errors@example.com", "admin")
+	for _, path := range []string{"/objects/versions?key=a", "/policy", "/objects/stale", "/tiering-recommendations", "/stats?refresh=true"} {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("%s/api/v1/providers/%d/buckets/b%s", ts.URL, pid, path), nil)
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != 500 || strings.Contains(string(out), "minio.internal") || !strings.Contains(string(out), ErrCodeStorageError) {
+			t.Errorf("%s: %d %s", path, resp.StatusCode, out)
+		}
+	}
+}
+
+func TestUploadQueueTimeout(t *testing.T) {
+	ts, _ := setupTestServer(t, func(c *config.Config) { c.UploadConcurrencyInit = 1; c.UploadConcurrencyMax = 1 })
+	defer ts.Close()
+	m := useMockS3(t)
+	m.OnUpload = func(bucket, key string, reader io.Reader, size int64, contentType string, sse encrypt.ServerSide) (minio.UploadInfo, error) {
+		n, err := io.Copy(io.Discard, reader)
+		return minio.UploadInfo{Bucket: bucket, Key: key, Size: n}, err
+	}
+	old := uploadQueueTimeout
+	uploadQueueTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { uploadQueueTimeout = old })
+	pid := mockProvider(t)
+	cookie := loginAs(t, ts, "queue-editor@example.com", "editor")
+	upload := func() (int, string) {
+		t.Helper()
+		body, ct := uploadForm(t, "a.txt", "hello")
+		req, _ := http.NewRequest("POST", fmt.Sprintf("%s/api/v1/providers/%d/buckets/b/upload", ts.URL, pid), body)
+		req.Header.Set("Content-Type", ct)
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		out, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(out)
+	}
+	// a stuck upload holds the only slot: the next one waits uploadQueueTimeout, not forever
+	if err := uploadLimiter.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if code, out := upload(); code != 503 || !strings.Contains(out, string(ErrCodeStorageBusy)) {
+		t.Fatalf("upload behind a full limiter: %d %s", code, out)
+	}
+	uploadLimiter.Release()
+	if code, out := upload(); code != 200 {
+		t.Fatalf("upload after release: %d %s", code, out)
+	}
+	if s := uploadLimiter.Stats(); s.InFlight != 0 || s.Waiting != 0 {
+		t.Fatalf("limiter not released: %+v", s)
+	}
+}
+
+func TestUploadLatency(t *testing.T) {
+	if d := uploadLatency(300*time.Millisecond, 4096); d != 300*time.Millisecond {
+		t.Fatalf("small upload: %v", d)
+	}
+	if d := uploadLatency(20*time.Second, 1<<30); d != 20*time.Second/1024 {
+		t.Fatalf("1 GiB upload: %v, want the latency per MiB", d)
+	}
+}
+
+func TestUploadAndListObjectsWithMock(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	m := useMockS3(t)
+	stored := map[string][]byte{}
+	m.OnUpload = func(bucket, key string, reader io.Reader, size int64, contentType string, sse encrypt.ServerSide) (minio.UploadInfo, error) {
+		b, err := io.ReadAll(reader)
+		stored[key] = b
+		return minio.UploadInfo{Bucket: bucket, Key: key, Size: int64(len(b))}, err
+	}
+	m.OnListObjects = func(bucket, prefix string) ([]minio.ObjectInfo, error) {
+		var out []minio.ObjectInfo
+		for k, v := range stored {
+			if strings.HasPrefix(k, prefix) {
+				out = append(out, minio.ObjectInfo{Key: k, Size: int64(len(v)), LastModified: time.Now()})
+			}
+		}
+		return out, nil
+	}
+	pid := mockProvider(t)
+	cookie := loginAs(t, ts, "mock-list@example.com", "editor")
+	body, ct := uploadForm(t, "docs/readme.md", "# hermes")
+	req, _ := http.NewRequest("POST", fmt.Sprintf("%s/api/v1/providers/%d/buckets/b/upload", ts.URL, pid), body)
+	req.Header.Set("Content-Type", ct)
+	req.AddCookie(cookie)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 || string(stored["docs/readme.md"]) != "# hermes" {
+		t.Fatalf("upload: status=%d stored=%q", resp.StatusCode, stored)
+	}
+
+	req, _ = http.NewRequest("GET", fmt.Sprintf("%s/api/v1/providers/%d/buckets/b/objects?prefix=docs/", ts.URL, pid), nil)
+	req.AddCookie(cookie)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var list struct {
+		Data objectPage `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&list)
+	if resp.StatusCode != 200 || len(list.Data.Objects) != 1 || list.Data.Objects[0].Key != "docs/readme.md" || list.Data.Objects[0].Size != 8 || list.Data.IsTruncated {
+		t.Fatalf("list: status=%d %+v", resp.StatusCode, list.Data)
+	}
+}
+
+func TestBatchCopy(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	m := useMockS3(t)
+	var mu sync.Mutex
+	serverSide, streamed := map[string]string{}, map[string]string{}
+	m.OnCopyObject = func(srcBucket, srcKey, dstBucket, dstKey string, sse encrypt.ServerSide) error {
+		if srcKey == "missing.txt" {
+			return minio.ErrorResponse{StatusCode: 404, Code: "NoSuchKey", Message: "The specified key does not exist."}
+		}
+		mu.Lock()
+		serverSide[srcKey] = dstBucket + "/" + dstKey
+		mu.Unlock()
+		return nil
+	}
+	m.OnDownloadWithInfo = func(bucket, key string) (io.ReadCloser, int64, error) {
+		return io.NopCloser(strings.NewReader("data")), 4, nil
+	}
+	m.OnUpload = func(bucket, key string, reader io.Reader, size int64, contentType string, sse encrypt.ServerSide) (minio.UploadInfo, error) {
+		b, _ := io.ReadAll(reader)
+		mu.Lock()
+		streamed[bucket+"/"+key] = string(b)
+		mu.Unlock()
+		return minio.UploadInfo{Size: int64(len(b))}, nil
+	}
+	src := mockProvider(t)
+	other := mockProvider(t)
+	cookie := loginAs(t, ts, "batch@example.com", "editor")
+	post := func(body any) (int, []byte) {
+		b, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", fmt.Sprintf("%s/api/v1/providers/%d/buckets/src/batch-copy", ts.URL, src), bytes.NewReader(b))
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		out, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, out
+	}
+
+	ops := []map[string]any{
+		{"srcKey": "a.txt", "dstBucket": "dst"},
+		{"srcKey": "b.txt", "dstBucket": "dst", "dstKey": "renamed.txt"},
+		{"srcKey": "missing.txt", "dstBucket": "dst"},
+		{"srcKey": "c.txt", "dstBucket": "remote", "dstProviderId": other},
+		{"srcKey": "d.txt", "dstBucket": "remote", "dstProviderId": other + 100},
+	}
+	code, raw := post(map[string]any{"operations": ops, "maxParallel": 50})
+	var out struct {
+		Data struct {
+			Results []batchCopyResult `json:"results"`
+			Success int               `json:"success"`
+			Failed  int               `json:"failed"`
+		} `json:"data"`
+	}
+	json.Unmarshal(raw, &out)
+	if code != 200 || out.Data.Success != 3 || out.Data.Failed != 2 || len(out.Data.Results) != 5 {
+		t.Fatalf("code=%d body=%s", code, raw)
+	}
+	for i, want := range []bool{true, true, false, true, false} {
+		if r := out.Data.Results[i]; r.OK != want || r.SrcKey != ops[i]["srcKey"] {
+			t.Fatalf("result %d: %+v", i, r)
+		}
+	}
+	if serverSide["a.txt"] != "dst/a.txt" || serverSide["b.txt"] != "dst/renamed.txt" || streamed["remote/c.txt"] != "data" {
+		t.Fatalf("copies not routed as expected: serverSide=%v streamed=%v", serverSide, streamed)
+	}
+
+	tooMany := make([]map[string]any, batchCopyMaxOps+1)
+	for i := range tooMany {
+		tooMany[i] = map[string]any{"srcKey": "k", "dstBucket": "dst"}
+	}
+	if code, _ := post(map[string]any{"operations": tooMany}); code != 400 {
+		t.Fatalf("expected 400 for %d operations, got %d", len(tooMany), code)
+	}
+}
+
+func TestRestoreObjectVersion(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	m := useMockS3(t)
+	versioning := true
+	m.OnVersioningEnabled = func(bucket string) (bool, error) { return versioning, nil }
+	m.OnListObjectVersions = func(bucket, key string) ([]minio.ObjectInfo, error) {
+		return []minio.ObjectInfo{
+			{Key: key, VersionID: "v3", IsLatest: true},
+			{Key: key, VersionID: "v2", IsDeleteMarker: true},
+			{Key: key, VersionID: "v1"},
+		}, nil
+	}
+	var restored string
+	m.OnRestoreObjectVersion = func(bucket, key, versionID string) (string, error) {
+		restored = versionID
+		return "v4", nil
+	}
+	pid := mockProvider(t)
+	editor := loginAs(t, ts, "restore@example.com", "editor")
+	viewer := loginAs(t, ts, "restore-viewer@example.com", "viewer")
+	restore := func(cookie *http.Cookie, versionID string) (int, string) {
+		b, _ := json.Marshal(map[string]string{"key": "photo.jpg", "versionId": versionID})
+		req, _ := http.NewRequest("POST", fmt.Sprintf("%s/api/v1/providers/%d/buckets/b/objects/restore-version", ts.URL, pid), bytes.NewReader(b))
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		out, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(out)
+	}
+	if code, _ := restore(viewer, "v1"); code != 403 {
+		t.Fatalf("viewer: expected 403, got %d", code)
+	}
+	for _, c := range []struct {
+		version string
+		code    int
+	}{{"v3", 400}, {"v2", 400}, {"nope", 404}} {
+		if code, body := restore(editor, c.version); code != c.code {
+			t.Fatalf("version %s: expected %d, got %d %s", c.version, c.code, code, body)
+		}
+	}
+	if restored != "" {
+		t.Fatalf("rejected restores must not copy, restored %q", restored)
+	}
+	code, body := restore(editor, "v1")
+	if code != 200 || restored != "v1" || !strings.Contains(body, `"newVersionId":"v4"`) || !strings.Contains(body, `"restoredFrom":"v1"`) {
+		t.Fatalf("restore: code=%d body=%s", code, body)
+	}
+	versioning = false
+	if code, body := restore(editor, "v1"); code != 409 || !strings.Contains(body, "versioning is not enabled") {
+		t.Fatalf("unversioned bucket: code=%d body=%s", code, body)
+	}
+}
+
+func TestBucketMaxObjectSize(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	m := useMockS3(t)
+	m.OnUpload = func(bucket, key string, reader io.Reader, size int64, contentType string, sse encrypt.ServerSide) (minio.UploadInfo, error) {
+		n, err := io.Copy(io.Discard, reader)
+		return minio.UploadInfo{Bucket: bucket, Key: key, Size: n}, err
+	}
+	pid := mockProvider(t)
+	admin := loginAs(t, ts, "size-admin@example.com", "admin")
+	editor := loginAs(t, ts, "size-editor@example.com", "editor")
+	cfgURL := fmt.Sprintf("%s/api/v1/providers/%d/buckets/thumbs/config", ts.URL, pid)
+	do := func(method, url string, cookie *http.Cookie, body io.Reader, ct string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, url, body)
+		if ct != "" {
+			req.Header.Set("Content-Type", ct)
+		}
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	if resp := do("PUT", cfgURL, editor, strings.NewReader(`{"maxObjectSizeBytes":1024}`), "application/json"); resp.StatusCode != 403 {
+		t.Fatalf("editor set limit: %d", resp.StatusCode)
+	}
+	if resp := do("PUT", cfgURL, admin, strings.NewReader(`{"maxObjectSizeBytes":1024}`), "application/json"); resp.StatusCode != 200 {
+		t.Fatalf("admin set limit: %d", resp.StatusCode)
+	}
+	req, _ := http.NewRequest("GET", cfgURL, nil)
+	req.AddCookie(editor)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Data bucketConfig `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&got)
+	resp.Body.Close()
+	if got.Data.MaxObjectSizeBytes != 1024 {
+		t.Fatalf("config = %+v", got.Data)
+	}
+
+	upURL := fmt.Sprintf("%s/api/v1/providers/%d/buckets/thumbs/upload", ts.URL, pid)
+	body, ct := uploadForm(t, "small.png", strings.Repeat("x", 100))
+	if resp := do("POST", upURL, editor, body, ct); resp.StatusCode != 200 {
+		t.Fatalf("small upload: %d", resp.StatusCode)
+	}
+	body, ct = uploadForm(t, "big.png", strings.Repeat("x", 4096))
+	if resp := do("POST", upURL, editor, body, ct); resp.StatusCode != 413 {
+		t.Fatalf("big upload: expected 413, got %d", resp.StatusCode)
+	}
+	// without Content-Length the limit is hit while streaming
+	body, ct = uploadForm(t, "big.png", strings.Repeat("x", 4096))
+	if resp := do("POST", upURL, editor, io.MultiReader(body), ct); resp.StatusCode != 413 {
+		t.Fatalf("chunked big upload: expected 413, got %d", resp.StatusCode)
+	}
+	// other buckets keep the global limit
+	body, ct = uploadForm(t, "big.png", strings.Repeat("x", 4096))
+	if resp := do("POST", fmt.Sprintf("%s/api/v1/providers/%d/buckets/other/upload", ts.URL, pid), editor, body, ct); resp.StatusCode != 200 {
+		t.Fatalf("unlimited bucket: %d", resp.StatusCode)
+	}
+}
+
+func TestProviderQuota(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	m := useMockS3(t)
+	m.OnUpload = func(bucket, key string, reader io.Reader, size int64, contentType string, sse encrypt.ServerSide) (minio.UploadInfo, error) {
+		n, err := io.Copy(io.Discard, reader)
+		return minio.UploadInfo{Bucket: bucket, Key: key, Size: n}, err
+	}
+	m.OnDownload = func(bucket, key string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(strings.Repeat("d", 150))), nil
+	}
+	p := models.Provider{Name: "quota", Type: "minio", Endpoint: "mock:9000", UploadQuotaBytes: 1000, DownloadQuotaBytes: 100, QuotaPeriod: "daily"}
+	if err := db.DB.Create(&p).Error; err != nil {
+		t.Fatal(err)
+	}
+	admin := loginAs(t, ts, "quota-admin@example.com", "admin")
+	editor := loginAs(t, ts, "quota-editor@example.com", "editor")
+	send := func(method, url string, cookie *http.Cookie, body io.Reader, ct string) (*http.Response, []byte) {
+		t.Helper()
+		req, _ := http.NewRequest(method, url, body)
+		if ct != "" {
+			req.Header.Set("Content-Type", ct)
+		}
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		out, _ := io.ReadAll(resp.Body)
+		return resp, out
+	}
+	base := fmt.Sprintf("%s/api/v1/providers/%d", ts.URL, p.ID)
+
+	body, ct := uploadForm(t, "a.bin", strings.Repeat("x", 400))
+	if resp, out := send("POST", base+"/buckets/b/upload", editor, body, ct); resp.StatusCode != 200 {
+		t.Fatalf("first upload: %d %s", resp.StatusCode, out)
+	}
+	body, ct = uploadForm(t, "b.bin", strings.Repeat("x", 700))
+	resp, out := send("POST", base+"/buckets/b/upload", editor, body, ct)
+	var rej struct {
+		Error     string `json:"error"`
+		Remaining int64  `json:"remaining"`
+	}
+	json.Unmarshal(out, &rej)
+	if resp.StatusCode != 429 || rej.Error != "quota_exceeded" || rej.Remaining != 600 {
+		t.Fatalf("over-quota upload: %d %s", resp.StatusCode, out)
+	}
+
+	// download size is unknown up front: the first one is served, the next is refused
+	if resp, _ := send("GET", base+"/buckets/b/download?key=a.bin", editor, nil, ""); resp.StatusCode != 200 {
+		t.Fatalf("first download: %d", resp.StatusCode)
+	}
+	if resp, _ := send("GET", base+"/buckets/b/download?key=a.bin", editor, nil, ""); resp.StatusCode != 429 {
+		t.Fatalf("exhausted download quota: %d", resp.StatusCode)
+	}
+
+	if resp, _ := send("GET", base+"/quota", editor, nil, ""); resp.StatusCode != 403 {
+		t.Fatalf("editor quota view: %d", resp.StatusCode)
+	}
+	resp, out = send("GET", base+"/quota", admin, nil, "")
+	var q struct {
+		Data struct {
+			PeriodType      string `json:"periodType"`
+			UploadedBytes   int64  `json:"uploadedBytes"`
+			DownloadedBytes int64  `json:"downloadedBytes"`
+		} `json:"data"`
+	}
+	json.Unmarshal(out, &q)
+	if resp.StatusCode != 200 || q.Data.PeriodType != "daily" || q.Data.UploadedBytes != 400 || q.Data.DownloadedBytes != 150 {
+		t.Fatalf("quota: %d %s", resp.StatusCode, out)
+	}
+
+	// a period that has ended is reset by the job
+	db.DB.Model(&models.ProviderQuota{}).Where("provider_id = ?", p.ID).Update("period_start", time.Now().AddDate(0, 0, -2))
+	if err := resetQuotas(); err != nil {
+		t.Fatal(err)
+	}
+	var row models.ProviderQuota
+	db.DB.First(&row, "provider_id = ?", p.ID)
+	if row.UploadedBytes != 0 || row.DownloadedBytes != 0 || !row.PeriodStart.Equal(quotaPeriodStart(time.Now(), "daily")) {
+		t.Fatalf("not reset: %+v", row)
+	}
+}
+
+func TestDrainUploadsAbortsAfterDeadline(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	t.Cleanup(func() {
+		uploads.mu.Lock()
+		uploads.draining = false
+		uploads.mu.Unlock()
+	})
+	m := useMockS3(t)
+	started, release := make(chan struct{}), make(chan struct{})
+	m.OnUpload = func(bucket, key string, reader io.Reader, size int64, contentType string, sse encrypt.ServerSide) (minio.UploadInfo, error) {
+		close(started)
+		<-release
+		return minio.UploadInfo{}, context.Canceled
+	}
+	var aborted string
+	m.OnAbortMultipartUpload = func(bucket, key string) error {
+		aborted = bucket + "/" + key
+		close(release)
+		return nil
+	}
+	pid := mockProvider(t)
+	cookie := loginAs(t, ts, "drain@example.com", "editor")
+	upload := func(key string) int {
+		body, ct := uploadForm(t, key, "payload")
+		req, _ := http.NewRequest("POST", fmt.Sprintf("%s/api/v1/providers/%d/buckets/b/upload", ts.URL, pid), body)
+		req.Header.Set("Content-Type", ct)
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	status := make(chan int, 1)
+	go func() { status <- upload("big.bin") }()
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := DrainUploads(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected the drain deadline to expire, got %v", err)
+	}
+	if aborted != "b/big.bin" {
+		t.Fatalf("multipart upload not aborted: %q", aborted)
+	}
+	if code := <-status; code != 500 {
+		t.Fatalf("aborted upload: expected 500, got %d", code)
+	}
+	if code := upload("late.bin"); code != 503 {
+		t.Fatalf("upload while draining: expected 503, got %d", code)
+	}
+}
+
+func TestUploadToken(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	m := useMockS3(t)
+	stored := map[string]string{}
+	m.OnUpload = func(bucket, key string, reader io.Reader, size int64, contentType string, sse encrypt.ServerSide) (minio.UploadInfo, error) {
+		b, err := io.ReadAll(reader)
+		stored[bucket+"/"+key] = contentType + ":" + string(b)
+		return minio.UploadInfo{Bucket: bucket, Key: key, Size: int64(len(b))}, err
+	}
+	pid := mockProvider(t)
+	editor := loginAs(t, ts, "token-editor@example.com", "editor")
+	b, _ := json.Marshal(map[string]any{"key": "avatars/me.png", "contentType": "image/png", "maxSizeBytes": 8, "ttlSeconds": 60})
+	req, _ := http.NewRequest("POST", fmt.Sprintf("%s/api/v1/providers/%d/buckets/b/upload-token", ts.URL, pid), bytes.NewReader(b))
+	req.AddCookie(editor)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Data struct {
+			Token     string `json:"token"`
+			UploadURL string `json:"uploadUrl"`
+		} `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&out)
+	resp.Body.Close()
+	if resp.StatusCode != 201 || out.Data.UploadURL != "/api/v1/upload/"+out.Data.Token {
+		t.Fatalf("issue token: status=%d out=%+v", resp.StatusCode, out.Data)
+	}
+	// the upload itself carries no session cookie
+	upload := func(token, contentType, body string) int {
+		resp, err := http.Post(ts.URL+"/api/v1/upload/"+token, contentType, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := upload(out.Data.Token, "image/jpeg", "pixels"); code != 415 {
+		t.Fatalf("wrong content type: expected 415, got %d", code)
+	}
+	if code := upload(out.Data.Token, "image/png", "too many pixels"); code != 413 {
+		t.Fatalf("oversized body: expected 413, got %d", code)
+	}
+	tampered := out.Data.Token[:len(out.Data.Token)-2] + "xx"
+	if code := upload(tampered, "image/png", "pixels"); code != 401 {
+		t.Fatalf("tampered token: expected 401, got %d", code)
+	}
+	expired := signUploadToken(uploadClaims{ProviderID: pid, Bucket: "b", Key: "late.png", ContentType: "image/png", MaxSize: 8, Expires: time.Now().Add(-time.Second).Unix()})
+	if code := upload(expired, "image/png", "pixels"); code != 401 {
+		t.Fatalf("expired token: expected 401, got %d", code)
+	}
+	if len(stored) != 0 {
+		t.Fatalf("rejected uploads reached S3: %v", stored)
+	}
+	if code := upload(out.Data.Token, "image/png", "pixels"); code != 200 || stored["b/avatars/me.png"] != "image/png:pixels" {
+		t.Fatalf("upload: status=%d stored=%v", code, stored)
+	}
+	// traces are readable by every user, so they must not carry the token
+	for _, tr := range traces.all(0) {
+		if strings.Contains(tr.Path, out.Data.Token) || strings.Contains(tr.Path, tampered) {
+			t.Fatalf("trace path exposes the upload token: %s", tr.Path)
+		}
+	}
+	// upload and provider access tokens are signed alike but not interchangeable
+	access := signProviderToken(providerTokenClaims{ProviderID: pid, Permissions: []string{"write"}, Expires: time.Now().Add(time.Minute).Unix()})
+	if code := upload(access, "image/png", "pixels"); code != 401 {
+		t.Fatalf("provider access token as upload token: expected 401, got %d", code)
+	}
+	if _, err := parseProviderToken(out.Data.Token, time.Now()); err == nil {
+		t.Fatal("upload token accepted as a provider access token")
+	}
+	// a size limit set on the bucket after the token was issued still applies
+	if err := db.DB.Create(&models.Bucket{ProviderID: pid, Name: "b", MaxObjectSizeBytes: 4}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if code := upload(out.Data.Token, "image/png", "pixels"); code != 413 {
+		t.Fatalf("upload above the bucket limit: expected 413, got %d", code)
+	}
+}
+
+func TestProviderHealthAlerts(t *testing.T) {
+	var mu sync.Mutex
+	var alerts []map[string]any
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a map[string]any
+		json.NewDecoder(r.Body).Decode(&a)
+		mu.Lock()
+		alerts = append(alerts, a)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+	ts, _ := setupTestServer(t, func(c *config.Config) { c.ProviderAlertWebhookURL = hook.URL })
+	defer ts.Close()
+	m := useMockS3(t)
+	down := true
+	m.OnListBuckets = func() ([]minio.BucketInfo, error) {
+		if down {
+			return nil, fmt.Errorf("dial tcp: connection refused")
+		}
+		return nil, nil
+	}
+	pid := mockProvider(t)
+	events := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		var out []string
+		for _, a := range alerts {
+			out = append(out, a["event"].(string))
+		}
+		return out
+	}
+	for i := 0; i < 4; i++ {
+		if err := checkProviders(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// one alert at the third failure, none for the fourth
+	if got := events(); len(got) != 1 || got[0] != "provider.unreachable" || alerts[0]["consecutiveFailures"] != float64(3) || alerts[0]["error"] != "dial tcp: connection refused" {
+		t.Fatalf("unexpected alerts after 4 failures: %v", alerts)
+	}
+
+	cookie := loginAs(t, ts, "health@example.com", "editor")
+	req, _ := http.NewRequest("GET", fmt.Sprintf("%s/api/v1/providers/%d/health", ts.URL, pid), nil)
+	req.AddCookie(cookie)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var h struct {
+		Data models.ProviderHealth `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&h)
+	resp.Body.Close()
+	if resp.StatusCode != 200 || h.Data.ConsecutiveFailures != 4 || h.Data.AlertSentAt == nil || h.Data.LastCheckedAt == nil {
+		t.Fatalf("health: status=%d %+v", resp.StatusCode, h.Data)
+	}
+
+	down = false
+	if err := checkProviders(); err != nil {
+		t.Fatal(err)
+	}
+	if got := events(); len(got) != 2 || got[1] != "provider.recovered" {
+		t.Fatalf("expected provider.recovered, got %v", got)
+	}
+	var stored models.ProviderHealth
+	db.DB.First(&stored, "provider_id = ?", pid)
+	if stored.ConsecutiveFailures != 0 || stored.AlertSentAt != nil {
+		t.Fatalf("recovery did not reset the streak: %+v", stored)
+	}
+}
+
+func TestBucketStatsSummary(t *testing.T) {
+	ts, _ := setupTestServer(t, func(c *config.Config) { c.IncrementalStats = true })
+	defer ts.Close()
+	store := newMemS3(t)
+	store.buckets["docs"] = map[string][]byte{"a.txt": []byte("12345"), "b.txt": []byte("123")}
+	pid := mockProvider(t)
+	cookie := loginAs(t, ts, "stats@example.com", "editor")
+	type stats struct {
+		ObjectCount int64 `json:"objectCount"`
+		TotalBytes  int64 `json:"totalBytes"`
+		Stale       bool  `json:"stale"`
+	}
+	get := func(query string) stats {
+		t.Helper()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("%s/api/v1/providers/%d/buckets/docs/stats%s", ts.URL, pid, query), nil)
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out struct {
+			Data stats `json:"data"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil || resp.StatusCode != 200 {
+			t.Fatalf("stats: status=%d err=%v", resp.StatusCode, err)
+		}
+		return out.Data
+	}
+	if s := get(""); s != (stats{ObjectCount: 2, TotalBytes: 8}) {
+		t.Fatalf("first request should compute the summary: %+v", s)
+	}
+	// changes made outside Hermes only show up after a recompute
+	store.mu.Lock()
+	store.buckets["docs"]["outside.txt"] = []byte("1")
+	store.mu.Unlock()
+	body, ct := uploadForm(t, "c.txt", "1234567890")
+	req, _ := http.NewRequest("POST", fmt.Sprintf("%s/api/v1/providers/%d/buckets/docs/upload", ts.URL, pid), body)
+	req.Header.Set("Content-Type", ct)
+	req.AddCookie(cookie)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	req, _ = http.NewRequest("DELETE", fmt.Sprintf("%s/api/v1/providers/%d/buckets/docs/objects?key=a.txt", ts.URL, pid), nil)
+	req.AddCookie(cookie)
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if s := get(""); s != (stats{ObjectCount: 2, TotalBytes: 13}) {
+		t.Fatalf("incremental update after upload and delete: %+v", s)
+	}
+	db.DB.Model(&models.BucketSummary{}).Where("provider_id = ?", pid).Update("last_computed_at", time.Now().Add(-48*time.Hour))
+	if s := get(""); !s.Stale {
+		t.Fatalf("old summary not reported stale: %+v", s)
+	}
+	if s := get("?refresh=true"); s != (stats{ObjectCount: 3, TotalBytes: 14}) {
+		t.Fatalf("refresh: %+v", s)
+	}
+}
+
+func TestBucketStatsCacheAndPartial(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	prev := bucketStatsTimeout
+	t.Cleanup(func() { bucketStatsTimeout = prev })
+	m := useMockS3(t)
+	newest := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	var listings atomic.Int32
+	var delay atomic.Int64
+	m.OnListObjects = func(bucket, prefix string) ([]minio.ObjectInfo, error) {
+		listings.Add(1)
+		time.Sleep(time.Duration(delay.Load()))
+		return []minio.ObjectInfo{{Key: "a", Size: 4, LastModified: newest.Add(-time.Hour)}, {Key: "b", Size: 6, LastModified: newest}}, nil
+	}
+	pid := mockProvider(t)
+	cookie := loginAs(t, ts, "stats-cache@example.com", "viewer")
+	type stats struct {
+		ObjectCount  int64     `json:"objectCount"`
+		TotalBytes   int64     `json:"totalBytes"`
+		LastModified time.Time `json:"lastModified"`
+		Partial      bool      `json:"partial"`
+	}
+	get := func(query string) stats {
+		t.Helper()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("%s/api/v1/providers/%d/buckets/big/stats%s", ts.URL, pid, query), nil)
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out struct {
+			Data stats `json:"data"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil || resp.StatusCode != 200 {
+			t.Fatalf("stats: status=%d err=%v", resp.StatusCode, err)
+		}
+		return out.Data
+	}
+	if s := get(""); s.ObjectCount != 2 || s.TotalBytes != 10 || !s.LastModified.Equal(newest) || s.Partial {
+		t.Fatalf("first request: %+v", s)
+	}
+	if s := get(""); s.ObjectCount != 2 || listings.Load() != 1 {
+		t.Fatalf("second request should be served from the summary: %+v after %d listings", s, listings.Load())
+	}
+	if get("?nocache=true"); listings.Load() != 2 {
+		t.Fatalf("nocache=true did not relist: %d listings", listings.Load())
+	}
+
+	bucketStatsTimeout = 20 * time.Millisecond
+	delay.Store(int64(100 * time.Millisecond))
+	if s := get("?nocache=true"); !s.Partial {
+		t.Fatalf("listing past the deadline not reported partial: %+v", s)
+	}
+	var stored models.BucketSummary
+	db.DB.First(&stored, "provider_id = ? AND bucket = ?", pid, "big")
+	if stored.ObjectCount != 2 {
+		t.Fatalf("partial totals replaced the stored summary: %+v", stored)
+	}
+}
+
+func TestUploadStreamProgress(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	m := useMockS3(t)
+	var stored []byte
+	m.OnUpload = func(bucket, key string, reader io.Reader, size int64, contentType string, sse encrypt.ServerSide) (minio.UploadInfo, error) {
+		half := make([]byte, size/2)
+		io.ReadFull(reader, half)
+		time.Sleep(2 * uploadProgressInterval) // let a progress frame go out mid-upload
+		rest, err := io.ReadAll(reader)
+		stored = append(half, rest...)
+		return minio.UploadInfo{Bucket: bucket, Key: key, Size: int64(len(stored))}, err
+	}
+	pid := mockProvider(t)
+	cookie := loginAs(t, ts, "stream-editor@example.com", "editor")
+	do := func(method, path string, body io.Reader) *http.Response {
+		req, _ := http.NewRequest(method, ts.URL+path, body)
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	resp := do("POST", fmt.Sprintf("/api/v1/providers/%d/buckets/b/upload-session", pid), nil)
+	var sess struct {
+		Data struct {
+			UploadToken string `json:"uploadToken"`
+			ProgressURL string `json:"progressUrl"`
+		} `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&sess)
+	resp.Body.Close()
+	if resp.StatusCode != 201 || sess.Data.UploadToken == "" {
+		t.Fatalf("upload session: %d %+v", resp.StatusCode, sess.Data)
+	}
+
+	events := do("GET", sess.Data.ProgressURL, nil)
+	defer events.Body.Close()
+	if ct := events.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("progress stream content type %q", ct)
+	}
+	payload := strings.Repeat("x", 4096)
+	upload := func() int {
+		resp := do("POST", fmt.Sprintf("/api/v1/providers/%d/buckets/b/upload-stream?key=big.bin&size=%d&uploadToken=%s", pid, len(payload), sess.Data.UploadToken), strings.NewReader(payload))
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := upload(); code != 200 || string(stored) != payload {
+		t.Fatalf("upload-stream: %d, stored %d bytes", code, len(stored))
+	}
+	var frames []map[string]any
+	sc := bufio.NewScanner(events.Body)
+	for sc.Scan() {
+		if data, ok := strings.CutPrefix(sc.Text(), "data: "); ok {
+			var f map[string]any
+			json.Unmarshal([]byte(data), &f)
+			frames = append(frames, f)
+		}
+	}
+	if len(frames) < 3 {
+		t.Fatalf("frames %v", frames)
+	}
+	first, last := frames[0], frames[len(frames)-1]
+	if n, _ := first["bytesUploaded"].(float64); n == 0 || n >= 4096 || first["totalBytes"] != float64(4096) || first["percent"] != float64(int(n*100/4096)) {
+		t.Fatalf("mid-upload frame %v", first)
+	}
+	if frames[len(frames)-2]["percent"] != float64(100) || last["done"] != true || last["key"] != "big.bin" {
+		t.Fatalf("closing frames %v", frames)
+	}
+	// the token is single use
+	if code := upload(); code != 401 {
+		t.Fatalf("reused token: expected 401, got %d", code)
+	}
+}
+
+func TestUploadStreamOutlivesAPITimeout(t *testing.T) {
+	ts, _ := setupTestServer(t, func(c *config.Config) { c.ApiTimeoutSec = 1 })
+	defer ts.Close()
+	m := useMockS3(t)
+	m.OnUpload = func(bucket, key string, reader io.Reader, size int64, contentType string, sse encrypt.ServerSide) (minio.UploadInfo, error) {
+		time.Sleep(1500 * time.Millisecond)
+		n, err := io.Copy(io.Discard, reader)
+		return minio.UploadInfo{Bucket: bucket, Key: key, Size: n}, err
+	}
+	pid := mockProvider(t)
+	cookie := loginAs(t, ts, "stream-slow@example.com", "editor")
+	do := func(method, path string, body io.Reader) *http.Response {
+		req, _ := http.NewRequest(method, ts.URL+path, body)
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	resp := do("POST", fmt.Sprintf("/api/v1/providers/%d/buckets/b/upload-session", pid), nil)
+	var sess struct {
+		Data struct {
+			UploadToken string `json:"uploadToken"`
+			ProgressURL string `json:"progressUrl"`
+		} `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&sess)
+	resp.Body.Close()
+	events := do("GET", sess.Data.ProgressURL, nil)
+	defer events.Body.Close()
+	if events.StatusCode != 200 || events.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("progress stream: %d %q", events.StatusCode, events.Header.Get("Content-Type"))
+	}
+	resp = do("POST", fmt.Sprintf("/api/v1/providers/%d/buckets/b/upload-stream?key=slow.bin&uploadToken=%s", pid, sess.Data.UploadToken), strings.NewReader("payload"))
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("upload-stream past TIMEOUT_API_SEC: %d", resp.StatusCode)
+	}
+	var last string
+	sc := bufio.NewScanner(events.Body)
+	for sc.Scan() {
+		if data, ok := strings.CutPrefix(sc.Text(), "data: "); ok {
+			last = data
+		}
+	}
+	if !strings.Contains(last, `"done":true`) {
+		t.Fatalf("progress stream ended with %q", last)
+	}
+}
+
+func TestAPITimeoutOptOut(t *testing.T) {
+	ts, _ := setupTestServer(t, func(c *config.Config) { c.ApiTimeoutSec = 1 })
+	defer ts.Close()
+	m := useMockS3(t)
+	slow := 1500 * time.Millisecond
+	m.OnListObjects = func(bucket, prefix string) ([]minio.ObjectInfo, error) {
+		time.Sleep(slow)
+		return nil, nil
+	}
+	m.OnDeleteObjects = func(bucket string, keys []string) ([]s3.ObjectError, error) {
+		time.Sleep(slow)
+		return nil, nil
+	}
+	pid := mockProvider(t)
+	cookie := loginAs(t, ts, "timeout-editor@example.com", "editor")
+	do := func(method, path, body string) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, fmt.Sprintf("%s/api/v1/providers/%d/buckets/b%s", ts.URL, pid, path), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	// bulk deletes opt out of TIMEOUT_API_SEC; listings do not
+	if code := do("DELETE", "/objects/bulk", `{"keys":["a","b"]}`); code != 200 {
+		t.Fatalf("bulk delete past TIMEOUT_API_SEC: %d, want 200", code)
+	}
+	if code := do("GET", "/objects", ""); code != 504 {
+		t.Fatalf("listing past TIMEOUT_API_SEC: %d, want 504", code)
+	}
+}
+
+func TestCopyVerifyMetadata(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	m := useMockS3(t)
+	m.OnDownloadWithInfo = func(bucket, key string) (io.ReadCloser, int64, error) {
+		return io.NopCloser(strings.NewReader("hello")), 5, nil
+	}
+	m.OnUpload = func(bucket, key string, r io.Reader, size int64, ct string, sse encrypt.ServerSide) (minio.UploadInfo, error) {
+		n, err := io.Copy(io.Discard, r)
+		return minio.UploadInfo{Key: key, Size: n}, err
+	}
+	m.OnStat = func(bucket, key string) (minio.ObjectInfo, error) {
+		if bucket == "src" {
+			return minio.ObjectInfo{Key: key, ETag: "abc", Size: 5, ContentType: "text/plain", UserMetadata: minio.StringMap{"Owner": "ops"}}, nil
+		}
+		return minio.ObjectInfo{Key: key, ETag: "abc", Size: 5, ContentType: "application/octet-stream"}, nil
+	}
+	src, dst := mockProvider(t), mockProvider(t)
+	cookie := loginAs(t, ts, "mock-verify@example.com", "editor")
+	b, _ := json.Marshal(map[string]any{"srcKey": "a.txt", "dstBucket": "dst", "dstProviderId": dst})
+	req, _ := http.NewRequest("POST", fmt.Sprintf("%s/api/v1/providers/%d/buckets/src/copy?verifyMetadata=true", ts.URL, src), bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(cookie)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var last map[string]any
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		last = nil
+		json.Unmarshal(sc.Bytes(), &last)
+	}
+	diff, _ := last["metadataDiff"].(map[string]any)
+	if diff == nil || last["warning"] != "metadata mismatch detected" {
+		t.Fatalf("expected a mismatch diff as the last frame, got %v", last)
+	}
+	if f := diff["size"].(map[string]any); f["match"] != true || f["src"] != float64(5) {
+		t.Fatalf("size %v", f)
+	}
+	if f := diff["contentType"].(map[string]any); f["match"] != false || f["dst"] != "application/octet-stream" {
+		t.Fatalf("contentType %v", f)
+	}
+	if f := diff["userMetadata.Owner"].(map[string]any); f["match"] != false || f["src"] != "ops" {
+		t.Fatalf("userMetadata.Owner %v", f)
+	}
+}
+
+func TestConditionalWrite(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	m := useMockS3(t)
+	existing := map[string]bool{"taken.txt": true}
+	m.OnStat = func(bucket, key string) (minio.ObjectInfo, error) {
+		if existing[key] {
+			return minio.ObjectInfo{Key: key, Size: 1}, nil
+		}
+		return minio.ObjectInfo{}, minio.ErrorResponse{StatusCode: 404, Code: "NoSuchKey"}
+	}
+	var uploaded []string
+	m.OnUpload = func(bucket, key string, r io.Reader, size int64, ct string, sse encrypt.ServerSide) (minio.UploadInfo, error) {
+		io.Copy(io.Discard, r)
+		uploaded = append(uploaded, key)
+		return minio.UploadInfo{Key: key}, nil
+	}
+	m.OnDownloadWithInfo = func(bucket, key string) (io.ReadCloser, int64, error) {
+		return io.NopCloser(strings.NewReader("x")), 1, nil
+	}
+	pid := mockProvider(t)
+	cookie := loginAs(t, ts, "mock-cond@example.com", "editor")
+	do := func(req *http.Request) (int, map[string]any) {
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out map[string]any
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+	upload := func(key string) (int, map[string]any) {
+		body, ct := uploadForm(t, key, "hello")
+		req, _ := http.NewRequest("POST", fmt.Sprintf("%s/api/v1/providers/%d/buckets/b/upload?ifNoneMatch=*", ts.URL, pid), body)
+		req.Header.Set("Content-Type", ct)
+		return do(req)
+	}
+
+	code, out := upload("taken.txt")
+	if code != 412 || out["error"] != "precondition_failed" || out["message"] != "object already exists" {
+		t.Fatalf("existing key: expected 412 precondition_failed, got %d %v", code, out)
+	}
+	if code, out := upload("free.txt"); code != 200 || out["Key"] != "free.txt" {
+		t.Fatalf("new key: expected 200, got %d %v", code, out)
+	}
+	if len(uploaded) != 1 || uploaded[0] != "free.txt" {
+		t.Fatalf("expected only free.txt to be written, got %v", uploaded)
+	}
+
+	copyTo := func(dstKey string) int {
+		b, _ := json.Marshal(map[string]any{"srcKey": "src.txt", "dstBucket": "b", "dstKey": dstKey, "ifNoneMatch": "*"})
+		req, _ := http.NewRequest("POST", fmt.Sprintf("%s/api/v1/providers/%d/buckets/b/copy", ts.URL, pid), bytes.NewReader(b))
+		req.Header.Set("Content-Type", "application/json")
+		code, _ := do(req)
+		return code
+	}
+	if code := copyTo("taken.txt"); code != 412 {
+		t.Fatalf("copy onto an existing key: expected 412, got %d", code)
+	}
+	if code := copyTo("copy.txt"); code != 200 || uploaded[len(uploaded)-1] != "copy.txt" {
+		t.Fatalf("copy to a new key: expected 200 and a write, got %d %v", code, uploaded)
+	}
+}
+
+// closingMock counts the CloseIdleConnections calls made when the client cache evicts it.
+type closingMock struct {
+	*s3.MockClient
+	closed *int32
+}
+
+func (c closingMock) CloseIdleConnections() { atomic.AddInt32(c.closed, 1) }
+
+func TestClientCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	useMockS3(t)
+	var closed int32
+	clientFactory = func(models.Provider) (s3.ClientInterface, error) {
+		return closingMock{MockClient: s3.NewMock(), closed: &closed}, nil
+	}
+	pids := make([]uint, 55)
+	for i := range pids {
+		pids[i] = mockProvider(t)
+	}
+	for _, pid := range pids {
+		if _, _, err := getClient(int(pid)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := s3Clients.lru.Len(); n != defaultClientCacheSize {
+		t.Fatalf("expected %d cached clients, got %d", defaultClientCacheSize, n)
+	}
+	for _, pid := range pids[:5] {
+		if s3Clients.lru.Contains(pid) {
+			t.Fatalf("provider %d should have been evicted", pid)
+		}
+	}
+	if n := atomic.LoadInt32(&closed); n != 5 {
+		t.Fatalf("expected 5 evicted clients to be closed, got %d", n)
+	}
+	// a cached client is reused
+	a, _, _ := getClient(int(pids[54]))
+	b, _, _ := getClient(int(pids[54]))
+	if a != b {
+		t.Fatal("expected the cached client to be reused")
+	}
+
+	cookie := loginAs(t, ts, "cache-admin@example.com", "admin")
+	req, _ := http.NewRequest("GET", ts.URL+"/api/v1/admin/cache/s3-clients", nil)
+	req.AddCookie(cookie)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Data struct {
+			Size  int               `json:"size"`
+			Cap   int               `json:"cap"`
+			Items []clientCacheItem `json:"items"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil || resp.StatusCode != 200 {
+		t.Fatalf("status %d: %v", resp.StatusCode, err)
+	}
+	if out.Data.Size != 50 || out.Data.Cap != 50 || len(out.Data.Items) != 50 || out.Data.Items[0].ProviderID != pids[54] {
+		t.Fatalf("unexpected cache listing: size=%d cap=%d items=%d first=%+v", out.Data.Size, out.Data.Cap, len(out.Data.Items), out.Data.Items[0])
+	}
+}
+
+func TestProviderConnectivityCheckOnSave(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	m := useMockS3(t)
+	prev := providerValidateOnSave
+	providerValidateOnSave = true
+	defer func() { providerValidateOnSave = prev }()
+	reachable := false
+	m.OnListBuckets = func() ([]minio.BucketInfo, error) {
+		if !reachable {
+			return nil, minio.ErrorResponse{StatusCode: 403, Code: "InvalidAccessKeyId", Message: "The access key does not exist"}
+		}
+		return nil, nil
+	}
+	cookie := loginAs(t, ts, "validate@example.com", "editor")
+	send := func(method, path string, body map[string]any) (int, map[string]any) {
+		b, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, ts.URL+path, bytes.NewReader(b))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out map[string]any
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+	prov := map[string]any{"name": "checked", "type": "minio", "endpoint": "minio.local:9000", "accessKey": "bad", "secretKey": "bad"}
+
+	code, out := send("POST", "/api/v1/providers", prov)
+	if code != 422 || out["error"] != "connectivity_check_failed" || out["detail"] == "" {
+		t.Fatalf("expected 422 connectivity_check_failed, got %d %v", code, out)
+	}
+	var n int64
+	db.DB.Model(&models.Provider{}).Where("name = ?", "checked").Count(&n)
+	if n != 0 {
+		t.Fatal("a provider failing the check must not be saved")
+	}
+	if code, _ := send("POST", "/api/v1/providers?skipValidation=true", prov); code != 201 {
+		t.Fatalf("skipValidation: expected 201, got %d", code)
+	}
+	reachable = true
+	prov["name"] = "checked-ok"
+	code, out = send("POST", "/api/v1/providers", prov)
+	if code != 201 {
+		t.Fatalf("reachable provider: expected 201, got %d %v", code, out)
+	}
+	id := int(out["id"].(float64))
+
+	reachable = false
+	path := fmt.Sprintf("/api/v1/providers/%d", id)
+	if code, _ := send("PUT", path, map[string]any{"name": "renamed"}); code != 200 {
+		t.Fatalf("update without connection changes must not be checked, got %d", code)
+	}
+	if code, _ := send("PUT", path, map[string]any{"secretKey": "rotated"}); code != 422 {
+		t.Fatalf("credential change failing the check: expected 422, got %d", code)
+	}
+	var p models.Provider
+	db.DB.First(&p, id)
+	if p.SecretKey != "bad" || p.Name != "renamed" {
+		t.Fatalf("rejected update was saved: %+v", p)
+	}
+	var outcomes []string
+	var rows []models.AuditLog
+	db.DB.Where("action = ?", "provider.validation").Order("id").Find(&rows)
+	for _, e := range rows {
+		var d struct{ Outcome string }
+		json.Unmarshal([]byte(e.Detail), &d)
+		outcomes = append(outcomes, d.Outcome)
+	}
+	if want := []string{"failed", "skipped", "ok", "failed"}; !slices.Equal(outcomes, want) {
+		t.Fatalf("audited validation outcomes %v, want %v", outcomes, want)
+	}
+}
+
+// blockingReader serves one chunk and then blocks until unblock is closed, like a stalled download.
+type blockingReader struct {
+	sent    bool
+	unblock chan struct{}
+}
+
+func (b *blockingReader) Read(p []byte) (int, error) {
+	if !b.sent {
+		b.sent = true
+		return copy(p, "first chunk"), nil
+	}
+	<-b.unblock
+	return 0, context.Canceled
+}
+
+func TestPipeCopy(t *testing.T) {
+	prev := copyPipeBuffer
+	copyPipeBuffer = 1024
+	defer func() { copyPipeBuffer = prev }()
+
+	t.Run("streams everything", func(t *testing.T) {
+		src := bytes.Repeat([]byte("0123456789"), 1000)
+		var got bytes.Buffer
+		var chunks, largest int
+		n, err := pipeCopy(bytes.NewReader(src), nil, func(c int) { chunks++; largest = max(largest, c) }, func(body io.Reader) error {
+			_, err := io.Copy(&got, body)
+			return err
+		})
+		if err != nil || n != int64(len(src)) || !bytes.Equal(got.Bytes(), src) {
+			t.Fatalf("copied %d bytes (%v), destination has %d", n, err, got.Len())
+		}
+		if largest > 1024 || chunks < 10 {
+			t.Fatalf("expected chunks of at most the pipe buffer, got %d chunks, largest %d", chunks, largest)
+		}
+	})
+
+	t.Run("source error fails the upload", func(t *testing.T) {
+		srcErr := fmt.Errorf("connection reset")
+		var uploadErr error
+		_, err := pipeCopy(io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(srcErr)), nil, nil, func(body io.Reader) error {
+			_, uploadErr = io.ReadAll(body)
+			return fmt.Errorf("upload: %w", uploadErr)
+		})
+		if uploadErr != srcErr || err != srcErr {
+			t.Fatalf("expected the source error to reach the upload and the caller, got %v / %v", uploadErr, err)
+		}
+	})
+
+	t.Run("upload error aborts the download", func(t *testing.T) {
+		src := &blockingReader{unblock: make(chan struct{})}
+		aborted := false
+		_, err := pipeCopy(src, func() { aborted = true; close(src.unblock) }, nil, func(body io.Reader) error {
+			buf := make([]byte, 4)
+			body.Read(buf)
+			return fmt.Errorf("destination refused")
+		})
+		if err == nil || err.Error() != "destination refused" || !aborted {
+			t.Fatalf("expected the upload error after aborting the download, got %v (aborted=%v)", err, aborted)
+		}
+	})
+
+	t.Run("upload stopping early is an error", func(t *testing.T) {
+		_, err := pipeCopy(strings.NewReader(strings.Repeat("x", 4096)), nil, nil, func(body io.Reader) error {
+			body.Read(make([]byte, 10))
+			return nil
+		})
+		if err != errUploadShort {
+			t.Fatalf("expected errUploadShort, got %v", err)
+		}
+	})
+}
+
+// BenchmarkPipeCopy measures pipe throughput for a 100 MB in-memory object.
+func BenchmarkPipeCopy(b *testing.B) {
+	src := bytes.Repeat([]byte{'x'}, 100<<20)
+	b.SetBytes(int64(len(src)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := pipeCopy(bytes.NewReader(src), nil, nil, func(body io.Reader) error {
+			_, err := io.Copy(io.Discard, body)
+			return err
+		}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestReindexBucket(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	store := newMemS3(t)
+	store.buckets["docs"] = map[string][]byte{"a.txt": []byte("12345"), "b.txt": []byte("123")}
+	pid := mockProvider(t)
+	cookie := loginAs(t, ts, "index@example.com", "viewer")
+	type status struct {
+		IndexedObjects    int64      `json:"indexedObjects"`
+		LastIndexedAt     *time.Time `json:"lastIndexedAt"`
+		StaleSinceSeconds *int64     `json:"staleSinceSeconds"`
+		Indexing          bool       `json:"indexing"`
+	}
+	get := func() (int, status) {
+		t.Helper()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("%s/api/v1/providers/%d/buckets/docs/index/status", ts.URL, pid), nil)
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out struct {
+			Data status `json:"data"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, out.Data
+	}
+	if code, s := get(); code != 200 || s.IndexedObjects != 0 || s.LastIndexedAt != nil {
+		t.Fatalf("before indexing: %d %+v", code, s)
+	}
+	c, _, _ := getClient(int(pid))
+	if err := reindexBucket(context.Background(), c, pid, "docs"); err != nil {
+		t.Fatal(err)
+	}
+	if code, s := get(); code != 200 || s.IndexedObjects != 2 || s.LastIndexedAt == nil || s.StaleSinceSeconds == nil {
+		t.Fatalf("after indexing: %d %+v", code, s)
+	}
+
+	// changed outside Hermes: one added, one deleted, one resized
+	store.mu.Lock()
+	store.buckets["docs"]["c.txt"] = []byte("1")
+	delete(store.buckets["docs"], "a.txt")
+	store.buckets["docs"]["b.txt"] = []byte("123456")
+	store.mu.Unlock()
+	if err := reindexBucket(context.Background(), c, pid, "docs"); err != nil {
+		t.Fatal(err)
+	}
+	var rows []models.ObjectIndex
+	db.DB.Where("provider_id = ? AND bucket = ?", pid, "docs").Order("key").Find(&rows)
+	if len(rows) != 2 || rows[0].Key != "b.txt" || rows[0].Size != 6 || rows[1].Key != "c.txt" {
+		t.Fatalf("index not reconciled: %+v", rows)
+	}
+
+	indexingBuckets.Store(indexingKey(pid, "docs"), struct{}{})
+	defer indexingBuckets.Delete(indexingKey(pid, "docs"))
+	if code, s := get(); code != 202 || !s.Indexing {
+		t.Fatalf("while indexing: %d %+v", code, s)
+	}
+}
+
+func TestBatchUpload(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	store := newMemS3(t)
+	store.buckets["data"] = map[string][]byte{}
+	pid := mockProvider(t)
+	cookie := loginAs(t, ts, "batch@example.com", "editor")
+	upload := func(build func(mw *multipart.Writer)) (int, []batchUploadResult) {
+		t.Helper()
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		build(mw)
+		mw.Close()
+		req, _ := http.NewRequest("POST", fmt.Sprintf("%s/api/v1/providers/%d/buckets/data/upload", ts.URL, pid), &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out []batchUploadResult
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+	file := func(mw *multipart.Writer, field, name, content string) {
+		fw, _ := mw.CreateFormFile(field, name)
+		fw.Write([]byte(content))
+	}
+
+	code, out := upload(func(mw *multipart.Writer) {
+		mw.WriteField("prefix", "set1/")
+		mw.WriteField("key0", "named/zero.csv")
+		file(mw, "file0", "ignored.csv", "a,b")
+		file(mw, "file1", "one.csv", "c,d,e")
+	})
+	want := []batchUploadResult{{Key: "named/zero.csv", Size: 3}, {Key: "set1/one.csv", Size: 5}}
+	if code != 200 || len(out) != 2 || out[0].Key != want[0].Key || out[0].Size != 3 || out[1].Key != want[1].Key || out[1].Size != 5 || out[0].Error != "" || out[1].Error != "" {
+		t.Fatalf("batch: %d %+v", code, out)
+	}
+	for _, k := range []string{"named/zero.csv", "set1/one.csv"} {
+		if _, ok := store.object("data", k); !ok {
+			t.Fatalf("%s not stored", k)
+		}
+	}
+
+	// repeated "file" parts are a batch too, and files past MAX_BATCH_FILES are refused
+	prev := maxBatchFiles
+	maxBatchFiles = 2
+	defer func() { maxBatchFiles = prev }()
+	code, out = upload(func(mw *multipart.Writer) {
+		for _, n := range []string{"a.txt", "b.txt", "c.txt"} {
+			file(mw, "file", n, n)
+		}
+	})
+	if code != 207 || len(out) != 3 || out[0].Error != "" || out[1].Error != "" || out[2].Error == "" {
+		t.Fatalf("file limit: %d %+v", code, out)
+	}
+	if _, ok := store.object("data", "c.txt"); ok {
+		t.Fatal("file past the batch limit was stored")
+	}
+}
+
+func TestBatchUploadPartialFailure(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	m := useMockS3(t)
+	var mu sync.Mutex
+	stored := map[string]string{}
+	m.OnUpload = func(bucket, key string, reader io.Reader, size int64, contentType string, sse encrypt.ServerSide) (minio.UploadInfo, error) {
+		b, _ := io.ReadAll(reader)
+		if key == "b.txt" {
+			return minio.UploadInfo{}, minio.ErrorResponse{StatusCode: 500, Code: "InternalError", Message: "disk failure"}
+		}
+		mu.Lock()
+		stored[key] = string(b)
+		mu.Unlock()
+		return minio.UploadInfo{Bucket: bucket, Key: key, Size: int64(len(b))}, nil
+	}
+	pid := mockProvider(t)
+	cookie := loginAs(t, ts, "batch-partial@example.com", "editor")
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, n := range []string{"a.txt", "b.txt", "c.txt"} {
+		fw, _ := mw.CreateFormFile("file", n)
+		fw.Write([]byte("data-" + n))
+	}
+	mw.Close()
+	req, _ := http.NewRequest("POST", fmt.Sprintf("%s/api/v1/providers/%d/buckets/data/upload", ts.URL, pid), &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.AddCookie(cookie)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out []batchUploadResult
+	json.NewDecoder(resp.Body).Decode(&out)
+	if resp.StatusCode != 207 || len(out) != 3 {
+		t.Fatalf("batch with a failing file: %d %+v", resp.StatusCode, out)
+	}
+	if out[0].Key != "a.txt" || out[0].Error != "" || out[0].Size != 10 || out[1].Key != "b.txt" || out[1].Error == "" || out[2].Key != "c.txt" || out[2].Error != "" {
+		t.Fatalf("results: %+v", out)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if stored["a.txt"] != "data-a.txt" || stored["c.txt"] != "data-c.txt" {
+		t.Fatalf("files around the failure not stored: %v", stored)
+	}
+}
+
+func TestProviderAccessToken(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	m := useMockS3(t)
+	m.OnListBuckets = func() ([]minio.BucketInfo, error) { return []minio.BucketInfo{{Name: "b1"}}, nil }
+	m.OnListObjects = func(bucket, prefix string) ([]minio.ObjectInfo, error) {
+		return []minio.ObjectInfo{{Key: "a.txt"}}, nil
+	}
+	pid := mockProvider(t)
+	other := mockProvider(t)
+	admin := loginAs(t, ts, "tokens-admin@example.com", "admin")
+	do := func(method, path, token string, body any) (int, []byte) {
+		var rd io.Reader
+		if body != nil {
+			b, _ := json.Marshal(body)
+			rd = bytes.NewReader(b)
+		}
+		req, _ := http.NewRequest(method, ts.URL+path, rd)
+		if token != "" {
+			req.Header.Set("X-Provider-Token", token)
+		} else {
+			req.AddCookie(admin)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, b
+	}
+
+	code, body := do("POST", fmt.Sprintf("/api/v1/providers/%d/access-token", pid), "", map[string]any{"ttlSeconds": 600, "permissions": []string{"list_buckets", "list_objects"}})
+	var out struct {
+		Data struct {
+			ID    uint   `json:"id"`
+			Token string `json:"token"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil || code != 201 || out.Data.Token == "" {
+		t.Fatalf("create: %d %s", code, body)
+	}
+	token := out.Data.Token
+	if code, _ := do("POST", fmt.Sprintf("/api/v1/providers/%d/access-token", pid), "", map[string]any{"permissions": []string{"upload"}}); code != 400 {
+		t.Fatalf("unknown permission: expected 400, got %d", code)
+	}
+
+	for _, c := range []struct {
+		path string
+		want int
+	}{
+		{fmt.Sprintf("/api/v1/providers/%d/buckets", pid), 200},
+		{fmt.Sprintf("/api/v1/providers/%d/buckets/b1/objects", pid), 200},
+		{fmt.Sprintf("/api/v1/providers/%d/buckets/b1/download?key=a.txt", pid), 403}, // no download permission
+		{fmt.Sprintf("/api/v1/providers/%d/buckets", other), 403},                     // another provider
+		{"/api/v1/providers", 403},                                                    // not a token route
+	} {
+		if code, body := do("GET", c.path, token, nil); code != c.want {
+			t.Fatalf("GET %s: expected %d, got %d %s", c.path, c.want, code, body)
+		}
+	}
+	if code, _ := do("GET", fmt.Sprintf("/api/v1/providers/%d/buckets", pid), token+"x", nil); code != 401 {
+		t.Fatalf("tampered token: expected 401, got %d", code)
+	}
+
+	if code, body := do("DELETE", fmt.Sprintf("/api/v1/providers/%d/access-token/%d", pid, out.Data.ID), "", nil); code != 204 {
+		t.Fatalf("revoke: %d %s", code, body)
+	}
+	if code, _ := do("GET", fmt.Sprintf("/api/v1/providers/%d/buckets", pid), token, nil); code != 401 {
+		t.Fatalf("revoked token: expected 401, got %d", code)
+	}
+}
+
+func TestBulkDelete(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	m := useMockS3(t)
+	var gotKeys []string
+	m.OnDeleteObjects = func(bucket string, keys []string) ([]s3.ObjectError, error) {
+		gotKeys = keys
+		return []s3.ObjectError{{Key: "b", Err: errors.New("Access Denied.")}}, nil
+	}
+	pid := mockProvider(t)
+	editor := loginAs(t, ts, "bulk-editor@example.com", "editor")
+	viewer := loginAs(t, ts, "bulk-viewer@example.com", "viewer")
+	del := func(body string, c *http.Cookie) (*http.Response, []byte) {
+		t.Helper()
+		req, _ := http.NewRequest("DELETE", fmt.Sprintf("%s/api/v1/providers/%d/buckets/data/objects/bulk", ts.URL, pid), strings.NewReader(body))
+		req.AddCookie(c)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp, b
+	}
+
+	resp, body := del(`{"keys":["a","b","c"]}`, editor)
+	var env struct {
+		Data struct {
+			Deleted int `json:"deleted"`
+			Failed  []struct {
+				Key   string `json:"key"`
+				Error string `json:"error"`
+			} `json:"failed"`
+		} `json:"data"`
+	}
+	json.Unmarshal(body, &env)
+	if resp.StatusCode != 200 || env.Data.Deleted != 2 || len(env.Data.Failed) != 1 || env.Data.Failed[0].Key != "b" || env.Data.Failed[0].Error != "Access Denied." {
+		t.Fatalf("partial failure: %d %s", resp.StatusCode, body)
+	}
+	if strings.Join(gotKeys, ",") != "a,b,c" {
+		t.Fatalf("keys sent to the provider: %v", gotKeys)
+	}
+
+	if resp, body := del(`{"keys":[]}`, editor); resp.StatusCode != 204 || len(body) != 0 {
+		t.Fatalf("empty list: %d %q", resp.StatusCode, body)
+	}
+	if resp, _ := del(`{"keys":["a"]}`, viewer); resp.StatusCode != 403 {
+		t.Fatalf("viewer: %d, want 403", resp.StatusCode)
+	}
+
+	prev := maxBulkDelete
+	maxBulkDelete = 2
+	defer func() { maxBulkDelete = prev }()
+	gotKeys = nil
+	if resp, _ := del(`{"keys":["a","b","c"]}`, editor); resp.StatusCode != 413 || gotKeys != nil {
+		t.Fatalf("over MAX_BULK_DELETE: %d, provider called with %v", resp.StatusCode, gotKeys)
+	}
+}
+
+func TestForceDeleteBucket(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	store := newMemS3(t)
+	store.buckets["full"] = map[string][]byte{}
+	for i := 0; i < 1500; i++ {
+		store.buckets["full"][fmt.Sprintf("obj-%04d", i)] = []byte("x")
+	}
+	store.buckets["big"] = map[string][]byte{"a": nil, "b": nil, "c": nil}
+	pid := mockProvider(t)
+	for _, row := range []any{
+		&models.Bucket{ProviderID: pid, Name: "full", MaxObjectSizeBytes: 10},
+		&models.BucketACL{ProviderID: pid, Bucket: "full", Subject: "role:editor", Permission: "write"},
+		&models.ObjectStat{ProviderID: pid, Bucket: "full", Key: "obj-0000", DownloadCount: 1},
+		&models.ObjectIndex{ProviderID: pid, Bucket: "full", Key: "obj-0000"},
+		&models.BucketSummary{ProviderID: pid, Bucket: "full", ObjectCount: 1500},
+	} {
+		if err := db.DB.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	cookie := loginAs(t, ts, "force-delete@example.com", "editor")
+	del := func(bucket, query string) (int, []map[string]any) {
+		t.Helper()
+		req, _ := http.NewRequest("DELETE", fmt.Sprintf("%s/api/v1/providers/%d/buckets/%s%s", ts.URL, pid, bucket, query), nil)
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var lines []map[string]any
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			var m map[string]any
+			if json.Unmarshal(sc.Bytes(), &m) == nil {
+				lines = append(lines, m)
+			}
+		}
+		return resp.StatusCode, lines
+	}
+
+	if code, _ := del("full", ""); code < 400 {
+		t.Fatalf("non-empty bucket without force: %d, want an error", code)
+	}
+	code, lines := del("full", "?force=true")
+	if code != 200 || len(lines) < 3 {
+		t.Fatalf("force delete: %d %v", code, lines)
+	}
+	if lines[0]["status"] != "starting" || lines[0]["total"] != float64(1500) {
+		t.Fatalf("first line: %v", lines[0])
+	}
+	if lines[1]["deleted"] != float64(1000) {
+		t.Fatalf("first batch: %v", lines[1])
+	}
+	if last := lines[len(lines)-1]; last["done"] != true || last["deleted"] != float64(1500) {
+		t.Fatalf("last line: %v", last)
+	}
+	if _, ok := store.buckets["full"]; ok {
+		t.Fatal("bucket still exists")
+	}
+	var n int64
+	db.DB.Model(&models.Bucket{}).Where("provider_id = ? AND name = ?", pid, "full").Count(&n)
+	if n != 0 {
+		t.Fatal("bucket record was not pruned")
+	}
+	for _, m := range []any{&models.BucketACL{}, &models.ObjectStat{}, &models.ObjectIndex{}, &models.BucketSummary{}} {
+		db.DB.Model(m).Where("provider_id = ? AND bucket = ?", pid, "full").Count(&n)
+		if n != 0 {
+			t.Fatalf("%T rows of the deleted bucket were not pruned", m)
+		}
+	}
+
+	// a versioned bucket holding only old versions and delete markers is emptied too
+	c, _ := clientFactory(models.Provider{})
+	m := c.(*s3.MockClient)
+	versions := []minio.ObjectInfo{
+		{Key: "doc", VersionID: "v2", IsDeleteMarker: true},
+		{Key: "doc", VersionID: "v1"},
+		{Key: "old", VersionID: "v1"},
+	}
+	store.buckets["versioned"] = map[string][]byte{}
+	m.OnVersioningEnabled = func(bucket string) (bool, error) { return bucket == "versioned", nil }
+	m.OnListBucketVersions = func(bucket string) ([]minio.ObjectInfo, error) { return slices.Clone(versions), nil }
+	m.OnDeleteObjectVersions = func(bucket string, batch []minio.ObjectInfo) ([]s3.ObjectError, error) {
+		for _, v := range batch {
+			versions = slices.DeleteFunc(versions, func(o minio.ObjectInfo) bool { return o.Key == v.Key && o.VersionID == v.VersionID })
+		}
+		return nil, nil
+	}
+	deleteBucket := m.OnDeleteBucket
+	m.OnDeleteBucket = func(name string) error {
+		if name == "versioned" && len(versions) > 0 {
+			return minio.ErrorResponse{StatusCode: 409, Code: "BucketNotEmpty"}
+		}
+		return deleteBucket(name)
+	}
+	code, lines = del("versioned", "?force=true")
+	if last := lines[len(lines)-1]; code != 200 || last["done"] != true || last["deleted"] != float64(3) {
+		t.Fatalf("versioned force delete: %d %v", code, lines)
+	}
+	if _, ok := store.buckets["versioned"]; ok || len(versions) != 0 {
+		t.Fatalf("versioned bucket left behind with %v", versions)
+	}
+
+	prev := bucketForceDeleteMax
+	bucketForceDeleteMax = 2
+	defer func() { bucketForceDeleteMax = prev }()
+	if code, _ := del("big", "?force=true"); code != 409 {
+		t.Fatalf("over BUCKET_FORCE_DELETE_MAX_OBJECTS: %d, want 409", code)
+	}
+	if len(store.buckets["big"]) != 3 {
+		t.Fatal("objects were deleted despite the safety limit")
+	}
+}
+
+func TestObjectTagsAndMeta(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	m := useMockS3(t)
+	stored := map[string]map[string]string{}
+	m.OnGetObjectTags = func(bucket, key string) (map[string]string, error) {
+		if key == "missing" {
+			return nil, minio.ErrorResponse{StatusCode: 404, Code: "NoSuchKey"}
+		}
+		return stored[bucket+"/"+key], nil
+	}
+	m.OnSetObjectTags = func(bucket, key string, tags map[string]string) error {
+		stored[bucket+"/"+key] = tags
+		return nil
+	}
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	m.OnStat = func(bucket, key string) (minio.ObjectInfo, error) {
+		return minio.ObjectInfo{Key: key, ContentType: "text/csv", Size: 42, ETag: "abc", LastModified: modified, UserMetadata: minio.StringMap{"Owner": "ops"}}, nil
+	}
+	pid := mockProvider(t)
+	editor := loginAs(t, ts, "tags-editor@example.com", "editor")
+	viewer := loginAs(t, ts, "tags-viewer@example.com", "viewer")
+	do := func(method, path, body string, c *http.Cookie) (int, []byte) {
+		t.Helper()
+		req, _ := http.NewRequest(method, fmt.Sprintf("%s/api/v1/providers/%d/buckets/data/objects/%s", ts.URL, pid, path), strings.NewReader(body))
+		req.AddCookie(c)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, b
+	}
+	tagsOf := func(b []byte) map[string]string {
+		var env struct {
+			Data objectTagsBody `json:"data"`
+		}
+		json.Unmarshal(b, &env)
+		return env.Data.Tags
+	}
+
+	if code, b := do("GET", "tags?key=r.csv", "", viewer); code != 200 || tagsOf(b) == nil || len(tagsOf(b)) != 0 {
+		t.Fatalf("untagged object: %d %s", code, b)
+	}
+	if code, _ := do("PUT", "tags?key=r.csv", `{"tags":{"team":"data"}}`, viewer); code != 403 {
+		t.Fatalf("viewer PUT: %d, want 403", code)
+	}
+	if code, b := do("PUT", "tags?key=r.csv", `{"tags":{"team":"data","env":"prod"}}`, editor); code != 200 {
+		t.Fatalf("PUT tags: %d %s", code, b)
+	}
+	code, b := do("GET", "tags?key=r.csv", "", viewer)
+	if got := tagsOf(b); code != 200 || len(got) != 2 || got["team"] != "data" || got["env"] != "prod" {
+		t.Fatalf("round trip: %d %s", code, b)
+	}
+	eleven := map[string]string{}
+	for i := 0; i < 11; i++ {
+		eleven[fmt.Sprintf("k%d", i)] = "v"
+	}
+	tooMany, _ := json.Marshal(objectTagsBody{Tags: eleven})
+	if code, _ := do("PUT", "tags?key=r.csv", string(tooMany), editor); code != 400 {
+		t.Fatalf("11 tags: %d, want 400", code)
+	}
+	if code, _ := do("GET", "tags?key=missing", "", viewer); code != 404 {
+		t.Fatalf("missing object: %d, want 404", code)
+	}
+	if code, _ := do("GET", "tags", "", viewer); code != 400 {
+		t.Fatalf("missing key: %d, want 400", code)
+	}
+
+	code, b = do("GET", "meta?key=r.csv", "", viewer)
+	var meta struct {
+		Data s3.ObjectMeta `json:"data"`
+	}
+	json.Unmarshal(b, &meta)
+	if code != 200 || meta.Data.ContentType != "text/csv" || meta.Data.Size != 42 || meta.Data.ETag != "abc" || !meta.Data.LastModified.Equal(modified) || meta.Data.UserMetadata["Owner"] != "ops" {
+		t.Fatalf("meta: %d %s", code, b)
+	}
+}
+
+func TestListObjectsPagination(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	store := newMemS3(t)
+	store.buckets["paged"] = map[string][]byte{}
+	for i := 0; i < 7; i++ {
+		store.buckets["paged"][fmt.Sprintf("k%02d", i)] = []byte("x")
+	}
+	pid := mockProvider(t)
+	cookie := loginAs(t, ts, "pager-objects@example.com", "viewer")
+	list := func(query string) (int, objectPage) {
+		t.Helper()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("%s/api/v1/providers/%d/buckets/paged/objects?%s", ts.URL, pid, query), nil)
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var env struct {
+			Data objectPage `json:"data"`
+		}
+		json.NewDecoder(resp.Body).Decode(&env)
+		return resp.StatusCode, env.Data
+	}
+
+	var keys []string
+	token := ""
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("pagination did not end")
+		}
+		code, page := list("maxKeys=3&continuationToken=" + token)
+		if code != 200 {
+			t.Fatalf("page %d: %d", pages, code)
+		}
+		for _, o := range page.Objects {
+			keys = append(keys, o.Key)
+		}
+		if !page.IsTruncated {
+			if page.NextToken != "" || len(page.Objects) != 1 {
+				t.Fatalf("last page: %+v", page)
+			}
+			break
+		}
+		if len(page.Objects) != 3 || page.NextToken == "" {
+			t.Fatalf("page %d: %+v", pages, page)
+		}
+		token = page.NextToken
+	}
+	if strings.Join(keys, ",") != "k00,k01,k02,k03,k04,k05,k06" {
+		t.Fatalf("keys across pages: %v", keys)
+	}
+
+	// a token past the last key yields an empty, final page
+	past := base64.RawURLEncoding.EncodeToString([]byte("k06"))
+	if code, page := list("continuationToken=" + past); code != 200 || len(page.Objects) != 0 || page.Objects == nil || page.IsTruncated {
+		t.Fatalf("beyond the last page: %d %+v", code, page)
+	}
+	if code, page := list(""); code != 200 || len(page.Objects) != 7 || page.IsTruncated {
+		t.Fatalf("default maxKeys: %d %+v", code, page)
+	}
+	if code, _ := list("continuationToken=%25%25"); code != 400 {
+		t.Fatalf("invalid token: %d, want 400", code)
+	}
+	if code, _ := list("maxKeys=0"); code != 400 {
+		t.Fatalf("maxKeys=0: %d, want 400", code)
+	}
+}
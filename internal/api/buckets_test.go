@@ -12,3 +12,17 @@ func TestContainsNoSuchBucket(t *testing.T){
 		if got := containsNoSuchBucket(c.in); got != c.want { t.Fatalf("%q => %v (want %v)", c.in, got, c.want) }
 	}
 }
+
+func TestValidSelectQuery(t *testing.T){
+	cases := []struct{ in string; want bool }{
+		{"SELECT * FROM S3Object WHERE age > 30", true},
+		{"  select s.name from S3Object s;", true},
+		{"DELETE FROM S3Object", false},
+		{"SELECT * FROM S3Object; DROP TABLE x", false},
+		{"SELECT", false},
+		{"", false},
+	}
+	for _, c := range cases {
+		if got := validSelectQuery(c.in); got != c.want { t.Fatalf("%q => %v (want %v)", c.in, got, c.want) }
+	}
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+	"github.com/arencloud/hermes/internal/s3"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// bucketSummaryMaxAge is how old a summary may get before bucketStats reports it as
+// stale; the nightly recompute keeps summaries younger than this.
+const bucketSummaryMaxAge = 25 * time.Hour
+
+// incrementalStats adjusts summaries as objects change through Hermes (set in Router).
+var incrementalStats bool
+
+// bucketStatsTimeout bounds the listing behind a stats request; a bucket too large to list
+// in time is answered with partial totals, which are not stored.
+var bucketStatsTimeout = 60 * time.Second
+
+func registerBucketSummary(r chi.Router) {
+	r.With(noAPITimeout).Get("/providers/{id}/buckets/{name}/stats", bucketStats)
+}
+
+// recomputeBucketSummary counts the bucket's objects from a full listing and stores the
+// result. If ctx ends first, the partial summary is returned unsaved with ctx's error.
+func recomputeBucketSummary(ctx context.Context, c s3.ClientInterface, pid uint, bucket string) (models.BucketSummary, error) {
+	count, total, last, err := c.BucketStats(ctx, bucket)
+	s := models.BucketSummary{ProviderID: pid, Bucket: bucket, ObjectCount: count, TotalBytes: total, LastModified: last, LastComputedAt: time.Now().UTC()}
+	if err != nil {
+		return s, err
+	}
+	return s, db.DB.Save(&s).Error
+}
+
+// recomputeBucketSummaries refreshes the summary of every known bucket (nightly job).
+func recomputeBucketSummaries() error {
+	var buckets []models.Bucket
+	if err := db.DB.Find(&buckets).Error; err != nil {
+		return err
+	}
+	var errs []error
+	for _, b := range buckets {
+		c, _, err := getClient(int(b.ProviderID))
+		if err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+			_, err = recomputeBucketSummary(ctx, c, b.ProviderID, b.Name)
+			cancel()
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("provider %d bucket %s: %w", b.ProviderID, b.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// adjustBucketSummary applies an object change made through Hermes to the bucket's cached
+// summary when INCREMENTAL_STATS is on. Buckets without a summary are left alone (their
+// first stats request computes one), and overwriting a key counts as a new object until
+// the next recompute.
+func adjustBucketSummary(pid uint, bucket string, objects, bytes int64) {
+	if !incrementalStats {
+		return
+	}
+	changes := map[string]any{
+		"object_count": gorm.Expr("object_count + ?", objects),
+		"total_bytes":  gorm.Expr("total_bytes + ?", bytes),
+	}
+	if objects > 0 {
+		changes["last_modified"] = time.Now().UTC()
+	}
+	err := db.DB.Model(&models.BucketSummary{}).Where("provider_id = ? AND bucket = ?", pid, bucket).Updates(changes).Error
+	if err != nil {
+		apiLogger.Error("bucket summary update failed", "component", "bucket.stats", "provider", pid, "bucket", bucket, "error", err)
+	}
+}
+
+// bucketStats returns the bucket's cached object count, size and newest modification time,
+// computing them on first use or with refresh=true (nocache=true is accepted too). stale is
+// true once the summary is older than bucketSummaryMaxAge; partial is true when the listing
+// did not finish within bucketStatsTimeout, and such totals are not cached.
+func bucketStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	pid, bucket, ok := aclParams(w, r)
+	if !ok || !enforceACL(w, r, int(pid), bucket, aclRead) {
+		return
+	}
+	var s models.BucketSummary
+	err := readDB(r).First(&s, "provider_id = ? AND bucket = ?", pid, bucket).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		respondError(w, r, 500, err.Error())
+		return
+	}
+	partial := false
+	q := r.URL.Query()
+	if err != nil || q.Get("refresh") == "true" || q.Get("nocache") == "true" {
+		c, _, err := getClient(int(pid))
+		if err != nil {
+			respondError(w, r, 404, "provider not found")
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), bucketStatsTimeout)
+		s, err = recomputeBucketSummary(ctx, c, pid, bucket)
+		cancel()
+		partial = errors.Is(err, context.DeadlineExceeded) && r.Context().Err() == nil
+		if err != nil && !partial {
+			if containsNoSuchBucket(err.Error()) {
+				respondError(w, r, 404, "bucket not found")
+				return
+			}
+			code, msg := s3Failure(r, err)
+			respondError(w, r, code, msg, storageErrCode(code, msg))
+			return
+		}
+		addEvent(r, "bucket.stats.recompute", map[string]any{"bucket": bucket, "objects": s.ObjectCount, "partial": partial})
+	}
+	Respond(w, r, 200, map[string]any{
+		"providerId":     s.ProviderID,
+		"bucket":         s.Bucket,
+		"objectCount":    s.ObjectCount,
+		"totalBytes":     s.TotalBytes,
+		"lastModified":   s.LastModified,
+		"lastComputedAt": s.LastComputedAt,
+		"stale":          time.Since(s.LastComputedAt) > bucketSummaryMaxAge,
+		"partial":        partial,
+	})
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/arencloud/hermes/internal/models"
+	"github.com/arencloud/hermes/internal/s3"
+	"github.com/arencloud/hermes/internal/secrets"
+	"github.com/go-chi/chi/v5"
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// defaultClientCacheSize is the S3_CLIENT_CACHE_SIZE default.
+const defaultClientCacheSize = 50
+
+// s3Clients keeps one client per provider so its connection pool is reused across
+// requests (replaced in Router with S3_CLIENT_CACHE_SIZE entries).
+var s3Clients = newClientCache(defaultClientCacheSize)
+
+type cachedClient struct {
+	client    s3.ClientInterface
+	updatedAt time.Time // the provider's UpdatedAt when built; an edited provider gets a new client
+	built     time.Time
+	lastUsed  time.Time
+}
+
+// clientCache is an LRU of provider clients. Evicted or replaced clients have their idle
+// connections closed.
+type clientCache struct {
+	mu   sync.Mutex // guards lastUsed and the lookup-then-add in get
+	lru  *lru.Cache[uint, *cachedClient]
+	size int
+}
+
+func newClientCache(size int) *clientCache {
+	if size <= 0 {
+		size = defaultClientCacheSize
+	}
+	c, _ := lru.NewWithEvict(size, func(_ uint, e *cachedClient) { closeClient(e.client) })
+	return &clientCache{lru: c, size: size}
+}
+
+func closeClient(c s3.ClientInterface) {
+	if cl, ok := c.(interface{ CloseIdleConnections() }); ok {
+		cl.CloseIdleConnections()
+	}
+}
+
+// get returns the cached client for p, building it with clientFactory when missing or
+// stale. Clients of providers with a SecretRef are rebuilt after secrets.CacheTTL so a
+// rotated secret is picked up.
+func (cc *clientCache) get(p models.Provider) (s3.ClientInterface, error) {
+	now := time.Now()
+	cc.mu.Lock()
+	if e, ok := cc.lru.Get(p.ID); ok && e.updatedAt.Equal(p.UpdatedAt) && (p.SecretRef == "" || now.Sub(e.built) < secrets.CacheTTL) {
+		e.lastUsed = now
+		cc.mu.Unlock()
+		return e.client, nil
+	}
+	cc.mu.Unlock()
+
+	c, err := clientFactory(p)
+	if err != nil {
+		return nil, err
+	}
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	// Add does not call the eviction callback for a replaced entry
+	if old, ok := cc.lru.Peek(p.ID); ok && old.client != c {
+		closeClient(old.client)
+	}
+	cc.lru.Add(p.ID, &cachedClient{client: c, updatedAt: p.UpdatedAt, built: now, lastUsed: now})
+	return c, nil
+}
+
+// remove drops a deleted provider's client.
+func (cc *clientCache) remove(pid uint) { cc.lru.Remove(pid) }
+
+// purge drops every client, e.g. when clientFactory is swapped in tests.
+func (cc *clientCache) purge() { cc.lru.Purge() }
+
+type clientCacheItem struct {
+	ProviderID uint      `json:"providerId"`
+	LastUsed   time.Time `json:"lastUsed"`
+}
+
+func (cc *clientCache) items() []clientCacheItem {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	var out []clientCacheItem
+	for _, pid := range cc.lru.Keys() {
+		if e, ok := cc.lru.Peek(pid); ok {
+			out = append(out, clientCacheItem{ProviderID: pid, LastUsed: e.lastUsed})
+		}
+	}
+	// most recently used first
+	sort.SliceStable(out, func(i, j int) bool { return out[i].LastUsed.After(out[j].LastUsed) })
+	return out
+}
+
+func registerClientCache(r chi.Router) {
+	r.With(requireSuperAdmin).Get("/admin/cache/s3-clients", clientCacheStatus)
+	r.With(requireAdmin).Get("/admin/config/s3-transport", s3TransportConfig)
+}
+
+// clientCacheStatus lists the cached provider clients (admin).
+func clientCacheStatus(w http.ResponseWriter, r *http.Request) {
+	items := s3Clients.items()
+	Respond(w, r, 200, map[string]any{"size": len(items), "cap": s3Clients.size, "items": items})
+}
+
+// s3TransportConfig reports the HTTP transport settings of provider clients (admin).
+func s3TransportConfig(w http.ResponseWriter, r *http.Request) {
+	ts := s3.CurrentTransportSettings()
+	Respond(w, r, 200, map[string]any{
+		"idleConnTimeoutSec":     int64(ts.IdleConnTimeout / time.Second),
+		"maxIdleConns":           ts.MaxIdleConns,
+		"dialTimeoutSec":         int64(ts.DialTimeout / time.Second),
+		"tlsHandshakeTimeoutSec": int64(ts.TLSHandshakeTimeout / time.Second),
+	})
+}
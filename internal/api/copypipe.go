@@ -0,0 +1,79 @@
+package api
+
+import (
+	"errors"
+	"io"
+)
+
+// copyPipeBuffer is the chunk size moved from the source download to the destination
+// upload during a streamed copy (COPY_PIPE_BUFFER_BYTES, set in Router).
+var copyPipeBuffer = 32 * 1024
+
+var errUploadShort = errors.New("upload finished before the source was fully read")
+
+// pipeCopy streams src into upload through an io.Pipe. A pump goroutine reads src in
+// copyPipeBuffer-sized chunks and writes them to the pipe while upload consumes the
+// other end, so at most one chunk is held between the two providers.
+//
+// A source error closes the writer with that error so the upload fails cleanly. An
+// upload error closes the reader and calls abort, which must make a pending src.Read
+// return (typically by cancelling the download's context). Either way pipeCopy waits
+// for the pump before returning, and the error reported is the one that came first.
+// onChunk is called with the size of every chunk handed to the upload.
+func pipeCopy(src io.Reader, abort func(), onChunk func(int), upload func(io.Reader) error) (int64, error) {
+	pr, pw := io.Pipe()
+	var copied int64
+	var eof bool
+	srcErr := make(chan error, 1) // filled before the writer is closed with the error
+	pumped := make(chan struct{})
+	go func() {
+		defer close(pumped)
+		buf := make([]byte, copyPipeBuffer)
+		for {
+			n, rerr := src.Read(buf)
+			if n > 0 {
+				if _, err := pw.Write(buf[:n]); err != nil {
+					return // upload side closed
+				}
+				copied += int64(n)
+				if onChunk != nil {
+					onChunk(n)
+				}
+			}
+			if rerr == io.EOF {
+				eof = true
+				pw.Close()
+				return
+			}
+			if rerr != nil {
+				srcErr <- rerr
+				pw.CloseWithError(rerr)
+				return
+			}
+		}
+	}()
+	err := upload(pr)
+	if err == nil {
+		// a well-behaved upload reads to EOF; anything left means the object was cut short
+		pr.CloseWithError(errUploadShort)
+	} else {
+		pr.CloseWithError(err)
+	}
+	select {
+	case <-pumped:
+	default:
+		if abort != nil {
+			abort()
+		}
+		<-pumped
+	}
+	select {
+	case serr := <-srcErr:
+		return copied, serr
+	default:
+	}
+	if err == nil && !eof {
+		err = errUploadShort
+	}
+	return copied, err
+}
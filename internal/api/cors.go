@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/arencloud/hermes/internal/config"
+	"github.com/go-chi/cors"
+)
+
+// providerAdminRe matches the provider routes whose mutations fall under the admin CORS policy.
+var providerAdminRe = regexp.MustCompile(`^/api/v1/providers(/[^/]+(/ca-cert|/failover/switch)?)?/?$`)
+
+// corsMiddleware applies one of three CORS policies per request: CORS_ADMIN_ORIGINS for
+// user management, admin and provider-changing routes, CORS_OBS_ORIGINS for /obs/*, and
+// CORS_ORIGINS for everything else. The policy has to be chosen before routing, because
+// preflight requests carry no session and would otherwise be refused by requireAuth.
+func corsMiddleware(cfg *config.Config) func(http.Handler) http.Handler {
+	origins := corsOrigins(cfg.CORSOrigins, []string{"*"})
+	options := func(origins []string) cors.Options {
+		return cors.Options{AllowedOrigins: origins, AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}, AllowedHeaders: []string{"*"}}
+	}
+	adminOpts := options(corsOrigins(cfg.CORSAdminOrigins, origins))
+	adminOpts.AllowCredentials = true
+	adminOpts.MaxAge = 600
+	corsDefault := cors.Handler(options(origins))
+	corsAdmin := cors.Handler(adminOpts)
+	corsObs := cors.Handler(options(corsOrigins(cfg.CORSObsOrigins, origins)))
+	return func(next http.Handler) http.Handler {
+		def, admin, obs := corsDefault(next), corsAdmin(next), corsObs(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch corsRouteClass(r) {
+			case "admin":
+				admin.ServeHTTP(w, r)
+			case "obs":
+				obs.ServeHTTP(w, r)
+			default:
+				def.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// corsRouteClass names the CORS policy for r. Preflights are classified by the method
+// they ask about.
+func corsRouteClass(r *http.Request) string {
+	method := r.Method
+	if method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+		method = r.Header.Get("Access-Control-Request-Method")
+	}
+	p := r.URL.Path
+	if rest, ok := strings.CutPrefix(p, "/api/v2/"); ok { // same routes as v1
+		p = "/api/v1/" + rest
+	}
+	switch {
+	case p == "/api/v1/users" || strings.HasPrefix(p, "/api/v1/users/"),
+		strings.HasPrefix(p, "/api/v1/admin/"),
+		p == "/api/v1/auth/fed/config":
+		return "admin"
+	case method != http.MethodGet && method != http.MethodHead && providerAdminRe.MatchString(p):
+		return "admin"
+	case strings.HasPrefix(p, "/api/v1/obs/"):
+		return "obs"
+	}
+	return ""
+}
+
+// corsOrigins splits a comma-separated origin list, returning def when it is empty.
+func corsOrigins(list string, def []string) []string {
+	var out []string
+	for _, o := range strings.Split(list, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			out = append(out, o)
+		}
+	}
+	if len(out) == 0 {
+		return def
+	}
+	return out
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+	"golang.org/x/sync/errgroup"
+)
+
+// dashboardMaxAge is both the browser cache lifetime of /admin/dashboard and how long
+// the server reuses a computed dashboard, so re-fetches within it can be answered 304.
+const dashboardMaxAge = 10 * time.Second
+
+// dashboardRecentErrors is the number of error traces included in the dashboard.
+const dashboardRecentErrors = 20
+
+// dashboardProvider is a provider as shown on the dashboard, with its health row.
+type dashboardProvider struct {
+	models.Provider
+	Health models.ProviderHealth `json:"health"`
+}
+
+type dashboardEntry struct {
+	body    []byte
+	etag    string
+	expires time.Time
+}
+
+// dashboardCache holds the last dashboard per tenant scope.
+var dashboardCache = struct {
+	sync.Mutex
+	m map[tenantInfo]dashboardEntry
+}{m: map[tenantInfo]dashboardEntry{}}
+
+// adminDashboard returns the metrics, observability summary, recent errors, providers
+// with their health, and database stats in one response, gathered concurrently.
+func adminDashboard(w http.ResponseWriter, r *http.Request) {
+	ti, _ := r.Context().Value(tenantCtxKey{}).(tenantInfo)
+	now := time.Now()
+	dashboardCache.Lock()
+	e, ok := dashboardCache.m[ti]
+	dashboardCache.Unlock()
+	if !ok || now.After(e.expires) {
+		body, err := buildDashboard(r)
+		if err != nil {
+			respondError(w, r, 500, err.Error(), ErrCodeInternal)
+			return
+		}
+		sum := sha256.Sum256(body)
+		e = dashboardEntry{body: body, etag: `"` + hex.EncodeToString(sum[:16]) + `"`, expires: now.Add(dashboardMaxAge)}
+		dashboardCache.Lock()
+		dashboardCache.m[ti] = e
+		dashboardCache.Unlock()
+	}
+	w.Header().Set("Cache-Control", "private, max-age=10")
+	w.Header().Set("ETag", e.etag)
+	if etagMatch(r.Header.Get("If-None-Match"), e.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(e.body)
+}
+
+func buildDashboard(r *http.Request) ([]byte, error) {
+	var (
+		g         errgroup.Group
+		metrics   map[string]any
+		summary   map[string]any
+		errs      []map[string]any
+		providers []dashboardProvider
+		dbStats   map[string]any
+	)
+	g.Go(func() error {
+		metrics = metricsSnapshot()
+		return nil
+	})
+	g.Go(func() error {
+		summary = summaryFor(r, defaultSummaryWindow)
+		return nil
+	})
+	g.Go(func() (err error) {
+		errs, err = recentErrors(r, dashboardRecentErrors)
+		return err
+	})
+	g.Go(func() (err error) {
+		providers, err = dashboardProviders(r)
+		return err
+	})
+	g.Go(func() (err error) {
+		dbStats, err = databaseStats(r)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(map[string]any{
+		"metrics":       metrics,
+		"observability": summary,
+		"recentErrors":  errs,
+		"providers":     providers,
+		"dbStats":       dbStats,
+	})
+}
+
+// dashboardProviders lists the tenant's providers with their health rows. Secret keys
+// are left out.
+func dashboardProviders(r *http.Request) ([]dashboardProvider, error) {
+	var ps []models.Provider
+	if err := readDB(r).Scopes(tenantScope(r)).Order("id").Find(&ps).Error; err != nil {
+		return nil, err
+	}
+	var hs []models.ProviderHealth
+	if err := readDB(r).Find(&hs).Error; err != nil {
+		return nil, err
+	}
+	health := make(map[uint]models.ProviderHealth, len(hs))
+	for _, h := range hs {
+		health[h.ProviderID] = h
+	}
+	out := make([]dashboardProvider, 0, len(ps))
+	for _, p := range ps {
+		p.SecretKey = ""
+		h, ok := health[p.ID]
+		if !ok {
+			h.ProviderID = p.ID
+		}
+		out = append(out, dashboardProvider{Provider: p, Health: h})
+	}
+	return out, nil
+}
+
+// databaseStats reports the connection pool of the primary database and the size of
+// the trace and log tables.
+func databaseStats(r *http.Request) (map[string]any, error) {
+	out := map[string]any{}
+	if sqlDB, err := db.DB.DB(); err == nil {
+		s := sqlDB.Stats()
+		out["openConnections"] = s.OpenConnections
+		out["inUse"] = s.InUse
+		out["idle"] = s.Idle
+		out["waitCount"] = s.WaitCount
+		out["waitDurationMs"] = s.WaitDuration.Milliseconds()
+	}
+	var traceRows, logRows int64
+	if err := readDB(r).Model(&models.TraceRow{}).Scopes(tenantScope(r)).Count(&traceRows).Error; err != nil {
+		return nil, err
+	}
+	if err := readDB(r).Model(&models.LogEntry{}).Scopes(tenantScope(r)).Count(&logRows).Error; err != nil {
+		return nil, err
+	}
+	out["traceRows"] = traceRows
+	out["logRows"] = logRows
+	out["replica"] = db.ReadDB() != db.DB
+	return out, nil
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/arencloud/hermes/internal/config"
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+)
+
+func TestAdminDashboard(t *testing.T) {
+	ts, _ := setupTestServer(t, func(c *config.Config) { c.TraceMemoryOnly = true })
+	defer ts.Close()
+	dashboardCache.Lock()
+	clear(dashboardCache.m) // the cache outlives the per-test databases
+	dashboardCache.Unlock()
+	p := models.Provider{Name: "dash", Type: "minio", Endpoint: "minio.local:9000", SecretKey: "s3cret"}
+	db.DB.Create(&p)
+	db.DB.Create(&models.ProviderHealth{ProviderID: p.ID, FailoverCount: 2, ConsecutiveFailures: 1})
+	db.DB.Create(&models.TraceRow{ID: "dash-err", Method: "GET", Path: "/api/v1/providers/9", Status: 404, Started: time.Now()})
+
+	get := func(cookie *http.Cookie, etag string) (*http.Response, map[string]json.RawMessage) {
+		req, _ := http.NewRequest("GET", ts.URL+"/api/v1/admin/dashboard", nil)
+		req.AddCookie(cookie)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out map[string]json.RawMessage
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp, out
+	}
+	if resp, _ := get(loginAs(t, ts, "dash-viewer@example.com", "viewer"), ""); resp.StatusCode != 403 {
+		t.Fatalf("viewer: expected 403, got %d", resp.StatusCode)
+	}
+	editor := loginAs(t, ts, "dash-editor@example.com", "editor")
+	resp, out := get(editor, "")
+	keys := make([]string, 0, len(out))
+	for k := range out {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	if resp.StatusCode != 200 || !slices.Equal(keys, []string{"dbStats", "metrics", "observability", "providers", "recentErrors"}) {
+		t.Fatalf("dashboard %d keys %v", resp.StatusCode, keys)
+	}
+	var providers []struct {
+		ID        uint                  `json:"id"`
+		SecretKey string                `json:"secretKey"`
+		Health    models.ProviderHealth `json:"health"`
+	}
+	json.Unmarshal(out["providers"], &providers)
+	if len(providers) != 1 || providers[0].ID != p.ID || providers[0].SecretKey != "" || providers[0].Health.FailoverCount != 2 {
+		t.Fatalf("providers %s", out["providers"])
+	}
+	var errs []map[string]any
+	json.Unmarshal(out["recentErrors"], &errs)
+	if len(errs) != 1 || errs[0]["id"] != "dash-err" {
+		t.Fatalf("recentErrors %s", out["recentErrors"])
+	}
+	var dbStats map[string]any
+	json.Unmarshal(out["dbStats"], &dbStats)
+	if dbStats["traceRows"] != float64(1) {
+		t.Fatalf("dbStats %s", out["dbStats"])
+	}
+	etag := resp.Header.Get("ETag")
+	if resp.Header.Get("Cache-Control") != "private, max-age=10" || etag == "" {
+		t.Fatalf("cache headers %v", resp.Header)
+	}
+	if resp, _ := get(editor, etag); resp.StatusCode != 304 {
+		t.Fatalf("re-fetch with If-None-Match: expected 304, got %d", resp.StatusCode)
+	}
+}
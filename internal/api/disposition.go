@@ -0,0 +1,97 @@
+package api
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxFilenameBytes is the longest download filename offered, the usual filesystem limit.
+const maxFilenameBytes = 255
+
+// downloadFilename turns an object key into a filename a browser can save: the last path
+// segment, with non-printable characters replaced by "_" and, when longer than
+// maxFilenameBytes, the stem shortened so the extension survives.
+func downloadFilename(key string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == utf8.RuneError || !unicode.IsPrint(r) {
+			return '_'
+		}
+		return r
+	}, path.Base(key))
+	if len(name) <= maxFilenameBytes {
+		return name
+	}
+	ext := path.Ext(name)
+	if len(ext) >= maxFilenameBytes/2 {
+		ext = ""
+	}
+	stem := name[:maxFilenameBytes-len(ext)]
+	for !utf8.ValidString(stem) {
+		stem = stem[:len(stem)-1]
+	}
+	return stem + ext
+}
+
+// contentDisposition builds the header for serving a file called name (see
+// downloadFilename): a quoted ASCII filename for old clients plus, when the name is not
+// plain ASCII, the exact name as an RFC 6266 filename*. inline is honoured only for
+// types a browser renders itself.
+func contentDisposition(name, contentType string, inline bool) string {
+	disp := "attachment"
+	if inline && browserRenderable(contentType) {
+		disp = "inline"
+	}
+	ascii := strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII || r == '"' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, name)
+	h := disp + `; filename="` + ascii + `"`
+	if ascii != name {
+		h += "; filename*=UTF-8''" + encodeRFC5987(name)
+	}
+	return h
+}
+
+// browserRenderable reports whether a content type may be served inline. SVG is left out:
+// it is a document that can carry script, not just an image.
+func browserRenderable(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if mt == "image/svg+xml" {
+		return false
+	}
+	return mt == "text/html" || mt == "application/pdf" || strings.HasPrefix(mt, "image/")
+}
+
+// setUntrustedContentHeaders marks a response as user content served from the Hermes
+// origin: the browser must not sniff another type from it, and any script it holds runs
+// sandboxed in a unique origin, without the viewer's session.
+func setUntrustedContentHeaders(h http.Header) {
+	h.Set("Content-Security-Policy", "sandbox")
+	h.Set("X-Content-Type-Options", "nosniff")
+}
+
+// encodeRFC5987 percent-encodes s as an RFC 5987 ext-value, leaving only attr-chars as is.
+func encodeRFC5987(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&15])
+	}
+	return b.String()
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+	minio "github.com/minio/minio-go/v7"
+)
+
+func TestDownloadFilename(t *testing.T) {
+	cases := []struct{ key, want string }{
+		{"report.pdf", "report.pdf"},
+		{"a/b/c/report.pdf", "report.pdf"},
+		{"dir/ünïcödé 文件.txt", "ünïcödé 文件.txt"},
+		{"tab\there\x00.txt", "tab_here_.txt"},
+	}
+	for _, tc := range cases {
+		if got := downloadFilename(tc.key); got != tc.want {
+			t.Errorf("downloadFilename(%q) = %q, want %q", tc.key, got, tc.want)
+		}
+	}
+
+	long := downloadFilename("x/" + strings.Repeat("é", 200) + ".tar.gz")
+	if len(long) > maxFilenameBytes || !strings.HasSuffix(long, ".gz") || !utf8.ValidString(long) {
+		t.Fatalf("long key: %d bytes, %q", len(long), long)
+	}
+	noExt := downloadFilename(strings.Repeat("a", 300))
+	if len(noExt) != maxFilenameBytes {
+		t.Fatalf("long key without extension: %d bytes", len(noExt))
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+	cases := []struct {
+		name, contentType string
+		inline            bool
+		want              string
+	}{
+		{"report.pdf", "application/pdf", false, `attachment; filename="report.pdf"`},
+		{"report.pdf", "application/pdf", true, `inline; filename="report.pdf"`},
+		{"photo.png", "image/png", true, `inline; filename="photo.png"`},
+		{"page.html", "text/html; charset=utf-8", true, `inline; filename="page.html"`},
+		{"logo.svg", "image/svg+xml", true, `attachment; filename="logo.svg"`},
+		{"data.bin", "application/octet-stream", true, `attachment; filename="data.bin"`},
+		{`say "hi".txt`, "text/plain", false, `attachment; filename="say _hi_.txt"; filename*=UTF-8''say%20%22hi%22.txt`},
+		{"文件.txt", "text/plain", false, `attachment; filename="__.txt"; filename*=UTF-8''%E6%96%87%E4%BB%B6.txt`},
+	}
+	for _, tc := range cases {
+		if got := contentDisposition(tc.name, tc.contentType, tc.inline); got != tc.want {
+			t.Errorf("contentDisposition(%q, %q, %v) = %s, want %s", tc.name, tc.contentType, tc.inline, got, tc.want)
+		}
+	}
+}
+
+func TestDownloadHeaders(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	m := useMockS3(t)
+	m.OnDownload = func(bucket, key string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("%PDF-1.4")), nil
+	}
+	m.OnStat = func(bucket, key string) (minio.ObjectInfo, error) {
+		return minio.ObjectInfo{Key: key, ContentType: "application/pdf"}, nil
+	}
+	p := models.Provider{Name: "dl", Type: "minio", Endpoint: "mock:9000"}
+	if err := db.DB.Create(&p).Error; err != nil {
+		t.Fatal(err)
+	}
+	viewer := loginAs(t, ts, "dl-viewer@example.com", "viewer")
+	get := func(query string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("%s/api/v1/providers/%d/buckets/b/download?%s", ts.URL, p.ID, query), nil)
+		req.AddCookie(viewer)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := get("key=docs/2024/%E6%8A%A5%E5%91%8A.pdf")
+	if resp.StatusCode != 200 || resp.Header.Get("Content-Type") != "application/pdf" {
+		t.Fatalf("download: %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if cd := resp.Header.Get("Content-Disposition"); cd != `attachment; filename="__.pdf"; filename*=UTF-8''%E6%8A%A5%E5%91%8A.pdf` {
+		t.Fatalf("Content-Disposition: %s", cd)
+	}
+	inline := get("key=a.pdf&inline=true")
+	if cd := inline.Header.Get("Content-Disposition"); cd != `inline; filename="a.pdf"` {
+		t.Fatalf("inline Content-Disposition: %s", cd)
+	}
+	for _, h := range []http.Header{resp.Header, inline.Header} {
+		if h.Get("Content-Security-Policy") != "sandbox" || h.Get("X-Content-Type-Options") != "nosniff" {
+			t.Fatalf("download without sandbox headers: %v", h)
+		}
+	}
+	if resp := get("key=docs/"); resp.StatusCode != 400 {
+		t.Fatalf("folder key: %d", resp.StatusCode)
+	}
+}
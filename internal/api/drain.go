@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/arencloud/hermes/internal/s3"
+)
+
+// inflightUpload is an object being written to a provider on behalf of a request.
+type inflightUpload struct {
+	client s3.ClientInterface
+	bucket string
+	key    string
+	cancel context.CancelFunc
+}
+
+// uploads tracks in-flight uploads so a graceful shutdown can wait for them. Once
+// draining is set no new upload is admitted, so wg never goes back up from zero while
+// DrainUploads waits on it.
+var uploads = struct {
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	draining bool
+	active   map[*inflightUpload]struct{}
+}{active: map[*inflightUpload]struct{}{}}
+
+// trackUpload registers an upload of bucket/key through c. The upload must run with the
+// returned context and call done when it returns. ok is false when the server is
+// shutting down and the upload should not start.
+func trackUpload(ctx context.Context, c s3.ClientInterface, bucket, key string) (_ context.Context, done func(), ok bool) {
+	uploads.mu.Lock()
+	defer uploads.mu.Unlock()
+	if uploads.draining {
+		return ctx, func() {}, false
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	u := &inflightUpload{client: c, bucket: bucket, key: key, cancel: cancel}
+	uploads.active[u] = struct{}{}
+	uploads.wg.Add(1)
+	return ctx, func() {
+		uploads.mu.Lock()
+		delete(uploads.active, u)
+		uploads.mu.Unlock()
+		cancel()
+		uploads.wg.Done()
+	}, true
+}
+
+// DrainUploads stops admitting uploads and waits for the running ones until ctx ends.
+// Uploads still running then are cancelled and their multipart uploads aborted, so no
+// orphaned parts are left on the providers. It returns ctx.Err() if anything was aborted.
+func DrainUploads(ctx context.Context) error {
+	uploads.mu.Lock()
+	uploads.draining = true
+	uploads.mu.Unlock()
+	finished := make(chan struct{})
+	go func() {
+		uploads.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+	}
+	uploads.mu.Lock()
+	pending := make([]*inflightUpload, 0, len(uploads.active))
+	for u := range uploads.active {
+		pending = append(pending, u)
+	}
+	uploads.mu.Unlock()
+	for _, u := range pending {
+		u.cancel()
+		actx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := u.client.AbortMultipartUpload(actx, u.bucket, u.key)
+		cancel()
+		if err != nil {
+			apiLogger.Error("upload abort failed", "component", "shutdown", "bucket", u.bucket, "key", u.key, "error", err)
+			continue
+		}
+		apiLogger.Info("upload aborted", "component", "shutdown", "bucket", u.bucket, "key", u.key)
+	}
+	return ctx.Err()
+}
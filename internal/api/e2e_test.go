@@ -0,0 +1,298 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+	"github.com/arencloud/hermes/internal/s3"
+	minio "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+// memStore backs a MockClient with in-memory buckets so a whole workflow can run against it.
+type memStore struct {
+	mu      sync.Mutex
+	buckets map[string]map[string][]byte
+}
+
+func newMemS3(t *testing.T) *memStore {
+	s := &memStore{buckets: map[string]map[string][]byte{}}
+	m := useMockS3(t)
+	m.OnListBuckets = func() ([]minio.BucketInfo, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		var out []minio.BucketInfo
+		for name := range s.buckets {
+			out = append(out, minio.BucketInfo{Name: name})
+		}
+		return out, nil
+	}
+	m.OnCreateBucket = func(name, region string) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.buckets[name] = map[string][]byte{}
+		return nil
+	}
+	m.OnDeleteBucket = func(name string) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if len(s.buckets[name]) > 0 {
+			return minio.ErrorResponse{StatusCode: 409, Code: "BucketNotEmpty"}
+		}
+		delete(s.buckets, name)
+		return nil
+	}
+	m.OnUpload = func(bucket, key string, reader io.Reader, size int64, contentType string, sse encrypt.ServerSide) (minio.UploadInfo, error) {
+		b, err := io.ReadAll(reader)
+		if err != nil {
+			return minio.UploadInfo{}, err
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		objs, ok := s.buckets[bucket]
+		if !ok {
+			return minio.UploadInfo{}, minio.ErrorResponse{StatusCode: 404, Code: "NoSuchBucket"}
+		}
+		objs[key] = b
+		return minio.UploadInfo{Bucket: bucket, Key: key, Size: int64(len(b))}, nil
+	}
+	m.OnListObjects = func(bucket, prefix string) ([]minio.ObjectInfo, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		var out []minio.ObjectInfo
+		for k, v := range s.buckets[bucket] {
+			if strings.HasPrefix(k, prefix) {
+				out = append(out, minio.ObjectInfo{Key: k, Size: int64(len(v)), LastModified: time.Now()})
+			}
+		}
+		return out, nil
+	}
+	get := func(bucket, key string) ([]byte, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		b, ok := s.buckets[bucket][key]
+		if !ok {
+			return nil, minio.ErrorResponse{StatusCode: 404, Code: "NoSuchKey"}
+		}
+		return b, nil
+	}
+	m.OnDownload = func(bucket, key string) (io.ReadCloser, error) {
+		b, err := get(bucket, key)
+		return io.NopCloser(bytes.NewReader(b)), err
+	}
+	m.OnDownloadWithInfo = func(bucket, key string) (io.ReadCloser, int64, error) {
+		b, err := get(bucket, key)
+		return io.NopCloser(bytes.NewReader(b)), int64(len(b)), err
+	}
+	m.OnStat = func(bucket, key string) (minio.ObjectInfo, error) {
+		b, err := get(bucket, key)
+		return minio.ObjectInfo{Key: key, Size: int64(len(b))}, err
+	}
+	m.OnDeleteObject = func(bucket, key string) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.buckets[bucket], key)
+		return nil
+	}
+	m.OnCopyObject = func(srcBucket, srcKey, dstBucket, dstKey string, sse encrypt.ServerSide) error {
+		b, err := get(srcBucket, srcKey)
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.buckets[dstBucket] == nil {
+			return minio.ErrorResponse{StatusCode: 404, Code: "NoSuchBucket"}
+		}
+		s.buckets[dstBucket][dstKey] = b
+		return nil
+	}
+	m.OnVersioningEnabled = func(bucket string) (bool, error) { return false, nil }
+	m.OnDeleteObjects = func(bucket string, keys []string) ([]s3.ObjectError, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, k := range keys {
+			delete(s.buckets[bucket], k)
+		}
+		return nil, nil
+	}
+	return s
+}
+
+func (s *memStore) object(bucket, key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[bucket][key]
+	return b, ok
+}
+
+// TestFullProviderLifecycle walks the provider → bucket → object workflow through the HTTP
+// API and checks that every step leaves a persisted trace with its events.
+func TestFullProviderLifecycle(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	store := newMemS3(t)
+	cookie := loginAs(t, ts, "e2e@example.com", "admin")
+	do := func(method, path, contentType string, body io.Reader) (*http.Response, []byte) {
+		t.Helper()
+		req, _ := http.NewRequest(method, ts.URL+"/api/v1"+path, body)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		out, _ := io.ReadAll(resp.Body)
+		return resp, out
+	}
+	jsonBody := func(v any) io.Reader {
+		b, _ := json.Marshal(v)
+		return bytes.NewReader(b)
+	}
+	// each step's trace must carry these events (besides request.end)
+	type step struct {
+		name   string
+		trace  string
+		events []string
+	}
+	var steps []step
+	expect := func(name string, resp *http.Response, body []byte, status int, events ...string) {
+		t.Helper()
+		if resp.StatusCode != status {
+			t.Fatalf("%s: expected %d, got %d %s", name, status, resp.StatusCode, body)
+		}
+		steps = append(steps, step{name, resp.Header.Get("X-Trace-Id"), events})
+	}
+
+	resp, body := do("POST", "/providers", "application/json", jsonBody(map[string]any{"name": "e2e", "type": "minio", "endpoint": "mock:9000"}))
+	expect("create provider", resp, body, 201, "provider.create")
+	var prov struct {
+		ID   uint   `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(body, &prov); err != nil || prov.ID == 0 || prov.Name != "e2e" {
+		t.Fatalf("create provider: %s (%v)", body, err)
+	}
+	base := fmt.Sprintf("/providers/%d/buckets", prov.ID)
+
+	// listing buckets is the connection check: it reaches the provider
+	resp, body = do("GET", base, "", nil)
+	expect("test connection", resp, body, 200, "buckets.list")
+
+	resp, body = do("POST", base, "application/json", jsonBody(map[string]any{"name": "docs"}))
+	expect("create bucket", resp, body, 201, "bucket.create")
+	resp, body = do("POST", base, "application/json", jsonBody(map[string]any{"name": "archive"}))
+	expect("create second bucket", resp, body, 201, "bucket.create")
+
+	content := strings.Repeat("x", 1024)
+	form, ct := uploadForm(t, "a.txt", content)
+	resp, body = do("POST", base+"/docs/upload", ct, form)
+	expect("upload", resp, body, 200, "object.upload", "object.upload.done")
+	var info struct {
+		Key  string `json:"Key"`
+		Size int64  `json:"Size"`
+	}
+	if err := json.Unmarshal(body, &info); err != nil || info.Key != "a.txt" || info.Size != 1024 {
+		t.Fatalf("upload: %s (%v)", body, err)
+	}
+
+	resp, body = do("GET", base+"/docs/objects?envelope=false", "", nil)
+	expect("list objects", resp, body, 200, "objects.list")
+	var list struct {
+		Data       []minio.ObjectInfo `json:"data"`
+		Pagination struct {
+			Total int `json:"total"`
+		} `json:"pagination"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil || len(list.Data) != 1 || list.Data[0].Key != "a.txt" || list.Pagination.Total != 1 {
+		t.Fatalf("list objects: %s (%v)", body, err)
+	}
+
+	resp, body = do("GET", base+"/docs/download?key=a.txt", "", nil)
+	expect("download", resp, body, 200)
+	if string(body) != content {
+		t.Fatalf("download returned %d bytes, want the uploaded 1 KB", len(body))
+	}
+
+	resp, body = do("POST", base+"/docs/copy", "application/json", jsonBody(map[string]any{"srcKey": "a.txt", "dstBucket": "docs", "dstKey": "b.txt"}))
+	expect("copy", resp, body, 200, "object.copy.end")
+	if !strings.Contains(string(body), `"done":true`) {
+		t.Fatalf("copy did not finish: %s", body)
+	}
+	resp, body = do("POST", base+"/docs/move", "application/json", jsonBody(map[string]any{"srcKey": "b.txt", "dstBucket": "archive"}))
+	expect("move", resp, body, 200, "object.move.end")
+	if !strings.Contains(string(body), `"done":true`) {
+		t.Fatalf("move did not finish: %s", body)
+	}
+	if b, ok := store.object("archive", "b.txt"); !ok || string(b) != content {
+		t.Fatal("moved object missing from the destination bucket")
+	}
+	if _, ok := store.object("docs", "b.txt"); ok {
+		t.Fatal("moved object still in the source bucket")
+	}
+	var mv models.InProgressMove
+	if err := db.DB.Where("src_key = ?", "b.txt").First(&mv).Error; err != nil || mv.CopiedAt == nil || mv.DeletedAt == nil || mv.UserEmail == "" {
+		t.Fatalf("move journal not completed: %v %+v", err, mv)
+	}
+
+	resp, body = do("DELETE", base+"/docs/objects?key=a.txt", "", nil)
+	expect("delete object", resp, body, 204)
+	resp, body = do("DELETE", base+"/docs", "", nil)
+	expect("delete bucket", resp, body, 204)
+	if _, ok := store.object("docs", "a.txt"); ok {
+		t.Fatal("deleted object still stored")
+	}
+
+	// every step is in the recent traces and its persisted trace has the expected events
+	resp, body = do("GET", "/trace/recent", "", nil)
+	if resp.StatusCode != 200 {
+		t.Fatalf("trace/recent: %d %s", resp.StatusCode, body)
+	}
+	var recent struct {
+		Data []Trace `json:"data"`
+	}
+	if err := json.Unmarshal(body, &recent); err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, tr := range recent.Data {
+		seen[tr.ID] = true
+	}
+	for _, s := range steps {
+		if !seen[s.trace] {
+			t.Fatalf("%s: trace %q not in /trace/recent", s.name, s.trace)
+		}
+		resp, body := do("GET", "/trace/"+s.trace, "", nil)
+		var tr struct {
+			Data Trace `json:"data"`
+		}
+		if err := json.Unmarshal(body, &tr); err != nil || resp.StatusCode != 200 {
+			t.Fatalf("%s: trace %d %s", s.name, resp.StatusCode, body)
+		}
+		var names []string
+		for _, e := range tr.Data.Events {
+			names = append(names, e.Name)
+		}
+		if len(names) != len(s.events)+1 || !slices.Contains(names, "request.end") {
+			t.Fatalf("%s: expected %v plus request.end, got %v", s.name, s.events, names)
+		}
+		for _, want := range s.events {
+			if !slices.Contains(names, want) {
+				t.Fatalf("%s: event %s missing from %v", s.name, want, names)
+			}
+		}
+	}
+}
@@ -1,44 +1,97 @@
 package api
 
 import (
+	"crypto/subtle"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
-	"sync/atomic"
+	"strings"
 	"time"
 
 	"github.com/arencloud/hermes/internal/db"
 	"github.com/arencloud/hermes/internal/logging"
+	"github.com/arencloud/hermes/internal/metrics"
+	"github.com/arencloud/hermes/internal/middleware"
 	"github.com/arencloud/hermes/internal/models"
 
 	"github.com/go-chi/chi/v5"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type apiServer struct{ logger logging.Logger }
 
 var appStart = time.Now()
-var totalRequests uint64
-var total4xx uint64
-var total5xx uint64
-var bytesIn uint64
-var bytesOut uint64
-var totalDurationNs uint64
+
+// request counters, served as JSON by /obs/metrics and in the Prometheus format by /metrics.
+// totalRequests counts on arrival for the JSON snapshot; /metrics has the labelled
+// hermes_requests_total, counted once the response status is known.
+var (
+	totalRequests   = new(metrics.Counter)
+	requestsByRoute = metrics.RegisterCounterVec("hermes_requests_total", "HTTP requests served, by method, route pattern and status.", "method", "path", "status")
+	requestDuration = metrics.RegisterHistogram("hermes_request_duration_seconds", "Time spent serving HTTP requests.", nil)
+	total4xx        = metrics.Register("hermes_requests_4xx_total", "HTTP requests answered with a 4xx status.")
+	total5xx        = metrics.Register("hermes_requests_5xx_total", "HTTP requests answered with a 5xx status.")
+	bytesIn         = metrics.Register("hermes_bytes_in_total", "Request body bytes received.")
+	bytesOut        = metrics.Register("hermes_bytes_out_total", "Response body bytes sent.")
+	totalDurationNs = metrics.Register("hermes_request_duration_nanoseconds_total", "Time spent serving HTTP requests, in nanoseconds.")
+)
+
+// readDB returns the connection for read-only handler queries. Clients that need to
+// observe their own just-written data can pass ?preferPrimary=true to bypass the replica.
+func readDB(r *http.Request) *gorm.DB {
+	if r.URL.Query().Get("preferPrimary") == "true" {
+		return db.DB
+	}
+	return db.ReadDB()
+}
+
+// cacheKey keys middleware.Cache entries by endpoint prefix, tenant scope and request URI
+// (which also tells /api/v1 from /api/v2).
+func cacheKey(prefix string) func(*http.Request) string {
+	return func(r *http.Request) string {
+		ti, _ := r.Context().Value(tenantCtxKey{}).(tenantInfo)
+		return fmt.Sprintf("%s|%s|%t|%s", prefix, ti.id, ti.all, r.URL.RequestURI())
+	}
+}
+
+// metricsTokenAuth guards /metrics with "Authorization: Bearer <token>" when token is set.
+func metricsTokenAuth(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			respondError(w, r, http.StatusUnauthorized, "unauthorized", ErrCodeUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
 
 func metricsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(metricsSnapshot())
+}
+
+// metricsSnapshot returns the process and request counters served by /obs/metrics.
+func metricsSnapshot() map[string]any {
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
 	uptime := time.Since(appStart).Seconds()
-	tr := atomic.LoadUint64(&totalRequests)
-	dn := atomic.LoadUint64(&totalDurationNs)
+	tr := totalRequests.Value()
+	dn := totalDurationNs.Value()
 	avgMs := 0.0
 	if tr > 0 {
 		avgMs = float64(dn) / float64(tr) / 1e6
 	}
-	json.NewEncoder(w).Encode(map[string]any{
+	out := map[string]any{
 		"uptimeSec":     uptime,
 		"uptimeHuman":   (time.Duration(uptime) * time.Second).String(),
 		"startedAt":     appStart.Format(time.RFC3339),
@@ -48,23 +101,39 @@ func metricsHandler(w http.ResponseWriter, r *http.Request) {
 		"lastGCUnix":    m.LastGC,
 		"gcNum":         m.NumGC,
 		"totalRequests": tr,
-		"total4xx":      atomic.LoadUint64(&total4xx),
-		"total5xx":      atomic.LoadUint64(&total5xx),
-		"bytesIn":       atomic.LoadUint64(&bytesIn),
-		"bytesOut":      atomic.LoadUint64(&bytesOut),
+		"total4xx":      total4xx.Value(),
+		"total5xx":      total5xx.Value(),
+		"bytesIn":       bytesIn.Value(),
+		"bytesOut":      bytesOut.Value(),
 		"avgDurationMs": avgMs,
-	})
+	}
+	out["traceBufferSize"] = traces.capacity()
+	out["logBufferSize"] = logging.BufferSize()
+	if jobPool != nil {
+		out["jobs"] = jobPool.Stats()
+	}
+	out["uploadConcurrency"] = uploadLimiter.Stats()
+	return out
 }
 
 // errorsHandler returns recent traces with errors (status >= 400) and the last error event message.
 func errorsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	// fetch recent error traces from DB (status>=400)
-	var trs []models.TraceRow
-	if err := db.DB.Where("status >= ?", 400).Order("started desc").Limit(200).Find(&trs).Error; err != nil {
-		http.Error(w, err.Error(), 500)
+	out, err := recentErrors(r, 200)
+	if err != nil {
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
 		return
 	}
+	json.NewEncoder(w).Encode(out)
+}
+
+// recentErrors returns the newest limit error traces (status >= 400) of the request's
+// tenant, each with the message of its last error event.
+func recentErrors(r *http.Request, limit int) ([]map[string]any, error) {
+	var trs []models.TraceRow
+	if err := db.DB.Scopes(tenantScope(r)).Where("status >= ?", 400).Order("started desc").Limit(limit).Find(&trs).Error; err != nil {
+		return nil, err
+	}
 	out := make([]map[string]any, 0, len(trs))
 	for _, t := range trs {
 		// find last error event for this trace
@@ -89,24 +158,54 @@ func errorsHandler(w http.ResponseWriter, r *http.Request) {
 			"started":    t.Started,
 		})
 	}
-	json.NewEncoder(w).Encode(out)
+	return out, nil
 }
 
 // logsRecent returns recent structured logs; now sourced from DB to survive restarts.
+// ?q= searches the message (full-text where the DB supports it), ?level= filters by
+// level and ?from=/?to= (RFC3339) by time; ?offset= pages through the matches.
 func logsRecent(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	qs := r.URL.Query()
 	limit := 200
-	if v := r.URL.Query().Get("limit"); v != "" {
+	if v := qs.Get("limit"); v != "" {
 		if i, err := strconv.Atoi(v); err == nil && i > 0 {
 			limit = i
 		}
 	}
-	var rows []models.LogEntry
-	if err := db.DB.Order("time desc").Limit(limit).Find(&rows).Error; err != nil {
-		http.Error(w, err.Error(), 500)
+	offset := 0
+	if v := qs.Get("offset"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			offset = i
+		}
+	}
+	var from, to time.Time
+	if v := qs.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			respondError(w, r, 400, "invalid from", ErrCodeInvalidParameter)
+			return
+		}
+		from = t
+	}
+	if v := qs.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			respondError(w, r, 400, "invalid to", ErrCodeInvalidParameter)
+			return
+		}
+		to = t
+	}
+	rows, total, err := db.SearchLogsIn(readDB(r), qs.Get("q"), qs.Get("level"), from, to, limit, offset, tenantScope(r))
+	if err != nil {
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
 		return
 	}
-	// Decode fields JSON into maps to keep UI compatibility
+	RespondList(w, r, 200, logEntryMaps(rows), int(total), limit, offset)
+}
+
+// logEntryMaps decodes the fields JSON of each entry into a map to keep UI compatibility.
+func logEntryMaps(rows []models.LogEntry) []map[string]any {
 	out := make([]map[string]any, 0, len(rows))
 	for _, r := range rows {
 		var f map[string]any
@@ -115,7 +214,29 @@ func logsRecent(w http.ResponseWriter, r *http.Request) {
 		}
 		out = append(out, map[string]any{"time": r.Time, "level": r.Level, "msg": r.Msg, "fields": f})
 	}
-	json.NewEncoder(w).Encode(out)
+	return out
+}
+
+// logsByTrace returns the log entries written while handling one request, oldest first.
+func logsByTrace(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	limit, offset := 200, 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			limit = i
+		}
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			offset = i
+		}
+	}
+	rows, total, err := db.LogsForTrace(readDB(r), chi.URLParam(r, "traceId"), limit, offset, tenantScope(r))
+	if err != nil {
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
+		return
+	}
+	RespondList(w, r, 200, logEntryMaps(rows), int(total), limit, offset)
 }
 
 // logsDownload returns recent logs as NDJSON for easy download
@@ -128,9 +249,17 @@ func logsDownload(w http.ResponseWriter, r *http.Request) {
 			limit = i
 		}
 	}
+	visible := logVisible(r)
 	enc := json.NewEncoder(w)
-	for _, e := range logging.Recent(limit) {
-		_ = enc.Encode(e)
+	n := 0
+	for _, e := range logging.Recent(0) {
+		if n == limit {
+			break
+		}
+		if visible(e.Fields) {
+			_ = enc.Encode(e)
+			n++
+		}
 	}
 }
 
@@ -147,17 +276,46 @@ func logsSetLevel(w http.ResponseWriter, r *http.Request) {
 		Level string `json:"level"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-		http.Error(w, err.Error(), 400)
+		respondError(w, r, 400, err.Error(), ErrCodeInvalidRequest)
 		return
 	}
 	if in.Level == "" {
-		http.Error(w, "level required", 400)
+		respondError(w, r, 400, "level required", ErrCodeMissingField)
 		return
 	}
 	logging.SetLevel(in.Level)
 	json.NewEncoder(w).Encode(map[string]any{"ok": true, "level": logging.GetLevel()})
 }
 
+// logsGetLevels returns the global level and the per-component overrides
+func logsGetLevels(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"level": logging.GetLevel(), "components": logging.GetComponentLevels(), "known": logging.KnownComponents})
+}
+
+// logsSetLevels updates per-component levels at runtime; an empty level removes the override
+func logsSetLevels(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var in struct {
+		Components map[string]string `json:"components"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondError(w, r, 400, err.Error(), ErrCodeInvalidRequest)
+		return
+	}
+	for c, l := range in.Components {
+		if c == "" || (l != "" && !logging.ValidLevel(l)) {
+			respondError(w, r, 400, "invalid level for component "+c, ErrCodeInvalidParameter)
+			return
+		}
+	}
+	for c, l := range in.Components {
+		logging.SetComponentLevel(c, l)
+	}
+	addEvent(r, "logs.levels", map[string]any{"components": in.Components})
+	json.NewEncoder(w).Encode(map[string]any{"ok": true, "components": logging.GetComponentLevels()})
+}
+
 // logsStream streams logs via Server-Sent Events
 func logsStream(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/event-stream")
@@ -165,11 +323,12 @@ func logsStream(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Connection", "keep-alive")
 	fl, ok := w.(http.Flusher)
 	if !ok {
-		http.Error(w, "streaming unsupported", 500)
+		respondError(w, r, 500, "streaming unsupported", ErrCodeInternal)
 		return
 	}
 	// optional level filter
 	qLevel := r.URL.Query().Get("level")
+	visible := logVisible(r)
 	write := func(e any) {
 		b, _ := json.Marshal(e)
 		w.Write([]byte("data: "))
@@ -179,7 +338,7 @@ func logsStream(w http.ResponseWriter, r *http.Request) {
 	}
 	// send a small backlog first
 	for _, e := range logging.Recent(50) {
-		if qLevel == "" || e.Level == qLevel {
+		if (qLevel == "" || e.Level == qLevel) && visible(e.Fields) {
 			write(e)
 		}
 	}
@@ -194,29 +353,88 @@ func logsStream(w http.ResponseWriter, r *http.Request) {
 			if !ok {
 				return
 			}
-			if qLevel == "" || e.Level == qLevel {
+			if (qLevel == "" || e.Level == qLevel) && visible(e.Fields) {
 				write(e)
 			}
 		}
 	}
 }
 
-// obsSummary returns aggregated observability insights computed from in-memory traces.
+// summaryWindow is the time span /obs/summary covers and the bucket size it is charted in.
+type summaryWindow struct {
+	span, bucket time.Duration
+}
+
+var defaultSummaryWindow = summaryWindow{12 * time.Minute, time.Minute}
+
+// summaryWindows are the accepted ?window values of /obs/summary.
+var summaryWindows = map[string]summaryWindow{
+	"5m":  {5 * time.Minute, time.Minute},
+	"12m": defaultSummaryWindow,
+	"1h":  {time.Hour, 5 * time.Minute},
+	"24h": {24 * time.Hour, time.Hour},
+	"7d":  {7 * 24 * time.Hour, time.Hour},
+}
+
+// recentLatencyCount is how many of the newest request latencies a summary lists.
+const recentLatencyCount = 500
+
+// summaryMaxTraces caps the traces aggregated by one /obs/summary request.
+const summaryMaxTraces = 50000
+
+// obsSummary returns aggregated observability insights computed from the persisted traces
+// of the requested window (?window=5m|12m|1h|24h|7d, default 12m).
 func obsSummary(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	win := defaultSummaryWindow
+	if v := r.URL.Query().Get("window"); v != "" {
+		var ok bool
+		if win, ok = summaryWindows[v]; !ok {
+			respondError(w, r, 400, "window must be one of 5m, 12m, 1h, 24h, 7d", ErrCodeInvalidParameter)
+			return
+		}
+	}
+	json.NewEncoder(w).Encode(summaryFor(r, win))
+}
+
+// summaryFor aggregates the request tenant's persisted traces over win.
+func summaryFor(r *http.Request, win summaryWindow) map[string]any {
 	// Use DB-backed traces for aggregation so data survives restarts
 	var trs []models.TraceRow
-	_ = db.DB.Order("started desc").Limit(500).Find(&trs).Error
-	lat := make([]float64, 0, len(trs))
+	_ = readDB(r).Scopes(tenantScope(r)).Where("started > ?", time.Now().Add(-win.span)).Order("started desc").Limit(summaryMaxTraces + 1).Find(&trs).Error
+	truncated := len(trs) > summaryMaxTraces
+	if truncated {
+		trs = trs[:summaryMaxTraces]
+	}
+	lastError := func(id string) string {
+		var ev models.TraceEventRow
+		if err := readDB(r).Where("trace_id = ? AND name = ?", id, "error").Order("time desc").First(&ev).Error; err != nil || ev.Fields == "" {
+			return ""
+		}
+		var f map[string]any
+		_ = json.Unmarshal([]byte(ev.Fields), &f)
+		v, _ := f["message"].(string)
+		return v
+	}
+	out := summarizeTraces(trs, win, lastError)
+	out["truncated"] = truncated
+	return out
+}
+
+// summarizeTraces aggregates traces (newest first) into the obsSummary shape, counting
+// requests per win.bucket over win.span; lastError returns the last error message recorded
+// for a trace ID.
+func summarizeTraces(trs []models.TraceRow, win summaryWindow, lastError func(id string) string) map[string]any {
+	lat := make([]float64, 0, min(len(trs), recentLatencyCount))
 	statusCounts := map[string]int{"2xx": 0, "3xx": 0, "4xx": 0, "5xx": 0}
-	// per-minute buckets (last 12 minutes)
+	// per-bucket counts, aligned to bucket boundaries (minute, 5 minutes, hour)
 	now := time.Now().UTC()
 	buckets := map[int64]*struct {
 		Count  int `json:"count"`
 		Errors int `json:"errors"`
 	}{}
-	for i := 0; i < 12; i++ {
-		m := now.Add(-time.Duration(i) * time.Minute).Truncate(time.Minute).Unix()
+	for i := 0; i < int(win.span/win.bucket); i++ {
+		m := now.Add(-time.Duration(i) * win.bucket).Truncate(win.bucket).Unix()
 		buckets[m] = &struct {
 			Count  int `json:"count"`
 			Errors int `json:"errors"`
@@ -238,7 +456,9 @@ func obsSummary(w http.ResponseWriter, r *http.Request) {
 		if ms < 0 {
 			ms = 0
 		}
-		lat = append(lat, ms)
+		if len(lat) < recentLatencyCount {
+			lat = append(lat, ms)
+		}
 		// status bucket
 		s := t.Status
 		sKey := "2xx"
@@ -250,8 +470,8 @@ func obsSummary(w http.ResponseWriter, r *http.Request) {
 			sKey = "3xx"
 		}
 		statusCounts[sKey]++
-		// per-minute
-		m := t.Started.UTC().Truncate(time.Minute).Unix()
+		// per-bucket
+		m := t.Started.UTC().Truncate(win.bucket).Unix()
 		if b, ok := buckets[m]; ok {
 			b.Count++
 			if t.Status >= 400 {
@@ -271,19 +491,10 @@ func obsSummary(w http.ResponseWriter, r *http.Request) {
 		}
 		if t.Status >= 400 {
 			pa.Errs++
-			// find last error message from events table
-			var ev models.TraceEventRow
-			if err := db.DB.Where("trace_id = ? AND name = ?", t.ID, "error").Order("time desc").First(&ev).Error; err == nil {
-				if ev.Fields != "" {
-					var f map[string]any
-					_ = json.Unmarshal([]byte(ev.Fields), &f)
-					if v, ok := f["message"].(string); ok {
-						pa.LastMsg = v
-					}
-				}
-			}
-			pa.LastStatus = t.Status
+			// traces are newest first: the first error seen per path is the last one
 			if pa.SampleID == "" {
+				pa.LastMsg = lastError(t.ID)
+				pa.LastStatus = t.Status
 				pa.SampleID = t.ID
 			}
 		}
@@ -356,28 +567,33 @@ func obsSummary(w http.ResponseWriter, r *http.Request) {
 	if len(topErrors) > 8 {
 		topErrors = topErrors[:8]
 	}
-	// per-minute array sorted ascending by time
-	perMinute := make([]map[string]any, 0, len(buckets))
+	// per-bucket array sorted ascending by time
+	perBucket := make([]map[string]any, 0, len(buckets))
 	for ts, b := range buckets {
-		perMinute = append(perMinute, map[string]any{"ts": ts, "count": b.Count, "errors": b.Errors})
+		perBucket = append(perBucket, map[string]any{"ts": ts, "count": b.Count, "errors": b.Errors})
 	}
 	// simple ascending sort by ts
-	for i := 0; i < len(perMinute); i++ {
+	for i := 0; i < len(perBucket); i++ {
 		mi := i
-		for j := i + 1; j < len(perMinute); j++ {
-			if perMinute[j]["ts"].(int64) < perMinute[mi]["ts"].(int64) {
+		for j := i + 1; j < len(perBucket); j++ {
+			if perBucket[j]["ts"].(int64) < perBucket[mi]["ts"].(int64) {
 				mi = j
 			}
 		}
-		perMinute[i], perMinute[mi] = perMinute[mi], perMinute[i]
+		perBucket[i], perBucket[mi] = perBucket[mi], perBucket[i]
 	}
-	json.NewEncoder(w).Encode(map[string]any{
-		"recentLatencies": lat,
-		"statusCounts":    statusCounts,
-		"perMinute":       perMinute,
-		"topSlow":         topSlow,
-		"topErrors":       topErrors,
-	})
+	out := map[string]any{
+		"recentLatencies":      lat,
+		"statusCounts":         statusCounts,
+		"perBucket":            perBucket,
+		"bucketGranularitySec": int64(win.bucket / time.Second),
+		"topSlow":              topSlow,
+		"topErrors":            topErrors,
+	}
+	if win.bucket == time.Minute {
+		out["perMinute"] = perBucket // kept for clients predating perBucket
+	}
+	return out
 }
 
 func openapiHandler(w http.ResponseWriter, r *http.Request) {
@@ -388,12 +604,24 @@ func openapiHandler(w http.ResponseWriter, r *http.Request) {
 		"info":    map[string]any{"title": "Hermes API", "version": "0.1.0", "description": "S3-compatible storage manager API (Providers, Buckets, Objects, Users, Auth, Observability, Tracing, Logging)"},
 		"servers": []any{map[string]any{"url": "/api/v1"}},
 		"paths": map[string]any{
-			"/auth/login": map[string]any{"post": map[string]any{"summary": "Login", "requestBody": map[string]any{"required": true, "content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object", "properties": map[string]any{"email": map[string]any{"type": "string"}, "password": map[string]any{"type": "string"}}, "required": []any{"email", "password"}}}}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
-			"/auth/me":    map[string]any{"get": map[string]any{"summary": "Current user", "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/auth/login":     map[string]any{"post": map[string]any{"summary": "Login", "requestBody": map[string]any{"required": true, "content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object", "properties": map[string]any{"email": map[string]any{"type": "string"}, "password": map[string]any{"type": "string"}}, "required": []any{"email", "password"}}}}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/auth/me":        map[string]any{"get": map[string]any{"summary": "Current user", "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/auth/keys":      map[string]any{"get": map[string]any{"summary": "List your API keys (session only; API_KEYS_ENABLED)", "responses": map[string]any{"200": map[string]any{"description": "OK"}}}, "post": map[string]any{"summary": "Create an API key; the key is returned only once", "requestBody": map[string]any{"required": true, "content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object", "properties": map[string]any{"label": map[string]any{"type": "string"}, "expiresAt": map[string]any{"type": "string", "format": "date-time"}}, "required": []any{"label"}}}}}, "responses": map[string]any{"201": map[string]any{"description": "Created"}}}},
+			"/auth/keys/{id}": map[string]any{"delete": map[string]any{"summary": "Revoke an API key", "responses": map[string]any{"204": map[string]any{"description": "Revoked"}, "404": map[string]any{"description": "Not found"}}}},
 			"/providers": map[string]any{
-				"get":  map[string]any{"summary": "List providers", "responses": map[string]any{"200": map[string]any{"description": "OK"}}},
+				"get":  map[string]any{"summary": "List providers, only those tagged tag when given", "parameters": []any{map[string]any{"name": "tag", "in": "query", "schema": map[string]any{"type": "string"}}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}}},
 				"post": map[string]any{"summary": "Create provider", "requestBody": map[string]any{"required": true, "content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/Provider"}}}}, "responses": map[string]any{"201": map[string]any{"description": "Created"}}},
 			},
+			"/providers/{id}/failover":               map[string]any{"get": map[string]any{"summary": "Active endpoint and failover history of a provider", "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/providers/{id}/failover/switch":        map[string]any{"post": map[string]any{"summary": "Switch a failover-enabled provider to its other endpoint (admin)", "responses": map[string]any{"200": map[string]any{"description": "OK"}, "409": map[string]any{"description": "Failover not enabled"}}}},
+			"/providers/{id}/quota":                  map[string]any{"get": map[string]any{"summary": "Transfer quotas and usage in the current period (admin)", "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/providers/tags":                        map[string]any{"get": map[string]any{"summary": "Distinct tags of the tenant's providers", "responses": map[string]any{"200": map[string]any{"description": "Sorted tag values"}}}},
+			"/providers/{id}/access-token":           map[string]any{"post": map[string]any{"summary": "Issue a read-only token for this provider, sent as X-Provider-Token to list buckets, list objects or download (ttlSeconds default 3600, max 604800; admin)", "requestBody": map[string]any{"content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object", "required": []any{"permissions"}, "properties": map[string]any{"ttlSeconds": map[string]any{"type": "integer"}, "permissions": map[string]any{"type": "array", "items": map[string]any{"type": "string", "enum": []any{"list_buckets", "list_objects", "download"}}}}}}}}, "responses": map[string]any{"201": map[string]any{"description": "Token"}}}},
+			"/providers/{id}/access-token/{tokenId}": map[string]any{"delete": map[string]any{"summary": "Revoke a provider access token (admin)", "responses": map[string]any{"204": map[string]any{"description": "Revoked"}}}},
+			"/providers/{id}/ca-cert": map[string]any{
+				"get": map[string]any{"summary": "Whether a custom CA certificate is configured (the PEM is never returned)", "responses": map[string]any{"200": map[string]any{"description": "OK"}}},
+				"put": map[string]any{"summary": "Set or clear (empty caCertPem) the PEM CA used to verify the provider endpoint (admin)", "requestBody": map[string]any{"content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object", "properties": map[string]any{"caCertPem": map[string]any{"type": "string"}}}}}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}, "400": map[string]any{"description": "Invalid PEM"}}},
+			},
 			"/providers/{id}": map[string]any{
 				"parameters": []any{map[string]any{"name": "id", "in": "path", "required": true, "schema": map[string]any{"type": "integer"}}},
 				"get":        map[string]any{"summary": "Get provider", "responses": map[string]any{"200": map[string]any{"description": "OK"}}},
@@ -402,65 +630,184 @@ func openapiHandler(w http.ResponseWriter, r *http.Request) {
 			},
 			"/providers/{id}/buckets": map[string]any{
 				"get":  map[string]any{"summary": "List buckets", "responses": map[string]any{"200": map[string]any{"description": "OK"}}},
-				"post": map[string]any{"summary": "Create bucket", "requestBody": map[string]any{"required": true, "content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object", "properties": map[string]any{"name": map[string]any{"type": "string"}, "region": map[string]any{"type": "string"}}, "required": []any{"name"}}}}}, "responses": map[string]any{"201": map[string]any{"description": "Created"}}},
+				"post": map[string]any{"summary": "Create bucket", "requestBody": map[string]any{"required": true, "content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object", "properties": map[string]any{"name": map[string]any{"type": "string"}, "region": map[string]any{"type": "string"}, "templateId": map[string]any{"type": "integer"}}, "required": []any{"name"}}}}}, "responses": map[string]any{"201": map[string]any{"description": "Created (with {bucket, templateApplied, warnings} when templateId is set)"}}},
 			},
+			"/providers/{id}/buckets/{name}": map[string]any{"delete": map[string]any{"summary": "Delete bucket; force=true first deletes its objects, streaming NDJSON progress (editor/admin)", "parameters": []any{map[string]any{"name": "force", "in": "query", "schema": map[string]any{"type": "boolean"}}}, "responses": map[string]any{"200": map[string]any{"description": "NDJSON progress (force=true)"}, "204": map[string]any{"description": "Deleted"}, "409": map[string]any{"description": "More than BUCKET_FORCE_DELETE_MAX_OBJECTS objects"}}}},
 			"/providers/{id}/buckets/{name}/objects": map[string]any{
-				"get":    map[string]any{"summary": "List objects", "parameters": []any{map[string]any{"name": "prefix", "in": "query", "schema": map[string]any{"type": "string"}}, map[string]any{"name": "recursive", "in": "query", "schema": map[string]any{"type": "boolean"}}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}}},
-				"delete": map[string]any{"summary": "Delete object", "parameters": []any{map[string]any{"name": "key", "in": "query", "required": true, "schema": map[string]any{"type": "string"}}}, "responses": map[string]any{"204": map[string]any{"description": "No Content"}}},
+				"get":    map[string]any{"summary": "List objects a page at a time, ordered by key (envelope=false: every object in one array, the original response)", "parameters": []any{map[string]any{"name": "prefix", "in": "query", "schema": map[string]any{"type": "string"}}, map[string]any{"name": "recursive", "in": "query", "schema": map[string]any{"type": "boolean"}}, map[string]any{"name": "maxKeys", "in": "query", "schema": map[string]any{"type": "integer", "minimum": 1, "maximum": 10000, "default": 500}}, map[string]any{"name": "continuationToken", "in": "query", "schema": map[string]any{"type": "string"}, "description": "nextToken of the previous page"}, map[string]any{"name": "envelope", "in": "query", "schema": map[string]any{"type": "boolean", "default": true}}}, "responses": map[string]any{"200": map[string]any{"description": "{objects, nextToken, isTruncated}", "content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object", "properties": map[string]any{"objects": map[string]any{"type": "array", "items": map[string]any{"type": "object"}}, "nextToken": map[string]any{"type": "string"}, "isTruncated": map[string]any{"type": "boolean"}}}}}}, "400": map[string]any{"description": "Invalid maxKeys or continuationToken"}}},
+				"delete": map[string]any{"summary": "Delete object (moved to trash when TRASH_BUCKET is set)", "parameters": []any{map[string]any{"name": "key", "in": "query", "required": true, "schema": map[string]any{"type": "string"}}, map[string]any{"name": "permanent", "in": "query", "schema": map[string]any{"type": "boolean"}}}, "responses": map[string]any{"204": map[string]any{"description": "No Content"}}},
+			},
+			"/providers/{id}/buckets/{name}/objects/bulk": map[string]any{"delete": map[string]any{"summary": "Delete up to MAX_BULK_DELETE objects (moved to trash when TRASH_BUCKET is set, unless permanent=true)", "parameters": []any{map[string]any{"name": "permanent", "in": "query", "schema": map[string]any{"type": "boolean"}}}, "requestBody": map[string]any{"required": true, "content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object", "required": []any{"keys"}, "properties": map[string]any{"keys": map[string]any{"type": "array", "items": map[string]any{"type": "string"}}}}}}}, "responses": map[string]any{"200": map[string]any{"description": "{deleted, failed: [{key, error}]}"}, "204": map[string]any{"description": "No keys given"}, "413": map[string]any{"description": "More than MAX_BULK_DELETE keys"}}}},
+			"/providers/{id}/buckets/{name}/objects/meta": map[string]any{"get": map[string]any{"summary": "Object metadata: contentType, size, etag, lastModified, userMetadata", "parameters": []any{map[string]any{"name": "key", "in": "query", "required": true, "schema": map[string]any{"type": "string"}}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/providers/{id}/buckets/{name}/objects/tags": map[string]any{
+				"get": map[string]any{"summary": "Object tags", "parameters": []any{map[string]any{"name": "key", "in": "query", "required": true, "schema": map[string]any{"type": "string"}}}, "responses": map[string]any{"200": map[string]any{"description": "{tags}"}}},
+				"put": map[string]any{"summary": "Replace object tags (editor/admin; at most 10)", "parameters": []any{map[string]any{"name": "key", "in": "query", "required": true, "schema": map[string]any{"type": "string"}}}, "requestBody": map[string]any{"required": true, "content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object", "properties": map[string]any{"tags": map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}}}}}}}, "responses": map[string]any{"200": map[string]any{"description": "{tags}"}, "400": map[string]any{"description": "Invalid tags"}}},
+			},
+			"/providers/{id}/buckets/{name}/objects/select": map[string]any{"post": map[string]any{"summary": "Query object content with S3 Select (NDJSON stream)", "responses": map[string]any{"200": map[string]any{"description": "OK"}, "501": map[string]any{"description": "Provider does not support S3 Select"}}}},
+			"/providers/{id}/buckets/{name}/policy": map[string]any{
+				"get":    map[string]any{"summary": "Get bucket policy (raw IAM JSON)", "responses": map[string]any{"200": map[string]any{"description": "OK"}, "501": map[string]any{"description": "Not supported by provider"}}},
+				"put":    map[string]any{"summary": "Set bucket policy (raw IAM JSON body)", "responses": map[string]any{"200": map[string]any{"description": "OK"}, "400": map[string]any{"description": "Invalid JSON"}}},
+				"delete": map[string]any{"summary": "Remove bucket policy", "responses": map[string]any{"204": map[string]any{"description": "Deleted"}}},
+			},
+			"/providers/{id}/buckets/{name}/acl": map[string]any{
+				"get":  map[string]any{"summary": "List bucket ACL entries", "responses": map[string]any{"200": map[string]any{"description": "OK"}}},
+				"post": map[string]any{"summary": "Add bucket ACL entry (admin)", "requestBody": map[string]any{"required": true, "content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object", "properties": map[string]any{"subject": map[string]any{"type": "string"}, "permission": map[string]any{"type": "string", "enum": []any{"read", "write"}}}, "required": []any{"subject", "permission"}}}}}, "responses": map[string]any{"201": map[string]any{"description": "Created"}}},
+			},
+			"/providers/{id}/buckets/{name}/acl/{aclId}": map[string]any{
+				"put":    map[string]any{"summary": "Update bucket ACL entry (admin)", "responses": map[string]any{"200": map[string]any{"description": "OK"}}},
+				"delete": map[string]any{"summary": "Delete bucket ACL entry (admin)", "responses": map[string]any{"204": map[string]any{"description": "No Content"}}},
 			},
+			"/providers/{id}/buckets/{name}/objects/popular": map[string]any{"get": map[string]any{"summary": "Most downloaded objects", "parameters": []any{map[string]any{"name": "limit", "in": "query", "schema": map[string]any{"type": "integer"}}, map[string]any{"name": "sortBy", "in": "query", "schema": map[string]any{"type": "string", "enum": []any{"count", "bytes"}}}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/providers/{id}/buckets/{name}/objects/stats":   map[string]any{"get": map[string]any{"summary": "Download stats of an object", "parameters": []any{map[string]any{"name": "key", "in": "query", "required": true, "schema": map[string]any{"type": "string"}}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/me/preferences": map[string]any{
+				"get":   map[string]any{"summary": "Current user's UI preferences ({} if unset)", "responses": map[string]any{"200": map[string]any{"description": "OK"}}},
+				"put":   map[string]any{"summary": "Replace UI preferences (JSON object, < 16 KB)", "responses": map[string]any{"200": map[string]any{"description": "OK"}, "413": map[string]any{"description": "Too large"}}},
+				"patch": map[string]any{"summary": "Merge keys into UI preferences (null removes a key)", "responses": map[string]any{"200": map[string]any{"description": "OK"}}},
+			},
+			"/providers/{id}/buckets/{name}/objects/stale":           map[string]any{"get": map[string]any{"summary": "Objects not modified or downloaded for daysSinceAccess days (max 1000; format=csv for a report)", "parameters": []any{map[string]any{"name": "daysSinceAccess", "in": "query", "schema": map[string]any{"type": "integer"}}, map[string]any{"name": "prefix", "in": "query", "schema": map[string]any{"type": "string"}}, map[string]any{"name": "format", "in": "query", "schema": map[string]any{"type": "string", "enum": []any{"json", "csv"}}}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/providers/{id}/buckets/{name}/objects/stale/delete":    map[string]any{"post": map[string]any{"summary": "Delete stale objects (dry run unless dryRun=false)", "requestBody": map[string]any{"content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object", "properties": map[string]any{"daysSinceAccess": map[string]any{"type": "integer"}, "prefix": map[string]any{"type": "string"}, "dryRun": map[string]any{"type": "boolean"}}}}}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/providers/{id}/buckets/{name}/tiering-recommendations": map[string]any{"get": map[string]any{"summary": "Suggest STANDARD_IA for standard objects older than 90 days that were never downloaded; optional JSON body overrides prices, apply=true performs the moves", "parameters": []any{map[string]any{"name": "apply", "in": "query", "schema": map[string]any{"type": "boolean"}}, map[string]any{"name": "prefix", "in": "query", "schema": map[string]any{"type": "string"}}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/providers/{id}/buckets/{name}/batch-copy":              map[string]any{"post": map[string]any{"summary": "Copy up to 100 objects out of the bucket (maxParallel up to 8); per-operation results", "requestBody": map[string]any{"content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object", "properties": map[string]any{"operations": map[string]any{"type": "array", "items": map[string]any{"type": "object", "properties": map[string]any{"srcKey": map[string]any{"type": "string"}, "dstBucket": map[string]any{"type": "string"}, "dstKey": map[string]any{"type": "string"}, "dstProviderId": map[string]any{"type": "integer"}}}}, "maxParallel": map[string]any{"type": "integer"}}}}}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}, "400": map[string]any{"description": "Invalid operations"}}}},
+			"/providers/{id}/buckets/{name}/objects/versions":        map[string]any{"get": map[string]any{"summary": "All versions of an object, newest first", "parameters": []any{map[string]any{"name": "key", "in": "query", "required": true, "schema": map[string]any{"type": "string"}}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/providers/{id}/buckets/{name}/objects/restore-version": map[string]any{"post": map[string]any{"summary": "Make a prior version current again (newer versions are kept)", "requestBody": map[string]any{"content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object", "properties": map[string]any{"key": map[string]any{"type": "string"}, "versionId": map[string]any{"type": "string"}}}}}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}, "400": map[string]any{"description": "Version is current or a delete marker"}, "409": map[string]any{"description": "Versioning not enabled"}}}},
+			"/providers/{id}/buckets/{name}/config":                  map[string]any{"get": map[string]any{"summary": "Bucket settings (maxObjectSizeBytes; 0 = global limit only)", "responses": map[string]any{"200": map[string]any{"description": "OK"}}}, "put": map[string]any{"summary": "Update bucket settings (admin)", "requestBody": map[string]any{"content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object", "properties": map[string]any{"maxObjectSizeBytes": map[string]any{"type": "integer"}}}}}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/providers/{id}/buckets/{name}/upload-token":            map[string]any{"post": map[string]any{"summary": "Issue a token for one unauthenticated upload of key with a fixed contentType and maxSizeBytes (ttlSeconds default 300, max 86400; editor/admin)", "requestBody": map[string]any{"content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object", "required": []any{"key", "contentType", "maxSizeBytes"}}}}}, "responses": map[string]any{"201": map[string]any{"description": "Token and upload URL"}}}},
+			"/providers/{id}/buckets/{name}/upload-session":          map[string]any{"post": map[string]any{"summary": "Issue a one-time uploadToken for upload-stream and its progress stream (editor/admin)", "responses": map[string]any{"201": map[string]any{"description": "uploadToken, progressUrl and expiresAt"}}}},
+			"/providers/{id}/buckets/{name}/upload-stream":           map[string]any{"post": map[string]any{"summary": "Upload the raw request body as key, publishing progress to the upload session (editor/admin; 401 for an unknown or used token)", "parameters": []any{map[string]any{"name": "key", "in": "query", "required": true, "schema": map[string]any{"type": "string"}}, map[string]any{"name": "size", "in": "query", "schema": map[string]any{"type": "integer"}}, map[string]any{"name": "uploadToken", "in": "query", "required": true, "schema": map[string]any{"type": "string"}}}, "responses": map[string]any{"200": map[string]any{"description": "Uploaded"}}}},
+			"/providers/{id}/upload-progress/{uploadToken}":          map[string]any{"get": map[string]any{"summary": "Upload progress (SSE; bytesUploaded/totalBytes/percent every 250 ms, then done with key, or error)", "responses": map[string]any{"200": map[string]any{"description": "text/event-stream"}, "404": map[string]any{"description": "Unknown or expired upload session"}}}},
+			"/upload/{token}":                             map[string]any{"post": map[string]any{"summary": "Upload the request body with an upload token (no login; 415 on a Content-Type mismatch, 413 above maxSizeBytes, 401 for an invalid or expired token)", "responses": map[string]any{"200": map[string]any{"description": "Uploaded"}}}},
+			"/providers/{id}/health":                      map[string]any{"get": map[string]any{"summary": "Connectivity check state: consecutiveFailures, lastError, lastCheckedAt, alertSentAt (editor/admin)", "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/providers/{id}/buckets/{name}/stats":        map[string]any{"get": map[string]any{"summary": "Cached object count, total size and last modified (computed on first use; refresh=true or nocache=true recomputes; stale when older than 25h; partial when the listing exceeds 60s)", "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/providers/{id}/buckets/{name}/index/status": map[string]any{"get": map[string]any{"summary": "Object index status: indexedObjects, lastIndexedAt, staleSinceSeconds, indexing (reindexed hourly)", "responses": map[string]any{"200": map[string]any{"description": "OK"}, "202": map[string]any{"description": "Reindex running"}}}},
+			"/logs/by-trace/{traceId}":                    map[string]any{"get": map[string]any{"summary": "Log entries written while handling the request with this trace ID, oldest first (limit, offset)", "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/timezones":                                  map[string]any{"get": map[string]any{"summary": "IANA time zone names accepted in the X-Timezone header (unauthenticated)", "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/me/accessible-buckets":                      map[string]any{"get": map[string]any{"summary": "Buckets the current user can read", "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/providers/{id}/trash":                       map[string]any{"get": map[string]any{"summary": "List trashed objects", "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/providers/{id}/trash/{trashId}/restore":     map[string]any{"post": map[string]any{"summary": "Restore a trashed object", "parameters": []any{map[string]any{"name": "overwrite", "in": "query", "schema": map[string]any{"type": "boolean"}}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}, "409": map[string]any{"description": "An object exists at the original key and overwrite is not true"}}}},
 			"/providers/{id}/buckets/{name}/upload": map[string]any{
-				"post": map[string]any{"summary": "Upload object", "requestBody": map[string]any{"required": true, "content": map[string]any{"multipart/form-data": map[string]any{"schema": map[string]any{"type": "object", "properties": map[string]any{"file": map[string]any{"type": "string", "format": "binary"}, "key": map[string]any{"type": "string"}}, "required": []any{"file"}}}}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}}},
+				"post": map[string]any{"summary": "Upload object, or several as file/key, file1/key1… (array response)", "requestBody": map[string]any{"required": true, "content": map[string]any{"multipart/form-data": map[string]any{"schema": map[string]any{"type": "object", "properties": map[string]any{"file": map[string]any{"type": "string", "format": "binary"}, "key": map[string]any{"type": "string"}}, "required": []any{"file"}}}}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}}},
+			},
+			"/providers/{id}/buckets/{name}/download":       map[string]any{"get": map[string]any{"summary": "Download object", "parameters": []any{map[string]any{"name": "key", "in": "query", "required": true, "schema": map[string]any{"type": "string"}}, map[string]any{"name": "inline", "in": "query", "required": false, "schema": map[string]any{"type": "boolean"}}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/providers/{id}/buckets/{name}/presign":        map[string]any{"get": map[string]any{"summary": "Presigned download URL (expiry default 1h, capped at MAX_PRESIGN_EXPIRY_HOURS)", "parameters": []any{map[string]any{"name": "key", "in": "query", "required": true, "schema": map[string]any{"type": "string"}}, map[string]any{"name": "expiry", "in": "query", "required": false, "schema": map[string]any{"type": "string"}, "description": "Duration such as 15m, or seconds"}}, "responses": map[string]any{"200": map[string]any{"description": "{url, method, key, expiresAt}"}}}},
+			"/providers/{id}/buckets/{name}/upload-url":     map[string]any{"post": map[string]any{"summary": "Fetch an http(s) URL into the bucket (editor/admin)", "requestBody": map[string]any{"content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object", "required": []any{"url"}, "properties": map[string]any{"url": map[string]any{"type": "string"}, "key": map[string]any{"type": "string"}}}}}}, "responses": map[string]any{"201": map[string]any{"description": "{key, size, etag, contentType}"}}}},
+			"/providers/{id}/buckets/{name}/presign-upload": map[string]any{"post": map[string]any{"summary": "Presigned upload (PUT) URL (editor/admin)", "requestBody": map[string]any{"content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object", "required": []any{"key"}, "properties": map[string]any{"key": map[string]any{"type": "string"}, "expiry": map[string]any{"type": "string"}}}}}}, "responses": map[string]any{"200": map[string]any{"description": "{url, method, key, expiresAt}"}, "409": map[string]any{"description": "The bucket has an object size limit, which a presigned PUT cannot enforce"}}}},
+			"/providers/{id}/buckets/{name}/copy":           map[string]any{"post": map[string]any{"summary": "Copy object", "requestBody": map[string]any{"required": true, "content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object", "properties": map[string]any{"srcKey": map[string]any{"type": "string"}, "dstBucket": map[string]any{"type": "string"}, "dstKey": map[string]any{"type": "string"}, "dstProviderId": map[string]any{"type": "integer"}}, "required": []any{"srcKey", "dstBucket"}}}}}, "responses": map[string]any{"200": map[string]any{"description": "OK (NDJSON progress)"}}}},
+			"/providers/{id}/buckets/{name}/move":           map[string]any{"post": map[string]any{"summary": "Move object", "requestBody": map[string]any{"required": true, "content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object", "properties": map[string]any{"srcKey": map[string]any{"type": "string"}, "dstBucket": map[string]any{"type": "string"}, "dstKey": map[string]any{"type": "string"}, "dstProviderId": map[string]any{"type": "integer"}}, "required": []any{"srcKey", "dstBucket"}}}}}, "responses": map[string]any{"200": map[string]any{"description": "OK (NDJSON progress)"}}}},
+			"/users/":                                       map[string]any{"get": map[string]any{"summary": "List users with lastLoginAt and activeSessionCount (admin)", "parameters": []any{map[string]any{"name": "sortBy", "in": "query", "schema": map[string]any{"type": "string", "enum": []any{"last_login_at", "created_at", "email"}}}, map[string]any{"name": "limit", "in": "query", "schema": map[string]any{"type": "integer"}}, map[string]any{"name": "offset", "in": "query", "schema": map[string]any{"type": "integer"}}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}}}, "post": map[string]any{"summary": "Create user (admin)", "responses": map[string]any{"201": map[string]any{"description": "Created"}}}},
+			"/admin/auth/saml/test-mapping":                 map[string]any{"post": map[string]any{"summary": "Dry-run the SAML attribute mapping against a raw assertion (XML body); returns email, mappedRole and attributes without creating a user or session (admin)", "requestBody": map[string]any{"required": true, "content": map[string]any{"application/xml": map[string]any{"schema": map[string]any{"type": "string"}}}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}, "400": map[string]any{"description": "Not a SAML assertion"}}}},
+			"/admin/users/{id}":                             map[string]any{"get": map[string]any{"summary": "User detail: lastLoginAt, lastLoginIp, activeSessionCount, totalLoginCount (admin)", "responses": map[string]any{"200": map[string]any{"description": "OK"}, "404": map[string]any{"description": "Not found"}}}},
+			"/admin/users/{id}/force-password-change":       map[string]any{"post": map[string]any{"summary": "Force a user to change password on next use (admin)", "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/admin/bucket-templates/": map[string]any{
+				"get":  map[string]any{"summary": "List bucket templates (admin)", "responses": map[string]any{"200": map[string]any{"description": "OK"}}},
+				"post": map[string]any{"summary": "Create bucket template (admin)", "requestBody": map[string]any{"required": true, "content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/BucketTemplate"}}}}, "responses": map[string]any{"201": map[string]any{"description": "Created"}}},
+			},
+			"/admin/bucket-templates/{tid}": map[string]any{
+				"get":    map[string]any{"summary": "Get bucket template (admin)", "responses": map[string]any{"200": map[string]any{"description": "OK"}}},
+				"put":    map[string]any{"summary": "Replace bucket template (admin)", "responses": map[string]any{"200": map[string]any{"description": "OK"}}},
+				"delete": map[string]any{"summary": "Delete bucket template (admin)", "responses": map[string]any{"204": map[string]any{"description": "No Content"}}},
 			},
-			"/providers/{id}/buckets/{name}/download": map[string]any{"get": map[string]any{"summary": "Download object", "parameters": []any{map[string]any{"name": "key", "in": "query", "required": true, "schema": map[string]any{"type": "string"}}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
-			"/providers/{id}/buckets/{name}/copy":     map[string]any{"post": map[string]any{"summary": "Copy object", "requestBody": map[string]any{"required": true, "content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object", "properties": map[string]any{"srcKey": map[string]any{"type": "string"}, "dstBucket": map[string]any{"type": "string"}, "dstKey": map[string]any{"type": "string"}, "dstProviderId": map[string]any{"type": "integer"}}, "required": []any{"srcKey", "dstBucket"}}}}}, "responses": map[string]any{"200": map[string]any{"description": "OK (NDJSON progress)"}}}},
-			"/providers/{id}/buckets/{name}/move":     map[string]any{"post": map[string]any{"summary": "Move object", "requestBody": map[string]any{"required": true, "content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object", "properties": map[string]any{"srcKey": map[string]any{"type": "string"}, "dstBucket": map[string]any{"type": "string"}, "dstKey": map[string]any{"type": "string"}, "dstProviderId": map[string]any{"type": "integer"}}, "required": []any{"srcKey", "dstBucket"}}}}}, "responses": map[string]any{"200": map[string]any{"description": "OK (NDJSON progress)"}}}},
-			"/users/":                                 map[string]any{"get": map[string]any{"summary": "List users (admin)", "responses": map[string]any{"200": map[string]any{"description": "OK"}}}, "post": map[string]any{"summary": "Create user (admin)", "responses": map[string]any{"201": map[string]any{"description": "Created"}}}},
-			"/users/{id}":                             map[string]any{"put": map[string]any{"summary": "Update user (admin)", "responses": map[string]any{"200": map[string]any{"description": "OK"}}}, "delete": map[string]any{"summary": "Delete user (admin)", "responses": map[string]any{"204": map[string]any{"description": "No Content"}}}},
-			"/obs/metrics":                            map[string]any{"get": map[string]any{"summary": "Server metrics", "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
-			"/obs/summary":                            map[string]any{"get": map[string]any{"summary": "Observability summary", "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
-			"/obs/errors":                             map[string]any{"get": map[string]any{"summary": "Recent error traces", "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
-			"/trace/recent":                           map[string]any{"get": map[string]any{"summary": "Recent traces", "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
-			"/trace/{id}":                             map[string]any{"get": map[string]any{"summary": "Trace detail", "parameters": []any{map[string]any{"name": "id", "in": "path", "required": true, "schema": map[string]any{"type": "string"}}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/users/{id}":                   map[string]any{"put": map[string]any{"summary": "Update user (admin)", "responses": map[string]any{"200": map[string]any{"description": "OK"}}}, "delete": map[string]any{"summary": "Delete user (admin)", "responses": map[string]any{"204": map[string]any{"description": "No Content"}}}},
+			"/obs/metrics":                  map[string]any{"get": map[string]any{"summary": "Server metrics", "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/obs/metrics/series":           map[string]any{"get": map[string]any{"summary": "Metric time series (granularity chosen from range)", "parameters": []any{map[string]any{"name": "name", "in": "query", "required": true, "schema": map[string]any{"type": "string"}}, map[string]any{"name": "from", "in": "query", "schema": map[string]any{"type": "string", "format": "date-time"}}, map[string]any{"name": "to", "in": "query", "schema": map[string]any{"type": "string", "format": "date-time"}}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/obs/summary":                  map[string]any{"get": map[string]any{"summary": "Observability summary; perBucket counts are bucketGranularitySec wide (1m up to 12m, 5m for 1h, 1h beyond); truncated is set past 50000 traces", "parameters": []any{map[string]any{"name": "window", "in": "query", "schema": map[string]any{"type": "string", "enum": []any{"5m", "12m", "1h", "24h", "7d"}, "default": "12m"}}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/obs/live":                     map[string]any{"get": map[string]any{"summary": "Live summary stream (SSE; one obsSummary-shaped frame per interval, max 20 streams)", "parameters": []any{map[string]any{"name": "since", "in": "query", "schema": map[string]any{"type": "integer"}}}, "responses": map[string]any{"200": map[string]any{"description": "text/event-stream"}, "503": map[string]any{"description": "Too many live connections"}}}},
+			"/admin/export/traces":          map[string]any{"get": map[string]any{"summary": "Export persisted traces (admin)", "parameters": []any{map[string]any{"name": "from", "in": "query", "schema": map[string]any{"type": "string", "format": "date-time"}}, map[string]any{"name": "to", "in": "query", "schema": map[string]any{"type": "string", "format": "date-time"}}, map[string]any{"name": "format", "in": "query", "schema": map[string]any{"type": "string", "enum": []any{"ndjson", "csv"}}}}, "responses": map[string]any{"200": map[string]any{"description": "NDJSON or CSV download; X-Truncated: true when capped at EXPORT_MAX_ROWS"}}}},
+			"/admin/export/logs":            map[string]any{"get": map[string]any{"summary": "Export persisted log entries (admin)", "parameters": []any{map[string]any{"name": "from", "in": "query", "schema": map[string]any{"type": "string", "format": "date-time"}}, map[string]any{"name": "to", "in": "query", "schema": map[string]any{"type": "string", "format": "date-time"}}, map[string]any{"name": "format", "in": "query", "schema": map[string]any{"type": "string", "enum": []any{"ndjson", "csv"}}}}, "responses": map[string]any{"200": map[string]any{"description": "NDJSON or CSV download; X-Truncated: true when capped at EXPORT_MAX_ROWS"}}}},
+			"/admin/config/s3-transport":    map[string]any{"get": map[string]any{"summary": "HTTP transport settings of S3 clients: idleConnTimeoutSec, maxIdleConns, dialTimeoutSec, tlsHandshakeTimeoutSec (admin)", "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/admin/db/migrations":          map[string]any{"get": map[string]any{"summary": "Applied schema migrations [{version, description, appliedAt}] (admin)", "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/admin/db/migrations/rollback": map[string]any{"post": map[string]any{"summary": "Roll back the last applied migration (admin)", "responses": map[string]any{"200": map[string]any{"description": "The rolled back migration"}, "409": map[string]any{"description": "Nothing applied, or the migration is irreversible"}}}},
+			"/admin/cache/s3-clients":       map[string]any{"get": map[string]any{"summary": "Cached S3 clients: size, cap (S3_CLIENT_CACHE_SIZE) and items [{providerId, lastUsed}], most recent first (admin)", "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/audit":                        map[string]any{"get": map[string]any{"summary": "Audit log of security-sensitive actions, newest first (admin)", "parameters": []any{map[string]any{"name": "limit", "in": "query", "schema": map[string]any{"type": "integer"}, "description": "Default 100, max 1000"}, map[string]any{"name": "before", "in": "query", "schema": map[string]any{"type": "string", "format": "date-time"}, "description": "Only entries older than this"}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}, "403": map[string]any{"description": "Not an admin"}}}},
+			"/admin/dashboard":              map[string]any{"get": map[string]any{"summary": "Dashboard data in one call: metrics, observability (12m summary), recentErrors (20), providers with health, dbStats (editor/admin; Cache-Control max-age=10, ETag with 304 on If-None-Match)", "responses": map[string]any{"200": map[string]any{"description": "OK"}, "304": map[string]any{"description": "Not modified"}}}},
+			"/obs/errors":                   map[string]any{"get": map[string]any{"summary": "Recent error traces", "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/trace/recent":                 map[string]any{"get": map[string]any{"summary": "Recent traces", "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/trace/list":                   map[string]any{"get": map[string]any{"summary": "Traces page (keyset pagination)", "parameters": []any{map[string]any{"name": "cursor", "in": "query", "schema": map[string]any{"type": "string"}}, map[string]any{"name": "firstCursor", "in": "query", "schema": map[string]any{"type": "string"}}, map[string]any{"name": "limit", "in": "query", "schema": map[string]any{"type": "integer"}}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
+			"/trace/stream":                 map[string]any{"get": map[string]any{"summary": "Live trace stream (SSE; the last 20 traces, then each new one)", "parameters": []any{map[string]any{"name": "status", "in": "query", "schema": map[string]any{"type": "string"}, "description": "Exact code or class such as 5xx"}}, "responses": map[string]any{"200": map[string]any{"description": "text/event-stream"}}}},
+			"/trace/export.csv":             map[string]any{"get": map[string]any{"summary": "Export traces as CSV (same filters as /trace/list, max 50000 rows)", "responses": map[string]any{"200": map[string]any{"description": "text/csv"}}}},
+			"/trace/{id}":                   map[string]any{"get": map[string]any{"summary": "Trace detail", "parameters": []any{map[string]any{"name": "id", "in": "path", "required": true, "schema": map[string]any{"type": "string"}}}, "responses": map[string]any{"200": map[string]any{"description": "OK"}}}},
 		},
 		"components": map[string]any{
 			"schemas": map[string]any{
 				"Provider": map[string]any{"type": "object", "properties": map[string]any{
-					"id":        map[string]any{"type": "integer"},
-					"name":      map[string]any{"type": "string"},
-					"type":      map[string]any{"type": "string"},
-					"endpoint":  map[string]any{"type": "string"},
-					"accessKey": map[string]any{"type": "string"},
-					"secretKey": map[string]any{"type": "string"},
-					"region":    map[string]any{"type": "string"},
-					"useSSL":    map[string]any{"type": "boolean"},
+					"id":                map[string]any{"type": "integer"},
+					"name":              map[string]any{"type": "string"},
+					"type":              map[string]any{"type": "string"},
+					"endpoint":          map[string]any{"type": "string"},
+					"accessKey":         map[string]any{"type": "string"},
+					"secretKey":         map[string]any{"type": "string"},
+					"secretRef":         map[string]any{"type": "string", "description": "Secret key reference in the secrets backend (env var name, or Vault KV path[#field]); overrides secretKey"},
+					"region":            map[string]any{"type": "string"},
+					"useSSL":            map[string]any{"type": "boolean"},
+					"secondaryEndpoint": map[string]any{"type": "string"},
+					"failoverEnabled":   map[string]any{"type": "boolean"},
+					"pathStyle":         map[string]any{"type": "string", "enum": []any{"auto", "path", "virtual"}},
+					"tags":              map[string]any{"type": "array", "maxItems": 20, "items": map[string]any{"type": "string", "minLength": 1, "maxLength": 64}},
+					"maxRetries":        map[string]any{"type": "integer", "minimum": 0, "maximum": 10, "description": "Retries of a list, upload or download failing with a transient error (default 3)"},
 				}, "required": []any{"name", "endpoint"}},
+				"BucketTemplate": map[string]any{"type": "object", "properties": map[string]any{
+					"name":                map[string]any{"type": "string"},
+					"versioningEnabled":   map[string]any{"type": "boolean"},
+					"lifecycleRulesJson":  map[string]any{"type": "string", "description": "JSON array of S3 lifecycle rules"},
+					"defaultStorageClass": map[string]any{"type": "string"},
+					"aclsJson":            map[string]any{"type": "string", "description": "JSON array of {subject, permission}"},
+				}, "required": []any{"name"}},
 			},
 		},
 	}
+	documentErrorCodes(spec)
 	json.NewEncoder(w).Encode(spec)
 }
 
+// apiTimeoutMiddleware applies TIMEOUT_API_SEC to every API route; routes that manage
+// their own deadlines (transfers, streams, exports, S3 Select) opt out with noAPITimeout.
+func apiTimeoutMiddleware(next http.Handler) http.Handler {
+	if apiTimeout <= 0 {
+		return next
+	}
+	return middleware.Timeout(apiTimeout)(next)
+}
+
+// noAPITimeout exempts a long-running route from TIMEOUT_API_SEC: r.With(noAPITimeout).
+var noAPITimeout = middleware.NoTimeout
+
 func registerAPI(r chi.Router, logger logging.Logger) {
 	s := &apiServer{logger: logger}
 	registerAuth(r, logger)
+	r.Get("/timezones", listTimezones)
+	// upload tokens stand in for a login on this route
+	r.Post("/upload/{token}", uploadWithToken)
 	// protected routes
 	r.Group(func(pr chi.Router) {
+		pr.Use(providerTokenAuth)
 		pr.Use(requireAuth)
+		pr.Use(requireNoPasswordChange)
+		pr.Use(tenantMiddleware)
+		pr.Use(apiTimeoutMiddleware)
 		// observability (lightweight metrics), visible to any authenticated user
-		pr.Get("/obs/metrics", metricsHandler)
+		pr.With(middleware.Cache(10*time.Second, cacheKey("obs.metrics"))).Get("/obs/metrics", metricsHandler)
+		pr.Get("/obs/metrics/series", metricsSeries)
 		pr.Get("/obs/errors", errorsHandler)
-		pr.Get("/obs/summary", obsSummary)
+		pr.With(middleware.Cache(15*time.Second, cacheKey("obs.summary"))).Get("/obs/summary", obsSummary)
+		pr.With(noAPITimeout).Get("/obs/live", obsLive)
 		// OpenAPI (Swagger) spec — restricted to editor/admin
 		pr.With(requireEditorOrAdmin).Get("/openapi.json", openapiHandler)
 		// tracing endpoints
 		pr.Get("/trace/recent", traceRecent)
+		pr.Get("/trace/list", traceList)
+		pr.With(noAPITimeout).Get("/trace/stream", traceStream)
+		pr.With(requireEditorOrAdmin, noAPITimeout).Get("/trace/export.csv", traceExportCSV)
 		pr.Get("/trace/{id}", traceGet)
 		// logging endpoints
 		pr.Get("/logs/recent", logsRecent)
-		pr.Get("/logs/download", logsDownload)
+		pr.Get("/logs/by-trace/{traceId}", logsByTrace)
+		pr.With(noAPITimeout).Get("/logs/download", logsDownload)
 		pr.Get("/logs/level", logsGetLevel)
-		pr.Put("/logs/level", logsSetLevel)
-		pr.Get("/logs/stream", logsStream)
+		pr.With(requireSuperAdmin).Put("/logs/level", logsSetLevel)
+		pr.Get("/logs/levels", logsGetLevels)
+		pr.With(requireSuperAdmin).Put("/logs/levels", logsSetLevels)
+		pr.With(noAPITimeout).Get("/logs/stream", logsStream)
+		pr.Get("/me/accessible-buckets", accessibleBuckets)
+		registerPreferences(pr)
 		pr.Route("/users", func(r chi.Router) {
 			r.Use(requireAdmin)
 			r.Get("/", s.listUsers)
@@ -468,19 +815,123 @@ func registerAPI(r chi.Router, logger logging.Logger) {
 			r.Put("/{id}", s.updateUser)
 			r.Delete("/{id}", s.deleteUser)
 		})
-		registerProviders(pr)
-		registerBuckets(pr)
+		pr.With(requireAdmin).Get("/admin/users/{id}", s.getUserDetail)
+		pr.With(requireAdmin).Post("/admin/users/{id}/force-password-change", s.forcePasswordChange)
+		pr.With(requireAdmin).Post("/admin/auth/saml/test-mapping", samlTestMapping)
+		pr.With(requireEditorOrAdmin).Get("/admin/dashboard", adminDashboard)
+		registerBucketTemplates(pr)
+		registerAudit(pr)
+		// provider-scoped routes are checked against the request tenant
+		tr := pr.With(requireProviderTenant)
+		registerProviders(tr)
+		registerBuckets(tr)
+		registerTrash(tr)
+		registerBucketPolicy(tr)
+		registerBucketACL(tr)
+		registerObjectStats(tr)
+		registerStaleObjects(tr)
+		registerTiering(tr)
+		registerBatchCopy(tr)
+		registerObjectVersions(tr)
+		registerBucketConfig(tr)
+		registerUploadToken(tr)
+		registerUploadStream(tr)
+		registerBucketSummary(tr)
+		registerObjectIndex(tr)
+		registerClientCache(tr)
+		registerExport(tr)
+		registerMigrations(tr)
 	})
 }
 
+// UserSummary is a user as listed for admins.
+type UserSummary struct {
+	ID                 uint       `json:"id"`
+	Email              string     `json:"email"`
+	Role               string     `json:"role"`
+	MustChangePassword bool       `json:"mustChangePassword"`
+	TenantID           string     `json:"tenantId"`
+	CreatedAt          time.Time  `json:"createdAt"`
+	LastLoginAt        *time.Time `json:"lastLoginAt"`
+	ActiveSessionCount int        `json:"activeSessionCount"`
+}
+
+// UserDetail adds login history to UserSummary.
+type UserDetail struct {
+	UserSummary
+	LastLoginIP     string    `json:"lastLoginIp"`
+	TotalLoginCount int64     `json:"totalLoginCount"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+func userSummary(u models.User, sessions map[uint]int) UserSummary {
+	return UserSummary{ID: u.ID, Email: u.Email, Role: u.Role, MustChangePassword: u.MustChangePassword, TenantID: u.TenantID, CreatedAt: u.CreatedAt, LastLoginAt: u.LastLoginAt, ActiveSessionCount: sessions[u.ID]}
+}
+
+// userSortColumns maps ?sortBy values to ORDER BY clauses; users never seen log in sort last.
+var userSortColumns = map[string]string{
+	"":              "id",
+	"email":         "email",
+	"created_at":    "created_at DESC",
+	"last_login_at": "last_login_at IS NULL, last_login_at DESC",
+}
+
 func (s *apiServer) listUsers(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	qs := r.URL.Query()
+	order, ok := userSortColumns[qs.Get("sortBy")]
+	if !ok {
+		respondError(w, r, 400, "sortBy must be one of last_login_at, created_at, email", ErrCodeInvalidParameter)
+		return
+	}
+	limit, offset := 100, 0
+	if v := qs.Get("limit"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			limit = min(i, 1000)
+		}
+	}
+	if v := qs.Get("offset"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			offset = i
+		}
+	}
+	var total int64
+	if err := readDB(r).Model(&models.User{}).Scopes(tenantScope(r)).Count(&total).Error; err != nil {
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
+		return
+	}
 	var users []models.User
-	if err := db.DB.Find(&users).Error; err != nil {
-		http.Error(w, err.Error(), 500)
+	if err := readDB(r).Scopes(tenantScope(r)).Order(order).Limit(limit).Offset(offset).Find(&users).Error; err != nil {
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
+		return
+	}
+	counts := sessionCounts()
+	out := make([]UserSummary, 0, len(users))
+	for _, u := range users {
+		out = append(out, userSummary(u, counts))
+	}
+	RespondList(w, r, 200, out, int(total), limit, offset)
+}
+
+// getUserDetail returns one user with last login, session and login counts (admin).
+func (s *apiServer) getUserDetail(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id <= 0 {
+		respondError(w, r, 400, "invalid user id", ErrCodeInvalidID)
+		return
+	}
+	var u models.User
+	if err := readDB(r).Scopes(tenantScope(r)).First(&u, id).Error; err != nil {
+		respondError(w, r, 404, "not found", ErrCodeNotFound)
 		return
 	}
-	json.NewEncoder(w).Encode(users)
+	d := UserDetail{UserSummary: userSummary(u, sessionCounts()), LastLoginIP: u.LastLoginIP, UpdatedAt: u.UpdatedAt}
+	if err := readDB(r).Model(&models.LoginAttempt{}).Where("user_id = ? AND success = ?", u.ID, true).Count(&d.TotalLoginCount).Error; err != nil {
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
+		return
+	}
+	Respond(w, r, 200, d)
 }
 
 var emailRe = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
@@ -493,62 +944,66 @@ func (s *apiServer) createUser(w http.ResponseWriter, r *http.Request) {
 		Role     string `json:"role"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-		http.Error(w, err.Error(), 400)
+		respondError(w, r, 400, err.Error(), ErrCodeInvalidRequest)
 		return
 	}
 	if in.Email == "" || !emailRe.MatchString(in.Email) {
-		http.Error(w, "invalid email", 400)
+		respondError(w, r, 400, "invalid email", ErrCodeValidation)
 		return
 	}
 	if len(in.Password) < 8 {
-		http.Error(w, "password too short", 400)
+		respondError(w, r, 400, "password too short", ErrCodeWeakPassword)
 		return
 	}
 	role := in.Role
 	if role == "" {
 		role = "admin"
 	}
+	if role == roleSuperAdmin && !isSuperAdmin(r) {
+		respondError(w, r, 403, "forbidden", ErrCodeForbidden)
+		return
+	}
 	hash, err := bcrypt.GenerateFromPassword([]byte(in.Password), bcrypt.DefaultCost)
 	if err != nil {
-		http.Error(w, "failed to hash password", 500)
+		respondError(w, r, 500, "failed to hash password", ErrCodeInternal)
 		return
 	}
-	u := models.User{Email: in.Email, Password: string(hash), Role: role}
+	u := models.User{Email: in.Email, Password: string(hash), Role: role, TenantID: tenantFromCtx(r)}
 	if err := db.DB.Create(&u).Error; err != nil {
-		http.Error(w, err.Error(), 500)
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
 		return
 	}
-	w.WriteHeader(201)
-	json.NewEncoder(w).Encode(u)
+	RecordAudit(r.Context(), "user.create", "user", strconv.Itoa(int(u.ID)), map[string]any{"email": u.Email, "role": u.Role})
+	Respond(w, r, 201, u)
 }
 
 func (s *apiServer) updateUser(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil || id <= 0 {
-		http.Error(w, "invalid user id", 400)
+		respondError(w, r, 400, "invalid user id", ErrCodeInvalidID)
 		return
 	}
 	var u models.User
-	if err := db.DB.First(&u, id).Error; err != nil {
-		http.Error(w, "not found", 404)
+	if err := db.DB.Scopes(tenantScope(r)).First(&u, id).Error; err != nil {
+		respondError(w, r, 404, "not found", ErrCodeNotFound)
 		return
 	}
 	var in map[string]any
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-		http.Error(w, err.Error(), 400)
+		respondError(w, r, 400, err.Error(), ErrCodeInvalidRequest)
 		return
 	}
 	if v, ok := in["email"].(string); ok {
 		if v == "" || !emailRe.MatchString(v) {
-			http.Error(w, "invalid email", 400)
+			respondError(w, r, 400, "invalid email", ErrCodeValidation)
 			return
 		}
 		u.Email = v
 	}
 	if v, ok := in["password"].(string); ok {
 		if len(v) < 8 {
-			http.Error(w, "password too short", 400)
+			respondError(w, r, 400, "password too short", ErrCodeWeakPassword)
 			return
 		}
 		hash, _ := bcrypt.GenerateFromPassword([]byte(v), bcrypt.DefaultCost)
@@ -556,24 +1011,66 @@ func (s *apiServer) updateUser(w http.ResponseWriter, r *http.Request) {
 		u.MustChangePassword = true
 	}
 	if v, ok := in["role"].(string); ok {
+		if (v == roleSuperAdmin || u.Role == roleSuperAdmin) && !isSuperAdmin(r) {
+			respondError(w, r, 403, "forbidden", ErrCodeForbidden)
+			return
+		}
 		u.Role = v
 	}
 	if err := db.DB.Save(&u).Error; err != nil {
-		http.Error(w, err.Error(), 500)
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
+		return
+	}
+	// record which fields changed, never the new password itself
+	changed := make([]string, 0, len(in))
+	for k := range in {
+		if k == "email" || k == "password" || k == "role" {
+			changed = append(changed, k)
+		}
+	}
+	sort.Strings(changed)
+	RecordAudit(r.Context(), "user.update", "user", strconv.Itoa(int(u.ID)), map[string]any{"email": u.Email, "role": u.Role, "changed": changed})
+	Respond(w, r, 200, u)
+}
+
+// forcePasswordChange flags a user (e.g. a compromised account) so that every protected
+// route answers 403 until they set a new password.
+func (s *apiServer) forcePasswordChange(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id <= 0 {
+		respondError(w, r, 400, "invalid user id", ErrCodeInvalidID)
+		return
+	}
+	var u models.User
+	if err := db.DB.Scopes(tenantScope(r)).First(&u, id).Error; err != nil {
+		respondError(w, r, 404, "not found", ErrCodeNotFound)
+		return
+	}
+	if u.Role == roleSuperAdmin && !isSuperAdmin(r) {
+		respondError(w, r, 403, "forbidden", ErrCodeForbidden)
+		return
+	}
+	u.MustChangePassword = true
+	if err := db.DB.Save(&u).Error; err != nil {
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
 		return
 	}
-	json.NewEncoder(w).Encode(u)
+	addEvent(r, "user.force_password_change", map[string]any{"userId": u.ID, "email": u.Email})
+	RecordAudit(r.Context(), "user.force_password_change", "user", strconv.Itoa(int(u.ID)), map[string]any{"email": u.Email})
+	Respond(w, r, 200, u)
 }
 
 func (s *apiServer) deleteUser(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil || id <= 0 {
-		http.Error(w, "invalid user id", 400)
+		respondError(w, r, 400, "invalid user id", ErrCodeInvalidID)
 		return
 	}
-	if err := db.DB.Delete(&models.User{}, id).Error; err != nil {
-		http.Error(w, err.Error(), 500)
+	if err := db.DB.Scopes(tenantScope(r)).Delete(&models.User{}, id).Error; err != nil {
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
 		return
 	}
+	RecordAudit(r.Context(), "user.delete", "user", strconv.Itoa(id), nil)
 	w.WriteHeader(204)
 }
@@ -0,0 +1,142 @@
+package api
+
+import "strings"
+
+// Machine-readable error codes, sent as "code" in JSON error bodies next to the human
+// readable "error". The HTTP status gives the class of failure and the code names the
+// failure itself, so clients can branch on it instead of on the message text.
+const (
+	// request problems (400, 413)
+	ErrCodeInvalidID        = "INVALID_ID"
+	ErrCodeInvalidRequest   = "INVALID_REQUEST"   // the body or form could not be read
+	ErrCodeMissingField     = "MISSING_FIELD"     // a required field or parameter is empty
+	ErrCodeInvalidParameter = "INVALID_PARAMETER" // a query parameter is out of range
+	ErrCodeValidation       = "VALIDATION_FAILED" // a field of the body is invalid
+	ErrCodeWeakPassword     = "WEAK_PASSWORD"
+	ErrCodePayloadTooLarge  = "PAYLOAD_TOO_LARGE"
+
+	// authentication and authorisation (401, 403)
+	ErrCodeUnauthorized       = "UNAUTHORIZED"
+	ErrCodeInvalidCredentials = "INVALID_CREDENTIALS"
+	ErrCodeForbidden          = "FORBIDDEN"
+	ErrCodeAuthNotConfigured  = "AUTH_NOT_CONFIGURED"
+	ErrCodeOIDCFailed         = "OIDC_FAILED"
+
+	// missing resources (404) and conflicts (409)
+	ErrCodeNotFound         = "NOT_FOUND"
+	ErrCodeProviderNotFound = "PROVIDER_NOT_FOUND"
+	ErrCodeBucketNotFound   = "BUCKET_NOT_FOUND"
+	ErrCodeObjectNotFound   = "OBJECT_NOT_FOUND"
+	ErrCodeConflict         = "CONFLICT"
+
+	// failed preconditions (412) and checks of otherwise valid input (422)
+	ErrCodePreconditionFailed      = "PRECONDITION_FAILED"
+	ErrCodeConnectivityCheckFailed = "CONNECTIVITY_CHECK_FAILED"
+
+	// limits and availability (429, 503)
+	ErrCodeQuotaExceeded = "QUOTA_EXCEEDED"
+	ErrCodeShuttingDown  = "SHUTTING_DOWN"
+	ErrCodeRateLimited   = "RATE_LIMITED"
+
+	// fetching a remote resource for upload-url (400, 502)
+	ErrCodeFetchFailed = "FETCH_FAILED"
+
+	// storage provider failures, from s3.ClassifyError
+	ErrCodeStorageError       = "STORAGE_ERROR"
+	ErrCodeStorageBusy        = "STORAGE_BUSY"
+	ErrCodeStorageUnavailable = "STORAGE_UNAVAILABLE"
+	ErrCodeStorageTimeout     = "STORAGE_TIMEOUT"
+
+	// server faults (500)
+	ErrCodeInternal = "INTERNAL"
+)
+
+// ErrorCodes lists every code, for the OpenAPI spec.
+var ErrorCodes = []string{
+	ErrCodeInvalidID, ErrCodeInvalidRequest, ErrCodeMissingField, ErrCodeInvalidParameter, ErrCodeValidation, ErrCodeWeakPassword, ErrCodePayloadTooLarge,
+	ErrCodeUnauthorized, ErrCodeInvalidCredentials, ErrCodeForbidden, ErrCodeAuthNotConfigured, ErrCodeOIDCFailed,
+	ErrCodeNotFound, ErrCodeProviderNotFound, ErrCodeBucketNotFound, ErrCodeObjectNotFound, ErrCodeConflict,
+	ErrCodePreconditionFailed, ErrCodeConnectivityCheckFailed,
+	ErrCodeQuotaExceeded, ErrCodeShuttingDown,
+	ErrCodeFetchFailed,
+	ErrCodeStorageError, ErrCodeStorageBusy, ErrCodeStorageUnavailable, ErrCodeStorageTimeout,
+	ErrCodeInternal,
+}
+
+// storageErrCode is the error code for a status and message returned by s3.ClassifyError.
+func storageErrCode(status int, msg string) string {
+	switch status {
+	case 400:
+		return ErrCodeValidation
+	case 403:
+		return ErrCodeForbidden
+	case 404:
+		if msg == "bucket not found" {
+			return ErrCodeBucketNotFound
+		}
+		return ErrCodeObjectNotFound
+	case 409:
+		return ErrCodeConflict
+	case 503:
+		if strings.Contains(msg, "busy") {
+			return ErrCodeStorageBusy
+		}
+		return ErrCodeStorageUnavailable
+	case 504:
+		return ErrCodeStorageTimeout
+	}
+	return ErrCodeStorageError
+}
+
+// endpointErrorCodes lists, per OpenAPI path, the codes its handlers return besides
+// UNAUTHORIZED and FORBIDDEN.
+var endpointErrorCodes = map[string][]string{
+	"/auth/login":                               {ErrCodeInvalidRequest, ErrCodeInvalidCredentials},
+	"/auth/me":                                  {ErrCodeInternal},
+	"/providers":                                {ErrCodeInvalidRequest, ErrCodeValidation, ErrCodeInternal},
+	"/providers/{id}":                           {ErrCodeInvalidID, ErrCodeNotFound, ErrCodeInvalidRequest, ErrCodeValidation, ErrCodeInternal},
+	"/providers/{id}/ca-cert":                   {ErrCodeInvalidID, ErrCodeNotFound, ErrCodeInvalidRequest, ErrCodeValidation, ErrCodeInternal},
+	"/providers/{id}/buckets":                   {ErrCodeInvalidID, ErrCodeProviderNotFound, ErrCodeInvalidRequest, ErrCodeMissingField, ErrCodeValidation, ErrCodeConflict, ErrCodeStorageError, ErrCodeStorageBusy, ErrCodeStorageUnavailable, ErrCodeStorageTimeout},
+	"/providers/{id}/buckets/{name}/objects":    {ErrCodeInvalidID, ErrCodeProviderNotFound, ErrCodeMissingField, ErrCodeBucketNotFound, ErrCodeObjectNotFound, ErrCodeStorageError, ErrCodeStorageBusy, ErrCodeStorageUnavailable, ErrCodeStorageTimeout},
+	"/providers/{id}/buckets/{name}/upload":     {ErrCodeInvalidID, ErrCodeProviderNotFound, ErrCodeMissingField, ErrCodeInvalidRequest, ErrCodePayloadTooLarge, ErrCodeQuotaExceeded, ErrCodeShuttingDown, ErrCodeBucketNotFound, ErrCodeStorageError, ErrCodeStorageBusy, ErrCodeStorageUnavailable, ErrCodeStorageTimeout},
+	"/providers/{id}/buckets/{name}/upload-url": {ErrCodeInvalidID, ErrCodeInvalidRequest, ErrCodeValidation, ErrCodeMissingField, ErrCodeProviderNotFound, ErrCodeQuotaExceeded, ErrCodeFetchFailed, ErrCodePayloadTooLarge, ErrCodeShuttingDown, ErrCodeBucketNotFound, ErrCodeStorageError, ErrCodeStorageBusy, ErrCodeStorageUnavailable, ErrCodeStorageTimeout},
+	"/providers/{id}/buckets/{name}/download":   {ErrCodeInvalidID, ErrCodeProviderNotFound, ErrCodeMissingField, ErrCodeQuotaExceeded, ErrCodeBucketNotFound, ErrCodeObjectNotFound, ErrCodeStorageError, ErrCodeStorageBusy, ErrCodeStorageUnavailable, ErrCodeStorageTimeout},
+	"/providers/{id}/buckets/{name}/copy":       {ErrCodeInvalidID, ErrCodeInvalidRequest, ErrCodeMissingField, ErrCodeProviderNotFound, ErrCodePayloadTooLarge, ErrCodeShuttingDown},
+	"/providers/{id}/buckets/{name}/move":       {ErrCodeInvalidID, ErrCodeInvalidRequest, ErrCodeMissingField, ErrCodeProviderNotFound, ErrCodePayloadTooLarge, ErrCodeShuttingDown, ErrCodeInternal},
+	"/upload/{token}":                           {ErrCodeValidation, ErrCodeMissingField, ErrCodePayloadTooLarge, ErrCodeProviderNotFound, ErrCodeQuotaExceeded, ErrCodeShuttingDown, ErrCodeBucketNotFound, ErrCodeStorageError, ErrCodeStorageBusy, ErrCodeStorageUnavailable, ErrCodeStorageTimeout},
+	"/users/":                                   {ErrCodeInvalidRequest, ErrCodeValidation, ErrCodeWeakPassword, ErrCodeInvalidParameter, ErrCodeInternal},
+	"/users/{id}":                               {ErrCodeInvalidID, ErrCodeNotFound, ErrCodeInvalidRequest, ErrCodeValidation, ErrCodeWeakPassword, ErrCodeInternal},
+	"/admin/users/{id}":                         {ErrCodeInvalidID, ErrCodeNotFound, ErrCodeInternal},
+	"/admin/dashboard":                          {ErrCodeInternal},
+	"/obs/summary":                              {ErrCodeInvalidParameter},
+	"/obs/metrics/series":                       {ErrCodeInvalidParameter, ErrCodeInternal},
+}
+
+// documentErrorCodes adds the Error schema and, to every operation of paths listed in
+// endpointErrorCodes, an x-error-codes list and a default Error response.
+func documentErrorCodes(spec map[string]any) {
+	schemas := spec["components"].(map[string]any)["schemas"].(map[string]any)
+	schemas["Error"] = map[string]any{"type": "object", "properties": map[string]any{
+		"error": map[string]any{"type": "string", "description": "Human readable message"},
+		"code":  map[string]any{"type": "string", "enum": ErrorCodes},
+	}, "required": []any{"error", "code"}}
+	for path, item := range spec["paths"].(map[string]any) {
+		codes, ok := endpointErrorCodes[path]
+		if !ok {
+			continue
+		}
+		if path != "/auth/login" {
+			codes = append([]string{ErrCodeUnauthorized, ErrCodeForbidden}, codes...)
+		}
+		for _, v := range item.(map[string]any) {
+			op, ok := v.(map[string]any) // skips path-level parameters
+			if !ok {
+				continue
+			}
+			op["x-error-codes"] = codes
+			if responses, ok := op["responses"].(map[string]any); ok {
+				responses["default"] = map[string]any{"description": "Error", "content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/Error"}}}}
+			}
+		}
+	}
+}
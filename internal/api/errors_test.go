@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestRespondErrorCode(t *testing.T) {
+	rec := httptest.NewRecorder()
+	respondError(rec, httptest.NewRequest("GET", "/x", nil), 404, "provider not found", ErrCodeProviderNotFound)
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || rec.Code != 404 || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("coded error %d %q %v", rec.Code, rec.Body, err)
+	}
+	if body["code"] != "PROVIDER_NOT_FOUND" || body["error"] != "provider not found" {
+		t.Fatalf("coded error body %v", body)
+	}
+	// without a code the plain-text body is kept
+	rec = httptest.NewRecorder()
+	respondError(rec, httptest.NewRequest("GET", "/x", nil), 400, "bad")
+	if rec.Body.String() != "bad\n" {
+		t.Fatalf("uncoded error %q", rec.Body)
+	}
+}
+
+func TestErrorCodesDocumented(t *testing.T) {
+	for status, want := range map[int]string{404: ErrCodeObjectNotFound, 503: ErrCodeStorageUnavailable, 504: ErrCodeStorageTimeout, 500: ErrCodeStorageError} {
+		if got := storageErrCode(status, ""); got != want {
+			t.Errorf("storageErrCode(%d) = %s, want %s", status, got, want)
+		}
+	}
+	if storageErrCode(404, "bucket not found") != ErrCodeBucketNotFound {
+		t.Error("missing bucket not mapped to BUCKET_NOT_FOUND")
+	}
+	rec := httptest.NewRecorder()
+	openapiHandler(rec, httptest.NewRequest("GET", "/api/v1/openapi.json", nil))
+	var spec struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &spec); err != nil {
+		t.Fatal(err)
+	}
+	if spec.Components.Schemas["Error"] == nil {
+		t.Fatal("Error schema missing")
+	}
+	for path := range endpointErrorCodes {
+		if _, ok := spec.Paths[path]; !ok {
+			t.Errorf("error codes listed for undocumented path %s", path)
+		}
+	}
+	var op struct {
+		Codes []string `json:"x-error-codes"`
+	}
+	json.Unmarshal(spec.Paths["/providers/{id}/buckets"]["get"], &op)
+	if codes := op.Codes; !slices.Contains(codes, ErrCodeProviderNotFound) || !slices.Contains(codes, ErrCodeUnauthorized) {
+		t.Fatalf("bucket list codes %v", codes)
+	}
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// exportMaxRows caps one /admin/export response (EXPORT_MAX_ROWS, set in Router).
+var exportMaxRows int64 = 500000
+
+var (
+	traceExportHeader = []string{"id", "method", "path", "status", "userEmail", "userRole", "userAgent", "remoteIp", "reqBytes", "respBytes", "started", "ended", "durationNs", "tenantId", "spanId", "parentTraceId"}
+	logExportHeader   = []string{"id", "time", "level", "msg", "fields", "tenantId"}
+)
+
+func registerExport(r chi.Router) {
+	r.With(requireAdmin, noAPITimeout).Get("/admin/export/traces", exportTraces)
+	r.With(requireAdmin, noAPITimeout).Get("/admin/export/logs", exportLogs)
+}
+
+// exportTraces streams the persisted traces started within ?from=&to= as NDJSON or CSV.
+func exportTraces(w http.ResponseWriter, r *http.Request) {
+	q, truncated, ok := exportQuery(w, r, &models.TraceRow{}, "started")
+	if !ok {
+		return
+	}
+	rows, err := q.Order("started asc, id asc").Rows()
+	if err != nil {
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
+		return
+	}
+	defer rows.Close()
+	ew := newExportWriter(w, r, "traces", traceExportHeader, truncated)
+	for rows.Next() {
+		var t models.TraceRow
+		if err := db.ReadDB().ScanRows(rows, &t); err != nil {
+			break
+		}
+		ew.write(t, []string{
+			t.ID, t.Method, t.Path, strconv.Itoa(t.Status), t.UserEmail, t.UserRole, t.UserAgent, t.RemoteIP,
+			strconv.FormatInt(t.ReqBytes, 10), strconv.FormatInt(t.RespBytes, 10),
+			t.Started.UTC().Format(time.RFC3339Nano), t.Ended.UTC().Format(time.RFC3339Nano),
+			strconv.FormatInt(t.DurationNs, 10), t.TenantID, t.SpanID, t.ParentTraceID,
+		})
+	}
+	ew.flush()
+	addEvent(r, "export.traces", map[string]any{"rows": ew.n, "truncated": truncated})
+}
+
+// exportLogs streams the persisted log entries written within ?from=&to= as NDJSON or CSV.
+func exportLogs(w http.ResponseWriter, r *http.Request) {
+	q, truncated, ok := exportQuery(w, r, &models.LogEntry{}, "time")
+	if !ok {
+		return
+	}
+	rows, err := q.Order("time asc, id asc").Rows()
+	if err != nil {
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
+		return
+	}
+	defer rows.Close()
+	ew := newExportWriter(w, r, "logs", logExportHeader, truncated)
+	for rows.Next() {
+		var e models.LogEntry
+		if err := db.ReadDB().ScanRows(rows, &e); err != nil {
+			break
+		}
+		ew.write(e, []string{strconv.FormatUint(uint64(e.ID), 10), e.Time.UTC().Format(time.RFC3339Nano), e.Level, e.Msg, e.Fields, e.TenantID})
+	}
+	ew.flush()
+	addEvent(r, "export.logs", map[string]any{"rows": ew.n, "truncated": truncated})
+}
+
+// exportQuery builds the export query on the read replica (when configured): the
+// tenant's rows of model with timeCol within ?from=&to= (RFC 3339), capped at
+// exportMaxRows. truncated reports whether more rows match than the cap.
+func exportQuery(w http.ResponseWriter, r *http.Request, model any, timeCol string) (q *gorm.DB, truncated, ok bool) {
+	qs := r.URL.Query()
+	if f := qs.Get("format"); f != "" && f != "ndjson" && f != "csv" {
+		respondError(w, r, 400, "format must be ndjson or csv", ErrCodeInvalidParameter)
+		return nil, false, false
+	}
+	q = db.ReadDB().Model(model).Scopes(tenantScope(r))
+	for _, p := range []struct{ name, op string }{{"from", ">="}, {"to", "<="}} {
+		v := qs.Get(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			respondError(w, r, 400, "invalid "+p.name, ErrCodeInvalidParameter)
+			return nil, false, false
+		}
+		q = q.Where(timeCol+" "+p.op+" ?", t)
+	}
+	// probe one row past the cap so X-Truncated can be set before streaming
+	var probe []string
+	if err := q.Session(&gorm.Session{}).Offset(int(exportMaxRows)).Limit(1).Pluck("id", &probe).Error; err != nil {
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
+		return nil, false, false
+	}
+	return q.Session(&gorm.Session{}).Limit(int(exportMaxRows)), len(probe) > 0, true
+}
+
+// exportWriter writes export rows as NDJSON (one JSON object per line) or CSV, flushing
+// every 1000 rows so the dataset is never held in memory.
+type exportWriter struct {
+	w   http.ResponseWriter
+	enc *json.Encoder
+	cw  *csv.Writer
+	n   int
+}
+
+func newExportWriter(w http.ResponseWriter, r *http.Request, dataset string, header []string, truncated bool) *exportWriter {
+	qs := r.URL.Query()
+	from, to := qs.Get("from"), qs.Get("to")
+	if from == "" {
+		from = "start"
+	}
+	if to == "" {
+		to = "now"
+	}
+	ext := "ndjson"
+	ew := &exportWriter{w: w}
+	if qs.Get("format") == "csv" {
+		ext = "csv"
+		w.Header().Set("Content-Type", "text/csv")
+		ew.cw = csv.NewWriter(w)
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		ew.enc = json.NewEncoder(w)
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "hermes-"+dataset+"-"+from+"-"+to+"."+ext))
+	w.Header().Set("Cache-Control", "no-store")
+	if truncated {
+		w.Header().Set("X-Truncated", "true")
+	}
+	if ew.cw != nil {
+		ew.cw.Write(header)
+	}
+	return ew
+}
+
+func (ew *exportWriter) write(v any, rec []string) {
+	if ew.cw != nil {
+		ew.cw.Write(rec)
+	} else {
+		ew.enc.Encode(v)
+	}
+	if ew.n++; ew.n%1000 == 0 {
+		ew.flush()
+	}
+}
+
+func (ew *exportWriter) flush() {
+	if ew.cw != nil {
+		ew.cw.Flush()
+	}
+	if fl, ok := ew.w.(http.Flusher); ok {
+		fl.Flush()
+	}
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+	"github.com/arencloud/hermes/internal/s3"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+func registerFailover(r chi.Router) {
+	r.With(requireEditorOrAdmin).Get("/providers/{id}/failover", getFailover)
+	r.With(requireAdmin).Post("/providers/{id}/failover/switch", switchFailover)
+}
+
+// recordFailover is the s3.OnFailover hook: it logs the switch and updates the provider's health row.
+func recordFailover(providerID uint, from, to string, cause error) {
+	apiLogger.Error("provider_warn", "msg", "provider failed over", "provider", providerID, "from", from, "to", to, "error", cause)
+	now := time.Now().UTC()
+	err := updateHealth(providerID, func(h *models.ProviderHealth) {
+		h.CurrentEndpoint = to
+		h.FailoverCount++
+		h.LastFailoverAt = &now
+		h.LastError = cause.Error()
+	})
+	if err != nil {
+		apiLogger.Error("provider health update failed", "provider", providerID, "error", err)
+	}
+}
+
+// updateHealth loads (or starts) the provider's health row, applies fn and saves it.
+func updateHealth(providerID uint, fn func(h *models.ProviderHealth)) error {
+	var h models.ProviderHealth
+	if err := db.DB.First(&h, "provider_id = ?", providerID).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		h = models.ProviderHealth{ProviderID: providerID}
+	}
+	fn(&h)
+	return db.DB.Save(&h).Error
+}
+
+// providerParam loads the provider named by the {id} URL parameter, writing 400/404 itself.
+func providerParam(w http.ResponseWriter, r *http.Request) (*models.Provider, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id <= 0 {
+		respondError(w, r, 400, "invalid provider id")
+		return nil, false
+	}
+	var p models.Provider
+	if err := db.DB.First(&p, id).Error; err != nil {
+		respondError(w, r, 404, "provider not found")
+		return nil, false
+	}
+	return &p, true
+}
+
+// getFailover reports the endpoint serving the provider and its failover history.
+func getFailover(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	p, ok := providerParam(w, r)
+	if !ok {
+		return
+	}
+	h := models.ProviderHealth{ProviderID: p.ID}
+	if err := readDB(r).First(&h, "provider_id = ?", p.ID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		respondError(w, r, 500, err.Error())
+		return
+	}
+	h.CurrentEndpoint = s3.ActiveEndpoint(*p)
+	Respond(w, r, 200, map[string]any{"failoverEnabled": p.FailoverEnabled, "health": h})
+}
+
+// switchFailover moves a failover-enabled provider to its other endpoint without waiting for an error.
+func switchFailover(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	p, ok := providerParam(w, r)
+	if !ok {
+		return
+	}
+	if !p.FailoverEnabled || p.SecondaryEndpoint == "" {
+		respondError(w, r, 409, "failover is not enabled for this provider")
+		return
+	}
+	from := s3.ActiveEndpoint(*p)
+	to := s3.SwitchEndpoint(*p)
+	if err := updateHealth(p.ID, func(h *models.ProviderHealth) { h.CurrentEndpoint = to }); err != nil {
+		respondError(w, r, 500, err.Error())
+		return
+	}
+	addEvent(r, "provider.failover.switch", map[string]any{"provider": p.ID, "from": from, "to": to})
+	Respond(w, r, 200, map[string]any{"currentEndpoint": to})
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/logging"
+	"github.com/arencloud/hermes/internal/worker"
+)
+
+// jobPool executes background work (periodic jobs and async tasks); nil until StartJobs.
+var jobPool *worker.Pool
+
+// StartJobs launches the periodic background jobs. It is called once from main;
+// tests exercise the individual job functions directly.
+func StartJobs(logger logging.Logger) {
+	jobPool = worker.New(4, 64, logger)
+	go every(time.Minute, "metrics.sample", logger, sampleMetrics)
+	go every(time.Hour, "metrics.rollup", logger, func() error { return db.RollupMetrics(db.DB, time.Now()) })
+	go every(time.Hour, "trash.purge", logger, purgeTrash)
+	go every(24*time.Hour, "object_stats.purge", logger, purgeObjectStats)
+	go every(time.Hour, "quota.reset", logger, resetQuotas) // usage also rolls over lazily on access
+	go every(time.Minute, "provider.health", logger, checkProviders)
+	go every(24*time.Hour, "bucket_summary.recompute", logger, recomputeBucketSummaries)
+	go every(time.Hour, "reindex_bucket", logger, reindexBuckets)
+	go every(15*time.Minute, "session.prune", logger, pruneSessions)
+	go every(24*time.Hour, "audit.prune", logger, pruneAudit)
+	go every(time.Minute, "ratelimit.evict", logger, evictRateLimits)
+}
+
+// StopJobs stops scheduling periodic jobs and waits for the running ones until ctx ends.
+func StopJobs(ctx context.Context) error {
+	if jobPool == nil {
+		return nil
+	}
+	return jobPool.Shutdown(ctx)
+}
+
+// every submits fn to the job pool on a fixed interval until the process exits,
+// logging failures. A tick is skipped while the previous run is still going.
+func every(d time.Duration, name string, logger logging.Logger, fn func() error) {
+	t := time.NewTicker(d)
+	defer t.Stop()
+	var running atomic.Bool
+	for range t.C {
+		if !running.CompareAndSwap(false, true) {
+			continue
+		}
+		err := jobPool.Submit(context.Background(), func() {
+			defer running.Store(false)
+			if err := fn(); err != nil {
+				logger.Error("job failed", "job", name, "error", err)
+			}
+		})
+		if errors.Is(err, worker.ErrClosed) {
+			return
+		}
+		if err != nil {
+			running.Store(false)
+			logger.Error("job not scheduled", "job", name, "error", err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/arencloud/hermes/internal/models"
+)
+
+// obsPushInterval is how often /obs/live pushes a summary (set in Router).
+var obsPushInterval = 5 * time.Second
+
+// liveConnLimit caps concurrent /obs/live streams; liveConns counts the open ones.
+var liveConnLimit int32 = 20
+var liveConns atomic.Int32
+
+const (
+	liveWindowSec = 300  // seconds of completed traces kept for the live feed
+	liveSlotCap   = 1000 // traces kept per second; extra traces in a busy second are dropped
+)
+
+// liveWindow keeps the traces completed in the last liveWindowSec seconds: a ring of
+// per-second slots, each holding that second's traces.
+type liveWindow struct {
+	mu    sync.Mutex
+	slots [liveWindowSec]liveSlot
+}
+
+type liveSlot struct {
+	sec    int64
+	traces []*Trace
+}
+
+var live = &liveWindow{}
+
+func (lw *liveWindow) add(t *Trace) {
+	sec := t.Ended.Unix()
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	s := &lw.slots[sec%liveWindowSec]
+	if s.sec != sec {
+		// slot still holds a second that has left the window
+		s.sec = sec
+		s.traces = s.traces[:0]
+	}
+	if len(s.traces) < liveSlotCap {
+		s.traces = append(s.traces, t)
+	}
+}
+
+// between returns the traces that ended in (from, to], newest first.
+func (lw *liveWindow) between(from, to time.Time) []*Trace {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	var out []*Trace
+	for sec := to.Unix(); sec >= from.Unix() && sec > to.Unix()-liveWindowSec; sec-- {
+		s := &lw.slots[sec%liveWindowSec]
+		if s.sec != sec {
+			continue
+		}
+		for i := len(s.traces) - 1; i >= 0; i-- {
+			if e := s.traces[i].Ended; e.After(from) && !e.After(to) {
+				out = append(out, s.traces[i])
+			}
+		}
+	}
+	return out
+}
+
+// obsLive streams an obsSummary-shaped object over SSE every obsPushInterval, computed
+// from the traces completed since the previous frame. ?since=<unix_ts> holds frames
+// back until that time, so a reconnecting client can resume where it left off.
+func obsLive(w http.ResponseWriter, r *http.Request) {
+	if liveConns.Add(1) > liveConnLimit {
+		liveConns.Add(-1)
+		respondError(w, r, http.StatusServiceUnavailable, "too many live connections")
+		return
+	}
+	defer liveConns.Add(-1)
+	fl, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", 500)
+		return
+	}
+	var since int64
+	if v := r.URL.Query().Get("since"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			respondError(w, r, 400, "since must be a unix timestamp")
+			return
+		}
+		since = n
+	}
+	ti, _ := r.Context().Value(tenantCtxKey{}).(tenantInfo)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(200)
+	fl.Flush()
+	tick := time.NewTicker(obsPushInterval)
+	defer tick.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case now := <-tick.C:
+			trs := live.between(last, now)
+			last = now
+			if now.Unix() < since {
+				continue
+			}
+			rows := make([]models.TraceRow, 0, len(trs))
+			msgs := map[string]string{}
+			for _, t := range trs {
+				if multiTenant && !ti.all && t.TenantID != ti.id {
+					continue
+				}
+				rows = append(rows, models.TraceRow{ID: t.ID, Path: t.Path, Status: t.Status, Started: t.Started, DurationNs: int64(t.Duration)})
+				msgs[t.ID] = lastErrorEvent(t)
+			}
+			b, _ := json.Marshal(summarizeTraces(rows, defaultSummaryWindow, func(id string) string { return msgs[id] }))
+			w.Write([]byte("data: "))
+			w.Write(b)
+			w.Write([]byte("\n\n"))
+			fl.Flush()
+		}
+	}
+}
+
+// lastErrorEvent returns the message of the trace's last "error" event.
+func lastErrorEvent(t *Trace) string {
+	for i := len(t.Events) - 1; i >= 0; i-- {
+		if t.Events[i].Name == "error" {
+			msg, _ := t.Events[i].fields()["message"].(string)
+			return msg
+		}
+	}
+	return ""
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arencloud/hermes/internal/config"
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+)
+
+func TestLiveWindowBetween(t *testing.T) {
+	lw := &liveWindow{}
+	base := time.Unix(1_700_000_000, 0)
+	for i, id := range []string{"a", "b", "c"} {
+		lw.add(&Trace{ID: id, Ended: base.Add(time.Duration(i) * time.Second)})
+	}
+	got := lw.between(base, base.Add(2*time.Second))
+	if len(got) != 2 || got[0].ID != "c" || got[1].ID != "b" {
+		t.Fatalf("between = %v", got)
+	}
+	// a trace one full window later reuses the slot of "a"
+	lw.add(&Trace{ID: "d", Ended: base.Add(liveWindowSec * time.Second)})
+	if got := lw.between(base.Add(-time.Second), base); len(got) != 0 {
+		t.Fatalf("expired slot still returned %v", got)
+	}
+}
+
+func TestObsLiveStream(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	prevInterval, prevLimit := obsPushInterval, liveConnLimit
+	obsPushInterval, liveConnLimit = 50*time.Millisecond, 1
+	defer func() { obsPushInterval, liveConnLimit = prevInterval, prevLimit }()
+	cookie := loginAs(t, ts, "live@example.com", "viewer")
+	open := func() *http.Response {
+		req, _ := http.NewRequest("GET", ts.URL+"/api/v1/obs/live", nil)
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	resp := open()
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status=%d content-type=%q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	second := open()
+	second.Body.Close()
+	if second.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("second stream status=%d, want 503", second.StatusCode)
+	}
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "data: ") {
+		t.Fatalf("frame %q err=%v", line, err)
+	}
+	var frame map[string]any
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &frame); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"recentLatencies", "statusCounts", "perMinute", "topSlow", "topErrors"} {
+		if _, ok := frame[k]; !ok {
+			t.Fatalf("frame misses %s: %v", k, frame)
+		}
+	}
+}
+
+func TestObsSummaryWindow(t *testing.T) {
+	ts, _ := setupTestServer(t, func(c *config.Config) { c.TraceMemoryOnly = true })
+	defer ts.Close()
+	cookie := loginAs(t, ts, "window@example.com", "viewer")
+	now := time.Now().UTC()
+	for i, age := range []time.Duration{time.Minute, 30 * time.Minute, 3 * time.Hour, 3 * time.Hour, 48 * time.Hour} {
+		db.DB.Create(&models.TraceRow{ID: fmt.Sprintf("window-%d", i), Path: "/api/v1/providers", Status: 200 + 300*(i%2), Started: now.Add(-age)})
+	}
+	summary := func(window string) (int, map[string]any) {
+		req, _ := http.NewRequest("GET", ts.URL+"/api/v1/obs/summary?window="+window, nil)
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out map[string]any
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+	total := func(out map[string]any) (n int) {
+		for _, b := range out["perBucket"].([]any) {
+			n += int(b.(map[string]any)["count"].(float64))
+		}
+		return n
+	}
+	cases := []struct {
+		window      string
+		granularity float64
+		buckets     int
+		requests    int
+	}{
+		{"", 60, 12, 1},
+		{"1h", 300, 12, 2},
+		{"24h", 3600, 24, 4},
+		{"7d", 3600, 168, 5},
+	}
+	for _, tc := range cases {
+		code, out := summary(tc.window)
+		if code != 200 {
+			t.Fatalf("window=%q status=%d", tc.window, code)
+		}
+		if out["bucketGranularitySec"] != tc.granularity || len(out["perBucket"].([]any)) != tc.buckets || total(out) != tc.requests || out["truncated"] != false {
+			t.Fatalf("window=%q: granularity=%v buckets=%d requests=%d truncated=%v", tc.window, out["bucketGranularitySec"], len(out["perBucket"].([]any)), total(out), out["truncated"])
+		}
+		for _, b := range out["perBucket"].([]any) {
+			if ts := int64(b.(map[string]any)["ts"].(float64)); ts%int64(tc.granularity) != 0 {
+				t.Fatalf("window=%q: bucket %d not aligned", tc.window, ts)
+			}
+		}
+		if _, ok := out["perMinute"]; ok != (tc.granularity == 60) {
+			t.Fatalf("window=%q: perMinute present=%v", tc.window, ok)
+		}
+	}
+	if code, _ := summary("30s"); code != 400 {
+		t.Fatalf("invalid window status=%d", code)
+	}
+}
+
+// TestMetricsSeriesPastWindow checks that a short range far in the past is served from
+// the rolled-up points that still exist for it.
+func TestMetricsSeriesPastWindow(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	from := time.Now().UTC().Add(-5 * 24 * time.Hour).Truncate(time.Hour)
+	if err := db.DB.Create(&models.MetricPoint{Name: "series-past", Timestamp: from, Granularity: db.GranularityHour, Count: 60, Sum: 60, Avg: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	cookie := loginAs(t, ts, "series@example.com", "viewer")
+	q := fmt.Sprintf("?name=series-past&from=%s&to=%s", from.Format(time.RFC3339), from.Add(time.Hour).Format(time.RFC3339))
+	req, _ := http.NewRequest("GET", ts.URL+"/api/v1/obs/metrics/series"+q, nil)
+	req.AddCookie(cookie)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Granularity string           `json:"granularity"`
+		Points      []map[string]any `json:"points"`
+	}
+	json.NewDecoder(resp.Body).Decode(&out)
+	if resp.StatusCode != 200 || out.Granularity != db.GranularityHour || len(out.Points) != 1 {
+		t.Fatalf("status=%d granularity=%s points=%d, want hour with 1 point", resp.StatusCode, out.Granularity, len(out.Points))
+	}
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/go-chi/chi/v5"
+)
+
+func registerMigrations(r chi.Router) {
+	r.With(requireAdmin).Get("/admin/db/migrations", listMigrations)
+	r.With(requireSuperAdmin).Post("/admin/db/migrations/rollback", rollbackMigration)
+}
+
+// listMigrations returns the applied schema migrations, oldest first (admin).
+func listMigrations(w http.ResponseWriter, r *http.Request) {
+	ms, err := db.AppliedMigrations(db.DB)
+	if err != nil {
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
+		return
+	}
+	Respond(w, r, 200, ms)
+}
+
+// rollbackMigration undoes the last applied migration (admin). It is applied again on
+// the next start unless the release that added it is rolled back too.
+func rollbackMigration(w http.ResponseWriter, r *http.Request) {
+	m, err := db.RollbackLast(db.DB)
+	if errors.Is(err, db.ErrNoMigrations) || errors.Is(err, db.ErrIrreversible) {
+		respondError(w, r, 409, err.Error(), ErrCodeConflict)
+		return
+	}
+	if err != nil {
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
+		return
+	}
+	addEvent(r, "db.migration.rollback", map[string]any{"version": m.Version, "description": m.Description})
+	RecordAudit(r.Context(), "db.migration.rollback", "migration", m.Version, map[string]any{"description": m.Description})
+	Respond(w, r, 200, m)
+}
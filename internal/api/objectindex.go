@@ -0,0 +1,149 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+	"github.com/arencloud/hermes/internal/s3"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// indexingBuckets holds the "pid/bucket" keys whose reindex is running, so the status
+// endpoint can report it and overlapping runs of the same bucket are skipped.
+var indexingBuckets sync.Map
+
+func registerObjectIndex(r chi.Router) {
+	r.Get("/providers/{id}/buckets/{name}/index/status", objectIndexStatus)
+}
+
+func indexingKey(pid uint, bucket string) string { return fmt.Sprintf("%d/%s", pid, bucket) }
+
+// reindexBucket reconciles the bucket's ObjectIndex rows with a full listing: missing
+// objects are inserted, rows for deleted objects removed and changed ETags or sizes
+// updated. Every row still present gets IndexedAt set to the time of this run.
+func reindexBucket(ctx context.Context, c s3.ClientInterface, pid uint, bucket string) error {
+	key := indexingKey(pid, bucket)
+	if _, running := indexingBuckets.LoadOrStore(key, struct{}{}); running {
+		return nil
+	}
+	defer indexingBuckets.Delete(key)
+
+	items, err := c.ListObjects(ctx, bucket, "", true)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		var rows []models.ObjectIndex
+		if err := tx.Where("provider_id = ? AND bucket = ?", pid, bucket).Find(&rows).Error; err != nil {
+			return err
+		}
+		existing := make(map[string]models.ObjectIndex, len(rows))
+		for _, row := range rows {
+			existing[row.Key] = row
+		}
+		var inserts []models.ObjectIndex
+		for _, o := range items {
+			row, ok := existing[o.Key]
+			if !ok {
+				inserts = append(inserts, models.ObjectIndex{ProviderID: pid, Bucket: bucket, Key: o.Key, Size: o.Size, ETag: o.ETag, LastModified: o.LastModified, IndexedAt: now})
+				continue
+			}
+			delete(existing, o.Key)
+			if row.ETag != o.ETag || row.Size != o.Size {
+				err := tx.Model(&models.ObjectIndex{}).Where("id = ?", row.ID).Updates(map[string]any{
+					"e_tag": o.ETag, "size": o.Size, "last_modified": o.LastModified, "indexed_at": now,
+				}).Error
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if len(inserts) > 0 {
+			if err := tx.CreateInBatches(inserts, 500).Error; err != nil {
+				return err
+			}
+		}
+		orphans := make([]uint, 0, len(existing))
+		for _, row := range existing {
+			orphans = append(orphans, row.ID)
+		}
+		for len(orphans) > 0 {
+			n := min(len(orphans), 500)
+			if err := tx.Delete(&models.ObjectIndex{}, orphans[:n]).Error; err != nil {
+				return err
+			}
+			orphans = orphans[n:]
+		}
+		// unchanged rows were verified by this listing too
+		return tx.Model(&models.ObjectIndex{}).Where("provider_id = ? AND bucket = ?", pid, bucket).Update("indexed_at", now).Error
+	})
+}
+
+// reindexBuckets refreshes the object index of every known bucket (periodic job).
+func reindexBuckets() error {
+	var buckets []models.Bucket
+	if err := db.DB.Find(&buckets).Error; err != nil {
+		return err
+	}
+	var errs []error
+	for _, b := range buckets {
+		c, _, err := getClient(int(b.ProviderID))
+		if err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+			err = reindexBucket(ctx, c, b.ProviderID, b.Name)
+			cancel()
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("provider %d bucket %s: %w", b.ProviderID, b.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// objectIndexStatus reports how many objects the bucket's index holds and how old it is;
+// it answers 202 while a reindex of the bucket is running.
+func objectIndexStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	pid, bucket, ok := aclParams(w, r)
+	if !ok || !enforceACL(w, r, int(pid), bucket, aclRead) {
+		return
+	}
+	q := func() *gorm.DB {
+		return readDB(r).Model(&models.ObjectIndex{}).Where("provider_id = ? AND bucket = ?", pid, bucket)
+	}
+	var count int64
+	if err := q().Count(&count).Error; err != nil {
+		respondError(w, r, 500, err.Error())
+		return
+	}
+	var lastIndexedAt *time.Time
+	var staleSince *int64
+	if count > 0 {
+		var latest models.ObjectIndex
+		if err := q().Order("indexed_at DESC").Limit(1).Find(&latest).Error; err != nil {
+			respondError(w, r, 500, err.Error())
+			return
+		}
+		secs := int64(time.Since(latest.IndexedAt).Seconds())
+		lastIndexedAt, staleSince = &latest.IndexedAt, &secs
+	}
+	_, indexing := indexingBuckets.Load(indexingKey(pid, bucket))
+	code := 200
+	if indexing {
+		code = 202
+	}
+	Respond(w, r, code, map[string]any{
+		"indexedObjects":    count,
+		"lastIndexedAt":     lastIndexedAt,
+		"staleSinceSeconds": staleSince,
+		"indexing":          indexing,
+	})
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/arencloud/hermes/internal/s3"
+	"github.com/go-chi/chi/v5"
+)
+
+// objectTagsBody is both the response of GET and the body of PUT .../objects/tags.
+type objectTagsBody struct {
+	Tags map[string]string `json:"tags"`
+}
+
+// objectTarget reads the provider, bucket and key of an object route and checks the
+// caller's bucket permission; ok is false once an error has been written.
+func objectTarget(w http.ResponseWriter, r *http.Request, perm string) (c s3.ClientInterface, bucket, key string, ok bool) {
+	pid, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || pid <= 0 {
+		respondError(w, r, 400, "invalid provider id", ErrCodeInvalidID)
+		return nil, "", "", false
+	}
+	bucket = chi.URLParam(r, "name")
+	if !enforceACL(w, r, pid, bucket, perm) {
+		return nil, "", "", false
+	}
+	key = r.URL.Query().Get("key")
+	if key == "" {
+		respondError(w, r, 400, "key is required", ErrCodeMissingField)
+		return nil, "", "", false
+	}
+	c, _, err = getClient(pid)
+	if err != nil {
+		respondError(w, r, 404, "provider not found", ErrCodeProviderNotFound)
+		return nil, "", "", false
+	}
+	return c, bucket, key, true
+}
+
+// getObjectTags returns the object's user-defined tags.
+func getObjectTags(w http.ResponseWriter, r *http.Request) {
+	c, bucket, key, ok := objectTarget(w, r, aclRead)
+	if !ok {
+		return
+	}
+	tags, err := c.GetObjectTags(r.Context(), bucket, key)
+	if err != nil {
+		code, msg := s3Failure(r, err)
+		respondError(w, r, code, msg, storageErrCode(code, msg))
+		return
+	}
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	Respond(w, r, 200, objectTagsBody{Tags: tags})
+}
+
+// putObjectTags replaces the object's tags with the given set; {"tags":{}} removes them.
+func putObjectTags(w http.ResponseWriter, r *http.Request) {
+	c, bucket, key, ok := objectTarget(w, r, aclWrite)
+	if !ok {
+		return
+	}
+	var in objectTagsBody
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondError(w, r, 400, err.Error(), ErrCodeInvalidRequest)
+		return
+	}
+	if err := s3.ValidateObjectTags(in.Tags); err != nil {
+		respondError(w, r, 400, err.Error(), ErrCodeValidation)
+		return
+	}
+	if err := c.SetObjectTags(r.Context(), bucket, key, in.Tags); err != nil {
+		code, msg := s3Failure(r, err)
+		respondError(w, r, code, msg, storageErrCode(code, msg))
+		return
+	}
+	addEvent(r, "object.tags.set", map[string]any{"bucket": bucket, "key": key, "tags": len(in.Tags)})
+	if in.Tags == nil {
+		in.Tags = map[string]string{}
+	}
+	Respond(w, r, 200, in)
+}
+
+// getObjectMeta returns the object's content type, size, ETag, modification time and
+// user metadata.
+func getObjectMeta(w http.ResponseWriter, r *http.Request) {
+	c, bucket, key, ok := objectTarget(w, r, aclRead)
+	if !ok {
+		return
+	}
+	meta, err := c.GetObjectMeta(r.Context(), bucket, key)
+	if err != nil {
+		code, msg := s3Failure(r, err)
+		respondError(w, r, code, msg, storageErrCode(code, msg))
+		return
+	}
+	Respond(w, r, 200, meta)
+}
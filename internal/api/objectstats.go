@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+	"github.com/arencloud/hermes/internal/s3"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+func registerObjectStats(r chi.Router) {
+	r.Group(func(gr chi.Router) {
+		gr.Use(requireEditorOrAdmin)
+		gr.Get("/providers/{id}/buckets/{name}/objects/popular", popularObjects)
+		gr.Get("/providers/{id}/buckets/{name}/objects/stats", objectStats)
+	})
+}
+
+// recordDownload bumps the download counters of an object, creating its row on first download.
+func recordDownload(pid int, bucket, key string, bytes int64) error {
+	now := time.Now().UTC()
+	upd := func() (int64, error) {
+		res := db.DB.Model(&models.ObjectStat{}).
+			Where("provider_id = ? AND bucket = ? AND key = ?", pid, bucket, key).
+			Updates(map[string]any{
+				"download_count":     gorm.Expr("download_count + 1"),
+				"total_bytes_served": gorm.Expr("total_bytes_served + ?", bytes),
+				"last_downloaded_at": now,
+			})
+		return res.RowsAffected, res.Error
+	}
+	n, err := upd()
+	if err != nil || n > 0 {
+		return err
+	}
+	st := models.ObjectStat{ProviderID: uint(pid), Bucket: bucket, Key: key, DownloadCount: 1, TotalBytesServed: bytes, LastDownloadedAt: now}
+	if err := db.DB.Create(&st).Error; err != nil {
+		// lost the race against a concurrent first download: the row exists now
+		_, err = upd()
+		return err
+	}
+	return nil
+}
+
+// popularObjects returns the most downloaded objects of a bucket, by count (default) or bytes served.
+func popularObjects(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	pid, bucket, ok := aclParams(w, r)
+	if !ok {
+		return
+	}
+	if !enforceACL(w, r, int(pid), bucket, aclRead) {
+		return
+	}
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 && i <= 1000 {
+			limit = i
+		}
+	}
+	order := "download_count desc"
+	switch r.URL.Query().Get("sortBy") {
+	case "", "count":
+	case "bytes":
+		order = "total_bytes_served desc"
+	default:
+		respondError(w, r, 400, "sortBy must be count or bytes")
+		return
+	}
+	var rows []models.ObjectStat
+	if err := readDB(r).Where("provider_id = ? AND bucket = ?", pid, bucket).Order(order).Order("id").Limit(limit).Find(&rows).Error; err != nil {
+		respondError(w, r, 500, err.Error())
+		return
+	}
+	RespondList(w, r, 200, rows, len(rows), limit, 0)
+}
+
+// objectStats returns the download counters of a single key; zero counters if it was never downloaded.
+func objectStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	pid, bucket, ok := aclParams(w, r)
+	if !ok {
+		return
+	}
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		respondError(w, r, 400, "key is required")
+		return
+	}
+	if !enforceACL(w, r, int(pid), bucket, aclRead) {
+		return
+	}
+	st := models.ObjectStat{ProviderID: pid, Bucket: bucket, Key: key}
+	if err := readDB(r).Where("provider_id = ? AND bucket = ? AND key = ?", pid, bucket, key).Limit(1).Find(&st).Error; err != nil {
+		respondError(w, r, 500, err.Error())
+		return
+	}
+	Respond(w, r, 200, st)
+}
+
+// purgeObjectStats drops counters of objects that no longer exist on their provider.
+func purgeObjectStats() error {
+	if db.DB == nil {
+		return nil
+	}
+	var rows []models.ObjectStat
+	if err := db.DB.Find(&rows).Error; err != nil {
+		return err
+	}
+	type providerClient struct {
+		c   s3.ClientInterface
+		err error
+	}
+	clients := map[uint]providerClient{}
+	for _, st := range rows {
+		pc, ok := clients[st.ProviderID]
+		if !ok {
+			pc.c, _, pc.err = getClient(int(st.ProviderID))
+			clients[st.ProviderID] = pc
+		}
+		if errors.Is(pc.err, gorm.ErrRecordNotFound) {
+			// provider was removed along with everything in it
+			_ = db.DB.Delete(&models.ObjectStat{}, st.ID).Error
+			continue
+		}
+		if pc.c == nil {
+			continue
+		}
+		c := pc.c
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		_, err := c.Stat(ctx, st.Bucket, st.Key)
+		cancel()
+		if err != nil && s3.IsNotFound(err) {
+			_ = db.DB.Delete(&models.ObjectStat{}, st.ID).Error
+		}
+	}
+	return nil
+}
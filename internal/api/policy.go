@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/arencloud/hermes/internal/s3"
+	"github.com/go-chi/chi/v5"
+)
+
+// maxPolicyBytes bounds a policy document; S3 itself caps bucket policies at 20 KB.
+const maxPolicyBytes = 20 << 10
+
+func registerBucketPolicy(r chi.Router) {
+	r.Group(func(gr chi.Router) {
+		gr.Use(requireEditorOrAdmin)
+		gr.Get("/providers/{id}/buckets/{name}/policy", getBucketPolicy)
+		gr.Put("/providers/{id}/buckets/{name}/policy", putBucketPolicy)
+		gr.Delete("/providers/{id}/buckets/{name}/policy", deleteBucketPolicy)
+	})
+}
+
+// policyClient resolves the provider client for a policy request and checks perm on the
+// bucket, writing the error response itself.
+func policyClient(w http.ResponseWriter, r *http.Request, perm string) (s3.ClientInterface, string, bool) {
+	pid, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || pid <= 0 {
+		respondError(w, r, 400, "invalid provider id")
+		return nil, "", false
+	}
+	if !enforceACL(w, r, pid, chi.URLParam(r, "name"), perm) {
+		return nil, "", false
+	}
+	c, _, err := getClient(pid)
+	if err != nil {
+		respondError(w, r, 404, "provider not found")
+		return nil, "", false
+	}
+	return c, chi.URLParam(r, "name"), true
+}
+
+func respondPolicyError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, s3.ErrPolicyNotSupported) {
+		respondError(w, r, http.StatusNotImplemented, err.Error())
+		return
+	}
+	code, msg := s3Failure(r, err)
+	respondError(w, r, code, msg, storageErrCode(code, msg))
+}
+
+// getBucketPolicy returns the raw policy JSON; an empty body means no policy is set.
+func getBucketPolicy(w http.ResponseWriter, r *http.Request) {
+	c, bucket, ok := policyClient(w, r, aclRead)
+	if !ok {
+		return
+	}
+	p, err := c.GetBucketPolicy(r.Context(), bucket)
+	if err != nil {
+		respondPolicyError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(p))
+}
+
+// putBucketPolicy replaces the policy with the raw IAM policy JSON in the request body.
+func putBucketPolicy(w http.ResponseWriter, r *http.Request) {
+	c, bucket, ok := policyClient(w, r, aclWrite)
+	if !ok {
+		return
+	}
+	b, err := io.ReadAll(io.LimitReader(r.Body, maxPolicyBytes+1))
+	if err != nil {
+		respondError(w, r, 400, err.Error())
+		return
+	}
+	if len(b) > maxPolicyBytes {
+		respondError(w, r, 413, "policy too large")
+		return
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		respondError(w, r, 400, "policy must be a JSON object: "+err.Error())
+		return
+	}
+	if err := c.SetBucketPolicy(r.Context(), bucket, string(b)); err != nil {
+		respondPolicyError(w, r, err)
+		return
+	}
+	addEvent(r, "bucket.policy.set", map[string]any{"bucket": bucket})
+	RecordAudit(r.Context(), "bucket.policy.set", "bucket", chi.URLParam(r, "id")+"/"+bucket, map[string]any{"policy": doc})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"ok": true})
+}
+
+func deleteBucketPolicy(w http.ResponseWriter, r *http.Request) {
+	c, bucket, ok := policyClient(w, r, aclWrite)
+	if !ok {
+		return
+	}
+	if err := c.SetBucketPolicy(r.Context(), bucket, ""); err != nil {
+		respondPolicyError(w, r, err)
+		return
+	}
+	addEvent(r, "bucket.policy.delete", map[string]any{"bucket": bucket})
+	RecordAudit(r.Context(), "bucket.policy.delete", "bucket", chi.URLParam(r, "id")+"/"+bucket, nil)
+	w.WriteHeader(204)
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// maxPrefsBytes bounds the stored preferences document of a user.
+const maxPrefsBytes = 16 << 10
+
+// registerPreferences exposes the current user's UI settings; there is no way to
+// address another user's preferences.
+func registerPreferences(r chi.Router) {
+	r.Get("/me/preferences", getPreferences)
+	r.Put("/me/preferences", putPreferences)
+	r.Patch("/me/preferences", patchPreferences)
+}
+
+// loadPrefs returns the stored preferences of a user, or an empty map when unset.
+func loadPrefs(userID uint) (map[string]any, error) {
+	var p models.UserPreference
+	if err := db.DB.First(&p, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return map[string]any{}, nil
+		}
+		return nil, err
+	}
+	out := map[string]any{}
+	if p.Prefs != "" {
+		if err := json.Unmarshal([]byte(p.Prefs), &out); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func savePrefs(userID uint, prefs map[string]any) error {
+	b, err := json.Marshal(prefs)
+	if err != nil {
+		return err
+	}
+	if len(b) >= maxPrefsBytes {
+		return errPrefsTooLarge
+	}
+	return db.DB.Save(&models.UserPreference{UserID: userID, Prefs: string(b)}).Error
+}
+
+var errPrefsTooLarge = errors.New("preferences must be smaller than 16 KB")
+
+// readPrefsBody decodes the request body as a JSON object, writing the error response itself.
+func readPrefsBody(w http.ResponseWriter, r *http.Request) (map[string]any, bool) {
+	b, err := io.ReadAll(io.LimitReader(r.Body, maxPrefsBytes))
+	if err != nil {
+		respondError(w, r, 400, err.Error())
+		return nil, false
+	}
+	if len(b) >= maxPrefsBytes {
+		respondError(w, r, 413, errPrefsTooLarge.Error())
+		return nil, false
+	}
+	var in map[string]any
+	if err := json.Unmarshal(b, &in); err != nil || in == nil {
+		respondError(w, r, 400, "preferences must be a JSON object")
+		return nil, false
+	}
+	return in, true
+}
+
+func writePrefs(w http.ResponseWriter, r *http.Request, userID uint, prefs map[string]any) {
+	if err := savePrefs(userID, prefs); err != nil {
+		if errors.Is(err, errPrefsTooLarge) {
+			respondError(w, r, 413, err.Error())
+			return
+		}
+		respondError(w, r, 500, err.Error())
+		return
+	}
+	Respond(w, r, 200, prefs)
+}
+
+func getPreferences(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	u := currentUser(r)
+	prefs, err := loadPrefs(u.ID)
+	if err != nil {
+		respondError(w, r, 500, err.Error())
+		return
+	}
+	Respond(w, r, 200, prefs)
+}
+
+// putPreferences replaces the stored preferences with the request body.
+func putPreferences(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	u := currentUser(r)
+	in, ok := readPrefsBody(w, r)
+	if !ok {
+		return
+	}
+	writePrefs(w, r, u.ID, in)
+}
+
+// patchPreferences merges the request body into the stored preferences (JSON merge
+// patch: nested objects are merged key by key and null removes a key).
+func patchPreferences(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	u := currentUser(r)
+	in, ok := readPrefsBody(w, r)
+	if !ok {
+		return
+	}
+	prefs, err := loadPrefs(u.ID)
+	if err != nil {
+		respondError(w, r, 500, err.Error())
+		return
+	}
+	writePrefs(w, r, u.ID, mergePrefs(prefs, in))
+}
+
+func mergePrefs(dst, patch map[string]any) map[string]any {
+	for k, v := range patch {
+		if v == nil {
+			delete(dst, k)
+			continue
+		}
+		if pm, ok := v.(map[string]any); ok {
+			if dm, ok := dst[k].(map[string]any); ok {
+				dst[k] = mergePrefs(dm, pm)
+				continue
+			}
+			dst[k] = mergePrefs(map[string]any{}, pm)
+			continue
+		}
+		dst[k] = v
+	}
+	return dst
+}
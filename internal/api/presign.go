@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// presigned URL lifetimes; maxPresignExpiry is set in Router (MAX_PRESIGN_EXPIRY_HOURS)
+const defaultPresignExpiry = time.Hour
+
+var maxPresignExpiry = 24 * time.Hour
+
+// presignExpiry parses a requested URL lifetime, given as a Go duration ("15m") or whole
+// seconds. Empty means defaultPresignExpiry; longer than max is capped to max.
+func presignExpiry(raw string, max time.Duration) (time.Duration, error) {
+	d := defaultPresignExpiry
+	if raw != "" {
+		var err error
+		if n, nerr := strconv.ParseInt(raw, 10, 64); nerr == nil {
+			d = time.Duration(n) * time.Second
+		} else if d, err = time.ParseDuration(raw); err != nil {
+			return 0, errors.New("expiry must be a duration like 15m or a number of seconds")
+		}
+		if d <= 0 {
+			return 0, errors.New("expiry must be positive")
+		}
+	}
+	if d > max {
+		d = max
+	}
+	return d, nil
+}
+
+// presignedURLResponse is returned by both presign endpoints.
+type presignedURLResponse struct {
+	URL       string    `json:"url"`
+	Method    string    `json:"method"`
+	Key       string    `json:"key"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// presignDownload returns a URL that downloads an object straight from the provider,
+// without credentials, until it expires.
+func presignDownload(w http.ResponseWriter, r *http.Request) {
+	pid, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || pid <= 0 {
+		respondError(w, r, 400, "invalid provider id", ErrCodeInvalidID)
+		return
+	}
+	bucket := chi.URLParam(r, "name")
+	if !enforceACL(w, r, pid, bucket, aclRead) {
+		return
+	}
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		respondError(w, r, 400, "key is required", ErrCodeMissingField)
+		return
+	}
+	if strings.HasSuffix(key, "/") {
+		respondError(w, r, 400, "key is a folder", ErrCodeInvalidRequest)
+		return
+	}
+	expiry, err := presignExpiry(r.URL.Query().Get("expiry"), maxPresignExpiry)
+	if err != nil {
+		respondError(w, r, 400, err.Error(), ErrCodeInvalidParameter)
+		return
+	}
+	c, prov, err := getClient(pid)
+	if err != nil {
+		respondError(w, r, 404, "provider not found", ErrCodeProviderNotFound)
+		return
+	}
+	// the transfer bypasses Hermes, so it cannot be metered; a provider over its download
+	// quota does not hand out links either
+	if !checkQuota(w, r, prov, false, 0) {
+		return
+	}
+	u, err := c.PresignedURL(r.Context(), bucket, key, expiry)
+	if err != nil {
+		code, msg := s3Failure(r, err)
+		respondError(w, r, code, msg, storageErrCode(code, msg))
+		return
+	}
+	addEvent(r, "object.presign", map[string]any{"bucket": bucket, "key": key, "method": "GET", "expirySec": int(expiry.Seconds())})
+	Respond(w, r, 200, presignedURLResponse{URL: u, Method: "GET", Key: key, ExpiresAt: time.Now().Add(expiry).UTC()})
+}
+
+// presignUpload returns a URL accepting a PUT of the object body straight to the
+// provider, so large files need not pass through Hermes.
+func presignUpload(w http.ResponseWriter, r *http.Request) {
+	pid, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || pid <= 0 {
+		respondError(w, r, 400, "invalid provider id", ErrCodeInvalidID)
+		return
+	}
+	bucket := chi.URLParam(r, "name")
+	if !enforceACL(w, r, pid, bucket, aclWrite) {
+		return
+	}
+	var in struct {
+		Key    string `json:"key"`
+		Expiry string `json:"expiry"` // duration ("15m") or seconds
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondError(w, r, 400, err.Error(), ErrCodeInvalidRequest)
+		return
+	}
+	if in.Key == "" {
+		respondError(w, r, 400, "key is required", ErrCodeMissingField)
+		return
+	}
+	if strings.HasSuffix(in.Key, "/") {
+		respondError(w, r, 400, "key is a folder", ErrCodeInvalidRequest)
+		return
+	}
+	expiry, err := presignExpiry(in.Expiry, maxPresignExpiry)
+	if err != nil {
+		respondError(w, r, 400, err.Error(), ErrCodeInvalidParameter)
+		return
+	}
+	c, prov, err := getClient(pid)
+	if err != nil {
+		respondError(w, r, 404, "provider not found", ErrCodeProviderNotFound)
+		return
+	}
+	// a presigned PUT takes a body of any size, so a size limit could not be enforced
+	if objectSizeLimit(uint(pid), bucket) > 0 {
+		respondError(w, r, 409, "bucket has an object size limit; upload through Hermes instead", ErrCodeConflict)
+		return
+	}
+	if !checkQuota(w, r, prov, true, 0) {
+		return
+	}
+	u, err := c.PresignedPutURL(r.Context(), bucket, in.Key, expiry)
+	if err != nil {
+		code, msg := s3Failure(r, err)
+		respondError(w, r, code, msg, storageErrCode(code, msg))
+		return
+	}
+	addEvent(r, "object.presign", map[string]any{"bucket": bucket, "key": in.Key, "method": "PUT", "expirySec": int(expiry.Seconds())})
+	Respond(w, r, 200, presignedURLResponse{URL: u, Method: "PUT", Key: in.Key, ExpiresAt: time.Now().Add(expiry).UTC()})
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arencloud/hermes/internal/config"
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+)
+
+func TestPresignExpiry(t *testing.T) {
+	cases := []struct {
+		raw     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", time.Hour, false},
+		{"900", 15 * time.Minute, false},
+		{"15m", 15 * time.Minute, false},
+		{"72h", 24 * time.Hour, false}, // capped
+		{"0", 0, true},
+		{"-5m", 0, true},
+		{"soon", 0, true},
+	}
+	for _, tc := range cases {
+		got, err := presignExpiry(tc.raw, 24*time.Hour)
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Errorf("presignExpiry(%q) = %v, %v; want %v (error %v)", tc.raw, got, err, tc.want, tc.wantErr)
+		}
+	}
+	if got, _ := presignExpiry("", 30*time.Minute); got != 30*time.Minute {
+		t.Errorf("default above the maximum: %v, want 30m", got)
+	}
+}
+
+func TestPresignEndpoints(t *testing.T) {
+	prev := maxPresignExpiry
+	t.Cleanup(func() { maxPresignExpiry = prev })
+	ts, _ := setupTestServer(t, func(c *config.Config) { c.MaxPresignExpiryHours = 2 })
+	defer ts.Close()
+	m := useMockS3(t)
+	var gotExpiry time.Duration
+	m.OnPresignedURL = func(bucket, key string, expiry time.Duration) (string, error) {
+		gotExpiry = expiry
+		return "https://mock:9000/" + bucket + "/" + key + "?X-Amz-Signature=get", nil
+	}
+	m.OnPresignedPutURL = func(bucket, key string, expiry time.Duration) (string, error) {
+		gotExpiry = expiry
+		return "https://mock:9000/" + bucket + "/" + key + "?X-Amz-Signature=put", nil
+	}
+	pid := mockProvider(t)
+	viewer := loginAs(t, ts, "presign-viewer@example.com", "viewer")
+	editor := loginAs(t, ts, "presign-editor@example.com", "editor")
+	do := func(method, path, body string, c *http.Cookie) (int, presignedURLResponse) {
+		t.Helper()
+		req, _ := http.NewRequest(method, fmt.Sprintf("%s/api/v1/providers/%d/buckets/b/%s", ts.URL, pid, path), strings.NewReader(body))
+		req.AddCookie(c)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var env struct {
+			Data presignedURLResponse `json:"data"`
+		}
+		json.NewDecoder(resp.Body).Decode(&env)
+		return resp.StatusCode, env.Data
+	}
+
+	before := time.Now()
+	code, out := do("GET", "presign?key=docs/a.txt", "", viewer)
+	if code != 200 || out.Method != "GET" || out.Key != "docs/a.txt" || !strings.HasSuffix(out.URL, "=get") {
+		t.Fatalf("presign: %d %+v", code, out)
+	}
+	if gotExpiry != time.Hour || out.ExpiresAt.Before(before.Add(time.Hour-time.Second)) {
+		t.Fatalf("default expiry: %v, expiresAt %v", gotExpiry, out.ExpiresAt)
+	}
+	if code, _ := do("GET", "presign?key=a.txt&expiry=12h", "", viewer); code != 200 || gotExpiry != 2*time.Hour {
+		t.Fatalf("expiry above MAX_PRESIGN_EXPIRY_HOURS: %d %v, want capped to 2h", code, gotExpiry)
+	}
+	if code, _ := do("GET", "presign?key=a.txt&expiry=never", "", viewer); code != 400 {
+		t.Fatalf("invalid expiry: %d", code)
+	}
+	if code, _ := do("GET", "presign", "", viewer); code != 400 {
+		t.Fatalf("missing key: %d", code)
+	}
+
+	if code, _ := do("POST", "presign-upload", `{"key":"up.bin"}`, viewer); code != 403 {
+		t.Fatalf("viewer presign-upload: %d, want 403", code)
+	}
+	code, out = do("POST", "presign-upload", `{"key":"up.bin","expiry":"10m"}`, editor)
+	if code != 200 || out.Method != "PUT" || !strings.HasSuffix(out.URL, "=put") || gotExpiry != 10*time.Minute {
+		t.Fatalf("presign-upload: %d %+v expiry %v", code, out, gotExpiry)
+	}
+	if code, _ := do("POST", "presign-upload", `{}`, editor); code != 400 {
+		t.Fatalf("presign-upload without key: %d", code)
+	}
+	// the provider would accept any size, so buckets with a size limit refuse presigned uploads
+	if err := db.DB.Create(&models.Bucket{ProviderID: uint(pid), Name: "b", MaxObjectSizeBytes: 1 << 20}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := do("POST", "presign-upload", `{"key":"up.bin"}`, editor); code != 409 {
+		t.Fatalf("presign-upload to a size-limited bucket: %d, want 409", code)
+	}
+	maxUploadSizeBytes = 1 << 30
+	t.Cleanup(func() { maxUploadSizeBytes = 0 })
+	db.DB.Model(&models.Bucket{}).Where("provider_id = ? AND name = ?", pid, "b").Update("max_object_size_bytes", 0)
+	if code, _ := do("POST", "presign-upload", `{"key":"up.bin"}`, editor); code != 409 {
+		t.Fatalf("presign-upload under MAX_UPLOAD_SIZE_BYTES: %d, want 409", code)
+	}
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// Provider connectivity alerts (set in Router).
+var (
+	providerAlertAfter   = 3  // consecutive failed checks before provider.unreachable
+	providerAlertWebhook = "" // empty = alerts are only logged
+)
+
+const providerCheckTimeout = 10 * time.Second
+
+var alertClient = &http.Client{Timeout: 10 * time.Second}
+
+func registerProviderHealth(r chi.Router) {
+	r.With(requireEditorOrAdmin).Get("/providers/{id}/health", getProviderHealth)
+}
+
+// checkProviders lists the buckets of every provider as a connectivity check and records
+// the outcome, raising or clearing alerts.
+func checkProviders() error {
+	var providers []models.Provider
+	if err := db.DB.Find(&providers).Error; err != nil {
+		return err
+	}
+	for i := range providers {
+		p := &providers[i]
+		c, _, err := getClient(int(p.ID))
+		if err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), providerCheckTimeout)
+			_, err = c.ListBuckets(ctx)
+			cancel()
+		}
+		if err := recordHealthCheck(p, err, time.Now().UTC()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordHealthCheck updates the provider's failure streak. provider.unreachable is sent
+// once the streak reaches providerAlertAfter and not again until the provider recovers,
+// which sends provider.recovered.
+func recordHealthCheck(p *models.Provider, checkErr error, now time.Time) error {
+	var event string
+	var snapshot models.ProviderHealth
+	err := updateHealth(p.ID, func(h *models.ProviderHealth) {
+		h.LastCheckedAt = &now
+		if checkErr != nil {
+			h.ConsecutiveFailures++
+			h.LastError = checkErr.Error()
+			if h.AlertSentAt == nil && h.ConsecutiveFailures >= providerAlertAfter {
+				h.AlertSentAt = &now
+				event = "provider.unreachable"
+			}
+		} else {
+			if h.AlertSentAt != nil {
+				event = "provider.recovered"
+			}
+			h.AlertSentAt = nil
+			h.ConsecutiveFailures = 0
+		}
+		snapshot = *h
+	})
+	if err != nil {
+		return err
+	}
+	if event != "" {
+		sendProviderAlert(event, p, snapshot)
+	}
+	return nil
+}
+
+// sendProviderAlert logs the alert and posts it to the webhook when one is configured.
+func sendProviderAlert(event string, p *models.Provider, h models.ProviderHealth) {
+	payload := map[string]any{"event": event, "providerId": p.ID, "name": p.Name, "consecutiveFailures": h.ConsecutiveFailures}
+	if event == "provider.unreachable" {
+		payload["error"] = h.LastError
+		apiLogger.Error("provider_warn", "msg", "provider unreachable", "provider", p.ID, "name", p.Name, "failures", h.ConsecutiveFailures, "error", h.LastError)
+	} else {
+		apiLogger.Info("provider recovered", "provider", p.ID, "name", p.Name)
+	}
+	if providerAlertWebhook == "" {
+		return
+	}
+	b, _ := json.Marshal(payload)
+	resp, err := alertClient.Post(providerAlertWebhook, "application/json", bytes.NewReader(b))
+	if err != nil {
+		apiLogger.Error("provider alert delivery failed", "event", event, "provider", p.ID, "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		apiLogger.Error("provider alert delivery failed", "event", event, "provider", p.ID, "status", resp.StatusCode)
+	}
+}
+
+// getProviderHealth returns the provider's health row (zero values before the first check).
+func getProviderHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	p, ok := providerParam(w, r)
+	if !ok {
+		return
+	}
+	h := models.ProviderHealth{ProviderID: p.ID}
+	if err := readDB(r).First(&h, "provider_id = ?", p.ID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		respondError(w, r, 500, err.Error())
+		return
+	}
+	Respond(w, r, 200, h)
+}
@@ -1,18 +1,39 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"net"
 	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/middleware"
 	"github.com/arencloud/hermes/internal/models"
+	"github.com/arencloud/hermes/internal/s3"
 	"github.com/go-chi/chi/v5"
 )
 
+// providersCachePrefix keys the cached provider lists; provider changes invalidate them.
+const providersCachePrefix = "providers"
+
+// providerValidateOnSave makes create and update test the credentials before saving
+// (PROVIDER_VALIDATE_ON_CREATE, set in Router).
+var providerValidateOnSave bool
+
+// providerSaveCheckTimeout bounds the ListBuckets call of the save-time connectivity check.
+var providerSaveCheckTimeout = 5 * time.Second
+
 func registerProviders(r chi.Router) {
 	// Read-only provider endpoints available to any authenticated user (viewers need these to select provider)
-	r.Get("/providers", listProviders)
+	r.With(middleware.Cache(30*time.Second, cacheKey(providersCachePrefix))).Get("/providers", listProviders)
+	r.Get("/providers/tags", listProviderTags)
 	r.Get("/providers/{id}", getProvider)
 	// Mutating provider endpoints require editor or admin
 	r.Group(func(gr chi.Router) {
@@ -20,17 +41,200 @@ func registerProviders(r chi.Router) {
 		gr.Post("/providers", createProvider)
 		gr.Put("/providers/{id}", updateProvider)
 		gr.Delete("/providers/{id}", deleteProvider)
+		gr.Get("/providers/{id}/ca-cert", getProviderCACert)
 	})
+	r.With(requireAdmin).Put("/providers/{id}/ca-cert", putProviderCACert)
+	registerFailover(r)
+	registerQuota(r)
+	registerProviderHealth(r)
+	registerProviderAccessTokens(r)
+}
+
+// FieldError is a single validation failure reported back to the client.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// provider tag limits
+const (
+	maxProviderTags   = 20
+	maxProviderTagLen = 64
+)
+
+var awsRegionRe = regexp.MustCompile(`^[a-z]{2}-[a-z]+-\d$`)
+
+// validateProvider checks the required fields and the rules specific to the provider type.
+func validateProvider(p *models.Provider) []FieldError {
+	var errs []FieldError
+	if p.Name == "" {
+		errs = append(errs, FieldError{"name", "is required"})
+	}
+	if p.Endpoint == "" {
+		errs = append(errs, FieldError{"endpoint", "is required"})
+	}
+	if p.UploadQuotaBytes < 0 || p.DownloadQuotaBytes < 0 {
+		errs = append(errs, FieldError{"quota", "must be 0 (unlimited) or positive"})
+	}
+	if p.QuotaPeriod != "" && p.QuotaPeriod != quotaDaily && p.QuotaPeriod != quotaMonthly {
+		errs = append(errs, FieldError{"quotaPeriod", "must be daily or monthly"})
+	}
+	if len(p.Tags) > maxProviderTags {
+		errs = append(errs, FieldError{"tags", fmt.Sprintf("at most %d tags", maxProviderTags)})
+	}
+	for _, t := range p.Tags {
+		if t == "" || len(t) > maxProviderTagLen {
+			errs = append(errs, FieldError{"tags", fmt.Sprintf("each tag must be 1 to %d characters", maxProviderTagLen)})
+			break
+		}
+	}
+	if p.MaxRetries < 0 || p.MaxRetries > 10 {
+		errs = append(errs, FieldError{"maxRetries", "must be between 0 and 10"})
+	}
+	switch p.PathStyle {
+	case "", s3.PathStyleAuto, s3.PathStylePath, s3.PathStyleVirtual:
+	default:
+		errs = append(errs, FieldError{"pathStyle", "must be auto, path or virtual"})
+	}
+	if p.FailoverEnabled {
+		if _, ok := endpointHost(p.SecondaryEndpoint); !ok {
+			errs = append(errs, FieldError{"secondaryEndpoint", "must be host:port or an http(s) URL when failover is enabled"})
+		} else if p.SecondaryEndpoint == p.Endpoint {
+			errs = append(errs, FieldError{"secondaryEndpoint", "must differ from endpoint"})
+		}
+	}
+	switch p.Type {
+	case "aws":
+		if !awsRegionRe.MatchString(p.Region) {
+			errs = append(errs, FieldError{"region", "must be a valid AWS region"})
+		}
+	case "minio", "mcg":
+		if p.Endpoint != "" {
+			if host, ok := endpointHost(p.Endpoint); !ok {
+				errs = append(errs, FieldError{"endpoint", "must be host:port or an http(s) URL"})
+			} else if isAWSHost(host) {
+				errs = append(errs, FieldError{"endpoint", "must not point at AWS for type " + p.Type})
+			}
+		}
+	}
+	return errs
+}
+
+// endpointHost extracts the host from a host:port or http(s) URL endpoint.
+func endpointHost(ep string) (string, bool) {
+	if strings.Contains(ep, "://") {
+		u, err := url.Parse(ep)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+			return "", false
+		}
+		return u.Hostname(), true
+	}
+	host, port, err := net.SplitHostPort(ep)
+	if err != nil || host == "" {
+		return "", false
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return "", false
+	}
+	return host, true
+}
+
+// hostOf is a lenient endpointHost that also accepts a bare host name.
+func hostOf(ep string) string {
+	if host, ok := endpointHost(ep); ok {
+		return host
+	}
+	return ep
+}
+
+func isAWSHost(host string) bool {
+	host = strings.ToLower(host)
+	return host == "s3.amazonaws.com" || strings.HasSuffix(host, ".amazonaws.com")
+}
+
+// checkProvider validates p, writing a 400 with the violations when it is invalid. AWS
+// providers with a non-AWS endpoint are allowed (S3-compatible gateways) but logged.
+func checkProvider(w http.ResponseWriter, r *http.Request, p *models.Provider) bool {
+	if errs := validateProvider(p); len(errs) > 0 {
+		addEvent(r, "provider.invalid", map[string]any{"violations": errs})
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		json.NewEncoder(w).Encode(map[string]any{"error": "validation failed", "code": ErrCodeValidation, "violations": errs})
+		return false
+	}
+	if p.Type == "aws" && !isAWSHost(hostOf(p.Endpoint)) {
+		apiLogger.Error("provider_warn", "msg", "aws provider uses a custom endpoint", "provider", p.Name, "endpoint", p.Endpoint)
+	}
+	return true
+}
+
+// checkConnectivity lists p's buckets before p is saved, when providerValidateOnSave is
+// set, writing 422 {"error":"connectivity_check_failed","detail"} when that fails.
+// ?skipValidation=true saves without the check, e.g. for an endpoint not deployed yet.
+// The outcome is recorded as a provider.validation event.
+func checkConnectivity(w http.ResponseWriter, r *http.Request, p *models.Provider) bool {
+	if !providerValidateOnSave {
+		return true
+	}
+	if r.URL.Query().Get("skipValidation") == "true" {
+		addEvent(r, "provider.validation", map[string]any{"provider": p.Name, "outcome": "skipped"})
+		auditValidation(r, p, "skipped", "")
+		return true
+	}
+	detail := ""
+	c, err := clientFactory(*p)
+	if err != nil {
+		detail = err.Error()
+	} else {
+		ctx, cancel := context.WithTimeout(r.Context(), providerSaveCheckTimeout)
+		_, err = c.ListBuckets(ctx)
+		cancel()
+		closeClient(c)
+		if err != nil {
+			_, detail = s3Failure(r, err)
+		}
+	}
+	if err == nil {
+		addEvent(r, "provider.validation", map[string]any{"provider": p.Name, "outcome": "ok"})
+		auditValidation(r, p, "ok", "")
+		return true
+	}
+	addEvent(r, "provider.validation", map[string]any{"provider": p.Name, "outcome": "failed", "detail": detail})
+	auditValidation(r, p, "failed", detail)
+	apiLogger.Info("provider connectivity check failed", "provider", p.Name, "endpoint", p.Endpoint, "detail", detail)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(422)
+	json.NewEncoder(w).Encode(map[string]any{"error": "connectivity_check_failed", "code": ErrCodeConnectivityCheckFailed, "detail": detail})
+	return false
+}
+
+// auditValidation records the outcome of a connectivity check; a new provider has no ID
+// yet and is named by the detail only.
+func auditValidation(r *http.Request, p *models.Provider, outcome, detail string) {
+	id := ""
+	if p.ID != 0 {
+		id = strconv.Itoa(int(p.ID))
+	}
+	fields := map[string]any{"name": p.Name, "endpoint": p.Endpoint, "outcome": outcome}
+	if detail != "" {
+		fields["detail"] = detail
+	}
+	RecordAudit(r.Context(), "provider.validation", "provider", id, fields)
 }
 
+// listProviders lists the tenant's providers, only those tagged ?tag= when given.
 func listProviders(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	var items []models.Provider
-	if err := db.DB.Find(&items).Error; err != nil {
-		http.Error(w, err.Error(), 500)
+	q := readDB(r).Scopes(tenantScope(r))
+	if tag := r.URL.Query().Get("tag"); tag != "" {
+		q = q.Scopes(db.ProviderTagScope(tag))
+	}
+	if err := q.Find(&items).Error; err != nil {
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
 		return
 	}
-	json.NewEncoder(w).Encode(items)
+	RespondList(w, r, 200, items, len(items), len(items), 0)
 }
 
 func createProvider(w http.ResponseWriter, r *http.Request) {
@@ -38,54 +242,73 @@ func createProvider(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	var p models.Provider
 	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
-		http.Error(w, err.Error(), 400)
+		respondError(w, r, 400, err.Error(), ErrCodeInvalidRequest)
 		return
 	}
-	// Basic validation
-	if p.Name == "" || p.Endpoint == "" {
-		http.Error(w, "name and endpoint are required", 400)
+	if !checkProvider(w, r, &p) || !checkConnectivity(w, r, &p) {
 		return
 	}
+	p.TenantID = tenantFromCtx(r)
 	if err := db.DB.Create(&p).Error; err != nil {
-		http.Error(w, err.Error(), 500)
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
 		return
 	}
+	middleware.InvalidateCache(providersCachePrefix + "|")
+	if len(p.Tags) > 0 {
+		addEvent(r, "provider.tags", map[string]any{"provider": p.ID, "tags": p.Tags})
+	}
+	RecordAudit(r.Context(), "provider.create", "provider", strconv.Itoa(int(p.ID)), map[string]any{"name": p.Name, "type": p.Type, "endpoint": p.Endpoint, "tags": p.Tags})
 	w.WriteHeader(201)
 	json.NewEncoder(w).Encode(p)
 }
 
+// listProviderTags returns the distinct tags of the tenant's providers, for filter menus.
+func listProviderTags(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	tags, err := db.ProviderTags(readDB(r), tenantScope(r))
+	if err != nil {
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
+		return
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	Respond(w, r, 200, tags)
+}
+
 func getProvider(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil || id <= 0 {
-		http.Error(w, "invalid provider id", 400)
+		respondError(w, r, 400, "invalid provider id", ErrCodeInvalidID)
 		return
 	}
 	var p models.Provider
 	if err := db.DB.First(&p, id).Error; err != nil {
-		http.Error(w, "not found", 404)
+		respondError(w, r, 404, "not found", ErrCodeNotFound)
 		return
 	}
-	json.NewEncoder(w).Encode(p)
+	Respond(w, r, 200, p)
 }
 
 func updateProvider(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil || id <= 0 {
-		http.Error(w, "invalid provider id", 400)
+		respondError(w, r, 400, "invalid provider id", ErrCodeInvalidID)
 		return
 	}
 	var p models.Provider
 	if err := db.DB.First(&p, id).Error; err != nil {
-		http.Error(w, "not found", 404)
+		respondError(w, r, 404, "not found", ErrCodeNotFound)
 		return
 	}
 	var in map[string]any
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-		http.Error(w, err.Error(), 400)
+		respondError(w, r, 400, err.Error(), ErrCodeInvalidRequest)
 		return
 	}
+	before := p
 	// Patch-like update: apply provided fields only
 	if name, ok := in["name"].(string); ok {
 		p.Name = name
@@ -102,33 +325,130 @@ func updateProvider(w http.ResponseWriter, r *http.Request) {
 	if sk, ok := in["secretKey"].(string); ok {
 		p.SecretKey = sk
 	}
+	if ref, ok := in["secretRef"].(string); ok {
+		p.SecretRef = ref
+	}
 	if rg, ok := in["region"].(string); ok {
 		p.Region = rg
 	}
 	if ussl, ok := in["useSSL"].(bool); ok {
 		p.UseSSL = ussl
 	}
-	// Validate required fields after merge
-	if p.Name == "" || p.Endpoint == "" {
-		http.Error(w, "name and endpoint are required", 400)
+	if sec, ok := in["secondaryEndpoint"].(string); ok {
+		p.SecondaryEndpoint = sec
+	}
+	if fo, ok := in["failoverEnabled"].(bool); ok {
+		p.FailoverEnabled = fo
+	}
+	if uq, ok := in["uploadQuotaBytes"].(float64); ok {
+		p.UploadQuotaBytes = int64(uq)
+	}
+	if dq, ok := in["downloadQuotaBytes"].(float64); ok {
+		p.DownloadQuotaBytes = int64(dq)
+	}
+	if qp, ok := in["quotaPeriod"].(string); ok {
+		p.QuotaPeriod = qp
+	}
+	if ps, ok := in["pathStyle"].(string); ok {
+		p.PathStyle = ps
+	}
+	if mr, ok := in["maxRetries"].(float64); ok {
+		p.MaxRetries = int(mr)
+	}
+	if raw, ok := in["tags"]; ok {
+		b, _ := json.Marshal(raw)
+		var tags models.StringList
+		if err := json.Unmarshal(b, &tags); err != nil {
+			respondError(w, r, 400, "tags must be an array of strings", ErrCodeValidation)
+			return
+		}
+		p.Tags = tags
+	}
+	// Validate after merge so partial updates are checked against the full provider
+	if !checkProvider(w, r, &p) {
+		return
+	}
+	connChanged := p.Endpoint != before.Endpoint || p.UseSSL != before.UseSSL || p.AccessKey != before.AccessKey ||
+		p.SecretKey != before.SecretKey || p.SecretRef != before.SecretRef
+	if connChanged && !checkConnectivity(w, r, &p) {
 		return
 	}
 	if err := db.DB.Save(&p).Error; err != nil {
-		http.Error(w, err.Error(), 500)
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
 		return
 	}
+	middleware.InvalidateCache(providersCachePrefix + "|")
+	if !slices.Equal(p.Tags, before.Tags) {
+		addEvent(r, "provider.tags", map[string]any{"provider": p.ID, "before": before.Tags, "tags": p.Tags})
+		RecordAudit(r.Context(), "provider.tags", "provider", strconv.Itoa(int(p.ID)), map[string]any{"before": before.Tags, "tags": p.Tags})
+	}
 	json.NewEncoder(w).Encode(p)
 }
 
 func deleteProvider(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil || id <= 0 {
-		http.Error(w, "invalid provider id", 400)
+		respondError(w, r, 400, "invalid provider id", ErrCodeInvalidID)
 		return
 	}
 	if err := db.DB.Delete(&models.Provider{}, id).Error; err != nil {
-		http.Error(w, err.Error(), 500)
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
 		return
 	}
+	s3Clients.remove(uint(id))
+	middleware.InvalidateCache(providersCachePrefix + "|")
+	RecordAudit(r.Context(), "provider.delete", "provider", strconv.Itoa(id), nil)
 	w.WriteHeader(204)
 }
+
+// getProviderCACert reports whether a custom CA is configured; the PEM itself is never returned.
+func getProviderCACert(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id <= 0 {
+		respondError(w, r, 400, "invalid provider id", ErrCodeInvalidID)
+		return
+	}
+	var p models.Provider
+	if err := db.DB.First(&p, id).Error; err != nil {
+		respondError(w, r, 404, "not found", ErrCodeNotFound)
+		return
+	}
+	Respond(w, r, 200, map[string]any{"configured": p.CACertPEM != ""})
+}
+
+// putProviderCACert sets the PEM CA bundle used to verify the provider's TLS endpoint;
+// an empty caCertPem clears it.
+func putProviderCACert(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id <= 0 {
+		respondError(w, r, 400, "invalid provider id", ErrCodeInvalidID)
+		return
+	}
+	var p models.Provider
+	if err := db.DB.First(&p, id).Error; err != nil {
+		respondError(w, r, 404, "not found", ErrCodeNotFound)
+		return
+	}
+	var in struct {
+		CACertPEM string `json:"caCertPem"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondError(w, r, 400, err.Error(), ErrCodeInvalidRequest)
+		return
+	}
+	pem := strings.TrimSpace(in.CACertPEM)
+	if pem != "" {
+		if _, err := s3.ParseCACert(pem); err != nil {
+			respondError(w, r, 400, err.Error(), ErrCodeInvalidRequest)
+			return
+		}
+	}
+	if err := db.DB.Model(&p).Update("CACertPEM", pem).Error; err != nil {
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
+		return
+	}
+	addEvent(r, "provider.ca_cert", map[string]any{"provider": p.ID, "configured": pem != ""})
+	Respond(w, r, 200, map[string]any{"configured": pem != ""})
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/arencloud/hermes/internal/models"
+)
+
+func TestValidateProvider(t *testing.T) {
+	cases := []struct {
+		name   string
+		p      models.Provider
+		fields []string // fields expected in the violations, in order
+	}{
+		{"aws ok", models.Provider{Name: "a", Type: "aws", Endpoint: "s3.amazonaws.com", Region: "us-east-1"}, nil},
+		{"aws custom endpoint is only a warning", models.Provider{Name: "a", Type: "aws", Endpoint: "gw.local:9000", Region: "eu-west-3"}, nil},
+		{"aws missing region", models.Provider{Name: "a", Type: "aws", Endpoint: "s3.amazonaws.com"}, []string{"region"}},
+		{"aws bad region", models.Provider{Name: "a", Type: "aws", Endpoint: "s3.amazonaws.com", Region: "useast1"}, []string{"region"}},
+		{"minio host:port", models.Provider{Name: "m", Type: "minio", Endpoint: "minio.local:9000"}, nil},
+		{"minio url", models.Provider{Name: "m", Type: "minio", Endpoint: "https://minio.example.com"}, nil},
+		{"minio bare host", models.Provider{Name: "m", Type: "minio", Endpoint: "minio.local"}, []string{"endpoint"}},
+		{"minio bad port", models.Provider{Name: "m", Type: "minio", Endpoint: "minio.local:99999"}, []string{"endpoint"}},
+		{"minio bad scheme", models.Provider{Name: "m", Type: "minio", Endpoint: "ftp://minio.local"}, []string{"endpoint"}},
+		{"mcg pointing at aws", models.Provider{Name: "m", Type: "mcg", Endpoint: "https://s3.amazonaws.com"}, []string{"endpoint"}},
+		{"generic is not type-checked", models.Provider{Name: "g", Type: "generic", Endpoint: "anything"}, nil},
+		{"required fields", models.Provider{Type: "generic"}, []string{"name", "endpoint"}},
+		{"virtual path style", models.Provider{Name: "m", Type: "minio", Endpoint: "minio.local:9000", PathStyle: "virtual"}, nil},
+		{"unknown path style", models.Provider{Name: "g", Type: "generic", Endpoint: "x", PathStyle: "dns"}, []string{"pathStyle"}},
+		{"tags", models.Provider{Name: "g", Type: "generic", Endpoint: "x", Tags: models.StringList{"prod", "aws"}}, nil},
+		{"empty tag", models.Provider{Name: "g", Type: "generic", Endpoint: "x", Tags: models.StringList{"prod", ""}}, []string{"tags"}},
+		{"long tag", models.Provider{Name: "g", Type: "generic", Endpoint: "x", Tags: models.StringList{strings.Repeat("t", 65)}}, []string{"tags"}},
+		{"too many tags", models.Provider{Name: "g", Type: "generic", Endpoint: "x", Tags: make(models.StringList, 21)}, []string{"tags", "tags"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := validateProvider(&tc.p)
+			if len(errs) != len(tc.fields) {
+				t.Fatalf("violations = %+v, want fields %v", errs, tc.fields)
+			}
+			for i, f := range tc.fields {
+				if errs[i].Field != f {
+					t.Fatalf("violation %d field = %s, want %s", i, errs[i].Field, f)
+				}
+			}
+		})
+	}
+}
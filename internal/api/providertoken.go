@@ -0,0 +1,222 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	providerTokenDefaultTTL = time.Hour
+	providerTokenMaxTTL     = 7 * 24 * time.Hour
+)
+
+// permissions a provider access token can carry
+const (
+	permListBuckets = "list_buckets"
+	permListObjects = "list_objects"
+	permDownload    = "download"
+)
+
+// providerTokenRoutes maps the routes a provider access token may call to the permission
+// each needs; any other route is refused.
+var providerTokenRoutes = map[string]string{
+	"/providers/{id}/buckets":                 permListBuckets,
+	"/providers/{id}/buckets/{name}/objects":  permListObjects,
+	"/providers/{id}/buckets/{name}/download": permDownload,
+}
+
+func registerProviderAccessTokens(r chi.Router) {
+	r.With(requireAdmin).Post("/providers/{id}/access-token", createProviderAccessToken)
+	r.With(requireAdmin).Delete("/providers/{id}/access-token/{tokenId}", revokeProviderAccessToken)
+}
+
+// providerTokenClaims are carried by a provider access token, a JWT signed like upload
+// tokens. Use keeps one kind of token from being accepted as the other.
+type providerTokenClaims struct {
+	Use         string   `json:"use"`
+	TokenID     uint     `json:"jti"`
+	ProviderID  uint     `json:"pid"`
+	Permissions []string `json:"perms"`
+	Expires     int64    `json:"exp"`
+}
+
+const providerTokenUse = "provider-access"
+
+var errProviderToken = errors.New("invalid or expired provider token")
+
+func signProviderToken(c providerTokenClaims) string {
+	c.Use = providerTokenUse
+	payload, _ := json.Marshal(c)
+	unsigned := uploadTokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + sign(unsigned)
+}
+
+// parseProviderToken checks the signature and expiry of token; revocation is checked by
+// the caller against its ProviderAccessToken record.
+func parseProviderToken(token string, now time.Time) (providerTokenClaims, error) {
+	var c providerTokenClaims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != uploadTokenHeader {
+		return c, errProviderToken
+	}
+	if !hmac.Equal([]byte(sign(parts[0]+"."+parts[1])), []byte(parts[2])) {
+		return c, errProviderToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(payload, &c) != nil || c.Use != providerTokenUse {
+		return c, errProviderToken
+	}
+	if now.Unix() >= c.Expires {
+		return c, errProviderToken
+	}
+	return c, nil
+}
+
+type tokenUserKey struct{}
+
+// providerTokenAuth authenticates requests carrying X-Provider-Token as a synthetic viewer
+// of the token's provider tenant, ahead of requireAuth. Only the routes in
+// providerTokenRoutes of the token's own provider are let through, and only with the
+// matching permission. Requests without the header fall through to cookie auth.
+func providerTokenAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("X-Provider-Token")
+		if token == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		claims, err := parseProviderToken(token, time.Now())
+		if err != nil {
+			respondError(w, r, 401, err.Error(), ErrCodeUnauthorized)
+			return
+		}
+		var rec models.ProviderAccessToken
+		if err := db.DB.First(&rec, claims.TokenID).Error; err != nil || rec.RevokedAt != nil || rec.ProviderID != claims.ProviderID {
+			respondError(w, r, 401, errProviderToken.Error(), ErrCodeUnauthorized)
+			return
+		}
+		perm := ""
+		pattern := chi.RouteContext(r.Context()).RoutePattern()
+		for suffix, p := range providerTokenRoutes {
+			if strings.HasSuffix(pattern, suffix) {
+				perm = p
+			}
+		}
+		if perm == "" || !slices.Contains(claims.Permissions, perm) {
+			respondError(w, r, 403, "provider token does not allow this request", ErrCodeForbidden)
+			return
+		}
+		if chi.URLParam(r, "id") != strconv.FormatUint(uint64(claims.ProviderID), 10) {
+			respondError(w, r, 403, "provider token is scoped to another provider", ErrCodeForbidden)
+			return
+		}
+		var p models.Provider
+		if err := db.DB.First(&p, claims.ProviderID).Error; err != nil {
+			respondError(w, r, 404, "provider not found", ErrCodeProviderNotFound)
+			return
+		}
+		u := &models.User{Email: fmt.Sprintf("provider-token:%d", rec.ID), Role: "viewer", TenantID: p.TenantID}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tokenUserKey{}, u)))
+	})
+}
+
+// createProviderAccessToken issues a read-only token for one provider (admin), e.g. for
+// an external auditor without a Hermes account.
+func createProviderAccessToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	pid, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || pid <= 0 {
+		respondError(w, r, 400, "invalid provider id", ErrCodeInvalidID)
+		return
+	}
+	var in struct {
+		TTLSeconds  int64    `json:"ttlSeconds"`
+		Permissions []string `json:"permissions"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondError(w, r, 400, err.Error(), ErrCodeInvalidRequest)
+		return
+	}
+	if len(in.Permissions) == 0 {
+		respondError(w, r, 400, "permissions is required", ErrCodeMissingField)
+		return
+	}
+	for _, p := range in.Permissions {
+		if p != permListBuckets && p != permListObjects && p != permDownload {
+			respondError(w, r, 400, "permissions must be list_buckets, list_objects or download", ErrCodeValidation)
+			return
+		}
+	}
+	ttl := providerTokenDefaultTTL
+	if in.TTLSeconds != 0 {
+		ttl = time.Duration(in.TTLSeconds) * time.Second
+	}
+	if ttl <= 0 || ttl > providerTokenMaxTTL {
+		respondError(w, r, 400, "ttlSeconds must be between 1 and 604800", ErrCodeValidation)
+		return
+	}
+	var p models.Provider
+	if err := db.DB.Scopes(tenantScope(r)).First(&p, pid).Error; err != nil {
+		respondError(w, r, 404, "provider not found", ErrCodeProviderNotFound)
+		return
+	}
+	perms := slices.Compact(slices.Sorted(slices.Values(in.Permissions)))
+	rec := models.ProviderAccessToken{ProviderID: p.ID, Permissions: strings.Join(perms, ","), ExpiresAt: time.Now().Add(ttl).UTC()}
+	if u := currentUser(r); u != nil {
+		rec.CreatedBy = u.Email
+	}
+	if err := db.DB.Create(&rec).Error; err != nil {
+		respondError(w, r, 500, err.Error(), ErrCodeInternal)
+		return
+	}
+	token := signProviderToken(providerTokenClaims{TokenID: rec.ID, ProviderID: p.ID, Permissions: perms, Expires: rec.ExpiresAt.Unix()})
+	addEvent(r, "provider_token.create", map[string]any{"tokenId": rec.ID, "permissions": rec.Permissions, "ttl": ttl.String()})
+	Respond(w, r, 201, map[string]any{
+		"id":          rec.ID,
+		"token":       token,
+		"permissions": perms,
+		"expiresAt":   rec.ExpiresAt,
+	})
+}
+
+// revokeProviderAccessToken stops a token from being accepted before it expires (admin).
+func revokeProviderAccessToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	pid, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || pid <= 0 {
+		respondError(w, r, 400, "invalid provider id", ErrCodeInvalidID)
+		return
+	}
+	tid, err := strconv.Atoi(chi.URLParam(r, "tokenId"))
+	if err != nil || tid <= 0 {
+		respondError(w, r, 400, "invalid token id", ErrCodeInvalidID)
+		return
+	}
+	var rec models.ProviderAccessToken
+	if err := db.DB.Where("provider_id = ?", pid).First(&rec, tid).Error; err != nil {
+		respondError(w, r, 404, "token not found", ErrCodeNotFound)
+		return
+	}
+	if rec.RevokedAt == nil {
+		now := time.Now().UTC()
+		if err := db.DB.Model(&rec).Update("revoked_at", now).Error; err != nil {
+			respondError(w, r, 500, err.Error(), ErrCodeInternal)
+			return
+		}
+	}
+	addEvent(r, "provider_token.revoke", map[string]any{"tokenId": rec.ID})
+	w.WriteHeader(204)
+}
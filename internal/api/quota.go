@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	quotaDaily   = "daily"
+	quotaMonthly = "monthly"
+)
+
+func registerQuota(r chi.Router) {
+	r.With(requireAdmin).Get("/providers/{id}/quota", getProviderQuota)
+}
+
+// quotaPeriodStart returns the start (UTC) of the quota period containing now.
+func quotaPeriodStart(now time.Time, period string) time.Time {
+	now = now.UTC()
+	if period == quotaDaily {
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func quotaPeriod(p *models.Provider) string {
+	if p.QuotaPeriod == quotaDaily {
+		return quotaDaily
+	}
+	return quotaMonthly
+}
+
+// currentQuota loads the provider's usage row, starting a fresh period when the stored
+// one has ended so usage never carries over even if the reset job has not run yet.
+func currentQuota(p *models.Provider, now time.Time) (models.ProviderQuota, error) {
+	period := quotaPeriod(p)
+	start := quotaPeriodStart(now, period)
+	var q models.ProviderQuota
+	err := db.DB.First(&q, "provider_id = ?", p.ID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return q, err
+	}
+	if err == nil && q.PeriodType == period && !q.PeriodStart.Before(start) {
+		return q, nil
+	}
+	q = models.ProviderQuota{ProviderID: p.ID, PeriodStart: start, PeriodType: period}
+	return q, db.DB.Save(&q).Error
+}
+
+// addQuotaUsage atomically adds transferred bytes to the provider's current period.
+func addQuotaUsage(p *models.Provider, uploaded, downloaded int64) error {
+	if p.UploadQuotaBytes <= 0 && p.DownloadQuotaBytes <= 0 {
+		return nil
+	}
+	if _, err := currentQuota(p, time.Now()); err != nil {
+		return err
+	}
+	return db.DB.Model(&models.ProviderQuota{}).Where("provider_id = ?", p.ID).Updates(map[string]any{
+		"uploaded_bytes":   gorm.Expr("uploaded_bytes + ?", uploaded),
+		"downloaded_bytes": gorm.Expr("downloaded_bytes + ?", downloaded),
+	}).Error
+}
+
+// checkQuota rejects a transfer of size bytes (upload or download) with 429 when it would
+// exceed the provider's quota. size may be 0 when unknown: then only an exhausted quota
+// is rejected.
+func checkQuota(w http.ResponseWriter, r *http.Request, p *models.Provider, upload bool, size int64) bool {
+	limit := p.DownloadQuotaBytes
+	if upload {
+		limit = p.UploadQuotaBytes
+	}
+	if limit <= 0 {
+		return true
+	}
+	q, err := currentQuota(p, time.Now())
+	if err != nil {
+		respondError(w, r, 500, err.Error())
+		return false
+	}
+	used := q.DownloadedBytes
+	if upload {
+		used = q.UploadedBytes
+	}
+	if used < limit && used+max(size, 0) <= limit {
+		return true
+	}
+	addEvent(r, "provider.quota_exceeded", map[string]any{"provider": p.ID, "upload": upload, "used": used, "limit": limit, "size": size})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(429)
+	json.NewEncoder(w).Encode(map[string]any{"error": "quota_exceeded", "code": ErrCodeQuotaExceeded, "remaining": max(limit-used, 0)})
+	return false
+}
+
+// resetQuotas starts a new period for every usage row whose period has ended.
+func resetQuotas() error {
+	now := time.Now()
+	for _, period := range []string{quotaDaily, quotaMonthly} {
+		start := quotaPeriodStart(now, period)
+		err := db.DB.Model(&models.ProviderQuota{}).Where("period_type = ? AND period_start < ?", period, start).
+			Updates(map[string]any{"uploaded_bytes": 0, "downloaded_bytes": 0, "period_start": start}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getProviderQuota reports the provider's limits and its usage in the current period.
+func getProviderQuota(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	p, ok := providerParam(w, r)
+	if !ok {
+		return
+	}
+	q, err := currentQuota(p, time.Now())
+	if err != nil {
+		respondError(w, r, 500, err.Error())
+		return
+	}
+	Respond(w, r, 200, map[string]any{
+		"uploadQuotaBytes":   p.UploadQuotaBytes,
+		"downloadQuotaBytes": p.DownloadQuotaBytes,
+		"periodType":         q.PeriodType,
+		"periodStart":        q.PeriodStart,
+		"uploadedBytes":      q.UploadedBytes,
+		"downloadedBytes":    q.DownloadedBytes,
+	})
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/arencloud/hermes/internal/middleware"
+)
+
+// rateLimiter throttles each user, or client IP when not signed in (RATE_LIMIT_RPS and
+// RATE_LIMIT_BURST); nil when rate limiting is off.
+var rateLimiter *middleware.RateLimiter
+
+// rateLimitIdle is how long a client's bucket is kept after its last request.
+const rateLimitIdle = 10 * time.Minute
+
+// rateLimitKey identifies the client of r: the user the trace was opened for, else the
+// client address, which only follows X-Forwarded-For behind a trusted proxy so that
+// spoofed headers neither dodge the limit nor fill the table.
+func rateLimitKey(r *http.Request) string {
+	t := traceFrom(r.Context())
+	if t == nil {
+		return "ip:" + clientIP(r)
+	}
+	if t.UserEmail != "" {
+		return "user:" + t.UserEmail
+	}
+	return "ip:" + t.RemoteIP
+}
+
+func rejectRateLimited(w http.ResponseWriter, r *http.Request) {
+	addEvent(r, "ratelimit.rejected", map[string]any{"client": rateLimitKey(r)})
+	respondError(w, r, http.StatusTooManyRequests, "rate limit exceeded", ErrCodeRateLimited)
+}
+
+// evictRateLimits forgets clients idle for rateLimitIdle so the table does not grow
+// with every address ever seen.
+func evictRateLimits() error {
+	if rateLimiter != nil {
+		rateLimiter.Evict(time.Now().Add(-rateLimitIdle))
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// envelope is the standard JSON response shape: the payload under data plus the
+// request's trace ID so clients can quote it when reporting problems.
+type envelope struct {
+	Data       any         `json:"data"`
+	RequestID  string      `json:"requestId"`
+	Timestamp  string      `json:"timestamp"`
+	Pagination *pagination `json:"pagination,omitempty"`
+}
+
+type pagination struct {
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// Respond writes data wrapped in the standard envelope.
+func Respond(w http.ResponseWriter, r *http.Request, status int, data any) {
+	writeEnvelope(w, r, status, envelope{Data: data})
+}
+
+// RespondList writes a list wrapped in the standard envelope with pagination metadata.
+func RespondList(w http.ResponseWriter, r *http.Request, status int, data any, total, limit, offset int) {
+	writeEnvelope(w, r, status, envelope{Data: data, Pagination: &pagination{Total: total, Limit: limit, Offset: offset}})
+}
+
+func writeEnvelope(w http.ResponseWriter, r *http.Request, status int, env envelope) {
+	// same ID the tracing middleware returns in X-Trace-Id
+	if t := traceFrom(r.Context()); t != nil {
+		env.RequestID = t.ID
+	}
+	env.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(env)
+}
@@ -1,21 +1,38 @@
 package api
 
 import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
-	"sync/atomic"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	v2 "github.com/arencloud/hermes/internal/api/v2"
 	"github.com/arencloud/hermes/internal/config"
 	"github.com/arencloud/hermes/internal/logging"
+	"github.com/arencloud/hermes/internal/metrics"
+	"github.com/arencloud/hermes/internal/middleware"
+	"github.com/arencloud/hermes/internal/s3"
+	"github.com/arencloud/hermes/internal/telemetry"
 	"github.com/arencloud/hermes/internal/version"
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/cors"
 )
 
 var maxUploadSizeBytes int64
 
+// apiLogger is the logger passed to Router, for handlers that log outside the request trace
+var apiLogger logging.Logger
+
+// apiTimeout bounds regular API requests (set in Router); 0 disables the deadline
+var apiTimeout = 30 * time.Second
+
 // Note: no go:embed for assets; we serve from disk only.
 
 type statusRecorder struct {
@@ -34,14 +51,89 @@ func (sr *statusRecorder) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// recorderPool recycles statusRecorders; they do not outlive the request, unlike the
+// Trace, which stays referenced from the ring buffer and the live stream.
+var recorderPool = sync.Pool{New: func() any { return new(statusRecorder) }}
+
+// Flush lets streaming handlers (SSE, NDJSON progress) flush through the recorder.
+func (sr *statusRecorder) Flush() {
+	if f, ok := sr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 func Router(cfg *config.Config, logger logging.Logger) http.Handler {
 	maxUploadSizeBytes = cfg.MaxUploadSizeBytes
+	maxBatchSizeBytes = cfg.MaxBatchSizeBytes
+	s3Clients = newClientCache(int(cfg.S3ClientCacheSize))
+	uploadLimiter = newUploadLimiter(cfg.UploadConcurrencyInit, cfg.UploadConcurrencyMax, cfg.UploadLatencyTargetMs)
+	providerValidateOnSave = cfg.ProviderValidateOnCreate
+	apiKeysEnabled = cfg.APIKeysEnabled
+	if cfg.MaxPresignExpiryHours > 0 {
+		maxPresignExpiry = time.Duration(cfg.MaxPresignExpiryHours) * time.Hour
+	}
+	if cfg.SessionTTLHours > 0 {
+		sessionTTL = time.Duration(cfg.SessionTTLHours) * time.Hour
+	}
+	if cfg.ExportMaxRows > 0 {
+		exportMaxRows = cfg.ExportMaxRows
+	}
+	if cfg.MaxBatchFiles > 0 {
+		maxBatchFiles = int(cfg.MaxBatchFiles)
+	}
+	if cfg.MaxBulkDelete > 0 {
+		maxBulkDelete = int(cfg.MaxBulkDelete)
+	}
+	if cfg.BucketForceDeleteMaxObjects > 0 {
+		bucketForceDeleteMax = int(cfg.BucketForceDeleteMaxObjects)
+	}
+	if cfg.AuditLogRetentionDays > 0 {
+		auditRetention = time.Duration(cfg.AuditLogRetentionDays) * 24 * time.Hour
+	}
+	apiLogger = logger
+	trashBucket = cfg.TrashBucket
+	apiTimeout = time.Duration(cfg.ApiTimeoutSec) * time.Second
+	if cfg.ObsPushIntervalSec > 0 {
+		obsPushInterval = time.Duration(cfg.ObsPushIntervalSec) * time.Second
+	}
+	multiTenant = cfg.MultiTenant
+	tracePersist = !cfg.TraceMemoryOnly
+	traces.resize(int(cfg.MaxTraceBuffer))
+	if cfg.CopyPipeBufferBytes > 0 {
+		copyPipeBuffer = int(cfg.CopyPipeBufferBytes)
+	}
+	logging.SetBufferSize(int(cfg.MaxLogBuffer))
+	multipartOpts = s3.MultipartOptions{
+		Threshold: cfg.AutoMultipartThresholdMB << 20,
+		ChunkSize: cfg.MultipartChunkMB << 20,
+		Workers:   int(cfg.MultipartWorkers),
+	}
+	s3.OnFailover = recordFailover
+	s3.Logger = logger
+	if cfg.ProviderAlertAfterFailures > 0 {
+		providerAlertAfter = int(cfg.ProviderAlertAfterFailures)
+	}
+	providerAlertWebhook = cfg.ProviderAlertWebhookURL
+	incrementalStats = cfg.IncrementalStats
+	uploadURLAllowPrivate = cfg.UploadURLAllowPrivate
+	if nets, err := parseTrustedProxies(cfg.TrustedProxies); err == nil {
+		trustedProxies = nets
+	}
+	allowedTenants = map[string]bool{}
+	for _, t := range strings.Split(cfg.AllowedTenants, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			allowedTenants[t] = true
+		}
+	}
+	if cfg.TrashRetentionDays > 0 {
+		trashRetention = time.Duration(cfg.TrashRetentionDays) * 24 * time.Hour
+	}
 	r := chi.NewRouter()
-	r.Use(cors.Handler(cors.Options{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}, AllowedHeaders: []string{"*"}}))
+	r.Use(corsMiddleware(cfg))
 	// simple global request counter (observability)
 	r.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			atomic.AddUint64(&totalRequests, 1)
+			totalRequests.Inc()
 			next.ServeHTTP(w, r)
 		})
 	})
@@ -50,63 +142,105 @@ func Router(cfg *config.Config, logger logging.Logger) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			id := newTraceID()
 			u := currentUser(r)
-			t := &Trace{ID: id, Method: r.Method, Path: r.URL.Path, Started: time.Now(), Events: []TraceEvent{}}
+			t := &Trace{ID: id, Method: r.Method, Path: redactUploadToken(r.URL.Path), Started: time.Now()}
+			t.Events = t.evBuf[:0]
 			if u != nil {
 				t.UserEmail = u.Email
 				t.UserRole = u.Role
+				t.TenantID = u.TenantID
 			}
 			t.UserAgent = r.UserAgent()
-			if ip := r.Header.Get("X-Forwarded-For"); ip != "" {
-				t.RemoteIP = ip
-			} else {
-				t.RemoteIP = r.RemoteAddr
+			// W3C TraceContext: continue the caller's trace and hand back our span as the parent
+			t.SpanID = newSpanID()
+			out := traceParent{TraceID: id, ParentID: t.SpanID, Flags: "01"}
+			if h := r.Header.Get("traceparent"); h != "" {
+				if tp, ok := parseTraceparent(h); ok {
+					t.ParentTraceID = tp.TraceID
+					t.Tags = map[string]any{"traceparent": h}
+					if ts := r.Header.Get("tracestate"); ts != "" {
+						t.Tags["tracestate"] = ts
+					}
+					out.TraceID, out.Flags = tp.TraceID, tp.Flags
+				}
 			}
+			w.Header()["Traceparent"] = []string{out.String()} // pre-canonicalised, Set would allocate the key
+			t.RemoteIP = clientIP(r)
 			if r.ContentLength > 0 {
 				t.ReqBytes = r.ContentLength
 			}
-			w.Header().Set("X-Trace-Id", id)
-			w.Header().Set("X-Request-Id", id)
-			addEvent(r, "request.start", map[string]any{"method": r.Method, "path": r.URL.Path})
+			// both headers share one value slice
+			ids := []string{id}
+			w.Header()["X-Trace-Id"] = ids
+			w.Header()["X-Request-Id"] = ids
 			r = r.WithContext(withTraceCtx(r.Context(), t))
-			rec := &statusRecorder{ResponseWriter: w, code: 200}
+			rec := recorderPool.Get().(*statusRecorder)
+			*rec = statusRecorder{ResponseWriter: w, code: 200}
 			next.ServeHTTP(rec, r)
 			t.Status = rec.code
 			t.Ended = time.Now()
 			t.Duration = t.Ended.Sub(t.Started)
 			t.RespBytes = rec.bytes
-			addEvent(r, "request.end", map[string]any{"status": rec.code, "respBytes": rec.bytes})
+			*rec = statusRecorder{}
+			recorderPool.Put(rec)
+			addEventSet(r, "request.end", FieldSet{}.Add("status", strconv.Itoa(t.Status)).Add("respBytes", strconv.FormatInt(t.RespBytes, 10)))
 			// observability counters
 			if t.ReqBytes > 0 {
-				atomic.AddUint64(&bytesIn, uint64(t.ReqBytes))
+				bytesIn.Add(uint64(t.ReqBytes))
 			}
 			if t.RespBytes > 0 {
-				atomic.AddUint64(&bytesOut, uint64(t.RespBytes))
+				bytesOut.Add(uint64(t.RespBytes))
 			}
-			atomic.AddUint64(&totalDurationNs, uint64(t.Duration))
+			totalDurationNs.Add(uint64(t.Duration))
 			if t.Status >= 500 {
-				atomic.AddUint64(&total5xx, 1)
+				total5xx.Inc()
 			} else if t.Status >= 400 {
-				atomic.AddUint64(&total4xx, 1)
+				total4xx.Inc()
+			}
+			route := "unmatched"
+			if rc := chi.RouteContext(r.Context()); rc != nil && rc.RoutePattern() != "" {
+				route = rc.RoutePattern()
 			}
+			telemetry.RecordRequest(r.Context(), t.Method, route, t.Status, t.Duration)
+			requestsByRoute.With(t.Method, route, strconv.Itoa(t.Status)).Inc()
+			requestDuration.Observe(t.Duration.Seconds())
 			traces.add(t)
+			live.add(t)
 			// persist trace to DB for durability
-			persistTrace(t)
-			// emit structured request log
-			logger.Info("http_request",
+			if tracePersist {
+				persistTrace(t)
+			}
+			// emit structured request log; checked first so the boxed fields are
+			// not built when http_request logging is off
+			if !logging.Enabled("http_request", "info") {
+				return
+			}
+			kv := []any{
 				"method", t.Method,
 				"path", t.Path,
 				"status", t.Status,
-				"durationMs", float64(t.Duration)/1e6,
+				"durationMs", float64(t.Duration) / 1e6,
 				"user", t.UserEmail,
 				"role", t.UserRole,
 				"traceId", t.ID,
 				"bytesIn", t.ReqBytes,
 				"bytesOut", t.RespBytes,
-			)
+			}
+			// the persisted entry takes its tenant from this field, for tenant-scoped log queries
+			if t.TenantID != "" {
+				kv = append(kv, "tenant", t.TenantID)
+			}
+			logger.Info("http_request", kv...)
 		})
 	})
+	// after tracing, so clients are told apart by the user the trace identified
+	if cfg.RateLimitRPS > 0 {
+		rateLimiter = middleware.NewRateLimiter(float64(cfg.RateLimitRPS), int(cfg.RateLimitBurst))
+		r.Use(rateLimiter.Middleware(rateLimitKey, rejectRateLimited))
+	}
 
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) })
+	// Prometheus text exposition of the request metrics
+	r.Method("GET", "/metrics", metricsTokenAuth(cfg.MetricsToken, metrics.Handler()))
 
 	// API placeholder groups
 	r.Route("/api", func(r chi.Router) {
@@ -114,8 +248,23 @@ func Router(cfg *config.Config, logger logging.Logger) http.Handler {
 			w.Header().Set("Content-Type", "application/json")
 			w.Write([]byte(`{"name":"hermes","version":"` + version.Version + `"}`))
 		})
-		r.Route("/v1", func(r chi.Router) {
+		// v1 and v2 serve the same handlers; v2 only changes the response conventions
+		mountAPI := func(r chi.Router) {
+			if cfg.RequestLogBody {
+				// debug aid: log (redacted) JSON request bodies at debug level
+				middleware.MaxLoggedBodyBytes = cfg.RequestLogMaxBytes
+				r.Use(middleware.BodyLogger(logger, strings.Split(cfg.RequestLogRedact, ",")))
+			}
+			r.Use(timezoneMiddleware)
 			registerAPI(r, logger)
+		}
+		r.Route("/v1", func(r chi.Router) {
+			r.Use(v1Deprecation)
+			mountAPI(r)
+		})
+		r.Route("/v2", func(r chi.Router) {
+			r.Use(v2.Middleware)
+			mountAPI(r)
 		})
 	})
 
@@ -124,26 +273,127 @@ func Router(cfg *config.Config, logger logging.Logger) http.Handler {
 
 	// Static from disk (no embed). If not found, serve index.html for SPA routing
 	fs := http.FileServer(http.Dir(cfg.StaticDir))
-	r.Handle("/*", spaHandler(cfg.StaticDir, fs, logger))
+	var preload []string
+	if cfg.TLSCertFile != "" { // HTTP/2, and with it push, is only negotiated over TLS
+		for _, p := range strings.Split(cfg.SPAPreloadAssets, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				preload = append(preload, p)
+			}
+		}
+	}
+	r.Handle("/*", spaHandler(cfg.StaticDir, fs, logger, preload))
 	return r
 }
 
+// v1DeprecatedAt is announced in the Deprecation header of every /api/v1 response.
+var v1DeprecatedAt = time.Date(2027, time.April, 16, 0, 0, 0, 0, time.UTC)
+
+// v1Deprecation marks /api/v1 responses as deprecated in favour of /api/v2 (RFC 9745
+// Deprecation header plus a successor-version link); the responses are otherwise unchanged.
+func v1Deprecation(next http.Handler) http.Handler {
+	deprecation := "@" + strconv.FormatInt(v1DeprecatedAt.Unix(), 10)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("X-API-Version", "v1")
+		h.Set("Deprecation", deprecation)
+		h.Add("Link", `</api/v2/>; rel="successor-version"`)
+		next.ServeHTTP(w, r)
+	})
+}
+
 type spa struct {
-	dir    string
-	next   http.Handler
-	logger logging.Logger
+	dir       string
+	next      http.Handler
+	logger    logging.Logger
+	preload   []string // asset paths pushed (or hinted) with index.html
+	indexETag string   // quoted MD5 of index.html, read once at startup; "" when missing
+}
+
+func spaHandler(dir string, next http.Handler, logger logging.Logger, preload []string) http.Handler {
+	s := &spa{dir: dir, next: next, logger: logger, preload: preload}
+	if b, err := os.ReadFile(filepath.Join(dir, "index.html")); err == nil {
+		sum := md5.Sum(b)
+		s.indexETag = `"` + hex.EncodeToString(sum[:]) + `"`
+	}
+	return s
 }
 
-func spaHandler(dir string, next http.Handler, logger logging.Logger) http.Handler {
-	return &spa{dir: dir, next: next, logger: logger}
+// hashedAssetRe matches build output whose name carries a content hash, such as
+// index-4f2a9c1e.js or app.BkX9aZ12.css: a run of 8+ name characters (with a digit,
+// checked separately) between a separator and the extension.
+var hashedAssetRe = regexp.MustCompile(`[.-]([A-Za-z0-9_]{8,})\.(js|mjs|css|woff2?|ttf|otf|eot|svg|png|jpe?g|gif|webp|avif|ico|map)$`)
+
+// hashedAsset reports whether p is content-addressed, so it can be cached forever.
+func hashedAsset(p string) bool {
+	m := hashedAssetRe.FindStringSubmatch(path.Base(p))
+	return m != nil && strings.ContainsAny(m[1], "0123456789")
 }
 
 func (s *spa) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	p := filepath.Join(s.dir, r.URL.Path)
 	if info, err := os.Stat(p); err == nil && !info.IsDir() {
+		if hashedAsset(r.URL.Path) {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		}
 		s.next.ServeHTTP(w, r)
 		return
 	}
-	// fallback to index.html
+	// fallback to index.html: always revalidated, answered with 304 while unchanged
+	if s.indexETag != "" {
+		w.Header().Set("ETag", s.indexETag)
+		w.Header().Set("Cache-Control", "no-cache")
+		if etagMatch(r.Header.Get("If-None-Match"), s.indexETag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	s.pushAssets(w)
 	http.ServeFile(w, r, filepath.Join(s.dir, "index.html"))
 }
+
+// etagMatch reports whether an If-None-Match header lists etag (weak comparison).
+func etagMatch(header, etag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == "*" || t == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// pushAssets sends the preload assets ahead of index.html: pushed when the connection
+// supports HTTP/2 push, and always announced with Link preload headers so HTTP/1.1
+// clients (and HTTP/2 clients that disabled push) can fetch them early.
+func (s *spa) pushAssets(w http.ResponseWriter) {
+	if len(s.preload) == 0 {
+		return
+	}
+	pusher, _ := w.(http.Pusher)
+	for _, p := range s.preload {
+		link := "<" + p + ">; rel=preload"
+		if as := preloadAs(p); as != "" {
+			link += "; as=" + as
+		}
+		w.Header().Add("Link", link)
+		if pusher != nil {
+			if err := pusher.Push(p, nil); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				s.logger.Debug("asset push failed", "path", p, "error", err)
+			}
+		}
+	}
+}
+
+func preloadAs(p string) string {
+	switch strings.ToLower(path.Ext(p)) {
+	case ".js", ".mjs":
+		return "script"
+	case ".css":
+		return "style"
+	case ".woff", ".woff2":
+		return "font"
+	case ".png", ".jpg", ".jpeg", ".svg", ".webp", ".gif":
+		return "image"
+	}
+	return ""
+}
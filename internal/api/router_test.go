@@ -1,23 +1,37 @@
 package api
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/arencloud/hermes/internal/config"
 	"github.com/arencloud/hermes/internal/db"
 	"github.com/arencloud/hermes/internal/logging"
+	"github.com/arencloud/hermes/internal/middleware"
 	"github.com/arencloud/hermes/internal/models"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
 )
 
-// set up a temporary DB and router for integration-style tests
-func setupTestServer(t *testing.T) (*httptest.Server, *config.Config) {
+// set up a temporary DB and router for integration-style tests; opts can adjust the config before init
+func setupTestServer(t testing.TB, opts ...func(*config.Config)) (*httptest.Server, *config.Config) {
 	t.Helper()
 	tmp := t.TempDir()
 	// minimal static dir
@@ -27,15 +41,47 @@ func setupTestServer(t *testing.T) (*httptest.Server, *config.Config) {
 	}
 	os.WriteFile(filepath.Join(staticDir, "index.html"), []byte("<html>ok</html>"), 0o644)
 	cfg := &config.Config{Env: "test", HttpPort: "0", DBPath: filepath.Join(tmp, "test.db"), DBDriver: "sqlite", StaticDir: staticDir}
+	for _, o := range opts {
+		o(cfg)
+	}
 	logger := logging.New("test")
 	if err := db.Init(cfg, logger); err != nil {
 		t.Fatalf("db init: %v", err)
 	}
+	middleware.InvalidateCache("") // cached responses must not leak between test databases
 	h := Router(cfg, logger)
 	ts := httptest.NewServer(h)
 	return ts, cfg
 }
 
+// benchmarkHealth serves GET /health through the full middleware stack against an in-memory SQLite DB.
+func benchmarkHealth(b *testing.B, opts ...func(*config.Config)) {
+	opts = append([]func(*config.Config){func(c *config.Config) {
+		c.DBPath = "file:" + b.Name() + "?mode=memory&cache=shared"
+	}}, opts...)
+	b.Setenv("LOG_LEVEL", "error") // measure tracing, not log output and persistence
+	ts, _ := setupTestServer(b, opts...)
+	defer ts.Close()
+	h := ts.Config.Handler
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/health", nil))
+		if rec.Code != 200 {
+			b.Fatalf("status=%d", rec.Code)
+		}
+	}
+}
+
+func BenchmarkTracingMiddleware(b *testing.B) {
+	benchmarkHealth(b)
+}
+
+func BenchmarkTracingMiddleware_NoDBPersist(b *testing.B) {
+	benchmarkHealth(b, func(c *config.Config) { c.TraceMemoryOnly = true })
+}
+
 func TestHealthAndVersion(t *testing.T) {
 	ts, _ := setupTestServer(t)
 	defer ts.Close()
@@ -57,6 +103,135 @@ func TestHealthAndVersion(t *testing.T) {
 	}
 }
 
+func TestPrometheusMetrics(t *testing.T) {
+	ts, _ := setupTestServer(t, func(c *config.Config) { c.MetricsToken = "scrape-token" })
+	defer ts.Close()
+	http.Get(ts.URL + "/health")
+	scrape := func(token string) (*http.Response, string) {
+		t.Helper()
+		req, _ := http.NewRequest("GET", ts.URL+"/metrics", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+	if resp, _ := scrape(""); resp.StatusCode != 401 {
+		t.Fatalf("/metrics without token: %d", resp.StatusCode)
+	}
+	if resp, _ := scrape("wrong"); resp.StatusCode != 401 {
+		t.Fatalf("/metrics with wrong token: %d", resp.StatusCode)
+	}
+	resp, body := scrape("scrape-token")
+	if resp.StatusCode != 200 || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		t.Fatalf("/metrics status=%d content-type=%q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	for name, typ := range map[string]string{
+		"hermes_requests_total":                     "counter",
+		"hermes_requests_4xx_total":                 "counter",
+		"hermes_requests_5xx_total":                 "counter",
+		"hermes_bytes_in_total":                     "counter",
+		"hermes_bytes_out_total":                    "counter",
+		"hermes_request_duration_nanoseconds_total": "counter",
+		"hermes_request_duration_seconds":           "histogram",
+	} {
+		if !strings.Contains(body, "# HELP "+name+" ") || !strings.Contains(body, "# TYPE "+name+" "+typ+"\n") {
+			t.Errorf("/metrics missing HELP/TYPE of %s:\n%s", name, body)
+		}
+	}
+	for _, want := range []string{
+		`hermes_requests_total{method="GET",path="/health",status="200"} `,
+		`hermes_requests_total{method="GET",path="/metrics",status="401"} `,
+		`hermes_request_duration_seconds_bucket{le="+Inf"} `,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics missing %s:\n%s", want, body)
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	ts, _ := setupTestServer(t, func(c *config.Config) { c.RateLimitRPS = 1; c.RateLimitBurst = 2 })
+	defer ts.Close()
+	viewer := loginAs(t, ts, "limited@example.com", "viewer") // the login takes the first anonymous token
+	get := func(path string, cookie *http.Cookie) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest("GET", ts.URL+path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	if resp := get("/health", nil); resp.StatusCode != 200 {
+		t.Fatalf("anonymous within burst: %d", resp.StatusCode)
+	}
+	resp := get("/health", nil)
+	if resp.StatusCode != 429 || resp.Header.Get("Retry-After") == "" {
+		t.Fatalf("anonymous over burst: %d Retry-After=%q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+	// X-Forwarded-For from a peer that is not a trusted proxy does not open a new bucket
+	for _, ip := range []string{"198.51.100.1", "198.51.100.2"} {
+		req, _ := http.NewRequest("GET", ts.URL+"/health", nil)
+		req.Header.Set("X-Forwarded-For", ip)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != 429 {
+			t.Fatalf("spoofed X-Forwarded-For %s: %d, want 429", ip, resp.StatusCode)
+		}
+	}
+	// a signed-in user has a bucket of their own
+	for i := 0; i < 2; i++ {
+		if resp := get("/api/v1/auth/me", viewer); resp.StatusCode != 200 {
+			t.Fatalf("user request %d: %d", i+1, resp.StatusCode)
+		}
+	}
+	if resp := get("/api/v1/auth/me", viewer); resp.StatusCode != 429 {
+		t.Fatalf("user over burst: %d", resp.StatusCode)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	nets, err := parseTrustedProxies("10.0.0.0/8, 192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trustedProxies = nets
+	t.Cleanup(func() { trustedProxies = nil })
+	for _, tc := range []struct{ peer, xff, want string }{
+		{"203.0.113.9:5000", "198.51.100.1", "203.0.113.9"},
+		{"192.0.2.1:443", "", "192.0.2.1"},
+		{"192.0.2.1:443", "198.51.100.1", "198.51.100.1"},
+		{"192.0.2.1:443", "6.6.6.6, 198.51.100.1, 10.1.2.3", "198.51.100.1"},
+		{"10.0.0.5:80", "10.1.2.3", "10.1.2.3"},
+		{"10.0.0.5:80", "garbage, 198.51.100.1", "198.51.100.1"},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tc.peer
+		if tc.xff != "" {
+			r.Header.Set("X-Forwarded-For", tc.xff)
+		}
+		if got := clientIP(r); got != tc.want {
+			t.Errorf("peer %s X-Forwarded-For %q: %s, want %s", tc.peer, tc.xff, got, tc.want)
+		}
+	}
+	if _, err := parseTrustedProxies("10.0.0.0/8,proxy"); err == nil {
+		t.Fatal("host name accepted as trusted proxy")
+	}
+}
+
 func TestAuthLoginAndMe(t *testing.T) {
 	ts, _ := setupTestServer(t)
 	defer ts.Close()
@@ -98,3 +273,1690 @@ func TestAuthLoginAndMe(t *testing.T) {
 		t.Fatalf("/me status=%d", resp2.StatusCode)
 	}
 }
+
+// loginAs creates a user with the given role and returns its session cookie.
+func loginAs(t *testing.T, ts *httptest.Server, email, role string) *http.Cookie {
+	t.Helper()
+	pass := "secretpass"
+	hash, _ := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.MinCost)
+	if err := db.DB.Create(&models.User{Email: email, Password: string(hash), Role: role}).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	body, _ := json.Marshal(map[string]string{"email": email, "password": pass})
+	resp, err := http.Post(ts.URL+"/api/v1/auth/login", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	for _, c := range resp.Cookies() {
+		if c.Name == "dsess" {
+			return c
+		}
+	}
+	t.Fatalf("no session cookie for %s", email)
+	return nil
+}
+
+func TestSessionCookieRejectedWithDifferentSecret(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	prev := secret
+	t.Cleanup(func() { SetSessionSecret(prev) })
+	SetSessionSecret([]byte("secret-a"))
+	cookie := loginAs(t, ts, "rotate@example.com", "viewer")
+	me := func() int {
+		req, _ := http.NewRequest("GET", ts.URL+"/api/v1/auth/me", nil)
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := me(); code != 200 {
+		t.Fatalf("/me with secret A status=%d", code)
+	}
+	SetSessionSecret([]byte("secret-b"))
+	if code := me(); code != 401 {
+		t.Fatalf("/me after switching to secret B status=%d, want 401", code)
+	}
+}
+
+func TestSessionsPersistAndExpire(t *testing.T) {
+	ts, cfg := setupTestServer(t)
+	defer ts.Close()
+	cookie := loginAs(t, ts, "persist@example.com", "viewer")
+	me := func(ts *httptest.Server) int {
+		req, _ := http.NewRequest("GET", ts.URL+"/api/v1/auth/me", nil)
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	// a restarted server reads the same sessions table
+	restarted := httptest.NewServer(Router(cfg, logging.New("test")))
+	defer restarted.Close()
+	if code := me(restarted); code != 200 {
+		t.Fatalf("/me after restart status=%d", code)
+	}
+
+	db.DB.Model(&models.Session{}).Where("1 = 1").Update("expires_at", time.Now().Add(-time.Minute))
+	if code := me(ts); code != 401 {
+		t.Fatalf("/me with expired session status=%d, want 401", code)
+	}
+	if err := pruneSessions(); err != nil {
+		t.Fatal(err)
+	}
+	var n int64
+	db.DB.Model(&models.Session{}).Count(&n)
+	if n != 0 {
+		t.Fatalf("%d sessions left after pruning", n)
+	}
+
+	// logout deletes the row
+	cookie = loginAs(t, ts, "persist2@example.com", "viewer")
+	req, _ := http.NewRequest("POST", ts.URL+"/api/v1/auth/logout", nil)
+	req.AddCookie(cookie)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if db.DB.Model(&models.Session{}).Count(&n); n != 0 || me(ts) != 401 {
+		t.Fatalf("after logout: %d sessions", n)
+	}
+}
+
+func TestReadReplicaServesListQueries(t *testing.T) {
+	replicaPath := filepath.Join(t.TempDir(), "replica.db")
+	rdb, err := gorm.Open(sqlite.Open(replicaPath), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rdb.AutoMigrate(&models.Provider{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := rdb.Create(&models.Provider{Name: "replica-only", Endpoint: "r:9000"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	ts, _ := setupTestServer(t, func(c *config.Config) { c.DBReplicaDsn = replicaPath })
+	defer ts.Close()
+	cookie := loginAs(t, ts, "reader@example.com", "viewer")
+	list := func(query string) []models.Provider {
+		req, _ := http.NewRequest("GET", ts.URL+"/api/v1/providers"+query, nil)
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var env struct {
+			Data []models.Provider `json:"data"`
+		}
+		json.NewDecoder(resp.Body).Decode(&env)
+		return env.Data
+	}
+	if got := list(""); len(got) != 1 || got[0].Name != "replica-only" {
+		t.Fatalf("expected replica provider, got %+v", got)
+	}
+	if got := list("?preferPrimary=true"); len(got) != 0 {
+		t.Fatalf("expected empty primary list, got %+v", got)
+	}
+}
+
+func TestProviderListCacheInvalidation(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	cookie := loginAs(t, ts, "cache-editor@example.com", "editor")
+	do := func(method, path, body string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, ts.URL+"/api/v1"+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	count := func() (int, string) {
+		t.Helper()
+		resp := do("GET", "/providers", "")
+		defer resp.Body.Close()
+		var env struct {
+			Data []models.Provider `json:"data"`
+		}
+		json.NewDecoder(resp.Body).Decode(&env)
+		return len(env.Data), resp.Header.Get("ETag")
+	}
+	if n, etag := count(); n != 0 || etag == "" {
+		t.Fatalf("expected an empty, cached list with an ETag, got %d %q", n, etag)
+	}
+	// written behind the API's back: the cached list is still served
+	db.DB.Create(&models.Provider{Name: "direct", Endpoint: "d:9000"})
+	if n, _ := count(); n != 0 {
+		t.Fatalf("expected the cached list, got %d providers", n)
+	}
+	resp := do("POST", "/providers", `{"name":"via-api","type":"minio","endpoint":"m:9000"}`)
+	resp.Body.Close()
+	if resp.StatusCode != 201 {
+		t.Fatalf("create: %d", resp.StatusCode)
+	}
+	if n, _ := count(); n != 2 {
+		t.Fatalf("create did not invalidate the cached list: %d providers", n)
+	}
+}
+
+func TestProviderTags(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	cookie := loginAs(t, ts, "tags-editor@example.com", "editor")
+	do := func(method, path, body string) (int, []byte) {
+		t.Helper()
+		req, _ := http.NewRequest(method, ts.URL+"/api/v1"+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, b
+	}
+	if code, body := do("POST", "/providers", `{"name":"a","type":"minio","endpoint":"a:9000","tags":["prod","aws"]}`); code != 201 {
+		t.Fatalf("create: %d %s", code, body)
+	}
+	code, body := do("POST", "/providers", `{"name":"b","type":"minio","endpoint":"b:9000","tags":["dev"]}`)
+	var b models.Provider
+	if err := json.Unmarshal(body, &b); err != nil || code != 201 {
+		t.Fatalf("create: %d %s", code, body)
+	}
+	db.DB.Create(&models.Provider{Name: "untagged", Endpoint: "c:9000"})
+
+	list := func(query string) []string {
+		t.Helper()
+		code, body := do("GET", "/providers"+query, "")
+		var env struct {
+			Data []models.Provider `json:"data"`
+		}
+		if err := json.Unmarshal(body, &env); err != nil || code != 200 {
+			t.Fatalf("list %s: %d %s", query, code, body)
+		}
+		var names []string
+		for _, p := range env.Data {
+			names = append(names, p.Name)
+		}
+		return names
+	}
+	if got := list("?tag=prod"); len(got) != 1 || got[0] != "a" {
+		t.Fatalf("tag=prod: got %v", got)
+	}
+	if got := list("?tag=pro"); len(got) != 0 {
+		t.Fatalf("tags must match whole values, got %v", got)
+	}
+	if code, body := do("PUT", fmt.Sprintf("/providers/%d", b.ID), `{"tags":["dev","prod"]}`); code != 200 {
+		t.Fatalf("update: %d %s", code, body)
+	}
+	if got := list("?tag=prod"); len(got) != 2 {
+		t.Fatalf("tag=prod after update: got %v", got)
+	}
+	if code, _ := do("PUT", fmt.Sprintf("/providers/%d", b.ID), `{"tags":"prod"}`); code != 400 {
+		t.Fatalf("tags as a string: expected 400, got %d", code)
+	}
+	code, body = do("GET", "/providers/tags", "")
+	var tags struct {
+		Data []string `json:"data"`
+	}
+	if err := json.Unmarshal(body, &tags); err != nil || code != 200 || strings.Join(tags.Data, ",") != "aws,dev,prod" {
+		t.Fatalf("distinct tags: %d %s", code, body)
+	}
+}
+
+func TestTraceListKeysetPagination(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	for i := 1; i <= 5; i++ {
+		id := fmt.Sprintf("%032d", i) // sorts before any real time-based trace ID
+		if err := db.DB.Create(&models.TraceRow{ID: id, Method: "GET", Path: "/seed", Status: 200}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	cookie := loginAs(t, ts, "pager@example.com", "viewer")
+	page := func(query string) (ids []string, next string, more bool) {
+		req, _ := http.NewRequest("GET", ts.URL+"/api/v1/trace/list"+query, nil)
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out struct {
+			Traces     []Trace `json:"traces"`
+			NextCursor string  `json:"nextCursor"`
+			HasMore    bool    `json:"hasMore"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+		for _, tr := range out.Traces {
+			ids = append(ids, tr.ID)
+		}
+		return ids, out.NextCursor, out.HasMore
+	}
+	// first page: the login trace plus the two newest seeded rows
+	ids, next, more := page("?limit=3")
+	if len(ids) != 3 || ids[1] != fmt.Sprintf("%032d", 5) || !more || next != fmt.Sprintf("%032d", 4) {
+		t.Fatalf("unexpected first page ids=%v next=%s more=%v", ids, next, more)
+	}
+	ids, next, more = page("?limit=3&cursor=" + next)
+	if len(ids) != 3 || ids[0] != fmt.Sprintf("%032d", 3) || more || next != fmt.Sprintf("%032d", 1) {
+		t.Fatalf("unexpected last page ids=%v next=%s more=%v", ids, next, more)
+	}
+	// newer than the oldest seeded row, still newest-first
+	ids, _, _ = page("?limit=2&firstCursor=" + fmt.Sprintf("%032d", 1))
+	if len(ids) != 2 || ids[0] != fmt.Sprintf("%032d", 3) || ids[1] != fmt.Sprintf("%032d", 2) {
+		t.Fatalf("unexpected firstCursor page ids=%v", ids)
+	}
+	req, _ := http.NewRequest("GET", ts.URL+"/api/v1/trace/list?cursor=unknown", nil)
+	req.AddCookie(cookie)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 400 {
+		t.Fatalf("unknown cursor: %d, want 400", resp.StatusCode)
+	}
+
+	// 16-char random IDs from before time-prefixed IDs still page in start order
+	now := time.Now()
+	legacy := []models.TraceRow{
+		{ID: "ffffffffffffffff", Started: now.Add(-2 * time.Hour)},
+		{ID: "18d0000000000000aaaaaaaaaaaaaaaa", Started: now.Add(-time.Hour)},
+		{ID: "00aaaaaaaaaaaaaa", Started: now.Add(-3 * time.Hour)},
+	}
+	for _, row := range legacy {
+		row.Method, row.Path, row.Status = "GET", "/legacy", 200
+		if err := db.DB.Create(&row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	var walked []string
+	for cursor, more := "", true; more; {
+		ids, cursor, more = page("?path=/legacy&limit=1&cursor=" + cursor)
+		walked = append(walked, ids...)
+	}
+	if want := []string{legacy[1].ID, legacy[0].ID, legacy[2].ID}; !slices.Equal(walked, want) {
+		t.Fatalf("legacy pages %v, want %v", walked, want)
+	}
+	if ids, _, _ := page("?path=/legacy&firstCursor=" + legacy[2].ID); !slices.Equal(ids, []string{legacy[1].ID, legacy[0].ID}) {
+		t.Fatalf("legacy firstCursor page %v", ids)
+	}
+}
+
+func TestTimezoneHeader(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	started := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	id := fmt.Sprintf("%032d", 7)
+	if err := db.DB.Create(&models.TraceRow{ID: id, Method: "GET", Path: "/seed", Status: 200, Started: started, Ended: started.Add(time.Second)}).Error; err != nil {
+		t.Fatal(err)
+	}
+	cookie := loginAs(t, ts, "tz@example.com", "viewer")
+	get := func(path, zone string) *http.Response {
+		req, _ := http.NewRequest("GET", ts.URL+"/api/v1"+path, nil)
+		req.AddCookie(cookie)
+		if zone != "" {
+			req.Header.Set("X-Timezone", zone)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	startedIn := func(zone string) string {
+		resp := get("/trace/"+id, zone)
+		defer resp.Body.Close()
+		var out struct {
+			Data struct {
+				Started string `json:"started"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+		return out.Data.Started
+	}
+	utc, ny := startedIn("UTC"), startedIn("America/New_York")
+	if utc != "2024-01-15T12:00:00Z" || ny != "2024-01-15T07:00:00-05:00" {
+		t.Fatalf("unexpected timestamps utc=%s ny=%s", utc, ny)
+	}
+	if resp := get("/trace/"+id, "Mars/Olympus_Mons"); resp.StatusCode != 400 {
+		t.Fatalf("expected 400 for an unknown zone, got %d", resp.StatusCode)
+	}
+	resp, err := http.Get(ts.URL + "/api/v1/timezones")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var zones struct {
+		Data []string `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&zones); err != nil || resp.StatusCode != 200 {
+		t.Fatalf("timezones: status=%d err=%v", resp.StatusCode, err)
+	}
+	if len(zones.Data) > 0 && !slices.Contains(zones.Data, "America/New_York") {
+		t.Fatalf("America/New_York missing from %d zones", len(zones.Data))
+	}
+}
+
+func TestTraceLinksLogs(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	id := fmt.Sprintf("%032d", 8)
+	started := time.Now().UTC()
+	if err := db.DB.Create(&models.TraceRow{ID: id, Method: "GET", Path: "/seed", Status: 500, Started: started}).Error; err != nil {
+		t.Fatal(err)
+	}
+	logs := []models.LogEntry{
+		{Time: started.Add(time.Millisecond), Level: "info", Msg: "http_request", Fields: `{"traceId":"` + id + `"}`},
+		{Time: started, Level: "error", Msg: "upload failed", Fields: `{"traceId":"` + id + `"}`},
+		{Time: started, Level: "info", Msg: "unrelated", Fields: `{"traceId":"other"}`},
+	}
+	if err := db.DB.Create(&logs).Error; err != nil {
+		t.Fatal(err)
+	}
+	cookie := loginAs(t, ts, "logs-trace@example.com", "viewer")
+	get := func(path string, out any) {
+		req, _ := http.NewRequest("GET", ts.URL+"/api/v1"+path, nil)
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil || resp.StatusCode != 200 {
+			t.Fatalf("%s: status=%d err=%v", path, resp.StatusCode, err)
+		}
+	}
+	var tr struct {
+		Data struct {
+			ID       string `json:"id"`
+			LogCount int64  `json:"logCount"`
+			LogsURL  string `json:"logsUrl"`
+		} `json:"data"`
+	}
+	get("/trace/"+id, &tr)
+	if tr.Data.ID != id || tr.Data.LogCount != 2 || tr.Data.LogsURL != "/api/v1/logs/by-trace/"+id {
+		t.Fatalf("unexpected trace links: %+v", tr.Data)
+	}
+	var list struct {
+		Data []struct {
+			Msg string `json:"msg"`
+		} `json:"data"`
+		Pagination struct {
+			Total int `json:"total"`
+		} `json:"pagination"`
+	}
+	get(strings.TrimPrefix(tr.Data.LogsURL, "/api/v1"), &list)
+	if list.Pagination.Total != 2 || len(list.Data) != 2 || list.Data[0].Msg != "upload failed" {
+		t.Fatalf("unexpected logs for trace: %+v", list)
+	}
+}
+
+func TestMultiTenantIsolation(t *testing.T) {
+	ts, _ := setupTestServer(t, func(c *config.Config) {
+		c.MultiTenant = true
+		c.AllowedTenants = "team-a,team-b"
+	})
+	defer ts.Close()
+	tenantUser := func(email, role, tenant string) *http.Cookie {
+		c := loginAs(t, ts, email, role)
+		if err := db.DB.Model(&models.User{}).Where("email = ?", email).Update("tenant_id", tenant).Error; err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	adminA := tenantUser("a@example.com", "admin", "team-a")
+	adminB := tenantUser("b@example.com", "admin", "team-b")
+	super := tenantUser("root@example.com", "superadmin", "")
+	do := func(method, path string, c *http.Cookie, tenant string, body any) *http.Response {
+		var rd *bytes.Reader
+		if body != nil {
+			b, _ := json.Marshal(body)
+			rd = bytes.NewReader(b)
+		} else {
+			rd = bytes.NewReader(nil)
+		}
+		req, _ := http.NewRequest(method, ts.URL+"/api/v1"+path, rd)
+		req.AddCookie(c)
+		if tenant != "" {
+			req.Header.Set("X-Hermes-Tenant", tenant)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	resp := do("POST", "/providers", adminA, "", map[string]any{"name": "a-store", "endpoint": "http://127.0.0.1:1"})
+	var p models.Provider
+	json.NewDecoder(resp.Body).Decode(&p)
+	resp.Body.Close()
+	if resp.StatusCode != 201 || p.TenantID != "team-a" {
+		t.Fatalf("create provider status=%d tenant=%q", resp.StatusCode, p.TenantID)
+	}
+	listCount := func(c *http.Cookie, tenant string) int {
+		resp := do("GET", "/providers", c, tenant, nil)
+		defer resp.Body.Close()
+		var env struct {
+			Data []models.Provider `json:"data"`
+		}
+		json.NewDecoder(resp.Body).Decode(&env)
+		return len(env.Data)
+	}
+	if n := listCount(adminB, ""); n != 0 {
+		t.Fatalf("team-b admin sees %d providers of team-a", n)
+	}
+	if resp := do("GET", fmt.Sprintf("/providers/%d", p.ID), adminB, "", nil); resp.StatusCode != 404 {
+		t.Fatalf("team-b admin fetching team-a provider status=%d, want 404", resp.StatusCode)
+	}
+	if resp := do("GET", "/providers", adminB, "team-a", nil); resp.StatusCode != 403 {
+		t.Fatalf("team-b admin switching tenant status=%d, want 403", resp.StatusCode)
+	}
+	if resp := do("GET", "/providers", adminA, "team-x", nil); resp.StatusCode != 400 {
+		t.Fatalf("unknown tenant status=%d, want 400", resp.StatusCode)
+	}
+	if n := listCount(super, ""); n != 1 {
+		t.Fatalf("superadmin without tenant header sees %d providers, want 1", n)
+	}
+	if n := listCount(super, "team-b"); n != 0 {
+		t.Fatalf("superadmin scoped to team-b sees %d providers, want 0", n)
+	}
+
+	// request logs are persisted with the tenant of their trace and scoped by it
+	logPaths := func(c *http.Cookie) []string {
+		resp := do("GET", "/logs/recent?q=http_request&limit=1000", c, "", nil)
+		defer resp.Body.Close()
+		var env struct {
+			Data []struct {
+				Fields map[string]any `json:"fields"`
+			} `json:"data"`
+		}
+		json.NewDecoder(resp.Body).Decode(&env)
+		var paths []string
+		for _, e := range env.Data {
+			if p, _ := e.Fields["path"].(string); p != "" {
+				paths = append(paths, p)
+			}
+		}
+		return paths
+	}
+	teamB := fmt.Sprintf("/api/v1/providers/%d", p.ID) // only fetched by the team-b admin
+	var all []string
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		logging.Flush()
+		if all = logPaths(super); slices.Contains(all, teamB) {
+			break
+		}
+	}
+	if !slices.Contains(all, teamB) {
+		t.Fatalf("team-b request log not persisted: %v", all)
+	}
+	if pathsA := logPaths(adminA); !slices.Contains(pathsA, "/api/v1/providers") || slices.Contains(pathsA, teamB) {
+		t.Fatalf("team-a sees request logs %v", pathsA)
+	}
+	// the in-memory log download is scoped the same way
+	downloaded := func(c *http.Cookie) []string {
+		resp := do("GET", "/logs/download", c, "", nil)
+		defer resp.Body.Close()
+		var paths []string
+		for dec := json.NewDecoder(resp.Body); dec.More(); {
+			var e struct {
+				Fields map[string]any `json:"fields"`
+			}
+			if dec.Decode(&e) != nil {
+				break
+			}
+			if p, _ := e.Fields["path"].(string); p != "" {
+				paths = append(paths, p)
+			}
+		}
+		return paths
+	}
+	if paths := downloaded(adminA); !slices.Contains(paths, "/api/v1/providers") || slices.Contains(paths, teamB) {
+		t.Fatalf("team-a downloads request logs %v", paths)
+	}
+	if paths := downloaded(super); !slices.Contains(paths, teamB) {
+		t.Fatalf("superadmin download misses team-b logs: %v", paths)
+	}
+
+	// settings that affect every tenant are reserved to super-admins
+	for _, rt := range []struct{ method, path string }{
+		{"GET", "/auth/fed/config"},
+		{"PUT", "/logs/level"},
+		{"PUT", "/logs/levels"},
+		{"POST", "/admin/db/migrations/rollback"},
+		{"GET", "/admin/cache/s3-clients"},
+	} {
+		if resp := do(rt.method, rt.path, adminA, "", map[string]any{}); resp.StatusCode != 403 {
+			t.Errorf("tenant admin %s %s status=%d, want 403", rt.method, rt.path, resp.StatusCode)
+		}
+	}
+	if resp := do("GET", "/admin/cache/s3-clients", super, "", nil); resp.StatusCode != 200 {
+		t.Fatalf("superadmin client cache status=%d", resp.StatusCode)
+	}
+}
+
+func TestTraceExportCSV(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	for i, st := range []int{200, 404, 500, 503} {
+		row := models.TraceRow{ID: fmt.Sprintf("%032d", i+1), Method: "GET", Path: "/api/v1/export-seed", Status: st, UserEmail: "ops@example.com"}
+		if err := db.DB.Create(&row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	cookie := loginAs(t, ts, "exporter@example.com", "editor")
+	req, _ := http.NewRequest("GET", ts.URL+"/api/v1/trace/export.csv?status=5xx&path=export-seed", nil)
+	req.AddCookie(cookie)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 || resp.Header.Get("Content-Type") != "text/csv" {
+		t.Fatalf("status=%d content-type=%q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	recs, err := csv.NewReader(resp.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 3 || recs[0][0] != "id" || recs[0][9] != "durationMs" {
+		t.Fatalf("unexpected csv: %v", recs)
+	}
+	for _, rec := range recs[1:] {
+		if rec[3] != "500" && rec[3] != "503" {
+			t.Fatalf("unexpected status in export: %v", rec)
+		}
+	}
+	// viewers may not export
+	viewer := loginAs(t, ts, "viewer-export@example.com", "viewer")
+	req, _ = http.NewRequest("GET", ts.URL+"/api/v1/trace/export.csv", nil)
+	req.AddCookie(viewer)
+	resp2, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp2.Body.Close()
+	if resp2.StatusCode != 403 {
+		t.Fatalf("viewer export status=%d, want 403", resp2.StatusCode)
+	}
+}
+
+func TestAdminExport(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		row := models.TraceRow{ID: fmt.Sprintf("export-%d", i), Method: "GET", Path: "/x", Status: 200, UserAgent: "curl", Started: base.Add(time.Duration(i) * time.Minute)}
+		if err := db.DB.Create(&row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		e := models.LogEntry{Time: base.Add(time.Duration(i) * time.Minute), Level: "info", Msg: "seeded, with comma", Fields: `{"n":1}`}
+		if err := db.DB.Create(&e).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	prev := exportMaxRows
+	exportMaxRows = 2
+	defer func() { exportMaxRows = prev }()
+	admin := loginAs(t, ts, "export-admin@example.com", "admin")
+	get := func(cookie *http.Cookie, path string) (*http.Response, []byte) {
+		req, _ := http.NewRequest("GET", ts.URL+path, nil)
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp, b
+	}
+	window := "from=2020-01-01T00:00:00Z&to=2020-01-01T01:00:00Z"
+
+	resp, body := get(admin, "/api/v1/admin/export/traces?"+window)
+	if resp.StatusCode != 200 || resp.Header.Get("X-Truncated") != "true" {
+		t.Fatalf("status=%d truncated=%q", resp.StatusCode, resp.Header.Get("X-Truncated"))
+	}
+	if cd := resp.Header.Get("Content-Disposition"); cd != `attachment; filename="hermes-traces-2020-01-01T00:00:00Z-2020-01-01T01:00:00Z.ndjson"` {
+		t.Fatalf("content-disposition %q", cd)
+	}
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	var first models.TraceRow
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[0]), &first) != nil || first.ID != "export-0" || first.UserAgent != "curl" {
+		t.Fatalf("unexpected ndjson: %q", body)
+	}
+
+	resp, body = get(admin, "/api/v1/admin/export/logs?format=csv&"+window)
+	recs, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+	if err != nil || resp.StatusCode != 200 || resp.Header.Get("X-Truncated") != "" {
+		t.Fatalf("status=%d truncated=%q err=%v", resp.StatusCode, resp.Header.Get("X-Truncated"), err)
+	}
+	if len(recs) != 3 || recs[0][3] != "msg" || recs[1][3] != "seeded, with comma" || recs[1][4] != `{"n":1}` {
+		t.Fatalf("unexpected csv: %v", recs)
+	}
+
+	if resp, _ := get(admin, "/api/v1/admin/export/logs?format=parquet"); resp.StatusCode != 400 {
+		t.Fatalf("unknown format: expected 400, got %d", resp.StatusCode)
+	}
+	editor := loginAs(t, ts, "export-editor@example.com", "editor")
+	if resp, _ := get(editor, "/api/v1/admin/export/traces"); resp.StatusCode != 403 {
+		t.Fatalf("editor export: expected 403, got %d", resp.StatusCode)
+	}
+}
+
+func TestAdminMigrations(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	admin := loginAs(t, ts, "migrations-admin@example.com", "admin")
+	do := func(method, path string) (int, []byte) {
+		req, _ := http.NewRequest(method, ts.URL+path, nil)
+		req.AddCookie(admin)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, b
+	}
+	code, body := do("GET", "/api/v1/admin/db/migrations")
+	var out struct {
+		Data []models.Migration `json:"data"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil || code != 200 || len(out.Data) == 0 || out.Data[0].Version != "001" {
+		t.Fatalf("status=%d body=%s", code, body)
+	}
+	// later migrations roll back one at a time; the initial schema is not reversible
+	for i := range out.Data[1:] {
+		if code, body := do("POST", "/api/v1/admin/db/migrations/rollback"); code != 200 {
+			t.Fatalf("rollback: expected 200, got %d %s", code, body)
+		}
+		if i == 0 {
+			last := out.Data[len(out.Data)-1]
+			var e models.AuditLog
+			if err := db.DB.Where("action = ?", "db.migration.rollback").Last(&e).Error; err != nil || e.ResourceType != "migration" || e.ResourceID != last.Version || e.ActorEmail != "migrations-admin@example.com" {
+				t.Fatalf("rollback audit row: %+v %v", e, err)
+			}
+		}
+		if !db.DB.Migrator().HasTable(&models.Session{}) {
+			// rolling back the sessions table signed everyone out, this admin included
+			db.DB.AutoMigrate(&models.Session{})
+			admin = loginAs(t, ts, "migrations-admin2@example.com", "admin")
+		}
+	}
+	if code, body := do("POST", "/api/v1/admin/db/migrations/rollback"); code != 409 {
+		t.Fatalf("rollback of 001: expected 409, got %d %s", code, body)
+	}
+}
+
+func TestPutBucketPolicyRejectsInvalidJSON(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	p := models.Provider{Name: "p", Endpoint: "http://127.0.0.1:1"}
+	if err := db.DB.Create(&p).Error; err != nil {
+		t.Fatal(err)
+	}
+	put := func(cookie *http.Cookie, body string) int {
+		req, _ := http.NewRequest("PUT", fmt.Sprintf("%s/api/v1/providers/%d/buckets/b1/policy", ts.URL, p.ID), bytes.NewReader([]byte(body)))
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := put(loginAs(t, ts, "pol-viewer@example.com", "viewer"), `{}`); code != 403 {
+		t.Fatalf("viewer status=%d, want 403", code)
+	}
+	if code := put(loginAs(t, ts, "pol-editor@example.com", "editor"), `{"Version":`); code != 400 {
+		t.Fatalf("invalid policy status=%d, want 400", code)
+	}
+}
+
+func TestListResponseEnvelope(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	cookie := loginAs(t, ts, "env@example.com", "admin")
+	req, _ := http.NewRequest("GET", ts.URL+"/api/v1/users", nil)
+	req.AddCookie(cookie)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var env struct {
+		Data       []models.User `json:"data"`
+		RequestID  string        `json:"requestId"`
+		Timestamp  string        `json:"timestamp"`
+		Pagination *struct {
+			Total int `json:"total"`
+		} `json:"pagination"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		t.Fatal(err)
+	}
+	if env.RequestID == "" || env.RequestID != resp.Header.Get("X-Trace-Id") {
+		t.Fatalf("requestId=%q, X-Trace-Id=%q", env.RequestID, resp.Header.Get("X-Trace-Id"))
+	}
+	if env.Timestamp == "" || env.Pagination == nil || env.Pagination.Total != len(env.Data) || len(env.Data) == 0 {
+		t.Fatalf("unexpected envelope %+v", env)
+	}
+}
+
+func TestBucketACL(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	p := models.Provider{Name: "acl", Endpoint: "127.0.0.1:1"}
+	if err := db.DB.Create(&p).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"open", "team"} {
+		if err := db.DB.Create(&models.Bucket{ProviderID: p.ID, Name: name}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	admin := loginAs(t, ts, "acl-admin@example.com", "admin")
+	viewer := loginAs(t, ts, "acl-viewer@example.com", "viewer")
+	member := loginAs(t, ts, "acl-member@example.com", "viewer")
+	do := func(method, path string, c *http.Cookie, body any) *http.Response {
+		b, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, ts.URL+"/api/v1"+path, bytes.NewReader(b))
+		req.AddCookie(c)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	aclPath := fmt.Sprintf("/providers/%d/buckets/team/acl", p.ID)
+	if resp := do("POST", aclPath, viewer, map[string]string{"subject": "acl-viewer@example.com", "permission": "write"}); resp.StatusCode != 403 {
+		t.Fatalf("viewer creating acl status=%d, want 403", resp.StatusCode)
+	}
+	if resp := do("POST", aclPath, admin, map[string]string{"subject": "acl-member@example.com", "permission": "read"}); resp.StatusCode != 201 {
+		t.Fatalf("create acl status=%d", resp.StatusCode)
+	}
+	var audit models.AuditLog
+	if err := db.DB.Where("action = ?", "bucket.acl.create").Last(&audit).Error; err != nil || audit.ActorEmail != "acl-admin@example.com" {
+		t.Fatalf("bucket.acl.create audit row: %+v %v", audit, err)
+	}
+	if resp := do("POST", aclPath, admin, map[string]string{"subject": "role:owner", "permission": "read"}); resp.StatusCode != 400 {
+		t.Fatalf("unknown role subject status=%d, want 400", resp.StatusCode)
+	}
+	// the ACL is checked before the provider is contacted
+	if resp := do("GET", fmt.Sprintf("/providers/%d/buckets/team/objects", p.ID), viewer, nil); resp.StatusCode != 403 {
+		t.Fatalf("viewer listing restricted bucket status=%d, want 403", resp.StatusCode)
+	}
+	var u models.User
+	db.DB.Where("email = ?", "acl-member@example.com").First(&u)
+	if ok, _ := aclCheck(db.DB, p.ID, "team", &u, aclRead); !ok {
+		t.Fatal("member should be able to read")
+	}
+	if ok, _ := aclCheck(db.DB, p.ID, "team", &u, aclWrite); ok {
+		t.Fatal("read entry must not grant write")
+	}
+	if ok, _ := aclCheck(db.DB, p.ID, "open", &u, aclWrite); !ok {
+		t.Fatal("bucket without entries should fall back to RBAC")
+	}
+	names := func(c *http.Cookie) []string {
+		req, _ := http.NewRequest("GET", ts.URL+"/api/v1/me/accessible-buckets", nil)
+		req.AddCookie(c)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var env struct {
+			Data []models.Bucket `json:"data"`
+		}
+		json.NewDecoder(resp.Body).Decode(&env)
+		var out []string
+		for _, b := range env.Data {
+			out = append(out, b.Name)
+		}
+		return out
+	}
+	if got := names(viewer); len(got) != 1 || got[0] != "open" {
+		t.Fatalf("viewer accessible buckets = %v, want [open]", got)
+	}
+	if got := names(member); len(got) != 2 {
+		t.Fatalf("member accessible buckets = %v, want both", got)
+	}
+}
+
+// TestBucketACLObjectRoutes checks that a restricted bucket cannot be read or written
+// through the routes that take a second bucket or read object content.
+func TestBucketACLObjectRoutes(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	m := useMockS3(t)
+	m.OnCopyObject = func(srcBucket, srcKey, dstBucket, dstKey string, sse encrypt.ServerSide) error {
+		t.Errorf("copy %s/%s reached the provider", srcBucket, srcKey)
+		return nil
+	}
+	m.OnSelectObject = func(bucket, key, query, inputFormat, outputFormat, csvDelimiter string) (io.ReadCloser, error) {
+		t.Errorf("select on %s/%s reached the provider", bucket, key)
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	pid := mockProvider(t)
+	if err := db.DB.Create(&models.BucketACL{ProviderID: pid, Bucket: "secret", Subject: "role:admin", Permission: aclWrite}).Error; err != nil {
+		t.Fatal(err)
+	}
+	editor := loginAs(t, ts, "acl-routes-editor@example.com", "editor")
+	post := func(path, body string) int {
+		t.Helper()
+		req, _ := http.NewRequest("POST", fmt.Sprintf("%s/api/v1/providers/%d/buckets/%s", ts.URL, pid, path), strings.NewReader(body))
+		req.AddCookie(editor)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	for name, tc := range map[string]struct{ path, body string }{
+		"copy out of a restricted bucket": {"secret/copy", `{"srcKey":"a.csv","dstBucket":"open"}`},
+		"copy into a restricted bucket":   {"open/copy", `{"srcKey":"a.csv","dstBucket":"secret"}`},
+		"move out of a restricted bucket": {"secret/move", `{"srcKey":"a.csv","dstBucket":"open"}`},
+		"select on a restricted bucket":   {"secret/objects/select", `{"key":"a.csv","query":"SELECT * FROM S3Object","inputFormat":"CSV"}`},
+	} {
+		if code := post(tc.path, tc.body); code != 403 {
+			t.Errorf("%s: %d, want 403", name, code)
+		}
+	}
+}
+
+func TestObjectStatsPopular(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	p := models.Provider{Name: "stats", Endpoint: "127.0.0.1:1"}
+	if err := db.DB.Create(&p).Error; err != nil {
+		t.Fatal(err)
+	}
+	pid := int(p.ID)
+	for i := 0; i < 3; i++ {
+		if err := recordDownload(pid, "b", "hot.txt", 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := recordDownload(pid, "b", "big.bin", 1000); err != nil {
+		t.Fatal(err)
+	}
+	cookie := loginAs(t, ts, "stats@example.com", "editor")
+	get := func(path string, out any) int {
+		req, _ := http.NewRequest("GET", ts.URL+"/api/v1"+path, nil)
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		json.NewDecoder(resp.Body).Decode(out)
+		return resp.StatusCode
+	}
+	var list struct {
+		Data []models.ObjectStat `json:"data"`
+	}
+	if code := get(fmt.Sprintf("/providers/%d/buckets/b/objects/popular", pid), &list); code != 200 || len(list.Data) != 2 || list.Data[0].Key != "hot.txt" || list.Data[0].DownloadCount != 3 {
+		t.Fatalf("popular by count: code=%d %+v", code, list.Data)
+	}
+	if code := get(fmt.Sprintf("/providers/%d/buckets/b/objects/popular?sortBy=bytes&limit=1", pid), &list); code != 200 || len(list.Data) != 1 || list.Data[0].Key != "big.bin" {
+		t.Fatalf("popular by bytes: code=%d %+v", code, list.Data)
+	}
+	var one struct {
+		Data models.ObjectStat `json:"data"`
+	}
+	if code := get(fmt.Sprintf("/providers/%d/buckets/b/objects/stats?key=hot.txt", pid), &one); code != 200 || one.Data.TotalBytesServed != 30 {
+		t.Fatalf("stats: code=%d %+v", code, one.Data)
+	}
+	// counters of a deleted provider are purged
+	db.DB.Delete(&models.Provider{}, p.ID)
+	if err := purgeObjectStats(); err != nil {
+		t.Fatal(err)
+	}
+	var n int64
+	db.DB.Model(&models.ObjectStat{}).Count(&n)
+	if n != 0 {
+		t.Fatalf("expected stats purged, %d left", n)
+	}
+}
+
+func TestStaleObjectsValidation(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	p := models.Provider{Name: "stale", Endpoint: "127.0.0.1:1"}
+	if err := db.DB.Create(&p).Error; err != nil {
+		t.Fatal(err)
+	}
+	base := fmt.Sprintf("%s/api/v1/providers/%d/buckets/b/objects/stale", ts.URL, p.ID)
+	do := func(cookie *http.Cookie, method, url, body string) int {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	viewer := loginAs(t, ts, "stale-viewer@example.com", "viewer")
+	if code := do(viewer, "GET", base, ""); code != 403 {
+		t.Fatalf("viewer: expected 403, got %d", code)
+	}
+	editor := loginAs(t, ts, "stale-editor@example.com", "editor")
+	if code := do(editor, "GET", base+"?daysSinceAccess=0", ""); code != 400 {
+		t.Fatalf("zero days: expected 400, got %d", code)
+	}
+	if code := do(editor, "POST", base+"/delete", `{"daysSinceAccess":-5}`); code != 400 {
+		t.Fatalf("negative days: expected 400, got %d", code)
+	}
+	if code := do(editor, "GET", fmt.Sprintf("%s/api/v1/providers/%d/buckets/b/objects/stale", ts.URL, p.ID+100), ""); code != 404 {
+		t.Fatalf("unknown provider: expected 404, got %d", code)
+	}
+}
+
+func TestTieringRecommendationsValidation(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	p := models.Provider{Name: "tiering", Endpoint: "127.0.0.1:1"}
+	if err := db.DB.Create(&p).Error; err != nil {
+		t.Fatal(err)
+	}
+	url := fmt.Sprintf("%s/api/v1/providers/%d/buckets/b/tiering-recommendations", ts.URL, p.ID)
+	do := func(cookie *http.Cookie, body string) int {
+		req, _ := http.NewRequest("GET", url, strings.NewReader(body))
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := do(loginAs(t, ts, "tier-viewer@example.com", "viewer"), ""); code != 403 {
+		t.Fatalf("viewer: expected 403, got %d", code)
+	}
+	editor := loginAs(t, ts, "tier-editor@example.com", "editor")
+	if code := do(editor, `{"iaPricePerGBMonth":-1}`); code != 400 {
+		t.Fatalf("negative price: expected 400, got %d", code)
+	}
+	if code := do(editor, `{not json`); code != 400 {
+		t.Fatalf("bad body: expected 400, got %d", code)
+	}
+}
+
+func TestProviderCACert(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	srv.Close()
+	caPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))
+	p := models.Provider{Name: "selfsigned", Type: "minio", Endpoint: "minio.local:9000"}
+	if err := db.DB.Create(&p).Error; err != nil {
+		t.Fatal(err)
+	}
+	url := fmt.Sprintf("%s/api/v1/providers/%d", ts.URL, p.ID)
+	do := func(cookie *http.Cookie, method, url string, body any) (int, string) {
+		b, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewReader(b))
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		out, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(out)
+	}
+	admin := loginAs(t, ts, "ca-admin@example.com", "admin")
+	editor := loginAs(t, ts, "ca-editor@example.com", "editor")
+	if code, _ := do(editor, "PUT", url+"/ca-cert", map[string]string{"caCertPem": caPEM}); code != 403 {
+		t.Fatalf("editor put: expected 403, got %d", code)
+	}
+	if code, _ := do(admin, "PUT", url+"/ca-cert", map[string]string{"caCertPem": "garbage"}); code != 400 {
+		t.Fatalf("invalid pem: expected 400, got %d", code)
+	}
+	if code, body := do(admin, "PUT", url+"/ca-cert", map[string]string{"caCertPem": caPEM}); code != 200 || !strings.Contains(body, `"configured":true`) {
+		t.Fatalf("put: code=%d body=%s", code, body)
+	}
+	if code, body := do(editor, "GET", url+"/ca-cert", nil); code != 200 || !strings.Contains(body, `"configured":true`) || strings.Contains(body, "CERTIFICATE") {
+		t.Fatalf("get: code=%d body=%s", code, body)
+	}
+	if _, body := do(admin, "GET", url, nil); strings.Contains(body, "CERTIFICATE") {
+		t.Fatalf("provider response leaks the CA: %s", body)
+	}
+	// a regular provider update keeps the CA
+	if code, _ := do(admin, "PUT", url, map[string]any{"name": "renamed"}); code != 200 {
+		t.Fatalf("update: %d", code)
+	}
+	var got models.Provider
+	db.DB.First(&got, p.ID)
+	if got.CACertPEM == "" {
+		t.Fatal("provider update dropped the CA")
+	}
+	if code, body := do(admin, "PUT", url+"/ca-cert", map[string]string{"caCertPem": ""}); code != 200 || !strings.Contains(body, `"configured":false`) {
+		t.Fatalf("clear: code=%d body=%s", code, body)
+	}
+}
+
+func TestProviderFailoverSwitch(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	admin := loginAs(t, ts, "fo-admin@example.com", "admin")
+	editor := loginAs(t, ts, "fo-editor@example.com", "editor")
+	do := func(cookie *http.Cookie, method, path string, body any) (int, string) {
+		b, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, ts.URL+"/api/v1"+path, bytes.NewReader(b))
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		out, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(out)
+	}
+	if code, body := do(admin, "POST", "/providers", map[string]any{"name": "ha", "type": "minio", "endpoint": "minio-a:9000", "failoverEnabled": true}); code != 400 || !strings.Contains(body, "secondaryEndpoint") {
+		t.Fatalf("missing secondary: code=%d body=%s", code, body)
+	}
+	var p models.Provider
+	code, body := do(admin, "POST", "/providers", map[string]any{"name": "ha", "type": "minio", "endpoint": "minio-a:9000", "secondaryEndpoint": "minio-b:9000", "failoverEnabled": true})
+	if code != 201 {
+		t.Fatalf("create: code=%d body=%s", code, body)
+	}
+	json.Unmarshal([]byte(body), &p)
+	plain := models.Provider{Name: "single", Type: "minio", Endpoint: "minio-c:9000"}
+	db.DB.Create(&plain)
+
+	if code, _ := do(editor, "POST", fmt.Sprintf("/providers/%d/failover/switch", p.ID), nil); code != 403 {
+		t.Fatalf("editor switch: expected 403, got %d", code)
+	}
+	if code, _ := do(admin, "POST", fmt.Sprintf("/providers/%d/failover/switch", plain.ID), nil); code != 409 {
+		t.Fatalf("switch without failover: expected 409, got %d", code)
+	}
+	if code, body := do(admin, "POST", fmt.Sprintf("/providers/%d/failover/switch", p.ID), nil); code != 200 || !strings.Contains(body, `"currentEndpoint":"minio-b:9000"`) {
+		t.Fatalf("switch: code=%d body=%s", code, body)
+	}
+	if code, body := do(editor, "GET", fmt.Sprintf("/providers/%d/failover", p.ID), nil); code != 200 || !strings.Contains(body, `"currentEndpoint":"minio-b:9000"`) {
+		t.Fatalf("status: code=%d body=%s", code, body)
+	}
+	// an automatic failover is counted on the health row
+	recordFailover(p.ID, "minio-b:9000", "minio-a:9000", errors.New("connection refused"))
+	var h models.ProviderHealth
+	db.DB.First(&h, "provider_id = ?", p.ID)
+	if h.CurrentEndpoint != "minio-a:9000" || h.FailoverCount != 1 || h.LastError != "connection refused" || h.LastFailoverAt == nil {
+		t.Fatalf("unexpected health: %+v", h)
+	}
+}
+
+func TestForcePasswordChange(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	admin := loginAs(t, ts, "force-admin@example.com", "admin")
+	user := loginAs(t, ts, "force-user@example.com", "viewer")
+	var u models.User
+	db.DB.Where("email = ?", "force-user@example.com").First(&u)
+	do := func(method, path string, c *http.Cookie, body any) (*http.Response, []byte) {
+		b, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, ts.URL+"/api/v1"+path, bytes.NewReader(b))
+		req.AddCookie(c)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var buf bytes.Buffer
+		buf.ReadFrom(resp.Body)
+		return resp, buf.Bytes()
+	}
+	forcePath := fmt.Sprintf("/admin/users/%d/force-password-change", u.ID)
+	if resp, _ := do("POST", forcePath, user, nil); resp.StatusCode != 403 {
+		t.Fatalf("viewer forcing password change status=%d, want 403", resp.StatusCode)
+	}
+	if resp, _ := do("POST", forcePath, admin, nil); resp.StatusCode != 200 {
+		t.Fatalf("force status=%d", resp.StatusCode)
+	}
+	resp, body := do("GET", "/providers", user, nil)
+	var gate map[string]string
+	json.Unmarshal(body, &gate)
+	if resp.StatusCode != 403 || gate["error"] != "password_change_required" {
+		t.Fatalf("gated request status=%d body=%s", resp.StatusCode, body)
+	}
+	resp, body = do("GET", "/auth/me", user, nil)
+	if resp.StatusCode != 200 || !bytes.Contains(body, []byte(`"mustChangePassword":true`)) {
+		t.Fatalf("me status=%d body=%s", resp.StatusCode, body)
+	}
+	if resp, body := do("POST", "/auth/change-password", user, map[string]string{"OldPassword": "secretpass", "NewPassword": "newsecretpass"}); resp.StatusCode != 200 {
+		t.Fatalf("change-password status=%d body=%s", resp.StatusCode, body)
+	}
+	if resp, _ := do("GET", "/providers", user, nil); resp.StatusCode != 200 {
+		t.Fatalf("after change status=%d, want 200", resp.StatusCode)
+	}
+}
+
+func TestSAMLTestMapping(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	admin := loginAs(t, ts, "saml-admin@example.com", "admin")
+	viewer := loginAs(t, ts, "saml-viewer@example.com", "viewer")
+	db.DB.Model(&models.AuthConfig{}).Where("1 = 1").Updates(map[string]any{"saml_group_claim": "memberOf", "saml_editor_values": "storage-editors", "default_role": "viewer"})
+	assertion := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">
+  <saml:Issuer>https://idp.example.com</saml:Issuer>
+  <saml:Assertion>
+    <saml:Issuer>https://idp.example.com</saml:Issuer>
+    <saml:Subject><saml:NameID>jdoe</saml:NameID></saml:Subject>
+    <saml:AttributeStatement>
+      <saml:Attribute Name="urn:oid:0.9.2342.19200300.100.1.3" FriendlyName="mail"><saml:AttributeValue>JDoe@Example.com</saml:AttributeValue></saml:Attribute>
+      <saml:Attribute Name="memberOf"><saml:AttributeValue>staff</saml:AttributeValue><saml:AttributeValue>storage-editors</saml:AttributeValue></saml:Attribute>
+    </saml:AttributeStatement>
+  </saml:Assertion>
+</samlp:Response>`
+	post := func(c *http.Cookie, body string) (*http.Response, []byte) {
+		req, _ := http.NewRequest("POST", ts.URL+"/api/v1/admin/auth/saml/test-mapping", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/xml")
+		req.AddCookie(c)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp, b
+	}
+	if resp, _ := post(viewer, assertion); resp.StatusCode != 403 {
+		t.Fatalf("viewer status=%d, want 403", resp.StatusCode)
+	}
+	resp, body := post(admin, assertion)
+	var out struct {
+		Data struct {
+			Email      string              `json:"email"`
+			MappedRole string              `json:"mappedRole"`
+			Attributes map[string][]string `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil || resp.StatusCode != 200 {
+		t.Fatalf("test-mapping status=%d body=%s", resp.StatusCode, body)
+	}
+	if out.Data.Email != "jdoe@example.com" || out.Data.MappedRole != "editor" || len(out.Data.Attributes["memberOf"]) != 2 || out.Data.Attributes["mail"][0] != "JDoe@Example.com" {
+		t.Fatalf("unexpected mapping: %+v", out.Data)
+	}
+	var n int64
+	db.DB.Model(&models.User{}).Where("email = ?", "jdoe@example.com").Count(&n)
+	if n != 0 {
+		t.Fatal("dry run created a user")
+	}
+	if resp, _ := post(admin, "<html>not saml</html>"); resp.StatusCode != 400 {
+		t.Fatalf("non-SAML body status=%d, want 400", resp.StatusCode)
+	}
+}
+
+func TestCORSPerRoutePolicies(t *testing.T) {
+	ts, _ := setupTestServer(t, func(c *config.Config) {
+		c.CORSOrigins = "http://app.example.com, http://evil.com"
+		c.CORSAdminOrigins = "http://admin.example.com"
+		c.CORSObsOrigins = "*"
+	})
+	defer ts.Close()
+	cases := []struct {
+		name, method, path, origin string
+		preflight                  bool
+		wantOrigin                 string // expected Access-Control-Allow-Origin; "" = rejected
+	}{
+		{"obs metrics from any origin", "GET", "/api/v1/obs/metrics", "http://evil.com", false, "*"},
+		{"obs preflight", "GET", "/api/v1/obs/metrics", "http://other.example.org", true, "*"},
+		{"read from default origin", "GET", "/api/v1/providers", "http://evil.com", false, "http://evil.com"},
+		{"read from unknown origin", "GET", "/api/v1/providers", "http://other.example.org", false, ""},
+		{"create user preflight from default origin", "POST", "/api/v1/users/", "http://evil.com", true, ""},
+		{"create user from default origin", "POST", "/api/v1/users/", "http://evil.com", false, ""},
+		{"delete user preflight from admin origin", "DELETE", "/api/v1/users/3", "http://admin.example.com", true, "http://admin.example.com"},
+		{"update provider preflight from default origin", "PUT", "/api/v1/providers/1", "http://evil.com", true, ""},
+		{"provider read stays on the default policy", "GET", "/api/v1/providers/1", "http://evil.com", false, "http://evil.com"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			method := tc.method
+			if tc.preflight {
+				method = "OPTIONS"
+			}
+			req, _ := http.NewRequest(method, ts.URL+tc.path, nil)
+			req.Header.Set("Origin", tc.origin)
+			if tc.preflight {
+				req.Header.Set("Access-Control-Request-Method", tc.method)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if got := resp.Header.Get("Access-Control-Allow-Origin"); got != tc.wantOrigin {
+				t.Fatalf("Access-Control-Allow-Origin=%q, want %q", got, tc.wantOrigin)
+			}
+			if tc.wantOrigin == "http://admin.example.com" && (resp.Header.Get("Access-Control-Allow-Credentials") != "true" || resp.Header.Get("Access-Control-Max-Age") != "600") {
+				t.Fatalf("admin policy headers missing: %v", resp.Header)
+			}
+		})
+	}
+}
+
+func TestAPIVersions(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	cookie := loginAs(t, ts, "versions@example.com", "admin")
+	loginAs(t, ts, "versions-2@example.com", "viewer")
+	get := func(path string) (*http.Response, []byte) {
+		req, _ := http.NewRequest("GET", ts.URL+path, nil)
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp, b
+	}
+	keys := func(b []byte) []string {
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal(b, &m); err != nil {
+			t.Fatalf("not a JSON object: %s", b)
+		}
+		out := make([]string, 0, len(m))
+		for k := range m {
+			out = append(out, k)
+		}
+		slices.Sort(out)
+		return out
+	}
+
+	// v1 keeps its exact shapes and announces its successor
+	resp, body := get("/api/v1/auth/me")
+	if got := keys(body); !slices.Equal(got, []string{"email", "id", "mustChangePassword", "preferencesUrl", "role"}) {
+		t.Fatalf("v1 /auth/me keys %v", got)
+	}
+	if resp.Header.Get("X-API-Version") != "v1" || !strings.HasPrefix(resp.Header.Get("Deprecation"), "@") || resp.Header.Get("Link") != `</api/v2/>; rel="successor-version"` {
+		t.Fatalf("v1 headers %v", resp.Header)
+	}
+	resp, body = get("/api/v1/users/?limit=1")
+	if got := keys(body); !slices.Equal(got, []string{"data", "pagination", "requestId", "timestamp"}) || bytes.Contains(body, []byte("nextCursor")) {
+		t.Fatalf("v1 /users shape %s", body)
+	}
+	resp, body = get("/api/v1/admin/users/x")
+	if resp.StatusCode != 400 || resp.Header.Get("Content-Type") != "application/json" || string(body) != `{"code":"INVALID_ID","error":"invalid user id"}`+"\n" {
+		t.Fatalf("v1 error %d %q %q", resp.StatusCode, resp.Header.Get("Content-Type"), body)
+	}
+
+	// v2 wraps bare objects in the envelope
+	resp, body = get("/api/v2/auth/me")
+	var me struct {
+		Data struct {
+			Email string `json:"email"`
+		} `json:"data"`
+		RequestID string `json:"requestId"`
+	}
+	if json.Unmarshal(body, &me) != nil || me.Data.Email != "versions@example.com" || me.RequestID != resp.Header.Get("X-Trace-Id") {
+		t.Fatalf("v2 /auth/me %s", body)
+	}
+	if resp.Header.Get("X-API-Version") != "v2" || resp.Header.Get("Deprecation") != "" {
+		t.Fatalf("v2 headers %v", resp.Header)
+	}
+	// errors are problem documents
+	resp, body = get("/api/v2/admin/users/x")
+	var prob map[string]any
+	json.Unmarshal(body, &prob)
+	if resp.StatusCode != 400 || resp.Header.Get("Content-Type") != "application/problem+json" || prob["status"] != float64(400) || prob["detail"] != "invalid user id" || prob["instance"] != "/api/v2/admin/users/x" || prob["title"] != "Bad Request" || prob["code"] != ErrCodeInvalidID {
+		t.Fatalf("v2 error %d %s", resp.StatusCode, body)
+	}
+	// lists are walked with cursors
+	type page struct {
+		Data       []UserSummary `json:"data"`
+		Pagination struct {
+			Total      int    `json:"total"`
+			NextCursor string `json:"nextCursor"`
+		} `json:"pagination"`
+	}
+	var first, second page
+	_, body = get("/api/v2/users/?limit=2&sortBy=email")
+	if json.Unmarshal(body, &first) != nil || len(first.Data) != 2 || first.Pagination.NextCursor == "" {
+		t.Fatalf("v2 first page %s", body)
+	}
+	_, body = get("/api/v2/users/?limit=2&sortBy=email&cursor=" + first.Pagination.NextCursor)
+	if json.Unmarshal(body, &second) != nil || len(second.Data) != first.Pagination.Total-2 || second.Pagination.NextCursor != "" || second.Data[0].Email == first.Data[0].Email {
+		t.Fatalf("v2 second page %s", body)
+	}
+}
+
+// pushRecorder is a ResponseRecorder that supports HTTP/2 server push.
+type pushRecorder struct {
+	*httptest.ResponseRecorder
+	pushed []string
+}
+
+func (p *pushRecorder) Push(target string, _ *http.PushOptions) error {
+	p.pushed = append(p.pushed, target)
+	return nil
+}
+
+func TestSPAPushesPreloadAssets(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "index.html"), []byte("<html></html>"), 0o644)
+	os.WriteFile(filepath.Join(dir, "app.js"), []byte("1"), 0o644)
+	h := spaHandler(dir, http.FileServer(http.Dir(dir)), logging.New("test"), []string{"/assets/index.js", "/assets/index.css"})
+
+	rec := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/providers/1", nil))
+	if rec.Code != 200 || !slices.Equal(rec.pushed, []string{"/assets/index.js", "/assets/index.css"}) {
+		t.Fatalf("status=%d pushed=%v", rec.Code, rec.pushed)
+	}
+	if links := rec.Header().Values("Link"); !slices.Equal(links, []string{"</assets/index.js>; rel=preload; as=script", "</assets/index.css>; rel=preload; as=style"}) {
+		t.Fatalf("unexpected Link headers %v", links)
+	}
+
+	// HTTP/1.1: no Pusher, only the preload hints
+	plain := httptest.NewRecorder()
+	h.ServeHTTP(plain, httptest.NewRequest("GET", "/", nil))
+	if len(plain.Header().Values("Link")) != 2 {
+		t.Fatalf("expected Link fallback headers, got %v", plain.Header())
+	}
+
+	// real assets are served as-is, without pushes
+	rec = &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/app.js", nil))
+	if len(rec.pushed) != 0 || rec.Header().Get("Link") != "" {
+		t.Fatalf("asset request pushed %v", rec.pushed)
+	}
+}
+
+func TestSPACacheHeaders(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "index.html"), []byte("<html></html>"), 0o644)
+	os.WriteFile(filepath.Join(dir, "index-4f2a9c1e.js"), []byte("1"), 0o644)
+	os.WriteFile(filepath.Join(dir, "favicon.ico"), []byte("1"), 0o644)
+	h := spaHandler(dir, http.FileServer(http.Dir(dir)), logging.New("test"), nil)
+	get := func(p, inm string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", p, nil)
+		if inm != "" {
+			req.Header.Set("If-None-Match", inm)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/providers/1", "")
+	etag := rec.Header().Get("ETag")
+	if rec.Code != 200 || etag != `"c83301425b2ad1d496473a5ff3d9ecca"` || rec.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatalf("index fallback %d etag=%s cache=%q", rec.Code, etag, rec.Header().Get("Cache-Control"))
+	}
+	if rec = get("/buckets", etag); rec.Code != 304 || rec.Body.Len() != 0 {
+		t.Fatalf("revalidation: %d %q", rec.Code, rec.Body)
+	}
+	if rec = get("/buckets", `W/"other", `+etag); rec.Code != 304 {
+		t.Fatalf("etag list: %d", rec.Code)
+	}
+	if rec = get("/buckets", `"stale"`); rec.Code != 200 {
+		t.Fatalf("stale etag: %d", rec.Code)
+	}
+
+	if rec = get("/index-4f2a9c1e.js", ""); rec.Header().Get("Cache-Control") != "public, max-age=31536000, immutable" {
+		t.Fatalf("hashed asset cache %q", rec.Header().Get("Cache-Control"))
+	}
+	if rec = get("/favicon.ico", ""); rec.Code != 200 || rec.Header().Get("Cache-Control") != "" {
+		t.Fatalf("unhashed asset %d cache %q", rec.Code, rec.Header().Get("Cache-Control"))
+	}
+	for p, want := range map[string]bool{"/assets/app.BkX9aZ12.css": true, "/fonts/roboto-regular.woff2": false, "/assets/index-abcdefgh.js": false, "/logo.png": false} {
+		if hashedAsset(p) != want {
+			t.Errorf("hashedAsset(%s) != %v", p, want)
+		}
+	}
+}
+
+func TestUserLoginMetadata(t *testing.T) {
+	ts, _ := setupTestServer(t, func(c *config.Config) { c.TrustedProxies = "127.0.0.1" })
+	defer ts.Close()
+	db.DB.Create(&models.User{Email: "never@example.com", Role: "viewer"})
+	loginAs(t, ts, "ll-user@example.com", "viewer")
+	login := func(pass string) int {
+		body, _ := json.Marshal(map[string]string{"email": "ll-user@example.com", "password": pass})
+		req, _ := http.NewRequest("POST", ts.URL+"/api/v1/auth/login", bytes.NewReader(body))
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if login("wrong-password") != 401 || login("secretpass") != 200 {
+		t.Fatal("unexpected login results")
+	}
+	admin := loginAs(t, ts, "ll-admin@example.com", "admin")
+	get := func(path string, v any) int {
+		req, _ := http.NewRequest("GET", ts.URL+"/api/v1"+path, nil)
+		req.AddCookie(admin)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		json.NewDecoder(resp.Body).Decode(v)
+		return resp.StatusCode
+	}
+	var u models.User
+	db.DB.Where("email = ?", "ll-user@example.com").First(&u)
+	var detail struct {
+		Data UserDetail `json:"data"`
+	}
+	if code := get(fmt.Sprintf("/admin/users/%d", u.ID), &detail); code != 200 {
+		t.Fatalf("detail status=%d", code)
+	}
+	d := detail.Data
+	if d.LastLoginAt == nil || d.LastLoginIP != "203.0.113.7" || d.ActiveSessionCount != 2 || d.TotalLoginCount != 2 {
+		t.Fatalf("unexpected detail %+v", d)
+	}
+	var list struct {
+		Data       []map[string]any `json:"data"`
+		Pagination struct {
+			Total int `json:"total"`
+			Limit int `json:"limit"`
+		} `json:"pagination"`
+	}
+	if code := get("/users/?sortBy=last_login_at&limit=2", &list); code != 200 {
+		t.Fatalf("list status=%d", code)
+	}
+	if len(list.Data) != 2 || list.Pagination.Total < 3 || list.Pagination.Limit != 2 {
+		t.Fatalf("unexpected page %+v", list)
+	}
+	// the admin logged in last; the user who never logged in is not on the first page
+	if list.Data[0]["email"] != "ll-admin@example.com" || list.Data[1]["email"] != "ll-user@example.com" || list.Data[1]["activeSessionCount"] != float64(2) {
+		t.Fatalf("unexpected order %v", list.Data)
+	}
+	if _, ok := list.Data[0]["password"]; ok {
+		t.Fatal("summary exposes password")
+	}
+	if code := get("/users/?sortBy=password", &list); code != 400 {
+		t.Fatalf("invalid sortBy status=%d", code)
+	}
+}
+
+func TestPreferencesPatchMergesNestedKeys(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	alice := loginAs(t, ts, "prefs-a@example.com", "viewer")
+	bob := loginAs(t, ts, "prefs-b@example.com", "viewer")
+	do := func(method string, c *http.Cookie, body string) (int, map[string]any) {
+		req, _ := http.NewRequest(method, ts.URL+"/api/v1/me/preferences", bytes.NewReader([]byte(body)))
+		req.AddCookie(c)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var env struct {
+			Data map[string]any `json:"data"`
+		}
+		json.NewDecoder(resp.Body).Decode(&env)
+		return resp.StatusCode, env.Data
+	}
+	if code, got := do("GET", alice, ""); code != 200 || len(got) != 0 {
+		t.Fatalf("unset prefs: code=%d %v", code, got)
+	}
+	if code, _ := do("PUT", alice, `{"theme":"dark","table":{"pageSize":50,"dense":true},"defaultProvider":3}`); code != 200 {
+		t.Fatalf("put status=%d", code)
+	}
+	if code, _ := do("PATCH", alice, `{"table":{"pageSize":100,"columns":{"size":false}},"defaultProvider":null}`); code != 200 {
+		t.Fatalf("patch status=%d", code)
+	}
+	_, got := do("GET", alice, "")
+	b, _ := json.Marshal(got)
+	if want := `{"table":{"columns":{"size":false},"dense":true,"pageSize":100},"theme":"dark"}`; string(b) != want {
+		t.Fatalf("merged prefs = %s, want %s", b, want)
+	}
+	if _, got := do("GET", bob, ""); len(got) != 0 {
+		t.Fatalf("other user sees prefs %v", got)
+	}
+	if code, _ := do("PUT", alice, `["not","an","object"]`); code != 400 {
+		t.Fatalf("array body status=%d, want 400", code)
+	}
+	big := `{"blob":"` + string(bytes.Repeat([]byte("x"), 16<<10)) + `"}`
+	if code, _ := do("PUT", alice, big); code != 413 {
+		t.Fatalf("oversized body status=%d, want 413", code)
+	}
+}
+
+func TestBucketTemplates(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	admin := loginAs(t, ts, "tmpl-admin@example.com", "admin")
+	do := func(method, path string, body any) (*http.Response, []byte) {
+		b, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, ts.URL+"/api/v1"+path, bytes.NewReader(b))
+		req.AddCookie(admin)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var buf bytes.Buffer
+		buf.ReadFrom(resp.Body)
+		return resp, buf.Bytes()
+	}
+	bad := map[string]any{"name": "bad", "lifecycleRulesJson": `[{"ID":"x","Status":"maybe"}]`}
+	if resp, _ := do("POST", "/admin/bucket-templates/", bad); resp.StatusCode != 400 {
+		t.Fatalf("invalid lifecycle status=%d, want 400", resp.StatusCode)
+	}
+	tmpl := map[string]any{"name": "team", "versioningEnabled": true, "aclsJson": `[{"subject":"role:editor","permission":"write"}]`}
+	resp, body := do("POST", "/admin/bucket-templates/", tmpl)
+	if resp.StatusCode != 201 {
+		t.Fatalf("create template status=%d body=%s", resp.StatusCode, body)
+	}
+	var created struct {
+		Data models.BucketTemplate `json:"data"`
+	}
+	json.Unmarshal(body, &created)
+	p := models.Provider{Name: "tmpl", Endpoint: "127.0.0.1:1"}
+	db.DB.Create(&p)
+	if resp, _ := do("POST", fmt.Sprintf("/providers/%d/buckets", p.ID), map[string]any{"name": "b", "templateId": 999}); resp.StatusCode != 400 {
+		t.Fatalf("unknown template status=%d, want 400", resp.StatusCode)
+	}
+	// steps that fail against the provider become warnings; ACL rows are still created
+	c, _, _ := getClient(int(p.ID))
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	r := httptest.NewRequest("POST", "/", nil).WithContext(ctx)
+	warnings := applyBucketTemplate(r, c, int(p.ID), "b", &created.Data)
+	if len(warnings) != 1 || !strings.HasPrefix(warnings[0], "versioning:") {
+		t.Fatalf("warnings = %v", warnings)
+	}
+	var acls []models.BucketACL
+	db.DB.Where("provider_id = ? AND bucket = ?", p.ID, "b").Find(&acls)
+	if len(acls) != 1 || acls[0].Subject != "role:editor" || acls[0].Permission != "write" {
+		t.Fatalf("template acls = %+v", acls)
+	}
+}
+
+func TestTraceStream(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	cookie := loginAs(t, ts, "trace-stream@example.com", "viewer")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/api/v1/trace/stream?status=4xx", nil)
+	req.AddCookie(cookie)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("stream: %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	received := make(chan Trace, 100)
+	go func() {
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			var tr Trace
+			if data, ok := strings.CutPrefix(sc.Text(), "data: "); ok && json.Unmarshal([]byte(data), &tr) == nil {
+				received <- tr
+			}
+		}
+	}()
+
+	// the stream is subscribed once its headers arrive; only the 404 passes the filter
+	for _, path := range []string{"/api/v1/auth/me", "/api/v1/trace-stream-missing"} {
+		r, _ := http.NewRequest("GET", ts.URL+path, nil)
+		r.AddCookie(cookie)
+		if resp, err := http.DefaultClient.Do(r); err == nil {
+			resp.Body.Close()
+		}
+	}
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case tr := <-received:
+			if tr.Status < 400 || tr.Status >= 500 {
+				t.Fatalf("status filter let through %d %s", tr.Status, tr.Path)
+			}
+			if tr.Path == "/api/v1/trace-stream-missing" {
+				return
+			}
+		case <-timeout:
+			t.Fatal("trace of a request made after subscribing was not streamed")
+		}
+	}
+}
+
+func TestTraceStreamInvalidStatus(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	cookie := loginAs(t, ts, "trace-stream-bad@example.com", "viewer")
+	req, _ := http.NewRequest("GET", ts.URL+"/api/v1/trace/stream?status=bad", nil)
+	req.AddCookie(cookie)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 400 {
+		t.Fatalf("invalid status filter: %d, want 400", resp.StatusCode)
+	}
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/arencloud/hermes/internal/models"
+)
+
+// samlAssertion is the part of a SAML 2.0 assertion used for login and role mapping.
+type samlAssertion struct {
+	Issuer  string `xml:"Issuer"`
+	Subject struct {
+		NameID string `xml:"NameID"`
+	} `xml:"Subject"`
+	Attributes []struct {
+		Name         string   `xml:"Name,attr"`
+		FriendlyName string   `xml:"FriendlyName,attr"`
+		Values       []string `xml:"AttributeValue"`
+	} `xml:"AttributeStatement>Attribute"`
+}
+
+// samlEmailAttributes are checked, in order, when the NameID is not an email address.
+var samlEmailAttributes = []string{"email", "mail", "emailaddress", "urn:oid:0.9.2342.19200300.100.1.3", "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress"}
+
+// parseSAMLAssertion reads an <Assertion>, or the first assertion of a <Response>.
+// Signatures are not checked: the result is only fit for dry-run mapping checks.
+func parseSAMLAssertion(raw []byte) (*samlAssertion, error) {
+	var root struct {
+		XMLName   xml.Name
+		Assertion *samlAssertion `xml:"Assertion"`
+	}
+	if err := xml.Unmarshal(raw, &root); err != nil {
+		return nil, err
+	}
+	switch root.XMLName.Local {
+	case "Response":
+		if root.Assertion == nil {
+			return nil, errors.New("response contains no assertion")
+		}
+		return root.Assertion, nil
+	case "Assertion":
+		var a samlAssertion
+		if err := xml.Unmarshal(raw, &a); err != nil {
+			return nil, err
+		}
+		return &a, nil
+	}
+	return nil, errors.New("expected a SAML Assertion or Response, got " + root.XMLName.Local)
+}
+
+// claims returns the assertion's attributes keyed by name, and also by friendly name.
+func (a *samlAssertion) claims() map[string]any {
+	out := map[string]any{}
+	for _, at := range a.Attributes {
+		vals := make([]string, 0, len(at.Values))
+		for _, v := range at.Values {
+			vals = append(vals, strings.TrimSpace(v))
+		}
+		if at.Name != "" {
+			out[at.Name] = vals
+		}
+		if at.FriendlyName != "" {
+			if _, ok := out[at.FriendlyName]; !ok {
+				out[at.FriendlyName] = vals
+			}
+		}
+	}
+	return out
+}
+
+func (a *samlAssertion) email(claims map[string]any) string {
+	if id := strings.TrimSpace(a.Subject.NameID); strings.Contains(id, "@") {
+		return strings.ToLower(id)
+	}
+	for _, name := range samlEmailAttributes {
+		for k, v := range claims {
+			if vals, _ := v.([]string); strings.EqualFold(k, name) && len(vals) > 0 && vals[0] != "" {
+				return strings.ToLower(vals[0])
+			}
+		}
+	}
+	return ""
+}
+
+// samlRoleMapping returns ac with the SAML mapping in the fields mapClaimsToRole reads.
+func samlRoleMapping(ac models.AuthConfig) models.AuthConfig {
+	ac.OIDCRoleClaim = ac.SAMLRoleClaim
+	ac.OIDCGroupClaim = ac.SAMLGroupClaim
+	ac.OIDCAdminValues = ac.SAMLAdminValues
+	ac.OIDCEditorValues = ac.SAMLEditorValues
+	ac.OIDCViewerValues = ac.SAMLViewerValues
+	return ac
+}
+
+// samlTestMapping applies the configured SAML attribute mapping to a posted assertion
+// (raw XML) and reports the result. It is a dry run: no user or session is created.
+func samlTestMapping(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		respondError(w, r, 400, err.Error())
+		return
+	}
+	a, err := parseSAMLAssertion(raw)
+	if err != nil {
+		respondError(w, r, 400, "invalid SAML assertion: "+err.Error())
+		return
+	}
+	var ac models.AuthConfig
+	if err := readDB(r).First(&ac).Error; err != nil {
+		respondError(w, r, 500, err.Error())
+		return
+	}
+	claims := a.claims()
+	role := mapClaimsToRole(claims, samlRoleMapping(ac))
+	if role == "" {
+		role = defaultRole(ac.DefaultRole)
+	}
+	addEvent(r, "auth.saml.test_mapping", map[string]any{"issuer": a.Issuer, "attributes": len(claims), "role": role})
+	Respond(w, r, 200, map[string]any{
+		"email":      a.email(claims),
+		"mappedRole": role,
+		"attributes": claims,
+		"issuer":     a.Issuer,
+	})
+}
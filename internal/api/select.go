@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arencloud/hermes/internal/s3"
+	"github.com/go-chi/chi/v5"
+)
+
+// selectTimeout bounds a single S3 Select query, including streaming the result.
+const selectTimeout = 60 * time.Second
+
+// validSelectQuery accepts a single SELECT statement; anything else (DDL/DML,
+// stacked statements) is rejected before it reaches the provider.
+func validSelectQuery(q string) bool {
+	q = strings.TrimSpace(q)
+	q = strings.TrimSuffix(q, ";")
+	if strings.Contains(q, ";") {
+		return false
+	}
+	f := strings.Fields(q)
+	return len(f) > 1 && strings.EqualFold(f[0], "SELECT")
+}
+
+// selectObject runs an S3 Select query and streams the records back as NDJSON.
+func selectObject(w http.ResponseWriter, r *http.Request) {
+	pid, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || pid <= 0 {
+		respondError(w, r, 400, "invalid provider id")
+		return
+	}
+	bucket := chi.URLParam(r, "name")
+	if !enforceACL(w, r, pid, bucket, aclRead) {
+		return
+	}
+	var in struct {
+		Key          string `json:"key"`
+		Query        string `json:"query"`
+		InputFormat  string `json:"inputFormat"`
+		OutputFormat string `json:"outputFormat"`
+		CSVDelimiter string `json:"csvDelimiter"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondError(w, r, 400, err.Error())
+		return
+	}
+	if in.Key == "" || in.Query == "" {
+		respondError(w, r, 400, "key and query are required")
+		return
+	}
+	if !validSelectQuery(in.Query) {
+		respondError(w, r, 400, "only SELECT statements are allowed")
+		return
+	}
+	in.InputFormat = strings.ToUpper(in.InputFormat)
+	if in.InputFormat != "CSV" && in.InputFormat != "JSON" {
+		respondError(w, r, 400, "inputFormat must be CSV or JSON")
+		return
+	}
+	// the response is always NDJSON, so only JSON output is accepted
+	if in.OutputFormat != "" && !strings.EqualFold(in.OutputFormat, "JSON") {
+		respondError(w, r, 400, "outputFormat must be JSON")
+		return
+	}
+	c, _, err := getClient(pid)
+	if err != nil {
+		respondError(w, r, 404, "provider not found")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), selectTimeout)
+	defer cancel()
+	rc, err := c.SelectObject(ctx, bucket, in.Key, in.Query, in.InputFormat, "JSON", in.CSVDelimiter)
+	if err != nil {
+		if errors.Is(err, s3.ErrSelectNotSupported) {
+			respondError(w, r, http.StatusNotImplemented, err.Error())
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			respondError(w, r, http.StatusGatewayTimeout, "select query timed out")
+			return
+		}
+		code, msg := s3Failure(r, err)
+		respondError(w, r, code, msg, storageErrCode(code, msg))
+		return
+	}
+	defer rc.Close()
+	addEvent(r, "object.select", map[string]any{"bucket": bucket, "key": in.Key, "inputFormat": in.InputFormat})
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	n, err := io.Copy(w, rc)
+	if err != nil {
+		// headers are already sent; all we can do is record the failure
+		addEvent(r, "object.select.error", map[string]any{"error": err.Error(), "bytes": n})
+	}
+}
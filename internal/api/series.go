@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+)
+
+// counter snapshot taken by the previous sampleMetrics run; used to store per-minute deltas
+var lastSample struct {
+	requests, c4xx, c5xx, in, out uint64
+}
+
+// sampleMetrics records one minute-granularity MetricPoint per tracked series.
+// Counters are stored as deltas since the previous sample; gauges as their current value.
+func sampleMetrics() error {
+	if db.DB == nil {
+		return nil
+	}
+	now := time.Now().UTC().Truncate(time.Minute)
+	tr := totalRequests.Value()
+	c4 := total4xx.Value()
+	c5 := total5xx.Value()
+	bi := bytesIn.Value()
+	bo := bytesOut.Value()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	vals := map[string]float64{
+		"requests":   float64(tr - lastSample.requests),
+		"errors_4xx": float64(c4 - lastSample.c4xx),
+		"errors_5xx": float64(c5 - lastSample.c5xx),
+		"bytes_in":   float64(bi - lastSample.in),
+		"bytes_out":  float64(bo - lastSample.out),
+		"goroutines": float64(runtime.NumGoroutine()),
+		"heap_alloc": float64(m.HeapAlloc),
+	}
+	lastSample.requests, lastSample.c4xx, lastSample.c5xx, lastSample.in, lastSample.out = tr, c4, c5, bi, bo
+	pts := make([]models.MetricPoint, 0, len(vals))
+	for name, v := range vals {
+		pts = append(pts, models.MetricPoint{Name: name, Timestamp: now, Granularity: db.GranularityMinute, Count: 1, Sum: v, Min: v, Max: v, Avg: v})
+	}
+	return db.DB.Create(&pts).Error
+}
+
+// metricsSeries returns the points of a single metric for a time range.
+// The granularity is picked from how far back the range reaches, since older minute data
+// is rolled up.
+func metricsSeries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "name required", 400)
+		return
+	}
+	to := time.Now().UTC()
+	from := to.Add(-time.Hour)
+	if v := r.URL.Query().Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "invalid from", 400)
+			return
+		}
+		from = t
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "invalid to", 400)
+			return
+		}
+		to = t
+	}
+	if !from.Before(to) {
+		http.Error(w, "from must be before to", 400)
+		return
+	}
+	// older points only exist rolled up, so the age of from matters as much as the span
+	gran := db.GranularityForRange(max(to.Sub(from), time.Since(from)))
+	if v := r.URL.Query().Get("granularity"); v == db.GranularityMinute || v == db.GranularityHour || v == db.GranularityDay {
+		gran = v
+	}
+	var rows []models.MetricPoint
+	if err := db.DB.Where("name = ? AND granularity = ? AND timestamp >= ? AND timestamp <= ?", name, gran, from, to).Order("timestamp asc").Find(&rows).Error; err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	points := make([]map[string]any, 0, len(rows))
+	for _, p := range rows {
+		points = append(points, map[string]any{"ts": p.Timestamp.Unix(), "labels": p.Labels, "count": p.Count, "sum": p.Sum, "min": p.Min, "max": p.Max, "avg": p.Avg})
+	}
+	json.NewEncoder(w).Encode(map[string]any{"name": name, "granularity": gran, "from": from, "to": to, "points": points})
+}
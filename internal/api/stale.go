@@ -0,0 +1,191 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arencloud/hermes/internal/models"
+	"github.com/arencloud/hermes/internal/s3"
+	"github.com/go-chi/chi/v5"
+	minio "github.com/minio/minio-go/v7"
+)
+
+// staleMaxResults caps a stale report; larger buckets should be scanned by prefix.
+const staleMaxResults = 1000
+
+func registerStaleObjects(r chi.Router) {
+	r.Group(func(gr chi.Router) {
+		gr.Use(requireEditorOrAdmin)
+		gr.Get("/providers/{id}/buckets/{name}/objects/stale", staleObjects)
+		gr.Post("/providers/{id}/buckets/{name}/objects/stale/delete", deleteStaleObjects)
+	})
+}
+
+type staleObject struct {
+	Key          string     `json:"key"`
+	Size         int64      `json:"size"`
+	LastModified time.Time  `json:"lastModified"`
+	LastAccessed *time.Time `json:"lastAccessed"` // last recorded download; nil if never downloaded through Hermes
+}
+
+// findStale lists objects under prefix whose last modification and last recorded
+// download are both older than days. truncated reports that the cap was hit.
+func findStale(r *http.Request, c s3.ClientInterface, pid uint, bucket, prefix string, days int) (out []staleObject, truncated bool, err error) {
+	objs, err := c.ListObjects(r.Context(), bucket, prefix, true)
+	if err != nil {
+		return nil, false, err
+	}
+	var stats []models.ObjectStat
+	if err := readDB(r).Where("provider_id = ? AND bucket = ?", pid, bucket).Find(&stats).Error; err != nil {
+		return nil, false, err
+	}
+	lastDL := make(map[string]time.Time, len(stats))
+	for _, st := range stats {
+		lastDL[st.Key] = st.LastDownloadedAt
+	}
+	out, truncated = staleFilter(objs, lastDL, time.Now().Add(-time.Duration(days)*24*time.Hour))
+	return out, truncated, nil
+}
+
+// staleFilter keeps the objects last modified and last downloaded before cutoff, up to staleMaxResults.
+func staleFilter(objs []minio.ObjectInfo, lastDL map[string]time.Time, cutoff time.Time) ([]staleObject, bool) {
+	out := []staleObject{}
+	for _, o := range objs {
+		if o.Key == "" || strings.HasSuffix(o.Key, "/") {
+			continue
+		}
+		so := staleObject{Key: o.Key, Size: o.Size, LastModified: o.LastModified}
+		if t, ok := lastDL[o.Key]; ok {
+			so.LastAccessed = &t
+			if t.After(cutoff) {
+				continue
+			}
+		}
+		if o.LastModified.After(cutoff) {
+			continue
+		}
+		if len(out) == staleMaxResults {
+			return out, true
+		}
+		out = append(out, so)
+	}
+	return out, false
+}
+
+// staleParams resolves the client and the scan parameters, writing the error response itself.
+func staleParams(w http.ResponseWriter, r *http.Request, perm string, daysParam string) (s3.ClientInterface, uint, string, int, bool) {
+	pid, bucket, ok := aclParams(w, r)
+	if !ok {
+		return nil, 0, "", 0, false
+	}
+	days := 90
+	if daysParam != "" {
+		n, err := strconv.Atoi(daysParam)
+		if err != nil || n <= 0 {
+			respondError(w, r, 400, "daysSinceAccess must be a positive number of days")
+			return nil, 0, "", 0, false
+		}
+		days = n
+	}
+	if !enforceACL(w, r, int(pid), bucket, perm) {
+		return nil, 0, "", 0, false
+	}
+	c, _, err := getClient(int(pid))
+	if err != nil {
+		respondError(w, r, 404, "provider not found")
+		return nil, 0, "", 0, false
+	}
+	return c, pid, bucket, days, true
+}
+
+// staleObjects reports objects not modified or downloaded within ?daysSinceAccess= days
+// (default 90), as JSON or, with ?format=csv, as a downloadable CSV report.
+func staleObjects(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	c, pid, bucket, days, ok := staleParams(w, r, aclRead, q.Get("daysSinceAccess"))
+	if !ok {
+		return
+	}
+	items, truncated, err := findStale(r, c, pid, bucket, q.Get("prefix"), days)
+	if err != nil {
+		code, msg := s3Failure(r, err)
+		respondError(w, r, code, msg, storageErrCode(code, msg))
+		return
+	}
+	addEvent(r, "objects.stale", map[string]any{"bucket": bucket, "days": days, "count": len(items), "truncated": truncated})
+	if truncated {
+		w.Header().Set("Warning", fmt.Sprintf(`199 hermes "report truncated to %d objects; narrow it with prefix"`, staleMaxResults))
+	}
+	if q.Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", bucket+"-stale-"+strconv.Itoa(days)+"d.csv"))
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"key", "size", "lastModified", "lastAccessed"})
+		for _, it := range items {
+			acc := ""
+			if it.LastAccessed != nil {
+				acc = it.LastAccessed.UTC().Format(time.RFC3339)
+			}
+			cw.Write([]string{it.Key, strconv.FormatInt(it.Size, 10), it.LastModified.UTC().Format(time.RFC3339), acc})
+		}
+		cw.Flush()
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	RespondList(w, r, 200, items, len(items), staleMaxResults, 0)
+}
+
+// deleteStaleObjects removes the objects staleObjects would report. It is a dry run
+// unless the body sets "dryRun": false; deletions go through the trash when enabled.
+func deleteStaleObjects(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		DaysSinceAccess int    `json:"daysSinceAccess"`
+		Prefix          string `json:"prefix"`
+		DryRun          *bool  `json:"dryRun"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondError(w, r, 400, err.Error())
+		return
+	}
+	days := ""
+	if in.DaysSinceAccess != 0 {
+		days = strconv.Itoa(in.DaysSinceAccess)
+	}
+	c, pid, bucket, n, ok := staleParams(w, r, aclWrite, days)
+	if !ok {
+		return
+	}
+	items, truncated, err := findStale(r, c, pid, bucket, in.Prefix, n)
+	if err != nil {
+		code, msg := s3Failure(r, err)
+		respondError(w, r, code, msg, storageErrCode(code, msg))
+		return
+	}
+	dryRun := in.DryRun == nil || *in.DryRun
+	deleted := []string{}
+	failed := map[string]string{}
+	for _, it := range items {
+		if dryRun {
+			deleted = append(deleted, it.Key)
+			continue
+		}
+		if trashBucket != "" && bucket != trashBucket {
+			err = moveToTrash(r, c, int(pid), bucket, it.Key)
+		} else {
+			err = c.DeleteObject(r.Context(), bucket, it.Key)
+		}
+		if err != nil {
+			_, failed[it.Key] = s3Failure(r, err)
+			continue
+		}
+		deleted = append(deleted, it.Key)
+	}
+	addEvent(r, "objects.stale.delete", map[string]any{"bucket": bucket, "days": n, "dryRun": dryRun, "deleted": len(deleted), "failed": len(failed)})
+	w.Header().Set("Content-Type", "application/json")
+	Respond(w, r, 200, map[string]any{"dryRun": dryRun, "deleted": deleted, "failed": failed, "truncated": truncated})
+}
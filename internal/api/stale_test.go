@@ -0,0 +1,41 @@
+package api
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	minio "github.com/minio/minio-go/v7"
+)
+
+func TestStaleFilter(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-200 * 24 * time.Hour)
+	cutoff := now.Add(-90 * 24 * time.Hour)
+	objs := []minio.ObjectInfo{
+		{Key: "old.txt", LastModified: old},
+		{Key: "new.txt", LastModified: now},
+		{Key: "read-recently.txt", LastModified: old},
+		{Key: "read-long-ago.txt", LastModified: old},
+		{Key: "dir/", LastModified: old},
+	}
+	lastDL := map[string]time.Time{
+		"read-recently.txt": now.Add(-time.Hour),
+		"read-long-ago.txt": old,
+	}
+	got, truncated := staleFilter(objs, lastDL, cutoff)
+	if truncated || len(got) != 2 || got[0].Key != "old.txt" || got[1].Key != "read-long-ago.txt" {
+		t.Fatalf("unexpected result truncated=%v %+v", truncated, got)
+	}
+	if got[0].LastAccessed != nil || got[1].LastAccessed == nil {
+		t.Fatalf("lastAccessed not set as expected: %+v", got)
+	}
+
+	many := make([]minio.ObjectInfo, staleMaxResults+1)
+	for i := range many {
+		many[i] = minio.ObjectInfo{Key: "k" + strconv.Itoa(i), LastModified: old}
+	}
+	if got, truncated := staleFilter(many, nil, cutoff); !truncated || len(got) != staleMaxResults {
+		t.Fatalf("expected truncation at %d, got %d truncated=%v", staleMaxResults, len(got), truncated)
+	}
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+	"github.com/arencloud/hermes/internal/s3"
+	"github.com/go-chi/chi/v5"
+)
+
+func registerBucketTemplates(r chi.Router) {
+	r.Route("/admin/bucket-templates", func(r chi.Router) {
+		r.Use(requireAdmin)
+		r.Get("/", listBucketTemplates)
+		r.Post("/", createBucketTemplate)
+		r.Get("/{tid}", getBucketTemplate)
+		r.Put("/{tid}", updateBucketTemplate)
+		r.Delete("/{tid}", deleteBucketTemplate)
+	})
+}
+
+// templateACLs decodes the ACL entries of a template.
+func templateACLs(t *models.BucketTemplate) ([]models.BucketACL, error) {
+	if strings.TrimSpace(t.ACLsJSON) == "" {
+		return nil, nil
+	}
+	var entries []models.BucketACL
+	if err := json.Unmarshal([]byte(t.ACLsJSON), &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// validateTemplate checks the name and that the embedded JSON documents can be applied later.
+func validateTemplate(t *models.BucketTemplate) string {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" {
+		return "name is required"
+	}
+	if strings.TrimSpace(t.LifecycleRulesJSON) != "" {
+		if _, err := s3.ParseLifecycleRules(t.LifecycleRulesJSON); err != nil {
+			return err.Error()
+		}
+	}
+	entries, err := templateACLs(t)
+	if err != nil {
+		return "aclsJson must be a JSON array of {subject, permission}"
+	}
+	for i := range entries {
+		if msg := validACLEntry(&entries[i]); msg != "" {
+			return "aclsJson entry " + strconv.Itoa(i) + ": " + msg
+		}
+	}
+	return ""
+}
+
+func listBucketTemplates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var items []models.BucketTemplate
+	if err := readDB(r).Order("name").Find(&items).Error; err != nil {
+		respondError(w, r, 500, err.Error())
+		return
+	}
+	RespondList(w, r, 200, items, len(items), len(items), 0)
+}
+
+func createBucketTemplate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var t models.BucketTemplate
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		respondError(w, r, 400, err.Error())
+		return
+	}
+	t.ID = 0
+	if msg := validateTemplate(&t); msg != "" {
+		respondError(w, r, 400, msg)
+		return
+	}
+	if err := db.DB.Create(&t).Error; err != nil {
+		respondError(w, r, 500, err.Error())
+		return
+	}
+	addEvent(r, "bucket_template.create", map[string]any{"id": t.ID, "name": t.Name})
+	Respond(w, r, 201, t)
+}
+
+// findTemplate loads the template named by {tid}, writing the error response itself.
+func findTemplate(w http.ResponseWriter, r *http.Request) (*models.BucketTemplate, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "tid"))
+	if err != nil || id <= 0 {
+		respondError(w, r, 400, "invalid template id")
+		return nil, false
+	}
+	var t models.BucketTemplate
+	if err := db.DB.First(&t, id).Error; err != nil {
+		respondError(w, r, 404, "template not found")
+		return nil, false
+	}
+	return &t, true
+}
+
+func getBucketTemplate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	t, ok := findTemplate(w, r)
+	if !ok {
+		return
+	}
+	Respond(w, r, 200, t)
+}
+
+func updateBucketTemplate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	t, ok := findTemplate(w, r)
+	if !ok {
+		return
+	}
+	var in models.BucketTemplate
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondError(w, r, 400, err.Error())
+		return
+	}
+	in.ID, in.CreatedAt = t.ID, t.CreatedAt
+	if msg := validateTemplate(&in); msg != "" {
+		respondError(w, r, 400, msg)
+		return
+	}
+	if err := db.DB.Save(&in).Error; err != nil {
+		respondError(w, r, 500, err.Error())
+		return
+	}
+	addEvent(r, "bucket_template.update", map[string]any{"id": in.ID, "name": in.Name})
+	Respond(w, r, 200, in)
+}
+
+func deleteBucketTemplate(w http.ResponseWriter, r *http.Request) {
+	t, ok := findTemplate(w, r)
+	if !ok {
+		return
+	}
+	if err := db.DB.Delete(t).Error; err != nil {
+		respondError(w, r, 500, err.Error())
+		return
+	}
+	addEvent(r, "bucket_template.delete", map[string]any{"id": t.ID, "name": t.Name})
+	w.WriteHeader(204)
+}
+
+// applyBucketTemplate configures a freshly created bucket from t. Each step is
+// best-effort: failures are returned as warnings and do not undo the bucket.
+func applyBucketTemplate(r *http.Request, c s3.ClientInterface, pid int, bucket string, t *models.BucketTemplate) []string {
+	warnings := []string{}
+	if t.VersioningEnabled {
+		if err := c.EnableBucketVersioning(r.Context(), bucket); err != nil {
+			warnings = append(warnings, "versioning: "+err.Error())
+		}
+	}
+	if strings.TrimSpace(t.LifecycleRulesJSON) != "" {
+		if err := c.SetBucketLifecycle(r.Context(), bucket, t.LifecycleRulesJSON); err != nil {
+			warnings = append(warnings, "lifecycle: "+err.Error())
+		}
+	}
+	entries, err := templateACLs(t)
+	if err != nil {
+		warnings = append(warnings, "acls: "+err.Error())
+	}
+	for _, e := range entries {
+		if msg := validACLEntry(&e); msg != "" {
+			warnings = append(warnings, "acl "+e.Subject+": "+msg)
+			continue
+		}
+		row := models.BucketACL{ProviderID: uint(pid), Bucket: bucket, Subject: e.Subject, Permission: e.Permission}
+		if err := db.DB.Create(&row).Error; err != nil {
+			warnings = append(warnings, "acl "+e.Subject+": "+err.Error())
+		}
+	}
+	addEvent(r, "bucket.template.apply", map[string]any{"bucket": bucket, "template": t.Name, "warnings": len(warnings)})
+	return warnings
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// roleSuperAdmin may act on any tenant; plain admins are confined to their own.
+const roleSuperAdmin = "superadmin"
+
+// multi-tenancy settings (set in Router); when disabled every record uses the empty tenant
+var multiTenant bool
+var allowedTenants = map[string]bool{}
+
+type tenantCtxKey struct{}
+
+type tenantInfo struct {
+	id  string // tenant new records are written to
+	all bool   // super-admin without X-Hermes-Tenant: reads span all tenants
+}
+
+// tenantMiddleware resolves the request tenant from X-Hermes-Tenant (validated
+// against ALLOWED_TENANTS) and the user's own tenant. Must run after requireAuth.
+func tenantMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !multiTenant {
+			next.ServeHTTP(w, r)
+			return
+		}
+		u := currentUser(r)
+		if u == nil {
+			http.Error(w, "unauthorized", 401)
+			return
+		}
+		h := strings.TrimSpace(r.Header.Get("X-Hermes-Tenant"))
+		if h != "" && !allowedTenants[h] {
+			respondError(w, r, 400, "unknown tenant")
+			return
+		}
+		ti := tenantInfo{id: u.TenantID}
+		if u.Role == roleSuperAdmin {
+			if h == "" {
+				ti.all = true
+			} else {
+				ti.id = h
+			}
+		} else if h != "" && h != u.TenantID {
+			respondError(w, r, 403, "tenant not accessible")
+			return
+		}
+		if t := traceFrom(r.Context()); t != nil {
+			t.TenantID = ti.id
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tenantCtxKey{}, ti)))
+	})
+}
+
+// tenantFromCtx returns the tenant that records created by this request belong to.
+func tenantFromCtx(r *http.Request) string {
+	ti, _ := r.Context().Value(tenantCtxKey{}).(tenantInfo)
+	return ti.id
+}
+
+// tenantScope returns the query scope for the request's tenant; a no-op when
+// multi-tenancy is disabled or a super-admin is reading across tenants.
+func tenantScope(r *http.Request) func(*gorm.DB) *gorm.DB {
+	ti, _ := r.Context().Value(tenantCtxKey{}).(tenantInfo)
+	if !multiTenant || ti.all {
+		return db.TenantScope(db.AllTenants)
+	}
+	return db.TenantScope(ti.id)
+}
+
+// logVisible returns a filter for in-memory log entries by their fields, keeping those of
+// the request tenant as tenantScope does for persisted ones.
+func logVisible(r *http.Request) func(fields map[string]any) bool {
+	ti, _ := r.Context().Value(tenantCtxKey{}).(tenantInfo)
+	return func(fields map[string]any) bool {
+		if !multiTenant || ti.all {
+			return true
+		}
+		t, _ := fields["tenant"].(string)
+		return t == ti.id
+	}
+}
+
+func isSuperAdmin(r *http.Request) bool {
+	u := currentUser(r)
+	return u != nil && u.Role == roleSuperAdmin
+}
+
+// requireProviderTenant rejects /providers/{id}/... requests for providers outside the
+// request tenant. Used as inline middleware so the {id} URL param is available.
+func requireProviderTenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v := chi.URLParam(r, "id")
+		if !multiTenant || v == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		pid, err := strconv.Atoi(v)
+		if err != nil || pid <= 0 {
+			respondError(w, r, 400, "invalid provider id")
+			return
+		}
+		var p models.Provider
+		if err := db.DB.Scopes(tenantScope(r)).First(&p, pid).Error; err != nil {
+			respondError(w, r, 404, "provider not found")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
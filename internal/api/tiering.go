@@ -0,0 +1,146 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+	"github.com/go-chi/chi/v5"
+	minio "github.com/minio/minio-go/v7"
+)
+
+const (
+	classStandard   = "STANDARD"
+	classStandardIA = "STANDARD_IA"
+)
+
+func registerTiering(r chi.Router) {
+	r.Group(func(gr chi.Router) {
+		gr.Use(requireEditorOrAdmin)
+		gr.Get("/providers/{id}/buckets/{name}/tiering-recommendations", tieringRecommendations)
+	})
+}
+
+// tieringCost is the price model used to estimate savings, in dollars per GB.
+type tieringCost struct {
+	StandardPricePerGBMonth float64 `json:"standardPricePerGBMonth"`
+	IAPricePerGBMonth       float64 `json:"iaPricePerGBMonth"`
+	RetrievalPricePerGB     float64 `json:"retrievalPricePerGB"`
+}
+
+// defaultTieringCost follows the AWS us-east-1 list prices.
+var defaultTieringCost = tieringCost{StandardPricePerGBMonth: 0.023, IAPricePerGBMonth: 0.0125, RetrievalPricePerGB: 0.01}
+
+type tieringItem struct {
+	Key                          string `json:"key"`
+	Size                         int64  `json:"size"`
+	CurrentClass                 string `json:"currentClass"`
+	RecommendedClass             string `json:"recommendedClass"`
+	EstimatedMonthlySavingsCents int64  `json:"estimatedMonthlySavingsCents"`
+	RetrievalCostCents           int64  `json:"retrievalCostCents"` // cost of reading the object back once after the move
+}
+
+// recommendTiering suggests STANDARD_IA for standard-class objects last modified before
+// cutoff that were never downloaded, largest savings first, up to staleMaxResults.
+func recommendTiering(objs []minio.ObjectInfo, downloads map[string]int64, cutoff time.Time, cost tieringCost) ([]tieringItem, bool) {
+	out := []tieringItem{}
+	truncated := false
+	for _, o := range objs {
+		class := o.StorageClass
+		if class == "" {
+			class = classStandard
+		}
+		if class != classStandard || downloads[o.Key] > 0 || o.LastModified.After(cutoff) || o.Key == "" || o.Key[len(o.Key)-1] == '/' {
+			continue
+		}
+		if len(out) == staleMaxResults {
+			truncated = true
+			break
+		}
+		gb := float64(o.Size) / (1 << 30)
+		out = append(out, tieringItem{
+			Key:                          o.Key,
+			Size:                         o.Size,
+			CurrentClass:                 class,
+			RecommendedClass:             classStandardIA,
+			EstimatedMonthlySavingsCents: int64(math.Round(gb * (cost.StandardPricePerGBMonth - cost.IAPricePerGBMonth) * 100)),
+			RetrievalCostCents:           int64(math.Round(gb * cost.RetrievalPricePerGB * 100)),
+		})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Size > out[j].Size })
+	return out, truncated
+}
+
+// tieringRecommendations lists objects worth moving to STANDARD_IA. The optional JSON body
+// overrides the price model; ?apply=true performs the moves and records each one.
+func tieringRecommendations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	apply := r.URL.Query().Get("apply") == "true"
+	cost := defaultTieringCost
+	if err := json.NewDecoder(r.Body).Decode(&cost); err != nil && !errors.Is(err, io.EOF) {
+		respondError(w, r, 400, err.Error())
+		return
+	}
+	if cost.StandardPricePerGBMonth < 0 || cost.IAPricePerGBMonth < 0 || cost.RetrievalPricePerGB < 0 {
+		respondError(w, r, 400, "prices must not be negative")
+		return
+	}
+	perm := aclRead
+	if apply {
+		perm = aclWrite
+	}
+	c, pid, bucket, days, ok := staleParams(w, r, perm, "")
+	if !ok {
+		return
+	}
+	objs, err := c.ListObjects(r.Context(), bucket, r.URL.Query().Get("prefix"), true)
+	if err != nil {
+		code, msg := s3Failure(r, err)
+		respondError(w, r, code, msg, storageErrCode(code, msg))
+		return
+	}
+	var stats []models.ObjectStat
+	if err := readDB(r).Where("provider_id = ? AND bucket = ?", pid, bucket).Find(&stats).Error; err != nil {
+		respondError(w, r, 500, err.Error())
+		return
+	}
+	downloads := make(map[string]int64, len(stats))
+	for _, st := range stats {
+		downloads[st.Key] = st.DownloadCount
+	}
+	items, truncated := recommendTiering(objs, downloads, time.Now().Add(-time.Duration(days)*24*time.Hour), cost)
+	addEvent(r, "tiering.recommend", map[string]any{"bucket": bucket, "count": len(items), "apply": apply})
+	if truncated {
+		w.Header().Set("Warning", fmt.Sprintf(`199 hermes "recommendations truncated to %d objects; narrow them with prefix"`, staleMaxResults))
+	}
+	if !apply {
+		RespondList(w, r, 200, items, len(items), staleMaxResults, 0)
+		return
+	}
+	by := ""
+	if u := currentUser(r); u != nil {
+		by = u.Email
+	}
+	applied := make([]models.TieringRecommendation, 0, len(items))
+	for _, it := range items {
+		rec := models.TieringRecommendation{ProviderID: pid, Bucket: bucket, Key: it.Key, FromClass: it.CurrentClass, ToClass: it.RecommendedClass, EstimatedMonthlySavingsCents: it.EstimatedMonthlySavingsCents, Status: "applied", AppliedBy: by}
+		if err := c.SetStorageClass(r.Context(), bucket, it.Key, it.RecommendedClass); err != nil {
+			rec.Status = "failed"
+			_, rec.Error = s3Failure(r, err)
+		}
+		if err := db.DB.Create(&rec).Error; err != nil {
+			respondError(w, r, 500, err.Error())
+			return
+		}
+		applied = append(applied, rec)
+	}
+	addEvent(r, "tiering.apply", map[string]any{"bucket": bucket, "count": len(applied)})
+	RespondList(w, r, 200, applied, len(applied), staleMaxResults, 0)
+}
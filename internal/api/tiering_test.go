@@ -0,0 +1,33 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	minio "github.com/minio/minio-go/v7"
+)
+
+func TestRecommendTiering(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-120 * 24 * time.Hour)
+	cutoff := now.Add(-90 * 24 * time.Hour)
+	objs := []minio.ObjectInfo{
+		{Key: "small.log", Size: 1 << 30, LastModified: old},
+		{Key: "big.bin", Size: 100 << 30, LastModified: old, StorageClass: "STANDARD"},
+		{Key: "fresh.bin", Size: 100 << 30, LastModified: now},
+		{Key: "popular.bin", Size: 100 << 30, LastModified: old},
+		{Key: "cold.bin", Size: 100 << 30, LastModified: old, StorageClass: "GLACIER"},
+	}
+	got, truncated := recommendTiering(objs, map[string]int64{"popular.bin": 4}, cutoff, defaultTieringCost)
+	if truncated || len(got) != 2 || got[0].Key != "big.bin" || got[1].Key != "small.log" {
+		t.Fatalf("unexpected recommendations truncated=%v %+v", truncated, got)
+	}
+	// 100 GB * (0.023 - 0.0125) $/GB = $1.05
+	if got[0].EstimatedMonthlySavingsCents != 105 || got[0].RetrievalCostCents != 100 || got[0].RecommendedClass != "STANDARD_IA" || got[0].CurrentClass != "STANDARD" {
+		t.Fatalf("unexpected estimate: %+v", got[0])
+	}
+	cheap := tieringCost{StandardPricePerGBMonth: 0.05, IAPricePerGBMonth: 0.01}
+	if got, _ := recommendTiering(objs[1:2], nil, cutoff, cheap); got[0].EstimatedMonthlySavingsCents != 400 || got[0].RetrievalCostCents != 0 {
+		t.Fatalf("custom prices not applied: %+v", got[0])
+	}
+}
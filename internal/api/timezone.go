@@ -0,0 +1,195 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// timestampRe picks the JSON strings worth trying to parse as RFC3339 timestamps.
+var timestampRe = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T`)
+
+// timezoneMiddleware rewrites the RFC3339 timestamps of JSON responses into the zone named
+// by the X-Timezone request header (an IANA name such as America/New_York). Other
+// responses, including streams, pass through untouched.
+func timezoneMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimSpace(r.Header.Get("X-Timezone"))
+		if name == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		loc, err := loadZone(name)
+		if err != nil {
+			respondError(w, r, 400, "invalid X-Timezone: "+name)
+			return
+		}
+		tw := &tzWriter{ResponseWriter: w, loc: loc, code: 200}
+		next.ServeHTTP(tw, r)
+		tw.finish()
+	})
+}
+
+// loadZone is time.LoadLocation restricted to IANA names ("Local" is the server's zone).
+func loadZone(name string) (*time.Location, error) {
+	if name == "Local" {
+		return nil, os.ErrNotExist
+	}
+	return time.LoadLocation(name)
+}
+
+// tzWriter buffers JSON bodies so their timestamps can be converted once the handler is done.
+type tzWriter struct {
+	http.ResponseWriter
+	loc     *time.Location
+	code    int
+	decided bool
+	rewrite bool
+	buf     bytes.Buffer
+}
+
+func (tw *tzWriter) WriteHeader(code int) {
+	if tw.decided {
+		return
+	}
+	tw.decided = true
+	tw.code = code
+	tw.rewrite = strings.HasPrefix(tw.Header().Get("Content-Type"), "application/json")
+	if !tw.rewrite {
+		tw.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (tw *tzWriter) Write(b []byte) (int, error) {
+	if !tw.decided {
+		tw.WriteHeader(200)
+	}
+	if tw.rewrite {
+		return tw.buf.Write(b)
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+func (tw *tzWriter) Flush() {
+	if tw.rewrite {
+		return
+	}
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (tw *tzWriter) finish() {
+	if !tw.rewrite {
+		return
+	}
+	body := convertTimestamps(tw.buf.Bytes(), tw.loc)
+	tw.Header().Del("Content-Length")
+	tw.ResponseWriter.WriteHeader(tw.code)
+	tw.ResponseWriter.Write(body)
+}
+
+// convertTimestamps re-encodes each JSON value in body with its timestamps moved to loc.
+// Bodies that do not parse are returned unchanged.
+func convertTimestamps(body []byte, loc *time.Location) []byte {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber() // keep large integers exact
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	for {
+		var v any
+		if err := dec.Decode(&v); err == io.EOF {
+			break
+		} else if err != nil {
+			return body
+		}
+		if err := enc.Encode(inZone(v, loc)); err != nil {
+			return body
+		}
+	}
+	return out.Bytes()
+}
+
+func inZone(v any, loc *time.Location) any {
+	switch x := v.(type) {
+	case map[string]any:
+		for k, e := range x {
+			x[k] = inZone(e, loc)
+		}
+	case []any:
+		for i, e := range x {
+			x[i] = inZone(e, loc)
+		}
+	case string:
+		if timestampRe.MatchString(x) {
+			if t, err := time.Parse(time.RFC3339Nano, x); err == nil {
+				return t.In(loc).Format(time.RFC3339Nano)
+			}
+		}
+	}
+	return v
+}
+
+var (
+	zonesOnce sync.Once
+	zoneNames []string
+)
+
+// zoneinfoDirs are searched in order for the system time zone database.
+var zoneinfoDirs = []string{"/usr/share/zoneinfo", "/usr/share/lib/zoneinfo", "/usr/lib/locale/TZ"}
+
+// timezones lists the IANA zone names found in the system zone database, once.
+func timezones() []string {
+	zonesOnce.Do(func() {
+		dirs := zoneinfoDirs
+		if d := os.Getenv("ZONEINFO"); d != "" {
+			dirs = append([]string{d}, dirs...)
+		}
+		for _, dir := range dirs {
+			filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return nil
+				}
+				name, _ := filepath.Rel(dir, path)
+				if d.IsDir() {
+					// posix/ and right/ duplicate the database with other leap-second rules
+					if name == "posix" || name == "right" {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if c := name[0]; c < 'A' || c > 'Z' || strings.ContainsAny(name, ".") {
+					return nil // zone.tab, tzdata.zi, leapseconds, ...
+				}
+				if _, err := time.LoadLocation(name); err == nil {
+					zoneNames = append(zoneNames, name)
+				}
+				return nil
+			})
+			if len(zoneNames) > 0 {
+				break
+			}
+		}
+		sort.Strings(zoneNames)
+	})
+	return zoneNames
+}
+
+// listTimezones returns the time zone names accepted in X-Timezone.
+func listTimezones(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(24*3600))
+	zones := timezones()
+	RespondList(w, r, 200, zones, len(zones), len(zones), 0)
+}
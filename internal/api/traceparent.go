@@ -0,0 +1,65 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// W3C TraceContext (https://www.w3.org/TR/trace-context/) support: an incoming
+// traceparent links the Hermes trace to the caller's trace, and every response carries
+// a traceparent naming the Hermes span so the caller can continue from it.
+
+// traceParent is a parsed traceparent header.
+type traceParent struct {
+	TraceID  string // 32 lowercase hex chars
+	ParentID string // 16 lowercase hex chars
+	Flags    string // 2 hex chars; 01 = sampled
+}
+
+// parseTraceparent parses a version-traceid-parentid-flags header. Unknown future
+// versions are accepted as long as the known fields are valid, as the spec requires.
+func parseTraceparent(h string) (traceParent, bool) {
+	parts := strings.Split(strings.TrimSpace(h), "-")
+	if len(parts) < 4 {
+		return traceParent{}, false
+	}
+	version, traceID, parentID, flags := parts[0], parts[1], parts[2], parts[3]
+	if !isLowerHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return traceParent{}, false
+	}
+	if !isLowerHex(traceID, 32) || traceID == strings.Repeat("0", 32) {
+		return traceParent{}, false
+	}
+	if !isLowerHex(parentID, 16) || parentID == strings.Repeat("0", 16) {
+		return traceParent{}, false
+	}
+	if !isLowerHex(flags, 2) {
+		return traceParent{}, false
+	}
+	return traceParent{TraceID: traceID, ParentID: parentID, Flags: flags}, true
+}
+
+func (tp traceParent) String() string {
+	return "00-" + tp.TraceID + "-" + tp.ParentID + "-" + tp.Flags
+}
+
+func isLowerHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// newSpanID returns 16 random hex chars identifying the Hermes span of a request.
+func newSpanID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	valid := []struct {
+		in   string
+		want traceParent
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", traceParent{"4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", "01"}},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", traceParent{"4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", "00"}},
+		// future versions may append fields
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", traceParent{"4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", "01"}},
+	}
+	for _, tc := range valid {
+		got, ok := parseTraceparent(tc.in)
+		if !ok || got != tc.want {
+			t.Fatalf("parseTraceparent(%q) = %+v, %v; want %+v", tc.in, got, ok, tc.want)
+		}
+	}
+	malformed := []string{
+		"",
+		"garbage",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",      // missing flags
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-x", // extra field on version 00
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",   // invalid version
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",   // zero trace id
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",   // zero parent id
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",   // uppercase
+		"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",     // short trace id
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0z",   // bad flags
+	}
+	for _, in := range malformed {
+		if got, ok := parseTraceparent(in); ok {
+			t.Fatalf("parseTraceparent(%q) accepted: %+v", in, got)
+		}
+	}
+}
+
+func TestTraceparentPropagation(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	const remote = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req, _ := http.NewRequest("GET", ts.URL+"/health", nil)
+	req.Header.Set("traceparent", "00-"+remote+"-00f067aa0ba902b7-01")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	out, ok := parseTraceparent(resp.Header.Get("traceparent"))
+	if !ok || out.TraceID != remote || out.ParentID == "00f067aa0ba902b7" {
+		t.Fatalf("response traceparent = %q", resp.Header.Get("traceparent"))
+	}
+	var row models.TraceRow
+	if err := db.DB.First(&row, "id = ?", resp.Header.Get("X-Trace-Id")).Error; err != nil {
+		t.Fatal(err)
+	}
+	if row.ParentTraceID != remote || row.SpanID != out.ParentID {
+		t.Fatalf("stored trace parent=%q span=%q", row.ParentTraceID, row.SpanID)
+	}
+	// a malformed header is ignored and Hermes starts its own trace
+	req, _ = http.NewRequest("GET", ts.URL+"/health", nil)
+	req.Header.Set("traceparent", "00-bogus")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if out, ok := parseTraceparent(resp.Header.Get("traceparent")); !ok || out.TraceID != resp.Header.Get("X-Trace-Id") {
+		t.Fatalf("response traceparent = %q", resp.Header.Get("traceparent"))
+	}
+}
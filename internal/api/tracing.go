@@ -3,15 +3,23 @@ package api
 import (
 	"context"
 	"crypto/rand"
+	"encoding/binary"
+	"encoding/csv"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/arencloud/hermes/internal/db"
 	"github.com/arencloud/hermes/internal/models"
 	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Lightweight in-memory tracing
@@ -21,40 +29,157 @@ type TraceEvent struct {
 	Time   time.Time      `json:"time"`
 	Name   string         `json:"name"`
 	Fields map[string]any `json:"fields,omitempty"`
+	// set holds the fields of events recorded with addEventSet; it is only turned
+	// into a map when the event is serialised.
+	set FieldSet
+}
+
+// Field is a single string-valued event field.
+type Field struct{ Key, Val string }
+
+// FieldSet holds up to 8 event fields inline, so hot-path events do not allocate a
+// map. Fields beyond the eighth are dropped.
+type FieldSet struct {
+	n int
+	f [8]Field
+}
+
+// Add appends a field and returns the set, so calls can be chained.
+func (fs FieldSet) Add(key, val string) FieldSet {
+	if fs.n < len(fs.f) {
+		fs.f[fs.n] = Field{key, val}
+		fs.n++
+	}
+	return fs
+}
+
+// Map returns the fields as a map, or nil when the set is empty.
+func (fs *FieldSet) Map() map[string]any {
+	if fs.n == 0 {
+		return nil
+	}
+	m := make(map[string]any, fs.n)
+	for _, f := range fs.f[:fs.n] {
+		m[f.Key] = f.Val
+	}
+	return m
+}
+
+// fields returns the event fields whichever way they were recorded.
+func (e *TraceEvent) fields() map[string]any {
+	if e.Fields != nil {
+		return e.Fields
+	}
+	return e.set.Map()
+}
+
+func (e TraceEvent) MarshalJSON() ([]byte, error) {
+	type plain TraceEvent
+	p := plain(e)
+	p.Fields = e.fields()
+	return json.Marshal(p)
 }
 
 type Trace struct {
-	ID        string         `json:"id"`
-	Method    string         `json:"method"`
-	Path      string         `json:"path"`
-	Status    int            `json:"status"`
-	UserEmail string         `json:"userEmail,omitempty"`
-	UserRole  string         `json:"userRole,omitempty"`
-	UserAgent string         `json:"userAgent,omitempty"`
-	RemoteIP  string         `json:"remoteIp,omitempty"`
-	ReqBytes  int64          `json:"reqBytes,omitempty"`
-	RespBytes int64          `json:"respBytes,omitempty"`
-	Started   time.Time      `json:"started"`
-	Ended     time.Time      `json:"ended"`
-	Duration  time.Duration  `json:"duration"`
-	Tags      map[string]any `json:"tags,omitempty"`
-	Events    []TraceEvent   `json:"events"`
+	ID        string        `json:"id"`
+	Method    string        `json:"method"`
+	Path      string        `json:"path"`
+	Status    int           `json:"status"`
+	UserEmail string        `json:"userEmail,omitempty"`
+	UserRole  string        `json:"userRole,omitempty"`
+	UserAgent string        `json:"userAgent,omitempty"`
+	RemoteIP  string        `json:"remoteIp,omitempty"`
+	ReqBytes  int64         `json:"reqBytes,omitempty"`
+	RespBytes int64         `json:"respBytes,omitempty"`
+	Started   time.Time     `json:"started"`
+	Ended     time.Time     `json:"ended"`
+	Duration  time.Duration `json:"duration"`
+	TenantID  string        `json:"tenantId,omitempty"`
+	// SpanID identifies Hermes's span for W3C TraceContext; ParentTraceID is the caller's
+	// trace ID when the request carried a valid traceparent header.
+	SpanID        string         `json:"spanId,omitempty"`
+	ParentTraceID string         `json:"parentTraceId,omitempty"`
+	Tags          map[string]any `json:"tags,omitempty"`
+	Events        []TraceEvent   `json:"events"`
+	// evBuf backs Events for the first few events so they share the Trace allocation.
+	evBuf [4]TraceEvent
 }
 
 type traceStore struct {
-	mu   sync.RWMutex
+	mu   sync.RWMutex // guards the ring
 	buf  []*Trace
 	next int
 	size int
+	// subscribers have their own lock, never held together with mu, so a subscriber
+	// reading the ring with all() cannot deadlock against add broadcasting to it
+	subMu sync.RWMutex
+	subs  map[chan *Trace]struct{}
 }
 
-var traces = &traceStore{buf: make([]*Trace, 1000), size: 1000}
+// defaultTraceBuffer is the ring size until Router applies MAX_TRACE_BUFFER.
+const defaultTraceBuffer = 1000
 
-func (s *traceStore) add(t *Trace) {
+var traces = &traceStore{buf: make([]*Trace, defaultTraceBuffer), size: defaultTraceBuffer}
+
+// resize changes the ring to hold n traces, keeping the newest ones.
+func (s *traceStore) resize(n int) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if n <= 0 || n == s.size {
+		return
+	}
+	kept := make([]*Trace, 0, min(n, s.size))
+	for i := 0; i < s.size; i++ { // oldest first
+		if t := s.buf[(s.next+i)%s.size]; t != nil {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) > n {
+		kept = kept[len(kept)-n:]
+	}
+	s.buf = make([]*Trace, n)
+	copy(s.buf, kept)
+	s.next = len(kept) % n
+	s.size = n
+}
+
+func (s *traceStore) capacity() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.size
+}
+
+func (s *traceStore) add(t *Trace) {
+	s.mu.Lock()
 	s.buf[s.next] = t
 	s.next = (s.next + 1) % s.size
+	s.mu.Unlock()
+	s.subMu.RLock()
+	defer s.subMu.RUnlock()
+	for ch := range s.subs {
+		select {
+		case ch <- t:
+		default: // drop for slow subscribers
+		}
+	}
+}
+
+// subscribe returns a channel receiving every trace added from now on. Call the
+// returned cancel func to unsubscribe.
+func (s *traceStore) subscribe() (<-chan *Trace, func()) {
+	ch := make(chan *Trace, 100)
+	s.subMu.Lock()
+	if s.subs == nil {
+		s.subs = map[chan *Trace]struct{}{}
+	}
+	s.subs[ch] = struct{}{}
+	s.subMu.Unlock()
+	return ch, func() {
+		s.subMu.Lock()
+		delete(s.subs, ch)
+		close(ch)
+		s.subMu.Unlock()
+	}
 }
 
 func (s *traceStore) all(limit int) []*Trace {
@@ -75,31 +200,42 @@ func (s *traceStore) all(limit int) []*Trace {
 	return out
 }
 
+// tracePersist controls whether completed traces are written to the DB (set in Router).
+var tracePersist = true
+
 // persistTrace stores the trace and its events into the database so they survive restarts.
 func persistTrace(t *Trace) {
 	if t == nil || db.DB == nil {
 		return
 	}
 	row := models.TraceRow{
-		ID:         t.ID,
-		Method:     t.Method,
-		Path:       t.Path,
-		Status:     t.Status,
-		UserEmail:  t.UserEmail,
-		UserRole:   t.UserRole,
-		UserAgent:  t.UserAgent,
-		RemoteIP:   t.RemoteIP,
-		ReqBytes:   t.ReqBytes,
-		RespBytes:  t.RespBytes,
-		Started:    t.Started,
-		Ended:      t.Ended,
-		DurationNs: int64(t.Duration),
-	}
-	_ = db.DB.Save(&row).Error
-	// insert events
+		ID:            t.ID,
+		Method:        t.Method,
+		Path:          t.Path,
+		Status:        t.Status,
+		UserEmail:     t.UserEmail,
+		UserRole:      t.UserRole,
+		UserAgent:     t.UserAgent,
+		RemoteIP:      t.RemoteIP,
+		ReqBytes:      t.ReqBytes,
+		RespBytes:     t.RespBytes,
+		Started:       t.Started,
+		Ended:         t.Ended,
+		DurationNs:    int64(t.Duration),
+		TenantID:      t.TenantID,
+		SpanID:        t.SpanID,
+		ParentTraceID: t.ParentTraceID,
+	}
+	// events are built up front so they go out as one multi-row INSERT per 100
+	evRows := make([]models.TraceEventRow, 0, len(t.Events))
 	for _, ev := range t.Events {
-		fieldsBytes, _ := json.Marshal(ev.Fields)
-		_ = db.DB.Create(&models.TraceEventRow{TraceID: t.ID, Time: ev.Time, Name: ev.Name, Fields: string(fieldsBytes)}).Error
+		fieldsBytes, _ := json.Marshal(ev.fields())
+		evRows = append(evRows, models.TraceEventRow{TraceID: t.ID, Time: ev.Time, Name: ev.Name, Fields: string(fieldsBytes)})
+	}
+	// an upsert, so a trace persisted twice updates its row instead of failing
+	_ = db.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&row).Error
+	if len(evRows) > 0 {
+		_ = db.DB.CreateInBatches(evRows, 100).Error
 	}
 }
 
@@ -122,7 +258,14 @@ func withTraceCtx(ctx context.Context, t *Trace) context.Context {
 	return context.WithValue(ctx, traceKey, t)
 }
 
-func newTraceID() string { b := make([]byte, 8); _, _ = rand.Read(b); return hex.EncodeToString(b) }
+// newTraceID returns 32 hex chars: the big-endian start time in nanoseconds followed by
+// 8 random bytes. IDs therefore sort by recency, which keyset pagination relies on.
+func newTraceID() string {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint64(b[:8], uint64(time.Now().UnixNano()))
+	_, _ = rand.Read(b[8:])
+	return hex.EncodeToString(b)
+}
 
 func addEvent(r *http.Request, name string, fields map[string]any) {
 	if t := traceFrom(r.Context()); t != nil {
@@ -130,10 +273,28 @@ func addEvent(r *http.Request, name string, fields map[string]any) {
 	}
 }
 
+// addEventSet is addEvent for the per-request hot path: the fields are kept inline
+// instead of in a map.
+func addEventSet(r *http.Request, name string, fs FieldSet) {
+	if t := traceFrom(r.Context()); t != nil {
+		t.Events = append(t.Events, TraceEvent{Time: time.Now(), Name: name, set: fs})
+	}
+}
+
 // respondError records an error event into the current trace and writes an HTTP error.
-func respondError(w http.ResponseWriter, r *http.Request, code int, msg string) {
-	addEvent(r, "error", map[string]any{"code": code, "message": msg})
-	http.Error(w, msg, code)
+// With an error code (see errors.go) the body is JSON: {"error": msg, "code": errCode}.
+func respondError(w http.ResponseWriter, r *http.Request, code int, msg string, errCode ...string) {
+	if len(errCode) == 0 {
+		addEvent(r, "error", map[string]any{"code": code, "message": msg})
+		http.Error(w, msg, code)
+		return
+	}
+	addEvent(r, "error", map[string]any{"code": code, "message": msg, "errorCode": errCode[0]})
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg, "code": errCode[0]})
 }
 
 // HTTP Handlers for trace API
@@ -146,12 +307,269 @@ func traceRecent(w http.ResponseWriter, r *http.Request) {
 	}
 	// Prefer DB-backed recent traces for durability
 	var rows []models.TraceRow
-	_ = db.DB.Order("started desc").Limit(limit).Find(&rows).Error
+	_ = readDB(r).Scopes(tenantScope(r)).Order("started desc").Limit(limit).Find(&rows).Error
+	out := make([]*Trace, 0, len(rows))
+	for _, r0 := range rows {
+		out = append(out, traceFromRow(r0))
+	}
+	RespondList(w, r, 200, out, len(out), limit, 0)
+}
+
+// traceList pages through persisted traces newest-first using keyset pagination on
+// (started, id), so no COUNT or OFFSET scan is needed on large tables. Paging on the ID
+// alone would interleave the older random IDs with the time-prefixed ones.
+// ?cursor=<id> continues with older traces (pass the previous nextCursor); ?firstCursor=<id>
+// returns traces newer than the given one (pass the first ID seen to poll for new traces).
+func traceList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 && i <= 1000 {
+			limit = i
+		}
+	}
+	q, err := traceSearchQuery(readDB(r).Model(&models.TraceRow{}).Scopes(tenantScope(r)), r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	// a cursor is a trace ID; its start time is read in SQL so it compares exactly as stored
+	started := func(id string) (*gorm.DB, bool) {
+		var n int64
+		readDB(r).Model(&models.TraceRow{}).Where("id = ?", id).Count(&n)
+		return readDB(r).Model(&models.TraceRow{}).Select("started").Where("id = ?", id), n > 0
+	}
+	var rows []models.TraceRow
+	if first := r.URL.Query().Get("firstCursor"); first != "" {
+		at, ok := started(first)
+		if !ok {
+			http.Error(w, "unknown firstCursor", 400)
+			return
+		}
+		// walk towards newer traces, then flip so the page stays newest-first
+		q = q.Where("(started > (?) OR (started = (?) AND id > ?))", at, at, first)
+		if err := q.Order("started asc, id asc").Limit(limit + 1).Find(&rows).Error; err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if len(rows) > limit {
+			rows = rows[:limit]
+		}
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	} else {
+		if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+			at, ok := started(cursor)
+			if !ok {
+				http.Error(w, "unknown cursor", 400)
+				return
+			}
+			q = q.Where("(started < (?) OR (started = (?) AND id < ?))", at, at, cursor)
+		}
+		if err := q.Order("started desc, id desc").Limit(limit + 1).Find(&rows).Error; err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+	// the extra row only tells us whether another page exists
+	hasMore := len(rows) > limit
+	if hasMore {
+		rows = rows[:limit]
+	}
 	out := make([]*Trace, 0, len(rows))
 	for _, r0 := range rows {
-		out = append(out, &Trace{ID: r0.ID, Method: r0.Method, Path: r0.Path, Status: r0.Status, UserEmail: r0.UserEmail, UserRole: r0.UserRole, UserAgent: r0.UserAgent, RemoteIP: r0.RemoteIP, ReqBytes: r0.ReqBytes, RespBytes: r0.RespBytes, Started: r0.Started, Ended: r0.Ended, Duration: time.Duration(r0.DurationNs)})
+		out = append(out, traceFromRow(r0))
+	}
+	next := ""
+	if len(out) > 0 {
+		next = out[len(out)-1].ID
+	}
+	json.NewEncoder(w).Encode(map[string]any{"traces": out, "nextCursor": next, "hasMore": hasMore})
+}
+
+// parseStatusFilter turns a ?status= value, an exact code or a class like 5xx, into the
+// half-open range [lo, hi) of matching status codes.
+func parseStatusFilter(v string) (lo, hi int, err error) {
+	v = strings.ToLower(v)
+	if len(v) == 3 && v[0] >= '1' && v[0] <= '5' && v[1:] == "xx" {
+		lo = int(v[0]-'0') * 100
+		return lo, lo + 100, nil
+	}
+	if code, err := strconv.Atoi(v); err == nil {
+		return code, code + 1, nil
+	}
+	return 0, 0, errors.New("invalid status")
+}
+
+// traceStreamBacklog is how many recent traces /trace/stream sends on connect.
+const traceStreamBacklog = 20
+
+// traceStream streams completed traces via Server-Sent Events: the last
+// traceStreamBacklog on connect, then each new one. ?status= (code or class like 5xx)
+// narrows the feed; with multi-tenancy only the request tenant's traces are sent.
+func traceStream(w http.ResponseWriter, r *http.Request) {
+	lo, hi := 0, 1000
+	if v := r.URL.Query().Get("status"); v != "" {
+		var err error
+		if lo, hi, err = parseStatusFilter(v); err != nil {
+			respondError(w, r, 400, err.Error(), ErrCodeInvalidParameter)
+			return
+		}
+	}
+	fl, ok := w.(http.Flusher)
+	if !ok {
+		respondError(w, r, 500, "streaming unsupported", ErrCodeInternal)
+		return
+	}
+	ti, _ := r.Context().Value(tenantCtxKey{}).(tenantInfo)
+	match := func(t *Trace) bool {
+		if t.Status < lo || t.Status >= hi {
+			return false
+		}
+		return !multiTenant || ti.all || t.TenantID == ti.id
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	write := func(t *Trace) {
+		b, _ := json.Marshal(t)
+		w.Write([]byte("data: "))
+		w.Write(b)
+		w.Write([]byte("\n\n"))
+	}
+	// subscribe before reading the backlog so no trace falls in between; the few that
+	// land in both are sent once
+	ch, cancel := traces.subscribe()
+	defer cancel()
+	var backlog []*Trace
+	for _, t := range traces.all(0) { // newest first
+		if len(backlog) == traceStreamBacklog {
+			break
+		}
+		if match(t) {
+			backlog = append(backlog, t)
+		}
+	}
+	sent := make(map[string]bool, len(backlog))
+	for i := len(backlog) - 1; i >= 0; i-- {
+		write(backlog[i])
+		sent[backlog[i].ID] = true
 	}
-	json.NewEncoder(w).Encode(out)
+	fl.Flush()
+	ctx := r.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t, ok := <-ch:
+			if !ok {
+				return
+			}
+			if match(t) && !sent[t.ID] {
+				write(t)
+				fl.Flush()
+			}
+		}
+	}
+}
+
+// traceSearchQuery applies the trace search filters (?from=&to= RFC3339 on started,
+// ?status= exact code or class like 5xx, ?user= email, ?path= substring). It is shared
+// by /trace/list and /trace/export.csv so both select exactly the same rows.
+func traceSearchQuery(q *gorm.DB, r *http.Request) (*gorm.DB, error) {
+	qs := r.URL.Query()
+	if v := qs.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, errors.New("invalid from")
+		}
+		q = q.Where("started >= ?", t)
+	}
+	if v := qs.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, errors.New("invalid to")
+		}
+		q = q.Where("started <= ?", t)
+	}
+	if v := qs.Get("status"); v != "" {
+		lo, hi, err := parseStatusFilter(v)
+		if err != nil {
+			return nil, err
+		}
+		q = q.Where("status >= ? AND status < ?", lo, hi)
+	}
+	if v := qs.Get("user"); v != "" {
+		q = q.Where("user_email = ?", v)
+	}
+	if v := qs.Get("path"); v != "" {
+		q = q.Where("path LIKE ?", "%"+v+"%")
+	}
+	return q, nil
+}
+
+// traceExportMaxRows caps a single CSV export.
+const traceExportMaxRows = 50000
+
+// traceExportCSV streams the traces matching the search filters as RFC 4180 CSV.
+// It reads from the replica (when configured) since exports can scan many rows.
+func traceExportCSV(w http.ResponseWriter, r *http.Request) {
+	q, err := traceSearchQuery(db.ReadDB().Model(&models.TraceRow{}).Scopes(tenantScope(r)), r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	// probe one row past the cap so the Warning header can be set before streaming
+	var probe []string
+	if err := q.Session(&gorm.Session{}).Order("started desc").Offset(traceExportMaxRows).Limit(1).Pluck("id", &probe).Error; err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	rows, err := q.Session(&gorm.Session{}).Order("started desc").Limit(traceExportMaxRows).Rows()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	defer rows.Close()
+	from, to := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+	if from == "" {
+		from = "start"
+	}
+	if to == "" {
+		to = "now"
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "traces-"+from+"-"+to+".csv"))
+	if len(probe) > 0 {
+		w.Header().Set("Warning", fmt.Sprintf(`199 hermes "export truncated to %d rows"`, traceExportMaxRows))
+	}
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "method", "path", "status", "userEmail", "userRole", "remoteIp", "reqBytes", "respBytes", "durationMs", "started", "ended"})
+	n := 0
+	for rows.Next() {
+		var t models.TraceRow
+		if err := db.ReadDB().ScanRows(rows, &t); err != nil {
+			break
+		}
+		cw.Write([]string{
+			t.ID, t.Method, t.Path, strconv.Itoa(t.Status), t.UserEmail, t.UserRole, t.RemoteIP,
+			strconv.FormatInt(t.ReqBytes, 10), strconv.FormatInt(t.RespBytes, 10),
+			strconv.FormatFloat(float64(t.DurationNs)/1e6, 'f', 3, 64),
+			t.Started.UTC().Format(time.RFC3339Nano), t.Ended.UTC().Format(time.RFC3339Nano),
+		})
+		// flush periodically so large exports stream instead of buffering
+		if n++; n%1000 == 0 {
+			cw.Flush()
+		}
+	}
+	cw.Flush()
+	addEvent(r, "trace.export", map[string]any{"rows": n, "truncated": len(probe) > 0})
+}
+
+func traceFromRow(r0 models.TraceRow) *Trace {
+	return &Trace{ID: r0.ID, Method: r0.Method, Path: r0.Path, Status: r0.Status, UserEmail: r0.UserEmail, UserRole: r0.UserRole, UserAgent: r0.UserAgent, RemoteIP: r0.RemoteIP, ReqBytes: r0.ReqBytes, RespBytes: r0.RespBytes, Started: r0.Started, Ended: r0.Ended, Duration: time.Duration(r0.DurationNs), TenantID: r0.TenantID, SpanID: r0.SpanID, ParentTraceID: r0.ParentTraceID}
 }
 
 func traceGet(w http.ResponseWriter, r *http.Request) {
@@ -163,13 +581,13 @@ func traceGet(w http.ResponseWriter, r *http.Request) {
 	}
 	// Load trace from DB with events
 	var tr models.TraceRow
-	if err := db.DB.First(&tr, "id = ?", id).Error; err != nil {
+	if err := db.DB.Scopes(tenantScope(r)).First(&tr, "id = ?", id).Error; err != nil {
 		http.Error(w, "not found", 404)
 		return
 	}
 	var evs []models.TraceEventRow
 	_ = db.DB.Where("trace_id = ?", id).Order("time asc").Find(&evs).Error
-	out := &Trace{ID: tr.ID, Method: tr.Method, Path: tr.Path, Status: tr.Status, UserEmail: tr.UserEmail, UserRole: tr.UserRole, UserAgent: tr.UserAgent, RemoteIP: tr.RemoteIP, ReqBytes: tr.ReqBytes, RespBytes: tr.RespBytes, Started: tr.Started, Ended: tr.Ended, Duration: time.Duration(tr.DurationNs)}
+	out := traceFromRow(tr)
 	for _, e := range evs {
 		var f map[string]any
 		if e.Fields != "" {
@@ -177,5 +595,11 @@ func traceGet(w http.ResponseWriter, r *http.Request) {
 		}
 		out.Events = append(out.Events, TraceEvent{Time: e.Time, Name: e.Name, Fields: f})
 	}
-	json.NewEncoder(w).Encode(out)
+	// link the log entries written during the request
+	n, _ := db.CountLogsForTrace(readDB(r), id, tenantScope(r))
+	Respond(w, r, 200, struct {
+		*Trace
+		LogCount int64  `json:"logCount"`
+		LogsURL  string `json:"logsUrl"`
+	}{out, n, "/api/v1/logs/by-trace/" + id})
 }
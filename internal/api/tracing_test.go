@@ -1,8 +1,15 @@
 package api
 
 import (
+	"encoding/json"
 	"net/http/httptest"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
 )
 
 func TestRespondErrorAddsEvent(t *testing.T){
@@ -20,3 +27,140 @@ func TestRespondErrorAddsEvent(t *testing.T){
 	}
 	if !found { t.Fatalf("error event not recorded") }
 }
+
+func TestAddEventSetSerialisesFields(t *testing.T){
+	r := httptest.NewRequest("GET", "/x", nil)
+	tc := &Trace{ID: "t1"}
+	tc.Events = tc.evBuf[:0]
+	r = r.WithContext(withTraceCtx(r.Context(), tc))
+	addEventSet(r, "request.end", FieldSet{}.Add("status", "204").Add("respBytes", "0"))
+	addEvent(r, "custom", map[string]any{"n": 1})
+	b, err := json.Marshal(tc)
+	if err != nil { t.Fatal(err) }
+	var out struct{ Events []struct{ Name string; Fields map[string]any } }
+	if err := json.Unmarshal(b, &out); err != nil { t.Fatal(err) }
+	if len(out.Events) != 2 { t.Fatalf("expected 2 events, got %s", b) }
+	if out.Events[0].Fields["status"] != "204" || out.Events[0].Fields["respBytes"] != "0" { t.Fatalf("fieldset not serialised: %s", b) }
+	if out.Events[1].Fields["n"] != float64(1) { t.Fatalf("map fields lost: %s", b) }
+}
+
+// BenchmarkConcurrentTraceAdd hammers the ring buffer from 100 goroutines; run with -race.
+func BenchmarkConcurrentTraceAdd(b *testing.B){
+	store := &traceStore{buf: make([]*Trace, 1000), size: 1000}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		for g := 0; g < 100; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				store.add(&Trace{ID: strconv.Itoa(g)})
+				store.all(10)
+			}(g)
+		}
+		wg.Wait()
+	}
+	b.StopTimer()
+	if n := len(store.all(0)); n != min(store.size, 100*b.N) { b.Fatalf("expected %d traces, got %d", min(store.size, 100*b.N), n) }
+}
+
+// TestTraceStoreConcurrentSubscribers runs writers, readers and subscribers (which read the
+// ring for every trace they receive) against one store for a second; run with -race.
+func TestTraceStoreConcurrentSubscribers(t *testing.T){
+	store := &traceStore{buf: make([]*Trace, 500), size: 500}
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	var received [3]int
+	for g := 0; g < 3; g++ {
+		ch, cancel := store.subscribe()
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			defer cancel()
+			for {
+				select {
+				case <-stop:
+					return
+				case tr := <-ch:
+					if tr == nil { t.Error("nil trace broadcast"); return }
+					received[g]++
+					store.all(100)
+				}
+			}
+		}(g)
+	}
+	for g := 0; g < 10; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+					store.add(&Trace{ID: strconv.Itoa(g) + "-" + strconv.Itoa(i)})
+				}
+			}
+		}(g)
+	}
+	for g := 0; g < 5; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					if n := len(store.all(100)); n > 100 { t.Errorf("all(100) returned %d traces", n); return }
+				}
+			}
+		}()
+	}
+	time.Sleep(time.Second)
+	close(stop)
+	done := make(chan struct{})
+	go func(){ wg.Wait(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("goroutines did not stop: deadlock")
+	}
+	for g, n := range received {
+		if n == 0 { t.Fatalf("subscriber %d received nothing", g) }
+	}
+	store.subMu.RLock(); defer store.subMu.RUnlock()
+	if len(store.subs) != 0 { t.Fatalf("%d subscribers left after cancel", len(store.subs)) }
+}
+
+func TestPersistTraceConcurrent(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tr := &Trace{ID: "concurrent-" + strconv.Itoa(i), Method: "PUT", Path: "/x", Status: 200, Started: time.Now()}
+			for j := 0; j < 5; j++ {
+				tr.Events = append(tr.Events, TraceEvent{Time: time.Now(), Name: "step", Fields: map[string]any{"n": j}})
+			}
+			persistTrace(tr)
+		}(i)
+	}
+	wg.Wait()
+	// persisting a trace again updates its row instead of violating the primary key
+	persistTrace(&Trace{ID: "concurrent-0", Method: "PUT", Path: "/x", Status: 500})
+
+	var traces, events int64
+	db.DB.Model(&models.TraceRow{}).Where("id LIKE ?", "concurrent-%").Count(&traces)
+	db.DB.Model(&models.TraceEventRow{}).Where("trace_id LIKE ?", "concurrent-%").Count(&events)
+	if traces != 100 || events != 500 {
+		t.Fatalf("expected 100 traces with 500 events, got %d and %d", traces, events)
+	}
+	var row models.TraceRow
+	if err := db.DB.First(&row, "id = ?", "concurrent-0").Error; err != nil || row.Status != 500 {
+		t.Fatalf("expected the re-persisted trace to be updated, got %+v (%v)", row, err)
+	}
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+	"github.com/arencloud/hermes/internal/s3"
+	"github.com/go-chi/chi/v5"
+)
+
+// trash settings (set in Router); an empty trashBucket disables the trash entirely
+var trashBucket string
+var trashRetention = 30 * 24 * time.Hour
+
+func registerTrash(r chi.Router) {
+	r.Get("/providers/{id}/trash", listTrash)
+	r.With(requireEditorOrAdmin).Post("/providers/{id}/trash/{trashId}/restore", restoreTrash)
+}
+
+// moveToTrash copies bucket/key into the trash bucket, records it and removes the original.
+func moveToTrash(r *http.Request, c s3.ClientInterface, pid int, bucket, key string) error {
+	info, err := c.Stat(r.Context(), bucket, key)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	trashKey := "trash/" + key + "/" + now.Format("20060102T150405.000000000Z")
+	if err := c.CopyObject(r.Context(), bucket, key, trashBucket, trashKey); err != nil {
+		return err
+	}
+	item := models.ObjectTrashItem{ProviderID: uint(pid), Bucket: bucket, Key: trashKey, OriginalKey: key, Size: info.Size, DeletedAt: now, ExpiresAt: now.Add(trashRetention)}
+	if u := currentUser(r); u != nil {
+		item.DeletedByEmail = u.Email
+	}
+	if err := db.DB.Create(&item).Error; err != nil {
+		_ = c.DeleteObject(r.Context(), trashBucket, trashKey)
+		return err
+	}
+	if err := c.DeleteObject(r.Context(), bucket, key); err != nil {
+		// keep trash and source consistent: the object was not deleted after all
+		_ = c.DeleteObject(r.Context(), trashBucket, trashKey)
+		_ = db.DB.Delete(&models.ObjectTrashItem{}, item.ID).Error
+		return err
+	}
+	addEvent(r, "object.trash", map[string]any{"bucket": bucket, "key": key, "trashKey": trashKey})
+	return nil
+}
+
+func listTrash(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	pid, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || pid <= 0 {
+		respondError(w, r, 400, "invalid provider id")
+		return
+	}
+	var items []models.ObjectTrashItem
+	if err := readDB(r).Where("provider_id = ? AND restored = ?", pid, false).Order("deleted_at desc").Find(&items).Error; err != nil {
+		respondError(w, r, 500, err.Error())
+		return
+	}
+	// only items of buckets the user may read
+	u := currentUser(r)
+	readable := map[string]bool{}
+	visible := items[:0]
+	for _, it := range items {
+		ok, seen := readable[it.Bucket]
+		if !seen {
+			var err error
+			if ok, err = aclCheck(readDB(r), uint(pid), it.Bucket, u, aclRead); err != nil {
+				respondError(w, r, 500, err.Error())
+				return
+			}
+			readable[it.Bucket] = ok
+		}
+		if ok {
+			visible = append(visible, it)
+		}
+	}
+	json.NewEncoder(w).Encode(visible)
+}
+
+func restoreTrash(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	pid, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || pid <= 0 {
+		respondError(w, r, 400, "invalid provider id")
+		return
+	}
+	tid, err := strconv.Atoi(chi.URLParam(r, "trashId"))
+	if err != nil || tid <= 0 {
+		respondError(w, r, 400, "invalid trash id")
+		return
+	}
+	var item models.ObjectTrashItem
+	if err := db.DB.Where("id = ? AND provider_id = ? AND restored = ?", tid, pid, false).First(&item).Error; err != nil {
+		respondError(w, r, 404, "trash item not found")
+		return
+	}
+	if !enforceACL(w, r, pid, item.Bucket, aclWrite) {
+		return
+	}
+	c, _, err := getClient(pid)
+	if err != nil {
+		respondError(w, r, 404, "provider not found")
+		return
+	}
+	// never replace an object written to the original key since, unless asked to
+	if r.URL.Query().Get("overwrite") != "true" {
+		_, err := c.Stat(r.Context(), item.Bucket, item.OriginalKey)
+		if err == nil {
+			respondError(w, r, 409, "an object already exists at "+item.OriginalKey+"; pass overwrite=true to replace it", ErrCodeConflict)
+			return
+		}
+		if !s3.IsNotFound(err) {
+			code, msg := s3Failure(r, err)
+			respondError(w, r, code, msg, storageErrCode(code, msg))
+			return
+		}
+	}
+	if err := c.CopyObject(r.Context(), trashBucket, item.Key, item.Bucket, item.OriginalKey); err != nil {
+		code, msg := s3Failure(r, err)
+		respondError(w, r, code, msg, storageErrCode(code, msg))
+		return
+	}
+	out := map[string]any{"ok": true, "bucket": item.Bucket, "key": item.OriginalKey}
+	if err := c.DeleteObject(r.Context(), trashBucket, item.Key); err != nil {
+		// the object is back, so the restore succeeded; the item is hidden and expires
+		// now, leaving the copy to the next purge
+		_, msg := s3Failure(r, err)
+		out["warning"] = "the trash copy could not be removed and is left to the purge: " + msg
+		err = db.DB.Model(&item).Updates(map[string]any{"restored": true, "expires_at": time.Now().UTC()}).Error
+		if err != nil {
+			respondError(w, r, 500, err.Error())
+			return
+		}
+	} else if err := db.DB.Delete(&models.ObjectTrashItem{}, item.ID).Error; err != nil {
+		respondError(w, r, 500, err.Error())
+		return
+	}
+	addEvent(r, "object.restore", map[string]any{"bucket": item.Bucket, "key": item.OriginalKey, "trashCopyKept": out["warning"] != nil})
+	json.NewEncoder(w).Encode(out)
+}
+
+// purgeTrash permanently deletes trashed objects past their retention.
+func purgeTrash() error {
+	if db.DB == nil || trashBucket == "" {
+		return nil
+	}
+	var items []models.ObjectTrashItem
+	if err := db.DB.Where("expires_at < ?", time.Now().UTC()).Find(&items).Error; err != nil {
+		return err
+	}
+	clients := map[uint]s3.ClientInterface{}
+	for _, it := range items {
+		c, ok := clients[it.ProviderID]
+		if !ok {
+			c, _, _ = getClient(int(it.ProviderID))
+			clients[it.ProviderID] = c
+		}
+		if c != nil {
+			if err := c.DeleteObject(context.Background(), trashBucket, it.Key); err != nil {
+				continue // retry on the next run
+			}
+		}
+		_ = db.DB.Delete(&models.ObjectTrashItem{}, it.ID).Error
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/arencloud/hermes/internal/config"
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/models"
+	"github.com/arencloud/hermes/internal/s3"
+)
+
+// TestTrashLifecycle deletes an object into the trash, lists and restores it, then purges
+// a trashed object past its retention.
+func TestTrashLifecycle(t *testing.T) {
+	ts, _ := setupTestServer(t, func(c *config.Config) { c.TrashBucket = "trash" })
+	defer ts.Close()
+	store := newMemS3(t)
+	store.buckets["docs"] = map[string][]byte{"report.txt": []byte("v1")}
+	store.buckets["trash"] = map[string][]byte{}
+	pid := mockProvider(t)
+	editor := loginAs(t, ts, "trash-editor@example.com", "editor")
+	do := func(method, path string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, fmt.Sprintf("%s/api/v1/providers/%d%s", ts.URL, pid, path), nil)
+		req.AddCookie(editor)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	list := func() []models.ObjectTrashItem {
+		t.Helper()
+		resp := do("GET", "/trash")
+		var items []models.ObjectTrashItem
+		if resp.StatusCode != 200 || json.NewDecoder(resp.Body).Decode(&items) != nil {
+			t.Fatalf("list trash: %d", resp.StatusCode)
+		}
+		return items
+	}
+
+	if resp := do("DELETE", "/buckets/docs/objects?key=report.txt"); resp.StatusCode != 204 {
+		t.Fatalf("delete: %d", resp.StatusCode)
+	}
+	if _, ok := store.object("docs", "report.txt"); ok {
+		t.Fatal("deleted object is still in its bucket")
+	}
+	items := list()
+	if len(items) != 1 || items[0].OriginalKey != "report.txt" || items[0].Bucket != "docs" {
+		t.Fatalf("trash listing: %+v", items)
+	}
+	if _, ok := store.object("trash", items[0].Key); !ok {
+		t.Fatalf("no trash copy at %s", items[0].Key)
+	}
+	restore := fmt.Sprintf("/trash/%d/restore", items[0].ID)
+
+	// a newer object at the original key is not replaced silently
+	store.buckets["docs"]["report.txt"] = []byte("v2")
+	if resp := do("POST", restore); resp.StatusCode != 409 {
+		t.Fatalf("restore over an existing object: %d, want 409", resp.StatusCode)
+	}
+	if b, _ := store.object("docs", "report.txt"); string(b) != "v2" {
+		t.Fatalf("existing object replaced by %q", b)
+	}
+
+	if resp := do("POST", restore+"?overwrite=true"); resp.StatusCode != 200 {
+		t.Fatalf("restore: %d", resp.StatusCode)
+	}
+	if b, _ := store.object("docs", "report.txt"); string(b) != "v1" {
+		t.Fatalf("restored %q, want v1", b)
+	}
+	if len(store.buckets["trash"]) != 0 || len(list()) != 0 {
+		t.Fatal("restored item left in the trash")
+	}
+
+	// a trash copy that cannot be removed does not fail the restore; the purge removes it
+	if resp := do("DELETE", "/buckets/docs/objects?key=report.txt"); resp.StatusCode != 204 {
+		t.Fatalf("delete before a failing restore: %d", resp.StatusCode)
+	}
+	c, _ := clientFactory(models.Provider{})
+	m := c.(*s3.MockClient)
+	deleteObject := m.OnDeleteObject
+	m.OnDeleteObject = func(bucket, key string) error {
+		if bucket == "trash" {
+			return errors.New("trash unavailable")
+		}
+		return deleteObject(bucket, key)
+	}
+	resp := do("POST", fmt.Sprintf("/trash/%d/restore", list()[0].ID))
+	var out struct {
+		Warning string `json:"warning"`
+	}
+	if resp.StatusCode != 200 || json.NewDecoder(resp.Body).Decode(&out) != nil || out.Warning == "" {
+		t.Fatalf("restore with a failing trash delete: %d %+v, want 200 with a warning", resp.StatusCode, out)
+	}
+	if _, ok := store.object("docs", "report.txt"); !ok {
+		t.Fatal("object not restored")
+	}
+	if len(list()) != 0 {
+		t.Fatal("restored item still listed")
+	}
+	m.OnDeleteObject = deleteObject
+	if err := purgeTrash(); err != nil {
+		t.Fatal(err)
+	}
+	var n int64
+	db.DB.Model(&models.ObjectTrashItem{}).Count(&n)
+	if len(store.buckets["trash"]) != 0 || n != 0 {
+		t.Fatal("purge left the trash copy of a restored item")
+	}
+
+	if resp := do("DELETE", "/buckets/docs/objects?key=report.txt"); resp.StatusCode != 204 {
+		t.Fatalf("second delete: %d", resp.StatusCode)
+	}
+	db.DB.Model(&models.ObjectTrashItem{}).Where("provider_id = ?", pid).Update("expires_at", time.Now().Add(-time.Hour))
+	if err := purgeTrash(); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.buckets["trash"]) != 0 || len(list()) != 0 {
+		t.Fatal("expired item was not purged")
+	}
+}
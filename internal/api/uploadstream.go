@@ -0,0 +1,299 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// uploadSessionTTL is how long an upload session waits for its upload to start.
+	uploadSessionTTL = 10 * time.Minute
+	// uploadSessionLinger keeps a finished session around so a late progress stream
+	// still gets the final frame.
+	uploadSessionLinger    = time.Minute
+	uploadProgressInterval = 250 * time.Millisecond
+)
+
+func registerUploadStream(r chi.Router) {
+	r.Group(func(gr chi.Router) {
+		gr.Use(requireEditorOrAdmin)
+		gr.Post("/providers/{id}/buckets/{name}/upload-session", createUploadSession)
+		gr.With(noAPITimeout).Post("/providers/{id}/buckets/{name}/upload-stream", uploadStream)
+	})
+	r.With(noAPITimeout).Get("/providers/{id}/upload-progress/{uploadToken}", uploadProgress)
+}
+
+// uploadSession ties a raw-body upload to the SSE stream reporting its progress. The
+// upload publishes the running byte count on progress and closes done when it ends.
+type uploadSession struct {
+	providerID uint
+	bucket     string
+	owner      string
+	progress   chan int64
+	done       chan struct{}
+	started    bool
+	total      int64 // set with started
+	expires    time.Time
+
+	// set before done is closed
+	key      string
+	uploaded int64
+	err      string
+}
+
+var uploadSessions = struct {
+	sync.Mutex
+	m map[string]*uploadSession
+}{m: map[string]*uploadSession{}}
+
+// lookupUploadSession returns the live session for token, dropping expired ones.
+func lookupUploadSession(token string) *uploadSession {
+	uploadSessions.Lock()
+	defer uploadSessions.Unlock()
+	now := time.Now()
+	for t, s := range uploadSessions.m {
+		if now.After(s.expires) {
+			delete(uploadSessions.m, t)
+		}
+	}
+	return uploadSessions.m[token]
+}
+
+func userEmail(r *http.Request) string {
+	if u := currentUser(r); u != nil {
+		return u.Email
+	}
+	return ""
+}
+
+// createUploadSession issues the one-time token for an upload-stream request and its
+// progress stream.
+func createUploadSession(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	pid, bucket, ok := aclParams(w, r)
+	if !ok || !enforceACL(w, r, int(pid), bucket, aclWrite) {
+		return
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		respondError(w, r, 500, "could not create upload session")
+		return
+	}
+	token := hex.EncodeToString(b)
+	s := &uploadSession{providerID: pid, bucket: bucket, owner: userEmail(r), progress: make(chan int64, 1), done: make(chan struct{}), expires: time.Now().Add(uploadSessionTTL)}
+	lookupUploadSession("") // sweep expired sessions
+	uploadSessions.Lock()
+	uploadSessions.m[token] = s
+	uploadSessions.Unlock()
+	addEvent(r, "upload_session.create", map[string]any{"bucket": bucket})
+	Respond(w, r, 201, map[string]any{
+		"uploadToken": token,
+		"progressUrl": "/api/v1/providers/" + strconv.Itoa(int(pid)) + "/upload-progress/" + token,
+		"expiresAt":   s.expires.UTC(),
+	})
+}
+
+// progressReader counts the bytes read and publishes the running total, replacing a
+// value the progress stream has not picked up yet rather than blocking the upload.
+type progressReader struct {
+	r   io.Reader
+	n   atomic.Int64
+	out chan int64
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		total := p.n.Add(int64(n))
+		select {
+		case <-p.out:
+		default:
+		}
+		select {
+		case p.out <- total:
+		default:
+		}
+	}
+	return n, err
+}
+
+// uploadStream stores the raw request body as ?key=, reporting progress to the session
+// named by ?uploadToken=. ?size= (or Content-Length) is the total shown as progress.
+func uploadStream(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	pid, bucket, ok := aclParams(w, r)
+	if !ok {
+		return
+	}
+	key := r.URL.Query().Get("key")
+	addEvent(r, "object.upload", map[string]any{"bucket": bucket, "key": key, "stream": true})
+	if key == "" {
+		respondError(w, r, 400, "key is required")
+		return
+	}
+	size := r.ContentLength
+	if v := r.URL.Query().Get("size"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			respondError(w, r, 400, "size must be a non-negative integer")
+			return
+		}
+		size = n
+	}
+	token := r.URL.Query().Get("uploadToken")
+	s := lookupUploadSession(token)
+	uploadSessions.Lock()
+	valid := s != nil && !s.started && s.providerID == pid && s.bucket == bucket && s.owner == userEmail(r)
+	if valid {
+		s.started = true // the token is single use
+		s.total = size
+	}
+	uploadSessions.Unlock()
+	if !valid {
+		respondError(w, r, 401, "invalid or used upload token")
+		return
+	}
+	finish := func(uploaded int64, errMsg string) {
+		s.key, s.uploaded, s.err = key, uploaded, errMsg
+		uploadSessions.Lock()
+		s.expires = time.Now().Add(uploadSessionLinger)
+		uploadSessions.Unlock()
+		close(s.done)
+	}
+	fail := func(code int, msg string) {
+		finish(0, msg)
+		respondError(w, r, code, msg)
+	}
+	if !enforceACL(w, r, int(pid), bucket, aclWrite) {
+		finish(0, "forbidden")
+		return
+	}
+	c, prov, err := getClient(int(pid))
+	if err != nil {
+		fail(404, "provider not found")
+		return
+	}
+	if !checkQuota(w, r, prov, true, size) {
+		finish(0, "quota exceeded")
+		return
+	}
+	var body io.Reader = r.Body
+	if limit := objectSizeLimit(pid, bucket); limit > 0 {
+		if size > limit {
+			fail(413, "payload too large")
+			return
+		}
+		body = http.MaxBytesReader(w, r.Body, limit)
+	}
+	ct := r.Header.Get("Content-Type")
+	if ct == "" {
+		ct = "application/octet-stream"
+	}
+	ctx, done, ok := trackUpload(r.Context(), c, bucket, key)
+	if !ok {
+		fail(503, "server is shutting down")
+		return
+	}
+	pr := &progressReader{r: body, out: s.progress}
+	info, err := c.Upload(ctx, bucket, key, pr, size, ct)
+	done()
+	if err != nil {
+		if tooLarge(err) {
+			fail(413, "payload too large")
+			return
+		}
+		code, msg := s3Failure(r, err)
+		fail(code, msg)
+		return
+	}
+	finish(pr.n.Load(), "")
+	adjustBucketSummary(pid, bucket, 1, info.Size)
+	if err := addQuotaUsage(prov, info.Size, 0); err != nil {
+		apiLogger.Error("quota update failed", "component", "object.upload", "provider", pid, "error", err)
+	}
+	addEvent(r, "object.upload.done", map[string]any{"bucket": bucket, "key": key})
+	Respond(w, r, 200, info)
+}
+
+// uploadProgress streams the progress of an upload session as SSE: a
+// {bytesUploaded,totalBytes,percent} frame every 250 ms while bytes arrive, then
+// {done,key} (or {error}) when the upload ends.
+func uploadProgress(w http.ResponseWriter, r *http.Request) {
+	pid, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || pid <= 0 {
+		respondError(w, r, 400, "invalid provider id")
+		return
+	}
+	s := lookupUploadSession(chi.URLParam(r, "uploadToken"))
+	if s == nil || s.providerID != uint(pid) || s.owner != userEmail(r) {
+		respondError(w, r, 404, "upload session not found")
+		return
+	}
+	fl, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", 500)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(200)
+	fl.Flush()
+	send := func(v map[string]any) {
+		b, _ := json.Marshal(v)
+		w.Write([]byte("data: "))
+		w.Write(b)
+		w.Write([]byte("\n\n"))
+		fl.Flush()
+	}
+	progress := func(n, total int64) map[string]any {
+		pct := 0
+		if total > 0 {
+			pct = int(n * 100 / total)
+		}
+		return map[string]any{"bytesUploaded": n, "totalBytes": total, "percent": pct}
+	}
+	total := func() int64 {
+		uploadSessions.Lock()
+		defer uploadSessions.Unlock()
+		return s.total
+	}
+	tick := time.NewTicker(uploadProgressInterval)
+	defer tick.Stop()
+	var latest, sent int64
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case n := <-s.progress:
+			latest = n
+		case <-tick.C:
+			if latest != sent {
+				send(progress(latest, total()))
+				sent = latest
+			}
+		case <-s.done:
+			if s.err != "" {
+				send(map[string]any{"error": s.err})
+				return
+			}
+			if s.uploaded != sent {
+				send(progress(s.uploaded, total()))
+			}
+			send(map[string]any{"done": true, "key": s.key})
+			uploadSessions.Lock()
+			delete(uploadSessions.m, chi.URLParam(r, "uploadToken"))
+			uploadSessions.Unlock()
+			return
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	uploadTokenDefaultTTL = 5 * time.Minute
+	uploadTokenMaxTTL     = 24 * time.Hour
+)
+
+func registerUploadToken(r chi.Router) {
+	r.With(requireEditorOrAdmin).Post("/providers/{id}/buckets/{name}/upload-token", createUploadToken)
+}
+
+// uploadClaims are the constraints carried by an upload token. The token is a JWT
+// (HS256, signed with the session secret) so the upload endpoint needs no login. Use
+// keeps it from being accepted as a provider access token and the other way round.
+type uploadClaims struct {
+	Use         string `json:"use"`
+	ProviderID  uint   `json:"pid"`
+	Bucket      string `json:"bucket"`
+	Key         string `json:"key"`
+	ContentType string `json:"contentType"`
+	MaxSize     int64  `json:"maxSize"`
+	IssuedBy    string `json:"sub,omitempty"`
+	Expires     int64  `json:"exp"`
+}
+
+var uploadTokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+const uploadTokenUse = "upload"
+
+var errUploadToken = errors.New("invalid or expired upload token")
+
+// uploadTokenPath is the route prefix that carries an upload token.
+const uploadTokenPath = "/api/v1/upload/"
+
+// redactUploadToken hides the token of an upload path. Traces and request logs are
+// readable by every user, and the token is a credential for the bucket.
+func redactUploadToken(path string) string {
+	if strings.HasPrefix(path, uploadTokenPath) {
+		return uploadTokenPath + "[redacted]"
+	}
+	return path
+}
+
+func signUploadToken(c uploadClaims) string {
+	c.Use = uploadTokenUse
+	payload, _ := json.Marshal(c)
+	unsigned := uploadTokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + sign(unsigned)
+}
+
+// parseUploadToken checks the signature and expiry of token. Only the header this
+// package issues is accepted, so the algorithm cannot be swapped (e.g. to "none").
+func parseUploadToken(token string, now time.Time) (uploadClaims, error) {
+	var c uploadClaims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != uploadTokenHeader {
+		return c, errUploadToken
+	}
+	if !hmac.Equal([]byte(sign(parts[0]+"."+parts[1])), []byte(parts[2])) {
+		return c, errUploadToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(payload, &c) != nil || c.Use != uploadTokenUse {
+		return c, errUploadToken
+	}
+	if now.Unix() >= c.Expires {
+		return c, errUploadToken
+	}
+	return c, nil
+}
+
+// createUploadToken issues a token allowing one object to be uploaded to bucket/key
+// without credentials, with the given content type and size cap.
+func createUploadToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	pid, bucket, ok := aclParams(w, r)
+	if !ok || !enforceACL(w, r, int(pid), bucket, aclWrite) {
+		return
+	}
+	var in struct {
+		Key          string `json:"key"`
+		ContentType  string `json:"contentType"`
+		MaxSizeBytes int64  `json:"maxSizeBytes"`
+		TTLSeconds   int64  `json:"ttlSeconds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondError(w, r, 400, err.Error())
+		return
+	}
+	if in.Key == "" {
+		respondError(w, r, 400, "key is required")
+		return
+	}
+	ct, _, err := mime.ParseMediaType(in.ContentType)
+	if err != nil {
+		respondError(w, r, 400, "contentType must be a media type such as image/png")
+		return
+	}
+	if in.MaxSizeBytes <= 0 {
+		respondError(w, r, 400, "maxSizeBytes must be positive")
+		return
+	}
+	if limit := objectSizeLimit(pid, bucket); limit > 0 && in.MaxSizeBytes > limit {
+		respondError(w, r, 400, "maxSizeBytes exceeds the bucket's size limit")
+		return
+	}
+	ttl := uploadTokenDefaultTTL
+	if in.TTLSeconds != 0 {
+		ttl = time.Duration(in.TTLSeconds) * time.Second
+	}
+	if ttl <= 0 || ttl > uploadTokenMaxTTL {
+		respondError(w, r, 400, "ttlSeconds must be between 1 and 86400")
+		return
+	}
+	if _, _, err := getClient(int(pid)); err != nil {
+		respondError(w, r, 404, "provider not found")
+		return
+	}
+	exp := time.Now().Add(ttl)
+	claims := uploadClaims{ProviderID: pid, Bucket: bucket, Key: in.Key, ContentType: ct, MaxSize: in.MaxSizeBytes, Expires: exp.Unix()}
+	if u := currentUser(r); u != nil {
+		claims.IssuedBy = u.Email
+	}
+	token := signUploadToken(claims)
+	addEvent(r, "upload_token.create", map[string]any{"bucket": bucket, "key": in.Key, "contentType": ct, "maxSize": in.MaxSizeBytes, "ttl": ttl.String()})
+	Respond(w, r, 201, map[string]any{
+		"token":     token,
+		"uploadUrl": "/api/v1/upload/" + token,
+		"expiresAt": time.Unix(claims.Expires, 0).UTC(),
+	})
+}
+
+// uploadWithToken stores the request body as the object named in the token, after
+// checking the request against the token's content type and size. The upload is stored
+// like any other, under the bucket's current object size limit when that is lower.
+func uploadWithToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	claims, err := parseUploadToken(chi.URLParam(r, "token"), time.Now())
+	if err != nil {
+		respondError(w, r, 401, err.Error(), ErrCodeUnauthorized)
+		return
+	}
+	addEvent(r, "object.upload", map[string]any{"bucket": claims.Bucket, "key": claims.Key, "token": true, "issuedBy": claims.IssuedBy})
+	if ct, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || !strings.EqualFold(ct, claims.ContentType) {
+		respondError(w, r, 415, "Content-Type must be "+claims.ContentType, ErrCodeValidation)
+		return
+	}
+	if r.ContentLength < 0 {
+		respondError(w, r, 411, "Content-Length is required", ErrCodeMissingField)
+		return
+	}
+	limit := claims.MaxSize
+	if l := objectSizeLimit(claims.ProviderID, claims.Bucket); l > 0 && l < limit {
+		limit = l
+	}
+	if r.ContentLength > limit {
+		respondError(w, r, 413, "payload too large", ErrCodePayloadTooLarge)
+		return
+	}
+	c, prov, err := getClient(int(claims.ProviderID))
+	if err != nil {
+		respondError(w, r, 404, "provider not found", ErrCodeProviderNotFound)
+		return
+	}
+	if !checkQuota(w, r, prov, true, r.ContentLength) {
+		return
+	}
+	if err := acquireUploadSlot(r); err != nil {
+		respondError(w, r, 503, "too many concurrent uploads", ErrCodeStorageBusy)
+		return
+	}
+	defer uploadLimiter.Release()
+	info, status, msg, code := storeUpload(r, c, claims.ProviderID, prov, claims.Bucket, claims.Key, r.Body, r.ContentLength, claims.ContentType, limit, nil)
+	if status != 0 {
+		respondError(w, r, status, msg, code)
+		return
+	}
+	Respond(w, r, 200, info)
+}
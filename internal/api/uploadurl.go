@@ -0,0 +1,154 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// uploadURLMaxBytes caps a resource fetched by uploadFromURL; a lower object size limit of
+// the bucket applies instead.
+const uploadURLMaxBytes = 1 << 30
+
+// uploadURLTimeout is how long the remote server has to answer; the body then streams for
+// as long as the transfer takes.
+var uploadURLTimeout = 30 * time.Second
+
+// uploadURLAllowPrivate lets uploadFromURL fetch from loopback, private and link-local
+// addresses (set in Router, UPLOAD_URL_ALLOW_PRIVATE).
+var uploadURLAllowPrivate bool
+
+var errPrivateAddress = errors.New("address is not public")
+
+// uploadURLClient fetches resources for uploadFromURL. It dials directly, without a proxy,
+// so that dialPublicOnly sees the address of every connection, redirects included.
+var uploadURLClient = &http.Client{Transport: &http.Transport{
+	DialContext:         (&net.Dialer{Timeout: 10 * time.Second, Control: dialPublicOnly}).DialContext,
+	TLSHandshakeTimeout: 10 * time.Second,
+}}
+
+// dialPublicOnly refuses connections to non-public addresses, so that editors cannot use
+// Hermes to reach internal services, unless uploadURLAllowPrivate is set.
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	if uploadURLAllowPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+// uploadURLResult is the object stored by uploadFromURL.
+type uploadURLResult struct {
+	Key         string `json:"key"`
+	Size        int64  `json:"size"`
+	ETag        string `json:"etag"`
+	ContentType string `json:"contentType"`
+}
+
+// uploadFromURL fetches an http(s) resource and streams it into the bucket, under key or
+// else the last segment of the URL path. The remote Content-Type is kept.
+func uploadFromURL(w http.ResponseWriter, r *http.Request) {
+	pid, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || pid <= 0 {
+		respondError(w, r, 400, "invalid provider id", ErrCodeInvalidID)
+		return
+	}
+	bucket := chi.URLParam(r, "name")
+	if !enforceACL(w, r, pid, bucket, aclWrite) {
+		return
+	}
+	var in struct {
+		URL string `json:"url"`
+		Key string `json:"key"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondError(w, r, 400, err.Error(), ErrCodeInvalidRequest)
+		return
+	}
+	u, err := url.Parse(in.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		respondError(w, r, 400, "url must be an absolute http or https URL", ErrCodeValidation)
+		return
+	}
+	key := in.Key
+	if key == "" {
+		if base := path.Base(u.Path); base != "." && base != "/" {
+			key = base
+		}
+	}
+	if key == "" {
+		respondError(w, r, 400, "key is required when the url has no file name", ErrCodeMissingField)
+		return
+	}
+	c, prov, err := getClient(pid)
+	if err != nil {
+		respondError(w, r, 404, "provider not found", ErrCodeProviderNotFound)
+		return
+	}
+	if !checkQuota(w, r, prov, true, 0) {
+		return
+	}
+	if err := acquireUploadSlot(r); err != nil {
+		respondError(w, r, 503, "too many concurrent uploads", ErrCodeStorageBusy)
+		return
+	}
+	defer uploadLimiter.Release()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		respondError(w, r, 400, err.Error(), ErrCodeValidation)
+		return
+	}
+	// the timeout covers the wait for the response only, not the transfer
+	timer := time.AfterFunc(uploadURLTimeout, cancel)
+	resp, err := uploadURLClient.Do(req)
+	timer.Stop()
+	if err != nil {
+		addEvent(r, "object.upload_url", map[string]any{"url": u.Redacted(), "error": err.Error()})
+		if errors.Is(err, errPrivateAddress) {
+			respondError(w, r, 400, "url must point to a public address", ErrCodeValidation)
+			return
+		}
+		respondError(w, r, 502, "fetch failed: "+err.Error(), ErrCodeFetchFailed)
+		return
+	}
+	defer resp.Body.Close()
+	addEvent(r, "object.upload_url", map[string]any{"url": u.Redacted(), "httpStatus": resp.StatusCode})
+	if resp.StatusCode >= 400 {
+		respondError(w, r, 400, "fetch failed: remote server answered "+resp.Status, ErrCodeFetchFailed)
+		return
+	}
+	limit := int64(uploadURLMaxBytes)
+	if l := objectSizeLimit(uint(pid), bucket); l > 0 && l < limit {
+		limit = l
+	}
+	if resp.ContentLength > limit {
+		respondError(w, r, 413, "payload too large", ErrCodePayloadTooLarge)
+		return
+	}
+	ct := resp.Header.Get("Content-Type")
+	info, status, msg, code := storeUpload(r, c, uint(pid), prov, bucket, key, resp.Body, resp.ContentLength, ct, limit, nil)
+	if status != 0 {
+		respondError(w, r, status, msg, code)
+		return
+	}
+	Respond(w, r, 201, uploadURLResult{Key: key, Size: info.Size, ETag: info.ETag, ContentType: ct})
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	minio "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+func TestUploadFromURL(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/files/report.csv":
+			w.Header().Set("Content-Type", "text/csv")
+			io.WriteString(w, "a,b\n1,2\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer remote.Close()
+	m := useMockS3(t)
+	var mu sync.Mutex
+	stored := map[string]string{}
+	types := map[string]string{}
+	m.OnUpload = func(bucket, key string, reader io.Reader, size int64, contentType string, sse encrypt.ServerSide) (minio.UploadInfo, error) {
+		b, err := io.ReadAll(reader)
+		if err != nil {
+			return minio.UploadInfo{}, err
+		}
+		mu.Lock()
+		stored[key], types[key] = string(b), contentType
+		mu.Unlock()
+		return minio.UploadInfo{Bucket: bucket, Key: key, Size: int64(len(b)), ETag: "etag"}, nil
+	}
+	pid := mockProvider(t)
+	editor := loginAs(t, ts, "upload-url-editor@example.com", "editor")
+	viewer := loginAs(t, ts, "upload-url-viewer@example.com", "viewer")
+	post := func(body string, c *http.Cookie) (int, uploadURLResult) {
+		t.Helper()
+		req, _ := http.NewRequest("POST", fmt.Sprintf("%s/api/v1/providers/%d/buckets/b/upload-url", ts.URL, pid), strings.NewReader(body))
+		req.AddCookie(c)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var env struct {
+			Data uploadURLResult `json:"data"`
+		}
+		json.NewDecoder(resp.Body).Decode(&env)
+		return resp.StatusCode, env.Data
+	}
+	src := `{"url":"` + remote.URL + `/files/report.csv"}`
+
+	// the test server listens on loopback, which is refused by default
+	if code, _ := post(src, editor); code != 400 {
+		t.Fatalf("loopback url: %d, want 400", code)
+	}
+	uploadURLAllowPrivate = true
+	t.Cleanup(func() { uploadURLAllowPrivate = false })
+
+	code, out := post(src, editor)
+	if code != 201 || out.Key != "report.csv" || out.Size != 8 || out.ContentType != "text/csv" {
+		t.Fatalf("upload-url: %d %+v", code, out)
+	}
+	mu.Lock()
+	if stored["report.csv"] != "a,b\n1,2\n" || types["report.csv"] != "text/csv" {
+		t.Fatalf("stored %q as %q", stored["report.csv"], types["report.csv"])
+	}
+	mu.Unlock()
+	if code, out := post(`{"url":"`+remote.URL+`/files/report.csv","key":"imports/r.csv"}`, editor); code != 201 || out.Key != "imports/r.csv" {
+		t.Fatalf("explicit key: %d %+v", code, out)
+	}
+
+	for name, body := range map[string]string{
+		"remote 404":       `{"url":"` + remote.URL + `/missing.csv"}`,
+		"ftp url":          `{"url":"ftp://example.com/file.csv"}`,
+		"relative url":     `{"url":"/files/report.csv"}`,
+		"no file name":     `{"url":"` + remote.URL + `/"}`,
+		"malformed body":   `{"url":`,
+		"empty url string": `{"url":""}`,
+	} {
+		if code, _ := post(body, editor); code != 400 {
+			t.Errorf("%s: %d, want 400", name, code)
+		}
+	}
+	if code, _ := post(src, viewer); code != 403 {
+		t.Fatalf("viewer: %d, want 403", code)
+	}
+}
+
+func TestDialPublicOnly(t *testing.T) {
+	for addr, ok := range map[string]bool{
+		"93.184.216.34:443":  true,
+		"[2606:4700::1]:80":  true,
+		"127.0.0.1:80":       false,
+		"10.1.2.3:9000":      false,
+		"192.168.0.10:80":    false,
+		"169.254.169.254:80": false,
+		"[::1]:443":          false,
+		"0.0.0.0:80":         false,
+	} {
+		if err := dialPublicOnly("tcp", addr, nil); (err == nil) != ok {
+			t.Errorf("%s: %v", addr, err)
+		}
+	}
+}
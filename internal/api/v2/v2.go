@@ -0,0 +1,191 @@
+// Package v2 holds the response conventions of /api/v2. The v2 routes run the same
+// handlers as /api/v1; Middleware translates their output so that every JSON response
+// uses the data envelope, errors are RFC 7807 problem documents and offset-paged lists
+// are walked with opaque cursors.
+package v2
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version is the X-API-Version value of v2 responses.
+const Version = "v2"
+
+// Problem is an RFC 7807 error response. Extension members from a JSON error body
+// (for example validation errors) are kept alongside the standard ones.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// EncodeCursor returns the opaque cursor for the page starting at offset.
+func EncodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("o:" + strconv.Itoa(offset)))
+}
+
+// DecodeCursor reverses EncodeCursor. ok is false for anything it did not produce, such
+// as the trace-ID cursors of /trace/list, which are passed to the handler untouched.
+func DecodeCursor(c string) (offset int, ok bool) {
+	b, err := base64.RawURLEncoding.DecodeString(c)
+	if err != nil || !bytes.HasPrefix(b, []byte("o:")) {
+		return 0, false
+	}
+	n, err := strconv.Atoi(string(b[2:]))
+	return n, err == nil && n >= 0
+}
+
+// Middleware applies the v2 conventions to the handlers behind it. A ?cursor= issued by
+// v2 is turned back into the offset the handler understands. JSON bodies and error
+// responses are buffered and rewritten; everything else (downloads, NDJSON progress,
+// event streams) passes through as it is written.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-API-Version", Version)
+		if off, ok := DecodeCursor(r.URL.Query().Get("cursor")); ok {
+			q := r.URL.Query()
+			q.Del("cursor")
+			q.Set("offset", strconv.Itoa(off))
+			u := *r.URL
+			u.RawQuery = q.Encode()
+			r2 := *r
+			r2.URL = &u
+			r = &r2
+		}
+		vw := &writer{ResponseWriter: w, code: 200}
+		next.ServeHTTP(vw, r)
+		vw.finish(r.URL.Path)
+	})
+}
+
+// writer decides at WriteHeader whether the response is rewritten (JSON or an error) or
+// streamed through.
+type writer struct {
+	http.ResponseWriter
+	code    int
+	decided bool
+	rewrite bool
+	buf     bytes.Buffer
+}
+
+func (vw *writer) WriteHeader(code int) {
+	if vw.decided {
+		return
+	}
+	vw.decided = true
+	vw.code = code
+	ct := vw.Header().Get("Content-Type")
+	vw.rewrite = code != http.StatusNoContent && code != http.StatusNotModified &&
+		(strings.HasPrefix(ct, "application/json") || (code >= 400 && (ct == "" || strings.HasPrefix(ct, "text/plain"))))
+	if !vw.rewrite {
+		vw.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (vw *writer) Write(b []byte) (int, error) {
+	if !vw.decided {
+		vw.WriteHeader(200)
+	}
+	if vw.rewrite {
+		return vw.buf.Write(b)
+	}
+	return vw.ResponseWriter.Write(b)
+}
+
+func (vw *writer) Flush() {
+	if vw.rewrite {
+		return
+	}
+	if f, ok := vw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (vw *writer) finish(path string) {
+	if !vw.rewrite {
+		return
+	}
+	requestID := vw.Header().Get("X-Trace-Id")
+	var out any
+	if vw.code >= 400 {
+		vw.Header().Set("Content-Type", "application/problem+json")
+		out = problem(vw.code, vw.buf.Bytes(), path, requestID)
+	} else {
+		out = envelope(vw.buf.Bytes(), requestID)
+	}
+	vw.Header().Del("Content-Length")
+	vw.Header().Del("X-Content-Type-Options") // set by http.Error for its text body
+	vw.ResponseWriter.WriteHeader(vw.code)
+	json.NewEncoder(vw.ResponseWriter).Encode(out)
+}
+
+// problem builds the problem document for an error body: plain text becomes the detail,
+// a JSON object's "error"/"message" does, and its other members are kept as extensions.
+func problem(code int, body []byte, path, requestID string) any {
+	p := Problem{Type: "about:blank", Title: http.StatusText(code), Status: code, Instance: path, RequestID: requestID}
+	var obj map[string]any
+	if json.Unmarshal(body, &obj) != nil {
+		p.Detail = strings.TrimSpace(string(body))
+		return p
+	}
+	for _, k := range []string{"error", "message"} {
+		if s, ok := obj[k].(string); ok && p.Detail == "" {
+			p.Detail = s
+			delete(obj, k)
+		}
+	}
+	b, _ := json.Marshal(p)
+	var merged map[string]any
+	json.Unmarshal(b, &merged)
+	for k, v := range obj {
+		if _, std := merged[k]; !std {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// envelope wraps a bare JSON body in the data envelope. Bodies already in the envelope
+// keep it and gain pagination.nextCursor when more items follow; an empty body becomes
+// null data.
+func envelope(body []byte, requestID string) any {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if len(bytes.TrimSpace(body)) > 0 && dec.Decode(&v) != nil {
+		v = strings.TrimSpace(string(body)) // mislabelled text: keep it as a string
+	}
+	if obj, ok := v.(map[string]any); ok {
+		if _, hasData := obj["data"]; hasData {
+			if _, hasID := obj["requestId"]; hasID {
+				addNextCursor(obj)
+				return obj
+			}
+		}
+	}
+	return map[string]any{"data": v, "requestId": requestID, "timestamp": time.Now().UTC().Format(time.RFC3339)}
+}
+
+func addNextCursor(env map[string]any) {
+	p, ok := env["pagination"].(map[string]any)
+	if !ok {
+		return
+	}
+	num := func(k string) int {
+		n, _ := p[k].(json.Number)
+		i, _ := n.Int64()
+		return int(i)
+	}
+	if next := num("offset") + num("limit"); num("limit") > 0 && next < num("total") {
+		p["nextCursor"] = EncodeCursor(next)
+	}
+}
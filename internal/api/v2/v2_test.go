@@ -0,0 +1,59 @@
+package v2
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	if n, ok := DecodeCursor(EncodeCursor(40)); !ok || n != 40 {
+		t.Fatalf("round trip gave %d %v", n, ok)
+	}
+	for _, c := range []string{"", "18df09279c73de18", EncodeCursor(-1)} {
+		if _, ok := DecodeCursor(c); ok {
+			t.Fatalf("foreign cursor %q accepted", c)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	serve := func(h http.HandlerFunc, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		Middleware(h).ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		return rec
+	}
+
+	// JSON error bodies keep their extra members next to the problem fields
+	rec := serve(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(422)
+		w.Write([]byte(`{"error":"validation failed","errors":[{"field":"name"}]}`))
+	}, "/api/v2/providers")
+	var p map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &p)
+	if rec.Header().Get("Content-Type") != "application/problem+json" || p["detail"] != "validation failed" || p["status"] != float64(422) || p["errors"] == nil {
+		t.Fatalf("problem %s", rec.Body)
+	}
+
+	// the cursor reaches the handler as an offset
+	var offset string
+	serve(func(w http.ResponseWriter, r *http.Request) { offset = r.URL.Query().Get("offset") }, "/x?cursor="+EncodeCursor(25))
+	if offset != "25" {
+		t.Fatalf("offset=%q", offset)
+	}
+
+	// streams pass through unbuffered
+	rec = serve(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Write([]byte("{\"progress\":50}\n"))
+		w.(http.Flusher).Flush()
+		if rec := w.(*writer); rec.buf.Len() != 0 {
+			t.Error("stream was buffered")
+		}
+	}, "/x")
+	if rec.Body.String() != "{\"progress\":50}\n" || !rec.Flushed {
+		t.Fatalf("stream body %q flushed=%v", rec.Body, rec.Flushed)
+	}
+}
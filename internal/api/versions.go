@@ -0,0 +1,125 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func registerObjectVersions(r chi.Router) {
+	r.Get("/providers/{id}/buckets/{name}/objects/versions", listObjectVersions)
+	r.With(requireEditorOrAdmin).Post("/providers/{id}/buckets/{name}/objects/restore-version", restoreObjectVersion)
+}
+
+// listObjectVersions returns every version of ?key=, newest first.
+func listObjectVersions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	pid, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || pid <= 0 {
+		respondError(w, r, 400, "invalid provider id")
+		return
+	}
+	bucket, key := chi.URLParam(r, "name"), r.URL.Query().Get("key")
+	if key == "" {
+		respondError(w, r, 400, "key is required")
+		return
+	}
+	if !enforceACL(w, r, pid, bucket, aclRead) {
+		return
+	}
+	c, _, err := getClient(pid)
+	if err != nil {
+		respondError(w, r, 404, "provider not found")
+		return
+	}
+	versions, err := c.ListObjectVersions(r.Context(), bucket, key)
+	if err != nil {
+		code, msg := s3Failure(r, err)
+		respondError(w, r, code, msg, storageErrCode(code, msg))
+		return
+	}
+	RespondList(w, r, 200, versions, len(versions), len(versions), 0)
+}
+
+// restoreObjectVersion makes an older version of an object current again by copying it
+// over the key. Newer versions are kept, so the restore itself can be undone.
+func restoreObjectVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	pid, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || pid <= 0 {
+		respondError(w, r, 400, "invalid provider id")
+		return
+	}
+	bucket := chi.URLParam(r, "name")
+	var in struct {
+		Key       string `json:"key"`
+		VersionID string `json:"versionId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondError(w, r, 400, err.Error())
+		return
+	}
+	if in.Key == "" || in.VersionID == "" {
+		respondError(w, r, 400, "key and versionId are required")
+		return
+	}
+	if !enforceACL(w, r, pid, bucket, aclWrite) {
+		return
+	}
+	c, _, err := getClient(pid)
+	if err != nil {
+		respondError(w, r, 404, "provider not found")
+		return
+	}
+	enabled, err := c.BucketVersioningEnabled(r.Context(), bucket)
+	if err != nil {
+		code, msg := s3Failure(r, err)
+		respondError(w, r, code, msg, storageErrCode(code, msg))
+		return
+	}
+	if !enabled {
+		respondError(w, r, 409, "versioning is not enabled on bucket "+bucket+"; there are no prior versions to restore")
+		return
+	}
+	versions, err := c.ListObjectVersions(r.Context(), bucket, in.Key)
+	if err != nil {
+		code, msg := s3Failure(r, err)
+		respondError(w, r, code, msg, storageErrCode(code, msg))
+		return
+	}
+	found := false
+	for _, v := range versions {
+		if v.VersionID != in.VersionID {
+			continue
+		}
+		found = true
+		if v.IsLatest {
+			respondError(w, r, 400, "version "+in.VersionID+" is already the current version")
+			return
+		}
+		if v.IsDeleteMarker {
+			respondError(w, r, 400, "version "+in.VersionID+" is a delete marker")
+			return
+		}
+	}
+	if !found {
+		respondError(w, r, 404, "version not found")
+		return
+	}
+	newID, err := c.RestoreObjectVersion(r.Context(), bucket, in.Key, in.VersionID)
+	if err != nil {
+		code, msg := s3Failure(r, err)
+		respondError(w, r, code, msg, storageErrCode(code, msg))
+		return
+	}
+	by := ""
+	if u := currentUser(r); u != nil {
+		by = u.Email
+	}
+	addEvent(r, "object.version.restore", map[string]any{"bucket": bucket, "key": in.Key, "restoredFrom": in.VersionID, "newVersionId": newID})
+	RecordAudit(r.Context(), "object.version.restore", "object", bucketResource(uint(pid), bucket)+"/"+in.Key, map[string]any{"restoredFrom": in.VersionID, "newVersionId": newID})
+	apiLogger.Info("object version restored", "component", "object.version", "provider", pid, "bucket", bucket, "key", in.Key, "restoredFrom", in.VersionID, "newVersionId", newID, "user", by)
+	Respond(w, r, 200, map[string]any{"ok": true, "newVersionId": newID, "restoredFrom": in.VersionID})
+}
@@ -0,0 +1,115 @@
+// Package concurrency bounds how many operations run at once.
+package concurrency
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// window is the number of observed latencies each adjustment is based on.
+const window = 10
+
+// AdaptiveLimiter admits up to Limit() concurrent operations and tunes that limit with
+// AIMD from the latencies reported to Observe: after every window of observations the
+// limit grows by one while their P95 is below the target, and is halved when the P95
+// exceeds twice the target.
+type AdaptiveLimiter struct {
+	mu       sync.Mutex
+	limit    int
+	max      int
+	target   time.Duration
+	inFlight int
+	waiters  []chan struct{}
+	samples  []time.Duration
+	p95      time.Duration // of the last full window
+}
+
+// NewAdaptiveLimiter returns a limiter starting at initial concurrency, never above maxLimit.
+func NewAdaptiveLimiter(initial, maxLimit int, target time.Duration) *AdaptiveLimiter {
+	maxLimit = max(maxLimit, 1)
+	initial = min(max(initial, 1), maxLimit)
+	return &AdaptiveLimiter{limit: initial, max: maxLimit, target: target, samples: make([]time.Duration, 0, window)}
+}
+
+// Acquire blocks until the operation may start or ctx is done. Each successful Acquire
+// must be paired with a Release.
+func (l *AdaptiveLimiter) Acquire(ctx context.Context) error {
+	l.mu.Lock()
+	if l.inFlight < l.limit && len(l.waiters) == 0 {
+		l.inFlight++
+		l.mu.Unlock()
+		return nil
+	}
+	ch := make(chan struct{})
+	l.waiters = append(l.waiters, ch)
+	l.mu.Unlock()
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if i := slices.Index(l.waiters, ch); i >= 0 {
+			l.waiters = slices.Delete(l.waiters, i, i+1)
+			return ctx.Err()
+		}
+		// granted while giving up: hand the slot on
+		l.inFlight--
+		l.grant()
+		return ctx.Err()
+	}
+}
+
+// Release ends an operation started with Acquire.
+func (l *AdaptiveLimiter) Release() {
+	l.mu.Lock()
+	l.inFlight--
+	l.grant()
+	l.mu.Unlock()
+}
+
+// grant admits waiters in arrival order while there is room; l.mu must be held.
+func (l *AdaptiveLimiter) grant() {
+	for len(l.waiters) > 0 && l.inFlight < l.limit {
+		close(l.waiters[0])
+		l.waiters = l.waiters[1:]
+		l.inFlight++
+	}
+}
+
+// Observe records the latency of a completed operation.
+func (l *AdaptiveLimiter) Observe(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.samples = append(l.samples, d)
+	if len(l.samples) < window {
+		return
+	}
+	sorted := slices.Sorted(slices.Values(l.samples))
+	l.p95 = sorted[(len(sorted)*95+99)/100-1]
+	l.samples = l.samples[:0]
+	switch {
+	case l.p95 < l.target && l.limit < l.max:
+		l.limit++
+		l.grant()
+	case l.p95 > 2*l.target:
+		l.limit = max(l.limit/2, 1)
+	}
+}
+
+// Stats is a snapshot of the limiter.
+type Stats struct {
+	Limit    int     `json:"limit"`
+	InFlight int     `json:"inFlight"`
+	Waiting  int     `json:"waiting"`
+	P95Ms    float64 `json:"p95LatencyMs"` // of the last window of observations; 0 before the first
+}
+
+// Stats returns the current limit, occupancy and recent P95 latency.
+func (l *AdaptiveLimiter) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return Stats{Limit: l.limit, InFlight: l.inFlight, Waiting: len(l.waiters), P95Ms: float64(l.p95) / float64(time.Millisecond)}
+}
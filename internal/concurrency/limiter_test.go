@@ -0,0 +1,135 @@
+package concurrency
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func observe(l *AdaptiveLimiter, d time.Duration) {
+	for i := 0; i < window; i++ {
+		l.Observe(d)
+	}
+}
+
+func TestAdaptiveLimiterAIMD(t *testing.T) {
+	l := NewAdaptiveLimiter(4, 6, 500*time.Millisecond)
+	observe(l, 100*time.Millisecond)
+	if s := l.Stats(); s.Limit != 5 || s.P95Ms != 100 {
+		t.Fatalf("fast window: expected limit 5 and p95 100ms, got %+v", s)
+	}
+	observe(l, 100*time.Millisecond)
+	observe(l, 100*time.Millisecond)
+	if got := l.Stats().Limit; got != 6 {
+		t.Fatalf("limit must stop at the max, got %d", got)
+	}
+	observe(l, 800*time.Millisecond) // between target and 2*target: hold
+	if got := l.Stats().Limit; got != 6 {
+		t.Fatalf("expected 6 to be held, got %d", got)
+	}
+	observe(l, 2*time.Second)
+	if got := l.Stats().Limit; got != 3 {
+		t.Fatalf("slow window: expected the limit halved to 3, got %d", got)
+	}
+	for i := 0; i < 3; i++ {
+		observe(l, 2*time.Second)
+	}
+	if got := l.Stats().Limit; got != 1 {
+		t.Fatalf("limit must not drop below 1, got %d", got)
+	}
+	// one slow upload in a window is its P95
+	for i := 0; i < window-1; i++ {
+		l.Observe(10 * time.Millisecond)
+	}
+	l.Observe(time.Second)
+	if s := l.Stats(); s.P95Ms != 1000 || s.Limit != 1 {
+		t.Fatalf("expected p95 1000ms, got %+v", s)
+	}
+}
+
+func TestAdaptiveLimiterAcquire(t *testing.T) {
+	l := NewAdaptiveLimiter(1, 2, time.Second)
+	if err := l.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.Acquire(ctx); err == nil {
+		t.Fatal("second acquire must wait for a slot")
+	}
+	done := make(chan struct{})
+	go func() {
+		l.Acquire(context.Background())
+		close(done)
+	}()
+	for l.Stats().Waiting != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	observe(l, time.Millisecond) // raising the limit admits the waiter
+	<-done
+	if s := l.Stats(); s.InFlight != 2 || s.Waiting != 0 {
+		t.Fatalf("expected 2 in flight, got %+v", s)
+	}
+	l.Release()
+	l.Release()
+	if s := l.Stats(); s.InFlight != 0 {
+		t.Fatalf("expected nothing in flight, got %+v", s)
+	}
+}
+
+// link simulates a network path with a few lanes: beyond them transfers share the
+// bandwidth and lose some of it to congestion, so too much concurrency lowers throughput.
+type link struct{ inFlight atomic.Int64 }
+
+const (
+	linkLanes = 4
+	linkBase  = 2 * time.Millisecond
+)
+
+func (k *link) transfer() time.Duration {
+	n := k.inFlight.Add(1)
+	defer k.inFlight.Add(-1)
+	d := linkBase
+	if n > linkLanes {
+		d = time.Duration(float64(linkBase) * float64(n) / linkLanes * (1 + 0.1*float64(n-linkLanes)))
+	}
+	time.Sleep(d)
+	return d
+}
+
+// benchmarkUploads runs b.N simulated uploads from 32 concurrent callers through l and
+// reports the achieved uploads per second.
+func benchmarkUploads(b *testing.B, l *AdaptiveLimiter) {
+	var k link
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for next.Add(1) <= int64(b.N) {
+				l.Acquire(context.Background())
+				l.Observe(k.transfer())
+				l.Release()
+			}
+		}()
+	}
+	wg.Wait()
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "uploads/s")
+	b.ReportMetric(float64(l.Stats().Limit), "limit")
+}
+
+func BenchmarkUploadsFixed16(b *testing.B) {
+	benchmarkUploads(b, NewAdaptiveLimiter(16, 16, time.Hour))
+}
+
+func BenchmarkUploadsFixed1(b *testing.B) {
+	benchmarkUploads(b, NewAdaptiveLimiter(1, 1, time.Hour))
+}
+
+func BenchmarkUploadsAdaptive(b *testing.B) {
+	benchmarkUploads(b, NewAdaptiveLimiter(4, 16, 3*time.Millisecond))
+}
@@ -10,8 +10,67 @@ type Config struct {
 	DBPath              string     // used when DBDriver=sqlite
 	DBDriver            string     // sqlite|postgres
 	DBDsn               string     // used when DBDriver=postgres (e.g., DATABASE_URL)
+	DBReplicaDsn        string     // optional read replica opened with the same driver; empty = reads use primary
+	SQLiteBusyTimeoutMs int64      // how long sqlite waits on a locked DB before returning SQLITE_BUSY
+	SQLiteSyncMode      string     // NORMAL|FULL|OFF (NORMAL is safe under WAL)
 	StaticDir           string
+	TLSCertFile         string     // serve HTTPS with this certificate (requires TLSKeyFile)
+	TLSKeyFile          string     // private key for TLSCertFile
+	CORSOrigins         string     // comma-separated allowed origins (default *)
+	CORSAdminOrigins    string     // origins for user/admin/provider-changing routes; empty = CORSOrigins
+	CORSObsOrigins      string     // origins for /obs/*; empty = CORSOrigins
+	SPAPreloadAssets    string     // comma-separated asset paths pushed/preloaded with index.html (TLS only)
 	MaxUploadSizeBytes  int64      // 0 = unlimited
+	MaxBatchFiles       int64      // files accepted by one multi-file upload request
+	MaxBatchSizeBytes   int64      // total body of a multi-file upload; defaults to MaxUploadSizeBytes, 0 = unlimited
+	MaxBulkDelete       int64      // keys accepted by one bulk delete request
+	BucketForceDeleteMaxObjects int64 // most objects DELETE .../buckets/{name}?force=true removes before refusing
+	AuditLogRetentionDays int64 // days audit entries are kept before the daily prune
+	TrashBucket         string     // bucket (on the same provider) receiving deleted objects; empty = deletes are permanent
+	TrashRetentionDays  int64      // days before trashed objects are purged
+	SessionSecret       string     // HMAC key for session cookies; required when Env=prod
+	SessionTTLHours     int64      // lifetime of a login session and its cookie
+	APIKeysEnabled      bool       // accept "Authorization: Bearer <key>" and serve /auth/keys
+	RateLimitRPS        int64      // requests per second allowed per user or client IP; 0 = unlimited
+	RateLimitBurst      int64      // requests a client may send at once before RateLimitRPS applies
+	TrustedProxies      string     // comma-separated IPs/CIDRs whose X-Forwarded-For is believed; empty = use the peer address
+	MetricsToken        string     // bearer token required by GET /metrics; empty = open
+	MaxPresignExpiryHours int64 // longest lifetime of a presigned URL (SigV4 allows up to 168)
+	ApiTimeoutSec       int64      // deadline for regular API requests; transfers and streams are exempt
+	RequestLogBody      bool       // log redacted request bodies at debug level
+	RequestLogMaxBytes  int64      // bodies larger than this are not logged
+	RequestLogRedact    string     // comma-separated JSON keys masked in logged bodies
+	MultiTenant         bool       // isolate users/providers/buckets/traces/logs by tenant
+	AllowedTenants      string     // comma-separated tenant IDs accepted in X-Hermes-Tenant
+	ObsPushIntervalSec  int64      // seconds between /obs/live frames
+	CopyPipeBufferBytes int64      // chunk size streamed between providers in a cross-provider copy
+	MaxTraceBuffer      int64      // traces kept in the in-memory ring (1..100000)
+	MaxLogBuffer        int64      // log entries kept in the in-memory ring (1..100000)
+	TraceMemoryOnly     bool       // TRACE_PERSIST=false: keep traces in the in-memory ring only, skip the DB
+	OTLPEndpoint        string     // OTLP/gRPC collector; empty = no OpenTelemetry export
+	OTLPMetricsEndpoint string     // overrides OTLPEndpoint for metrics only
+	ProviderAlertAfterFailures int64 // consecutive failed health checks before provider.unreachable is sent
+	ProviderAlertWebhookURL    string // optional URL receiving provider.unreachable/provider.recovered as JSON POSTs
+	IncrementalStats    bool       // adjust cached bucket summaries on upload/delete/move/copy instead of only nightly
+	UploadURLAllowPrivate bool // let upload-url fetch from loopback, private and link-local addresses
+	ShutdownDrainSec    int64      // on SIGTERM, how long in-flight requests and uploads may finish before uploads are aborted
+	AutoMultipartThresholdMB int64 // uploads larger than this switch to parallel multipart; 0 = always single PUT
+	MultipartChunkMB    int64      // part size of automatic multipart uploads (min 5)
+	MultipartWorkers    int64      // parts of one upload sent concurrently
+	SecretsBackend      string     // env|vault: where provider SecretRef values are resolved
+	VaultAddr           string     // Vault server URL when SecretsBackend=vault
+	VaultToken          string     // Vault token when SecretsBackend=vault
+	VaultKVMount        string     // mount of the KV v2 engine holding provider secrets
+	S3ClientCacheSize   int64      // provider clients kept for reuse; the least recently used is evicted
+	ExportMaxRows       int64      // rows streamed by one /admin/export request before it is truncated
+	ProviderValidateOnCreate bool  // test provider credentials with ListBuckets before create/update saves them
+	S3IdleConnTimeoutSec     int64 // idle pooled S3 connections are closed after this
+	S3MaxIdleConns           int64 // pooled idle connections per provider client
+	S3DialTimeoutSec         int64 // TCP connect timeout to S3 endpoints
+	S3TLSHandshakeTimeoutSec int64 // TLS handshake timeout to S3 endpoints
+	UploadConcurrencyInit    int64 // concurrent uploads admitted at start; adjusted by observed latency
+	UploadConcurrencyMax     int64 // upper bound of the adaptive upload concurrency
+	UploadLatencyTargetMs    int64 // P95 upload latency below which concurrency grows; above twice it, it is halved
 }
 
 func Load() *Config {
@@ -21,9 +80,68 @@ func Load() *Config {
 		DBPath:    getEnv("DB_PATH", "data/hermes.db"),
 		DBDriver:  getEnv("DB_DRIVER", "sqlite"),
 		DBDsn:     getEnv("DATABASE_URL", getEnv("DB_DSN", "")),
+		DBReplicaDsn: getEnv("DB_REPLICA_DSN", ""),
+		SQLiteBusyTimeoutMs: getEnvInt64("SQLITE_BUSY_TIMEOUT_MS", 5000),
+		SQLiteSyncMode: getEnv("SQLITE_SYNC_MODE", "NORMAL"),
 		StaticDir: getEnv("STATIC_DIR", "web/dist"),
+		TLSCertFile: getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile: getEnv("TLS_KEY_FILE", ""),
+		CORSOrigins: getEnv("CORS_ORIGINS", "*"),
+		CORSAdminOrigins: getEnv("CORS_ADMIN_ORIGINS", ""),
+		CORSObsOrigins: getEnv("CORS_OBS_ORIGINS", ""),
+		SPAPreloadAssets: getEnv("SPA_PRELOAD_ASSETS", ""),
 		MaxUploadSizeBytes: getEnvInt64("MAX_UPLOAD_SIZE_BYTES", 0),
+		TrashBucket: getEnv("TRASH_BUCKET", ""),
+		TrashRetentionDays: getEnvInt64("TRASH_RETENTION_DAYS", 30),
+		SessionSecret: getEnv("SESSION_SECRET", ""),
+		ApiTimeoutSec: getEnvInt64("TIMEOUT_API_SEC", 30),
+		RequestLogBody: getEnv("REQUEST_LOG_BODY", "false") == "true",
+		RequestLogMaxBytes: getEnvInt64("REQUEST_LOG_MAX_BODY_BYTES", 4096),
+		RequestLogRedact: getEnv("REQUEST_LOG_REDACT_FIELDS", "password,secret,token,apikey"),
+		MultiTenant: getEnv("MULTI_TENANT", "false") == "true",
+		AllowedTenants: getEnv("ALLOWED_TENANTS", ""),
+		ObsPushIntervalSec: getEnvInt64("OBS_PUSH_INTERVAL_SEC", 5),
+		CopyPipeBufferBytes: getEnvInt64("COPY_PIPE_BUFFER_BYTES", 32*1024),
+		MaxTraceBuffer: getEnvInt64("MAX_TRACE_BUFFER", 1000),
+		MaxLogBuffer: getEnvInt64("MAX_LOG_BUFFER", 1000),
+		TraceMemoryOnly: getEnv("TRACE_PERSIST", "true") == "false",
+		OTLPEndpoint: getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+		OTLPMetricsEndpoint: getEnv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT", ""),
+		ProviderAlertAfterFailures: getEnvInt64("PROVIDER_ALERT_AFTER_FAILURES", 3),
+		ProviderAlertWebhookURL: getEnv("PROVIDER_ALERT_WEBHOOK_URL", ""),
+		IncrementalStats: getEnv("INCREMENTAL_STATS", "false") == "true",
+		UploadURLAllowPrivate: getEnv("UPLOAD_URL_ALLOW_PRIVATE", "false") == "true",
+		ShutdownDrainSec: getEnvInt64("SHUTDOWN_DRAIN_SEC", 30),
+		AutoMultipartThresholdMB: getEnvInt64("AUTO_MULTIPART_THRESHOLD_MB", 100),
+		MultipartChunkMB: getEnvInt64("MULTIPART_CHUNK_MB", 64),
+		MultipartWorkers: getEnvInt64("MULTIPART_WORKERS", 3),
+		SecretsBackend: getEnv("SECRETS_BACKEND", "env"),
+		VaultAddr: getEnv("VAULT_ADDR", ""),
+		VaultToken: getEnv("VAULT_TOKEN", ""),
+		VaultKVMount: getEnv("VAULT_KV_MOUNT", "secret"),
 	}
+	cfg.MaxBatchFiles = getEnvInt64("MAX_BATCH_FILES", 50)
+	cfg.MaxBulkDelete = getEnvInt64("MAX_BULK_DELETE", 1000)
+	cfg.BucketForceDeleteMaxObjects = getEnvInt64("BUCKET_FORCE_DELETE_MAX_OBJECTS", 100000)
+	cfg.AuditLogRetentionDays = getEnvInt64("AUDIT_LOG_RETENTION_DAYS", 90)
+	cfg.MaxBatchSizeBytes = getEnvInt64("MAX_BATCH_SIZE_BYTES", cfg.MaxUploadSizeBytes)
+	cfg.S3ClientCacheSize = getEnvInt64("S3_CLIENT_CACHE_SIZE", 50)
+	cfg.ExportMaxRows = getEnvInt64("EXPORT_MAX_ROWS", 500000)
+	cfg.ProviderValidateOnCreate = getEnv("PROVIDER_VALIDATE_ON_CREATE", "false") == "true"
+	cfg.S3IdleConnTimeoutSec = getEnvInt64("S3_IDLE_CONN_TIMEOUT_SEC", 30)
+	cfg.S3MaxIdleConns = getEnvInt64("S3_MAX_IDLE_CONNS", 100)
+	cfg.S3DialTimeoutSec = getEnvInt64("S3_DIAL_TIMEOUT_SEC", 5)
+	cfg.S3TLSHandshakeTimeoutSec = getEnvInt64("S3_TLS_HANDSHAKE_TIMEOUT_SEC", 5)
+	cfg.UploadConcurrencyInit = getEnvInt64("UPLOAD_CONCURRENCY_INIT", 4)
+	cfg.UploadConcurrencyMax = getEnvInt64("UPLOAD_CONCURRENCY_MAX", 16)
+	cfg.UploadLatencyTargetMs = getEnvInt64("UPLOAD_LATENCY_TARGET_MS", 500)
+	cfg.SessionTTLHours = getEnvInt64("SESSION_TTL_HOURS", 24)
+	cfg.APIKeysEnabled = getEnv("API_KEYS_ENABLED", "false") == "true"
+	cfg.RateLimitRPS = getEnvInt64("RATE_LIMIT_RPS", 60)
+	cfg.RateLimitBurst = getEnvInt64("RATE_LIMIT_BURST", 20)
+	cfg.TrustedProxies = getEnv("TRUSTED_PROXIES", "")
+	cfg.MetricsToken = getEnv("METRICS_TOKEN", "")
+	cfg.MaxPresignExpiryHours = getEnvInt64("MAX_PRESIGN_EXPIRY_HOURS", 24)
 	return cfg
 }
 
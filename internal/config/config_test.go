@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -37,3 +39,62 @@ func TestLoadEnvOverride(t *testing.T){
 	if cfg.DBDsn == "" { t.Fatalf("DATABASE_URL should be set") }
 	if cfg.StaticDir != "/srv/www" { t.Fatalf("static dir override failed") }
 }
+
+func TestValidate(t *testing.T){
+	static := t.TempDir()
+	os.WriteFile(filepath.Join(static, "index.html"), []byte("ok"), 0o644)
+	empty := t.TempDir()
+	valid := func() *Config { return &Config{HttpPort: "8080", DBDriver: "sqlite", StaticDir: static, MaxTraceBuffer: 1000, MaxLogBuffer: 1000} }
+	cases := []struct{
+		name string
+		mod  func(*Config)
+		want string // substring of the single expected error; "" = valid
+	}{
+		{"valid", func(c *Config){}, ""},
+		{"port not a number", func(c *Config){ c.HttpPort = "http" }, "HTTP_PORT"},
+		{"port out of range", func(c *Config){ c.HttpPort = "70000" }, "HTTP_PORT"},
+		{"unknown driver", func(c *Config){ c.DBDriver = "mysql" }, "DB_DRIVER"},
+		{"postgres without dsn", func(c *Config){ c.DBDriver = "postgres" }, "DATABASE_URL"},
+		{"postgres with dsn", func(c *Config){ c.DBDriver = "postgres"; c.DBDsn = "postgres://u:p@h/db" }, ""},
+		{"missing static dir", func(c *Config){ c.StaticDir = filepath.Join(static, "nope") }, "not a directory"},
+		{"static dir without index", func(c *Config){ c.StaticDir = empty }, "index.html"},
+		{"cert without key", func(c *Config){ c.TLSCertFile = "cert.pem" }, "TLS_CERT_FILE"},
+		{"key without cert", func(c *Config){ c.TLSKeyFile = "key.pem" }, "TLS_CERT_FILE"},
+		{"cert and key", func(c *Config){ c.TLSCertFile = "cert.pem"; c.TLSKeyFile = "key.pem" }, ""},
+		{"negative upload size", func(c *Config){ c.MaxUploadSizeBytes = -1 }, "MAX_UPLOAD_SIZE_BYTES"},
+		{"empty trace buffer", func(c *Config){ c.MaxTraceBuffer = 0 }, "MAX_TRACE_BUFFER"},
+		{"huge log buffer", func(c *Config){ c.MaxLogBuffer = MaxRingBuffer + 1 }, "MAX_LOG_BUFFER"},
+		{"largest buffers", func(c *Config){ c.MaxTraceBuffer = MaxRingBuffer; c.MaxLogBuffer = MaxRingBuffer }, ""},
+		{"multipart chunk too small", func(c *Config){ c.AutoMultipartThresholdMB = 100; c.MultipartChunkMB = 1; c.MultipartWorkers = 3 }, "MULTIPART_CHUNK_MB"},
+		{"multipart without workers", func(c *Config){ c.AutoMultipartThresholdMB = 100; c.MultipartChunkMB = 64 }, "MULTIPART_WORKERS"},
+		{"upload concurrency above max", func(c *Config){ c.UploadConcurrencyInit = 8; c.UploadConcurrencyMax = 4 }, "UPLOAD_CONCURRENCY_INIT"},
+		{"negative rate limit", func(c *Config){ c.RateLimitBurst = -1 }, "RATE_LIMIT_BURST"},
+		{"bad trusted proxy", func(c *Config){ c.TrustedProxies = "10.0.0.0/8, proxy.local" }, "TRUSTED_PROXIES"},
+		{"trusted proxies", func(c *Config){ c.TrustedProxies = "10.0.0.0/8, 192.0.2.1,::1" }, ""},
+		{"negative bulk delete limit", func(c *Config){ c.MaxBulkDelete = -1 }, "MAX_BULK_DELETE"},
+		{"negative force delete limit", func(c *Config){ c.BucketForceDeleteMaxObjects = -1 }, "BUCKET_FORCE_DELETE_MAX_OBJECTS"},
+		{"negative audit retention", func(c *Config){ c.AuditLogRetentionDays = -1 }, "AUDIT_LOG_RETENTION_DAYS"},
+		{"presign expiry beyond a week", func(c *Config){ c.MaxPresignExpiryHours = 169 }, "MAX_PRESIGN_EXPIRY_HOURS"},
+		{"negative session ttl", func(c *Config){ c.SessionTTLHours = -1 }, "SESSION_TTL_HOURS"},
+		{"unknown secrets backend", func(c *Config){ c.SecretsBackend = "aws" }, "SECRETS_BACKEND"},
+		{"vault without token", func(c *Config){ c.SecretsBackend = "vault"; c.VaultAddr = "https://vault:8200" }, "VAULT_TOKEN"},
+		{"vault", func(c *Config){ c.SecretsBackend = "vault"; c.VaultAddr = "https://vault:8200"; c.VaultToken = "t" }, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T){
+			cfg := valid()
+			tc.mod(cfg)
+			errs := Validate(cfg)
+			if tc.want == "" {
+				if len(errs) != 0 { t.Fatalf("expected valid, got %v", errs) }
+				return
+			}
+			if len(errs) != 1 || !strings.Contains(errs[0].Error(), tc.want) { t.Fatalf("expected one error about %s, got %v", tc.want, errs) }
+		})
+	}
+}
+
+func TestValidateReportsAllErrors(t *testing.T){
+	errs := Validate(&Config{HttpPort: "x", DBDriver: "x", StaticDir: "", MaxUploadSizeBytes: -5, MaxTraceBuffer: 1000, MaxLogBuffer: 1000})
+	if len(errs) != 4 { t.Fatalf("expected 4 errors, got %d: %v", len(errs), errs) }
+}
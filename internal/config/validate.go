@@ -0,0 +1,103 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MaxRingBuffer caps MAX_TRACE_BUFFER and MAX_LOG_BUFFER.
+const MaxRingBuffer = 100000
+
+// Validate checks cfg for settings that would make the server fail later or behave
+// unexpectedly, and returns every problem found rather than stopping at the first.
+func Validate(cfg *Config) []error {
+	var errs []error
+	if p, err := strconv.Atoi(cfg.HttpPort); err != nil || p < 1 || p > 65535 {
+		errs = append(errs, fmt.Errorf("HTTP_PORT %q is not a valid port number", cfg.HttpPort))
+	}
+	switch cfg.DBDriver {
+	case "sqlite":
+	case "postgres":
+		if cfg.DBDsn == "" {
+			errs = append(errs, errors.New("DATABASE_URL (or DB_DSN) is required when DB_DRIVER=postgres"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("DB_DRIVER %q must be sqlite or postgres", cfg.DBDriver))
+	}
+	if info, err := os.Stat(cfg.StaticDir); err != nil || !info.IsDir() {
+		errs = append(errs, fmt.Errorf("STATIC_DIR %q is not a directory", cfg.StaticDir))
+	} else if _, err := os.Stat(filepath.Join(cfg.StaticDir, "index.html")); err != nil {
+		errs = append(errs, fmt.Errorf("STATIC_DIR %q does not contain index.html", cfg.StaticDir))
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		errs = append(errs, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together"))
+	}
+	if cfg.MaxUploadSizeBytes < 0 {
+		errs = append(errs, fmt.Errorf("MAX_UPLOAD_SIZE_BYTES %d must not be negative", cfg.MaxUploadSizeBytes))
+	}
+	for _, b := range []struct {
+		name string
+		val  int64
+	}{{"MAX_TRACE_BUFFER", cfg.MaxTraceBuffer}, {"MAX_LOG_BUFFER", cfg.MaxLogBuffer}} {
+		if b.val < 1 || b.val > MaxRingBuffer {
+			errs = append(errs, fmt.Errorf("%s %d must be between 1 and %d", b.name, b.val, MaxRingBuffer))
+		}
+	}
+	if cfg.AutoMultipartThresholdMB > 0 {
+		if cfg.MultipartChunkMB < 5 {
+			errs = append(errs, fmt.Errorf("MULTIPART_CHUNK_MB %d must be at least 5 (the S3 minimum part size)", cfg.MultipartChunkMB))
+		}
+		if cfg.MultipartWorkers < 1 {
+			errs = append(errs, fmt.Errorf("MULTIPART_WORKERS %d must be at least 1", cfg.MultipartWorkers))
+		}
+	}
+	if cfg.S3ClientCacheSize < 0 {
+		errs = append(errs, fmt.Errorf("S3_CLIENT_CACHE_SIZE %d must not be negative", cfg.S3ClientCacheSize))
+	}
+	if cfg.UploadConcurrencyInit < 0 || cfg.UploadConcurrencyMax < 0 || cfg.UploadLatencyTargetMs < 0 {
+		errs = append(errs, errors.New("UPLOAD_CONCURRENCY_INIT, UPLOAD_CONCURRENCY_MAX and UPLOAD_LATENCY_TARGET_MS must not be negative"))
+	} else if cfg.UploadConcurrencyMax > 0 && cfg.UploadConcurrencyInit > cfg.UploadConcurrencyMax {
+		errs = append(errs, fmt.Errorf("UPLOAD_CONCURRENCY_INIT %d must not exceed UPLOAD_CONCURRENCY_MAX %d", cfg.UploadConcurrencyInit, cfg.UploadConcurrencyMax))
+	}
+	if cfg.RateLimitRPS < 0 || cfg.RateLimitBurst < 0 {
+		errs = append(errs, errors.New("RATE_LIMIT_RPS and RATE_LIMIT_BURST must not be negative"))
+	}
+	for _, p := range strings.Split(cfg.TrustedProxies, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(p); err != nil && net.ParseIP(p) == nil {
+			errs = append(errs, fmt.Errorf("TRUSTED_PROXIES entry %q is neither an IP nor a CIDR", p))
+		}
+	}
+	if cfg.MaxBulkDelete < 0 {
+		errs = append(errs, fmt.Errorf("MAX_BULK_DELETE %d must not be negative", cfg.MaxBulkDelete))
+	}
+	if cfg.BucketForceDeleteMaxObjects < 0 {
+		errs = append(errs, fmt.Errorf("BUCKET_FORCE_DELETE_MAX_OBJECTS %d must not be negative", cfg.BucketForceDeleteMaxObjects))
+	}
+	if cfg.AuditLogRetentionDays < 0 {
+		errs = append(errs, fmt.Errorf("AUDIT_LOG_RETENTION_DAYS %d must not be negative", cfg.AuditLogRetentionDays))
+	}
+	if cfg.MaxPresignExpiryHours < 0 || cfg.MaxPresignExpiryHours > 168 {
+		errs = append(errs, fmt.Errorf("MAX_PRESIGN_EXPIRY_HOURS %d must be between 0 and 168", cfg.MaxPresignExpiryHours))
+	}
+	if cfg.SessionTTLHours < 0 {
+		errs = append(errs, fmt.Errorf("SESSION_TTL_HOURS %d must not be negative", cfg.SessionTTLHours))
+	}
+	switch cfg.SecretsBackend {
+	case "", "env":
+	case "vault":
+		if cfg.VaultAddr == "" || cfg.VaultToken == "" {
+			errs = append(errs, errors.New("VAULT_ADDR and VAULT_TOKEN are required when SECRETS_BACKEND=vault"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("SECRETS_BACKEND %q must be env or vault", cfg.SecretsBackend))
+	}
+	return errs
+}
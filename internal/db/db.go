@@ -6,12 +6,14 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/arencloud/hermes/internal/config"
 	"github.com/arencloud/hermes/internal/logging"
 	"github.com/arencloud/hermes/internal/models"
+	"github.com/arencloud/hermes/internal/s3"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/postgres"
@@ -22,6 +24,18 @@ import (
 
 var DB *gorm.DB
 
+// replicaDB is an optional read replica; nil when DB_REPLICA_DSN is not set.
+var replicaDB *gorm.DB
+
+// ReadDB returns the connection to use for read-only queries: the replica when
+// configured, otherwise the primary. Writes must always go through DB.
+func ReadDB() *gorm.DB {
+	if replicaDB != nil {
+		return replicaDB
+	}
+	return DB
+}
+
 func Init(cfg *config.Config, logger logging.Logger) error {
 	// Configure GORM to use our structured logger so SQL logs are not plain text
 	var gormLevel gormlogger.LogLevel
@@ -49,18 +63,36 @@ func Init(cfg *config.Config, logger logging.Logger) error {
 		if err := os.MkdirAll(filepath.Dir(cfg.DBPath), 0o755); err != nil {
 			return err
 		}
-		dialector = sqlite.Open(cfg.DBPath)
-		logger.Info("db connect", "driver", "sqlite", "path", cfg.DBPath)
+		dialector = sqlite.Open(sqliteDSN(cfg.DBPath, cfg))
+		logger.Info("db connect", "driver", "sqlite", "path", cfg.DBPath, "journalMode", "WAL", "syncMode", sqliteSyncMode(cfg.SQLiteSyncMode))
 	}
 
 	gdb, err := gorm.Open(dialector, &gorm.Config{Logger: gormLogger})
 	if err != nil {
 		return err
 	}
-	if err := gdb.AutoMigrate(&models.User{}, &models.Provider{}, &models.Bucket{}, &models.AuthConfig{}, &models.LogEntry{}, &models.TraceRow{}, &models.TraceEventRow{}); err != nil {
+	if err := Migrate(gdb); err != nil {
 		return err
 	}
+	migrateLogSearch(gdb, driver == "postgres" || driver == "postgresql", logger)
+	migrateLogTraceIndex(gdb, driver == "postgres" || driver == "postgresql", logger)
 	DB = gdb
+	replicaDB = nil
+	if cfg.DBReplicaDsn != "" {
+		var rd gorm.Dialector
+		if driver == "postgres" || driver == "postgresql" {
+			rd = postgres.Open(cfg.DBReplicaDsn)
+		} else {
+			rd = sqlite.Open(sqliteDSN(cfg.DBReplicaDsn, cfg))
+		}
+		// the replica is not migrated: it is expected to follow the primary's schema
+		rdb, err := gorm.Open(rd, &gorm.Config{Logger: gormLogger})
+		if err != nil {
+			return err
+		}
+		replicaDB = rdb
+		logger.Info("db replica connect", "driver", driver)
+	}
 	// Hook logging persistence into DB (non-blocking)
 	logging.SetPersist(func(e any) error {
 		// accept logging.entry via json marshal/unmarshal path
@@ -76,7 +108,10 @@ func Init(cfg *config.Config, logger logging.Logger) error {
 		}
 		fieldsBytes, _ := json.Marshal(tmp.Fields)
 		le := models.LogEntry{Time: tmp.Time, Level: tmp.Level, Msg: tmp.Msg, Fields: string(fieldsBytes)}
-		return DB.Create(&le).Error
+		if t, ok := tmp.Fields["tenant"].(string); ok {
+			le.TenantID = t
+		}
+		return gdb.Create(&le).Error
 	})
 	// Ensure there is exactly one auth config row
 	var ac models.AuthConfig
@@ -93,7 +128,11 @@ func Init(cfg *config.Config, logger logging.Logger) error {
 			// hex-encode to readable
 			tmpPass := hex.EncodeToString(tmp)
 			hash, _ := bcrypt.GenerateFromPassword([]byte(tmpPass), bcrypt.DefaultCost)
-			admin := models.User{Email: "admin@local", Password: string(hash), Role: "admin", MustChangePassword: true}
+			role := "admin"
+			if cfg.MultiTenant {
+				role = "superadmin" // the bootstrap admin must be able to set up every tenant
+			}
+			admin := models.User{Email: "admin@local", Password: string(hash), Role: role, MustChangePassword: true}
 			if err := DB.Create(&admin).Error; err == nil {
 				logger.Info("default admin created", "email", admin.Email, "tempPassword", tmpPass)
 			} else {
@@ -101,5 +140,33 @@ func Init(cfg *config.Config, logger logging.Logger) error {
 			}
 		}
 	}
+	// Finish object moves a previous run stopped between the copy and the source delete
+	if err := ResumeMoves(DB, time.Now(), s3.NewFromProvider, logger); err != nil {
+		logger.Error("db_warn", "msg", "move journal recovery failed", "error", err.Error())
+	}
 	return nil
 }
+
+// sqliteDSN appends the WAL, busy_timeout and synchronous pragmas to the sqlite path.
+// They are passed as DSN parameters rather than executed once because busy_timeout and
+// synchronous are per-connection settings and database/sql opens several connections.
+func sqliteDSN(path string, cfg *config.Config) string {
+	busy := cfg.SQLiteBusyTimeoutMs
+	if busy <= 0 {
+		busy = 5000
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + "_journal_mode=WAL&_busy_timeout=" + strconv.FormatInt(busy, 10) + "&_synchronous=" + sqliteSyncMode(cfg.SQLiteSyncMode)
+}
+
+func sqliteSyncMode(mode string) string {
+	switch m := strings.ToUpper(strings.TrimSpace(mode)); m {
+	case "NORMAL", "FULL", "OFF":
+		return m
+	default:
+		return "NORMAL"
+	}
+}
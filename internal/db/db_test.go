@@ -0,0 +1,59 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arencloud/hermes/internal/config"
+	"github.com/arencloud/hermes/internal/logging"
+	"github.com/arencloud/hermes/internal/models"
+)
+
+func TestSQLiteDSN(t *testing.T) {
+	cfg := &config.Config{SQLiteBusyTimeoutMs: 250, SQLiteSyncMode: "full"}
+	if got := sqliteDSN("data/h.db", cfg); got != "data/h.db?_journal_mode=WAL&_busy_timeout=250&_synchronous=FULL" {
+		t.Fatalf("unexpected dsn %q", got)
+	}
+	if got := sqliteDSN("file:h.db?cache=shared", &config.Config{SQLiteSyncMode: "bogus"}); got != "file:h.db?cache=shared&_journal_mode=WAL&_busy_timeout=5000&_synchronous=NORMAL" {
+		t.Fatalf("unexpected dsn %q", got)
+	}
+}
+
+func TestSQLiteConcurrentWrites(t *testing.T) {
+	cfg := &config.Config{DBDriver: "sqlite", DBPath: filepath.Join(t.TempDir(), "concurrent.db"), SQLiteBusyTimeoutMs: 5000, SQLiteSyncMode: "NORMAL"}
+	if err := Init(cfg, logging.New("test")); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	var mode string
+	DB.Raw("PRAGMA journal_mode").Scan(&mode)
+	if mode != "wal" {
+		t.Fatalf("expected wal journal mode, got %q", mode)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 20*100)
+	for g := 0; g < 20; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				row := models.TraceRow{ID: fmt.Sprintf("g%02d-%03d", g, i), Method: "GET", Path: "/x", Status: 200, Started: time.Now()}
+				if err := DB.Create(&row).Error; err != nil {
+					errs <- err
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("concurrent write failed: %v", err)
+	}
+	var n int64
+	DB.Model(&models.TraceRow{}).Count(&n)
+	if n != 2000 {
+		t.Fatalf("expected 2000 rows, got %d", n)
+	}
+}
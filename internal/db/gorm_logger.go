@@ -79,7 +79,8 @@ func (g *gormJSONLogger) Trace(ctx context.Context, begin time.Time, fc func() (
 		g.l.Error("gorm_sql", fields...)
 		return
 	}
-	if g.level >= logger.Info {
+	// a gorm_sql=debug component override enables SQL traces regardless of the gorm level
+	if g.level >= logger.Info || logging.Enabled("gorm_sql", "debug") {
 		g.l.Debug("gorm_sql", fields...)
 	}
 }
@@ -0,0 +1,176 @@
+package db
+
+import (
+	"strings"
+	"time"
+
+	"github.com/arencloud/hermes/internal/logging"
+	"github.com/arencloud/hermes/internal/models"
+	"gorm.io/gorm"
+)
+
+// Log search strategies, chosen once per Init.
+const (
+	logSearchLike     = "like"     // msg LIKE '%q%' (full scan)
+	logSearchFTS5     = "fts5"     // sqlite log_entries_fts; needs the sqlite_fts5 build tag
+	logSearchTSVector = "tsvector" // postgres msg_tsv generated column with a GIN index
+)
+
+var logSearch = logSearchLike
+
+// logTraceID is the SQL expression for a log entry's traceId field: the indexed trace_id
+// generated column, or the raw JSON lookup if the column could not be added.
+var logTraceID = "trace_id"
+
+// migrateLogSearch creates the full-text index for log messages. On sqlite this is an
+// external-content FTS5 table kept in sync by triggers and back-filled when first
+// created; on postgres a generated tsvector column (back-filled by postgres itself).
+// If the index cannot be created, search falls back to LIKE.
+func migrateLogSearch(gdb *gorm.DB, postgres bool, logger logging.Logger) {
+	logSearch = logSearchLike
+	if postgres {
+		err := gdb.Exec(`ALTER TABLE log_entries ADD COLUMN IF NOT EXISTS msg_tsv tsvector GENERATED ALWAYS AS (to_tsvector('simple', coalesce(msg, ''))) STORED`).Error
+		if err == nil {
+			err = gdb.Exec(`CREATE INDEX IF NOT EXISTS idx_log_entries_msg_tsv ON log_entries USING GIN (msg_tsv)`).Error
+		}
+		if err != nil {
+			logger.Error("db_warn", "msg", "log full-text index unavailable, search uses LIKE", "error", err.Error())
+			return
+		}
+		logSearch = logSearchTSVector
+		return
+	}
+	var existing int64
+	gdb.Raw(`SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'log_entries_fts'`).Scan(&existing)
+	err := gdb.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range []string{
+			`CREATE VIRTUAL TABLE IF NOT EXISTS log_entries_fts USING fts5(msg, fields, content='log_entries', content_rowid='id')`,
+			`CREATE TRIGGER IF NOT EXISTS log_entries_fts_ai AFTER INSERT ON log_entries BEGIN
+				INSERT INTO log_entries_fts(rowid, msg, fields) VALUES (new.id, new.msg, new.fields);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS log_entries_fts_ad AFTER DELETE ON log_entries BEGIN
+				INSERT INTO log_entries_fts(log_entries_fts, rowid, msg, fields) VALUES ('delete', old.id, old.msg, old.fields);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS log_entries_fts_au AFTER UPDATE ON log_entries BEGIN
+				INSERT INTO log_entries_fts(log_entries_fts, rowid, msg, fields) VALUES ('delete', old.id, old.msg, old.fields);
+				INSERT INTO log_entries_fts(rowid, msg, fields) VALUES (new.id, new.msg, new.fields);
+			END`,
+		} {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		if existing == 0 {
+			// back-fill rows written before the index existed
+			return tx.Exec(`INSERT INTO log_entries_fts(log_entries_fts) VALUES ('rebuild')`).Error
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("db_warn", "msg", "log full-text index unavailable, search uses LIKE", "error", err.Error())
+		return
+	}
+	logSearch = logSearchFTS5
+}
+
+// migrateLogTraceIndex adds trace_id, a generated column holding the traceId of the
+// entry's fields, with an index so the logs of one request can be found without a scan.
+func migrateLogTraceIndex(gdb *gorm.DB, postgres bool, logger logging.Logger) {
+	var stmts []string
+	if postgres {
+		logTraceID = "(fields::jsonb ->> 'traceId')"
+		stmts = []string{
+			`ALTER TABLE log_entries ADD COLUMN IF NOT EXISTS trace_id text GENERATED ALWAYS AS (CASE WHEN left(fields, 1) = '{' THEN fields::jsonb ->> 'traceId' END) STORED`,
+			`CREATE INDEX IF NOT EXISTS idx_log_entries_trace_id ON log_entries (trace_id)`,
+		}
+	} else {
+		logTraceID = "json_extract(fields, '$.traceId')"
+		var existing int64
+		// generated columns are listed by table_xinfo only
+		gdb.Raw(`SELECT count(*) FROM pragma_table_xinfo('log_entries') WHERE name = 'trace_id'`).Scan(&existing)
+		if existing == 0 {
+			stmts = append(stmts, `ALTER TABLE log_entries ADD COLUMN trace_id TEXT GENERATED ALWAYS AS (CASE WHEN json_valid(fields) THEN json_extract(fields, '$.traceId') END) VIRTUAL`)
+		}
+		stmts = append(stmts, `CREATE INDEX IF NOT EXISTS idx_log_entries_trace_id ON log_entries (trace_id)`)
+	}
+	for _, stmt := range stmts {
+		if err := gdb.Exec(stmt).Error; err != nil {
+			logger.Error("db_warn", "msg", "log trace_id index unavailable, trace lookups scan the JSON fields", "error", err.Error())
+			return
+		}
+	}
+	logTraceID = "trace_id"
+}
+
+// ftsQuery turns free text into an FTS5 query matching all of its words, quoting each
+// so that FTS operators and punctuation in user input are taken literally.
+func ftsQuery(q string) string {
+	words := strings.Fields(q)
+	for i, w := range words {
+		words[i] = `"` + strings.ReplaceAll(w, `"`, `""`) + `"`
+	}
+	return strings.Join(words, " ")
+}
+
+// SearchLogs returns log entries newest-first with the total number of matches. query is
+// matched against the message (and, with FTS5, the fields); level, from and to are
+// ignored when empty or zero. scopes narrow the search further, e.g. to a tenant.
+func SearchLogs(query string, level string, from, to time.Time, limit, offset int, scopes ...func(*gorm.DB) *gorm.DB) ([]models.LogEntry, int64, error) {
+	return SearchLogsIn(ReadDB(), query, level, from, to, limit, offset, scopes...)
+}
+
+// SearchLogsIn is SearchLogs against a specific connection, e.g. the primary.
+func SearchLogsIn(gdb *gorm.DB, query string, level string, from, to time.Time, limit, offset int, scopes ...func(*gorm.DB) *gorm.DB) ([]models.LogEntry, int64, error) {
+	q := gdb.Model(&models.LogEntry{}).Scopes(scopes...)
+	if level != "" {
+		q = q.Where("level = ?", level)
+	}
+	if !from.IsZero() {
+		q = q.Where("time >= ?", from)
+	}
+	if !to.IsZero() {
+		q = q.Where("time <= ?", to)
+	}
+	if query = strings.TrimSpace(query); query != "" {
+		switch logSearch {
+		case logSearchFTS5:
+			q = q.Where("id IN (SELECT rowid FROM log_entries_fts WHERE log_entries_fts MATCH ?)", ftsQuery(query))
+		case logSearchTSVector:
+			q = q.Where("msg_tsv @@ plainto_tsquery('simple', ?)", query)
+		default:
+			q = q.Where("msg LIKE ?", "%"+query+"%")
+		}
+	}
+	q = q.Session(&gorm.Session{}) // reusable for both the count and the page
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var rows []models.LogEntry
+	if err := q.Order("time desc").Limit(limit).Offset(offset).Find(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+	return rows, total, nil
+}
+
+// LogsForTrace returns the log entries carrying traceId in their fields, oldest first,
+// with the total number of them.
+func LogsForTrace(gdb *gorm.DB, traceID string, limit, offset int, scopes ...func(*gorm.DB) *gorm.DB) ([]models.LogEntry, int64, error) {
+	q := gdb.Model(&models.LogEntry{}).Scopes(scopes...).Where(logTraceID+" = ?", traceID).Session(&gorm.Session{})
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var rows []models.LogEntry
+	if err := q.Order("time asc").Order("id asc").Limit(limit).Offset(offset).Find(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+	return rows, total, nil
+}
+
+// CountLogsForTrace returns how many log entries carry traceId in their fields.
+func CountLogsForTrace(gdb *gorm.DB, traceID string, scopes ...func(*gorm.DB) *gorm.DB) (int64, error) {
+	var n int64
+	err := gdb.Model(&models.LogEntry{}).Scopes(scopes...).Where(logTraceID+" = ?", traceID).Count(&n).Error
+	return n, err
+}
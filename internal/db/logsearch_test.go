@@ -0,0 +1,127 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arencloud/hermes/internal/config"
+	"github.com/arencloud/hermes/internal/logging"
+	"github.com/arencloud/hermes/internal/models"
+)
+
+func initLogDB(tb testing.TB) {
+	tb.Helper()
+	cfg := &config.Config{DBDriver: "sqlite", DBPath: filepath.Join(tb.TempDir(), "logs.db")}
+	if err := Init(cfg, logging.New("test")); err != nil {
+		tb.Fatalf("init: %v", err)
+	}
+	logging.SetPersist(nil) // keep Init's own log lines out of the table
+	logging.Flush()
+	DB.Where("1 = 1").Delete(&models.LogEntry{})
+}
+
+func TestFTSQuery(t *testing.T) {
+	if got := ftsQuery(`  upload failed  "x" OR`); got != `"upload" "failed" """x""" "OR"` {
+		t.Fatalf("unexpected query %s", got)
+	}
+}
+
+func TestSearchLogs(t *testing.T) {
+	initLogDB(t)
+	t.Logf("log search strategy: %s", logSearch)
+	base := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	rows := []models.LogEntry{
+		{Time: base, Level: "info", Msg: "upload started", Fields: `{"bucket":"a"}`},
+		{Time: base.Add(time.Minute), Level: "error", Msg: "upload failed", Fields: `{"bucket":"a"}`},
+		{Time: base.Add(2 * time.Minute), Level: "info", Msg: "download finished", Fields: `{}`},
+		{Time: base.Add(3 * time.Minute), Level: "error", Msg: "upload failed", Fields: `{"bucket":"b"}`},
+	}
+	if err := DB.Create(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	got, total, err := SearchLogs("upload", "", time.Time{}, time.Time{}, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 || len(got) != 3 || got[0].Time != base.Add(3*time.Minute) {
+		t.Fatalf("upload: total=%d got=%+v", total, got)
+	}
+	got, total, err = SearchLogs("upload", "error", base, base.Add(2*time.Minute), 10, 0)
+	if err != nil || total != 1 || got[0].Msg != "upload failed" {
+		t.Fatalf("upload/error in range: total=%d got=%+v err=%v", total, got, err)
+	}
+	got, total, err = SearchLogs("", "", time.Time{}, time.Time{}, 2, 2)
+	if err != nil || total != 4 || len(got) != 2 || got[0].Msg != "upload failed" || got[1].Msg != "upload started" {
+		t.Fatalf("paging: total=%d got=%+v err=%v", total, got, err)
+	}
+	// rows deleted from log_entries must drop out of the index too
+	DB.Where("msg = ?", "upload started").Delete(&models.LogEntry{})
+	if _, total, _ = SearchLogs("started", "", time.Time{}, time.Time{}, 10, 0); total != 0 {
+		t.Fatalf("deleted row still matched: %d", total)
+	}
+}
+
+// BenchmarkSearchLogs compares the FTS5 index with a LIKE scan on 100k rows. The fts5 case
+// needs the sqlite_fts5 build tag: go test -tags sqlite_fts5 -bench SearchLogs ./internal/db
+func BenchmarkSearchLogs(b *testing.B) {
+	initLogDB(b)
+	strategy := logSearch
+	base := time.Now().Add(-24 * time.Hour)
+	rows := make([]models.LogEntry, 0, 100000)
+	for i := 0; i < cap(rows); i++ {
+		msg := fmt.Sprintf("http_request path=/api/v1/providers/%d/buckets status=200", i%50)
+		if i%1000 == 0 {
+			msg = fmt.Sprintf("upload failed for object %d: connection reset", i)
+		}
+		rows = append(rows, models.LogEntry{Time: base.Add(time.Duration(i) * time.Millisecond), Level: "info", Msg: msg})
+	}
+	if err := DB.CreateInBatches(&rows, 1000).Error; err != nil {
+		b.Fatal(err)
+	}
+	for _, s := range []string{logSearchLike, logSearchFTS5} {
+		b.Run(s, func(b *testing.B) {
+			if s == logSearchFTS5 && strategy != logSearchFTS5 {
+				b.Skip("FTS5 not compiled in; build with -tags sqlite_fts5")
+			}
+			logSearch = s
+			defer func() { logSearch = strategy }()
+			for i := 0; i < b.N; i++ {
+				if _, total, err := SearchLogs("connection reset", "", time.Time{}, time.Time{}, 50, 0); err != nil || total != 100 {
+					b.Fatalf("total=%d err=%v", total, err)
+				}
+			}
+		})
+	}
+}
+
+func TestLogsForTrace(t *testing.T) {
+	initLogDB(t)
+	if logTraceID != "trace_id" {
+		t.Fatalf("trace_id column not created, lookups use %s", logTraceID)
+	}
+	base := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	rows := []models.LogEntry{
+		{Time: base.Add(time.Second), Level: "info", Msg: "http_request", Fields: `{"traceId":"abc","status":200}`},
+		{Time: base, Level: "error", Msg: "upload failed", Fields: `{"traceId":"abc"}`},
+		{Time: base, Level: "info", Msg: "other request", Fields: `{"traceId":"def"}`},
+		{Time: base, Level: "info", Msg: "no fields", Fields: ``},
+	}
+	if err := DB.Create(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	got, total, err := LogsForTrace(DB, "abc", 10, 0)
+	if err != nil || total != 2 || len(got) != 2 || got[0].Msg != "upload failed" || got[1].Msg != "http_request" {
+		t.Fatalf("total=%d got=%+v err=%v", total, got, err)
+	}
+	if n, err := CountLogsForTrace(DB, "def"); err != nil || n != 1 {
+		t.Fatalf("count def: %d %v", n, err)
+	}
+	var plan []struct{ Detail string }
+	DB.Raw(`EXPLAIN QUERY PLAN SELECT * FROM log_entries WHERE trace_id = ?`, "abc").Scan(&plan)
+	if len(plan) == 0 || !strings.Contains(plan[0].Detail, "idx_log_entries_trace_id") {
+		t.Fatalf("trace lookup does not use the index: %+v", plan)
+	}
+}
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/arencloud/hermes/internal/models"
+	"gorm.io/gorm"
+)
+
+// Migration is one versioned schema change. Rollback is nil for changes that cannot be
+// undone.
+type Migration struct {
+	Version     string
+	Description string
+	Apply       func(tx *gorm.DB) error
+	Rollback    func(tx *gorm.DB) error
+}
+
+// migrations are applied in slice order. Append new ones with the next version; never
+// edit one that may already be applied somewhere, as it will not run again.
+var migrations = []Migration{
+	{
+		Version:     "001",
+		Description: "initial schema",
+		// AutoMigrate only adds, so databases created before versioning are adopted as is.
+		// Not reversible: it would drop every table, users included.
+		Apply: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&models.User{}, &models.Provider{}, &models.Bucket{}, &models.AuthConfig{}, &models.LogEntry{}, &models.TraceRow{}, &models.TraceEventRow{}, &models.MetricPoint{}, &models.ObjectTrashItem{}, &models.BucketACL{}, &models.ObjectStat{}, &models.UserPreference{}, &models.BucketTemplate{}, &models.TieringRecommendation{}, &models.ProviderHealth{}, &models.ProviderQuota{}, &models.BucketSummary{}, &models.LoginAttempt{}, &models.InProgressMove{}, &models.ObjectIndex{})
+		},
+	},
+	{
+		Version:     "002",
+		Description: "provider access tokens",
+		Apply:       func(tx *gorm.DB) error { return tx.AutoMigrate(&models.ProviderAccessToken{}) },
+		Rollback:    func(tx *gorm.DB) error { return tx.Migrator().DropTable(&models.ProviderAccessToken{}) },
+	},
+	{
+		Version:     "003",
+		Description: "provider max retries",
+		Apply:       func(tx *gorm.DB) error { return tx.AutoMigrate(&models.Provider{}) },
+		Rollback:    func(tx *gorm.DB) error { return tx.Migrator().DropColumn(&models.Provider{}, "MaxRetries") },
+	},
+	{
+		Version:     "004",
+		Description: "provider tags",
+		Apply:       func(tx *gorm.DB) error { return tx.AutoMigrate(&models.Provider{}) },
+		Rollback:    func(tx *gorm.DB) error { return tx.Migrator().DropColumn(&models.Provider{}, "Tags") },
+	},
+	{
+		Version:     "005",
+		Description: "sessions",
+		Apply:       func(tx *gorm.DB) error { return tx.AutoMigrate(&models.Session{}) },
+		Rollback:    func(tx *gorm.DB) error { return tx.Migrator().DropTable(&models.Session{}) },
+	},
+	{
+		Version:     "006",
+		Description: "api keys",
+		Apply:       func(tx *gorm.DB) error { return tx.AutoMigrate(&models.APIKey{}) },
+		Rollback:    func(tx *gorm.DB) error { return tx.Migrator().DropTable(&models.APIKey{}) },
+	},
+	{
+		Version:     "007",
+		Description: "audit log",
+		Apply:       func(tx *gorm.DB) error { return tx.AutoMigrate(&models.AuditLog{}) },
+		Rollback:    func(tx *gorm.DB) error { return tx.Migrator().DropTable(&models.AuditLog{}) },
+	},
+	{
+		Version:     "008",
+		Description: "bucket summary last modified",
+		Apply:       func(tx *gorm.DB) error { return tx.AutoMigrate(&models.BucketSummary{}) },
+		Rollback:    func(tx *gorm.DB) error { return tx.Migrator().DropColumn(&models.BucketSummary{}, "LastModified") },
+	},
+	{
+		Version:     "009",
+		Description: "trace start time index",
+		Apply:       func(tx *gorm.DB) error { return tx.AutoMigrate(&models.TraceRow{}) },
+		Rollback:    func(tx *gorm.DB) error { return tx.Migrator().DropIndex(&models.TraceRow{}, "Started") },
+	},
+	{
+		Version:     "010",
+		Description: "trash item restored flag",
+		Apply:       func(tx *gorm.DB) error { return tx.AutoMigrate(&models.ObjectTrashItem{}) },
+		Rollback:    func(tx *gorm.DB) error { return tx.Migrator().DropColumn(&models.ObjectTrashItem{}, "Restored") },
+	},
+}
+
+var (
+	// ErrNoMigrations is returned by RollbackLast when nothing is applied.
+	ErrNoMigrations = errors.New("no migrations applied")
+	// ErrIrreversible is returned by RollbackLast for a migration without Rollback.
+	ErrIrreversible = errors.New("migration cannot be rolled back")
+)
+
+// Migrate applies, in order, every migration not yet recorded in the migrations table.
+// Each runs in its own transaction together with its record, so a failed one is retried
+// on the next start.
+func Migrate(gdb *gorm.DB) error {
+	if err := gdb.AutoMigrate(&models.Migration{}); err != nil {
+		return err
+	}
+	var applied []string
+	if err := gdb.Model(&models.Migration{}).Pluck("version", &applied).Error; err != nil {
+		return err
+	}
+	done := make(map[string]bool, len(applied))
+	for _, v := range applied {
+		done[v] = true
+	}
+	for _, m := range migrations {
+		if done[m.Version] {
+			continue
+		}
+		err := gdb.Transaction(func(tx *gorm.DB) error {
+			if err := m.Apply(tx); err != nil {
+				return err
+			}
+			return tx.Create(&models.Migration{Version: m.Version, Description: m.Description, AppliedAt: time.Now().UTC()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s (%s): %w", m.Version, m.Description, err)
+		}
+	}
+	return nil
+}
+
+// AppliedMigrations lists the recorded migrations, oldest first.
+func AppliedMigrations(gdb *gorm.DB) ([]models.Migration, error) {
+	var out []models.Migration
+	err := gdb.Order("version asc").Find(&out).Error
+	return out, err
+}
+
+// RollbackLast undoes the most recently applied migration and removes its record.
+func RollbackLast(gdb *gorm.DB) (models.Migration, error) {
+	var last models.Migration
+	if err := gdb.Order("version desc").Limit(1).Find(&last).Error; err != nil {
+		return last, err
+	}
+	if last.Version == "" {
+		return last, ErrNoMigrations
+	}
+	var m *Migration
+	for i := range migrations {
+		if migrations[i].Version == last.Version {
+			m = &migrations[i]
+		}
+	}
+	if m == nil || m.Rollback == nil {
+		return last, fmt.Errorf("%s: %w", last.Version, ErrIrreversible)
+	}
+	// The record is deleted first so the transaction starts with a write: on SQLite a
+	// transaction that reads the schema first (as DropColumn does) fails with "database is
+	// locked" instead of waiting when another connection writes in between.
+	err := gdb.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.Migration{}, "version = ?", last.Version).Error; err != nil {
+			return err
+		}
+		return m.Rollback(tx)
+	})
+	return last, err
+}
@@ -0,0 +1,57 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/arencloud/hermes/internal/config"
+	"github.com/arencloud/hermes/internal/logging"
+	"gorm.io/gorm"
+)
+
+func TestMigrateAndRollback(t *testing.T) {
+	cfg := &config.Config{DBDriver: "sqlite", DBPath: filepath.Join(t.TempDir(), "migrations.db")}
+	if err := Init(cfg, logging.New("test")); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	applied, err := AppliedMigrations(DB)
+	if err != nil || len(applied) != len(migrations) || applied[0].Version != "001" || applied[0].AppliedAt.IsZero() {
+		t.Fatalf("expected every migration recorded from 001, got %+v (%v)", applied, err)
+	}
+
+	type widget struct{ ID uint }
+	prev := migrations
+	defer func() { migrations = prev }()
+	runs := 0
+	migrations = append(append([]Migration{}, prev...), Migration{
+		Version:     "999",
+		Description: "test widgets",
+		Apply:       func(tx *gorm.DB) error { runs++; return tx.AutoMigrate(&widget{}) },
+		Rollback:    func(tx *gorm.DB) error { return tx.Migrator().DropTable(&widget{}) },
+	})
+	for i := 0; i < 2; i++ {
+		if err := Migrate(DB); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if runs != 1 || !DB.Migrator().HasTable(&widget{}) {
+		t.Fatalf("expected 999 applied once, ran %d times", runs)
+	}
+
+	m, err := RollbackLast(DB)
+	if err != nil || m.Version != "999" || DB.Migrator().HasTable(&widget{}) {
+		t.Fatalf("rollback of 999: %+v %v", m, err)
+	}
+	if applied, _ := AppliedMigrations(DB); len(applied) != len(prev) {
+		t.Fatalf("expected %d migrations left, got %+v", len(prev), applied)
+	}
+	for range prev[1:] {
+		if _, err := RollbackLast(DB); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := RollbackLast(DB); !errors.Is(err, ErrIrreversible) {
+		t.Fatalf("expected 001 to be irreversible, got %v", err)
+	}
+}
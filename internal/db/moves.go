@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/arencloud/hermes/internal/logging"
+	"github.com/arencloud/hermes/internal/models"
+	"github.com/arencloud/hermes/internal/s3"
+	"gorm.io/gorm"
+)
+
+// MoveRetention is how long finished move journal rows are kept.
+const MoveRetention = 24 * time.Hour
+
+// ResumeMoves finishes moves that were interrupted after the object was copied but before
+// the source was deleted, by deleting the source now. That is safe because the copy is
+// already in place. Journal rows finished (or abandoned before the copy) more than
+// MoveRetention ago are removed. open builds the client for the source provider.
+func ResumeMoves(gdb *gorm.DB, now time.Time, open func(models.Provider) (s3.ClientInterface, error), logger logging.Logger) error {
+	var pending []models.InProgressMove
+	if err := gdb.Where("copied_at IS NOT NULL AND deleted_at IS NULL").Find(&pending).Error; err != nil {
+		return err
+	}
+	for _, mv := range pending {
+		var p models.Provider
+		if err := gdb.First(&p, mv.ProviderID).Error; err != nil {
+			logger.Error("move resume failed", "move", mv.ID, "error", "source provider not found")
+			continue
+		}
+		c, err := open(p)
+		if err != nil {
+			logger.Error("move resume failed", "move", mv.ID, "error", err)
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err = c.DeleteObject(ctx, mv.SrcBucket, mv.SrcKey)
+		cancel()
+		if err != nil {
+			logger.Error("move resume failed", "move", mv.ID, "bucket", mv.SrcBucket, "key", mv.SrcKey, "error", err)
+			continue
+		}
+		deleted := now
+		if err := gdb.Model(&models.InProgressMove{}).Where("id = ?", mv.ID).Update("deleted_at", &deleted).Error; err != nil {
+			return err
+		}
+		logger.Info("move resumed", "move", mv.ID, "bucket", mv.SrcBucket, "key", mv.SrcKey, "user", mv.UserEmail)
+	}
+	cutoff := now.Add(-MoveRetention)
+	return gdb.Where("deleted_at < ? OR (copied_at IS NULL AND created_at < ?)", cutoff, cutoff).Delete(&models.InProgressMove{}).Error
+}
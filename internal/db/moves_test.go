@@ -0,0 +1,55 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/arencloud/hermes/internal/logging"
+	"github.com/arencloud/hermes/internal/models"
+	"github.com/arencloud/hermes/internal/s3"
+)
+
+func TestResumeMoves(t *testing.T) {
+	gdb := openTestDB(t)
+	if err := gdb.AutoMigrate(&models.Provider{}, &models.InProgressMove{}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time { ts := now.Add(d); return &ts }
+	gdb.Create(&models.Provider{ID: 1, Name: "p"})
+	moves := []models.InProgressMove{
+		{ID: "copied", ProviderID: 1, SrcBucket: "a", SrcKey: "k1", CopiedAt: at(-time.Minute), CreatedAt: now.Add(-time.Minute)},
+		{ID: "failing", ProviderID: 1, SrcBucket: "a", SrcKey: "k2", CopiedAt: at(-time.Minute), CreatedAt: now.Add(-time.Minute)},
+		{ID: "old-done", ProviderID: 1, SrcBucket: "a", SrcKey: "k3", CopiedAt: at(-48 * time.Hour), DeletedAt: at(-48 * time.Hour), CreatedAt: now.Add(-48 * time.Hour)},
+		{ID: "recent-done", ProviderID: 1, SrcBucket: "a", SrcKey: "k4", CopiedAt: at(-time.Hour), DeletedAt: at(-time.Hour), CreatedAt: now.Add(-time.Hour)},
+		{ID: "abandoned", ProviderID: 1, SrcBucket: "a", SrcKey: "k5", CreatedAt: now.Add(-48 * time.Hour)},
+	}
+	if err := gdb.Create(&moves).Error; err != nil {
+		t.Fatal(err)
+	}
+	var deleted []string
+	m := &s3.MockClient{OnDeleteObject: func(bucket, key string) error {
+		if key == "k2" {
+			return errors.New("endpoint down")
+		}
+		deleted = append(deleted, bucket+"/"+key)
+		return nil
+	}}
+	open := func(models.Provider) (s3.ClientInterface, error) { return m, nil }
+	if err := ResumeMoves(gdb, now, open, logging.New("test")); err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0] != "a/k1" {
+		t.Fatalf("deleted %v", deleted)
+	}
+	var rows []models.InProgressMove
+	gdb.Order("id").Find(&rows)
+	got := map[string]models.InProgressMove{}
+	for _, r := range rows {
+		got[r.ID] = r
+	}
+	if len(got) != 3 || got["copied"].DeletedAt == nil || got["failing"].DeletedAt != nil || got["recent-done"].ID == "" {
+		t.Fatalf("journal after resume: %+v", rows)
+	}
+}
@@ -0,0 +1,36 @@
+package db
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/arencloud/hermes/internal/models"
+	"gorm.io/gorm"
+)
+
+// ProviderTagScope limits a provider query to those tagged tag. Tags are a JSON array,
+// matched with jsonb containment on postgres and json_each on sqlite.
+func ProviderTagScope(tag string) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		if tx.Dialector.Name() == "postgres" {
+			arr, _ := json.Marshal([]string{tag})
+			return tx.Where("providers.tags::jsonb @> ?::jsonb", string(arr))
+		}
+		return tx.Where("EXISTS (SELECT 1 FROM json_each(providers.tags) WHERE json_each.value = ?)", tag)
+	}
+}
+
+// ProviderTags returns the distinct tags of the providers matched by scopes, sorted.
+func ProviderTags(gdb *gorm.DB, scopes ...func(*gorm.DB) *gorm.DB) ([]string, error) {
+	var tags []string
+	q := gdb.Model(&models.Provider{}).Scopes(scopes...)
+	col := "jsonb_array_elements_text(providers.tags::jsonb)"
+	if gdb.Dialector.Name() != "postgres" {
+		q, col = q.Joins(", json_each(providers.tags)"), "json_each.value"
+	}
+	if err := q.Distinct().Pluck(col, &tags).Error; err != nil {
+		return nil, err
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
@@ -0,0 +1,133 @@
+package db
+
+import (
+	"time"
+
+	"github.com/arencloud/hermes/internal/models"
+	"gorm.io/gorm"
+)
+
+// Metric point granularities, from finest to coarsest.
+const (
+	GranularityMinute = "minute"
+	GranularityHour   = "hour"
+	GranularityDay    = "day"
+)
+
+// Rollup horizons: minute points older than MinuteRetention are compressed into
+// hourly points, hourly points older than HourRetention into daily points.
+const (
+	MinuteRetention = 48 * time.Hour
+	HourRetention   = 30 * 24 * time.Hour
+)
+
+// RollupMetrics compresses old metric points into coarser granularities.
+// Each group of (name, labels, clock period) becomes a single aggregated row and
+// the source rows are deleted in the same transaction.
+func RollupMetrics(gdb *gorm.DB, now time.Time) error {
+	if err := rollupGranularity(gdb, GranularityMinute, GranularityHour, time.Hour, now.Add(-MinuteRetention)); err != nil {
+		return err
+	}
+	return rollupGranularity(gdb, GranularityHour, GranularityDay, 24*time.Hour, now.Add(-HourRetention))
+}
+
+// GranularityForRange picks the finest point granularity still kept for points as old
+// as d. Callers pass how far back the queried range reaches, not just its span: a short
+// range far in the past only exists in coarser points.
+func GranularityForRange(d time.Duration) string {
+	switch {
+	case d < MinuteRetention:
+		return GranularityMinute
+	case d < HourRetention:
+		return GranularityHour
+	default:
+		return GranularityDay
+	}
+}
+
+// rollupGranularity compresses the from points older than before, one period per
+// transaction, so that a large backlog (e.g. the first run on an existing install) is
+// never loaded or locked at once.
+func rollupGranularity(gdb *gorm.DB, from, to string, period time.Duration, before time.Time) error {
+	// only roll up whole periods so a clock hour/day is never split across two rows
+	cutoff := before.UTC().Truncate(period)
+	for {
+		var first models.MetricPoint
+		res := gdb.Where("granularity = ? AND timestamp < ?", from, cutoff).Order("timestamp asc").Limit(1).Find(&first)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		start := first.Timestamp.UTC().Truncate(period)
+		n, err := rollupPeriod(gdb, from, to, start, start.Add(period))
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil // nothing matched the period of the oldest point; do not spin
+		}
+	}
+}
+
+// rollupPeriod aggregates the from points in [start, end) into to points, one per
+// (name, labels), and deletes them, in one transaction. It returns the points removed.
+func rollupPeriod(gdb *gorm.DB, from, to string, start, end time.Time) (int, error) {
+	n := 0
+	err := gdb.Transaction(func(tx *gorm.DB) error {
+		var rows []models.MetricPoint
+		if err := tx.Where("granularity = ? AND timestamp >= ? AND timestamp < ?", from, start, end).Order("timestamp asc").Find(&rows).Error; err != nil {
+			return err
+		}
+		n = len(rows)
+		if n == 0 {
+			return nil
+		}
+		type key struct{ name, labels string }
+		groups := map[key]*models.MetricPoint{}
+		order := make([]key, 0)
+		ids := make([]uint, 0, len(rows))
+		for _, p := range rows {
+			ids = append(ids, p.ID)
+			k := key{p.Name, p.Labels}
+			g := groups[k]
+			if g == nil {
+				g = &models.MetricPoint{Name: p.Name, Labels: p.Labels, Timestamp: start, Granularity: to, Min: p.Min, Max: p.Max}
+				groups[k] = g
+				order = append(order, k)
+			}
+			g.Count += p.Count
+			g.Sum += p.Sum
+			if p.Min < g.Min {
+				g.Min = p.Min
+			}
+			if p.Max > g.Max {
+				g.Max = p.Max
+			}
+		}
+		out := make([]models.MetricPoint, 0, len(order))
+		for _, k := range order {
+			g := groups[k]
+			if g.Count > 0 {
+				g.Avg = g.Sum / float64(g.Count)
+			}
+			out = append(out, *g)
+		}
+		if err := tx.CreateInBatches(out, 100).Error; err != nil {
+			return err
+		}
+		// delete in chunks to stay below driver bind-variable limits
+		for i := 0; i < len(ids); i += 500 {
+			end := i + 500
+			if end > len(ids) {
+				end = len(ids)
+			}
+			if err := tx.Delete(&models.MetricPoint{}, ids[i:end]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return n, err
+}
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/arencloud/hermes/internal/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	gdb, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.AutoMigrate(&models.MetricPoint{}); err != nil {
+		t.Fatal(err)
+	}
+	return gdb
+}
+
+func minutePoint(name string, ts time.Time, v float64) models.MetricPoint {
+	return models.MetricPoint{Name: name, Timestamp: ts, Granularity: GranularityMinute, Count: 1, Sum: v, Min: v, Max: v, Avg: v}
+}
+
+func TestRollupMinuteToHour(t *testing.T) {
+	gdb := openTestDB(t)
+	now := time.Date(2024, 5, 10, 12, 30, 0, 0, time.UTC)
+	old := now.Add(-3 * 24 * time.Hour).Truncate(time.Hour) // a whole clock hour well past retention
+	pts := []models.MetricPoint{
+		minutePoint("requests", old.Add(1*time.Minute), 2),
+		minutePoint("requests", old.Add(2*time.Minute), 8),
+		minutePoint("requests", old.Add(59*time.Minute), 5),
+		minutePoint("requests", old.Add(61*time.Minute), 4), // next hour
+		minutePoint("bytes_in", old.Add(3*time.Minute), 100),
+		minutePoint("requests", now.Add(-time.Hour), 7), // recent, must stay minute-level
+	}
+	if err := gdb.Create(&pts).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := RollupMetrics(gdb, now); err != nil {
+		t.Fatalf("rollup: %v", err)
+	}
+	var hours []models.MetricPoint
+	gdb.Where("granularity = ?", GranularityHour).Order("name asc, timestamp asc").Find(&hours)
+	if len(hours) != 3 {
+		t.Fatalf("expected 3 hourly points, got %d", len(hours))
+	}
+	bi, h1, h2 := hours[0], hours[1], hours[2]
+	if bi.Name != "bytes_in" || bi.Count != 1 || bi.Sum != 100 {
+		t.Fatalf("unexpected bytes_in rollup: %+v", bi)
+	}
+	if !h1.Timestamp.Equal(old) || h1.Count != 3 || h1.Sum != 15 || h1.Min != 2 || h1.Max != 8 || h1.Avg != 5 {
+		t.Fatalf("unexpected first hour rollup: %+v", h1)
+	}
+	if !h2.Timestamp.Equal(old.Add(time.Hour)) || h2.Count != 1 || h2.Sum != 4 {
+		t.Fatalf("unexpected second hour rollup: %+v", h2)
+	}
+	var minutes int64
+	gdb.Model(&models.MetricPoint{}).Where("granularity = ?", GranularityMinute).Count(&minutes)
+	if minutes != 1 {
+		t.Fatalf("expected only the recent minute point to remain, got %d", minutes)
+	}
+}
+
+func TestRollupHourToDay(t *testing.T) {
+	gdb := openTestDB(t)
+	now := time.Date(2024, 5, 10, 12, 30, 0, 0, time.UTC)
+	day := now.Add(-40 * 24 * time.Hour).Truncate(24 * time.Hour)
+	pts := []models.MetricPoint{
+		{Name: "requests", Timestamp: day.Add(1 * time.Hour), Granularity: GranularityHour, Count: 3, Sum: 30, Min: 5, Max: 15, Avg: 10},
+		{Name: "requests", Timestamp: day.Add(5 * time.Hour), Granularity: GranularityHour, Count: 1, Sum: 2, Min: 2, Max: 2, Avg: 2},
+	}
+	if err := gdb.Create(&pts).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := RollupMetrics(gdb, now); err != nil {
+		t.Fatalf("rollup: %v", err)
+	}
+	var days []models.MetricPoint
+	gdb.Where("granularity = ?", GranularityDay).Find(&days)
+	if len(days) != 1 {
+		t.Fatalf("expected 1 daily point, got %d", len(days))
+	}
+	d := days[0]
+	if !d.Timestamp.Equal(day) || d.Count != 4 || d.Sum != 32 || d.Min != 2 || d.Max != 15 || d.Avg != 8 {
+		t.Fatalf("unexpected daily rollup: %+v", d)
+	}
+}
+
+func TestGranularityForRange(t *testing.T) {
+	cases := []struct {
+		d    time.Duration
+		want string
+	}{
+		{time.Hour, GranularityMinute},
+		{3 * 24 * time.Hour, GranularityHour},
+		{60 * 24 * time.Hour, GranularityDay},
+	}
+	for _, c := range cases {
+		if got := GranularityForRange(c.d); got != c.want {
+			t.Fatalf("GranularityForRange(%v)=%s want %s", c.d, got, c.want)
+		}
+	}
+}
+
+func TestRollupBacklogByPeriod(t *testing.T) {
+	gdb := openTestDB(t)
+	now := time.Date(2024, 5, 10, 12, 30, 0, 0, time.UTC)
+	start := now.Add(-10 * 24 * time.Hour).Truncate(time.Hour)
+	var pts []models.MetricPoint
+	for h := 0; h < 120; h++ {
+		for m := 0; m < 60; m += 20 {
+			pts = append(pts, minutePoint("requests", start.Add(time.Duration(h)*time.Hour+time.Duration(m)*time.Minute), 1))
+		}
+	}
+	if err := gdb.CreateInBatches(pts, 500).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := RollupMetrics(gdb, now); err != nil {
+		t.Fatalf("rollup: %v", err)
+	}
+	var hours []models.MetricPoint
+	gdb.Where("granularity = ?", GranularityHour).Order("timestamp asc").Find(&hours)
+	if len(hours) != 120 || !hours[0].Timestamp.Equal(start) || hours[119].Count != 3 {
+		t.Fatalf("expected 120 hourly points of 3 minutes each, got %d", len(hours))
+	}
+	var minutes int64
+	gdb.Model(&models.MetricPoint{}).Where("granularity = ?", GranularityMinute).Count(&minutes)
+	if minutes != 0 {
+		t.Fatalf("%d minute points left behind", minutes)
+	}
+}
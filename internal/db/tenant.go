@@ -0,0 +1,17 @@
+package db
+
+import "gorm.io/gorm"
+
+// AllTenants makes TenantScope a no-op; used for super-admin cross-tenant access.
+const AllTenants = "*"
+
+// TenantScope restricts a query to rows owned by tenantID. Every tenant-aware model
+// carries a tenant_id column; single-tenant deployments store the empty tenant.
+func TenantScope(tenantID string) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		if tenantID == AllTenants {
+			return tx
+		}
+		return tx.Where("tenant_id = ?", tenantID)
+	}
+}
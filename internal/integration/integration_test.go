@@ -0,0 +1,250 @@
+//go:build integration
+
+// Package integration runs Hermes against real MinIO and PostgreSQL containers.
+// Run with: make test-integration (requires Docker or Podman with a Docker-compatible socket).
+package integration
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/arencloud/hermes/internal/api"
+	"github.com/arencloud/hermes/internal/config"
+	"github.com/arencloud/hermes/internal/db"
+	"github.com/arencloud/hermes/internal/logging"
+	"github.com/arencloud/hermes/internal/models"
+	"github.com/testcontainers/testcontainers-go"
+	tcminio "github.com/testcontainers/testcontainers-go/modules/minio"
+	tcpostgres "github.com/testcontainers/testcontainers-go/modules/postgres"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	minioImage    = "minio/minio:RELEASE.2024-01-16T16-07-38Z"
+	postgresImage = "postgres:16-alpine"
+	minioUser     = "minioadmin"
+	minioPassword = "minioadmin"
+)
+
+func startMinIO(t *testing.T, ctx context.Context) string {
+	t.Helper()
+	c, err := tcminio.Run(ctx, minioImage, tcminio.WithUsername(minioUser), tcminio.WithPassword(minioPassword))
+	t.Cleanup(func() {
+		if err := testcontainers.TerminateContainer(c); err != nil {
+			t.Logf("terminate minio: %v", err)
+		}
+	})
+	if err != nil {
+		t.Fatalf("start minio: %v", err)
+	}
+	ep, err := c.ConnectionString(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ep
+}
+
+func startPostgres(t *testing.T, ctx context.Context) string {
+	t.Helper()
+	c, err := tcpostgres.Run(ctx, postgresImage,
+		tcpostgres.WithDatabase("hermes"), tcpostgres.WithUsername("hermes"), tcpostgres.WithPassword("hermes"),
+		tcpostgres.BasicWaitStrategies())
+	t.Cleanup(func() {
+		if err := testcontainers.TerminateContainer(c); err != nil {
+			t.Logf("terminate postgres: %v", err)
+		}
+	})
+	if err != nil {
+		t.Fatalf("start postgres: %v", err)
+	}
+	dsn, err := c.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dsn
+}
+
+// client is an HTTP client logged in to a Hermes server as an admin.
+type client struct {
+	t    *testing.T
+	base string
+	hc   *http.Client
+}
+
+func startHermes(t *testing.T, dsn string) *client {
+	t.Helper()
+	static := t.TempDir()
+	os.WriteFile(filepath.Join(static, "index.html"), []byte("<html></html>"), 0o644)
+	cfg := &config.Config{Env: "test", DBDriver: "postgres", DBDsn: dsn, StaticDir: static}
+	logger := logging.New("test")
+	if err := db.Init(cfg, logger); err != nil {
+		t.Fatalf("db init: %v", err)
+	}
+	ts := httptest.NewServer(api.Router(cfg, logger))
+	t.Cleanup(ts.Close)
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secretpass"), bcrypt.MinCost)
+	if err := db.DB.Create(&models.User{Email: "it-admin@example.com", Password: string(hash), Role: "admin"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	jar, _ := cookiejar.New(nil)
+	c := &client{t: t, base: ts.URL + "/api/v1", hc: &http.Client{Jar: jar, Timeout: time.Minute}}
+	c.expect("POST", "/auth/login", map[string]string{"email": "it-admin@example.com", "password": "secretpass"}, 200, nil)
+	return c
+}
+
+func (c *client) do(method, path, contentType string, body io.Reader) *http.Response {
+	c.t.Helper()
+	req, err := http.NewRequest(method, c.base+path, body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		c.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	return resp
+}
+
+// expect sends a JSON request, checks the status and decodes the response into out (if non-nil).
+func (c *client) expect(method, path string, in any, status int, out any) {
+	c.t.Helper()
+	var body io.Reader
+	if in != nil {
+		b, _ := json.Marshal(in)
+		body = bytes.NewReader(b)
+	}
+	resp := c.do(method, path, "application/json", body)
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != status {
+		c.t.Fatalf("%s %s: status %d, want %d: %s", method, path, resp.StatusCode, status, raw)
+	}
+	if out != nil {
+		if err := json.Unmarshal(raw, out); err != nil {
+			c.t.Fatalf("%s %s: decode: %v", method, path, err)
+		}
+	}
+}
+
+func (c *client) createProvider(name, endpoint string) uint {
+	c.t.Helper()
+	var p models.Provider
+	c.expect("POST", "/providers", map[string]any{"name": name, "type": "minio", "endpoint": endpoint, "accessKey": minioUser, "secretKey": minioPassword, "region": "us-east-1"}, 201, &p)
+	return p.ID
+}
+
+func (c *client) upload(pid uint, bucket, key string, data []byte) {
+	c.t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("key", key)
+	fw, _ := mw.CreateFormFile("file", key)
+	fw.Write(data)
+	mw.Close()
+	resp := c.do("POST", fmt.Sprintf("/providers/%d/buckets/%s/upload", pid, bucket), mw.FormDataContentType(), &buf)
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		raw, _ := io.ReadAll(resp.Body)
+		c.t.Fatalf("upload %s: status %d: %s", key, resp.StatusCode, raw)
+	}
+}
+
+func (c *client) download(pid uint, bucket, key string) (int, []byte) {
+	c.t.Helper()
+	resp := c.do("GET", fmt.Sprintf("/providers/%d/buckets/%s/download?key=%s", pid, bucket, key), "", nil)
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, b
+}
+
+func randomObject(t *testing.T, size int) []byte {
+	t.Helper()
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestObjectLifecycle(t *testing.T) {
+	ctx := context.Background()
+	c := startHermes(t, startPostgres(t, ctx))
+	pid := c.createProvider("minio-a", startMinIO(t, ctx))
+	c.expect("POST", fmt.Sprintf("/providers/%d/buckets", pid), map[string]string{"name": "it-bucket"}, 201, nil)
+
+	data := randomObject(t, 1<<20)
+	c.upload(pid, "it-bucket", "blob.bin", data)
+	code, got := c.download(pid, "it-bucket", "blob.bin")
+	if code != 200 || sha256.Sum256(got) != sha256.Sum256(data) {
+		t.Fatalf("download: status %d, %d bytes, checksum mismatch", code, len(got))
+	}
+
+	c.expect("DELETE", fmt.Sprintf("/providers/%d/buckets/it-bucket/objects?key=blob.bin", pid), nil, 204, nil)
+	if code, _ := c.download(pid, "it-bucket", "blob.bin"); code == 200 {
+		t.Fatal("object still downloadable after delete")
+	}
+	var list struct {
+		Data []struct {
+			Key string `json:"key"`
+		} `json:"data"`
+	}
+	c.expect("GET", fmt.Sprintf("/providers/%d/buckets/it-bucket/objects", pid), nil, 200, &list)
+	if len(list.Data) != 0 {
+		t.Fatalf("bucket not empty after delete: %+v", list.Data)
+	}
+}
+
+func TestCrossProviderCopy(t *testing.T) {
+	ctx := context.Background()
+	c := startHermes(t, startPostgres(t, ctx))
+	src := c.createProvider("minio-src", startMinIO(t, ctx))
+	dst := c.createProvider("minio-dst", startMinIO(t, ctx))
+	c.expect("POST", fmt.Sprintf("/providers/%d/buckets", src), map[string]string{"name": "src"}, 201, nil)
+	c.expect("POST", fmt.Sprintf("/providers/%d/buckets", dst), map[string]string{"name": "dst"}, 201, nil)
+
+	data := randomObject(t, 1<<20)
+	c.upload(src, "src", "blob.bin", data)
+
+	b, _ := json.Marshal(map[string]any{"srcKey": "blob.bin", "dstBucket": "dst", "dstKey": "copied.bin", "dstProviderId": dst})
+	resp := c.do("POST", fmt.Sprintf("/providers/%d/buckets/src/copy", src), "application/json", bytes.NewReader(b))
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("copy: status %d", resp.StatusCode)
+	}
+	// the NDJSON progress stream ends with a "done" frame
+	var last map[string]any
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		last = nil
+		json.Unmarshal(sc.Bytes(), &last)
+	}
+	if last["done"] != true {
+		t.Fatalf("copy did not complete: %v", last)
+	}
+
+	code, got := c.download(dst, "dst", "copied.bin")
+	if code != 200 || sha256.Sum256(got) != sha256.Sum256(data) {
+		t.Fatalf("copied object: status %d, %d bytes, checksum mismatch", code, len(got))
+	}
+	if code, got := c.download(src, "src", "blob.bin"); code != 200 || len(got) != len(data) {
+		t.Fatalf("source object changed by copy: status %d", code)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"log"
 	"os"
+	"strings"
 	"sync"
 	"time"
 )
@@ -23,8 +24,9 @@ type entry struct {
 }
 
 type stdLogger struct {
-	json bool
-	mu   sync.Mutex
+	json   bool
+	mu     sync.Mutex
+	syslog *syslogSink // optional second output, see LOG_SYSLOG_*
 }
 
 var (
@@ -37,19 +39,44 @@ var (
 	// live subscribers for streaming
 	subMu      sync.RWMutex
 	subscribers = map[chan *entry]struct{}{}
+	// per-component level overrides (component -> level), see SetComponentLevel
+	compMu     sync.RWMutex
+	compLevels = map[string]string{}
 	// optional persistence hook
 	persistMu sync.RWMutex
 	persistFn func(any) error
+	persistWG sync.WaitGroup // entries handed to persistFn and not yet written
 )
 
-// New creates a logger; honors env vars LOG_LEVEL (debug|info|error), LOG_JSON (true|false).
+// KnownComponents are the component names emitted by Hermes itself. A log entry's
+// component is its "component" field, or its message when the field is absent.
+var KnownComponents = []string{"gorm_sql", "http_request", "object.upload", "object.download"}
+
+// levels in increasing severity
+var levelOrder = map[string]int{"debug":0, "info":1, "warn":2, "error":3, "fatal":4}
+
+// New creates a logger; honors env vars LOG_LEVEL (debug|info|warn|error), LOG_JSON (true|false)
+// and LOG_COMPONENT_LEVELS (e.g. "gorm_sql=warn;http_request=info").
 func New(env string) Logger {
 	lvl := os.Getenv("LOG_LEVEL")
 	if lvl == "" { lvl = "info" }
 	SetLevel(lvl)
+	if v := os.Getenv("LOG_COMPONENT_LEVELS"); v != "" {
+		for _, pair := range strings.Split(v, ";") {
+			c, l, ok := strings.Cut(pair, "=")
+			if ok { SetComponentLevel(strings.TrimSpace(c), strings.TrimSpace(l)) }
+		}
+	}
 	j := true
 	if v := os.Getenv("LOG_JSON"); v == "false" { j = false }
-	return &stdLogger{json: j}
+	l := &stdLogger{json: j}
+	// LOG_SYSLOG_NETWORK (udp|tcp) + LOG_SYSLOG_ADDR enable a syslog copy of every entry
+	if n, a := os.Getenv("LOG_SYSLOG_NETWORK"), os.Getenv("LOG_SYSLOG_ADDR"); n != "" && a != "" {
+		tag := os.Getenv("LOG_SYSLOG_TAG")
+		if tag == "" { tag = "hermes" }
+		l.syslog = newSyslogSink(n, a, tag)
+	}
+	return l
 }
 
 // Allow external packages to register a persistence callback
@@ -58,25 +85,51 @@ func SetPersist(fn func(any) error) {
 	persistFn = fn
 }
 
+// Flush waits until the entries already handed to the persistence callback are written.
+func Flush() { persistWG.Wait() }
+
 // Level control
 func SetLevel(lvl string) {
 	levelMu.Lock(); defer levelMu.Unlock()
-	switch lvl {
-	case "debug", "info", "error", "fatal":
-		logLevel = lvl
-	default:
-		logLevel = "info"
-	}
+	if ValidLevel(lvl) { logLevel = lvl } else { logLevel = "info" }
 }
 
 func GetLevel() string { levelMu.RLock(); defer levelMu.RUnlock(); return logLevel }
 
-func shouldLog(lvl string) bool {
-	levelMu.RLock(); defer levelMu.RUnlock()
-	order := map[string]int{"debug":0, "info":1, "error":2, "fatal":3}
-	cur := order[logLevel]
-	lv := order[lvl]
-	return lv >= cur
+// ValidLevel reports whether lvl is a known log level.
+func ValidLevel(lvl string) bool { _, ok := levelOrder[lvl]; return ok }
+
+// SetComponentLevel overrides the global level for one component; an empty or
+// unknown level removes the override.
+func SetComponentLevel(component, level string) {
+	if component == "" { return }
+	compMu.Lock(); defer compMu.Unlock()
+	if ValidLevel(level) { compLevels[component] = level } else { delete(compLevels, component) }
+}
+
+// GetComponentLevels returns a copy of the per-component level overrides.
+func GetComponentLevels() map[string]string {
+	compMu.RLock(); defer compMu.RUnlock()
+	out := make(map[string]string, len(compLevels))
+	for k, v := range compLevels { out[k] = v }
+	return out
+}
+
+// Enabled reports whether an entry of lvl for component would be logged.
+func Enabled(component, lvl string) bool {
+	compMu.RLock(); cl, ok := compLevels[component]; compMu.RUnlock()
+	if !ok { cl = GetLevel() }
+	return levelOrder[lvl] >= levelOrder[cl]
+}
+
+// componentOf returns the "component" field from kv, falling back to msg.
+func componentOf(msg string, kv []any) string {
+	for i := 0; i+1 < len(kv); i += 2 {
+		if k, ok := kv[i].(string); ok && k == "component" {
+			if c, ok := kv[i+1].(string); ok { return c }
+		}
+	}
+	return msg
 }
 
 func broadcast(e *entry){
@@ -94,7 +147,10 @@ func appendBuf(e *entry){
 	broadcast(e)
 	// persist asynchronously if configured
 	persistMu.RLock(); fn := persistFn; persistMu.RUnlock()
-	if fn != nil { go fn(e) }
+	if fn != nil {
+		persistWG.Add(1)
+		go func() { defer persistWG.Done(); fn(e) }()
+	}
 }
 
 func fieldsFromKV(kv []any) map[string]any {
@@ -110,9 +166,14 @@ func fieldsFromKV(kv []any) map[string]any {
 }
 
 func (l *stdLogger) write(level, msg string, kv ...any) {
-	if !shouldLog(level) { return }
+	if !Enabled(componentOf(msg, kv), level) { return }
 	e := &entry{Time: time.Now(), Level: level, Msg: msg, Fields: fieldsFromKV(kv)}
 	appendBuf(e)
+	if l.syslog != nil {
+		// syslog always receives the JSON form, regardless of LOG_JSON; it queues, so no lock
+		b, _ := json.Marshal(e)
+		l.syslog.write(level, string(b))
+	}
 	l.mu.Lock(); defer l.mu.Unlock()
 	if l.json {
 		b, _ := json.Marshal(e)
@@ -130,6 +191,24 @@ func (l *stdLogger) Info(msg string, kv ...any)  { l.write("info", msg, kv...) }
 func (l *stdLogger) Error(msg string, kv ...any) { l.write("error", msg, kv...) }
 func (l *stdLogger) Fatal(msg string, kv ...any) { l.write("fatal", msg, kv...); os.Exit(1) }
 
+// SetBufferSize resizes the ring of recent entries served by Recent, keeping the newest.
+func SetBufferSize(n int) {
+	if n <= 0 { return }
+	bufMu.Lock(); defer bufMu.Unlock()
+	if n == len(recent) { return }
+	kept := make([]*entry, 0, len(recent))
+	for i := 0; i < len(recent); i++ { // oldest first
+		if e := recent[(nextIdx+i)%len(recent)]; e != nil { kept = append(kept, e) }
+	}
+	if len(kept) > n { kept = kept[len(kept)-n:] }
+	recent = make([]*entry, n)
+	copy(recent, kept)
+	nextIdx = len(kept) % n
+}
+
+// BufferSize returns the capacity of the recent-entries ring.
+func BufferSize() int { bufMu.RLock(); defer bufMu.RUnlock(); return len(recent) }
+
 // Recent returns up to n most recent log entries (newest-first).
 func Recent(n int) []*entry {
 	bufMu.RLock(); defer bufMu.RUnlock()
@@ -37,3 +37,38 @@ func TestSubscribeAndPersistHook(t *testing.T){
 		t.Fatalf("no log received via subscription")
 	}
 }
+
+func TestComponentLevelOverride(t *testing.T){
+	SetLevel("info")
+	defer SetComponentLevel("gorm_sql", "")
+	defer SetComponentLevel("object.upload", "")
+	SetComponentLevel("gorm_sql", "warn")
+	SetComponentLevel("object.upload", "debug")
+	l := New("test").(*stdLogger)
+	l.Debug("gorm_sql", "table", "users")
+	l.Debug("uploaded", "component", "object.upload", "key", "k1")
+	l.Debug("plain-debug")
+	items := Recent(5)
+	seen := map[string]bool{}
+	for _, e := range items { seen[e.Msg] = true }
+	if seen["gorm_sql"] || seen["plain-debug"] { t.Fatalf("debug entries should be filtered: %v", seen) }
+	if !seen["uploaded"] { t.Fatalf("component debug override not applied") }
+	if lv := GetComponentLevels(); lv["gorm_sql"] != "warn" || lv["object.upload"] != "debug" { t.Fatalf("unexpected levels %v", lv) }
+	SetComponentLevel("gorm_sql", "")
+	if _, ok := GetComponentLevels()["gorm_sql"]; ok { t.Fatalf("override not removed") }
+}
+
+func TestSetBufferSize(t *testing.T){
+	defer SetBufferSize(BufferSize())
+	SetLevel("info")
+	l := New("test").(*stdLogger)
+	SetBufferSize(3)
+	for _, m := range []string{"b1", "b2", "b3", "b4"} { l.Info(m) }
+	SetBufferSize(2)
+	if BufferSize() != 2 { t.Fatalf("expected size 2, got %d", BufferSize()) }
+	items := Recent(0)
+	if len(items) != 2 || items[0].Msg != "b4" || items[1].Msg != "b3" { t.Fatalf("expected newest entries kept, got %v", items) }
+	SetBufferSize(5)
+	l.Info("b5")
+	if items := Recent(0); len(items) != 3 || items[0].Msg != "b5" || items[2].Msg != "b3" { t.Fatalf("unexpected entries after grow: %v", items) }
+}
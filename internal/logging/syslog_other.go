@@ -0,0 +1,10 @@
+//go:build windows || plan9
+
+package logging
+
+// syslog is not available on this platform; LOG_SYSLOG_* settings are ignored.
+type syslogSink struct{}
+
+func newSyslogSink(network, addr, tag string) *syslogSink { return nil }
+
+func (s *syslogSink) write(level, line string) {}
@@ -0,0 +1,110 @@
+//go:build !windows && !plan9
+
+package logging
+
+import (
+	"fmt"
+	"log/syslog"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	syslogMinBackoff = time.Second
+	syslogMaxBackoff = time.Minute
+	// syslogTimeout bounds a dial or a write to the daemon
+	syslogTimeout = 5 * time.Second
+	// syslogQueue is how many entries wait for the daemon before new ones are dropped
+	syslogQueue = 1024
+)
+
+type syslogLine struct {
+	prio syslog.Priority
+	line string
+}
+
+// syslogSink forwards JSON log lines to a syslog daemon. Entries are queued and sent by
+// one goroutine, so a slow or unreachable daemon never blocks logging: when the queue is
+// full new entries are dropped. When the connection fails it is dropped and re-dialled
+// with exponential backoff; entries logged while disconnected are not delivered to syslog
+// (stdout still has them).
+type syslogSink struct {
+	network, addr, tag, host string
+	queue                    chan syslogLine
+
+	// owned by run
+	conn      net.Conn
+	backoff   time.Duration
+	nextRetry time.Time
+}
+
+func newSyslogSink(network, addr, tag string) *syslogSink {
+	host, _ := os.Hostname()
+	s := &syslogSink{network: network, addr: addr, tag: tag, host: host, queue: make(chan syslogLine, syslogQueue)}
+	go s.run()
+	return s
+}
+
+// write queues line for the daemon, dropping it when the queue is full.
+func (s *syslogSink) write(level, line string) {
+	prio := syslog.LOG_INFO
+	switch level {
+	case "debug":
+		prio = syslog.LOG_DEBUG
+	case "warn":
+		prio = syslog.LOG_WARNING
+	case "error":
+		prio = syslog.LOG_ERR
+	case "fatal":
+		prio = syslog.LOG_CRIT
+	}
+	select {
+	case s.queue <- syslogLine{prio | syslog.LOG_LOCAL0, line}:
+	default:
+	}
+}
+
+func (s *syslogSink) run() {
+	s.connect()
+	for l := range s.queue {
+		if s.conn == nil && (time.Now().Before(s.nextRetry) || !s.connect()) {
+			continue
+		}
+		// the same framing as log/syslog uses for a remote daemon
+		msg := fmt.Sprintf("<%d>%s %s %s[%d]: %s", l.prio, time.Now().Format(time.RFC3339), s.host, s.tag, os.Getpid(), l.line)
+		if !strings.HasSuffix(msg, "\n") {
+			msg += "\n"
+		}
+		s.conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+		if _, err := s.conn.Write([]byte(msg)); err != nil {
+			s.fail()
+		}
+	}
+}
+
+// connect dials the daemon.
+func (s *syslogSink) connect() bool {
+	c, err := net.DialTimeout(s.network, s.addr, syslogTimeout)
+	if err != nil {
+		s.fail()
+		return false
+	}
+	s.conn, s.backoff = c, 0
+	return true
+}
+
+// fail closes the connection and schedules the next reconnect attempt.
+func (s *syslogSink) fail() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	if s.backoff == 0 {
+		s.backoff = syslogMinBackoff
+	} else if s.backoff *= 2; s.backoff > syslogMaxBackoff {
+		s.backoff = syslogMaxBackoff
+	}
+	s.nextRetry = time.Now().Add(s.backoff)
+}
@@ -0,0 +1,71 @@
+//go:build !windows && !plan9
+
+package logging
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogOutput(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("udp listen: %v", err)
+	}
+	defer pc.Close()
+	t.Setenv("LOG_SYSLOG_NETWORK", "udp")
+	t.Setenv("LOG_SYSLOG_ADDR", pc.LocalAddr().String())
+	t.Setenv("LOG_SYSLOG_TAG", "hermes-test")
+	SetLevel("info")
+	l := New("test")
+	l.Error("syslog-check", "k", "v")
+	pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 4096)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("no syslog packet: %v", err)
+	}
+	pkt := string(buf[:n])
+	// LOG_LOCAL0 (16<<3) | LOG_ERR (3) = 131
+	if !strings.HasPrefix(pkt, "<131>") || !strings.Contains(pkt, "hermes-test") {
+		t.Fatalf("unexpected syslog header: %q", pkt)
+	}
+	var e entry
+	if err := json.Unmarshal([]byte(strings.TrimSpace(pkt[strings.Index(pkt, "{"):])), &e); err != nil {
+		t.Fatalf("payload is not a JSON entry: %q (%v)", pkt, err)
+	}
+	if e.Msg != "syslog-check" || e.Level != "error" || e.Fields["k"] != "v" {
+		t.Fatalf("unexpected entry %+v", e)
+	}
+}
+
+// TestSyslogStalledDaemon checks that a TCP daemon that stops reading does not block logging.
+func TestSyslogStalledDaemon(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("tcp listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err == nil {
+			defer c.Close()
+			time.Sleep(10 * time.Second) // accept, never read
+		}
+	}()
+	t.Setenv("LOG_SYSLOG_NETWORK", "tcp")
+	t.Setenv("LOG_SYSLOG_ADDR", ln.Addr().String())
+	SetLevel("info")
+	l := New("test")
+	pad := strings.Repeat("x", 4096)
+	start := time.Now()
+	for i := 0; i < 4*syslogQueue; i++ {
+		l.Info("stalled-check", "pad", pad)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("logging blocked on a stalled syslog daemon for %v", d)
+	}
+}
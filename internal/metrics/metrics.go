@@ -0,0 +1,164 @@
+// Package metrics is a small in-process metrics registry with counters, gauges and
+// histograms, exported in the Prometheus text format without the client_golang
+// dependency.
+package metrics
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// kind is the metric type as written on the # TYPE line.
+type kind string
+
+const (
+	kindCounter   kind = "counter"
+	kindGauge     kind = "gauge"
+	kindHistogram kind = "histogram"
+)
+
+type metric interface {
+	desc() (name, help string, k kind)
+}
+
+var registry = struct {
+	sync.RWMutex
+	byName map[string]metric
+}{byName: map[string]metric{}}
+
+// register returns the metric already registered under name, or stores m. Registering a
+// name twice with another type panics, as that is a programming error.
+func register[M metric](name string, m M) M {
+	registry.Lock()
+	defer registry.Unlock()
+	if old, ok := registry.byName[name]; ok {
+		prev, same := old.(M)
+		if !same {
+			panic("metrics: " + name + " already registered with another type")
+		}
+		return prev
+	}
+	registry.byName[name] = m
+	return m
+}
+
+// sorted returns the registered metrics ordered by name.
+func sorted() []metric {
+	registry.RLock()
+	defer registry.RUnlock()
+	out := make([]metric, 0, len(registry.byName))
+	for _, m := range registry.byName {
+		out = append(out, m)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, _, _ := out[i].desc()
+		b, _, _ := out[j].desc()
+		return a < b
+	})
+	return out
+}
+
+// Counter is a monotonically increasing count.
+type Counter struct {
+	name, help string
+	v          atomic.Uint64
+}
+
+// Register returns the counter called name, creating it on first use.
+func Register(name, help string) *Counter {
+	return register(name, &Counter{name: name, help: help})
+}
+
+func (c *Counter) desc() (string, string, kind) { return c.name, c.help, kindCounter }
+
+// Inc adds one.
+func (c *Counter) Inc() { c.v.Add(1) }
+
+// Add adds n.
+func (c *Counter) Add(n uint64) { c.v.Add(n) }
+
+// Value returns the current count.
+func (c *Counter) Value() uint64 { return c.v.Load() }
+
+// Gauge is a value that can go up and down. A gauge registered with a function reports
+// the function's result instead.
+type Gauge struct {
+	name, help string
+	bits       atomic.Uint64
+	fn         func() float64
+}
+
+// RegisterGauge returns the gauge called name, creating it on first use.
+func RegisterGauge(name, help string) *Gauge {
+	return register(name, &Gauge{name: name, help: help})
+}
+
+// RegisterGaugeFunc registers a gauge whose value is read from fn at export time.
+func RegisterGaugeFunc(name, help string, fn func() float64) *Gauge {
+	return register(name, &Gauge{name: name, help: help, fn: fn})
+}
+
+func (g *Gauge) desc() (string, string, kind) { return g.name, g.help, kindGauge }
+
+// Set replaces the value.
+func (g *Gauge) Set(v float64) { g.bits.Store(math.Float64bits(v)) }
+
+// Add adds d (which may be negative).
+func (g *Gauge) Add(d float64) { addFloat(&g.bits, d) }
+
+// Value returns the current value.
+func (g *Gauge) Value() float64 {
+	if g.fn != nil {
+		return g.fn()
+	}
+	return math.Float64frombits(g.bits.Load())
+}
+
+// DefBuckets are the default histogram upper bounds, in seconds for latencies.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Histogram counts observations into buckets with fixed upper bounds.
+type Histogram struct {
+	name, help string
+	bounds     []float64       // ascending upper bounds; +Inf is implicit
+	counts     []atomic.Uint64 // per bucket, not cumulative
+	count      atomic.Uint64
+	sumBits    atomic.Uint64
+}
+
+// RegisterHistogram returns the histogram called name, creating it with buckets (sorted
+// upper bounds, DefBuckets when nil) on first use.
+func RegisterHistogram(name, help string, buckets []float64) *Histogram {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	return register(name, &Histogram{name: name, help: help, bounds: b, counts: make([]atomic.Uint64, len(b)+1)})
+}
+
+func (h *Histogram) desc() (string, string, kind) { return h.name, h.help, kindHistogram }
+
+// Observe records v.
+func (h *Histogram) Observe(v float64) {
+	h.counts[sort.SearchFloat64s(h.bounds, v)].Add(1)
+	h.count.Add(1)
+	addFloat(&h.sumBits, v)
+}
+
+// Count returns the number of observations.
+func (h *Histogram) Count() uint64 { return h.count.Load() }
+
+// Sum returns the sum of the observed values.
+func (h *Histogram) Sum() float64 { return math.Float64frombits(h.sumBits.Load()) }
+
+func addFloat(bits *atomic.Uint64, d float64) {
+	for {
+		old := bits.Load()
+		if bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+d)) {
+			return
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCounterAndGauge(t *testing.T) {
+	c := Register("test_counter_total", "A test counter.")
+	c.Inc()
+	c.Add(4)
+	if c.Value() != 5 {
+		t.Fatalf("counter = %d, want 5", c.Value())
+	}
+	if Register("test_counter_total", "ignored") != c {
+		t.Fatal("registering the same name twice should return the existing counter")
+	}
+	g := RegisterGauge("test_gauge", "A test gauge.")
+	g.Set(2.5)
+	g.Add(-1)
+	if g.Value() != 1.5 {
+		t.Fatalf("gauge = %v, want 1.5", g.Value())
+	}
+	f := RegisterGaugeFunc("test_gauge_func", "A gauge read from a function.", func() float64 { return 7 })
+	if f.Value() != 7 {
+		t.Fatalf("gauge func = %v, want 7", f.Value())
+	}
+}
+
+func TestRegisterTypeClash(t *testing.T) {
+	Register("test_clash", "")
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic when re-registering a name with another type")
+		}
+	}()
+	RegisterGauge("test_clash", "")
+}
+
+func TestWriteText(t *testing.T) {
+	Register("test_text_requests_total", "Requests\nseen.").Add(3)
+	h := RegisterHistogram("test_text_latency_seconds", "Latency.", []float64{1, 0.1})
+	for _, v := range []float64{0.05, 0.1, 0.5, 3} {
+		h.Observe(v)
+	}
+	if h.Count() != 4 || h.Sum() != 3.65 {
+		t.Fatalf("count=%d sum=%v", h.Count(), h.Sum())
+	}
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != ContentType {
+		t.Fatalf("content type %q", ct)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# HELP test_text_requests_total Requests\\nseen.\n# TYPE test_text_requests_total counter\ntest_text_requests_total 3\n",
+		"# TYPE test_text_latency_seconds histogram\n",
+		`test_text_latency_seconds_bucket{le="0.1"} 2` + "\n",
+		`test_text_latency_seconds_bucket{le="1"} 3` + "\n",
+		`test_text_latency_seconds_bucket{le="+Inf"} 4` + "\n",
+		"test_text_latency_seconds_sum 3.65\ntest_text_latency_seconds_count 4\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("output missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Index(body, "test_text_latency_seconds") > strings.Index(body, "test_text_requests_total") {
+		t.Error("metrics should be written ordered by name")
+	}
+}
+
+func TestCounterVec(t *testing.T) {
+	v := RegisterCounterVec("test_vec_total", "Labelled.", "method", "path")
+	v.With("GET", "/b").Inc()
+	v.With("GET", "/a").Add(2)
+	v.With("GET", "/a").Inc()
+	v.With("POST", `/q"x\`).Inc()
+	if v.With("GET", "/a").Value() != 3 {
+		t.Fatalf("GET /a = %d, want 3", v.With("GET", "/a").Value())
+	}
+	var b strings.Builder
+	WriteText(&b)
+	want := "# HELP test_vec_total Labelled.\n# TYPE test_vec_total counter\n" +
+		`test_vec_total{method="GET",path="/a"} 3` + "\n" +
+		`test_vec_total{method="GET",path="/b"} 1` + "\n" +
+		`test_vec_total{method="POST",path="/q\"x\\"} 1` + "\n"
+	if !strings.Contains(b.String(), want) {
+		t.Fatalf("output missing\n%s\ngot:\n%s", want, b.String())
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for a wrong number of label values")
+		}
+	}()
+	v.With("GET")
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"bufio"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CounterVec is a family of counters sharing a name and told apart by label values, such
+// as requests by method, path and status.
+type CounterVec struct {
+	name, help string
+	labels     []string
+	mu         sync.RWMutex
+	children   map[string]*labelled
+}
+
+type labelled struct {
+	values []string
+	c      *Counter
+}
+
+// RegisterCounterVec returns the counter family called name with the given label names,
+// creating it on first use.
+func RegisterCounterVec(name, help string, labels ...string) *CounterVec {
+	return register(name, &CounterVec{name: name, help: help, labels: labels, children: map[string]*labelled{}})
+}
+
+func (v *CounterVec) desc() (string, string, kind) { return v.name, v.help, kindCounter }
+
+// With returns the counter for one combination of label values, given in the order of
+// the label names. A wrong number of values panics.
+func (v *CounterVec) With(values ...string) *Counter {
+	if len(values) != len(v.labels) {
+		panic("metrics: " + v.name + " takes " + strings.Join(v.labels, ",") + " labels")
+	}
+	key := strings.Join(values, "\xff")
+	v.mu.RLock()
+	ch, ok := v.children[key]
+	v.mu.RUnlock()
+	if ok {
+		return ch.c
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if ch, ok := v.children[key]; ok {
+		return ch.c
+	}
+	ch = &labelled{values: append([]string(nil), values...), c: &Counter{name: v.name}}
+	v.children[key] = ch
+	return ch.c
+}
+
+// write emits one sample per label combination, ordered by label values.
+func (v *CounterVec) write(bw *bufio.Writer) {
+	v.mu.RLock()
+	keys := make([]string, 0, len(v.children))
+	for k := range v.children {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	children := make([]*labelled, len(keys))
+	for i, k := range keys {
+		children[i] = v.children[k]
+	}
+	v.mu.RUnlock()
+	for _, ch := range children {
+		bw.WriteString(v.name + "{" + labelPairs(v.labels, ch.values) + "} " + formatUint(ch.c.Value()) + "\n")
+	}
+}
+
+// labelPairs renders name="value" pairs, escaping values as the text format requires.
+func labelPairs(names, values []string) string {
+	var b strings.Builder
+	for i, n := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(n + `="`)
+		for j := 0; j < len(values[i]); j++ {
+			switch c := values[i][j]; c {
+			case '\\':
+				b.WriteString(`\\`)
+			case '"':
+				b.WriteString(`\"`)
+			case '\n':
+				b.WriteString(`\n`)
+			default:
+				b.WriteByte(c)
+			}
+		}
+		b.WriteByte('"')
+	}
+	return b.String()
+}
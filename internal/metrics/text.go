@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// ContentType is the media type of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// WriteText writes every registered metric, ordered by name, in the Prometheus text
+// exposition format.
+func WriteText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, m := range sorted() {
+		name, help, k := m.desc()
+		bw.WriteString("# HELP " + name + " " + escapeHelp(help) + "\n")
+		bw.WriteString("# TYPE " + name + " " + string(k) + "\n")
+		switch m := m.(type) {
+		case *Counter:
+			bw.WriteString(name + " " + formatUint(m.Value()) + "\n")
+		case *CounterVec:
+			m.write(bw)
+		case *Gauge:
+			bw.WriteString(name + " " + formatFloat(m.Value()) + "\n")
+		case *Histogram:
+			var cum uint64
+			for i, le := range m.bounds {
+				cum += m.counts[i].Load()
+				bw.WriteString(name + `_bucket{le="` + formatFloat(le) + `"} ` + formatUint(cum) + "\n")
+			}
+			cum += m.counts[len(m.bounds)].Load()
+			bw.WriteString(name + `_bucket{le="+Inf"} ` + formatUint(cum) + "\n")
+			bw.WriteString(name + "_sum " + formatFloat(m.Sum()) + "\n")
+			bw.WriteString(name + "_count " + formatUint(cum) + "\n")
+		}
+	}
+	return bw.Flush()
+}
+
+// Handler serves WriteText.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		WriteText(w)
+	})
+}
+
+func formatUint(v uint64) string { return strconv.FormatUint(v, 10) }
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escapeHelp escapes a HELP text as the format requires: backslash and newline.
+func escapeHelp(s string) string {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			out = append(out, '\\', '\\')
+		case '\n':
+			out = append(out, '\\', 'n')
+		default:
+			out = append(out, s[i])
+		}
+	}
+	return string(out)
+}
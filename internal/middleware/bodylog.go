@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/arencloud/hermes/internal/logging"
+)
+
+// DefaultRedactFields are the body keys masked by BodyLogger when none are configured.
+var DefaultRedactFields = []string{"password", "secret", "token", "apikey"}
+
+// MaxLoggedBodyBytes is the largest request body BodyLogger will log; bigger bodies are skipped.
+var MaxLoggedBodyBytes int64 = 4096
+
+// BodyLogger logs request bodies at debug level (field requestBody) for debugging API
+// misuse. JSON keys containing any of redactFields (case-insensitive) are masked, non-JSON
+// bodies are only reported by size, and multipart uploads are never read. The handler
+// still receives the original, unmodified body.
+func BodyLogger(logger logging.Logger, redactFields []string) func(http.Handler) http.Handler {
+	if len(redactFields) == 0 {
+		redactFields = DefaultRedactFields
+	}
+	redact := make([]string, 0, len(redactFields))
+	for _, f := range redactFields {
+		if f = strings.ToLower(strings.TrimSpace(f)); f != "" {
+			redact = append(redact, f)
+		}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body == nil || r.Body == http.NoBody || strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") || strings.HasSuffix(r.URL.Path, "/upload") || strings.Contains(r.URL.Path, "/upload/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			buf, err := io.ReadAll(io.LimitReader(r.Body, MaxLoggedBodyBytes+1))
+			// hand the handler the bytes already consumed followed by the rest of the stream
+			r.Body = readCloser{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+			if err == nil && len(buf) > 0 && int64(len(buf)) <= MaxLoggedBodyBytes {
+				logger.Debug("request body", "method", r.Method, "path", r.URL.Path, "requestBody", redactBody(buf, redact))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func redactBody(b []byte, fields []string) string {
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return "[non-JSON body, " + strconv.Itoa(len(b)) + " bytes]"
+	}
+	out, _ := json.Marshal(redactValue(v, fields))
+	return string(out)
+}
+
+func redactValue(v any, fields []string) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, val := range t {
+			if sensitiveKey(k, fields) {
+				t[k] = "[REDACTED]"
+				continue
+			}
+			t[k] = redactValue(val, fields)
+		}
+	case []any:
+		for i := range t {
+			t[i] = redactValue(t[i], fields)
+		}
+	}
+	return v
+}
+
+func sensitiveKey(k string, fields []string) bool {
+	k = strings.ToLower(k)
+	for _, f := range fields {
+		if strings.Contains(k, f) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type cacheEntry struct {
+	body        []byte
+	contentType string
+	etag        string
+	expires     time.Time
+}
+
+// responseCache is shared by every Cache middleware so handlers can drop entries with
+// InvalidateCache; keys should therefore start with a per-endpoint prefix.
+var responseCache sync.Map // key -> cacheEntry
+
+// Cache serves repeated GETs from memory for ttl. Entries are keyed by keyFn(r) and hold
+// the body and Content-Type of a 200 response; every response carries an ETag and
+// Cache-Control: private, max-age=ttl, and a matching If-None-Match is answered 304.
+// Non-200 responses pass through uncached.
+func Cache(ttl time.Duration, keyFn func(*http.Request) string) func(http.Handler) http.Handler {
+	maxAge := "private, max-age=" + strconv.Itoa(int(ttl.Seconds()))
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				next.ServeHTTP(w, r)
+				return
+			}
+			key := keyFn(r)
+			now := time.Now()
+			if v, ok := responseCache.Load(key); ok {
+				if e := v.(cacheEntry); now.Before(e.expires) {
+					writeCached(w, r, e, maxAge)
+					return
+				}
+				responseCache.Delete(key)
+			}
+			cw := &cacheWriter{ResponseWriter: w, header: make(http.Header), code: http.StatusOK}
+			next.ServeHTTP(cw, r)
+			if cw.code != http.StatusOK {
+				cw.flush()
+				return
+			}
+			sum := sha256.Sum256(cw.buf.Bytes())
+			e := cacheEntry{
+				body:        cw.buf.Bytes(),
+				contentType: cw.header.Get("Content-Type"),
+				etag:        `"` + hex.EncodeToString(sum[:16]) + `"`,
+				expires:     now.Add(ttl),
+			}
+			responseCache.Store(key, e)
+			sweepCache(now)
+			copyHeader(w.Header(), cw.header)
+			writeCached(w, r, e, maxAge)
+		})
+	}
+}
+
+// InvalidateCache drops every cached response whose key starts with prefix.
+func InvalidateCache(prefix string) {
+	responseCache.Range(func(k, _ any) bool {
+		if strings.HasPrefix(k.(string), prefix) {
+			responseCache.Delete(k)
+		}
+		return true
+	})
+}
+
+var lastSweep struct {
+	sync.Mutex
+	at time.Time
+}
+
+// sweepCache removes expired entries at most once a minute, so keys that are never
+// requested again do not accumulate.
+func sweepCache(now time.Time) {
+	lastSweep.Lock()
+	if now.Sub(lastSweep.at) < time.Minute {
+		lastSweep.Unlock()
+		return
+	}
+	lastSweep.at = now
+	lastSweep.Unlock()
+	responseCache.Range(func(k, v any) bool {
+		if now.After(v.(cacheEntry).expires) {
+			responseCache.Delete(k)
+		}
+		return true
+	})
+}
+
+func writeCached(w http.ResponseWriter, r *http.Request, e cacheEntry, maxAge string) {
+	h := w.Header()
+	h.Set("Cache-Control", maxAge)
+	h.Set("ETag", e.etag)
+	if etagMatches(r.Header.Get("If-None-Match"), e.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if e.contentType != "" {
+		h.Set("Content-Type", e.contentType)
+	}
+	w.Write(e.body)
+}
+
+func etagMatches(header, etag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == "*" || t == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func copyHeader(dst, src http.Header) {
+	for k, v := range src {
+		dst[k] = v
+	}
+}
+
+// cacheWriter buffers the handler's response so its ETag can be sent ahead of the body.
+type cacheWriter struct {
+	http.ResponseWriter
+	header http.Header
+	code   int
+	buf    bytes.Buffer
+}
+
+func (cw *cacheWriter) Header() http.Header { return cw.header }
+
+func (cw *cacheWriter) WriteHeader(code int) { cw.code = code }
+
+func (cw *cacheWriter) Write(b []byte) (int, error) { return cw.buf.Write(b) }
+
+// flush sends an uncached response as the handler wrote it.
+func (cw *cacheWriter) flush() {
+	copyHeader(cw.ResponseWriter.Header(), cw.header)
+	cw.ResponseWriter.WriteHeader(cw.code)
+	cw.ResponseWriter.Write(cw.buf.Bytes())
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCacheServesHitsAndRevalidates(t *testing.T) {
+	calls := 0
+	h := Cache(time.Minute, func(r *http.Request) string { return "test|" + r.URL.RequestURI() })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"n":` + strconv.Itoa(calls) + `}`))
+	}))
+	t.Cleanup(func() { InvalidateCache("test|") })
+	get := func(inm string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/things", nil)
+		if inm != "" {
+			req.Header.Set("If-None-Match", inm)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	first := get("")
+	etag := first.Header().Get("ETag")
+	if first.Code != 200 || first.Body.String() != `{"n":1}` || etag == "" || first.Header().Get("Cache-Control") != "private, max-age=60" {
+		t.Fatalf("miss: %d %q headers=%v", first.Code, first.Body.String(), first.Header())
+	}
+	second := get("")
+	if second.Body.String() != `{"n":1}` || second.Header().Get("ETag") != etag || second.Header().Get("Content-Type") != "application/json" || calls != 1 {
+		t.Fatalf("hit should be served from cache: %q calls=%d", second.Body.String(), calls)
+	}
+	if rec := get(etag); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Fatalf("expected 304, got %d %q", rec.Code, rec.Body.String())
+	}
+	InvalidateCache("test|")
+	if rec := get(""); rec.Body.String() != `{"n":2}` {
+		t.Fatalf("invalidated entry still served: %q", rec.Body.String())
+	}
+}
+
+func TestCacheSkipsErrors(t *testing.T) {
+	calls := 0
+	h := Cache(time.Minute, func(r *http.Request) string { return "test-err|" })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	t.Cleanup(func() { InvalidateCache("test-err|") })
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Code != 500 || rec.Header().Get("ETag") != "" {
+			t.Fatalf("error response altered: %d %v", rec.Code, rec.Header())
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("error responses must not be cached, handler ran %d times", calls)
+	}
+}
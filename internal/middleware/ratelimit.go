@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimiter keeps one token bucket per client key: each holds up to burst tokens and
+// refills at rps tokens per second, and every request takes one.
+type RateLimiter struct {
+	rps     float64
+	burst   float64
+	buckets sync.Map // key -> *tokenBucket
+}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time // last refill, i.e. last request
+}
+
+// NewRateLimiter returns a limiter allowing rps requests per second per key, with bursts
+// of up to burst requests (at least 1).
+func NewRateLimiter(rps float64, burst int) *RateLimiter {
+	return &RateLimiter{rps: rps, burst: math.Max(float64(burst), 1)}
+}
+
+// Allow takes a token from key's bucket. When none is left it returns false and how long
+// until the next token is available.
+func (l *RateLimiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	v, ok := l.buckets.Load(key)
+	if !ok {
+		v, _ = l.buckets.LoadOrStore(key, &tokenBucket{tokens: l.burst, last: now})
+	}
+	b := v.(*tokenBucket)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(l.burst, b.tokens+elapsed*l.rps)
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.rps * float64(time.Second))
+}
+
+// Evict drops the buckets not used since before; a client returning afterwards starts
+// with a full bucket, which it would have refilled to by then anyway when idle long enough.
+func (l *RateLimiter) Evict(before time.Time) {
+	l.buckets.Range(func(k, v any) bool {
+		b := v.(*tokenBucket)
+		b.mu.Lock()
+		stale := b.last.Before(before)
+		b.mu.Unlock()
+		if stale {
+			l.buckets.Delete(k)
+		}
+		return true
+	})
+}
+
+// Len returns the number of tracked clients.
+func (l *RateLimiter) Len() int {
+	n := 0
+	l.buckets.Range(func(any, any) bool { n++; return true })
+	return n
+}
+
+// Middleware sets Retry-After (whole seconds, rounded up) and hands the request to reject
+// once the client identified by keyFn(r) runs out of tokens. A nil reject answers a plain
+// 429.
+func (l *RateLimiter) Middleware(keyFn func(*http.Request) string, reject http.HandlerFunc) func(http.Handler) http.Handler {
+	if reject == nil {
+		reject = func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+		}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ok, wait := l.Allow(keyFn(r), time.Now())
+			if !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				reject(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterTokenBucket(t *testing.T) {
+	l := NewRateLimiter(2, 3)
+	now := time.Unix(1000, 0)
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("a", now); !ok {
+			t.Fatalf("request %d within burst refused", i+1)
+		}
+	}
+	ok, wait := l.Allow("a", now)
+	if ok || wait != 500*time.Millisecond {
+		t.Fatalf("over burst: ok=%v wait=%v, want refused for 500ms", ok, wait)
+	}
+	if ok, _ := l.Allow("b", now); !ok {
+		t.Fatal("another key must have its own bucket")
+	}
+	// half a second refills one token at 2/s
+	if ok, _ := l.Allow("a", now.Add(500*time.Millisecond)); !ok {
+		t.Fatal("refilled token refused")
+	}
+	if ok, _ := l.Allow("a", now.Add(500*time.Millisecond)); ok {
+		t.Fatal("bucket should be empty again")
+	}
+	// never more than burst after a long pause
+	later := now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		l.Allow("a", later)
+	}
+	if ok, _ := l.Allow("a", later); ok {
+		t.Fatal("bucket refilled beyond burst")
+	}
+}
+
+func TestRateLimiterEvict(t *testing.T) {
+	l := NewRateLimiter(1, 1)
+	now := time.Unix(1000, 0)
+	l.Allow("old", now)
+	l.Allow("new", now.Add(10*time.Minute))
+	l.Evict(now.Add(5 * time.Minute))
+	if l.Len() != 1 {
+		t.Fatalf("%d buckets after evicting the stale one, want 1", l.Len())
+	}
+	if ok, _ := l.Allow("new", now.Add(10*time.Minute)); ok {
+		t.Fatal("the recent bucket should have been kept, still empty")
+	}
+}
+
+func TestRateLimiterMiddleware(t *testing.T) {
+	l := NewRateLimiter(1, 1)
+	h := l.Middleware(func(r *http.Request) string { return r.Header.Get("X-Client") }, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(204)
+	}))
+	get := func(client string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Client", client)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := get("a"); rec.Code != 204 {
+		t.Fatalf("first request: %d", rec.Code)
+	}
+	rec := get("a")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("second request: %d Retry-After=%q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if rec := get("b"); rec.Code != 204 {
+		t.Fatalf("other client: %d", rec.Code)
+	}
+}
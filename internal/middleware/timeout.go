@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type deadlineKey struct{}
+
+// Timeout bounds a request to d. The handler runs with a context that is cancelled at
+// the deadline; if it has not written anything by then the client receives 504.
+// A handler that already started its response is left to finish on its own, since
+// the status line can no longer be changed. Either way Timeout returns only once the
+// handler has, so that middleware further out never shares request state with it.
+// NoTimeout and LiftTimeout exempt routes that manage their own deadlines.
+func Timeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithCancelCause(r.Context())
+			defer cancel(nil)
+			deadline := time.AfterFunc(d, func() { cancel(context.DeadlineExceeded) })
+			defer deadline.Stop()
+			ctx = context.WithValue(ctx, deadlineKey{}, deadline)
+			tw := &timeoutWriter{w: w, h: make(http.Header)}
+			done := make(chan struct{})
+			panicked := make(chan any, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicked <- p
+					}
+				}()
+				next.ServeHTTP(tw, r.WithContext(ctx))
+				close(done)
+			}()
+			select {
+			case <-done:
+			case p := <-panicked:
+				panic(p) // re-raise on the serving goroutine so Recoverer sees it
+			case <-ctx.Done():
+				tw.mu.Lock()
+				// a response already in flight is left alone rather than corrupted
+				if !tw.written.Load() {
+					tw.timedOut = true
+					if context.Cause(ctx) == context.DeadlineExceeded {
+						http.Error(w, "request timeout", http.StatusGatewayTimeout)
+					}
+				}
+				tw.mu.Unlock()
+				// the handler sees the cancelled context; wait for it so that the trace and
+				// logs it still writes to are not read concurrently by the caller
+				select {
+				case <-done:
+				case p := <-panicked:
+					panic(p)
+				}
+			}
+		})
+	}
+}
+
+// LiftTimeout removes the deadline an enclosing Timeout put on r, for a handler that
+// turns out to be long-running. It reports false when there is no deadline to lift
+// or it has already passed.
+func LiftTimeout(r *http.Request) bool {
+	deadline, ok := r.Context().Value(deadlineKey{}).(*time.Timer)
+	return ok && deadline.Stop()
+}
+
+// NoTimeout exempts the routes it is applied to from an enclosing Timeout.
+func NoTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		LiftTimeout(r)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// timeoutWriter passes writes straight through (no buffering, so streaming still
+// works) and records whether the response has started.
+type timeoutWriter struct {
+	w        http.ResponseWriter
+	h        http.Header
+	mu       sync.Mutex
+	written  atomic.Bool
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header { return tw.h }
+
+// start copies the handler's headers to the real writer; callers hold mu.
+func (tw *timeoutWriter) start() {
+	if tw.written.Load() {
+		return
+	}
+	dst := tw.w.Header()
+	for k, v := range tw.h {
+		dst[k] = v
+	}
+	tw.written.Store(true)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.written.Load() {
+		return
+	}
+	tw.start()
+	tw.w.WriteHeader(code)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.start()
+	return tw.w.Write(b)
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	if f, ok := tw.w.(http.Flusher); ok {
+		tw.start()
+		f.Flush()
+	}
+}
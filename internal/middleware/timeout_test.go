@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeoutReturns504(t *testing.T) {
+	h := Timeout(50 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+			w.Write([]byte("late"))
+		case <-r.Context().Done():
+		}
+	}))
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/slow", nil))
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status=%d want 504", rec.Code)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("timeout took too long: %v", time.Since(start))
+	}
+}
+
+func TestTimeoutKeepsStartedResponse(t *testing.T) {
+	h := Timeout(50 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Test", "1")
+		w.WriteHeader(200)
+		w.Write([]byte("partial"))
+		<-r.Context().Done()
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/stream", nil))
+	if rec.Code != 200 || rec.Body.String() != "partial" || rec.Header().Get("X-Test") != "1" {
+		t.Fatalf("unexpected response code=%d body=%q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestTimeoutFastHandler(t *testing.T) {
+	h := Timeout(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(201)
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/", nil))
+	if rec.Code != 201 {
+		t.Fatalf("status=%d want 201", rec.Code)
+	}
+}
+
+func TestNoTimeout(t *testing.T) {
+	h := Timeout(50 * time.Millisecond)(NoTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(150 * time.Millisecond):
+			w.Write([]byte("done"))
+		case <-r.Context().Done():
+		}
+	})))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/long", nil))
+	if rec.Code != 200 || rec.Body.String() != "done" {
+		t.Fatalf("status=%d body=%q want 200 done", rec.Code, rec.Body.String())
+	}
+}
+
+func TestLiftTimeoutAfterDeadline(t *testing.T) {
+	lifted := make(chan bool, 1)
+	h := Timeout(10 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		lifted <- LiftTimeout(r)
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/slow", nil))
+	if rec.Code != http.StatusGatewayTimeout || <-lifted {
+		t.Fatalf("status=%d, a passed deadline was lifted", rec.Code)
+	}
+	if LiftTimeout(httptest.NewRequest("GET", "/", nil)) {
+		t.Fatal("lifted a deadline outside Timeout")
+	}
+}
+
+// TestTimeoutWaitsForHandler checks that a timed-out handler has returned before Timeout
+// does; run with -race, the unsynchronised finished flag catches a handler left running.
+func TestTimeoutWaitsForHandler(t *testing.T) {
+	finished := false
+	h := Timeout(10 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		time.Sleep(20 * time.Millisecond) // cleanup after the deadline, e.g. recording events
+		finished = true
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/slow", nil))
+	if rec.Code != http.StatusGatewayTimeout || !finished {
+		t.Fatalf("status=%d finished=%v, want 504 after the handler returned", rec.Code, finished)
+	}
+}
@@ -6,10 +6,37 @@ import "time"
 // Unique per (ProviderID, Name)
 // We only store minimal info to keep schema simple and robust across vendors.
 type Bucket struct {
-	ID         uint      `gorm:"primaryKey" json:"id"`
-	ProviderID uint      `gorm:"index;not null" json:"providerId"`
-	Name       string    `gorm:"not null" json:"name"`
-	Region     string    `json:"region"`
-	CreatedAt  time.Time `json:"createdAt"`
-	UpdatedAt  time.Time `json:"updatedAt"`
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	ProviderID uint   `gorm:"index;not null" json:"providerId"`
+	Name       string `gorm:"not null" json:"name"`
+	Region     string `json:"region"`
+	TenantID   string `gorm:"index;default:''" json:"tenantId"`
+	// MaxObjectSizeBytes caps uploads and copies into the bucket; 0 = only the global limit applies.
+	MaxObjectSizeBytes int64     `json:"maxObjectSizeBytes"`
+	CreatedAt          time.Time `json:"createdAt"`
+	UpdatedAt          time.Time `json:"updatedAt"`
+}
+
+// BucketSummary caches a bucket's object count and total size. It is recomputed from a
+// full listing nightly and, with INCREMENTAL_STATS, adjusted as objects change through Hermes.
+type BucketSummary struct {
+	ProviderID     uint      `gorm:"primaryKey;autoIncrement:false" json:"providerId"`
+	Bucket         string    `gorm:"primaryKey" json:"bucket"`
+	ObjectCount    int64     `json:"objectCount"`
+	TotalBytes     int64     `json:"totalBytes"`
+	LastModified   time.Time `json:"lastModified"` // newest object modification
+	LastComputedAt time.Time `json:"lastComputedAt"`
+}
+
+// ObjectIndex is a searchable copy of a bucket listing, one row per object. The periodic
+// reindex job keeps it in step with the provider, including changes made outside Hermes.
+type ObjectIndex struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	ProviderID   uint      `gorm:"uniqueIndex:idx_object_index_key;not null" json:"providerId"`
+	Bucket       string    `gorm:"uniqueIndex:idx_object_index_key;not null" json:"bucket"`
+	Key          string    `gorm:"uniqueIndex:idx_object_index_key;not null" json:"key"`
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag"`
+	LastModified time.Time `json:"lastModified"`
+	IndexedAt    time.Time `gorm:"index" json:"indexedAt"`
 }
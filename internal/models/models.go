@@ -1,6 +1,9 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 )
 
@@ -10,10 +13,24 @@ type User struct {
 	Password            string    `json:"-"`
 	Role                string    `json:"role"`
 	MustChangePassword  bool      `json:"mustChangePassword"`
+	TenantID            string    `gorm:"index;default:''" json:"tenantId"`
+	LastLoginAt         *time.Time `json:"lastLoginAt"`
+	LastLoginIP         string    `json:"lastLoginIp,omitempty"`
 	CreatedAt           time.Time `json:"createdAt"`
 	UpdatedAt           time.Time `json:"updatedAt"`
 }
 
+// LoginAttempt records one password login, successful or not. UserID is 0 when the
+// email matched no user.
+type LoginAttempt struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"index" json:"userId"`
+	Email     string    `gorm:"index" json:"email"`
+	Success   bool      `json:"success"`
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
 type Provider struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	Name      string    `json:"name"`
@@ -21,12 +38,63 @@ type Provider struct {
 	Endpoint  string    `json:"endpoint"`
 	AccessKey string    `json:"accessKey"`
 	SecretKey string    `json:"secretKey"`
+	// SecretRef names the secret key in the configured secrets backend (env var name or
+	// Vault path[#field]); when set it is resolved at connect time and SecretKey is ignored
+	SecretRef string    `json:"secretRef"`
 	Region    string    `json:"region"`
 	UseSSL    bool      `json:"useSSL"`
+	CACertPEM string    `json:"-"` // PEM CA bundle trusted for the endpoint; managed via /providers/{id}/ca-cert
+	// SecondaryEndpoint takes over (same credentials) when the active endpoint fails and FailoverEnabled is set
+	SecondaryEndpoint string `json:"secondaryEndpoint"`
+	FailoverEnabled   bool   `json:"failoverEnabled"`
+	// Transfer quotas per QuotaPeriod (daily|monthly, default monthly); 0 = unlimited
+	UploadQuotaBytes   int64  `json:"uploadQuotaBytes"`
+	DownloadQuotaBytes int64  `json:"downloadQuotaBytes"`
+	QuotaPeriod        string `json:"quotaPeriod"`
+	// PathStyle selects bucket addressing: auto (by provider type), path or virtual
+	PathStyle string `gorm:"default:'auto'" json:"pathStyle"`
+	// MaxRetries is how often a list, upload or download failing with a transient error is retried
+	MaxRetries int       `gorm:"default:3" json:"maxRetries"`
+	// Tags group providers for filtering, e.g. ["prod","aws","us-east"]; stored as a JSON array
+	Tags      StringList `gorm:"type:text" json:"tags"`
+	TenantID  string    `gorm:"index;default:''" json:"tenantId"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
 
+// StringList is a list of strings stored as a JSON array in a text column.
+type StringList []string
+
+// Value implements driver.Valuer; an empty list is stored as [].
+func (l StringList) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return "[]", nil
+	}
+	b, err := json.Marshal([]string(l))
+	return string(b), err
+}
+
+// Scan implements sql.Scanner; NULL and "" read as an empty list.
+func (l *StringList) Scan(src any) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		return fmt.Errorf("StringList: cannot scan %T", src)
+	}
+	if len(b) == 0 {
+		*l = nil
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(l))
+}
+
 type AuthConfig struct {
 	ID               uint      `gorm:"primaryKey" json:"id"`
 	Mode             string    `json:"mode"` // local|oidc|saml
@@ -65,6 +133,7 @@ type LogEntry struct {
 	Level  string    `json:"level"`
 	Msg    string    `json:"msg"`
 	Fields string    `json:"fields"` // JSON string of fields
+	TenantID string  `gorm:"index;default:''" json:"tenantId"`
 }
 
 type TraceRow struct {
@@ -78,9 +147,12 @@ type TraceRow struct {
 	RemoteIP  string    `json:"remoteIp"`
 	ReqBytes  int64     `json:"reqBytes"`
 	RespBytes int64     `json:"respBytes"`
-	Started   time.Time `json:"started"`
+	Started   time.Time `gorm:"index" json:"started"`
 	Ended     time.Time `json:"ended"`
 	DurationNs int64    `json:"durationNs"`
+	TenantID  string    `gorm:"index;default:''" json:"tenantId"`
+	SpanID    string    `json:"spanId"`
+	ParentTraceID string `gorm:"index" json:"parentTraceId"` // caller's W3C trace ID, if any
 }
 
 type TraceEventRow struct {
@@ -90,3 +162,188 @@ type TraceEventRow struct {
 	Name    string    `json:"name"`
 	Fields  string    `json:"fields"` // JSON string of fields
 }
+
+// MetricPoint is a sampled metric value. Minute points are rolled up into hour
+// and day points by the rollup job so the table stays small over time.
+type MetricPoint struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"index:idx_metric_series" json:"name"`
+	Labels      string    `gorm:"index:idx_metric_series" json:"labels"` // JSON string of labels
+	Timestamp   time.Time `gorm:"index" json:"timestamp"`
+	Granularity string    `gorm:"index" json:"granularity"` // minute|hour|day
+	Count       int64     `json:"count"`
+	Sum         float64   `json:"sum"`
+	Min         float64   `json:"min"`
+	Max         float64   `json:"max"`
+	Avg         float64   `json:"avg"`
+}
+
+// ObjectTrashItem records an object moved to the trash bucket instead of being deleted.
+// Key is the object's key inside the trash bucket; OriginalKey is where it is restored to.
+type ObjectTrashItem struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	ProviderID     uint      `gorm:"index;not null" json:"providerId"`
+	Bucket         string    `json:"bucket"`
+	Key            string    `json:"key"`
+	OriginalKey    string    `json:"originalKey"`
+	Size           int64     `json:"size"`
+	DeletedByEmail string    `json:"deletedByEmail"`
+	DeletedAt      time.Time `json:"deletedAt"`
+	ExpiresAt      time.Time `gorm:"index" json:"expiresAt"`
+	// Restored marks an item restored while its trash copy could not be removed; it is
+	// no longer listed and the next purge removes the copy.
+	Restored bool `gorm:"default:false" json:"-"`
+}
+
+// BucketACL grants a subject access to a single bucket. Subject is a user email or
+// "role:<role>"; Permission is read or write (write implies read). A bucket without
+// any entries falls back to plain role-based access.
+type BucketACL struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ProviderID uint      `gorm:"index:idx_bucket_acl;not null" json:"providerId"`
+	Bucket     string    `gorm:"index:idx_bucket_acl;not null" json:"bucket"`
+	Subject    string    `gorm:"not null" json:"subject"`
+	Permission string    `gorm:"not null" json:"permission"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// ObjectStat counts downloads of a single object; one row per provider/bucket/key.
+type ObjectStat struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	ProviderID       uint      `gorm:"uniqueIndex:idx_object_stat;not null" json:"providerId"`
+	Bucket           string    `gorm:"uniqueIndex:idx_object_stat;not null" json:"bucket"`
+	Key              string    `gorm:"uniqueIndex:idx_object_stat;not null" json:"key"`
+	DownloadCount    int64     `gorm:"index" json:"downloadCount"`
+	TotalBytesServed int64     `gorm:"index" json:"totalBytesServed"`
+	LastDownloadedAt time.Time `json:"lastDownloadedAt"`
+}
+
+// UserPreference stores a user's UI settings as an opaque JSON object.
+type UserPreference struct {
+	UserID    uint      `gorm:"primaryKey" json:"userId"`
+	Prefs     string    `json:"prefs"` // JSON object
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// BucketTemplate is a reusable set of settings applied to a bucket right after creation.
+// LifecycleRulesJSON is a JSON array of S3 lifecycle rules; ACLsJSON a JSON array of
+// {subject, permission} entries turned into BucketACL rows.
+type BucketTemplate struct {
+	ID                  uint      `gorm:"primaryKey" json:"id"`
+	Name                string    `gorm:"uniqueIndex;not null" json:"name"`
+	VersioningEnabled   bool      `json:"versioningEnabled"`
+	LifecycleRulesJSON  string    `json:"lifecycleRulesJson"`
+	DefaultStorageClass string    `json:"defaultStorageClass"`
+	ACLsJSON            string    `json:"aclsJson"`
+	CreatedAt           time.Time `json:"createdAt"`
+	UpdatedAt           time.Time `json:"updatedAt"`
+}
+
+// TieringRecommendation records a storage class migration applied from the tiering
+// recommendations; Status is applied or failed (with Error).
+type TieringRecommendation struct {
+	ID                           uint      `gorm:"primaryKey" json:"id"`
+	ProviderID                   uint      `gorm:"index:idx_tiering_bucket;not null" json:"providerId"`
+	Bucket                       string    `gorm:"index:idx_tiering_bucket;not null" json:"bucket"`
+	Key                          string    `gorm:"not null" json:"key"`
+	FromClass                    string    `json:"fromClass"`
+	ToClass                      string    `json:"toClass"`
+	EstimatedMonthlySavingsCents int64     `json:"estimatedMonthlySavingsCents"`
+	Status                       string    `json:"status"`
+	Error                        string    `json:"error,omitempty"`
+	AppliedBy                    string    `json:"appliedBy"`
+	CreatedAt                    time.Time `json:"createdAt"`
+}
+
+// ProviderQuota is the bytes transferred through a provider in the current quota period.
+type ProviderQuota struct {
+	ProviderID      uint      `gorm:"primaryKey" json:"providerId"`
+	UploadedBytes   int64     `json:"uploadedBytes"`
+	DownloadedBytes int64     `json:"downloadedBytes"`
+	PeriodStart     time.Time `json:"periodStart"`
+	PeriodType      string    `json:"periodType"` // daily|monthly
+}
+
+// ProviderHealth tracks the endpoint currently serving a provider, its failovers and the
+// outcome of the periodic connectivity check.
+type ProviderHealth struct {
+	ProviderID          uint       `gorm:"primaryKey" json:"providerId"`
+	CurrentEndpoint     string     `json:"currentEndpoint"`
+	FailoverCount       int64      `json:"failoverCount"`
+	LastFailoverAt      *time.Time `json:"lastFailoverAt"`
+	LastError           string     `json:"lastError"`
+	LastCheckedAt       *time.Time `json:"lastCheckedAt"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	AlertSentAt         *time.Time `json:"alertSentAt"` // set while a provider.unreachable alert is outstanding
+	UpdatedAt           time.Time  `json:"updatedAt"`
+}
+
+// InProgressMove journals an object move so one interrupted between copying the object
+// and deleting the source can be finished at startup. ProviderID is the source provider.
+type InProgressMove struct {
+	ID         string     `gorm:"primaryKey" json:"id"`
+	ProviderID uint       `json:"providerId"`
+	SrcBucket  string     `json:"srcBucket"`
+	SrcKey     string     `json:"srcKey"`
+	DstBucket  string     `json:"dstBucket"`
+	DstKey     string     `json:"dstKey"`
+	CopiedAt   *time.Time `gorm:"index" json:"copiedAt"`
+	DeletedAt  *time.Time `gorm:"index" json:"deletedAt"`
+	UserEmail  string     `json:"userEmail"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// Migration records a schema migration applied by db.Migrate.
+type Migration struct {
+	Version     string    `gorm:"primaryKey" json:"version"`
+	Description string    `json:"description"`
+	AppliedAt   time.Time `json:"appliedAt"`
+}
+
+// Session is a signed-in browser session; the dsess cookie carries its ID. Rows past
+// ExpiresAt are ignored and pruned periodically.
+type Session struct {
+	ID        string    `gorm:"primaryKey;size:64"`
+	UserID    uint      `gorm:"index;not null"`
+	CreatedAt time.Time
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+// APIKey lets a user authenticate scripts with "Authorization: Bearer <key>". Only a
+// bcrypt hash of the key's secret part is stored; the key itself is shown once.
+type APIKey struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"userId"`
+	KeyHash    string     `gorm:"not null" json:"-"`
+	Label      string     `json:"label"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	ExpiresAt  *time.Time `json:"expiresAt"` // nil = never
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// AuditLog is a durable record of a security-sensitive action: who did what to which
+// resource. Detail holds action-specific fields as JSON; secrets are never included.
+type AuditLog struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Time         time.Time `gorm:"index;not null" json:"time"`
+	ActorEmail   string    `gorm:"index" json:"actorEmail"`
+	ActorRole    string    `json:"actorRole"`
+	Action       string    `gorm:"index;not null" json:"action"`
+	ResourceType string    `json:"resourceType"`
+	ResourceID   string    `json:"resourceId"`
+	Detail       string    `json:"detail"`
+	RemoteIP     string    `json:"remoteIp"`
+	TenantID     string    `gorm:"index" json:"tenantId"`
+}
+
+// ProviderAccessToken records a read-only provider access token so it can be revoked
+// before it expires. Permissions is a comma-separated list.
+type ProviderAccessToken struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	ProviderID  uint       `gorm:"index;not null" json:"providerId"`
+	Permissions string     `gorm:"not null" json:"permissions"`
+	CreatedBy   string     `json:"createdBy"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	RevokedAt   *time.Time `json:"revokedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
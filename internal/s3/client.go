@@ -2,17 +2,41 @@ package s3
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"net/http"
 	"net/url"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/arencloud/hermes/internal/models"
+	"github.com/arencloud/hermes/internal/secrets"
 
 	minio "github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
 )
 
-type Client struct{ mc *minio.Client }
+type Client struct {
+	mc          *minio.Client
+	tr          *http.Transport // owned by this client, so idle connections can be closed with it
+	maxAttempts int             // for calls retried on transient errors: the provider's MaxRetries + 1
+}
+
+// CloseIdleConnections releases the client's pooled connections; called when the client
+// is evicted from the API's client cache.
+func (c *Client) CloseIdleConnections() {
+	if c.tr != nil {
+		c.tr.CloseIdleConnections()
+	}
+}
 
 // Stat returns object info (size, content type) if available.
 func (c *Client) Stat(ctx context.Context, bucket, key string) (minio.ObjectInfo, error) {
@@ -62,26 +86,99 @@ func normalizeEndpoint(endpoint string, useSSL bool) (host string, secure bool)
 	return endpoint, secure
 }
 
+// Provider.PathStyle values.
+const (
+	PathStyleAuto    = "auto"
+	PathStylePath    = "path"
+	PathStyleVirtual = "virtual"
+)
+
 func forcePathStyle(p models.Provider) bool {
+	switch p.PathStyle {
+	case PathStylePath:
+		return true
+	case PathStyleVirtual:
+		return false
+	}
 	// Use path-style for non-AWS by default; AWS prefers virtual-hosted
 	pt := strings.ToLower(strings.TrimSpace(p.Type))
 	return pt == "minio" || pt == "mcg" || pt == "generic" || pt == "" // default to path style for unknown
 }
 
-func NewFromProvider(p models.Provider) (*Client, error) {
+// bucketLookup maps the provider's PathStyle to minio-go's bucket addressing. In auto
+// mode the type decides, and minio-go's own detection applies where it does not force
+// path style.
+func bucketLookup(p models.Provider) minio.BucketLookupType {
+	switch {
+	case p.PathStyle == PathStyleVirtual:
+		return minio.BucketLookupDNS
+	case forcePathStyle(p):
+		return minio.BucketLookupPath
+	}
+	return minio.BucketLookupAuto
+}
+
+// NewFromProvider builds the client for a provider: a FailoverClient when failover is
+// enabled, otherwise a plain Client. A SecretRef is resolved through the secrets store
+// first, so rotated credentials take effect without a provider update.
+func NewFromProvider(p models.Provider) (ClientInterface, error) {
+	if p.SecretRef != "" {
+		sk, err := secrets.DefaultStore().Get(p.SecretRef)
+		if err != nil {
+			return nil, fmt.Errorf("resolve secret for provider %q: %w", p.Name, err)
+		}
+		p.SecretKey = sk
+	}
+	if p.FailoverEnabled && p.SecondaryEndpoint != "" {
+		f, err := newFailoverClient(p)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+	c, err := newClient(p, 0)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// newClient builds a client for p's endpoint; maxRetries 0 keeps the minio-go default.
+func newClient(p models.Provider, maxRetries int) (*Client, error) {
 	endpoint, secure := normalizeEndpoint(p.Endpoint, p.UseSSL)
 	opts := &minio.Options{
-		Creds:  credentials.NewStaticV4(p.AccessKey, p.SecretKey, ""),
-		Secure: secure,
-		Region: p.Region,
+		Creds:        credentials.NewStaticV4(p.AccessKey, p.SecretKey, ""),
+		Secure:       secure,
+		Region:       p.Region,
+		MaxRetries:   maxRetries,
+		BucketLookup: bucketLookup(p),
 	}
-	// minio-go v7 automatically handles path-style for custom endpoints (MinIO/MCG).
-	// For AWS, virtual-hosted style is used by default.
+	tr, err := newTransport(secure)
+	if err != nil {
+		return nil, err
+	}
+	if p.CACertPEM != "" {
+		pool, err := ParseCACert(p.CACertPEM)
+		if err != nil {
+			return nil, err
+		}
+		tr.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	opts.Transport = tr
 	mc, err := minio.New(endpoint, opts)
 	if err != nil {
 		return nil, err
 	}
-	return &Client{mc: mc}, nil
+	return &Client{mc: mc, tr: tr, maxAttempts: p.MaxRetries + 1}, nil
+}
+
+// ParseCACert builds a certificate pool from PEM-encoded CA certificates.
+func ParseCACert(pem string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(pem)) {
+		return nil, errors.New("no valid PEM certificates found in CA cert")
+	}
+	return pool, nil
 }
 
 func (c *Client) ListBuckets(ctx context.Context) ([]minio.BucketInfo, error) {
@@ -96,24 +193,222 @@ func (c *Client) DeleteBucket(ctx context.Context, name string) error {
 	return c.mc.RemoveBucket(ctx, name)
 }
 
+// ListObjects lists the objects under prefix; a listing cut short by a transient error
+// is started over.
 func (c *Client) ListObjects(ctx context.Context, bucket, prefix string, recursive bool) ([]minio.ObjectInfo, error) {
 	var out []minio.ObjectInfo
-	for obj := range c.mc.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: recursive}) {
+	err := withRetry(ctx, c.maxAttempts, func() error {
+		out = nil
+		for obj := range c.mc.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: recursive}) {
+			if obj.Err != nil {
+				return obj.Err
+			}
+			out = append(out, obj)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListObjectsPage lists up to maxKeys objects under prefix that sort after startAfter and
+// reports whether more follow. The listing stops after maxKeys+1 entries, so a page of a
+// large bucket costs one or two provider requests rather than a full listing.
+func (c *Client) ListObjectsPage(ctx context.Context, bucket, prefix string, recursive bool, startAfter string, maxKeys int) ([]minio.ObjectInfo, bool, error) {
+	var out []minio.ObjectInfo
+	var truncated bool
+	err := withRetry(ctx, c.maxAttempts, func() error {
+		out, truncated = nil, false
+		lctx, cancel := context.WithCancel(ctx)
+		defer cancel() // stops the lister once the page is full
+		opts := minio.ListObjectsOptions{Prefix: prefix, Recursive: recursive, StartAfter: startAfter, MaxKeys: min(maxKeys+1, 1000)}
+		for obj := range c.mc.ListObjects(lctx, bucket, opts) {
+			if obj.Err != nil {
+				return obj.Err
+			}
+			// a common prefix given as startAfter is listed again
+			if obj.Key <= startAfter {
+				continue
+			}
+			if len(out) == maxKeys {
+				truncated = true
+				return nil
+			}
+			out = append(out, obj)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return out, truncated, nil
+}
+
+// BucketStats counts the bucket's objects, their total size and the newest modification
+// time from a recursive listing, without holding the listing in memory. When ctx ends
+// before the listing does, the totals so far are returned with ctx's error.
+func (c *Client) BucketStats(ctx context.Context, bucket string) (int64, int64, time.Time, error) {
+	return sumObjects(ctx, c.mc.ListObjects(ctx, bucket, minio.ListObjectsOptions{Recursive: true}))
+}
+
+// sumObjects drains a listing for BucketStats.
+func sumObjects(ctx context.Context, objs <-chan minio.ObjectInfo) (count, total int64, last time.Time, err error) {
+	for obj := range objs {
 		if obj.Err != nil {
-			return nil, obj.Err
+			if ctx.Err() != nil {
+				return count, total, last, ctx.Err()
+			}
+			return 0, 0, time.Time{}, obj.Err
+		}
+		count++
+		total += obj.Size
+		if obj.LastModified.After(last) {
+			last = obj.LastModified
+		}
+	}
+	// the lister may close the channel on cancellation without sending an error
+	return count, total, last, ctx.Err()
+}
+
+// pageObjects cuts one ListObjectsPage page out of a full listing.
+func pageObjects(items []minio.ObjectInfo, startAfter string, maxKeys int) ([]minio.ObjectInfo, bool) {
+	sorted := slices.Clone(items)
+	slices.SortFunc(sorted, func(a, b minio.ObjectInfo) int { return strings.Compare(a.Key, b.Key) })
+	out := make([]minio.ObjectInfo, 0, min(len(sorted), maxKeys))
+	for _, obj := range sorted {
+		if obj.Key <= startAfter {
+			continue
+		}
+		if len(out) == maxKeys {
+			return out, true
 		}
 		out = append(out, obj)
 	}
-	return out, nil
+	return out, false
 }
 
 func (c *Client) Upload(ctx context.Context, bucket, key string, reader io.Reader, size int64, contentType string) (minio.UploadInfo, error) {
-	opts := minio.PutObjectOptions{ContentType: contentType}
-	return c.mc.PutObject(ctx, bucket, key, reader, size, opts)
+	return c.UploadWithSSE(ctx, bucket, key, reader, size, contentType, nil)
 }
 
+// UploadWithSSE uploads an object encrypted at rest with sse; a nil sse uses the bucket default.
+// Only a reader that can be rewound (an io.Seeker) is retried on transient errors.
+func (c *Client) UploadWithSSE(ctx context.Context, bucket, key string, reader io.Reader, size int64, contentType string, sse encrypt.ServerSide) (minio.UploadInfo, error) {
+	opts := minio.PutObjectOptions{ContentType: contentType, ServerSideEncryption: sse}
+	rs, ok := reader.(io.Seeker)
+	if !ok {
+		return c.mc.PutObject(ctx, bucket, key, reader, size, opts)
+	}
+	start, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return c.mc.PutObject(ctx, bucket, key, reader, size, opts)
+	}
+	var info minio.UploadInfo
+	first := true
+	err = withRetry(ctx, c.maxAttempts, func() error {
+		if !first {
+			if _, err := rs.Seek(start, io.SeekStart); err != nil {
+				return err
+			}
+		}
+		first = false
+		var err error
+		info, err = c.mc.PutObject(ctx, bucket, key, reader, size, opts)
+		return err
+	})
+	return info, err
+}
+
+// Download opens the object for reading. The first request is made here so a transient
+// failure can be retried; other errors, such as a missing key, surface on Read as before.
 func (c *Client) Download(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
-	return c.mc.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	var obj *minio.Object
+	err := withRetry(ctx, c.maxAttempts, func() error {
+		o, err := c.mc.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+		if err != nil {
+			return err
+		}
+		if _, err := o.Stat(); isRetriable(err) {
+			o.Close()
+			return err
+		}
+		obj = o
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// PresignedURL returns a URL that downloads the object without credentials until expiry
+// has passed.
+func (c *Client) PresignedURL(ctx context.Context, bucket, key string, expiry time.Duration) (string, error) {
+	u, err := c.mc.PresignedGetObject(ctx, bucket, key, expiry, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// PresignedPutURL returns a URL accepting a PUT of the object body without credentials
+// until expiry has passed.
+func (c *Client) PresignedPutURL(ctx context.Context, bucket, key string, expiry time.Duration) (string, error) {
+	u, err := c.mc.PresignedPutObject(ctx, bucket, key, expiry)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// ObjectError is a key that a batch operation could not process.
+type ObjectError struct {
+	Key string
+	Err error
+}
+
+// DeleteObjects removes keys in batches of up to 1000 per request and returns the keys the
+// provider refused. err is set only when the whole operation stopped, e.g. on cancellation.
+func (c *Client) DeleteObjects(ctx context.Context, bucket string, keys []string) ([]ObjectError, error) {
+	objects := make(chan minio.ObjectInfo)
+	go func() {
+		defer close(objects)
+		for _, k := range keys {
+			select {
+			case objects <- minio.ObjectInfo{Key: k}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	var failed []ObjectError
+	for e := range c.mc.RemoveObjects(ctx, bucket, objects, minio.RemoveObjectsOptions{}) {
+		failed = append(failed, ObjectError{Key: e.ObjectName, Err: e.Err})
+	}
+	return failed, ctx.Err()
+}
+
+// DeleteObjectVersions removes the given object versions (delete markers included) like
+// DeleteObjects, which removes only the current version of each key.
+func (c *Client) DeleteObjectVersions(ctx context.Context, bucket string, versions []minio.ObjectInfo) ([]ObjectError, error) {
+	objects := make(chan minio.ObjectInfo)
+	go func() {
+		defer close(objects)
+		for _, v := range versions {
+			select {
+			case objects <- minio.ObjectInfo{Key: v.Key, VersionID: v.VersionID}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	var failed []ObjectError
+	for e := range c.mc.RemoveObjects(ctx, bucket, objects, minio.RemoveObjectsOptions{}) {
+		failed = append(failed, ObjectError{Key: e.ObjectName, Err: e.Err})
+	}
+	return failed, ctx.Err()
 }
 
 func (c *Client) DeleteObject(ctx context.Context, bucket, key string) error {
@@ -121,8 +416,13 @@ func (c *Client) DeleteObject(ctx context.Context, bucket, key string) error {
 }
 
 func (c *Client) CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	return c.CopyObjectWithSSE(ctx, srcBucket, srcKey, dstBucket, dstKey, nil)
+}
+
+// CopyObjectWithSSE performs a server-side copy, encrypting the destination with sse.
+func (c *Client) CopyObjectWithSSE(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string, sse encrypt.ServerSide) error {
 	src := minio.CopySrcOptions{Bucket: srcBucket, Object: srcKey}
-	dst := minio.CopyDestOptions{Bucket: dstBucket, Object: dstKey}
+	dst := minio.CopyDestOptions{Bucket: dstBucket, Object: dstKey, Encryption: sse}
 	_, err := c.mc.CopyObject(ctx, dst, src)
 	return err
 }
@@ -133,3 +433,221 @@ func (c *Client) MoveObject(ctx context.Context, srcBucket, srcKey, dstBucket, d
 	}
 	return c.DeleteObject(ctx, srcBucket, srcKey)
 }
+
+// ParseSSE builds the server-side encryption setting for an upload or copy.
+// sseType is SSE-S3, SSE-KMS (kmsKeyID required) or SSE-C (customerKey is a
+// base64-encoded 32-byte key); an empty sseType returns nil (no explicit encryption).
+func ParseSSE(sseType, kmsKeyID, customerKey string) (encrypt.ServerSide, error) {
+	switch strings.ToUpper(sseType) {
+	case "":
+		return nil, nil
+	case "SSE-S3":
+		return encrypt.NewSSE(), nil
+	case "SSE-KMS":
+		if kmsKeyID == "" {
+			return nil, errors.New("sseKmsKeyId is required for SSE-KMS")
+		}
+		return encrypt.NewSSEKMS(kmsKeyID, nil)
+	case "SSE-C":
+		key, err := base64.StdEncoding.DecodeString(customerKey)
+		if err != nil || len(key) != 32 {
+			return nil, errors.New("sseCKey must be a base64-encoded 32-byte key for SSE-C")
+		}
+		return encrypt.NewSSEC(key)
+	default:
+		return nil, fmt.Errorf("unsupported sseType %q", sseType)
+	}
+}
+
+// ErrSelectNotSupported is returned by SelectObject when the provider does not implement S3 Select.
+var ErrSelectNotSupported = errors.New("s3 select not supported by provider")
+
+// SelectObject runs an S3 Select query against a CSV or JSON object and returns the
+// result stream. inputFormat/outputFormat are "CSV" or "JSON"; csvDelimiter applies to
+// CSV input and defaults to ",". CSV input is expected to have a header row.
+func (c *Client) SelectObject(ctx context.Context, bucket, key, query, inputFormat, outputFormat, csvDelimiter string) (io.ReadCloser, error) {
+	opts := minio.SelectObjectOptions{Expression: query, ExpressionType: minio.QueryExpressionTypeSQL}
+	opts.InputSerialization.CompressionType = minio.SelectCompressionNONE
+	switch strings.ToUpper(inputFormat) {
+	case "CSV":
+		in := &minio.CSVInputOptions{}
+		in.SetFileHeaderInfo(minio.CSVFileHeaderInfoUse)
+		if csvDelimiter != "" {
+			in.SetFieldDelimiter(csvDelimiter)
+		}
+		opts.InputSerialization.CSV = in
+	case "JSON":
+		in := &minio.JSONInputOptions{}
+		in.SetType(minio.JSONLinesType)
+		opts.InputSerialization.JSON = in
+	default:
+		return nil, fmt.Errorf("unsupported input format %q", inputFormat)
+	}
+	switch strings.ToUpper(outputFormat) {
+	case "CSV":
+		opts.OutputSerialization.CSV = &minio.CSVOutputOptions{}
+	case "JSON", "":
+		out := &minio.JSONOutputOptions{}
+		out.SetRecordDelimiter("\n")
+		opts.OutputSerialization.JSON = out
+	default:
+		return nil, fmt.Errorf("unsupported output format %q", outputFormat)
+	}
+	res, err := c.mc.SelectObjectContent(ctx, bucket, key, opts)
+	if err != nil {
+		if notImplemented(err) {
+			return nil, ErrSelectNotSupported
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
+// notImplemented reports whether the provider rejected a call as unsupported.
+func notImplemented(err error) bool {
+	er := minio.ToErrorResponse(err)
+	return er.StatusCode == http.StatusNotImplemented || er.Code == "NotImplemented" || er.Code == "XNotImplemented"
+}
+
+// IsNotFound reports whether err means the object or its bucket does not exist.
+func IsNotFound(err error) bool {
+	er := minio.ToErrorResponse(err)
+	return er.StatusCode == http.StatusNotFound || er.Code == "NoSuchKey" || er.Code == "NoSuchBucket"
+}
+
+// ErrPolicyNotSupported is returned by the bucket policy calls when the provider does not implement them.
+var ErrPolicyNotSupported = errors.New("bucket policies not supported by provider")
+
+// GetBucketPolicy returns the bucket's raw IAM policy JSON ("" when none is set).
+func (c *Client) GetBucketPolicy(ctx context.Context, bucket string) (string, error) {
+	p, err := c.mc.GetBucketPolicy(ctx, bucket)
+	if err != nil && notImplemented(err) {
+		return "", ErrPolicyNotSupported
+	}
+	return p, err
+}
+
+// SetBucketPolicy replaces the bucket policy; an empty policy removes it.
+func (c *Client) SetBucketPolicy(ctx context.Context, bucket, policy string) error {
+	err := c.mc.SetBucketPolicy(ctx, bucket, policy)
+	if err != nil && notImplemented(err) {
+		return ErrPolicyNotSupported
+	}
+	return err
+}
+
+// EnableBucketVersioning turns on object versioning for the bucket.
+func (c *Client) EnableBucketVersioning(ctx context.Context, bucket string) error {
+	return c.mc.EnableVersioning(ctx, bucket)
+}
+
+// BucketVersioningEnabled reports whether versioning is currently enabled on the bucket.
+func (c *Client) BucketVersioningEnabled(ctx context.Context, bucket string) (bool, error) {
+	cfg, err := c.mc.GetBucketVersioning(ctx, bucket)
+	if err != nil {
+		return false, err
+	}
+	return cfg.Enabled(), nil
+}
+
+// ListObjectVersions returns every version of key, newest first (delete markers included).
+func (c *Client) ListObjectVersions(ctx context.Context, bucket, key string) ([]minio.ObjectInfo, error) {
+	var out []minio.ObjectInfo
+	for obj := range c.mc.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: key, WithVersions: true}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		if obj.Key == key {
+			out = append(out, obj)
+		}
+	}
+	return out, nil
+}
+
+// ListBucketVersions lists every version and delete marker in the bucket, stopping after
+// maxKeys; truncated reports that more remain.
+func (c *Client) ListBucketVersions(ctx context.Context, bucket string, maxKeys int) ([]minio.ObjectInfo, bool, error) {
+	var out []minio.ObjectInfo
+	var truncated bool
+	err := withRetry(ctx, c.maxAttempts, func() error {
+		out, truncated = nil, false
+		lctx, cancel := context.WithCancel(ctx)
+		defer cancel() // stops the lister once maxKeys are listed
+		opts := minio.ListObjectsOptions{Recursive: true, WithVersions: true, MaxKeys: min(maxKeys+1, 1000)}
+		for obj := range c.mc.ListObjects(lctx, bucket, opts) {
+			if obj.Err != nil {
+				return obj.Err
+			}
+			if len(out) == maxKeys {
+				truncated = true
+				return nil
+			}
+			out = append(out, obj)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return out, truncated, nil
+}
+
+// RestoreObjectVersion copies versionID of key over the key itself, making it the latest
+// version without removing newer ones, and returns the new version's ID.
+func (c *Client) RestoreObjectVersion(ctx context.Context, bucket, key, versionID string) (string, error) {
+	src := minio.CopySrcOptions{Bucket: bucket, Object: key, VersionID: versionID}
+	dst := minio.CopyDestOptions{Bucket: bucket, Object: key}
+	info, err := c.mc.CopyObject(ctx, dst, src)
+	if err != nil {
+		return "", err
+	}
+	return info.VersionID, nil
+}
+
+// ParseLifecycleRules decodes a JSON array of S3 lifecycle rules, using the S3 field
+// names (ID, Status, Prefix, Expiration: {Days}, ...).
+func ParseLifecycleRules(rulesJSON string) ([]lifecycle.Rule, error) {
+	var rules []lifecycle.Rule
+	if err := json.Unmarshal([]byte(rulesJSON), &rules); err != nil {
+		return nil, fmt.Errorf("invalid lifecycle rules: %w", err)
+	}
+	for i, r := range rules {
+		if r.Status != "Enabled" && r.Status != "Disabled" {
+			return nil, fmt.Errorf("lifecycle rule %d: Status must be Enabled or Disabled", i)
+		}
+	}
+	return rules, nil
+}
+
+// SetBucketLifecycle replaces the bucket's lifecycle configuration with the given JSON rules.
+func (c *Client) SetBucketLifecycle(ctx context.Context, bucket, rulesJSON string) error {
+	rules, err := ParseLifecycleRules(rulesJSON)
+	if err != nil {
+		return err
+	}
+	return c.mc.SetBucketLifecycle(ctx, bucket, &lifecycle.Configuration{Rules: rules})
+}
+
+// SetStorageClass moves an object to another storage class by copying it onto itself,
+// keeping its content type and user metadata.
+func (c *Client) SetStorageClass(ctx context.Context, bucket, key, class string) error {
+	info, err := c.mc.StatObject(ctx, bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return err
+	}
+	meta := make(map[string]string, len(info.UserMetadata)+1)
+	for k, v := range info.UserMetadata {
+		meta[k] = v
+	}
+	meta["X-Amz-Storage-Class"] = class
+	src := minio.CopySrcOptions{Bucket: bucket, Object: key}
+	dst := minio.CopyDestOptions{Bucket: bucket, Object: key, ContentType: info.ContentType, UserMetadata: meta, ReplaceMetadata: true}
+	_, err = c.mc.CopyObject(ctx, dst, src)
+	return err
+}
+
+// AbortMultipartUpload aborts any incomplete multipart upload of key, releasing the parts
+// already stored on the backend.
+func (c *Client) AbortMultipartUpload(ctx context.Context, bucket, key string) error {
+	return c.mc.RemoveIncompleteUpload(ctx, bucket, key)
+}
@@ -1,8 +1,22 @@
 package s3
 
 import (
-	"github.com/arencloud/hermes/internal/models"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/arencloud/hermes/internal/models"
+	minio "github.com/minio/minio-go/v7"
 )
 
 func TestNormalizeEndpoint(t *testing.T) {
@@ -25,13 +39,239 @@ func TestNormalizeEndpoint(t *testing.T) {
 }
 
 func TestForcePathStyle(t *testing.T) {
-	if !forcePathStyle(models.Provider{Type: "minio"}) {
-		t.Fatal("minio should be path-style")
+	cases := []struct {
+		typ, style string
+		force      bool
+		lookup     minio.BucketLookupType
+	}{
+		{"minio", "", true, minio.BucketLookupPath},
+		{"generic", "auto", true, minio.BucketLookupPath},
+		{"", "auto", true, minio.BucketLookupPath},
+		{"aws", "", false, minio.BucketLookupAuto},
+		{"aws", "auto", false, minio.BucketLookupAuto},
+		{"aws", "path", true, minio.BucketLookupPath},
+		{"minio", "path", true, minio.BucketLookupPath},
+		{"minio", "virtual", false, minio.BucketLookupDNS},
+		{"mcg", "virtual", false, minio.BucketLookupDNS},
+		{"aws", "virtual", false, minio.BucketLookupDNS},
+	}
+	for _, c := range cases {
+		p := models.Provider{Type: c.typ, PathStyle: c.style}
+		if got := forcePathStyle(p); got != c.force {
+			t.Errorf("type %q style %q: forcePathStyle = %v, want %v", c.typ, c.style, got, c.force)
+		}
+		if got := bucketLookup(p); got != c.lookup {
+			t.Errorf("type %q style %q: bucketLookup = %v, want %v", c.typ, c.style, got, c.lookup)
+		}
+	}
+}
+
+func TestParseSSE(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	cases := []struct {
+		typ, kms, ckey string
+		want           string // encrypt.Type, "" for nil
+		err            bool
+	}{
+		{"", "", "", "", false},
+		{"SSE-S3", "", "", "S3", false},
+		{"SSE-KMS", "my-key", "", "KMS", false},
+		{"SSE-KMS", "", "", "", true},
+		{"SSE-C", "", key, "SSE-C", false},
+		{"SSE-C", "", base64.StdEncoding.EncodeToString([]byte("short")), "", true},
+		{"AES", "", "", "", true},
+	}
+	for _, c := range cases {
+		sse, err := ParseSSE(c.typ, c.kms, c.ckey)
+		if (err != nil) != c.err {
+			t.Fatalf("ParseSSE(%q) err=%v wantErr=%v", c.typ, err, c.err)
+		}
+		got := ""
+		if sse != nil {
+			got = string(sse.Type())
+		}
+		if got != c.want {
+			t.Fatalf("ParseSSE(%q) type=%q want %q", c.typ, got, c.want)
+		}
+	}
+}
+
+func TestNotImplemented(t *testing.T) {
+	if !notImplemented(minio.ErrorResponse{Code: "NotImplemented", StatusCode: 501}) {
+		t.Fatal("NotImplemented response should be detected")
+	}
+	if notImplemented(minio.ErrorResponse{Code: "AccessDenied", StatusCode: 403}) {
+		t.Fatal("AccessDenied is not NotImplemented")
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	if !IsNotFound(minio.ErrorResponse{Code: "NoSuchKey", StatusCode: 404}) {
+		t.Fatal("NoSuchKey should be not found")
+	}
+	if IsNotFound(minio.ErrorResponse{Code: "AccessDenied", StatusCode: 403}) {
+		t.Fatal("AccessDenied is not not-found")
+	}
+}
+
+func TestParseLifecycleRules(t *testing.T) {
+	rules, err := ParseLifecycleRules(`[{"ID":"expire-tmp","Status":"Enabled","Prefix":"tmp/","Expiration":{"Days":7}}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 || rules[0].ID != "expire-tmp" || rules[0].Expiration.Days != 7 {
+		t.Fatalf("unexpected rules %+v", rules)
+	}
+	if _, err := ParseLifecycleRules(`[{"ID":"x","Status":"on"}]`); err == nil {
+		t.Fatal("invalid status should be rejected")
+	}
+	if _, err := ParseLifecycleRules(`{"ID":"x"}`); err == nil {
+		t.Fatal("non-array JSON should be rejected")
+	}
+}
+
+func TestProviderCACert(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(403)
+	}))
+	defer srv.Close()
+	caPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))
+	p := models.Provider{Type: "minio", Endpoint: srv.URL, AccessKey: "ak", SecretKey: "sk"}
+
+	c, err := NewFromProvider(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.ListBuckets(context.Background())
+	var uae x509.UnknownAuthorityError
+	if !errors.As(err, &uae) {
+		t.Fatalf("expected an unknown authority error without the CA, got %v", err)
+	}
+
+	p.CACertPEM = caPEM
+	c, err = NewFromProvider(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.ListBuckets(context.Background()); err == nil || errors.As(err, &uae) {
+		t.Fatalf("expected the TLS handshake to pass with the CA and S3 to refuse, got %v", err)
+	}
+
+	p.CACertPEM = "not a certificate"
+	if _, err := NewFromProvider(p); err == nil {
+		t.Fatal("expected an invalid PEM to be rejected")
+	}
+}
+
+func TestTransportSettings(t *testing.T) {
+	defer SetTransportSettings(DefaultTransportSettings)
+	// zero fields fall back to the defaults
+	SetTransportSettings(TransportSettings{IdleConnTimeout: 10 * time.Second})
+	c, err := newClient(models.Provider{Endpoint: "minio.local:9000"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.tr.IdleConnTimeout != 10*time.Second || c.tr.MaxIdleConns != 100 || c.tr.TLSHandshakeTimeout != 5*time.Second {
+		t.Fatalf("transport idle=%v maxIdle=%d tls=%v", c.tr.IdleConnTimeout, c.tr.MaxIdleConns, c.tr.TLSHandshakeTimeout)
+	}
+	if got := CurrentTransportSettings(); got.DialTimeout != 5*time.Second {
+		t.Fatalf("dial timeout %v, want the 5s default", got.DialTimeout)
+	}
+}
+
+// fakeListServer answers ListObjectsV2 over keys k00..k(n-1), counting list requests.
+func fakeListServer(t *testing.T, n int, requests *atomic.Int32) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if _, ok := q["location"]; ok {
+			fmt.Fprint(w, `<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/">us-east-1</LocationConstraint>`)
+			return
+		}
+		requests.Add(1)
+		after := max(q.Get("start-after"), q.Get("continuation-token"))
+		limit, _ := strconv.Atoi(q.Get("max-keys"))
+		var b strings.Builder
+		last, truncated := "", false
+		for i := 0; i < n; i++ {
+			k := fmt.Sprintf("k%02d", i)
+			if k <= after {
+				continue
+			}
+			if limit > 0 && strings.Count(b.String(), "<Contents>") == limit {
+				truncated = true
+				break
+			}
+			fmt.Fprintf(&b, `<Contents><Key>%s</Key><Size>1</Size><LastModified>2024-01-01T00:00:00.000Z</LastModified><ETag>"e"</ETag></Contents>`, k)
+			last = k
+		}
+		fmt.Fprintf(w, `<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Name>bkt</Name><MaxKeys>%d</MaxKeys><IsTruncated>%v</IsTruncated><NextContinuationToken>%s</NextContinuationToken>%s</ListBucketResult>`, limit, truncated, last, b.String())
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestListObjectsPage(t *testing.T) {
+	var requests atomic.Int32
+	srv := fakeListServer(t, 10, &requests)
+	c, err := NewFromProvider(models.Provider{Type: "minio", Endpoint: srv.URL, AccessKey: "ak", SecretKey: "sk", Region: "us-east-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := func(items []minio.ObjectInfo) string {
+		var ks []string
+		for _, o := range items {
+			ks = append(ks, o.Key)
+		}
+		return strings.Join(ks, ",")
+	}
+	page, truncated, err := c.ListObjectsPage(context.Background(), "bkt", "", true, "", 3)
+	if err != nil || keys(page) != "k00,k01,k02" || !truncated {
+		t.Fatalf("first page: %s truncated=%v err=%v", keys(page), truncated, err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("%d list requests for one page, want 1", n)
+	}
+	page, truncated, err = c.ListObjectsPage(context.Background(), "bkt", "", true, "k08", 3)
+	if err != nil || keys(page) != "k09" || truncated {
+		t.Fatalf("last page: %s truncated=%v err=%v", keys(page), truncated, err)
+	}
+}
+
+func TestPageObjects(t *testing.T) {
+	items := []minio.ObjectInfo{{Key: "c"}, {Key: "a"}, {Key: "d"}, {Key: "b"}}
+	page, truncated := pageObjects(items, "a", 2)
+	if len(page) != 2 || page[0].Key != "b" || page[1].Key != "c" || !truncated {
+		t.Fatalf("page after a: %+v truncated=%v", page, truncated)
+	}
+	if page, truncated := pageObjects(items, "c", 2); len(page) != 1 || page[0].Key != "d" || truncated {
+		t.Fatalf("page after c: %+v truncated=%v", page, truncated)
+	}
+	if items[0].Key != "c" {
+		t.Fatal("pageObjects reordered its input")
+	}
+}
+
+func TestSumObjects(t *testing.T) {
+	newest := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	feed := func(objs ...minio.ObjectInfo) <-chan minio.ObjectInfo {
+		ch := make(chan minio.ObjectInfo, len(objs))
+		for _, o := range objs {
+			ch <- o
+		}
+		close(ch)
+		return ch
+	}
+	count, total, last, err := sumObjects(context.Background(), feed(minio.ObjectInfo{Size: 3, LastModified: newest}, minio.ObjectInfo{Size: 4, LastModified: newest.Add(-time.Hour)}))
+	if err != nil || count != 2 || total != 7 || !last.Equal(newest) {
+		t.Fatalf("sum: %d %d %v %v", count, total, last, err)
 	}
-	if !forcePathStyle(models.Provider{Type: "generic"}) {
-		t.Fatal("generic should be path-style")
+	if _, _, _, err := sumObjects(context.Background(), feed(minio.ObjectInfo{Size: 1}, minio.ObjectInfo{Err: errors.New("boom")})); err == nil {
+		t.Fatal("listing error not returned")
 	}
-	if forcePathStyle(models.Provider{Type: "aws"}) {
-		t.Fatal("aws should not force path-style")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	count, _, _, err = sumObjects(ctx, feed(minio.ObjectInfo{Size: 1}, minio.ObjectInfo{Err: ctx.Err()}))
+	if !errors.Is(err, context.Canceled) || count != 1 {
+		t.Fatalf("cancelled listing: %d %v, want the partial count with ctx's error", count, err)
 	}
 }
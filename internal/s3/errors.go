@@ -0,0 +1,40 @@
+package s3
+
+import (
+	"errors"
+	"net"
+	"syscall"
+
+	minio "github.com/minio/minio-go/v7"
+)
+
+// ClassifyError maps an error from a storage call to the HTTP status and message a client
+// should see. The message never contains the raw error, which may name endpoints,
+// addresses or credentials; callers log that separately.
+func ClassifyError(err error) (httpCode int, userMessage string) {
+	if err == nil {
+		return 200, ""
+	}
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchBucket":
+		return 404, "bucket not found"
+	case "NoSuchKey":
+		return 404, "object not found"
+	case "AccessDenied":
+		return 403, "access denied by storage provider"
+	case "BucketAlreadyOwnedByYou", "BucketAlreadyExists":
+		return 409, "bucket already exists"
+	case "InvalidBucketName":
+		return 400, "invalid bucket name"
+	case "SlowDown", "ServiceUnavailable":
+		return 503, "storage provider is busy, try again later"
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return 504, "storage provider timed out"
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return 503, "storage provider unavailable"
+	}
+	return 500, "storage operation failed"
+}
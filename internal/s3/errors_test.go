@@ -0,0 +1,44 @@
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	minio "github.com/minio/minio-go/v7"
+)
+
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "i/o timeout on 10.0.0.5:9000" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
+
+func TestClassifyError(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	cases := []struct {
+		err  error
+		code int
+	}{
+		{minio.ErrorResponse{StatusCode: 404, Code: "NoSuchBucket", Message: "The specified bucket does not exist"}, 404},
+		{minio.ErrorResponse{StatusCode: 404, Code: "NoSuchKey"}, 404},
+		{minio.ErrorResponse{StatusCode: 403, Code: "AccessDenied"}, 403},
+		{minio.ErrorResponse{StatusCode: 409, Code: "BucketAlreadyOwnedByYou"}, 409},
+		{fmt.Errorf("list: %w", timeoutErr{}), 504},
+		{&net.OpError{Op: "read", Net: "tcp", Err: timeoutErr{}}, 504},
+		{refused, 503},
+		{errors.New("secret-endpoint.internal:9000 exploded"), 500},
+	}
+	for _, c := range cases {
+		code, msg := ClassifyError(c.err)
+		if code != c.code {
+			t.Errorf("%v: code %d, want %d", c.err, code, c.code)
+		}
+		if msg == "" || msg == c.err.Error() {
+			t.Errorf("%v: unsafe message %q", c.err, msg)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/arencloud/hermes/internal/models"
+	minio "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+// OnFailover, when set, is called each time a FailoverClient switches endpoints because
+// the active one failed. err is the failure that triggered the switch.
+var OnFailover func(providerID uint, from, to string, err error)
+
+// activeSecondary remembers, per provider, whether the secondary endpoint is active.
+// Clients are built per request, so the state lives here rather than in the client.
+var activeSecondary sync.Map // providerID -> bool
+
+// ActiveEndpoint returns the endpoint a failover-enabled provider currently uses.
+func ActiveEndpoint(p models.Provider) string {
+	if v, ok := activeSecondary.Load(p.ID); ok && v.(bool) && p.FailoverEnabled {
+		return p.SecondaryEndpoint
+	}
+	return p.Endpoint
+}
+
+// SwitchEndpoint flips a failover-enabled provider to its other endpoint and returns
+// the now active one.
+func SwitchEndpoint(p models.Provider) string {
+	sec := ActiveEndpoint(p) != p.SecondaryEndpoint
+	activeSecondary.Store(p.ID, sec)
+	return ActiveEndpoint(p)
+}
+
+// FailoverClient sends calls to the provider's active endpoint and, when it fails with a
+// network error or a 5xx, switches to the other endpoint and retries the call there.
+type FailoverClient struct {
+	provider  models.Provider
+	primary   *Client
+	secondary *Client
+	uploads   sync.Map // multipart upload ID -> *Client it was started on
+}
+
+var (
+	_ ClientInterface = (*FailoverClient)(nil)
+	_ MultipartClient = (*FailoverClient)(nil)
+)
+
+// failoverMaxRetries bounds minio-go's own retries so a dead endpoint fails over quickly.
+const failoverMaxRetries = 2
+
+func newFailoverClient(p models.Provider) (*FailoverClient, error) {
+	primary, err := newClient(p, failoverMaxRetries)
+	if err != nil {
+		return nil, err
+	}
+	sp := p
+	sp.Endpoint = p.SecondaryEndpoint
+	secondary, err := newClient(sp, failoverMaxRetries)
+	if err != nil {
+		return nil, err
+	}
+	return &FailoverClient{provider: p, primary: primary, secondary: secondary}, nil
+}
+
+// shouldFailover reports whether err means the endpoint itself is unhealthy: a 5xx from
+// S3 or a transport error. Client-side errors and cancellations do not count.
+func shouldFailover(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	er := minio.ToErrorResponse(err)
+	return er.StatusCode >= 500 || (er.StatusCode == 0 && er.Code == "")
+}
+
+// CloseIdleConnections releases the pooled connections of both endpoints.
+func (f *FailoverClient) CloseIdleConnections() {
+	f.primary.CloseIdleConnections()
+	f.secondary.CloseIdleConnections()
+}
+
+func (f *FailoverClient) active() (*Client, string) {
+	if ActiveEndpoint(f.provider) == f.provider.SecondaryEndpoint {
+		return f.secondary, f.provider.SecondaryEndpoint
+	}
+	return f.primary, f.provider.Endpoint
+}
+
+// do runs call against the active endpoint and, if that fails over, once more against the
+// new active endpoint. retry=false still switches endpoints but returns the first error,
+// for calls that cannot be repeated (a consumed upload stream).
+func (f *FailoverClient) do(retry bool, call func(c *Client) error) error {
+	c, from := f.active()
+	err := call(c)
+	if !shouldFailover(err) {
+		return err
+	}
+	to := SwitchEndpoint(f.provider)
+	if OnFailover != nil {
+		OnFailover(f.provider.ID, from, to, err)
+	}
+	if !retry {
+		return err
+	}
+	c, _ = f.active()
+	return call(c)
+}
+
+func (f *FailoverClient) Stat(ctx context.Context, bucket, key string) (info minio.ObjectInfo, err error) {
+	err = f.do(true, func(c *Client) error { info, err = c.Stat(ctx, bucket, key); return err })
+	return info, err
+}
+
+func (f *FailoverClient) DownloadWithInfo(ctx context.Context, bucket, key string) (rc io.ReadCloser, size int64, err error) {
+	err = f.do(true, func(c *Client) error { rc, size, err = c.DownloadWithInfo(ctx, bucket, key); return err })
+	return rc, size, err
+}
+
+func (f *FailoverClient) ListBuckets(ctx context.Context) (out []minio.BucketInfo, err error) {
+	err = f.do(true, func(c *Client) error { out, err = c.ListBuckets(ctx); return err })
+	return out, err
+}
+
+func (f *FailoverClient) CreateBucket(ctx context.Context, name string, region string) error {
+	return f.do(true, func(c *Client) error { return c.CreateBucket(ctx, name, region) })
+}
+
+func (f *FailoverClient) DeleteBucket(ctx context.Context, name string) error {
+	return f.do(true, func(c *Client) error { return c.DeleteBucket(ctx, name) })
+}
+
+func (f *FailoverClient) ListObjects(ctx context.Context, bucket, prefix string, recursive bool) (out []minio.ObjectInfo, err error) {
+	err = f.do(true, func(c *Client) error { out, err = c.ListObjects(ctx, bucket, prefix, recursive); return err })
+	return out, err
+}
+
+func (f *FailoverClient) BucketStats(ctx context.Context, bucket string) (count, total int64, last time.Time, err error) {
+	err = f.do(true, func(c *Client) error { count, total, last, err = c.BucketStats(ctx, bucket); return err })
+	return count, total, last, err
+}
+
+func (f *FailoverClient) ListObjectsPage(ctx context.Context, bucket, prefix string, recursive bool, startAfter string, maxKeys int) (out []minio.ObjectInfo, truncated bool, err error) {
+	err = f.do(true, func(c *Client) error {
+		out, truncated, err = c.ListObjectsPage(ctx, bucket, prefix, recursive, startAfter, maxKeys)
+		return err
+	})
+	return out, truncated, err
+}
+
+func (f *FailoverClient) Upload(ctx context.Context, bucket, key string, reader io.Reader, size int64, contentType string) (minio.UploadInfo, error) {
+	return f.UploadWithSSE(ctx, bucket, key, reader, size, contentType, nil)
+}
+
+// UploadWithSSE only retries on the other endpoint when the reader can be rewound.
+func (f *FailoverClient) UploadWithSSE(ctx context.Context, bucket, key string, reader io.Reader, size int64, contentType string, sse encrypt.ServerSide) (info minio.UploadInfo, err error) {
+	seeker, canRewind := reader.(io.Seeker)
+	first := true
+	err = f.do(canRewind, func(c *Client) error {
+		if !first {
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+		}
+		first = false
+		info, err = c.UploadWithSSE(ctx, bucket, key, reader, size, contentType, sse)
+		return err
+	})
+	return info, err
+}
+
+func (f *FailoverClient) Download(ctx context.Context, bucket, key string) (rc io.ReadCloser, err error) {
+	err = f.do(true, func(c *Client) error { rc, err = c.Download(ctx, bucket, key); return err })
+	return rc, err
+}
+
+func (f *FailoverClient) DeleteObject(ctx context.Context, bucket, key string) error {
+	return f.do(true, func(c *Client) error { return c.DeleteObject(ctx, bucket, key) })
+}
+
+func (f *FailoverClient) CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	return f.CopyObjectWithSSE(ctx, srcBucket, srcKey, dstBucket, dstKey, nil)
+}
+
+func (f *FailoverClient) CopyObjectWithSSE(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string, sse encrypt.ServerSide) error {
+	return f.do(true, func(c *Client) error { return c.CopyObjectWithSSE(ctx, srcBucket, srcKey, dstBucket, dstKey, sse) })
+}
+
+func (f *FailoverClient) MoveObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	return f.do(true, func(c *Client) error { return c.MoveObject(ctx, srcBucket, srcKey, dstBucket, dstKey) })
+}
+
+func (f *FailoverClient) SelectObject(ctx context.Context, bucket, key, query, inputFormat, outputFormat, csvDelimiter string) (rc io.ReadCloser, err error) {
+	err = f.do(true, func(c *Client) error {
+		rc, err = c.SelectObject(ctx, bucket, key, query, inputFormat, outputFormat, csvDelimiter)
+		return err
+	})
+	return rc, err
+}
+
+func (f *FailoverClient) GetBucketPolicy(ctx context.Context, bucket string) (policy string, err error) {
+	err = f.do(true, func(c *Client) error { policy, err = c.GetBucketPolicy(ctx, bucket); return err })
+	return policy, err
+}
+
+func (f *FailoverClient) SetBucketPolicy(ctx context.Context, bucket, policy string) error {
+	return f.do(true, func(c *Client) error { return c.SetBucketPolicy(ctx, bucket, policy) })
+}
+
+func (f *FailoverClient) EnableBucketVersioning(ctx context.Context, bucket string) error {
+	return f.do(true, func(c *Client) error { return c.EnableBucketVersioning(ctx, bucket) })
+}
+
+func (f *FailoverClient) BucketVersioningEnabled(ctx context.Context, bucket string) (enabled bool, err error) {
+	err = f.do(true, func(c *Client) error { enabled, err = c.BucketVersioningEnabled(ctx, bucket); return err })
+	return enabled, err
+}
+
+func (f *FailoverClient) ListObjectVersions(ctx context.Context, bucket, key string) (out []minio.ObjectInfo, err error) {
+	err = f.do(true, func(c *Client) error { out, err = c.ListObjectVersions(ctx, bucket, key); return err })
+	return out, err
+}
+
+func (f *FailoverClient) ListBucketVersions(ctx context.Context, bucket string, maxKeys int) (out []minio.ObjectInfo, truncated bool, err error) {
+	err = f.do(true, func(c *Client) error { out, truncated, err = c.ListBucketVersions(ctx, bucket, maxKeys); return err })
+	return out, truncated, err
+}
+
+func (f *FailoverClient) RestoreObjectVersion(ctx context.Context, bucket, key, versionID string) (id string, err error) {
+	err = f.do(true, func(c *Client) error { id, err = c.RestoreObjectVersion(ctx, bucket, key, versionID); return err })
+	return id, err
+}
+
+func (f *FailoverClient) SetBucketLifecycle(ctx context.Context, bucket, rulesJSON string) error {
+	return f.do(true, func(c *Client) error { return c.SetBucketLifecycle(ctx, bucket, rulesJSON) })
+}
+
+func (f *FailoverClient) SetStorageClass(ctx context.Context, bucket, key, class string) error {
+	return f.do(true, func(c *Client) error { return c.SetStorageClass(ctx, bucket, key, class) })
+}
+
+func (f *FailoverClient) AbortMultipartUpload(ctx context.Context, bucket, key string) error {
+	return f.do(true, func(c *Client) error { return c.AbortMultipartUpload(ctx, bucket, key) })
+}
+
+func (f *FailoverClient) DeleteObjects(ctx context.Context, bucket string, keys []string) (failed []ObjectError, err error) {
+	err = f.do(true, func(c *Client) error { failed, err = c.DeleteObjects(ctx, bucket, keys); return err })
+	return failed, err
+}
+
+func (f *FailoverClient) DeleteObjectVersions(ctx context.Context, bucket string, versions []minio.ObjectInfo) (failed []ObjectError, err error) {
+	err = f.do(true, func(c *Client) error { failed, err = c.DeleteObjectVersions(ctx, bucket, versions); return err })
+	return failed, err
+}
+
+func (f *FailoverClient) GetObjectTags(ctx context.Context, bucket, key string) (t map[string]string, err error) {
+	err = f.do(true, func(c *Client) error { t, err = c.GetObjectTags(ctx, bucket, key); return err })
+	return t, err
+}
+
+func (f *FailoverClient) SetObjectTags(ctx context.Context, bucket, key string, t map[string]string) error {
+	return f.do(true, func(c *Client) error { return c.SetObjectTags(ctx, bucket, key, t) })
+}
+
+func (f *FailoverClient) GetObjectMeta(ctx context.Context, bucket, key string) (m ObjectMeta, err error) {
+	err = f.do(true, func(c *Client) error { m, err = c.GetObjectMeta(ctx, bucket, key); return err })
+	return m, err
+}
+
+func (f *FailoverClient) PresignedURL(ctx context.Context, bucket, key string, expiry time.Duration) (u string, err error) {
+	err = f.do(true, func(c *Client) error { u, err = c.PresignedURL(ctx, bucket, key, expiry); return err })
+	return u, err
+}
+
+func (f *FailoverClient) PresignedPutURL(ctx context.Context, bucket, key string, expiry time.Duration) (u string, err error) {
+	err = f.do(true, func(c *Client) error { u, err = c.PresignedPutURL(ctx, bucket, key, expiry); return err })
+	return u, err
+}
+
+// NewMultipartUpload starts the upload on the active endpoint and pins the upload ID to
+// it: the parts of an upload only exist on the endpoint that issued its ID.
+func (f *FailoverClient) NewMultipartUpload(ctx context.Context, bucket, key, contentType string, sse encrypt.ServerSide) (id string, err error) {
+	var pinned *Client
+	err = f.do(true, func(c *Client) error {
+		id, err = c.NewMultipartUpload(ctx, bucket, key, contentType, sse)
+		pinned = c
+		return err
+	})
+	if err == nil {
+		f.uploads.Store(id, pinned)
+	}
+	return id, err
+}
+
+// pinned runs call against the endpoint uploadID was started on. A failure there still
+// switches later calls to the other endpoint, but the upload itself cannot move.
+func (f *FailoverClient) pinned(uploadID string, call func(c *Client) error) error {
+	c, from := f.active()
+	if v, ok := f.uploads.Load(uploadID); ok {
+		c = v.(*Client)
+		from = f.provider.Endpoint
+		if c == f.secondary {
+			from = f.provider.SecondaryEndpoint
+		}
+	}
+	err := call(c)
+	if shouldFailover(err) && ActiveEndpoint(f.provider) == from {
+		to := SwitchEndpoint(f.provider)
+		if OnFailover != nil {
+			OnFailover(f.provider.ID, from, to, err)
+		}
+	}
+	return err
+}
+
+func (f *FailoverClient) UploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int, reader io.Reader, size int64, sse encrypt.ServerSide) (p minio.CompletePart, err error) {
+	err = f.pinned(uploadID, func(c *Client) error {
+		p, err = c.UploadPart(ctx, bucket, key, uploadID, partNumber, reader, size, sse)
+		return err
+	})
+	return p, err
+}
+
+func (f *FailoverClient) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []minio.CompletePart) (info minio.UploadInfo, err error) {
+	err = f.pinned(uploadID, func(c *Client) error {
+		info, err = c.CompleteMultipartUpload(ctx, bucket, key, uploadID, parts)
+		return err
+	})
+	if err == nil {
+		f.uploads.Delete(uploadID) // a failed completion is still aborted on this endpoint
+	}
+	return info, err
+}
+
+func (f *FailoverClient) AbortUpload(ctx context.Context, bucket, key, uploadID string) error {
+	err := f.pinned(uploadID, func(c *Client) error { return c.AbortUpload(ctx, bucket, key, uploadID) })
+	f.uploads.Delete(uploadID)
+	return err
+}
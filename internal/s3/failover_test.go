@@ -0,0 +1,187 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/arencloud/hermes/internal/models"
+	minio "github.com/minio/minio-go/v7"
+)
+
+const listBucketsXML = `<?xml version="1.0" encoding="UTF-8"?>
+<ListAllMyBucketsResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Owner><ID>x</ID></Owner><Buckets><Bucket><Name>b1</Name><CreationDate>2024-01-01T00:00:00.000Z</CreationDate></Bucket></Buckets></ListAllMyBucketsResult>`
+
+func fakeS3(status int, hits *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(status)
+		if status == 200 {
+			w.Write([]byte(listBucketsXML))
+		}
+	}))
+}
+
+func TestFailoverClientSwitchesOn5xx(t *testing.T) {
+	var primaryHits, secondaryHits atomic.Int32
+	primary := fakeS3(503, &primaryHits)
+	defer primary.Close()
+	secondary := fakeS3(200, &secondaryHits)
+	defer secondary.Close()
+
+	type switched struct{ from, to string }
+	var got []switched
+	prev := OnFailover
+	OnFailover = func(_ uint, from, to string, _ error) { got = append(got, switched{from, to}) }
+	defer func() { OnFailover = prev }()
+
+	p := models.Provider{ID: 9001, Type: "minio", Endpoint: primary.URL, SecondaryEndpoint: secondary.URL, FailoverEnabled: true, Region: "us-east-1", AccessKey: "ak", SecretKey: "sk"}
+	c, err := NewFromProvider(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.(*FailoverClient); !ok {
+		t.Fatalf("expected a FailoverClient, got %T", c)
+	}
+	buckets, err := c.ListBuckets(context.Background())
+	if err != nil || len(buckets) != 1 || buckets[0].Name != "b1" {
+		t.Fatalf("expected the secondary to answer, got %v %v", buckets, err)
+	}
+	if len(got) != 1 || got[0].from != primary.URL || got[0].to != secondary.URL {
+		t.Fatalf("unexpected failover record: %+v", got)
+	}
+	if ActiveEndpoint(p) != secondary.URL {
+		t.Fatalf("active endpoint = %q", ActiveEndpoint(p))
+	}
+	// later clients go straight to the secondary
+	before := primaryHits.Load()
+	c, _ = NewFromProvider(p)
+	if _, err := c.ListBuckets(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if primaryHits.Load() != before {
+		t.Fatal("primary called again after failover")
+	}
+	// a manual switch goes back to the primary
+	if ep := SwitchEndpoint(p); ep != primary.URL {
+		t.Fatalf("switch returned %q", ep)
+	}
+}
+
+func TestFailoverClientIgnoresClientErrors(t *testing.T) {
+	var primaryHits, secondaryHits atomic.Int32
+	primary := fakeS3(403, &primaryHits)
+	defer primary.Close()
+	secondary := fakeS3(200, &secondaryHits)
+	defer secondary.Close()
+	p := models.Provider{ID: 9002, Type: "minio", Endpoint: primary.URL, SecondaryEndpoint: secondary.URL, FailoverEnabled: true, Region: "us-east-1", AccessKey: "ak", SecretKey: "sk"}
+	c, err := NewFromProvider(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ListBuckets(context.Background()); err == nil {
+		t.Fatal("expected the 403 to be returned")
+	}
+	if secondaryHits.Load() != 0 || ActiveEndpoint(p) != primary.URL {
+		t.Fatal("a 403 must not trigger failover")
+	}
+}
+
+func TestShouldFailover(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{context.Canceled, false},
+		{minio.ErrorResponse{StatusCode: 500, Code: "InternalError"}, true},
+		{minio.ErrorResponse{StatusCode: 404, Code: "NoSuchKey"}, false},
+		{minio.ErrorResponse{Code: "InvalidArgument"}, false},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+	}
+	for _, c := range cases {
+		if got := shouldFailover(c.err); got != c.want {
+			t.Fatalf("shouldFailover(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+// fakeMultipartS3 answers the multipart upload calls and counts the parts it received.
+func fakeMultipartS3(uploadID string, parts *atomic.Int32, completed *atomic.Bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		w.Header().Set("Content-Type", "application/xml")
+		switch {
+		case r.Method == "POST" && q.Has("uploads"):
+			w.Write([]byte(`<InitiateMultipartUploadResult><Bucket>b</Bucket><Key>k</Key><UploadId>` + uploadID + `</UploadId></InitiateMultipartUploadResult>`))
+		case r.Method == "PUT" && q.Get("uploadId") == uploadID:
+			io.Copy(io.Discard, r.Body)
+			parts.Add(1)
+			w.Header().Set("ETag", `"etag-`+q.Get("partNumber")+`"`)
+		case r.Method == "POST" && q.Get("uploadId") == uploadID:
+			io.Copy(io.Discard, r.Body)
+			completed.Store(true)
+			w.Write([]byte(`<CompleteMultipartUploadResult><Bucket>b</Bucket><Key>k</Key><ETag>"done"</ETag></CompleteMultipartUploadResult>`))
+		default:
+			w.WriteHeader(404)
+			w.Write([]byte(`<Error><Code>NoSuchUpload</Code></Error>`))
+		}
+	}))
+}
+
+func TestFailoverClientMultipartStaysOnOneEndpoint(t *testing.T) {
+	var primaryParts, secondaryParts atomic.Int32
+	var primaryDone, secondaryDone atomic.Bool
+	primary := fakeMultipartS3("up-primary", &primaryParts, &primaryDone)
+	defer primary.Close()
+	secondary := fakeMultipartS3("up-secondary", &secondaryParts, &secondaryDone)
+	defer secondary.Close()
+	p := models.Provider{ID: 9003, Type: "minio", Endpoint: primary.URL, SecondaryEndpoint: secondary.URL, FailoverEnabled: true, Region: "us-east-1", AccessKey: "ak", SecretKey: "sk"}
+	c, err := NewFromProvider(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc, ok := c.(MultipartClient)
+	if !ok {
+		t.Fatalf("%T does not implement MultipartClient", c)
+	}
+	ctx := context.Background()
+	id, err := fc.NewMultipartUpload(ctx, "bucket", "k", "application/octet-stream", nil)
+	if err != nil || id != "up-primary" {
+		t.Fatalf("new upload: %q %v", id, err)
+	}
+	// a failover in the middle of the upload does not move its parts
+	SwitchEndpoint(p)
+	defer activeSecondary.Delete(p.ID)
+	var parts []minio.CompletePart
+	for i := 1; i <= 2; i++ {
+		part, err := fc.UploadPart(ctx, "bucket", "k", id, i, strings.NewReader("data"), 4, nil)
+		if err != nil {
+			t.Fatalf("part %d: %v", i, err)
+		}
+		parts = append(parts, part)
+	}
+	if _, err := fc.CompleteMultipartUpload(ctx, "bucket", "k", id, parts); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if primaryParts.Load() != 2 || !primaryDone.Load() || secondaryParts.Load() != 0 || secondaryDone.Load() {
+		t.Fatalf("parts primary=%d secondary=%d, completed primary=%v secondary=%v", primaryParts.Load(), secondaryParts.Load(), primaryDone.Load(), secondaryDone.Load())
+	}
+
+	// UploadAuto takes the multipart path for a failover client
+	before := secondaryParts.Load()
+	opts := MultipartOptions{Threshold: 1, ChunkSize: MinPartSize, Workers: 2}
+	if _, err := UploadAuto(ctx, c, "bucket", "k", &patternReader{n: MinPartSize + 1}, MinPartSize+1, "application/octet-stream", nil, opts); err != nil {
+		t.Fatalf("upload auto: %v", err)
+	}
+	if secondaryParts.Load()-before != 2 || !secondaryDone.Load() {
+		t.Fatalf("expected 2 parts on the active secondary, got %d", secondaryParts.Load()-before)
+	}
+}
@@ -0,0 +1,50 @@
+package s3
+
+import (
+	"context"
+	"io"
+	"time"
+
+	minio "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+// ClientInterface is the set of operations handlers use on a provider, implemented by
+// Client and, in tests, by MockClient.
+type ClientInterface interface {
+	Stat(ctx context.Context, bucket, key string) (minio.ObjectInfo, error)
+	DownloadWithInfo(ctx context.Context, bucket, key string) (io.ReadCloser, int64, error)
+	ListBuckets(ctx context.Context) ([]minio.BucketInfo, error)
+	CreateBucket(ctx context.Context, name string, region string) error
+	DeleteBucket(ctx context.Context, name string) error
+	ListObjects(ctx context.Context, bucket, prefix string, recursive bool) ([]minio.ObjectInfo, error)
+	ListObjectsPage(ctx context.Context, bucket, prefix string, recursive bool, startAfter string, maxKeys int) ([]minio.ObjectInfo, bool, error)
+	BucketStats(ctx context.Context, bucket string) (objectCount, totalBytes int64, lastModified time.Time, err error)
+	Upload(ctx context.Context, bucket, key string, reader io.Reader, size int64, contentType string) (minio.UploadInfo, error)
+	UploadWithSSE(ctx context.Context, bucket, key string, reader io.Reader, size int64, contentType string, sse encrypt.ServerSide) (minio.UploadInfo, error)
+	Download(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+	DeleteObject(ctx context.Context, bucket, key string) error
+	CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error
+	CopyObjectWithSSE(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string, sse encrypt.ServerSide) error
+	MoveObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error
+	SelectObject(ctx context.Context, bucket, key, query, inputFormat, outputFormat, csvDelimiter string) (io.ReadCloser, error)
+	GetBucketPolicy(ctx context.Context, bucket string) (string, error)
+	SetBucketPolicy(ctx context.Context, bucket, policy string) error
+	EnableBucketVersioning(ctx context.Context, bucket string) error
+	BucketVersioningEnabled(ctx context.Context, bucket string) (bool, error)
+	ListObjectVersions(ctx context.Context, bucket, key string) ([]minio.ObjectInfo, error)
+	ListBucketVersions(ctx context.Context, bucket string, maxKeys int) ([]minio.ObjectInfo, bool, error)
+	RestoreObjectVersion(ctx context.Context, bucket, key, versionID string) (string, error)
+	SetBucketLifecycle(ctx context.Context, bucket, rulesJSON string) error
+	SetStorageClass(ctx context.Context, bucket, key, class string) error
+	AbortMultipartUpload(ctx context.Context, bucket, key string) error
+	DeleteObjects(ctx context.Context, bucket string, keys []string) ([]ObjectError, error)
+	DeleteObjectVersions(ctx context.Context, bucket string, versions []minio.ObjectInfo) ([]ObjectError, error)
+	GetObjectTags(ctx context.Context, bucket, key string) (map[string]string, error)
+	SetObjectTags(ctx context.Context, bucket, key string, tags map[string]string) error
+	GetObjectMeta(ctx context.Context, bucket, key string) (ObjectMeta, error)
+	PresignedURL(ctx context.Context, bucket, key string, expiry time.Duration) (string, error)
+	PresignedPutURL(ctx context.Context, bucket, key string, expiry time.Duration) (string, error)
+}
+
+var _ ClientInterface = (*Client)(nil)
@@ -0,0 +1,343 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	minio "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+// ErrNotMocked is returned by MockClient methods whose On* func is not set.
+var ErrNotMocked = errors.New("s3 mock: method not configured")
+
+// MockClient is a ClientInterface for tests. Each method calls the matching On* func;
+// unset funcs return ErrNotMocked.
+type MockClient struct {
+	OnStat                   func(bucket, key string) (minio.ObjectInfo, error)
+	OnDownloadWithInfo       func(bucket, key string) (io.ReadCloser, int64, error)
+	OnListBuckets            func() ([]minio.BucketInfo, error)
+	OnCreateBucket           func(name, region string) error
+	OnDeleteBucket           func(name string) error
+	OnListObjects            func(bucket, prefix string) ([]minio.ObjectInfo, error)
+	OnUpload                 func(bucket, key string, reader io.Reader, size int64, contentType string, sse encrypt.ServerSide) (minio.UploadInfo, error)
+	OnDownload               func(bucket, key string) (io.ReadCloser, error)
+	OnDeleteObject           func(bucket, key string) error
+	OnCopyObject             func(srcBucket, srcKey, dstBucket, dstKey string, sse encrypt.ServerSide) error
+	OnMoveObject             func(srcBucket, srcKey, dstBucket, dstKey string) error
+	OnSelectObject           func(bucket, key, query, inputFormat, outputFormat, csvDelimiter string) (io.ReadCloser, error)
+	OnGetBucketPolicy        func(bucket string) (string, error)
+	OnSetBucketPolicy        func(bucket, policy string) error
+	OnEnableBucketVersioning func(bucket string) error
+	OnVersioningEnabled      func(bucket string) (bool, error)
+	OnListObjectVersions     func(bucket, key string) ([]minio.ObjectInfo, error)
+	OnListBucketVersions     func(bucket string) ([]minio.ObjectInfo, error)
+	OnRestoreObjectVersion   func(bucket, key, versionID string) (string, error)
+	OnSetBucketLifecycle     func(bucket, rulesJSON string) error
+	OnSetStorageClass        func(bucket, key, class string) error
+	OnAbortMultipartUpload   func(bucket, key string) error
+	OnDeleteObjects          func(bucket string, keys []string) ([]ObjectError, error)
+	OnDeleteObjectVersions   func(bucket string, versions []minio.ObjectInfo) ([]ObjectError, error)
+	OnGetObjectTags          func(bucket, key string) (map[string]string, error)
+	OnSetObjectTags          func(bucket, key string, tags map[string]string) error
+	OnPresignedURL           func(bucket, key string, expiry time.Duration) (string, error)
+	OnPresignedPutURL        func(bucket, key string, expiry time.Duration) (string, error)
+	OnNewMultipartUpload     func(bucket, key, contentType string, sse encrypt.ServerSide) (string, error)
+	OnUploadPart             func(bucket, key, uploadID string, partNumber int, reader io.Reader, size int64) (minio.CompletePart, error)
+	OnCompleteMultipart      func(bucket, key, uploadID string, parts []minio.CompletePart) (minio.UploadInfo, error)
+	OnAbortUpload            func(bucket, key, uploadID string) error
+}
+
+var (
+	_ ClientInterface = (*MockClient)(nil)
+	_ MultipartClient = (*MockClient)(nil)
+)
+
+// NewMock returns a MockClient with no methods configured.
+func NewMock() *MockClient { return &MockClient{} }
+
+// Reset clears every configured response.
+func (m *MockClient) Reset() { *m = MockClient{} }
+
+func (m *MockClient) Stat(ctx context.Context, bucket, key string) (minio.ObjectInfo, error) {
+	if m.OnStat == nil {
+		return minio.ObjectInfo{}, ErrNotMocked
+	}
+	return m.OnStat(bucket, key)
+}
+
+func (m *MockClient) DownloadWithInfo(ctx context.Context, bucket, key string) (io.ReadCloser, int64, error) {
+	if m.OnDownloadWithInfo == nil {
+		return nil, 0, ErrNotMocked
+	}
+	return m.OnDownloadWithInfo(bucket, key)
+}
+
+func (m *MockClient) ListBuckets(ctx context.Context) ([]minio.BucketInfo, error) {
+	if m.OnListBuckets == nil {
+		return nil, ErrNotMocked
+	}
+	return m.OnListBuckets()
+}
+
+func (m *MockClient) CreateBucket(ctx context.Context, name string, region string) error {
+	if m.OnCreateBucket == nil {
+		return ErrNotMocked
+	}
+	return m.OnCreateBucket(name, region)
+}
+
+func (m *MockClient) DeleteBucket(ctx context.Context, name string) error {
+	if m.OnDeleteBucket == nil {
+		return ErrNotMocked
+	}
+	return m.OnDeleteBucket(name)
+}
+
+// ListObjects ignores recursive; the mock decides what a listing returns.
+func (m *MockClient) ListObjects(ctx context.Context, bucket, prefix string, recursive bool) ([]minio.ObjectInfo, error) {
+	if m.OnListObjects == nil {
+		return nil, ErrNotMocked
+	}
+	return m.OnListObjects(bucket, prefix)
+}
+
+// BucketStats sums what OnListObjects returns. A listing still running when ctx ends
+// yields partial totals, as with the real client.
+func (m *MockClient) BucketStats(ctx context.Context, bucket string) (int64, int64, time.Time, error) {
+	items, err := m.ListObjects(ctx, bucket, "", true)
+	if err != nil {
+		return 0, 0, time.Time{}, err
+	}
+	ch := make(chan minio.ObjectInfo)
+	go func() {
+		defer close(ch)
+		for _, o := range items {
+			select {
+			case ch <- o:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return sumObjects(ctx, ch)
+}
+
+// ListObjectsPage pages through what OnListObjects returns, ordered by key.
+func (m *MockClient) ListObjectsPage(ctx context.Context, bucket, prefix string, recursive bool, startAfter string, maxKeys int) ([]minio.ObjectInfo, bool, error) {
+	items, err := m.ListObjects(ctx, bucket, prefix, recursive)
+	if err != nil {
+		return nil, false, err
+	}
+	page, truncated := pageObjects(items, startAfter, maxKeys)
+	return page, truncated, nil
+}
+
+func (m *MockClient) Upload(ctx context.Context, bucket, key string, reader io.Reader, size int64, contentType string) (minio.UploadInfo, error) {
+	return m.UploadWithSSE(ctx, bucket, key, reader, size, contentType, nil)
+}
+
+func (m *MockClient) UploadWithSSE(ctx context.Context, bucket, key string, reader io.Reader, size int64, contentType string, sse encrypt.ServerSide) (minio.UploadInfo, error) {
+	if m.OnUpload == nil {
+		return minio.UploadInfo{}, ErrNotMocked
+	}
+	return m.OnUpload(bucket, key, reader, size, contentType, sse)
+}
+
+func (m *MockClient) Download(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	if m.OnDownload == nil {
+		return nil, ErrNotMocked
+	}
+	return m.OnDownload(bucket, key)
+}
+
+func (m *MockClient) DeleteObject(ctx context.Context, bucket, key string) error {
+	if m.OnDeleteObject == nil {
+		return ErrNotMocked
+	}
+	return m.OnDeleteObject(bucket, key)
+}
+
+func (m *MockClient) CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	return m.CopyObjectWithSSE(ctx, srcBucket, srcKey, dstBucket, dstKey, nil)
+}
+
+func (m *MockClient) CopyObjectWithSSE(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string, sse encrypt.ServerSide) error {
+	if m.OnCopyObject == nil {
+		return ErrNotMocked
+	}
+	return m.OnCopyObject(srcBucket, srcKey, dstBucket, dstKey, sse)
+}
+
+func (m *MockClient) MoveObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	if m.OnMoveObject == nil {
+		return ErrNotMocked
+	}
+	return m.OnMoveObject(srcBucket, srcKey, dstBucket, dstKey)
+}
+
+func (m *MockClient) SelectObject(ctx context.Context, bucket, key, query, inputFormat, outputFormat, csvDelimiter string) (io.ReadCloser, error) {
+	if m.OnSelectObject == nil {
+		return nil, ErrNotMocked
+	}
+	return m.OnSelectObject(bucket, key, query, inputFormat, outputFormat, csvDelimiter)
+}
+
+func (m *MockClient) GetBucketPolicy(ctx context.Context, bucket string) (string, error) {
+	if m.OnGetBucketPolicy == nil {
+		return "", ErrNotMocked
+	}
+	return m.OnGetBucketPolicy(bucket)
+}
+
+func (m *MockClient) SetBucketPolicy(ctx context.Context, bucket, policy string) error {
+	if m.OnSetBucketPolicy == nil {
+		return ErrNotMocked
+	}
+	return m.OnSetBucketPolicy(bucket, policy)
+}
+
+func (m *MockClient) EnableBucketVersioning(ctx context.Context, bucket string) error {
+	if m.OnEnableBucketVersioning == nil {
+		return ErrNotMocked
+	}
+	return m.OnEnableBucketVersioning(bucket)
+}
+
+func (m *MockClient) BucketVersioningEnabled(ctx context.Context, bucket string) (bool, error) {
+	if m.OnVersioningEnabled == nil {
+		return false, ErrNotMocked
+	}
+	return m.OnVersioningEnabled(bucket)
+}
+
+func (m *MockClient) ListObjectVersions(ctx context.Context, bucket, key string) ([]minio.ObjectInfo, error) {
+	if m.OnListObjectVersions == nil {
+		return nil, ErrNotMocked
+	}
+	return m.OnListObjectVersions(bucket, key)
+}
+
+// ListBucketVersions returns the first maxKeys of what OnListBucketVersions returns.
+func (m *MockClient) ListBucketVersions(ctx context.Context, bucket string, maxKeys int) ([]minio.ObjectInfo, bool, error) {
+	if m.OnListBucketVersions == nil {
+		return nil, false, ErrNotMocked
+	}
+	items, err := m.OnListBucketVersions(bucket)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(items) > maxKeys {
+		return items[:maxKeys], true, nil
+	}
+	return items, false, nil
+}
+
+func (m *MockClient) RestoreObjectVersion(ctx context.Context, bucket, key, versionID string) (string, error) {
+	if m.OnRestoreObjectVersion == nil {
+		return "", ErrNotMocked
+	}
+	return m.OnRestoreObjectVersion(bucket, key, versionID)
+}
+
+func (m *MockClient) SetBucketLifecycle(ctx context.Context, bucket, rulesJSON string) error {
+	if m.OnSetBucketLifecycle == nil {
+		return ErrNotMocked
+	}
+	return m.OnSetBucketLifecycle(bucket, rulesJSON)
+}
+
+func (m *MockClient) SetStorageClass(ctx context.Context, bucket, key, class string) error {
+	if m.OnSetStorageClass == nil {
+		return ErrNotMocked
+	}
+	return m.OnSetStorageClass(bucket, key, class)
+}
+
+func (m *MockClient) AbortMultipartUpload(ctx context.Context, bucket, key string) error {
+	if m.OnAbortMultipartUpload == nil {
+		return ErrNotMocked
+	}
+	return m.OnAbortMultipartUpload(bucket, key)
+}
+
+func (m *MockClient) DeleteObjects(ctx context.Context, bucket string, keys []string) ([]ObjectError, error) {
+	if m.OnDeleteObjects == nil {
+		return nil, ErrNotMocked
+	}
+	return m.OnDeleteObjects(bucket, keys)
+}
+
+func (m *MockClient) DeleteObjectVersions(ctx context.Context, bucket string, versions []minio.ObjectInfo) ([]ObjectError, error) {
+	if m.OnDeleteObjectVersions == nil {
+		return nil, ErrNotMocked
+	}
+	return m.OnDeleteObjectVersions(bucket, versions)
+}
+
+func (m *MockClient) GetObjectTags(ctx context.Context, bucket, key string) (map[string]string, error) {
+	if m.OnGetObjectTags == nil {
+		return nil, ErrNotMocked
+	}
+	return m.OnGetObjectTags(bucket, key)
+}
+
+func (m *MockClient) SetObjectTags(ctx context.Context, bucket, key string, tags map[string]string) error {
+	if m.OnSetObjectTags == nil {
+		return ErrNotMocked
+	}
+	return m.OnSetObjectTags(bucket, key, tags)
+}
+
+// GetObjectMeta is answered from OnStat, as the real client derives it from StatObject.
+func (m *MockClient) GetObjectMeta(ctx context.Context, bucket, key string) (ObjectMeta, error) {
+	info, err := m.Stat(ctx, bucket, key)
+	if err != nil {
+		return ObjectMeta{}, err
+	}
+	return objectMeta(info), nil
+}
+
+func (m *MockClient) PresignedURL(ctx context.Context, bucket, key string, expiry time.Duration) (string, error) {
+	if m.OnPresignedURL == nil {
+		return "", ErrNotMocked
+	}
+	return m.OnPresignedURL(bucket, key, expiry)
+}
+
+func (m *MockClient) PresignedPutURL(ctx context.Context, bucket, key string, expiry time.Duration) (string, error) {
+	if m.OnPresignedPutURL == nil {
+		return "", ErrNotMocked
+	}
+	return m.OnPresignedPutURL(bucket, key, expiry)
+}
+
+func (m *MockClient) NewMultipartUpload(ctx context.Context, bucket, key, contentType string, sse encrypt.ServerSide) (string, error) {
+	if m.OnNewMultipartUpload == nil {
+		return "", ErrNotMocked
+	}
+	return m.OnNewMultipartUpload(bucket, key, contentType, sse)
+}
+
+func (m *MockClient) UploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int, reader io.Reader, size int64, sse encrypt.ServerSide) (minio.CompletePart, error) {
+	if m.OnUploadPart == nil {
+		return minio.CompletePart{}, ErrNotMocked
+	}
+	return m.OnUploadPart(bucket, key, uploadID, partNumber, reader, size)
+}
+
+func (m *MockClient) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []minio.CompletePart) (minio.UploadInfo, error) {
+	if m.OnCompleteMultipart == nil {
+		return minio.UploadInfo{}, ErrNotMocked
+	}
+	return m.OnCompleteMultipart(bucket, key, uploadID, parts)
+}
+
+func (m *MockClient) AbortUpload(ctx context.Context, bucket, key, uploadID string) error {
+	if m.OnAbortUpload == nil {
+		return ErrNotMocked
+	}
+	return m.OnAbortUpload(bucket, key, uploadID)
+}
@@ -0,0 +1,195 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	minio "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+// MultipartClient is the part-level upload API UploadAuto drives. Client, FailoverClient
+// and MockClient implement it; FailoverClient keeps each upload ID on the endpoint that
+// issued it.
+type MultipartClient interface {
+	NewMultipartUpload(ctx context.Context, bucket, key, contentType string, sse encrypt.ServerSide) (string, error)
+	UploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int, reader io.Reader, size int64, sse encrypt.ServerSide) (minio.CompletePart, error)
+	CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []minio.CompletePart) (minio.UploadInfo, error)
+	AbortUpload(ctx context.Context, bucket, key, uploadID string) error
+}
+
+var _ MultipartClient = (*Client)(nil)
+
+// MultipartOptions controls when and how UploadAuto switches to a multipart upload.
+type MultipartOptions struct {
+	Threshold int64 // objects larger than this are uploaded in parts
+	ChunkSize int64 // size of each part (the last one may be smaller)
+	Workers   int   // parts uploaded concurrently; also bounds the chunks held in memory
+}
+
+// DefaultMultipartOptions are the AUTO_MULTIPART_THRESHOLD_MB, MULTIPART_CHUNK_MB and
+// MULTIPART_WORKERS defaults.
+var DefaultMultipartOptions = MultipartOptions{Threshold: 100 << 20, ChunkSize: 64 << 20, Workers: 3}
+
+// MinPartSize is the smallest part S3 accepts for any but the last part.
+const MinPartSize = 5 << 20
+
+func (c *Client) NewMultipartUpload(ctx context.Context, bucket, key, contentType string, sse encrypt.ServerSide) (string, error) {
+	core := minio.Core{Client: c.mc}
+	return core.NewMultipartUpload(ctx, bucket, key, minio.PutObjectOptions{ContentType: contentType, ServerSideEncryption: sse})
+}
+
+// UploadPart sends one part; sse is only repeated on parts for SSE-C, as S3 requires.
+func (c *Client) UploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int, reader io.Reader, size int64, sse encrypt.ServerSide) (minio.CompletePart, error) {
+	core := minio.Core{Client: c.mc}
+	var opts minio.PutObjectPartOptions
+	if sse != nil && sse.Type() == encrypt.SSEC {
+		opts.SSE = sse
+	}
+	p, err := core.PutObjectPart(ctx, bucket, key, uploadID, partNumber, reader, size, opts)
+	if err != nil {
+		return minio.CompletePart{}, err
+	}
+	return minio.CompletePart{PartNumber: p.PartNumber, ETag: p.ETag}, nil
+}
+
+func (c *Client) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []minio.CompletePart) (minio.UploadInfo, error) {
+	core := minio.Core{Client: c.mc}
+	return core.CompleteMultipartUpload(ctx, bucket, key, uploadID, parts, minio.PutObjectOptions{})
+}
+
+func (c *Client) AbortUpload(ctx context.Context, bucket, key, uploadID string) error {
+	core := minio.Core{Client: c.mc}
+	return core.AbortMultipartUpload(ctx, bucket, key, uploadID)
+}
+
+// UploadAuto uploads reader in a single PUT when it is at most opts.Threshold bytes and
+// in opts.ChunkSize parts otherwise. size is the expected length, or -1 when unknown: then
+// up to Threshold+1 bytes are spooled to a temp file to decide. Clients that do not
+// implement MultipartClient always use the single PUT.
+func UploadAuto(ctx context.Context, c ClientInterface, bucket, key string, reader io.Reader, size int64, contentType string, sse encrypt.ServerSide, opts MultipartOptions) (minio.UploadInfo, error) {
+	mc, ok := c.(MultipartClient)
+	if !ok || opts.Threshold <= 0 {
+		return c.UploadWithSSE(ctx, bucket, key, reader, -1, contentType, sse)
+	}
+	if size >= 0 {
+		if size <= opts.Threshold {
+			return c.UploadWithSSE(ctx, bucket, key, reader, -1, contentType, sse)
+		}
+		return uploadMultipart(ctx, mc, bucket, key, reader, contentType, sse, opts)
+	}
+	spool, err := os.CreateTemp("", "hermes-upload-*")
+	if err != nil {
+		return minio.UploadInfo{}, err
+	}
+	defer func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}()
+	n, err := io.CopyN(spool, reader, opts.Threshold+1)
+	if err != nil && err != io.EOF {
+		return minio.UploadInfo{}, err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return minio.UploadInfo{}, err
+	}
+	if n <= opts.Threshold {
+		return c.UploadWithSSE(ctx, bucket, key, spool, n, contentType, sse)
+	}
+	return uploadMultipart(ctx, mc, bucket, key, io.MultiReader(spool, reader), contentType, sse, opts)
+}
+
+// uploadMultipart reads ChunkSize parts from reader in order and uploads up to Workers
+// of them concurrently. On any failure the upload is aborted so no parts are left behind.
+func uploadMultipart(ctx context.Context, mc MultipartClient, bucket, key string, reader io.Reader, contentType string, sse encrypt.ServerSide, opts MultipartOptions) (minio.UploadInfo, error) {
+	chunk := opts.ChunkSize
+	if chunk < MinPartSize {
+		chunk = MinPartSize
+	}
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	uploadID, err := mc.NewMultipartUpload(ctx, bucket, key, contentType, sse)
+	if err != nil {
+		return minio.UploadInfo{}, err
+	}
+	pctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		parts    []minio.CompletePart
+		firstErr error
+		total    int64
+	)
+	fail := func(err error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+		mu.Unlock()
+	}
+	sem := make(chan struct{}, workers)
+	for num := 1; ; num++ {
+		sem <- struct{}{} // at most workers chunks are buffered at once
+		if pctx.Err() != nil {
+			<-sem
+			break
+		}
+		buf := make([]byte, chunk)
+		n, err := io.ReadFull(reader, buf)
+		last := err == io.ErrUnexpectedEOF // a short final part
+		if n == 0 || (err != nil && !last) {
+			<-sem
+			if err != io.EOF {
+				fail(err)
+			}
+			break
+		}
+		total += int64(n)
+		wg.Add(1)
+		go func(num int, data []byte) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			p, err := mc.UploadPart(pctx, bucket, key, uploadID, num, bytes.NewReader(data), int64(len(data)), sse)
+			if err != nil {
+				fail(err)
+				return
+			}
+			mu.Lock()
+			parts = append(parts, p)
+			mu.Unlock()
+		}(num, buf[:n])
+		if last {
+			break
+		}
+	}
+	wg.Wait()
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = ctx.Err()
+	}
+	if firstErr != nil {
+		// the request context may be gone; abort on a fresh one so parts are not orphaned
+		if err := mc.AbortUpload(context.WithoutCancel(ctx), bucket, key, uploadID); err != nil {
+			return minio.UploadInfo{}, errors.Join(firstErr, err)
+		}
+		return minio.UploadInfo{}, firstErr
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+	info, err := mc.CompleteMultipartUpload(ctx, bucket, key, uploadID, parts)
+	if err != nil {
+		return minio.UploadInfo{}, err
+	}
+	if info.Size == 0 {
+		info.Size = total
+	}
+	return info, nil
+}
@@ -0,0 +1,155 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	minio "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+// patternReader yields n bytes without holding them in memory.
+type patternReader struct{ n int64 }
+
+func (p *patternReader) Read(b []byte) (int, error) {
+	if p.n <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(b)) > p.n {
+		b = b[:p.n]
+	}
+	for i := range b {
+		b[i] = byte(i)
+	}
+	p.n -= int64(len(b))
+	return len(b), nil
+}
+
+// multipartMock records part sizes and the peak number of concurrent part uploads.
+func multipartMock(t *testing.T) (*MockClient, *sync.Map, *int32) {
+	t.Helper()
+	m := NewMock()
+	var sizes sync.Map
+	var inFlight, peak int32
+	m.OnUpload = func(bucket, key string, r io.Reader, size int64, ct string, sse encrypt.ServerSide) (minio.UploadInfo, error) {
+		n, err := io.Copy(io.Discard, r)
+		return minio.UploadInfo{Key: key, Size: n}, err
+	}
+	m.OnNewMultipartUpload = func(bucket, key, ct string, sse encrypt.ServerSide) (string, error) { return "up-1", nil }
+	m.OnUploadPart = func(bucket, key, id string, num int, r io.Reader, size int64) (minio.CompletePart, error) {
+		cur := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if cur <= p || atomic.CompareAndSwapInt32(&peak, p, cur) {
+				break
+			}
+		}
+		n, err := io.Copy(io.Discard, r)
+		if n != size {
+			t.Errorf("part %d: read %d bytes, declared %d", num, n, size)
+		}
+		sizes.Store(num, n)
+		return minio.CompletePart{PartNumber: num, ETag: "etag"}, err
+	}
+	m.OnCompleteMultipart = func(bucket, key, id string, parts []minio.CompletePart) (minio.UploadInfo, error) {
+		for i, p := range parts {
+			if p.PartNumber != i+1 {
+				t.Errorf("parts not in order: %+v", parts)
+			}
+		}
+		return minio.UploadInfo{Key: key}, nil
+	}
+	return m, &sizes, &peak
+}
+
+func TestUploadAutoMultipart200MB(t *testing.T) {
+	const total = 200 << 20
+	for _, tc := range []struct {
+		name string
+		size int64
+	}{{"known length", total}, {"unknown length", -1}} {
+		t.Run(tc.name, func(t *testing.T) {
+			m, sizes, peak := multipartMock(t)
+			singleCalled := false
+			m.OnUpload = func(string, string, io.Reader, int64, string, encrypt.ServerSide) (minio.UploadInfo, error) {
+				singleCalled = true
+				return minio.UploadInfo{}, nil
+			}
+			info, err := UploadAuto(context.Background(), m, "b", "big.bin", &patternReader{n: total}, tc.size, "application/octet-stream", nil, DefaultMultipartOptions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if singleCalled {
+				t.Fatal("expected a multipart upload, got a single PUT")
+			}
+			if info.Size != total {
+				t.Fatalf("size = %d, want %d", info.Size, total)
+			}
+			want := map[int]int64{1: 64 << 20, 2: 64 << 20, 3: 64 << 20, 4: 8 << 20}
+			for num, n := range want {
+				got, ok := sizes.Load(num)
+				if !ok || got.(int64) != n {
+					t.Fatalf("part %d size = %v, want %d", num, got, n)
+				}
+			}
+			if _, ok := sizes.Load(5); ok {
+				t.Fatal("unexpected fifth part")
+			}
+			if p := atomic.LoadInt32(peak); p > int32(DefaultMultipartOptions.Workers) {
+				t.Fatalf("%d concurrent parts, want at most %d", p, DefaultMultipartOptions.Workers)
+			}
+		})
+	}
+}
+
+func TestUploadAutoSmallUsesSinglePut(t *testing.T) {
+	for _, size := range []int64{5, -1} {
+		m, _, _ := multipartMock(t)
+		var gotSize int64
+		m.OnUpload = func(bucket, key string, r io.Reader, size int64, ct string, sse encrypt.ServerSide) (minio.UploadInfo, error) {
+			gotSize = size
+			n, _ := io.Copy(io.Discard, r)
+			return minio.UploadInfo{Size: n}, nil
+		}
+		m.OnNewMultipartUpload = nil
+		info, err := UploadAuto(context.Background(), m, "b", "k", strings.NewReader("hello"), size, "text/plain", nil, DefaultMultipartOptions)
+		if err != nil || info.Size != 5 {
+			t.Fatalf("size hint %d: info=%+v err=%v", size, info, err)
+		}
+		// a spooled upload knows its exact length
+		if size == -1 && gotSize != 5 {
+			t.Fatalf("spooled upload sent size %d, want 5", gotSize)
+		}
+	}
+}
+
+func TestUploadAutoAbortsOnPartFailure(t *testing.T) {
+	m, _, _ := multipartMock(t)
+	boom := errors.New("boom")
+	m.OnUploadPart = func(bucket, key, id string, num int, r io.Reader, size int64) (minio.CompletePart, error) {
+		if num == 2 {
+			return minio.CompletePart{}, boom
+		}
+		return minio.CompletePart{PartNumber: num}, nil
+	}
+	aborted := ""
+	m.OnAbortUpload = func(bucket, key, id string) error { aborted = id; return nil }
+	m.OnCompleteMultipart = func(string, string, string, []minio.CompletePart) (minio.UploadInfo, error) {
+		t.Fatal("completed a failed upload")
+		return minio.UploadInfo{}, nil
+	}
+	opts := MultipartOptions{Threshold: 1 << 20, ChunkSize: MinPartSize, Workers: 2}
+	_, err := UploadAuto(context.Background(), m, "b", "k", &patternReader{n: 30 << 20}, 30<<20, "", nil, opts)
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected part error, got %v", err)
+	}
+	if aborted != "up-1" {
+		t.Fatalf("expected upload up-1 to be aborted, got %q", aborted)
+	}
+}
@@ -0,0 +1,73 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"time"
+
+	"github.com/arencloud/hermes/internal/logging"
+	minio "github.com/minio/minio-go/v7"
+)
+
+// Logger, when set, receives a debug entry for each retried call (set in the API router).
+var Logger logging.Logger
+
+// retry backoff: retryBase doubled per attempt up to retryMax, with ±20% jitter
+var (
+	retryBase = 100 * time.Millisecond
+	retryMax  = 10 * time.Second
+)
+
+// isRetriable reports whether err is a transient failure worth repeating the call for:
+// a temporary or timed-out network error, a connection cut mid-response, or an S3
+// InternalError, ServiceUnavailable or SlowDown.
+func isRetriable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && (ne.Timeout() || ne.Temporary()) {
+		return true
+	}
+	switch minio.ToErrorResponse(err).Code {
+	case "InternalError", "ServiceUnavailable", "SlowDown":
+		return true
+	}
+	return false
+}
+
+// withRetry runs fn up to maxAttempts times while it fails with a retriable error,
+// backing off exponentially in between. It gives up early when ctx is done.
+func withRetry(ctx context.Context, maxAttempts int, fn func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil || attempt >= maxAttempts || !isRetriable(err) || ctx.Err() != nil {
+			return err
+		}
+		if Logger != nil {
+			Logger.Debug("s3 call retried", "component", "s3", "attempt", attempt, "error", err.Error())
+		}
+		t := time.NewTimer(retryDelay(attempt))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+// retryDelay is the backoff before the retry following attempt (1-based).
+func retryDelay(attempt int) time.Duration {
+	d := retryMax
+	if attempt < 20 {
+		d = min(retryBase<<(attempt-1), retryMax)
+	}
+	return time.Duration(float64(d) * (0.8 + 0.4*rand.Float64()))
+}
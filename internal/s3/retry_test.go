@@ -0,0 +1,53 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	minio "github.com/minio/minio-go/v7"
+)
+
+func TestWithRetry(t *testing.T) {
+	prev := retryBase
+	retryBase = time.Millisecond
+	defer func() { retryBase = prev }()
+
+	calls := 0
+	err := withRetry(context.Background(), 3, func() error {
+		if calls++; calls == 1 {
+			return &net.OpError{Op: "read", Net: "tcp", Err: timeoutErr{}}
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Fatalf("expected success on the second attempt, got %v after %d calls", err, calls)
+	}
+
+	calls = 0
+	denied := minio.ErrorResponse{StatusCode: 403, Code: "AccessDenied"}
+	if err := withRetry(context.Background(), 3, func() error { calls++; return denied }); calls != 1 || err == nil {
+		t.Fatalf("a non-transient error must not be retried: %d calls, %v", calls, err)
+	}
+	calls = 0
+	if err := withRetry(context.Background(), 3, func() error { calls++; return io.ErrUnexpectedEOF }); calls != 3 || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected 3 attempts ending in the last error, got %d calls, %v", calls, err)
+	}
+	for _, code := range []string{"InternalError", "ServiceUnavailable", "SlowDown"} {
+		if !isRetriable(minio.ErrorResponse{StatusCode: 503, Code: code}) {
+			t.Errorf("%s should be retriable", code)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for attempt, want := range map[int]time.Duration{1: 100 * time.Millisecond, 4: 800 * time.Millisecond, 10: 10 * time.Second, 64: 10 * time.Second} {
+		d := retryDelay(attempt)
+		if d < want*8/10 || d > want*12/10 {
+			t.Errorf("attempt %d: delay %v outside %v ±20%%", attempt, d, want)
+		}
+	}
+}
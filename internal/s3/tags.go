@@ -0,0 +1,65 @@
+package s3
+
+import (
+	"context"
+	"time"
+
+	minio "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/tags"
+)
+
+// ObjectMeta is the system and user-defined metadata of an object.
+type ObjectMeta struct {
+	ContentType  string            `json:"contentType"`
+	Size         int64             `json:"size"`
+	ETag         string            `json:"etag"`
+	LastModified time.Time         `json:"lastModified"`
+	UserMetadata map[string]string `json:"userMetadata"`
+}
+
+// objectMeta picks the metadata out of a StatObject result.
+func objectMeta(info minio.ObjectInfo) ObjectMeta {
+	m := ObjectMeta{ContentType: info.ContentType, Size: info.Size, ETag: info.ETag, LastModified: info.LastModified, UserMetadata: map[string]string{}}
+	for k, v := range info.UserMetadata {
+		m.UserMetadata[k] = v
+	}
+	return m
+}
+
+// ValidateObjectTags checks tags against the S3 limits: at most 10 tags, keys up to 128
+// and values up to 256 characters, from the allowed character set.
+func ValidateObjectTags(t map[string]string) error {
+	_, err := tags.NewTags(t, true)
+	return err
+}
+
+// GetObjectTags returns the object's user-defined tags.
+func (c *Client) GetObjectTags(ctx context.Context, bucket, key string) (map[string]string, error) {
+	t, err := c.mc.GetObjectTagging(ctx, bucket, key, minio.GetObjectTaggingOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return t.ToMap(), nil
+}
+
+// SetObjectTags replaces the object's tags; an empty map removes them all.
+func (c *Client) SetObjectTags(ctx context.Context, bucket, key string, t map[string]string) error {
+	if len(t) == 0 {
+		return c.mc.RemoveObjectTagging(ctx, bucket, key, minio.RemoveObjectTaggingOptions{})
+	}
+	ot, err := tags.NewTags(t, true)
+	if err != nil {
+		return err
+	}
+	return c.mc.PutObjectTagging(ctx, bucket, key, ot, minio.PutObjectTaggingOptions{})
+}
+
+// GetObjectMeta returns the object's content type, size, ETag, modification time and
+// user metadata (x-amz-meta-* headers, without the prefix).
+func (c *Client) GetObjectMeta(ctx context.Context, bucket, key string) (ObjectMeta, error) {
+	info, err := c.Stat(ctx, bucket, key)
+	if err != nil {
+		return ObjectMeta{}, err
+	}
+	return objectMeta(info), nil
+}
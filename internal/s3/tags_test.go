@@ -0,0 +1,29 @@
+package s3
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateObjectTags(t *testing.T) {
+	ten := map[string]string{}
+	for _, k := range strings.Split("a b c d e f g h i j", " ") {
+		ten[k] = "v"
+	}
+	if err := ValidateObjectTags(ten); err != nil {
+		t.Fatalf("10 tags: %v", err)
+	}
+	ten["k"] = "v"
+	if ValidateObjectTags(ten) == nil {
+		t.Fatal("11 tags accepted")
+	}
+	if ValidateObjectTags(map[string]string{strings.Repeat("k", 129): "v"}) == nil {
+		t.Fatal("129-character key accepted")
+	}
+	if ValidateObjectTags(map[string]string{"k": strings.Repeat("v", 257)}) == nil {
+		t.Fatal("257-character value accepted")
+	}
+	if err := ValidateObjectTags(nil); err != nil {
+		t.Fatalf("no tags: %v", err)
+	}
+}
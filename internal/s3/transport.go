@@ -0,0 +1,74 @@
+package s3
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	minio "github.com/minio/minio-go/v7"
+)
+
+// TransportSettings tune the HTTP transport of every provider client.
+type TransportSettings struct {
+	IdleConnTimeout     time.Duration // how long an unused pooled connection is kept
+	MaxIdleConns        int           // pooled connections kept per client, across hosts
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+}
+
+// DefaultTransportSettings are the S3_IDLE_CONN_TIMEOUT_SEC, S3_MAX_IDLE_CONNS,
+// S3_DIAL_TIMEOUT_SEC and S3_TLS_HANDSHAKE_TIMEOUT_SEC defaults.
+var DefaultTransportSettings = TransportSettings{
+	IdleConnTimeout:     30 * time.Second,
+	MaxIdleConns:        100,
+	DialTimeout:         5 * time.Second,
+	TLSHandshakeTimeout: 5 * time.Second,
+}
+
+var transportSettings = struct {
+	sync.RWMutex
+	s TransportSettings
+}{s: DefaultTransportSettings}
+
+// SetTransportSettings replaces the settings used for clients built from now on; zero
+// fields keep their default.
+func SetTransportSettings(ts TransportSettings) {
+	d := DefaultTransportSettings
+	if ts.IdleConnTimeout <= 0 {
+		ts.IdleConnTimeout = d.IdleConnTimeout
+	}
+	if ts.MaxIdleConns <= 0 {
+		ts.MaxIdleConns = d.MaxIdleConns
+	}
+	if ts.DialTimeout <= 0 {
+		ts.DialTimeout = d.DialTimeout
+	}
+	if ts.TLSHandshakeTimeout <= 0 {
+		ts.TLSHandshakeTimeout = d.TLSHandshakeTimeout
+	}
+	transportSettings.Lock()
+	transportSettings.s = ts
+	transportSettings.Unlock()
+}
+
+// CurrentTransportSettings returns the settings new clients are built with.
+func CurrentTransportSettings() TransportSettings {
+	transportSettings.RLock()
+	defer transportSettings.RUnlock()
+	return transportSettings.s
+}
+
+// newTransport is minio-go's default transport with the current TransportSettings applied.
+func newTransport(secure bool) (*http.Transport, error) {
+	tr, err := minio.DefaultTransport(secure)
+	if err != nil {
+		return nil, err
+	}
+	ts := CurrentTransportSettings()
+	tr.IdleConnTimeout = ts.IdleConnTimeout
+	tr.MaxIdleConns = ts.MaxIdleConns
+	tr.TLSHandshakeTimeout = ts.TLSHandshakeTimeout
+	tr.DialContext = (&net.Dialer{Timeout: ts.DialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	return tr, nil
+}
@@ -0,0 +1,130 @@
+// Package secrets resolves provider credentials kept outside the database, so they can
+// be rotated in the secret backend without touching Hermes' provider records.
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/arencloud/hermes/internal/config"
+)
+
+// CacheTTL is how long a resolved secret is reused before the backend is asked again;
+// a rotated secret is picked up by new connections within this window.
+const CacheTTL = 60 * time.Second
+
+// ErrNotFound is returned when the backend has no value for the key.
+var ErrNotFound = errors.New("secret not found")
+
+// SecretStore reads and writes secrets by key. The key format is backend specific: an
+// environment variable name for EnvSecretStore, a KV path (optionally path#field) for
+// VaultSecretStore.
+type SecretStore interface {
+	Get(key string) (string, error)
+	Set(key, value string) error
+}
+
+var (
+	mu           sync.RWMutex
+	defaultStore SecretStore = NewCachedStore(EnvSecretStore{}, CacheTTL)
+)
+
+// DefaultStore returns the store configured by Init (environment variables until then).
+func DefaultStore() SecretStore {
+	mu.RLock()
+	defer mu.RUnlock()
+	return defaultStore
+}
+
+// SetDefaultStore replaces the default store; tests use it to install a fake backend.
+func SetDefaultStore(s SecretStore) {
+	mu.Lock()
+	defaultStore = s
+	mu.Unlock()
+}
+
+// Init builds the backend selected by SECRETS_BACKEND and installs it, wrapped in a
+// CacheTTL cache, as the default store.
+func Init(cfg *config.Config) error {
+	var s SecretStore
+	switch cfg.SecretsBackend {
+	case "", "env":
+		s = EnvSecretStore{}
+	case "vault":
+		v, err := NewVaultSecretStore(cfg.VaultAddr, cfg.VaultToken, cfg.VaultKVMount)
+		if err != nil {
+			return err
+		}
+		s = v
+	default:
+		return fmt.Errorf("unknown secrets backend %q", cfg.SecretsBackend)
+	}
+	SetDefaultStore(NewCachedStore(s, CacheTTL))
+	return nil
+}
+
+// EnvSecretStore reads secrets from environment variables named by the key.
+type EnvSecretStore struct{}
+
+func (EnvSecretStore) Get(key string) (string, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return "", fmt.Errorf("%w: env %s", ErrNotFound, key)
+	}
+	return v, nil
+}
+
+func (EnvSecretStore) Set(key, value string) error {
+	return os.Setenv(key, value)
+}
+
+type cachedValue struct {
+	value   string
+	fetched time.Time
+}
+
+// CachedStore serves Get from memory for ttl after a successful backend read. Failed
+// reads are not cached, and Set refreshes the entry.
+type CachedStore struct {
+	backend SecretStore
+	ttl     time.Duration
+	now     func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cachedValue
+}
+
+// NewCachedStore wraps backend with a ttl read cache.
+func NewCachedStore(backend SecretStore, ttl time.Duration) *CachedStore {
+	return &CachedStore{backend: backend, ttl: ttl, now: time.Now, entries: map[string]cachedValue{}}
+}
+
+func (c *CachedStore) Get(key string) (string, error) {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && c.now().Sub(e.fetched) < c.ttl {
+		return e.value, nil
+	}
+	v, err := c.backend.Get(key)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	c.entries[key] = cachedValue{value: v, fetched: c.now()}
+	c.mu.Unlock()
+	return v, nil
+}
+
+func (c *CachedStore) Set(key, value string) error {
+	if err := c.backend.Set(key, value); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.entries[key] = cachedValue{value: value, fetched: c.now()}
+	c.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,84 @@
+package secrets
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type countingStore struct {
+	values map[string]string
+	gets   int
+}
+
+func (s *countingStore) Get(key string) (string, error) {
+	s.gets++
+	v, ok := s.values[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return v, nil
+}
+
+func (s *countingStore) Set(key, value string) error {
+	s.values[key] = value
+	return nil
+}
+
+func TestCachedStoreTTL(t *testing.T) {
+	backend := &countingStore{values: map[string]string{"k": "v1"}}
+	c := NewCachedStore(backend, time.Minute)
+	now := time.Unix(1000, 0)
+	c.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if v, err := c.Get("k"); err != nil || v != "v1" {
+			t.Fatalf("get = %q, %v", v, err)
+		}
+	}
+	if backend.gets != 1 {
+		t.Fatalf("expected one backend read within the TTL, got %d", backend.gets)
+	}
+
+	// Rotated in the backend: still cached until the TTL passes
+	backend.values["k"] = "v2"
+	if v, _ := c.Get("k"); v != "v1" {
+		t.Fatalf("expected cached v1, got %q", v)
+	}
+	now = now.Add(time.Minute)
+	if v, _ := c.Get("k"); v != "v2" {
+		t.Fatalf("expected rotated v2 after TTL, got %q", v)
+	}
+}
+
+func TestCachedStoreDoesNotCacheMisses(t *testing.T) {
+	backend := &countingStore{values: map[string]string{}}
+	c := NewCachedStore(backend, time.Minute)
+	if _, err := c.Get("k"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	backend.values["k"] = "v"
+	if v, err := c.Get("k"); err != nil || v != "v" {
+		t.Fatalf("get after miss = %q, %v", v, err)
+	}
+}
+
+func TestEnvSecretStore(t *testing.T) {
+	t.Setenv("HERMES_TEST_SECRET", "s3cret")
+	var s EnvSecretStore
+	if v, err := s.Get("HERMES_TEST_SECRET"); err != nil || v != "s3cret" {
+		t.Fatalf("get = %q, %v", v, err)
+	}
+	if _, err := s.Get("HERMES_TEST_SECRET_MISSING"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestSplitVaultKey(t *testing.T) {
+	if p, f := splitVaultKey("hermes/prod"); p != "hermes/prod" || f != DefaultVaultField {
+		t.Fatalf("got %q %q", p, f)
+	}
+	if p, f := splitVaultKey("hermes/prod#sk"); p != "hermes/prod" || f != "sk" {
+		t.Fatalf("got %q %q", p, f)
+	}
+}
@@ -0,0 +1,95 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// DefaultVaultField is the KV field read when a key names no field.
+const DefaultVaultField = "secretKey"
+
+const vaultTimeout = 10 * time.Second
+
+// VaultSecretStore reads and writes fields of HashiCorp Vault KV v2 secrets. Keys are
+// "path" or "path#field" relative to the KV mount, e.g. "hermes/providers/prod#secretKey".
+type VaultSecretStore struct {
+	kv *vault.KVv2
+}
+
+// NewVaultSecretStore connects to the Vault at addr with token, using the KV v2 engine
+// mounted at mount (default "secret"). Empty addr/token fall back to the vault client's
+// own VAULT_ADDR/VAULT_TOKEN handling.
+func NewVaultSecretStore(addr, token, mount string) (*VaultSecretStore, error) {
+	cfg := vault.DefaultConfig()
+	if cfg.Error != nil {
+		return nil, cfg.Error
+	}
+	if addr != "" {
+		cfg.Address = addr
+	}
+	c, err := vault.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		c.SetToken(token)
+	}
+	if c.Token() == "" {
+		return nil, errors.New("VAULT_TOKEN is required when SECRETS_BACKEND=vault")
+	}
+	if mount == "" {
+		mount = "secret"
+	}
+	return &VaultSecretStore{kv: c.KVv2(mount)}, nil
+}
+
+func splitVaultKey(key string) (path, field string) {
+	path, field, _ = strings.Cut(key, "#")
+	if field == "" {
+		field = DefaultVaultField
+	}
+	return path, field
+}
+
+func (s *VaultSecretStore) Get(key string) (string, error) {
+	path, field := splitVaultKey(key)
+	ctx, cancel := context.WithTimeout(context.Background(), vaultTimeout)
+	defer cancel()
+	sec, err := s.kv.Get(ctx, path)
+	if errors.Is(err, vault.ErrSecretNotFound) {
+		return "", fmt.Errorf("%w: vault %s", ErrNotFound, path)
+	}
+	if err != nil {
+		return "", err
+	}
+	v, ok := sec.Data[field].(string)
+	if !ok || v == "" {
+		return "", fmt.Errorf("%w: vault %s#%s", ErrNotFound, path, field)
+	}
+	return v, nil
+}
+
+// Set writes field as a new version of the secret, keeping its other fields.
+func (s *VaultSecretStore) Set(key, value string) error {
+	path, field := splitVaultKey(key)
+	ctx, cancel := context.WithTimeout(context.Background(), vaultTimeout)
+	defer cancel()
+	data := map[string]any{}
+	sec, err := s.kv.Get(ctx, path)
+	switch {
+	case err == nil:
+		for k, v := range sec.Data {
+			data[k] = v
+		}
+	case !errors.Is(err, vault.ErrSecretNotFound):
+		return err
+	}
+	data[field] = value
+	_, err = s.kv.Put(ctx, path, data)
+	return err
+}
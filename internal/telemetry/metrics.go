@@ -0,0 +1,83 @@
+// Package telemetry exports Hermes request metrics over OpenTelemetry (OTLP/gRPC).
+package telemetry
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// PushInterval is how often metrics are pushed to the collector.
+const PushInterval = 15 * time.Second
+
+var (
+	mu        sync.RWMutex
+	requests  metric.Int64Counter
+	durations metric.Float64Histogram
+)
+
+// InitMetrics starts pushing metrics to the OTLP/gRPC collector at endpoint (host:port or
+// an http(s) URL; http:// disables TLS). The returned func flushes and stops the exporter.
+func InitMetrics(ctx context.Context, endpoint string) (func(context.Context) error, error) {
+	var opts []otlpmetricgrpc.Option
+	if strings.Contains(endpoint, "://") {
+		opts = append(opts, otlpmetricgrpc.WithEndpointURL(endpoint))
+	} else {
+		opts = append(opts, otlpmetricgrpc.WithEndpoint(endpoint))
+	}
+	exp, err := otlpmetricgrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exp, sdkmetric.WithInterval(PushInterval))))
+	otel.SetMeterProvider(mp)
+	if err := Use(mp); err != nil {
+		_ = mp.Shutdown(ctx)
+		return nil, err
+	}
+	return mp.Shutdown, nil
+}
+
+// Use creates the request instruments on mp. InitMetrics calls it; tests pass a provider
+// backed by a manual reader.
+func Use(mp metric.MeterProvider) error {
+	m := mp.Meter("github.com/arencloud/hermes")
+	c, err := m.Int64Counter("hermes_requests_total", metric.WithDescription("HTTP requests served"))
+	if err != nil {
+		return err
+	}
+	h, err := m.Float64Histogram("hermes_request_duration", metric.WithDescription("HTTP request latency"), metric.WithUnit("s"))
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	requests, durations = c, h
+	mu.Unlock()
+	return nil
+}
+
+// RecordRequest counts a completed request and its latency. route should be the route
+// pattern rather than the raw path to keep cardinality bounded. It is a no-op until
+// metrics are initialised.
+func RecordRequest(ctx context.Context, method, route string, status int, d time.Duration) {
+	mu.RLock()
+	c, h := requests, durations
+	mu.RUnlock()
+	if c == nil {
+		return
+	}
+	attrs := metric.WithAttributes(
+		attribute.String("method", method),
+		attribute.String("route", route),
+		attribute.Int("status", status),
+	)
+	c.Add(ctx, 1, attrs)
+	h.Record(ctx, d.Seconds(), attrs)
+}
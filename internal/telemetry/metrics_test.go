@@ -0,0 +1,62 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestRecordRequest(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	if err := Use(mp); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		mu.Lock()
+		requests, durations = nil, nil
+		mu.Unlock()
+	})
+	ctx := context.Background()
+	RecordRequest(ctx, "GET", "/api/v1/providers", 200, 20*time.Millisecond)
+	RecordRequest(ctx, "GET", "/api/v1/providers", 200, 40*time.Millisecond)
+	RecordRequest(ctx, "POST", "/api/v1/providers", 400, time.Millisecond)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]bool{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			found[m.Name] = true
+			switch d := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				var total int64
+				for _, p := range d.DataPoints {
+					total += p.Value
+				}
+				if total != 3 || len(d.DataPoints) != 2 {
+					t.Fatalf("requests_total: %d over %d series, want 3 over 2", total, len(d.DataPoints))
+				}
+			case metricdata.Histogram[float64]:
+				for _, p := range d.DataPoints {
+					if p.Count == 2 && (p.Sum < 0.059 || p.Sum > 0.061) {
+						t.Fatalf("duration sum = %v, want 0.06", p.Sum)
+					}
+				}
+			}
+		}
+	}
+	if !found["hermes_requests_total"] || !found["hermes_request_duration"] {
+		t.Fatalf("missing instruments: %v", found)
+	}
+}
+
+func TestRecordRequestNoop(t *testing.T) {
+	// must not panic before InitMetrics
+	RecordRequest(context.Background(), "GET", "/health", 200, time.Millisecond)
+}
@@ -0,0 +1,132 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+
+	"github.com/arencloud/hermes/internal/logging"
+)
+
+// ErrClosed is returned by Submit after Shutdown has been called.
+var ErrClosed = errors.New("worker pool closed")
+
+// Pool runs submitted functions on a fixed number of goroutines fed by a bounded queue.
+// A panicking task is recovered and logged; it never takes down the pool.
+type Pool struct {
+	logger  logging.Logger
+	workers int
+	queue   chan func()
+	wg      sync.WaitGroup
+
+	mu      sync.RWMutex // guards closed against concurrent Submit/Shutdown
+	closed  bool
+	done    chan struct{}  // closed by Shutdown; releases Submits blocked on a full queue
+	senders sync.WaitGroup // Submits that may still send on queue
+
+	processed uint64
+	panics    uint64
+}
+
+// Stats is a point-in-time snapshot of pool activity.
+type Stats struct {
+	Workers   int    `json:"workers"`
+	QueueLen  int    `json:"queueLen"`
+	Processed uint64 `json:"processed"`
+	Panics    uint64 `json:"panics"`
+}
+
+// New starts a pool with the given number of workers and queue capacity.
+func New(workers, queueSize int, logger logging.Logger) *Pool {
+	if workers <= 0 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	p := &Pool{logger: logger, workers: workers, queue: make(chan func(), queueSize), done: make(chan struct{})}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.run()
+	}
+	return p
+}
+
+func (p *Pool) run() {
+	defer p.wg.Done()
+	for fn := range p.queue {
+		p.exec(fn)
+	}
+}
+
+func (p *Pool) exec(fn func()) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			atomic.AddUint64(&p.panics, 1)
+			p.logger.Error("worker task panicked", "error", rec, "stack", string(debug.Stack()))
+		}
+		atomic.AddUint64(&p.processed, 1)
+	}()
+	fn()
+}
+
+// Submit enqueues fn, blocking while the queue is full until ctx is done or the pool
+// is shut down.
+func (p *Pool) Submit(ctx context.Context, fn func()) error {
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		return ErrClosed
+	}
+	p.senders.Add(1)
+	p.mu.RUnlock()
+	defer p.senders.Done()
+	select {
+	case p.queue <- fn:
+		return nil
+	case <-p.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown stops accepting tasks and waits for queued and in-flight tasks to finish.
+// Submits blocked on a full queue return ErrClosed. Shutdown returns ctx.Err() if ctx
+// expires first; the remaining tasks keep running.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.done)
+		go func() {
+			// no Submit can start now; the queue closes once the running ones are out
+			p.senders.Wait()
+			close(p.queue)
+		}()
+	}
+	p.mu.Unlock()
+	finished := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns the current pool counters.
+func (p *Pool) Stats() Stats {
+	return Stats{
+		Workers:   p.workers,
+		QueueLen:  len(p.queue),
+		Processed: atomic.LoadUint64(&p.processed),
+		Panics:    atomic.LoadUint64(&p.panics),
+	}
+}
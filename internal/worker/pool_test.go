@@ -0,0 +1,86 @@
+package worker
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/arencloud/hermes/internal/logging"
+)
+
+func TestPoolRunsTasksAndRecoversPanics(t *testing.T) {
+	p := New(4, 8, logging.New("test"))
+	var ran int64
+	for i := 0; i < 20; i++ {
+		i := i
+		if err := p.Submit(context.Background(), func() {
+			if i%5 == 0 {
+				panic("boom")
+			}
+			atomic.AddInt64(&ran, 1)
+		}); err != nil {
+			t.Fatalf("submit: %v", err)
+		}
+	}
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	st := p.Stats()
+	if ran != 16 || st.Processed != 20 || st.Panics != 4 || st.Workers != 4 {
+		t.Fatalf("unexpected result ran=%d stats=%+v", ran, st)
+	}
+	if err := p.Submit(context.Background(), func() {}); err != ErrClosed {
+		t.Fatalf("submit after shutdown: %v", err)
+	}
+}
+
+func TestPoolSubmitBlocksUntilContextDone(t *testing.T) {
+	p := New(1, 0, logging.New("test"))
+	release := make(chan struct{})
+	if err := p.Submit(context.Background(), func() { <-release }); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := p.Submit(ctx, func() {}); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	sctx, scancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer scancel()
+	if err := p.Shutdown(sctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected shutdown timeout while a task is in flight, got %v", err)
+	}
+	close(release)
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+}
+
+func TestShutdownReleasesBlockedSubmit(t *testing.T) {
+	p := New(1, 0, logging.New("test"))
+	release := make(chan struct{})
+	if err := p.Submit(context.Background(), func() { <-release }); err != nil {
+		t.Fatal(err)
+	}
+	blocked := make(chan error, 1)
+	go func() { blocked <- p.Submit(context.Background(), func() {}) }()
+	time.Sleep(20 * time.Millisecond) // let the second Submit block on the full queue
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := p.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected shutdown to honour ctx while a task is in flight, got %v", err)
+	}
+	select {
+	case err := <-blocked:
+		if err != ErrClosed {
+			t.Fatalf("blocked submit: %v, want ErrClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("submit still blocked after shutdown")
+	}
+	close(release)
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+}